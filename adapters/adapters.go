@@ -0,0 +1,122 @@
+// Package adapters encapsulates the quirks of networks that deviate from Tendermint's
+// defaults, like custom vote types, different RPC paths or nonstandard commit layouts.
+// Each network's quirks are implemented by an Adapter, which is registered for the
+// network's chain ID and selected via the chain_id in the [privval] section, so that
+// supporting a new network doesn't require changes to the privval and rpc packages.
+package adapters
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+// Adapter encapsulates the quirks of a network.
+type Adapter interface {
+	// Name returns the name of the adapter.
+	Name() string
+
+	// BlockPath returns the path, including the query, of the validator's RPC
+	// endpoint that the block at the given height is queried from.
+	BlockPath(height int64) string
+
+	// IsValidVoteType returns true if votes of the given type may be signed.
+	IsValidVoteType(t tm_typesproto.SignedMsgType) bool
+
+	// HasSignedCommit returns true if the last commit of the given block contains a
+	// signature of the validator with the given address.
+	HasSignedCommit(valaddr tm_types.Address, block *tm_types.Block) bool
+}
+
+// Default implements the Adapter interface for networks that follow Tendermint's
+// defaults. It is used for all chains that have no adapter registered.
+type Default struct{}
+
+// Default must implement the Adapter interface.
+var _ Adapter = Default{}
+
+// Name returns the name of the adapter.
+// Implements the Adapter interface.
+func (Default) Name() string {
+	return "default"
+}
+
+// BlockPath returns the path of Tendermint's /block endpoint.
+// Implements the Adapter interface.
+func (Default) BlockPath(height int64) string {
+	return fmt.Sprintf("/block?height=%v", height)
+}
+
+// IsValidVoteType returns true for prevotes and precommits.
+// Implements the Adapter interface.
+func (Default) IsValidVoteType(t tm_typesproto.SignedMsgType) bool {
+	return t == tm_typesproto.PrevoteType || t == tm_typesproto.PrecommitType
+}
+
+// HasSignedCommit checks the commitsigs of the block's last commit.
+// Implements the Adapter interface.
+func (Default) HasSignedCommit(valaddr tm_types.Address, block *tm_types.Block) bool {
+	if block == nil || block.LastCommit == nil {
+		return false
+	}
+	for _, commitsig := range block.LastCommit.Signatures {
+		if bytes.Equal(commitsig.ValidatorAddress, valaddr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	// adaptersMtx guards adapters.
+	adaptersMtx sync.RWMutex
+
+	// adapters maps chain IDs to the adapters of their networks.
+	adapters = make(map[string]Adapter)
+)
+
+// Register registers a for the given chain ID. An already registered adapter for the
+// same chain ID is replaced, and passing a nil adapter removes the registration.
+func Register(chainID string, a Adapter) {
+	adaptersMtx.Lock()
+	defer adaptersMtx.Unlock()
+
+	if a == nil {
+		delete(adapters, chainID)
+		return
+	}
+	adapters[chainID] = a
+}
+
+// For returns the adapter registered for the given chain ID, or Default if there is
+// none.
+func For(chainID string) Adapter {
+	adaptersMtx.RLock()
+	defer adaptersMtx.RUnlock()
+
+	if a, ok := adapters[chainID]; ok {
+		return a
+	}
+
+	return Default{}
+}
+
+// ChainIDs returns the chain IDs that have an adapter registered in alphabetical
+// order.
+func ChainIDs() []string {
+	adaptersMtx.RLock()
+	defer adaptersMtx.RUnlock()
+
+	chainIDs := make([]string, 0, len(adapters))
+	for chainID := range adapters {
+		chainIDs = append(chainIDs, chainID)
+	}
+	sort.Strings(chainIDs)
+
+	return chainIDs
+}
@@ -0,0 +1,74 @@
+package adapters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+func testBlock(t *testing.T) *tm_types.Block {
+	t.Helper()
+	return &tm_types.Block{
+		LastCommit: &tm_types.Commit{
+			Signatures: []tm_types.CommitSig{
+				{
+					ValidatorAddress: []byte("ALPHA-ADDR"),
+					Signature:        []byte("ALPHA-SIG"),
+				},
+				{
+					ValidatorAddress: []byte("BETA-ADDR"),
+					Signature:        []byte("BETA-SIG"),
+				},
+			},
+		},
+	}
+}
+
+func TestDefault_BlockPath(t *testing.T) {
+	assert.Equal(t, "/block?height=10", Default{}.BlockPath(10))
+}
+
+func TestDefault_IsValidVoteType(t *testing.T) {
+	assert.True(t, Default{}.IsValidVoteType(tm_typesproto.PrevoteType))
+	assert.True(t, Default{}.IsValidVoteType(tm_typesproto.PrecommitType))
+	assert.False(t, Default{}.IsValidVoteType(tm_typesproto.ProposalType))
+	assert.False(t, Default{}.IsValidVoteType(tm_typesproto.UnknownType))
+}
+
+func TestDefault_HasSignedCommit(t *testing.T) {
+	signed := Default{}.HasSignedCommit([]byte("ALPHA-ADDR"), testBlock(t))
+	assert.True(t, signed)
+
+	signed = Default{}.HasSignedCommit([]byte("BETA-SIG"), testBlock(t))
+	assert.False(t, signed)
+
+	signed = Default{}.HasSignedCommit([]byte("GAMMA"), testBlock(t))
+	assert.False(t, signed)
+
+	// Blocks without a last commit, like the genesis block, contain no signatures.
+	signed = Default{}.HasSignedCommit([]byte("ALPHA-ADDR"), &tm_types.Block{})
+	assert.False(t, signed)
+}
+
+// customAdapter is an adapter for a network that only differs from the default by
+// its name.
+type customAdapter struct {
+	Default
+}
+
+func (customAdapter) Name() string { return "custom" }
+
+func TestRegister(t *testing.T) {
+	assert.Equal(t, Default{}, For("customchain"))
+
+	Register("customchain", customAdapter{})
+	assert.Equal(t, "custom", For("customchain").Name())
+	assert.Equal(t, "default", For("otherchain").Name())
+	assert.Equal(t, []string{"customchain"}, ChainIDs())
+
+	Register("customchain", nil)
+	assert.Equal(t, Default{}, For("customchain"))
+	assert.Empty(t, ChainIDs())
+}
@@ -0,0 +1,228 @@
+// Package alerts pushes alerts about rank changes, shutdowns and connection losses to
+// webhooks, PagerDuty and Slack, so that operators can be paged without scraping the logs. Alerts are
+// queued and sent in the background, so that a slow or unreachable webhook never
+// delays signing.
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+const (
+	// queueSize is the number of alerts that can be queued before new ones are
+	// dropped.
+	queueSize = 64
+
+	// attempts is the number of times an alert is sent to a notifier before it is
+	// given up on.
+	attempts = 3
+
+	// requestTimeout is the maximum time a single request to a notifier may take.
+	requestTimeout = 10 * time.Second
+)
+
+// Severities of the alerts, in the order of their urgency. They are the ones of
+// PagerDuty's Events API v2.
+const (
+	SeverityCritical = "critical"
+	SeverityError    = "error"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// ValidSeverity returns true if s is one of the severities.
+func ValidSeverity(s string) bool {
+	switch s {
+	case SeverityCritical, SeverityError, SeverityWarning, SeverityInfo:
+		return true
+	}
+
+	return false
+}
+
+// retryDelay is the time waited before an alert is sent to a notifier again. It is
+// doubled after every attempt.
+var retryDelay = time.Second
+
+// Alert is the JSON body POSTed to the webhooks.
+type Alert struct {
+	// Node is the name of the node the alert is about.
+	Node string `json:"node"`
+
+	// ChainID is the ID of the chain the node signs for.
+	ChainID string `json:"chain_id"`
+
+	// Type is the type of the watchtower event the alert was sent for, e.g. promoted.
+	Type string `json:"type"`
+
+	// Severity is the severity of the alert, e.g. SeverityWarning.
+	Severity string `json:"severity"`
+
+	// Time is the time the event occurred at.
+	Time time.Time `json:"time"`
+
+	// OldRank is the node's rank before the event, and NewRank the one after it. Both
+	// are the same if the event didn't change the rank.
+	OldRank int `json:"old_rank"`
+	NewRank int `json:"new_rank"`
+
+	// Height is the height of the last sign request the node received.
+	Height int64 `json:"height"`
+
+	// Reason is a human-readable description of the event.
+	Reason string `json:"reason"`
+}
+
+// summary returns a one-line description of a for the notifiers' messages.
+func summary(a Alert) string {
+	return fmt.Sprintf("[%v] %v on %v: %v", a.Severity, a.Node, a.ChainID, a.Reason)
+}
+
+// Notifier sends alerts to an external service.
+type Notifier interface {
+	// Notify sends a to the service. It returns an error if the service couldn't be
+	// reached or didn't accept the alert.
+	Notify(ctx context.Context, a Alert) error
+}
+
+// Webhook is a Notifier that POSTs the alerts as JSON to a URL.
+// Implements the Notifier interface.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Webhook must implement the Notifier interface.
+var _ Notifier = Webhook{}
+
+// Notify POSTs a to the webhook's URL.
+// Implements the Notifier interface.
+func (w Webhook) Notify(ctx context.Context, a Alert) error {
+	return postJSON(ctx, w.Client, w.URL, a)
+}
+
+// postJSON POSTs v as JSON to url and returns an error if the response status isn't
+// 2xx.
+func postJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%v: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	return nil
+}
+
+// Dispatcher queues alerts and sends them to its notifiers in the background.
+type Dispatcher struct {
+	notifiers []Notifier
+	logger    types.Logger
+
+	mtx    sync.Mutex // guards queue and closed
+	queue  chan Alert
+	closed bool
+	done   chan struct{}
+}
+
+// NewDispatcher creates a new Dispatcher sending the alerts to the given notifiers.
+// Run must be called to send them.
+func NewDispatcher(notifiers []Notifier, logger types.Logger) *Dispatcher {
+	return &Dispatcher{
+		notifiers: notifiers,
+		logger:    logger,
+		queue:     make(chan Alert, queueSize),
+		done:      make(chan struct{}),
+	}
+}
+
+// Send queues a to be sent to the notifiers without blocking. The alert is dropped if
+// the queue is full or the dispatcher was closed.
+func (d *Dispatcher) Send(a Alert) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.closed {
+		return
+	}
+	select {
+	case d.queue <- a:
+	default:
+		d.logger.Warn("Dropped %v alert, too many alerts are queued", a.Type)
+	}
+}
+
+// Run sends the queued alerts to the notifiers until the dispatcher is closed and all
+// alerts queued until then were sent.
+func (d *Dispatcher) Run() {
+	defer close(d.done)
+
+	for a := range d.queue {
+		for _, n := range d.notifiers {
+			d.notify(n, a)
+		}
+	}
+}
+
+// notify sends a to n, retrying failed attempts with an increasing delay.
+func (d *Dispatcher) notify(n Notifier, a Alert) {
+	delay := retryDelay
+	for i := 1; ; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		err := n.Notify(ctx, a)
+		cancel()
+		if err == nil {
+			return
+		}
+		if i == attempts {
+			d.logger.Error("couldn't send %v alert via %T: %v", a.Type, n, err)
+			return
+		}
+		d.logger.Debug("couldn't send %v alert via %T, retrying in %v: %v", a.Type, n, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// Close stops accepting alerts and waits up to timeout for the queued ones to be
+// sent, so that the alerts about a shutdown aren't lost.
+func (d *Dispatcher) Close(timeout time.Duration) {
+	d.mtx.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mtx.Unlock()
+
+	select {
+	case <-d.done:
+	case <-time.After(timeout):
+		d.logger.Warn("Gave up on sending the queued alerts after %v", timeout)
+	}
+}
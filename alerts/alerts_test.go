@@ -0,0 +1,103 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testWebhook is a webhook server recording the alerts it receives. The first fail
+// requests are answered with an error.
+type testWebhook struct {
+	*httptest.Server
+
+	mtx    sync.Mutex
+	fail   int
+	alerts []Alert
+}
+
+func newTestWebhook(t *testing.T, fail int) *testWebhook {
+	t.Helper()
+	w := &testWebhook{fail: fail}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w.mtx.Lock()
+		defer w.mtx.Unlock()
+		if w.fail > 0 {
+			w.fail--
+			http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var a Alert
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.alerts = append(w.alerts, a)
+	}))
+	t.Cleanup(w.Close)
+
+	return w
+}
+
+// received returns the alerts received so far.
+func (w *testWebhook) received() []Alert {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	return append([]Alert{}, w.alerts...)
+}
+
+func testLogger() types.Logger {
+	return types.NewSyncLogger(os.Stderr, "", 0)
+}
+
+func TestWebhook_Notify(t *testing.T) {
+	w := newTestWebhook(t, 1)
+	a := Alert{Node: "node-a", ChainID: "testchain", Type: "promoted", OldRank: 2, NewRank: 1, Height: 10, Reason: "Promoted to rank 1"}
+
+	// The webhook's error is returned.
+	err := Webhook{URL: w.URL}.Notify(context.Background(), a)
+	assert.EqualError(t, err, "503 Service Unavailable: unavailable")
+
+	require.NoError(t, Webhook{URL: w.URL}.Notify(context.Background(), a))
+	assert.Equal(t, []Alert{a}, w.received())
+}
+
+func TestDispatcher(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	ok, failing := newTestWebhook(t, 2), newTestWebhook(t, attempts)
+	d := NewDispatcher([]Notifier{Webhook{URL: ok.URL}, Webhook{URL: failing.URL}}, testLogger())
+	go d.Run()
+
+	d.Send(Alert{Type: "promoted"})
+	d.Send(Alert{Type: "must_shutdown"})
+
+	// The queued alerts are sent before Close returns. Failed attempts are retried,
+	// but only up to the maximum number of attempts.
+	d.Close(5 * time.Second)
+	assert.Equal(t, []Alert{{Type: "promoted"}, {Type: "must_shutdown"}}, ok.received())
+	assert.Equal(t, []Alert{{Type: "must_shutdown"}}, failing.received())
+
+	// Alerts are dropped once the dispatcher is closed.
+	d.Send(Alert{Type: "demoted"})
+	assert.Len(t, ok.received(), 2)
+}
+
+func TestDispatcher_QueueFull(t *testing.T) {
+	d := NewDispatcher(nil, testLogger())
+	for i := 0; i < queueSize+1; i++ {
+		d.Send(Alert{Type: "missed_too_many"})
+	}
+	assert.Len(t, d.queue, queueSize)
+}
@@ -0,0 +1,74 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultPagerDutyURL is the endpoint of PagerDuty's Events API v2.
+const DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty is a Notifier that triggers incidents via PagerDuty's Events API v2.
+// Implements the Notifier interface.
+type PagerDuty struct {
+	// RoutingKey is the integration key of the PagerDuty service.
+	RoutingKey string
+
+	// URL is the endpoint the events are sent to. Defaults to DefaultPagerDutyURL.
+	URL string
+
+	Client *http.Client
+}
+
+// PagerDuty must implement the Notifier interface.
+var _ Notifier = PagerDuty{}
+
+// pagerDutyEvent is the body of a request to the Events API v2.
+type pagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     pagerDutyPayload `json:"payload"`
+}
+
+// pagerDutyPayload is the payload of a pagerDutyEvent.
+type pagerDutyPayload struct {
+	Summary       string `json:"summary"`
+	Source        string `json:"source"`
+	Severity      string `json:"severity"`
+	Timestamp     string `json:"timestamp"`
+	Component     string `json:"component"`
+	Class         string `json:"class"`
+	CustomDetails Alert  `json:"custom_details"`
+}
+
+// Notify triggers an incident for a. Alerts of the same type from the same node are
+// deduplicated into one incident by PagerDuty.
+// Implements the Notifier interface.
+func (p PagerDuty) Notify(ctx context.Context, a Alert) error {
+	url := p.URL
+	if url == "" {
+		url = DefaultPagerDutyURL
+	}
+	severity := a.Severity
+	if severity == "" {
+		severity = SeverityInfo
+	}
+
+	return postJSON(ctx, p.Client, url, pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    fmt.Sprintf("signctrl/%v/%v/%v", a.ChainID, a.Node, a.Type),
+		Payload: pagerDutyPayload{
+			Summary:       summary(a),
+			Source:        a.Node,
+			Severity:      severity,
+			Timestamp:     a.Time.UTC().Format(time.RFC3339),
+			Component:     a.ChainID,
+			Class:         a.Type,
+			CustomDetails: a,
+		},
+	})
+}
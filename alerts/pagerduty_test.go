@@ -0,0 +1,47 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagerDuty_Notify(t *testing.T) {
+	var event pagerDutyEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	a := Alert{
+		Node:     "node-a",
+		ChainID:  "testchain",
+		Type:     "must_shutdown",
+		Severity: SeverityCritical,
+		Time:     time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		OldRank:  1,
+		NewRank:  1,
+		Reason:   "Shutting down",
+	}
+	require.NoError(t, PagerDuty{RoutingKey: "key", URL: srv.URL}.Notify(context.Background(), a))
+	assert.Equal(t, "key", event.RoutingKey)
+	assert.Equal(t, "trigger", event.EventAction)
+	assert.Equal(t, "signctrl/testchain/node-a/must_shutdown", event.DedupKey)
+	assert.Equal(t, "[critical] node-a on testchain: Shutting down", event.Payload.Summary)
+	assert.Equal(t, "node-a", event.Payload.Source)
+	assert.Equal(t, SeverityCritical, event.Payload.Severity)
+	assert.Equal(t, "2021-01-01T00:00:00Z", event.Payload.Timestamp)
+	assert.Equal(t, a, event.Payload.CustomDetails)
+
+	// PagerDuty rejects events without a severity.
+	a.Severity = ""
+	require.NoError(t, PagerDuty{RoutingKey: "key", URL: srv.URL}.Notify(context.Background(), a))
+	assert.Equal(t, SeverityInfo, event.Payload.Severity)
+}
@@ -0,0 +1,61 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Slack is a Notifier that posts the alerts as messages via a Slack incoming webhook.
+// Implements the Notifier interface.
+type Slack struct {
+	URL    string
+	Client *http.Client
+}
+
+// Slack must implement the Notifier interface.
+var _ Notifier = Slack{}
+
+// slackColors are the colors of the messages' attachments by severity.
+var slackColors = map[string]string{
+	SeverityCritical: "#d32f2f",
+	SeverityError:    "#f57c00",
+	SeverityWarning:  "#fbc02d",
+	SeverityInfo:     "#1976d2",
+}
+
+// slackMessage is the body of a request to a Slack incoming webhook.
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+// slackAttachment is an attachment of a slackMessage.
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Fields []slackField `json:"fields"`
+}
+
+// slackField is a field of a slackAttachment.
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// Notify posts a as a message to the webhook's channel.
+// Implements the Notifier interface.
+func (s Slack) Notify(ctx context.Context, a Alert) error {
+	return postJSON(ctx, s.Client, s.URL, slackMessage{
+		Text: summary(a),
+		Attachments: []slackAttachment{{
+			Color: slackColors[a.Severity],
+			Fields: []slackField{
+				{Title: "Event", Value: a.Type, Short: true},
+				{Title: "Severity", Value: a.Severity, Short: true},
+				{Title: "Rank", Value: fmt.Sprintf("%v → %v", a.OldRank, a.NewRank), Short: true},
+				{Title: "Height", Value: fmt.Sprint(a.Height), Short: true},
+			},
+		}},
+	})
+}
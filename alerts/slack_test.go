@@ -0,0 +1,27 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlack_Notify(t *testing.T) {
+	var msg slackMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+	}))
+	defer srv.Close()
+
+	a := Alert{Node: "node-a", ChainID: "testchain", Type: "promoted", Severity: SeverityWarning, OldRank: 2, NewRank: 1, Height: 10, Reason: "Promoted to rank 1"}
+	require.NoError(t, Slack{URL: srv.URL}.Notify(context.Background(), a))
+	assert.Equal(t, "[warning] node-a on testchain: Promoted to rank 1", msg.Text)
+	require.Len(t, msg.Attachments, 1)
+	assert.Equal(t, slackColors[SeverityWarning], msg.Attachments[0].Color)
+	assert.Contains(t, msg.Attachments[0].Fields, slackField{Title: "Rank", Value: "2 → 1", Short: true})
+}
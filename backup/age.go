@@ -0,0 +1,96 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// The backups are encrypted with age (https://age-encryption.org/v1) to a single
+// X25519 recipient, so that they can also be decrypted with the age CLI.
+
+var (
+	// ErrNoIdentityMatched is returned if a backup wasn't encrypted to the identity it
+	// is decrypted with.
+	ErrNoIdentityMatched = errors.New("backup wasn't encrypted to the given identity")
+
+	// ErrMalformedBackup is returned if a backup isn't a valid age file.
+	ErrMalformedBackup = errors.New("malformed backup")
+)
+
+// Recipient is an age X25519 public key the backups are encrypted to.
+type Recipient = age.X25519Recipient
+
+// Identity is an age X25519 private key the backups are decrypted with.
+type Identity = age.X25519Identity
+
+// ParseRecipient parses an age public key, e.g. "age1...".
+func ParseRecipient(s string) (*Recipient, error) {
+	r, err := age.ParseX25519Recipient(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid age recipient: %w", err)
+	}
+
+	return r, nil
+}
+
+// GenerateIdentity generates a new age identity.
+func GenerateIdentity() (*Identity, error) {
+	return age.GenerateX25519Identity()
+}
+
+// ParseIdentity parses an age identity file holding a single private key, e.g.
+// "AGE-SECRET-KEY-1...". Comments and empty lines are ignored.
+func ParseIdentity(s string) (*Identity, error) {
+	ids, err := age.ParseIdentities(strings.NewReader(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid age identity: %w", err)
+	}
+	if len(ids) != 1 {
+		return nil, fmt.Errorf("expected a single age identity, found %v", len(ids))
+	}
+	id, ok := ids[0].(*Identity)
+	if !ok {
+		return nil, errors.New("invalid age identity: not an X25519 identity")
+	}
+
+	return id, nil
+}
+
+// Encrypt encrypts plaintext to the given recipient.
+func Encrypt(plaintext []byte, r *Recipient) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decrypt decrypts ciphertext with the given identity.
+func Decrypt(ciphertext []byte, id *Identity) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), id)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return nil, ErrNoIdentityMatched
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedBackup, err)
+	}
+	plaintext, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedBackup, err)
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentity(t *testing.T) {
+	id, err := GenerateIdentity()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(id.String(), "AGE-SECRET-KEY-1"))
+	assert.True(t, strings.HasPrefix(id.Recipient().String(), "age1"))
+
+	parsed, err := ParseIdentity("# created: 2021-01-01T00:00:00Z\n# public key: " + id.Recipient().String() + "\n" + id.String() + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, id, parsed)
+
+	r, err := ParseRecipient(id.Recipient().String())
+	require.NoError(t, err)
+	assert.Equal(t, id.Recipient(), r)
+
+	_, err = ParseRecipient(id.String())
+	assert.Error(t, err)
+	_, err = ParseIdentity(id.Recipient().String())
+	assert.Error(t, err)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	id, err := GenerateIdentity()
+	require.NoError(t, err)
+
+	// Empty, single-chunk, exactly full and multi-chunk payloads.
+	for _, size := range []int{0, 100, 64 << 10, 2*64<<10 + 1} {
+		plaintext := bytes.Repeat([]byte{'x'}, size)
+		ciphertext, err := Encrypt(plaintext, id.Recipient())
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(ciphertext, []byte("age-encryption.org/v1\n-> X25519 ")))
+
+		decrypted, err := Decrypt(ciphertext, id)
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, append([]byte{}, decrypted...))
+	}
+}
+
+func TestDecrypt_Invalid(t *testing.T) {
+	id, err := GenerateIdentity()
+	require.NoError(t, err)
+	ciphertext, err := Encrypt([]byte("watermark"), id.Recipient())
+	require.NoError(t, err)
+
+	other, err := GenerateIdentity()
+	require.NoError(t, err)
+	_, err = Decrypt(ciphertext, other)
+	assert.True(t, errors.Is(err, ErrNoIdentityMatched))
+
+	// Tampered payload.
+	tampered := append([]byte{}, ciphertext...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = Decrypt(tampered, id)
+	assert.True(t, errors.Is(err, ErrMalformedBackup))
+
+	_, err = Decrypt([]byte("not an age file"), id)
+	assert.True(t, errors.Is(err, ErrMalformedBackup))
+}
+
+func TestDecrypt_AgeVector(t *testing.T) {
+	// testdata/example.age of filippo.io/age v1.1.1, encrypted with the age CLI.
+	id, err := ParseIdentity("# Test key for ExampleParseIdentities.\nAGE-SECRET-KEY-184JMZMVQH3E6U0PSL869004Y3U2NYV7R30EU99CSEDNPH02YUVFSZW44VU\n")
+	require.NoError(t, err)
+	payload, err := hex.DecodeString("70c5e53624a1520753f92c5ad10ecab273ba4d6117807713e83820417a1df2ca08182272c8f85c857734a1311a3b75e98d0eaf")
+	require.NoError(t, err)
+	ciphertext := append([]byte("age-encryption.org/v1\n"+
+		"-> X25519 8hrlM+ZBG3Dd4fF2+a583zdTIWDk8/R41kCYZsvwTW4\n"+
+		"yO4PYdlMWDJ+CxgUNRqY5Z0T/m+g3FCh5jIxGLbCVXc\n"+
+		"--- I/imevZzy8120JSzmJnmn/KMk3p5A11V83Nk41m9NPE\n"), payload...)
+
+	plaintext, err := Decrypt(ciphertext, id)
+	require.NoError(t, err)
+	assert.Equal(t, "Black lives matter.", string(plaintext))
+
+	// Re-encrypted to the same recipient, it round-trips with a stanza of the same
+	// shape.
+	reencrypted, err := Encrypt(plaintext, id.Recipient())
+	require.NoError(t, err)
+	lines := strings.SplitN(string(reencrypted), "\n", 5)
+	require.Len(t, lines, 5)
+	assert.Len(t, lines[1], len("-> X25519 8hrlM+ZBG3Dd4fF2+a583zdTIWDk8/R41kCYZsvwTW4"))
+	assert.Len(t, lines[2], len("yO4PYdlMWDJ+CxgUNRqY5Z0T/m+g3FCh5jIxGLbCVXc"))
+	assert.Len(t, lines[3], len("--- I/imevZzy8120JSzmJnmn/KMk3p5A11V83Nk41m9NPE"))
+	assert.Len(t, reencrypted, len(ciphertext))
+	decrypted, err := Decrypt(reencrypted, id)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+}
@@ -0,0 +1,322 @@
+// Package backup keeps encrypted off-site backups of SignCTRL's watermarks and rank,
+// so that a disk failure on the signing host doesn't erase the double-signing
+// protection history. Keys are never backed up. The backups are encrypted to an age
+// recipient whose identity is kept off the host, and uploaded to an S3-compatible
+// object storage whenever a watermark changed. A backup can be restored on top of a
+// snapshot of the node's identity, which only ever raises the watermarks.
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+const (
+	// FormatVersion is the version of the backup format.
+	FormatVersion = 1
+)
+
+var (
+	// ErrUnsupportedVersion is returned if a backup has a format version this version
+	// of SignCTRL doesn't support.
+	ErrUnsupportedVersion = errors.New("unsupported backup version")
+
+	// ErrChainIDMismatch is returned if a backup is restored for another chain.
+	ErrChainIDMismatch = errors.New("backup was created for another chain")
+)
+
+// Archive is the decrypted contents of a backup.
+type Archive struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	ChainID   string    `json:"chain_id"`
+
+	// Files are the contents of the watermarks and the rank of the default validator,
+	// the consumer chains and the instances, keyed by their paths relative to the
+	// configuration directory, using forward slashes.
+	Files map[string][]byte `json:"files"`
+}
+
+// Collect returns the contents of the watermarks and the rank in the configuration
+// directory and the directories of the consumer chains and instances, keyed by their
+// paths relative to the configuration directory.
+func Collect(cfgDir string) (map[string][]byte, error) {
+	dirs := []string{"."}
+	for _, parent := range []string{privval.ConsumersDir, privval.InstancesDir} {
+		entries, err := ioutil.ReadDir(filepath.Join(cfgDir, parent))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				dirs = append(dirs, path.Join(parent, e.Name()))
+			}
+		}
+	}
+
+	files := make(map[string][]byte)
+	for _, dir := range dirs {
+		for _, name := range []string{privval.StateFile, config.StateFile} {
+			p := path.Join(dir, name)
+			bz, err := ioutil.ReadFile(filepath.Join(cfgDir, filepath.FromSlash(p)))
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			files[p] = bz
+		}
+	}
+
+	return files, nil
+}
+
+// Seal encrypts the archive to the given recipient.
+func Seal(a Archive, r *Recipient) ([]byte, error) {
+	bz, err := json.Marshal(&a)
+	if err != nil {
+		return nil, err
+	}
+
+	return Encrypt(bz, r)
+}
+
+// Unseal decrypts a backup with the given identity.
+func Unseal(data []byte, id *Identity) (Archive, error) {
+	bz, err := Decrypt(data, id)
+	if err != nil {
+		return Archive{}, err
+	}
+	var a Archive
+	if err := json.Unmarshal(bz, &a); err != nil {
+		return Archive{}, fmt.Errorf("%w: %v", ErrMalformedBackup, err)
+	}
+	if a.Version != FormatVersion {
+		return Archive{}, fmt.Errorf("%w: %v", ErrUnsupportedVersion, a.Version)
+	}
+
+	return a, nil
+}
+
+// Restore restores the archive in the configuration directory and returns the paths
+// of the restored files. Watermarks are only raised, never lowered, and the rank is
+// only restored if it is at least as recent as the local one. Files that don't exist
+// in the configuration directory, e.g. of consumer chains that were removed, are
+// skipped.
+func Restore(cfgDir string, a Archive) ([]string, error) {
+	paths := make([]string, 0, len(a.Files))
+	for p := range a.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var restored []string
+	for _, p := range paths {
+		if path.Clean(p) != p || path.IsAbs(p) || strings.HasPrefix(p, "../") {
+			return restored, fmt.Errorf("%w: invalid path %v", ErrMalformedBackup, p)
+		}
+		full := filepath.Join(cfgDir, filepath.FromSlash(p))
+		if _, err := os.Stat(full); os.IsNotExist(err) {
+			continue
+		}
+
+		switch path.Base(p) {
+		case privval.StateFile:
+			var lss tm_privval.FilePVLastSignState
+			if err := tm_json.Unmarshal(a.Files[p], &lss); err != nil {
+				return restored, fmt.Errorf("couldn't read %v: %w", p, err)
+			}
+			raised, err := privval.RaiseWatermark(full, privval.Watermark{Height: lss.Height, Round: lss.Round, Step: lss.Step})
+			if err != nil {
+				return restored, err
+			}
+			if raised {
+				restored = append(restored, p)
+			}
+
+		case config.StateFile:
+			var state config.State
+			if err := tm_json.Unmarshal(a.Files[p], &state); err != nil {
+				return restored, fmt.Errorf("couldn't read %v: %w", p, err)
+			}
+			dir := filepath.Dir(full)
+			local, err := config.LoadOrGenState(dir)
+			if err != nil {
+				return restored, err
+			}
+			if state.LastHeight < local.LastHeight || state == local {
+				continue
+			}
+			if err := state.Save(dir); err != nil {
+				return restored, err
+			}
+			restored = append(restored, p)
+		}
+	}
+
+	return restored, nil
+}
+
+// NewStore creates the store for the given [backup] section.
+func NewStore(cfg config.Backup) (*S3Store, error) {
+	accessKeyID, secretAccessKey, err := config.LoadCredentials(cfg.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewS3Store(cfg.Endpoint, cfg.Region, cfg.Bucket, accessKeyID, secretAccessKey, nil)
+}
+
+// Fetch downloads the latest backup from the store and decrypts it with the given
+// identity. The backup must have been created for the given chain.
+func Fetch(ctx context.Context, store Store, object, chainID string, id *Identity) (Archive, error) {
+	data, err := store.Get(ctx, object)
+	if err != nil {
+		return Archive{}, err
+	}
+	a, err := Unseal(data, id)
+	if err != nil {
+		return Archive{}, err
+	}
+	if a.ChainID != chainID {
+		return Archive{}, fmt.Errorf("%w: %v", ErrChainIDMismatch, a.ChainID)
+	}
+
+	return a, nil
+}
+
+// Uploader uploads a backup whenever a watermark changed.
+type Uploader struct {
+	// Store is the store the backups are uploaded to.
+	Store Store
+
+	// Recipient is the recipient the backups are encrypted to.
+	Recipient *Recipient
+
+	// Object is the key the latest backup is stored under.
+	Object string
+
+	// CfgDir is the configuration directory that is backed up.
+	CfgDir string
+
+	// ChainID is the chain ID of the default validator.
+	ChainID string
+
+	// Interval is the interval in which the watermarks are checked.
+	Interval time.Duration
+
+	Clock  types.Clock
+	Logger types.Logger
+
+	// uploaded are the watermarks in the last uploaded backup.
+	uploaded map[string][]byte
+}
+
+// NewUploader creates a new uploader for the given configuration.
+func NewUploader(cfg config.Config, cfgDir string, logger types.Logger) (*Uploader, error) {
+	r, err := ParseRecipient(cfg.Backup.Recipient)
+	if err != nil {
+		return nil, err
+	}
+	store, err := NewStore(cfg.Backup)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Uploader{
+		Store:     store,
+		Recipient: r,
+		Object:    cfg.Backup.Object,
+		CfgDir:    cfgDir,
+		ChainID:   cfg.Privval.ChainID,
+		Interval:  cfg.Backup.GetInterval(),
+		Clock:     types.SystemClock,
+		Logger:    logger,
+	}, nil
+}
+
+// watermarksChanged returns true if a watermark in files differs from the last
+// uploaded backup.
+func (u *Uploader) watermarksChanged(files map[string][]byte) bool {
+	for p, bz := range files {
+		if path.Base(p) == privval.StateFile && !bytes.Equal(bz, u.uploaded[p]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Upload uploads a backup if a watermark changed since the last upload, and returns
+// true if it did.
+func (u *Uploader) Upload(ctx context.Context) (bool, error) {
+	files, err := Collect(u.CfgDir)
+	if err != nil {
+		return false, err
+	}
+	if !u.watermarksChanged(files) {
+		return false, nil
+	}
+
+	data, err := Seal(Archive{
+		Version:   FormatVersion,
+		CreatedAt: u.Clock.Now().UTC(),
+		ChainID:   u.ChainID,
+		Files:     files,
+	}, u.Recipient)
+	if err != nil {
+		return false, err
+	}
+	if err := u.Store.Put(ctx, u.Object, data); err != nil {
+		return false, err
+	}
+	u.uploaded = files
+
+	return true, nil
+}
+
+// Run checks the watermarks in the configured interval and uploads a backup whenever
+// one of them changed, until ctx is done. The watermarks at the time Run is called
+// count as uploaded, so that a backup node that never signs doesn't overwrite the
+// signer's backup with its stale watermarks. Failed uploads are logged and retried
+// in the next interval.
+func (u *Uploader) Run(ctx context.Context) {
+	files, err := Collect(u.CfgDir)
+	if err != nil {
+		u.Logger.Error("couldn't read the state to back up: %v\n", err)
+	}
+	u.uploaded = files
+	u.Logger.Info("Backing up the watermarks to %v every %v", u.Object, u.Interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-u.Clock.After(u.Interval):
+		}
+
+		uploadCtx, cancel := context.WithTimeout(ctx, u.Interval)
+		if uploaded, err := u.Upload(uploadCtx); err != nil && ctx.Err() == nil {
+			u.Logger.Error("couldn't upload backup: %v\n", err)
+		} else if uploaded {
+			u.Logger.Debug("Uploaded backup to %v", u.Object)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,174 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+// memStore is a Store that keeps the backups in memory.
+type memStore map[string][]byte
+
+func (s memStore) Put(_ context.Context, key string, data []byte) error {
+	s[key] = data
+	return nil
+}
+
+func (s memStore) Get(_ context.Context, key string) ([]byte, error) {
+	data, ok := s[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return data, nil
+}
+
+// testState writes a watermark at the given height and the given rank to dir.
+func testState(t *testing.T, dir string, height int64, rank int) *tm_privval.FilePV {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, config.PermConfigDir))
+	filePV := tm_privval.GenFilePV(privval.KeyFilePath(dir), privval.StateFilePath(dir))
+	filePV.LastSignState.Height = height
+	filePV.LastSignState.Step = 3
+	filePV.Save()
+	state := config.State{LastHeight: height, LastRank: rank}
+	require.NoError(t, state.Save(dir))
+
+	return filePV
+}
+
+// watermark returns the height of the watermark in dir.
+func watermark(t *testing.T, dir string) int64 {
+	t.Helper()
+	return tm_privval.LoadFilePV(privval.KeyFilePath(dir), privval.StateFilePath(dir)).LastSignState.Height
+}
+
+func TestCollect(t *testing.T) {
+	cfgDir := t.TempDir()
+	testState(t, cfgDir, 10, 1)
+	testState(t, privval.ConsumerDir(cfgDir, "neutron-1"), 20, 1)
+	testState(t, privval.InstanceDir(cfgDir, "operator-a"), 30, 2)
+
+	files, err := Collect(cfgDir)
+	require.NoError(t, err)
+	assert.Len(t, files, 6)
+	assert.Contains(t, files, "priv_validator_state.json")
+	assert.Contains(t, files, "signctrl_state.json")
+	assert.Contains(t, files, "consumers/neutron-1/priv_validator_state.json")
+	assert.Contains(t, files, "instances/operator-a/signctrl_state.json")
+
+	// Keys are never backed up.
+	assert.NotContains(t, files, privval.KeyFile)
+	assert.NotContains(t, files, "consumers/neutron-1/"+privval.KeyFile)
+}
+
+func TestSealUnseal(t *testing.T) {
+	id, err := GenerateIdentity()
+	require.NoError(t, err)
+	a := Archive{Version: FormatVersion, CreatedAt: time.Unix(10, 0).UTC(), ChainID: "testchain", Files: map[string][]byte{"signctrl_state.json": []byte("{}")}}
+	data, err := Seal(a, id.Recipient())
+	require.NoError(t, err)
+
+	unsealed, err := Unseal(data, id)
+	require.NoError(t, err)
+	assert.Equal(t, a, unsealed)
+
+	a.Version = FormatVersion + 1
+	data, err = Seal(a, id.Recipient())
+	require.NoError(t, err)
+	_, err = Unseal(data, id)
+	assert.True(t, errors.Is(err, ErrUnsupportedVersion))
+}
+
+func TestRestore(t *testing.T) {
+	backedUp := t.TempDir()
+	testState(t, backedUp, 100, 1)
+	testState(t, privval.ConsumerDir(backedUp, "neutron-1"), 50, 1)
+	files, err := Collect(backedUp)
+	require.NoError(t, err)
+	a := Archive{Version: FormatVersion, ChainID: "testchain", Files: files}
+
+	// The snapshot is older than the backup, and doesn't contain the consumer chain.
+	cfgDir := t.TempDir()
+	testState(t, cfgDir, 80, 2)
+	restored, err := Restore(cfgDir, a)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"priv_validator_state.json", "signctrl_state.json"}, restored)
+	assert.Equal(t, int64(100), watermark(t, cfgDir))
+	state, err := config.LoadOrGenState(cfgDir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, state.LastRank)
+	assert.NoDirExists(t, privval.ConsumerDir(cfgDir, "neutron-1"))
+
+	// Watermarks are never lowered.
+	testState(t, cfgDir, 120, 2)
+	restored, err = Restore(cfgDir, a)
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+	assert.Equal(t, int64(120), watermark(t, cfgDir))
+
+	// Paths outside the configuration directory are rejected.
+	a.Files = map[string][]byte{"../priv_validator_state.json": files["priv_validator_state.json"]}
+	_, err = Restore(cfgDir, a)
+	assert.True(t, errors.Is(err, ErrMalformedBackup))
+}
+
+func TestUploader(t *testing.T) {
+	cfgDir := t.TempDir()
+	filePV := testState(t, cfgDir, 10, 1)
+	id, err := GenerateIdentity()
+	require.NoError(t, err)
+	store := memStore{}
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	u := &Uploader{
+		Store:     store,
+		Recipient: id.Recipient(),
+		Object:    "signctrl/state.age",
+		CfgDir:    cfgDir,
+		ChainID:   "testchain",
+		Interval:  time.Minute,
+		Clock:     clock,
+		Logger:    types.NewSyncLogger(ioutil.Discard, "", 0),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		u.Run(ctx)
+	}()
+
+	// The watermark at startup isn't uploaded.
+	clock.BlockUntil(1)
+	clock.Advance(u.Interval)
+	clock.BlockUntil(1)
+	assert.Empty(t, store)
+
+	// A changed watermark is uploaded.
+	filePV.LastSignState.Height = 11
+	filePV.Save()
+	clock.Advance(u.Interval)
+	clock.BlockUntil(1)
+	cancel()
+	<-done
+
+	a, err := Fetch(context.Background(), store, "signctrl/state.age", "testchain", id)
+	require.NoError(t, err)
+	restoreDir := t.TempDir()
+	testState(t, restoreDir, 5, 2)
+	_, err = Restore(restoreDir, a)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), watermark(t, restoreDir))
+
+	_, err = Fetch(context.Background(), store, "signctrl/state.age", "otherchain", id)
+	assert.True(t, errors.Is(err, ErrChainIDMismatch))
+}
@@ -0,0 +1,89 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var (
+	// ErrNotFound is returned if a backup doesn't exist in the store.
+	ErrNotFound = errors.New("backup not found")
+)
+
+// Store stores the encrypted backups off-site.
+type Store interface {
+	// Put stores data under the given key, replacing the existing data.
+	Put(ctx context.Context, key string, data []byte) error
+
+	// Get returns the data stored under the given key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// S3Store stores backups in a bucket of an S3-compatible object storage, using the
+// AWS SDK with path-style URLs and static credentials. Object storages that only
+// implement a subset of the S3 API, e.g. GCS through its XML API with HMAC keys,
+// work as long as they support plain PUT and GET requests of objects.
+type S3Store struct {
+	client *s3.S3
+	bucket string
+}
+
+// NewS3Store creates a store for the bucket at the given endpoint. httpClient may be
+// nil to use the SDK's default HTTP client.
+func NewS3Store(endpoint, region, bucket, accessKeyID, secretAccessKey string, httpClient *http.Client) (*S3Store, error) {
+	cfg := aws.NewConfig().
+		WithEndpoint(endpoint).
+		WithRegion(region).
+		WithS3ForcePathStyle(true).
+		WithCredentials(credentials.NewStaticCredentials(accessKeyID, secretAccessKey, ""))
+	if httpClient != nil {
+		cfg = cfg.WithHTTPClient(httpClient)
+	}
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3Store{client: s3.New(sess), bucket: bucket}, nil
+}
+
+// Put implements Store.
+func (s *S3Store) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't upload %v: %w", key, err)
+	}
+
+	return nil
+}
+
+// Get implements Store.
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	out, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, key)
+	} else if err != nil {
+		return nil, fmt.Errorf("couldn't download %v: %w", key, err)
+	}
+	defer out.Body.Close()
+
+	return ioutil.ReadAll(out.Body)
+}
@@ -0,0 +1,47 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Store(t *testing.T) {
+	objects := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/"))
+		switch r.Method {
+		case http.MethodPut:
+			body, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err)
+			objects[r.URL.Path] = body
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(rw, r)
+				return
+			}
+			_, _ = rw.Write(body)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := NewS3Store(srv.URL, "auto", "backups", "access", "secret", srv.Client())
+	require.NoError(t, err)
+	ctx := context.Background()
+	_, err = s.Get(ctx, "signctrl/state.age")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	require.NoError(t, s.Put(ctx, "signctrl/state.age", []byte("encrypted")))
+	assert.Contains(t, objects, "/backups/signctrl/state.age")
+	data, err := s.Get(ctx, "signctrl/state.age")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("encrypted"), data)
+}
@@ -0,0 +1,158 @@
+// Package chaos injects failures into a running SignCTRL node. It is meant for
+// staging game-days that validate the failover of a SignCTRL set and must never be
+// enabled on mainnet.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+var (
+	// ErrInjected is returned by all operations that fail due to an injected failure.
+	ErrInjected = errors.New("injected failure")
+)
+
+// Config defines the probabilities of failures being injected. All rates are in the
+// range of [0, 1] and are applied per message or query.
+type Config struct {
+	// MaxDelay is the maximum time responses to the validator are delayed by.
+	MaxDelay time.Duration
+
+	// DelayRate is the probability of a response to the validator being delayed.
+	DelayRate float64
+
+	// DropRate is the probability of the connection to the validator being dropped
+	// instead of sending a response.
+	DropRate float64
+
+	// QueryFailRate is the probability of a block query failing.
+	QueryFailRate float64
+
+	// CrashRate is the probability of the node crashing before sending a response.
+	CrashRate float64
+}
+
+// DefaultConfig returns the default failure rates used by the --chaos flag.
+func DefaultConfig() Config {
+	return Config{
+		MaxDelay:      2 * time.Second,
+		DelayRate:     0.1,
+		DropRate:      0.01,
+		QueryFailRate: 0.05,
+		CrashRate:     0.001,
+	}
+}
+
+// Injector injects failures according to its configuration. The failures injected
+// are determined by the seed it is created with, so game-days can be replayed.
+type Injector struct {
+	sync.Mutex
+	rand *rand.Rand
+
+	Config Config
+	Logger types.Logger
+
+	// OnCrash is called whenever a crash is injected. It is expected to terminate the
+	// node without shutting it down gracefully.
+	OnCrash func()
+
+	// sleep is used to delay responses. It's replaced in tests.
+	sleep func(time.Duration)
+}
+
+// NewInjector creates a new Injector with the given seed and configuration.
+func NewInjector(seed int64, cfg Config, logger types.Logger, onCrash func()) *Injector {
+	return &Injector{
+		rand:    rand.New(rand.NewSource(seed)),
+		Config:  cfg,
+		Logger:  logger,
+		OnCrash: onCrash,
+		sleep:   time.Sleep,
+	}
+}
+
+// roll returns true with probability p.
+func (i *Injector) roll(p float64) bool {
+	i.Lock()
+	defer i.Unlock()
+
+	return i.rand.Float64() < p
+}
+
+// delay returns a random delay in the range of [0, MaxDelay).
+func (i *Injector) delay() time.Duration {
+	i.Lock()
+	defer i.Unlock()
+
+	if i.Config.MaxDelay <= 0 {
+		return 0
+	}
+	return time.Duration(i.rand.Int63n(int64(i.Config.MaxDelay)))
+}
+
+// Dialer wraps dial so that all connections it establishes are subject to failure
+// injection.
+func (i *Injector) Dialer(dial privval.Dialer) privval.Dialer {
+	return func(ctx context.Context) (net.Conn, error) {
+		conn, err := dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return i.Conn(conn), nil
+	}
+}
+
+// BlockQuerier wraps query so that block queries fail randomly.
+func (i *Injector) BlockQuerier(query privval.BlockQuerier) privval.BlockQuerier {
+	return func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		if i.roll(i.Config.QueryFailRate) {
+			i.Logger.Info("[chaos] Failing block query for height %v", height)
+			return nil, ErrInjected
+		}
+		return query(ctx, height)
+	}
+}
+
+// Conn wraps conn so that writes to it randomly crash the node, drop the connection
+// or are delayed. Each response to the validator is sent with a single write.
+func (i *Injector) Conn(conn net.Conn) net.Conn {
+	return &faultyConn{Conn: conn, inj: i}
+}
+
+// faultyConn is a connection subject to failure injection.
+type faultyConn struct {
+	net.Conn
+	inj *Injector
+}
+
+// Write writes to the underlying connection unless a crash or dropped connection is
+// injected. The write is delayed randomly.
+func (c *faultyConn) Write(b []byte) (int, error) {
+	if c.inj.roll(c.inj.Config.CrashRate) {
+		c.inj.Logger.Info("[chaos] Crashing SignCTRL")
+		if c.inj.OnCrash != nil {
+			c.inj.OnCrash()
+		}
+	}
+	if c.inj.roll(c.inj.Config.DropRate) {
+		c.inj.Logger.Info("[chaos] Dropping connection to the validator")
+		c.Conn.Close()
+		return 0, ErrInjected
+	}
+	if c.inj.roll(c.inj.Config.DelayRate) {
+		d := c.inj.delay()
+		c.inj.Logger.Info("[chaos] Delaying response by %v", d)
+		c.inj.sleep(d)
+	}
+
+	return c.Conn.Write(b)
+}
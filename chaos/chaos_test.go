@@ -0,0 +1,104 @@
+package chaos
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+func testInjector(t *testing.T, cfg Config) *Injector {
+	t.Helper()
+	inj := NewInjector(1, cfg, types.NewSyncLogger(ioutil.Discard, "", 0), nil)
+	inj.sleep = func(time.Duration) {}
+	return inj
+}
+
+func TestDefaultConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	for _, rate := range []float64{cfg.DelayRate, cfg.DropRate, cfg.QueryFailRate, cfg.CrashRate} {
+		assert.True(t, rate > 0 && rate < 1)
+	}
+	assert.True(t, cfg.MaxDelay > 0)
+}
+
+func TestInjector_Deterministic(t *testing.T) {
+	a := testInjector(t, DefaultConfig())
+	b := testInjector(t, DefaultConfig())
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, a.roll(0.5), b.roll(0.5))
+		assert.Equal(t, a.delay(), b.delay())
+	}
+}
+
+func TestBlockQuerier(t *testing.T) {
+	query := func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		return &tm_coretypes.ResultBlock{}, nil
+	}
+
+	rb, err := testInjector(t, Config{QueryFailRate: 1}).BlockQuerier(query)(context.Background(), 1)
+	assert.Nil(t, rb)
+	assert.ErrorIs(t, err, ErrInjected)
+
+	rb, err = testInjector(t, Config{}).BlockQuerier(query)(context.Background(), 1)
+	assert.NotNil(t, rb)
+	assert.NoError(t, err)
+}
+
+func TestConn_Drop(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	conn := testInjector(t, Config{DropRate: 1}).Conn(client)
+	_, err := conn.Write([]byte("response"))
+	assert.ErrorIs(t, err, ErrInjected)
+
+	// The underlying connection must be closed.
+	_, err = client.Write([]byte("response"))
+	assert.Error(t, err)
+}
+
+func TestConn_Crash(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	crashed := false
+	inj := testInjector(t, Config{CrashRate: 1})
+	inj.OnCrash = func() { crashed = true }
+
+	go func() { _, _ = server.Read(make([]byte, 8)) }()
+	_, err := inj.Conn(client).Write([]byte("response"))
+	assert.NoError(t, err)
+	assert.True(t, crashed)
+}
+
+func TestConn_Delay(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	var delayed time.Duration
+	inj := testInjector(t, Config{DelayRate: 1, MaxDelay: time.Second})
+	inj.sleep = func(d time.Duration) { delayed = d }
+
+	go func() { _, _ = server.Read(make([]byte, 8)) }()
+	_, err := inj.Conn(client).Write([]byte("response"))
+	assert.NoError(t, err)
+	assert.True(t, delayed >= 0 && delayed < time.Second)
+}
+
+func TestDialer(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	dial := testInjector(t, Config{}).Dialer(func(ctx context.Context) (net.Conn, error) {
+		return client, nil
+	})
+	conn, err := dial(context.Background())
+	assert.NoError(t, err)
+	assert.IsType(t, &faultyConn{}, conn)
+}
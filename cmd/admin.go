@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/totp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// adminInstance is the name of the instance whose admin API is configured.
+	adminInstance string
+
+	// keepTokens keeps the existing tokens when rotating the admin token.
+	keepTokens bool
+
+	// overwriteTOTP overwrites an existing TOTP secret.
+	overwriteTOTP bool
+
+	adminCmd = &cobra.Command{
+		Use:   "admin",
+		Short: "Manages the credentials of the admin API",
+		Long: `Manages the credentials of the admin API configured in the [admin] section. Use
+--instance to manage the credentials of an instance instead of the default
+validator's ones.`,
+	}
+
+	rotateTokenCmd = &cobra.Command{
+		Use:   "rotate-token",
+		Short: "Writes a new token to the token_file",
+		Long: `Writes a new random token to the token_file and prints it. The running node
+accepts the new token right away. The existing tokens are revoked unless --keep is
+set, so that clients can be switched to the new token first.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg := loadAdminConfig(adminInstance)
+			if !cfg.Admin.RequiresToken() {
+				fmt.Println("token_file is not set in the [admin] section")
+				os.Exit(1)
+			}
+			token, err := privval.RotateAdminToken(cfg.Admin.TokenFile, keepTokens)
+			if err != nil {
+				fmt.Printf("couldn't rotate admin token: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Println(token)
+		},
+	}
+
+	genTOTPCmd = &cobra.Command{
+		Use:   "gen-totp",
+		Short: "Generates a new TOTP secret in the totp_secret_file",
+		Long: `Generates a new TOTP secret in the totp_secret_file and prints its otpauth:// URI,
+which can be added to an authenticator app. Destructive admin requests like
+swapping the signer backend then require the app's current code via --totp.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg := loadAdminConfig(adminInstance)
+			if !cfg.Admin.RequiresTOTP() {
+				fmt.Println("totp_secret_file is not set in the [admin] section")
+				os.Exit(1)
+			}
+			if _, err := os.Stat(cfg.Admin.TOTPSecretFile); err == nil && !overwriteTOTP {
+				fmt.Printf("%v already exists, use --force to overwrite it\n", cfg.Admin.TOTPSecretFile)
+				os.Exit(1)
+			}
+			secret, err := totp.NewSecret()
+			if err != nil {
+				fmt.Printf("couldn't generate TOTP secret: %v\n", err)
+				os.Exit(1)
+			}
+			if err := ioutil.WriteFile(cfg.Admin.TOTPSecretFile, []byte(secret+"\n"), privval.PermSecretFile); err != nil {
+				fmt.Printf("couldn't write TOTP secret: %v\n", err)
+				os.Exit(1)
+			}
+
+			account := cfg.Privval.ChainID
+			if adminInstance != "" {
+				account += "/" + adminInstance
+			}
+			fmt.Println(totp.URI("SignCTRL", account, secret))
+		},
+	}
+)
+
+// adminDir returns the configuration directory of the given instance, or the default
+// validator's one if name is empty.
+func adminDir(name string) string {
+	if name == "" {
+		return config.Dir()
+	}
+
+	return privval.InstanceDir(config.Dir(), name)
+}
+
+// loadAdminConfig loads the configuration of the given instance, or the default
+// validator's one if name is empty, and exits if it can't be loaded.
+func loadAdminConfig(name string) config.Config {
+	cfg, err := config.LoadFrom(adminDir(name))
+	if err != nil {
+		fmt.Printf("couldn't load %v:\n%v", config.File, err)
+		os.Exit(1)
+	}
+
+	return cfg
+}
+
+// adminCredentials returns the credentials for requests to the admin API of the given
+// instance, or the default validator if name is empty. The first token in the
+// configured token_file is used. No token is sent if the configuration can't be
+// loaded, in which case the node rejects the request if it requires one.
+func adminCredentials(name, totpCode string) privval.AdminCredentials {
+	creds := privval.AdminCredentials{TOTPCode: totpCode}
+	cfg, err := config.LoadFrom(adminDir(name))
+	if err != nil || !cfg.Admin.RequiresToken() {
+		return creds
+	}
+	if tokens, err := privval.LoadAdminTokens(cfg.Admin.TokenFile); err == nil && len(tokens) > 0 {
+		creds.Token = tokens[0]
+	}
+
+	return creds
+}
+
+func init() {
+	rootCmd.AddCommand(adminCmd)
+	adminCmd.AddCommand(rotateTokenCmd, genTOTPCmd)
+
+	adminCmd.PersistentFlags().StringVar(&adminInstance, "instance", "", "name of the instance whose admin API is configured")
+	rotateTokenCmd.Flags().BoolVar(&keepTokens, "keep", false, "keep the existing tokens")
+	genTOTPCmd.Flags().BoolVar(&overwriteTOTP, "force", false, "overwrite an existing TOTP secret")
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/backup"
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// backupIdentityFile is the path to the age identity the backup is decrypted with.
+	backupIdentityFile string
+
+	backupCmd = &cobra.Command{
+		Use:   "backup",
+		Short: "Manages the encrypted off-site backups of the watermarks and the rank",
+		Long: `Manages the backups configured in the [backup] section, which SignCTRL encrypts and
+uploads whenever a watermark changed. Keys are never backed up.`,
+	}
+
+	backupKeygenCmd = &cobra.Command{
+		Use:   "keygen",
+		Short: "Generates an age identity for the backups",
+		Long: `Prints a new age identity and its public key. Set the public key as the recipient
+in the [backup] section and store the identity off the host, e.g. in a password
+manager. It's only needed to restore a backup.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			id, err := backup.GenerateIdentity()
+			if err != nil {
+				fmt.Printf("couldn't generate age identity: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("# public key: %v\n%v\n", id.Recipient(), id)
+		},
+	}
+
+	backupRestoreCmd = &cobra.Command{
+		Use:   "restore",
+		Short: "Restores the latest backup in the configuration directory",
+		Long: `Downloads the latest backup, decrypts it with the age identity in --identity and
+raises the watermarks in the configuration directory to the backed up ones. The rank
+is restored if it is more recent than the local one. SignCTRL must not be running.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if sr, err := privval.GetStatus(); err == nil {
+				fmt.Printf("SignCTRL is running on rank %v, stop it before restoring a backup\n", sr.Rank)
+				os.Exit(1)
+			}
+			id := loadBackupIdentity(backupIdentityFile)
+			if err := restoreBackup(config.Dir(), id); err != nil {
+				fmt.Printf("%v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+// loadBackupIdentity loads the age identity from the given file and exits on error.
+func loadBackupIdentity(file string) *backup.Identity {
+	bz, err := ioutil.ReadFile(file)
+	if err != nil {
+		fmt.Printf("couldn't read age identity: %v\n", err)
+		os.Exit(1)
+	}
+	id, err := backup.ParseIdentity(string(bz))
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	return id
+}
+
+// restoreBackup restores the latest backup in the given configuration directory and
+// prints the restored files.
+func restoreBackup(cfgDir string, id *backup.Identity) error {
+	cfg, err := config.LoadFrom(cfgDir)
+	if err != nil {
+		return fmt.Errorf("couldn't load %v:\n%v", config.File, err)
+	}
+	if !cfg.Backup.Enabled() {
+		return errors.New("backups aren't configured in the [backup] section")
+	}
+	store, err := backup.NewStore(cfg.Backup)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fenceCheckTimeout)
+	defer cancel()
+	a, err := backup.Fetch(ctx, store, cfg.Backup.Object, cfg.Privval.ChainID, id)
+	if err != nil {
+		return fmt.Errorf("couldn't fetch backup: %w", err)
+	}
+	restored, err := backup.Restore(cfgDir, a)
+	if err != nil {
+		return fmt.Errorf("couldn't restore backup: %w", err)
+	}
+
+	fmt.Printf("Restored backup from %v ✓\n", a.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	for _, p := range restored {
+		fmt.Printf("  %v\n", p)
+	}
+	if len(restored) == 0 {
+		fmt.Println("The local state was already up to date.")
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupKeygenCmd, backupRestoreCmd)
+
+	backupRestoreCmd.Flags().StringVar(&backupIdentityFile, "identity", "", "path to the age identity the backup is decrypted with")
+	_ = backupRestoreCmd.MarkFlagRequired("identity")
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/sandbox"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// validateFile is the path to the configuration file that is validated.
+	validateFile string
+
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Manages the config.toml",
+	}
+
+	validateConfigCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Validates the config.toml without starting SignCTRL",
+		Long: `Loads and validates the config.toml and prints all problems found, along with the
+lines they are located at. The files the configuration refers to, like the conn.key
+and the priv_validator_key.json, must exist and be readable as well. Exits with a
+non-zero status code if there is a problem, e.g. to fail a CI/CD pipeline.
+Use --file to validate another file than the config.toml in the configuration
+directory, whose referenced files are then looked up next to it.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			file := validateFile
+			if file == "" {
+				file = config.FilePath(config.Dir())
+			}
+			cfg, problems, err := config.Check(file)
+			if err != nil {
+				fmt.Printf("couldn't read %v: %v\n", file, err)
+				os.Exit(1)
+			}
+			var msgs []string
+			for _, p := range problems {
+				msgs = append(msgs, p.String())
+			}
+			if len(problems) == 0 {
+				msgs = append(msgs, checkReferencedFiles(filepath.Dir(file), cfg)...)
+			}
+
+			if len(msgs) > 0 {
+				fmt.Printf("%v is invalid:\n\t%v\n", file, strings.Join(msgs, "\n\t"))
+				os.Exit(1)
+			}
+			fmt.Printf("%v is valid ✓\n", file)
+		},
+	}
+)
+
+// checkReferencedFiles returns a message for each file the configuration refers to
+// that doesn't exist or can't be read.
+func checkReferencedFiles(cfgDir string, cfg config.Config) []string {
+	files := []string{privval.KeyFilePath(cfgDir)}
+	p := cfg.Privval
+	if !p.UsesGRPC() && !p.UsesMTLS() && strings.HasPrefix(cfg.Base.ValidatorListenAddress, "tcp://") {
+		files = append(files, connection.KeyFilePath(cfgDir))
+	}
+	rules, err := sandbox.RulesFor(cfgDir, cfg, 0)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	files = append(files, rules.ReadPaths...)
+
+	var msgs []string
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			msgs = append(msgs, err.Error())
+			continue
+		}
+		f.Close()
+	}
+
+	return msgs
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(validateConfigCmd)
+
+	validateConfigCmd.Flags().StringVar(&validateFile, "file", "", "path to the configuration file to validate")
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// connKeyInstance is the name of the instance whose conn.key is managed.
+	connKeyInstance string
+
+	// reloadConnKey makes the running node re-handshake with the new conn.key.
+	reloadConnKey bool
+
+	connKeyCmd = &cobra.Command{
+		Use:   "conn-key",
+		Short: "Manages the conn.key",
+		Long: `Manages the conn.key the secret connection to the validator is established with.
+Use --instance to manage the conn.key of an instance instead of the default
+validator's one.`,
+	}
+
+	rotateConnKeyCmd = &cobra.Command{
+		Use:   "rotate",
+		Short: "Replaces the conn.key with a new one",
+		Long: `Replaces the conn.key with a new one and prints its public key. The running node
+keeps using the old key until its configuration is reloaded with SIGHUP or
+signctrl reload, after which it re-handshakes with the validator using the new key.
+Set --reload to reload the running node right away.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			dir := adminDir(connKeyInstance)
+			pubKey, err := connection.RotateConnKey(dir)
+			if err != nil {
+				fmt.Printf("couldn't rotate %v: %v\n", connection.KeyFile, err)
+				os.Exit(1)
+			}
+			fmt.Println(base64.StdEncoding.EncodeToString(pubKey.Bytes()))
+
+			if !reloadConnKey {
+				return
+			}
+			if _, err := privval.ReloadConfig(connKeyInstance, adminCredentials(connKeyInstance, "")); err != nil {
+				fmt.Printf("couldn't reload the running node, the new key is used once it's reloaded or restarted: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(connKeyCmd)
+	connKeyCmd.AddCommand(rotateConnKeyCmd)
+
+	connKeyCmd.PersistentFlags().StringVar(&connKeyInstance, "instance", "", "name of the instance whose conn.key is managed")
+	rotateConnKeyCmd.Flags().BoolVar(&reloadConnKey, "reload", false, "reload the running node to re-handshake with the new key")
+}
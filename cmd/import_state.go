@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// importFormat is the format of the sign state file to import.
+	importFormat string
+
+	// watermarkLoaders map the supported sign state formats to the functions loading
+	// the watermark from them.
+	watermarkLoaders = map[string]func(path string) (privval.Watermark, error){
+		"privval": privval.LoadSignStateWatermark,
+		"tmkms":   privval.LoadTmkmsWatermark,
+	}
+
+	importStateCmd = &cobra.Command{
+		Use:   "import-state [file]",
+		Short: "Imports the watermark from another signer's sign state file",
+		Long: `Raises the last sign state in the priv_validator_state.json in the configuration
+directory to the height, round and step found in another signer's sign state file, so
+that SignCTRL never signs anything the other signer has already signed. The sign state
+is never lowered. SignCTRL must not be running while the state is imported.
+
+Supported formats:
+  privval: a priv_validator_state.json, e.g. of another Tendermint node, or the
+           {chain-id}_priv_validator_state.json or {chain-id}_share_sign_state.json
+           of a Horcrux cosigner
+  tmkms:   the consensus state file of tmkms, i.e. state/{chain-id}_consensus.json`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			load, ok := watermarkLoaders[importFormat]
+			if !ok {
+				fmt.Printf("unknown format: %v\n", importFormat)
+				os.Exit(1)
+			}
+			w, err := load(args[0])
+			if err != nil {
+				fmt.Printf("couldn't load %v: %v\n", args[0], err)
+				os.Exit(1)
+			}
+
+			stateFile := privval.StateFilePath(config.Dir())
+			raised, err := privval.RaiseWatermark(stateFile, w)
+			if err != nil {
+				fmt.Printf("couldn't import sign state: %v\n", err)
+				os.Exit(1)
+			}
+			if !raised {
+				fmt.Printf("%v is already at or ahead of %v, nothing to import ✓\n", stateFile, w)
+				return
+			}
+
+			fmt.Printf("Raised the sign state in %v to %v ✓\n", stateFile, w)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(importStateCmd)
+
+	importStateCmd.Flags().StringVar(&importFormat, "format", "privval", "format of the sign state file to import")
+}
@@ -6,21 +6,72 @@ import (
 
 	init_util "github.com/BlockscapeNetwork/signctrl/cmd/init"
 	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/presets"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
-	newPrivval bool
-	initCmd    = &cobra.Command{
+	newPrivval     bool
+	network        string
+	initValues     init_util.Values
+	interactive    bool
+	nonInteractive bool
+	initForce      bool
+	initCmd        = &cobra.Command{
 		Use:   "init",
 		Short: "Initializes the SignCTRL node",
-		Long:  "Creates the .signctrl/ directory, including a config.toml and a conn.key file",
+		Long: `Creates the .signctrl/ directory, including a config.toml and a conn.key file.
+
+The node-specific values of the config.toml can be set via flags, e.g.
+--set-size 3 --rank 2 --chain-id cosmoshub-4, or prompted for with --interactive.
+Use --non-interactive to never prompt, e.g. when provisioning nodes with Ansible or
+Terraform. Existing files are kept then, unless --force is set.`,
 		Run: func(cmd *cobra.Command, args []string) {
+			if interactive && nonInteractive {
+				fmt.Println("--interactive and --non-interactive can't be used together")
+				os.Exit(1)
+			}
+			if err := initValues.Validate(); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			overwrite := init_util.OverwriteAsk
+			switch {
+			case initForce:
+				overwrite = init_util.OverwriteAlways
+			case nonInteractive:
+				overwrite = init_util.OverwriteNever
+			}
+
 			// Get the config directory.
 			cfgDir := config.Dir()
 
+			// Look up the preset of the network before creating any files.
+			var preset *presets.Preset
+			if network != "" {
+				registry, err := presets.Load(cfgDir)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				p, err := registry.Get(network)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				preset = &p
+			}
+
+			// Ask for the values that weren't given via flags.
+			if interactive {
+				if err := init_util.Prompt(&initValues, preset); err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+			}
+
 			// Create the config directory if it doesn't already exist.
 			if _, err := os.Stat(cfgDir); os.IsNotExist(err) {
 				if err := os.MkdirAll(cfgDir, config.PermConfigDir); err != nil {
@@ -33,20 +84,20 @@ var (
 			}
 
 			// Create the config file.
-			if err := init_util.CreateConfigFile(cfgDir); err != nil {
+			if err := init_util.CreateConfigFile(cfgDir, preset, initValues, overwrite); err != nil {
 				fmt.Println(err)
 				os.Exit(1)
 			}
 
 			// Create the connection key.
-			if err := init_util.CreateConnKeyFile(cfgDir); err != nil {
+			if err := init_util.CreateConnKeyFile(cfgDir, overwrite); err != nil {
 				fmt.Println(err)
 				os.Exit(1)
 			}
 
 			// Create new priv_validator_key.json and priv_validator_state.json files if --new-pv flag is set.
 			if newPrivval {
-				if err := init_util.CreateKeyAndStateFiles(cfgDir); err != nil {
+				if err := init_util.CreateKeyAndStateFiles(cfgDir, overwrite); err != nil {
 					fmt.Println(err)
 					os.Exit(1)
 				}
@@ -62,4 +113,17 @@ func init() {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	initCmd.Flags().StringVar(&network, "network", "", "Prefills the config.toml with the preset of the given network, e.g. cosmoshub")
+	if err := viper.BindPFlag("network", initCmd.Flags().Lookup("network")); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	initCmd.Flags().IntVar(&initValues.SetSize, "set-size", 0, "Sets the set_size in the config.toml")
+	initCmd.Flags().IntVar(&initValues.StartRank, "rank", 0, "Sets the start_rank in the config.toml")
+	initCmd.Flags().StringVar(&initValues.ChainID, "chain-id", "", "Sets the chain_id in the config.toml, taking precedence over the --network preset")
+	initCmd.Flags().StringVar(&initValues.ValidatorListenAddress, "validator-laddr", "", "Sets the validator_laddr in the config.toml")
+	initCmd.Flags().StringVar(&initValues.ValidatorListenAddressRPC, "validator-laddr-rpc", "", "Sets the validator_laddr_rpc in the config.toml")
+	initCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Prompts for the values that aren't set via flags")
+	initCmd.Flags().BoolVar(&nonInteractive, "non-interactive", false, "Never prompts, keeping existing files unless --force is set")
+	initCmd.Flags().BoolVar(&initForce, "force", false, "Overwrites existing files without asking")
 }
@@ -4,26 +4,42 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/BlockscapeNetwork/signctrl/config"
 	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/presets"
 	"github.com/BlockscapeNetwork/signctrl/privval"
 	tm_privval "github.com/tendermint/tendermint/privval"
 )
 
+// stdin is shared by all prompts, so that answers piped in line by line aren't
+// swallowed by the buffer of a previous prompt.
+var stdin = bufio.NewReader(os.Stdin)
+
+// readLine reads a line from stdin without the trailing newline.
+func readLine() (string, error) {
+	input, err := stdin.ReadString('\n')
+	if err != nil && input == "" {
+		return "", err
+	}
+
+	return strings.TrimSpace(input), nil
+}
+
 // confirm asks the user for confirmation on file creation, like when a file is about
 // to be overwritten. It handles "y" and "yes" for approval, and "", "n" and "no" for
 // denial.
 func confirm() bool {
 	for {
-		input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		input, err := readLine()
 		if err != nil {
-			fmt.Printf("parsing error: %v", err)
-			continue
+			fmt.Printf("parsing error: %v\n", err)
+			return false
 		}
 
-		switch strings.TrimSuffix(strings.ToLower(input), "\n") {
+		switch strings.ToLower(input) {
 		case "y", "yes":
 			return true
 		case "", "n", "no":
@@ -35,36 +51,223 @@ func confirm() bool {
 	}
 }
 
+// Overwrite determines what happens to files that already exist.
+type Overwrite int
+
+const (
+	// OverwriteAsk asks the user whether an existing file should be overwritten.
+	OverwriteAsk Overwrite = iota
+
+	// OverwriteAlways overwrites existing files without asking.
+	OverwriteAlways
+
+	// OverwriteNever keeps existing files without asking.
+	OverwriteNever
+)
+
+// confirm returns true if the existing file with the given name at cfgDir should be
+// overwritten.
+func (o Overwrite) confirm(name, cfgDir string) bool {
+	switch o {
+	case OverwriteAlways:
+		fmt.Printf("Overwriting existing %v at %v\n", name, cfgDir)
+		return true
+	case OverwriteNever:
+		fmt.Printf("Keeping existing %v at %v\n", name, cfgDir)
+		return false
+	}
+	fmt.Printf("Found existing %v at %v. Do you want to overwrite it? [y(es)/N(o)]: ", name, cfgDir)
+
+	return confirm()
+}
+
+// Values are the values of the config.toml that are specific to a node. Zero values
+// are left as they are in the template or the preset.
+type Values struct {
+	SetSize                   int
+	StartRank                 int
+	ChainID                   string
+	ValidatorListenAddress    string
+	ValidatorListenAddressRPC string
+}
+
+// Validate returns an error if one of the values is set but invalid.
+func (v Values) Validate() error {
+	if v.SetSize != 0 && v.SetSize < 2 {
+		return fmt.Errorf("set size must be 2 or higher, got %v", v.SetSize)
+	}
+	if v.StartRank < 0 {
+		return fmt.Errorf("rank must be 1 or higher, got %v", v.StartRank)
+	}
+	if v.SetSize != 0 && v.StartRank > v.SetSize {
+		return fmt.Errorf("rank %v is out of the set of size %v", v.StartRank, v.SetSize)
+	}
+
+	return nil
+}
+
+// overrides returns the values that are set, keyed by section and key for
+// config.Override.
+func (v Values) overrides() map[string]interface{} {
+	values := make(map[string]interface{})
+	if v.SetSize != 0 {
+		values["base.set_size"] = v.SetSize
+	}
+	if v.StartRank != 0 {
+		values["base.start_rank"] = v.StartRank
+	}
+	if v.ChainID != "" {
+		values["privval.chain_id"] = v.ChainID
+	}
+	if v.ValidatorListenAddress != "" {
+		values["base.validator_laddr"] = v.ValidatorListenAddress
+	}
+	if v.ValidatorListenAddressRPC != "" {
+		values["base.validator_laddr_rpc"] = v.ValidatorListenAddressRPC
+	}
+
+	return values
+}
+
+// Prompt asks the user for the set size, the rank, the chain ID and the validator's
+// addresses. The values already set, e.g. via flags, and the ones of the preset, if
+// any, are suggested as defaults and kept on an empty answer.
+func Prompt(v *Values, preset *presets.Preset) error {
+	if v.ChainID == "" && preset != nil {
+		v.ChainID = preset.ChainID
+	}
+	for {
+		if err := promptInt("Number of validators in the set", &v.SetSize, 2); err != nil {
+			return err
+		}
+		if err := promptInt("Rank of this node on its first startup", &v.StartRank, 0); err != nil {
+			return err
+		}
+		if v.StartRank == 0 {
+			fmt.Println("The rank must be set. Please try again.")
+			continue
+		}
+		if err := v.Validate(); err != nil {
+			fmt.Printf("%v. Please try again.\n", err)
+			v.StartRank = 0
+			continue
+		}
+		break
+	}
+	if err := promptString("Chain ID", &v.ChainID, ""); err != nil {
+		return err
+	}
+	if err := promptString("Address the validator listens on for SignCTRL", &v.ValidatorListenAddress, "tcp://127.0.0.1:3000"); err != nil {
+		return err
+	}
+
+	return promptString("Address of the validator's RPC server", &v.ValidatorListenAddressRPC, "tcp://127.0.0.1:26657")
+}
+
+// promptString asks the user for a string. The current value of s, or def if it is
+// empty, is kept on an empty answer.
+func promptString(question string, s *string, def string) error {
+	if *s != "" {
+		def = *s
+	}
+	fmt.Printf("%v [%v]: ", question, def)
+	input, err := readLine()
+	if err != nil {
+		return err
+	}
+	if input == "" {
+		input = def
+	}
+	*s = input
+
+	return nil
+}
+
+// promptInt asks the user for a positive integer until a valid one is entered. The
+// current value of n, or def if it is 0, is kept on an empty answer. No answer is
+// required if both are 0.
+func promptInt(question string, n *int, def int) error {
+	if *n != 0 {
+		def = *n
+	}
+	for {
+		if def != 0 {
+			fmt.Printf("%v [%v]: ", question, def)
+		} else {
+			fmt.Printf("%v: ", question)
+		}
+		input, err := readLine()
+		if err != nil {
+			return err
+		}
+		if input == "" {
+			*n = def
+			return nil
+		}
+		i, err := strconv.Atoi(input)
+		if err != nil || i < 1 {
+			fmt.Println("Please enter a number of 1 or higher.")
+			continue
+		}
+		*n = i
+
+		return nil
+	}
+}
+
 // CreateConfigFile creates the configuration file in the specified configuration
-// directory. In case it already exists, the user is asked to decide whether it
-// should be overwritten or not.
-func CreateConfigFile(cfgDir string) error {
+// directory and applies the given preset and values to it. The values take precedence
+// over the preset. In case the file already exists, overwrite decides whether it is
+// overwritten or not.
+func CreateConfigFile(cfgDir string, preset *presets.Preset, values Values, overwrite Overwrite) error {
 	if _, err := os.Stat(config.FilePath(cfgDir)); !os.IsNotExist(err) {
-		fmt.Printf("Found existing %v at %v. Do you want to overwrite it? [y(es)/N(o)]: ", config.File, cfgDir)
-		if confirm() {
+		if overwrite.confirm(config.File, cfgDir) {
 			os.Remove(config.FilePath(cfgDir))
-			if err := config.Create(cfgDir); err != nil {
+			if err := createConfigFile(cfgDir, preset, values); err != nil {
 				return err
 			}
-			fmt.Printf("Created %v at %v ✓\n", config.File, cfgDir)
+		} else if len(values.overrides()) > 0 {
+			fmt.Printf("The values given for the %v weren't applied, as it was kept\n", config.File)
 		}
 	} else {
-		if err := config.Create(cfgDir); err != nil {
+		if err := createConfigFile(cfgDir, preset, values); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createConfigFile creates the configuration file in the specified configuration
+// directory and applies the given preset and values to it.
+func createConfigFile(cfgDir string, preset *presets.Preset, values Values) error {
+	if err := config.Create(cfgDir); err != nil {
+		return err
+	}
+	fmt.Printf("Created %v at %v ✓\n", config.File, cfgDir)
+
+	if preset != nil {
+		if err := config.Override(cfgDir, preset.Values()); err != nil {
+			return err
+		}
+		fmt.Printf("Applied the %v preset (chain_id %v, threshold %v, rpc timeout %v) ✓\n", preset.Name, preset.ChainID, preset.GetThreshold(), preset.GetRPCTimeout())
+	}
+	if overrides := values.overrides(); len(overrides) > 0 {
+		if err := config.Override(cfgDir, overrides); err != nil {
 			return err
 		}
-		fmt.Printf("Created %v at %v ✓\n", config.File, cfgDir)
+		fmt.Printf("Set %v values in the %v ✓\n", len(overrides), config.File)
 	}
 
 	return nil
 }
 
 // CreateConnKeyFile creates the connection key file in the specified configuration
-// directory. In case it already exists, the user is asked to decide whether it should
-// be overwritten or not.
-func CreateConnKeyFile(cfgDir string) error {
+// directory. In case it already exists, overwrite decides whether it is overwritten
+// or not.
+func CreateConnKeyFile(cfgDir string, overwrite Overwrite) error {
 	if _, err := os.Stat(connection.KeyFilePath(cfgDir)); !os.IsNotExist(err) {
-		fmt.Printf("Found existing %v at %v. Do you want to overwrite it? [y(es)/N(o)]: ", connection.KeyFile, cfgDir)
-		if confirm() {
+		if overwrite.confirm(connection.KeyFile, cfgDir) {
 			os.Remove(connection.KeyFilePath(cfgDir))
 			if err := connection.CreateBase64ConnKey(cfgDir); err != nil {
 				return err
@@ -82,12 +285,11 @@ func CreateConnKeyFile(cfgDir string) error {
 }
 
 // CreateKeyAndStateFiles creates the priv_validator_key.json and priv_validator_state.json
-// in the specified configuration directory. In case it already exists, the user is asked
-// to decide whether it should be overwritten or not.
-func CreateKeyAndStateFiles(cfgDir string) error {
+// in the specified configuration directory. In case they already exist, overwrite
+// decides whether they are overwritten or not.
+func CreateKeyAndStateFiles(cfgDir string, overwrite Overwrite) error {
 	if _, err := os.Stat(privval.KeyFilePath(cfgDir)); !os.IsNotExist(err) {
-		fmt.Printf("Found existing priv_validator_key.json at %v. Do you want to overwrite it? [y(es)/N(o)]: ", cfgDir)
-		if confirm() {
+		if overwrite.confirm("priv_validator_key.json", cfgDir) {
 			os.Remove(privval.KeyFilePath(cfgDir))
 			os.Remove(privval.StateFilePath(cfgDir))
 			tm_privval.LoadOrGenFilePV(privval.KeyFilePath(cfgDir), privval.StateFilePath(cfgDir))
@@ -0,0 +1,69 @@
+package init
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/presets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValuesValidate(t *testing.T) {
+	assert.NoError(t, Values{}.Validate())
+	assert.NoError(t, Values{SetSize: 3, StartRank: 2}.Validate())
+	assert.Error(t, Values{SetSize: 1}.Validate())
+	assert.Error(t, Values{StartRank: -1}.Validate())
+	assert.Error(t, Values{SetSize: 3, StartRank: 4}.Validate())
+}
+
+func TestCreateConfigFile(t *testing.T) {
+	cfgDir := t.TempDir()
+	preset := &presets.Preset{Name: "cosmoshub", ChainID: "cosmoshub-4", BlockTime: "6s"}
+	values := Values{SetSize: 3, StartRank: 2, ChainID: "testchain", ValidatorListenAddress: "tcp://10.0.0.1:3000"}
+	require.NoError(t, CreateConfigFile(cfgDir, preset, values, OverwriteNever))
+
+	// The values take precedence over the preset.
+	cfg, err := config.LoadFrom(cfgDir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.Base.SetSize)
+	assert.Equal(t, 2, cfg.Base.StartRank)
+	assert.Equal(t, "testchain", cfg.Privval.ChainID)
+	assert.Equal(t, "tcp://10.0.0.1:3000", cfg.Base.ValidatorListenAddress)
+	assert.Equal(t, "tcp://127.0.0.1:26657", cfg.Base.ValidatorListenAddressRPC)
+
+	// The existing file is kept without asking.
+	require.NoError(t, CreateConfigFile(cfgDir, nil, Values{SetSize: 5, StartRank: 5}, OverwriteNever))
+	cfg, err = config.LoadFrom(cfgDir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.Base.SetSize)
+
+	// The existing file is overwritten without asking.
+	require.NoError(t, CreateConfigFile(cfgDir, nil, Values{SetSize: 5, StartRank: 5, ChainID: "testchain"}, OverwriteAlways))
+	cfg, err = config.LoadFrom(cfgDir)
+	require.NoError(t, err)
+	assert.Equal(t, 5, cfg.Base.SetSize)
+}
+
+func TestPrompt(t *testing.T) {
+	defer func(r *bufio.Reader) { stdin = r }(stdin)
+
+	// The set size is kept, the first rank is out of the set and the addresses are
+	// left at their defaults.
+	stdin = bufio.NewReader(strings.NewReader("\n4\n\nabc\n2\n\n\n\n"))
+	values := Values{SetSize: 3}
+	require.NoError(t, Prompt(&values, &presets.Preset{ChainID: "cosmoshub-4"}))
+	assert.Equal(t, Values{
+		SetSize:                   3,
+		StartRank:                 2,
+		ChainID:                   "cosmoshub-4",
+		ValidatorListenAddress:    "tcp://127.0.0.1:3000",
+		ValidatorListenAddressRPC: "tcp://127.0.0.1:26657",
+	}, values)
+
+	// Prompting fails once stdin is closed.
+	stdin = bufio.NewReader(strings.NewReader(""))
+	assert.Error(t, Prompt(&Values{}, nil))
+}
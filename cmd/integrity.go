@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/integrity"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// integrityKeyFile is the path to the private key binaries are signed with.
+	integrityKeyFile string
+
+	// integritySignatureFile is the path to the detached signature of a binary.
+	integritySignatureFile string
+
+	// integritySigningKey is the base64-encoded public key binaries are verified with.
+	integritySigningKey string
+
+	integrityCmd = &cobra.Command{
+		Use:   "integrity",
+		Short: "Signs and verifies SignCTRL binaries",
+		Long: `Signs and verifies the detached signatures SignCTRL verifies its own binary against
+on startup if signature_file is set in the [integrity] section.`,
+	}
+
+	integrityKeygenCmd = &cobra.Command{
+		Use:   "keygen",
+		Short: "Generates a key for signing SignCTRL binaries",
+		Long: `Writes a new ed25519 private key to --key and prints its public key. Set the public
+key as signing_key in the [integrity] section, or embed it at build time with
+make build SIGNING_KEY=<public key>. Keep the private key off the signing hosts.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			pub, err := integrity.GenerateKey(integrityKeyFile)
+			if err != nil {
+				fmt.Printf("couldn't generate signing key: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Wrote private key to %v ✓\nPublic key: %v\n", integrityKeyFile, pub)
+		},
+	}
+
+	integritySignCmd = &cobra.Command{
+		Use:   "sign <binary>",
+		Short: "Signs a SignCTRL binary",
+		Long: `Signs the SHA-256 hash of the binary with the private key in --key and writes the
+detached signature to --signature, which defaults to the binary's path with .sig
+appended.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			sigFile := signatureFile(args[0])
+			if err := integrity.Sign(args[0], integrityKeyFile, sigFile); err != nil {
+				fmt.Printf("couldn't sign %v: %v\n", args[0], err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Wrote signature to %v ✓\n", sigFile)
+		},
+	}
+
+	integrityVerifyCmd = &cobra.Command{
+		Use:   "verify <binary>",
+		Short: "Verifies a SignCTRL binary against its signature",
+		Long: `Verifies the binary against the detached signature in --signature, which defaults
+to the binary's path with .sig appended, using --signing-key or the key embedded in
+this binary.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			pub, err := integrity.PublicKey(integritySigningKey)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				os.Exit(1)
+			}
+			hash, err := integrity.Verify(args[0], signatureFile(args[0]), pub)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Signature is valid ✓\nsha256 %v\n", hash)
+		},
+	}
+)
+
+// signatureFile returns the path to the detached signature of the given binary.
+func signatureFile(binary string) string {
+	if integritySignatureFile != "" {
+		return integritySignatureFile
+	}
+
+	return binary + integrity.SignatureExt
+}
+
+func init() {
+	rootCmd.AddCommand(integrityCmd)
+	integrityCmd.AddCommand(integrityKeygenCmd, integritySignCmd, integrityVerifyCmd)
+
+	for _, c := range []*cobra.Command{integrityKeygenCmd, integritySignCmd} {
+		c.Flags().StringVar(&integrityKeyFile, "key", "", "path to the private signing key")
+		_ = c.MarkFlagRequired("key")
+	}
+	for _, c := range []*cobra.Command{integritySignCmd, integrityVerifyCmd} {
+		c.Flags().StringVar(&integritySignatureFile, "signature", "", "path to the detached signature (default <binary>.sig)")
+	}
+	integrityVerifyCmd.Flags().StringVar(&integritySigningKey, "signing-key", "", "base64-encoded public key to verify with (default the embedded key)")
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// maintenanceDuration is the duration of the maintenance window to start.
+	maintenanceDuration time.Duration
+
+	// maintenanceEnd ends the maintenance window instead of starting one.
+	maintenanceEnd bool
+
+	// maintenanceInstance is the name of the instance the maintenance window is
+	// started for.
+	maintenanceInstance string
+
+	maintenanceCmd = &cobra.Command{
+		Use:   "maintenance",
+		Short: "Starts or ends a maintenance window on the running node",
+		Long: `Starts a maintenance window on the running node, in which missed blocks aren't
+counted, so that planned chain or infrastructure maintenance doesn't lead to
+promotions. Start the window on all validators in the set. Use --end to end the
+window early, and --instance to start or end it for an instance instead of the
+default validator. Recurring windows are configured in the [maintenance] section.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if maintenanceEnd {
+				if err := privval.EndMaintenance(maintenanceInstance, adminCredentials(maintenanceInstance, "")); err != nil {
+					fmt.Printf("couldn't end maintenance window: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Println("Ended maintenance window")
+				return
+			}
+
+			if err := privval.StartMaintenance(maintenanceInstance, maintenanceDuration, adminCredentials(maintenanceInstance, "")); err != nil {
+				fmt.Printf("couldn't start maintenance window: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Started maintenance window for %v\n", maintenanceDuration)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(maintenanceCmd)
+
+	maintenanceCmd.Flags().DurationVar(&maintenanceDuration, "duration", time.Hour, "duration of the maintenance window")
+	maintenanceCmd.Flags().BoolVar(&maintenanceEnd, "end", false, "end the maintenance window instead of starting one")
+	maintenanceCmd.Flags().StringVar(&maintenanceInstance, "instance", "", "name of the instance the maintenance window is started or ended for")
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// proposalsInstance is the name of the instance whose proposals are managed.
+	proposalsInstance string
+
+	// proposalsTOTP is the TOTP code required for approvals if totp_secret_file is
+	// set.
+	proposalsTOTP string
+
+	proposalsCmd = &cobra.Command{
+		Use:   "proposals",
+		Short: "Lists the proposals waiting for approval",
+		Long: `Lists the proposals the running node holds until a second operator approves them,
+which is the case if proposal_approval_timeout is set in the [privval] section. Use
+--instance to manage the proposals of an instance instead of the default
+validator's ones.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			proposals, err := privval.GetPendingProposals(proposalsInstance, adminCredentials(proposalsInstance, ""))
+			if err != nil {
+				fmt.Printf("couldn't get pending proposals: %v\n", err)
+				os.Exit(1)
+			}
+			if len(proposals) == 0 {
+				fmt.Println("No proposals are waiting for approval")
+				return
+			}
+			for _, p := range proposals {
+				fmt.Printf("%v: block %v, %v left\n", p.ID, p.BlockHash, time.Until(p.Deadline).Round(time.Second))
+			}
+		},
+	}
+
+	approveProposalCmd = &cobra.Command{
+		Use:   "approve <id>",
+		Short: "Approves a pending proposal, so that it is signed",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			decideProposal(args[0], true)
+			fmt.Printf("Approved proposal %v\n", args[0])
+		},
+	}
+
+	rejectProposalCmd = &cobra.Command{
+		Use:   "reject <id>",
+		Short: "Rejects a pending proposal, so that it isn't signed",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			decideProposal(args[0], false)
+			fmt.Printf("Rejected proposal %v\n", args[0])
+		},
+	}
+)
+
+// decideProposal approves or rejects the pending proposal with the given ID and exits
+// if it failed.
+func decideProposal(id string, approve bool) {
+	if err := privval.DecidePendingProposal(proposalsInstance, id, approve, adminCredentials(proposalsInstance, proposalsTOTP)); err != nil {
+		fmt.Printf("couldn't decide on proposal %v: %v\n", id, err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(proposalsCmd)
+	proposalsCmd.AddCommand(approveProposalCmd, rejectProposalCmd)
+
+	proposalsCmd.PersistentFlags().StringVar(&proposalsInstance, "instance", "", "name of the instance whose proposals are managed")
+	approveProposalCmd.Flags().StringVar(&proposalsTOTP, "totp", "", "current TOTP code, required if totp_secret_file is set")
+}
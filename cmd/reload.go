@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// reloadInstance is the name of the instance whose configuration is reloaded.
+	reloadInstance string
+
+	reloadCmd = &cobra.Command{
+		Use:   "reload",
+		Short: "Reloads the configuration of the running node",
+		Long: `Reloads the config.toml of the running node without dropping the connection to
+the validator, just like sending it a SIGHUP. The log level, threshold,
+threshold_duration, retry_dial_after and the feature flags are applied right
+away, and the validator is dialed again if the conn.key was rotated. Changes to
+any other settings require a restart. Use --instance to reload the configuration
+of an instance instead of the default validator.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := privval.ReloadConfig(reloadInstance, adminCredentials(reloadInstance, ""))
+			if err != nil {
+				fmt.Printf("couldn't reload %v: %v\n", config.File, err)
+				os.Exit(1)
+			}
+
+			if len(resp.Applied) == 0 {
+				fmt.Println("No reloadable settings were changed")
+			}
+			for _, change := range resp.Applied {
+				fmt.Printf("Applied %v\n", change)
+			}
+			if resp.RestartRequired {
+				fmt.Println("Further settings were changed, which only take effect after a restart")
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(reloadCmd)
+
+	reloadCmd.Flags().StringVar(&reloadInstance, "instance", "", "name of the instance whose configuration is reloaded")
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// reportInstance is the name of the instance whose signing history is reported.
+	reportInstance string
+
+	// reportFrom and reportTo are the first and last height of the report.
+	reportFrom, reportTo int64
+
+	// reportRecords lists the outcome of each height if set.
+	reportRecords bool
+
+	reportCmd = &cobra.Command{
+		Use:   "report",
+		Short: "Reports the signing outcomes of a range of heights",
+		Long: `Summarizes how many of the heights from --from to --to were signed, missed or
+refused by the running node, which keeps its signing history if retention is set in
+the [history] section. Missed blocks that weren't counted towards the threshold,
+e.g. during maintenance windows, don't count against the uptime. Use --instance to
+report on an instance instead of the default validator.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			report, err := privval.GetHistoryReport(reportInstance, reportFrom, reportTo, reportRecords, adminCredentials(reportInstance, ""))
+			if err != nil {
+				fmt.Printf("couldn't get the signing history: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf(`Signing history from height %v to %v:
+  Signed:  %v
+  Missed:  %v (%v not counted)
+  Refused: %v
+  Uptime:  %.2f%%
+`, report.From, report.To, report.Signed, report.Missed, report.Uncounted, report.Refused, 100*report.Uptime)
+
+			for _, r := range report.Records {
+				line := fmt.Sprintf("  %v %v %v (rank %v", r.Height, r.Time.Format(time.RFC3339), r.Outcome, r.Rank)
+				if r.SignedBy != "" {
+					line += ", by " + r.SignedBy
+				}
+				if r.Reason != "" {
+					line += ", " + r.Reason
+				}
+				if r.Source != "" {
+					line += ", via " + r.Source
+				}
+				fmt.Println(line + ")")
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+
+	reportCmd.Flags().StringVar(&reportInstance, "instance", "", "name of the instance to report on")
+	reportCmd.Flags().Int64Var(&reportFrom, "from", 0, "first height of the report")
+	reportCmd.Flags().Int64Var(&reportTo, "to", 0, "last height of the report, 0 for the latest one")
+	reportCmd.Flags().BoolVar(&reportRecords, "records", false, "list the outcome of each height")
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/privval"
 	"github.com/spf13/cobra"
 )
 
@@ -14,6 +16,19 @@ var (
 	}
 )
 
+// initHTTPClient points the commands talking to the HTTP server, e.g. `signctrl
+// status`, to the address it listens on according to the configuration. The default
+// address is kept if the configuration can't be loaded.
+func initHTTPClient() {
+	if cfg, err := config.LoadFrom(config.Dir()); err == nil {
+		privval.HTTPClientAddress = privval.ClientAddress(privval.HTTPListenAddress(cfg.Metrics))
+	}
+}
+
+func init() {
+	cobra.OnInitialize(initHTTPClient)
+}
+
 // Execute executes the root command.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
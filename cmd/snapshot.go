@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/backup"
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/rpc"
+	"github.com/BlockscapeNetwork/signctrl/snapshot"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// fenceCheckTimeout is the time after which the checks against the replaced node
+	// and the validator's RPC server are canceled.
+	fenceCheckTimeout = 10 * time.Second
+)
+
+var (
+	// applyForce allows overwriting an existing validator key.
+	applyForce bool
+
+	// applyReplacedAddr is the address of the HTTP server of the replaced node.
+	applyReplacedAddr string
+
+	// applySkipHeightCheck skips raising the watermark to the chain's latest height.
+	applySkipHeightCheck bool
+
+	// applyBackupIdentity is the path to the age identity the latest backup is
+	// decrypted with, if it is restored after applying the snapshot.
+	applyBackupIdentity string
+
+	snapshotCmd = &cobra.Command{
+		Use:   "snapshot",
+		Short: "Creates and applies snapshots of the node's identity",
+		Long: `Bundles the node's configuration, keys, watermark and rank into a single file, so
+that a failed machine can be replaced by applying the snapshot on a new one.`,
+	}
+
+	snapshotCreateCmd = &cobra.Command{
+		Use:   "create [file]",
+		Short: "Creates a snapshot of the node's identity",
+		Long: `Writes the config.toml, the validator's and connection keys, the watermarks and
+the rank in the configuration directory to the given file. SignCTRL must not be running
+while the snapshot is created. Afterwards, SignCTRL refuses to start from the
+configuration directory, so that the node can't sign alongside the machine the snapshot
+is applied on.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if sr, err := privval.GetStatus(); err == nil {
+				fmt.Printf("SignCTRL is running on rank %v, stop it before creating a snapshot\n", sr.Rank)
+				os.Exit(1)
+			}
+
+			f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, snapshot.PermSnapshotFile)
+			if err != nil {
+				fmt.Printf("couldn't create snapshot: %v\n", err)
+				os.Exit(1)
+			}
+			m, err := snapshot.Create(config.Dir(), f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(args[0])
+				fmt.Printf("couldn't create snapshot: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf(`Created snapshot %v in %v ✓
+  Chain ID:  %v
+  Watermark: %v/%v/%v
+  Rank:      %v
+SignCTRL refuses to start from %v from now on.
+`, m.ID, args[0], m.ChainID, m.Watermark.Height, m.Watermark.Round, m.Watermark.Step, m.Rank, config.Dir())
+		},
+	}
+
+	snapshotApplyCmd = &cobra.Command{
+		Use:   "apply [file]",
+		Short: "Applies a snapshot of a node's identity",
+		Long: `Verifies the snapshot and writes its contents to the configuration directory. A
+snapshot can only be applied once. Before applying it, SignCTRL makes sure that neither
+SignCTRL on this machine nor the replaced node (--replaced-addr) is running, and raises
+the watermark past the latest height of the chain, so that nothing the replaced node may
+have signed after the snapshot was created is signed again. With --restore-backup, the
+latest backup of the watermarks and the rank is restored on top of the snapshot.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			s, err := snapshot.Open(args[0])
+			if err != nil {
+				fmt.Printf("couldn't open snapshot: %v\n", err)
+				os.Exit(1)
+			}
+			var id *backup.Identity
+			if applyBackupIdentity != "" {
+				id = loadBackupIdentity(applyBackupIdentity)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), fenceCheckTimeout)
+			defer cancel()
+
+			// Fencing: the identity must not be in use anywhere else.
+			if sr, err := privval.GetStatus(); err == nil {
+				fmt.Printf("SignCTRL is running on rank %v, stop it before applying a snapshot\n", sr.Rank)
+				os.Exit(1)
+			}
+			if applyReplacedAddr != "" {
+				if err := snapshot.CheckReplaced(ctx, applyReplacedAddr); err != nil {
+					fmt.Printf("%v\n", err)
+					os.Exit(1)
+				}
+			}
+
+			// Never sign anything the replaced node may have signed after the snapshot was
+			// created.
+			opts := snapshot.ApplyOptions{Force: applyForce}
+			if !applySkipHeightCheck {
+				height, err := rpc.QueryLatestHeight(ctx, s.Manifest.ValidatorRPC, nil)
+				if err != nil {
+					fmt.Printf("couldn't query the latest height from %v: %v\n", s.Manifest.ValidatorRPC, err)
+					fmt.Println("Make sure the validator is reachable or use --skip-height-check if the chain is halted.")
+					os.Exit(1)
+				}
+				opts.MinHeight = height + 1
+			}
+
+			if err := s.Apply(config.Dir(), opts); err != nil {
+				fmt.Printf("couldn't apply snapshot: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Applied snapshot %v to %v ✓\n", s.Manifest.ID, config.Dir())
+			for _, p := range s.Paths() {
+				fmt.Printf("  %v\n", p)
+			}
+			if opts.MinHeight > 0 {
+				fmt.Printf("SignCTRL won't sign anything at or below height %v.\n", opts.MinHeight)
+			}
+
+			// The backup is more recent than the snapshot if the replaced node kept
+			// signing after the snapshot was created.
+			if applyBackupIdentity != "" {
+				if err := restoreBackup(config.Dir(), id); err != nil {
+					fmt.Printf("%v\n", err)
+					fmt.Println("The snapshot was applied, retry restoring the backup with signctrl backup restore.")
+					os.Exit(1)
+				}
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotCmd.AddCommand(snapshotApplyCmd)
+
+	snapshotApplyCmd.Flags().BoolVar(&applyForce, "force", false, "overwrite an existing validator key in the configuration directory")
+	snapshotApplyCmd.Flags().StringVar(&applyReplacedAddr, "replaced-addr", "", "address of the replaced node's HTTP server, e.g. http://10.0.0.1:8080, which must not be running")
+	snapshotApplyCmd.Flags().StringVar(&applyBackupIdentity, "restore-backup", "", "path to an age identity to restore the latest backup with after applying the snapshot")
+	snapshotApplyCmd.Flags().BoolVar(&applySkipHeightCheck, "skip-height-check", false, "don't raise the watermark to the chain's latest height")
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/BlockscapeNetwork/signctrl/internal/soak"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/hashicorp/logutils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	soakCfg            = soak.DefaultConfig()
+	soakNodeLogs       bool
+	soakMaxLeakedGorts int
+
+	soakCmd = &cobra.Command{
+		Use:   "soak",
+		Short: "Runs a full SignCTRL set against mock validators",
+		Long: `Runs a full SignCTRL set against mock validators for a long time, tracking memory,
+goroutines and missed signatures in order to catch leaks. The command fails if the
+set double-signed or goroutines leaked.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := types.NewSyncLogger(os.Stderr, "", 0)
+
+			// The nodes' logs are discarded unless requested, as they are very verbose.
+			nodeLogs := ioutil.Discard
+			if soakNodeLogs {
+				nodeLogs = &logutils.LevelFilter{
+					Levels:   types.LogLevels,
+					MinLevel: logutils.LogLevel("INFO"),
+					Writer:   os.Stderr,
+				}
+			}
+
+			// Cancel the soak test early on SIGINT/SIGTERM.
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			logger.Info("[soak] Running a set of %v nodes for %v...", soakCfg.SetSize, soakCfg.Duration)
+			report, err := soak.Run(ctx, soakCfg, logger, nodeLogs)
+			if err != nil {
+				logger.Error("%v", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf(`Soak test results:
+  Baseline:      %v
+  Final:         %v
+  Missed blocks: %v
+  Double-signed: %v
+  Dropped conns: %v
+  Stopped nodes: %v
+  Leaked gorts:  %v
+`, report.Baseline, report.Final, len(report.Missed), len(report.DoubleSigned), report.Drops, report.Stopped, report.LeakedGoroutines())
+
+			if len(report.DoubleSigned) > 0 || report.LeakedGoroutines() > soakMaxLeakedGorts {
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(soakCmd)
+	soakCmd.Flags().IntVar(&soakCfg.SetSize, "set-size", soakCfg.SetSize, "number of nodes in the set")
+	soakCmd.Flags().IntVar(&soakCfg.Threshold, "threshold", soakCfg.Threshold, "threshold of missed blocks in a row")
+	soakCmd.Flags().DurationVar(&soakCfg.Duration, "duration", soakCfg.Duration, "duration of the soak test")
+	soakCmd.Flags().DurationVar(&soakCfg.BlockTime, "block-time", soakCfg.BlockTime, "time between two blocks")
+	soakCmd.Flags().DurationVar(&soakCfg.SampleInterval, "sample-interval", soakCfg.SampleInterval, "interval in which memory and goroutines are sampled")
+	soakCmd.Flags().DurationVar(&soakCfg.DropInterval, "drop-interval", soakCfg.DropInterval, "interval in which a random connection is dropped (0 disables dropping)")
+	soakCmd.Flags().StringVar(&soakCfg.RetryDialAfter, "retry-dial-after", soakCfg.RetryDialAfter, "retry_dial_after setting of the nodes")
+	soakCmd.Flags().Int64Var(&soakCfg.Seed, "seed", soakCfg.Seed, "seed for the connections dropped")
+	soakCmd.Flags().BoolVar(&soakNodeLogs, "node-logs", false, "print the nodes' logs")
+	soakCmd.Flags().IntVar(&soakMaxLeakedGorts, "max-leaked-goroutines", 0, "number of goroutines allowed to be left over")
+}
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
@@ -9,17 +12,47 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/BlockscapeNetwork/signctrl/backup"
+	"github.com/BlockscapeNetwork/signctrl/chaos"
 	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/integrity"
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
 	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/remotewrite"
+	"github.com/BlockscapeNetwork/signctrl/resources"
+	"github.com/BlockscapeNetwork/signctrl/sandbox"
+	"github.com/BlockscapeNetwork/signctrl/snapshot"
+	"github.com/BlockscapeNetwork/signctrl/statemac"
 	"github.com/BlockscapeNetwork/signctrl/types"
 	"github.com/hashicorp/logutils"
-
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+const (
+	// exitCodeCrash is the exit code SignCTRL terminates with after recovering from a
+	// panic and writing a crash report.
+	exitCodeCrash = 3
 )
 
 var (
+	// chaosMode enables failure injection via the hidden --chaos flag.
+	chaosMode bool
+
+	// chaosSeed is the seed the injected failures are derived from.
+	chaosSeed int64
+
+	// fixPermissions fixes the permissions and owners of the keys and state files
+	// instead of refusing to start.
+	fixPermissions bool
+
+	// trustStateFiles trusts the state files as they are, generating the secret their
+	// MACs are keyed with if needed.
+	trustStateFiles bool
+
 	startCmd = &cobra.Command{
 		Use:   "start",
 		Short: "Starts the SignCTRL node",
@@ -32,56 +65,223 @@ var (
 			}
 			cfgDir := config.Dir()
 
-			// Set the logger and its mininum log level.
-			logger := types.NewSyncLogger(os.Stderr, "", 0)
-			filter := &logutils.LevelFilter{
-				Levels:   types.LogLevels,
-				MinLevel: logutils.LogLevel(cfg.Base.LogLevel),
-				Writer:   os.Stderr,
+			// Refuse to start if the node's identity was moved to another machine.
+			if err := snapshot.CheckFence(cfgDir); err != nil {
+				fmt.Printf("%v\n", err)
+				os.Exit(1)
+			}
+
+			// Set the logger and its mininum log level. The logs are rate limited
+			// behind the level filter, so that filtered lines don't use up the rate.
+			// JSON lines keep their level tags until they pass both.
+			var out io.Writer = os.Stderr
+			if cfg.Base.GetLogFormat() == types.LogFormatJSON {
+				out = types.NewJSONWriter(os.Stderr, types.SystemClock)
+			}
+			var limiter *types.RateLimitedWriter
+			if cfg.Base.LogRateLimit > 0 {
+				limiter = types.NewRateLimitedWriter(out, cfg.Base.LogRateLimit, cfg.Base.GetLogBurst(), types.SystemClock)
+				out = limiter
 			}
+			logger := types.NewSyncLogger(os.Stderr, "", 0)
+			logger.SetFormat(cfg.Base.GetLogFormat())
+			filter := types.NewLevelFilter(logutils.LogLevel(cfg.Base.LogLevel), out)
 			logger.SetOutput(filter)
 
+			// Verify the binary against its release signature before loading any key.
+			if cfg.Integrity.Enabled() {
+				if err := verifyBinary(cfg.Integrity, logger); err != nil {
+					logger.Error("couldn't verify the SignCTRL binary: %v\n", err)
+					if cfg.Integrity.Enforce {
+						os.Exit(1)
+					}
+				}
+			}
+
+			// Don't schedule onto more CPUs than the container's CPU quota allows, so
+			// that SignCTRL isn't throttled in bursts.
+			limits := resources.Detect().Limits()
+			if n, ok := resources.SetMaxProcs(limits); ok {
+				logger.Info("Set GOMAXPROCS to %v to match the CPU quota of %v CPUs", n, limits.CPUs)
+			}
+
+			// Refuse to start if a key or state file is accessible by other users.
+			checkPermissions(cfgDir, cfg)
+
+			// Refuse to start if a state file was modified out-of-band, unless the
+			// operator explicitly trusts the files as they are.
+			if trustStateFiles {
+				trustAllStateFiles(cfgDir, cfg)
+			}
+			if cfg.Privval.StateMAC {
+				if err := privval.VerifyStateFiles(cfgDir); err != nil {
+					fmt.Printf("couldn't verify state files:\n%v\n", err)
+					printStateMACHint(err)
+					os.Exit(1)
+				}
+			}
+
 			// Load the state.
 			state, err := config.LoadOrGenState(cfgDir)
 			if err != nil {
 				fmt.Printf("couldn't load %v:\n%v\n", config.StateFile, err)
 				os.Exit(1)
 			}
+			if state.Recovered {
+				logger.Warn("%v was corrupted, recovered the state from its backup", config.StateFile)
+			}
+
+			// Sign with the key in AWS KMS if configured, so that it never touches the
+			// disk, or with the priv_validator_key.json otherwise.
+			var tmpv tm_types.PrivValidator
+			if cfg.KMS.Enabled() {
+				if tmpv, err = privval.NewKMSSignerFor(cfg.KMS); err != nil {
+					fmt.Printf("couldn't use the KMS key %v:\n%v\n", cfg.KMS.KeyID, err)
+					os.Exit(1)
+				}
+			} else {
+				tmpv = tm_privval.LoadOrGenFilePV(privval.KeyFilePath(cfgDir), privval.StateFilePath(cfgDir))
+			}
 
 			// Initialize a new SCFilePV.
 			pv := privval.NewSCFilePV(
 				logger,
 				cfg,
 				state,
-				tm_privval.LoadOrGenFilePV(
-					privval.KeyFilePath(cfgDir),
-					privval.StateFilePath(cfgDir),
-				),
-				&http.Server{Addr: fmt.Sprintf(":%v", privval.DefaultHTTPPort)},
+				tmpv,
+				&http.Server{Addr: privval.HTTPListenAddress(cfg.Metrics)},
 			)
-			pv.Gauges = types.RegisterGauges()
+			pv.Gauges = types.RegisterGaugesFor(cfg.Privval.ChainID, "")
+			pv.LogFilter = filter
+			if err := privval.RegisterPoolMetrics(prometheus.DefaultRegisterer); err != nil {
+				logger.Error("couldn't register pool metrics: %v\n", err)
+			}
+			if limiter != nil {
+				limiter.RegisterMetrics()
+			}
+
+			// Protect the state files that were just created.
+			if cfg.Privval.StateMAC {
+				if err := privval.SignStateFiles(cfgDir); err != nil {
+					fmt.Printf("couldn't sign state files:\n%v\n", err)
+					os.Exit(1)
+				}
+			}
 
-			// Start the SignCTRL service.
-			if err := pv.Start(); err != nil {
-				logger.Error(err.Error())
-				if err := pv.Stop(); err != nil {
-					fmt.Println(err)
+			// Initialize an SCFilePV for each consumer chain. They share the feature flags
+			// with the provider chain.
+			pvs := []*privval.SCFilePV{pv}
+			for _, consumer := range cfg.Consumers {
+				consumerPV, err := privval.NewConsumerSCFilePV(logger, cfg, cfgDir, consumer)
+				if err != nil {
+					fmt.Printf("couldn't load consumer chain %v:\n%v\n", consumer.ChainID, err)
+					printStateMACHint(err)
 					os.Exit(1)
 				}
+				consumerPV.Features = pv.Features
+				consumerPV.Gauges = types.RegisterGaugesFor(consumer.ChainID, "")
+				pvs = append(pvs, consumerPV)
+			}
+
+			// Initialize an isolated SCFilePV for each additional validator in the instances
+			// directory. Their endpoints are served by the default validator's HTTP server.
+			names, err := privval.ListInstances(cfgDir)
+			if err != nil {
+				fmt.Printf("couldn't list instances:\n%v\n", err)
 				os.Exit(1)
 			}
+			pv.Instances = make(map[string]*privval.SCFilePV, len(names))
+			for _, name := range names {
+				if err := snapshot.CheckFence(privval.InstanceDir(cfgDir, name)); err != nil {
+					fmt.Printf("%v\n", err)
+					os.Exit(1)
+				}
+				instancePV, err := privval.NewInstanceSCFilePV(logger, cfgDir, name)
+				if err != nil {
+					fmt.Printf("couldn't load instance %v:\n%v\n", name, err)
+					printStateMACHint(err)
+					os.Exit(1)
+				}
+				instancePV.Gauges = types.RegisterGaugesFor(instancePV.Config.Privval.ChainID, name)
+				pv.Instances[name] = instancePV
+				pvs = append(pvs, instancePV)
+			}
 
-			// Wait either for the service itself or a system call to quit the process.
-			sigs := make(chan os.Signal, 1)
-			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+			// Inject failures for staging game-days if chaos mode is enabled.
+			if chaosMode {
+				logger.Info("[chaos] Chaos mode enabled with seed %v. NEVER use this on mainnet!", chaosSeed)
+				inj := chaos.NewInjector(chaosSeed, chaos.DefaultConfig(), logger, func() { os.Exit(1) })
+				for _, pv := range pvs {
+					pv.Dial = inj.Dialer(pv.Dial)
+					pv.QueryBlock = inj.BlockQuerier(pv.QueryBlock)
+				}
+			}
+
+			// Cancel the context passed down to the service on SIGINT/SIGTERM.
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			// Push the metrics over an outbound connection if the host can't be scraped.
+			if cfg.Metrics.RemoteWriteEnabled() {
+				pusher, err := remotewrite.NewPusher(cfg.Metrics, logger)
+				if err != nil {
+					fmt.Printf("couldn't set up the metrics push:\n%v\n", err)
+					os.Exit(1)
+				}
+				goroutines.Go("remote_write", func() { pusher.Run(ctx) })
+			}
+
+			// Back up the watermarks and the rank off-site whenever a watermark changed.
+			if cfg.Backup.Enabled() {
+				uploader, err := backup.NewUploader(cfg, cfgDir, logger)
+				if err != nil {
+					fmt.Printf("couldn't set up the backups:\n%v\n", err)
+					os.Exit(1)
+				}
+				goroutines.Go("backup", func() { uploader.Run(ctx) })
+			}
 
+			// Start the SignCTRL services.
+			for _, pv := range pvs {
+				if err := pv.StartContext(ctx); err != nil {
+					logger.Error(err.Error())
+					stopAll(pvs, logger)
+					os.Exit(1)
+				}
+			}
+
+			// Restrict the process to its configuration and state directories and the
+			// configured endpoints now that it is initialized.
+			if cfg.Sandbox.Enabled {
+				if err := applySandbox(cfgDir, cfg, logger); err != nil {
+					logger.Error("couldn't enable sandbox: %v", err)
+					stopAll(pvs, logger)
+					os.Exit(1)
+				}
+			}
+
+			// Reload the configuration file on SIGHUP.
+			goroutines.Go("sighup", func() { reloadOnSighup(ctx, pv) })
+
+			// Dump a diagnostic snapshot of every service on SIGUSR1.
+			goroutines.Go("sigusr1", func() { dumpDiagnosticsOnSigusr1(ctx, pvs) })
+
+			// Wait either for all services or a system call to quit the process. The
+			// services of the provider and consumer chains and of the instances shut
+			// themselves down independently of each other.
+			quit := make(chan struct{})
+			goroutines.Go("quit", func() {
+				for _, pv := range pvs {
+					<-pv.Quit()
+				}
+				close(quit)
+			})
 			select {
-			case <-pv.Quit(): // Used for self-induced shutdown
+			case <-quit: // Used for self-induced shutdown
 				pv.Logger.Info("Shutting SignCTRL down... \u23FB (quit)")
-			case <-sigs: // The sigs channel is only used for OS interrupt signals
+			case <-ctx.Done(): // The context is only canceled by OS interrupt signals
 				pv.Logger.Info("Shutting SignCTRL down... \u23FB (user/os interrupt)")
-				if err := pv.Stop(); err != nil {
-					logger.Error(err.Error())
+				if !stopAll(pvs, logger) {
 					os.Exit(1)
 				}
 			}
@@ -89,15 +289,192 @@ var (
 			// Wait for all log messages to be printed out.
 			time.Sleep(500 * time.Millisecond)
 
+			// Terminate with a dedicated exit code if the shutdown was caused by a panic.
+			for _, pv := range pvs {
+				if pv.IsCrashed() {
+					os.Exit(exitCodeCrash)
+				}
+			}
+
 			// Terminate the process gracefully with exit code 0.
 			os.Exit(0)
 		},
 	}
 )
 
+// stateDirs returns the configuration directory and the directories of the consumer
+// chains and instances, which hold their own keys and state files.
+func stateDirs(cfgDir string, cfg config.Config) []string {
+	dirs := []string{cfgDir}
+	for _, consumer := range cfg.Consumers {
+		dirs = append(dirs, privval.ConsumerDir(cfgDir, consumer.ChainID))
+	}
+	names, err := privval.ListInstances(cfgDir)
+	if err != nil {
+		fmt.Printf("couldn't list instances:\n%v\n", err)
+		os.Exit(1)
+	}
+	for _, name := range names {
+		dirs = append(dirs, privval.InstanceDir(cfgDir, name))
+	}
+
+	return dirs
+}
+
+// checkPermissions checks the permissions of the keys and state files in the
+// configuration directory and the directories of the consumer chains and instances,
+// and exits if they are insecure, unless they are fixed with --fix-permissions.
+func checkPermissions(cfgDir string, cfg config.Config) {
+	for _, dir := range stateDirs(cfgDir, cfg) {
+		fixed, err := privval.CheckPermissions(dir, fixPermissions)
+		for _, file := range fixed {
+			fmt.Printf("Fixed permissions of %v\n", file)
+		}
+		if err != nil {
+			fmt.Printf("%v\n", strings.TrimSpace(err.Error()))
+			os.Exit(1)
+		}
+	}
+}
+
+// trustAllStateFiles trusts the state files in the configuration directory and the
+// directories of the consumer chains and instances as they are, as requested with
+// --trust-state-files, and exits if they can't be signed.
+func trustAllStateFiles(cfgDir string, cfg config.Config) {
+	for _, dir := range stateDirs(cfgDir, cfg) {
+		if err := privval.TrustStateFiles(dir); err != nil {
+			fmt.Printf("couldn't trust the state files in %v:\n%v\n", dir, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Trusted the state files in %v as they are\n", dir)
+	}
+}
+
+// printStateMACHint explains how to trust the state files if err was returned because
+// the secret their MACs are keyed with is missing.
+func printStateMACHint(err error) {
+	if errors.Is(err, statemac.ErrMissingKey) {
+		fmt.Println("Check the state files and start with --trust-state-files to trust them as they are.")
+	}
+}
+
+// applySandbox sandboxes the process according to the configuration. TCP ports are
+// only restricted if the kernel supports it.
+func applySandbox(cfgDir string, cfg config.Config, logger types.Logger) error {
+	rules, err := sandbox.RulesFor(cfgDir, cfg, privval.DefaultHTTPPort)
+	if err != nil {
+		return err
+	}
+	abi, err := sandbox.Apply(rules)
+	if err != nil {
+		return err
+	}
+	if abi < 4 {
+		logger.Warn("Sandbox enabled, but TCP ports aren't restricted, as the kernel only supports landlock ABI version %v", abi)
+		return nil
+	}
+	logger.Info("Sandbox enabled (landlock ABI version %v)", abi)
+
+	return nil
+}
+
+// verifyBinary verifies the running binary against its detached signature and logs
+// its hash.
+func verifyBinary(cfg config.Integrity, logger types.Logger) error {
+	pub, err := integrity.PublicKey(cfg.SigningKey)
+	if err != nil {
+		return err
+	}
+	path, err := integrity.Executable()
+	if err != nil {
+		return err
+	}
+	hash, err := integrity.Verify(path, cfg.SignatureFile, pub)
+	if err != nil {
+		return err
+	}
+	logger.Info("Verified SignCTRL %v (%v), sha256 %v", SemVer, GitCommit, hash)
+
+	return nil
+}
+
+// stopAll stops all running services and returns false if any of them couldn't be
+// stopped.
+func stopAll(pvs []*privval.SCFilePV, logger types.Logger) bool {
+	ok := true
+	for _, pv := range pvs {
+		if !pv.IsRunning() {
+			continue
+		}
+		if err := pv.Stop(); err != nil {
+			logger.Error(err.Error())
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+// reloadOnSighup reloads the configuration file every time the process receives a
+// SIGHUP, until ctx is done. Only the settings that can be changed without dropping
+// the connection to the validator are applied, see privval.SCFilePV.ApplyConfig.
+func reloadOnSighup(ctx context.Context, pv *privval.SCFilePV) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			resp, err := pv.Reload()
+			if err != nil {
+				pv.Logger.Error("%v, keeping the current configuration", err)
+				continue
+			}
+			if len(resp.Applied) == 0 && !resp.RestartRequired {
+				pv.Logger.Info("Reloaded %v, nothing changed", config.File)
+			}
+		}
+	}
+}
+
+// dumpDiagnosticsOnSigusr1 writes a diagnostic snapshot of each of the services to
+// their configuration directories whenever the process receives SIGUSR1.
+func dumpDiagnosticsOnSigusr1(ctx context.Context, pvs []*privval.SCFilePV) {
+	sigusr1 := make(chan os.Signal, 1)
+	signal.Notify(sigusr1, syscall.SIGUSR1)
+	defer signal.Stop(sigusr1)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigusr1:
+			for _, pv := range pvs {
+				path, err := pv.WriteDiagnosticDump()
+				if err != nil {
+					pv.Logger.Error("couldn't write diagnostic dump: %v", err)
+					continue
+				}
+				pv.Logger.Info("Wrote diagnostic dump to %v", path)
+			}
+		}
+	}
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.AddCommand(startCmd)
+
+	// The chaos flags are hidden, as they are only meant for staging game-days.
+	startCmd.Flags().BoolVar(&chaosMode, "chaos", false, "randomly delay responses, drop connections and crash (staging only)")
+	startCmd.Flags().Int64Var(&chaosSeed, "chaos-seed", 1, "seed for the failures injected in chaos mode")
+	startCmd.Flags().BoolVar(&fixPermissions, "fix-permissions", false, "fix the permissions and owners of the keys and state files instead of refusing to start")
+	startCmd.Flags().BoolVar(&trustStateFiles, "trust-state-files", false, "trust the state files as they are, generating signctrl_mac.key for state_mac if needed")
+	_ = startCmd.Flags().MarkHidden("chaos")
+	_ = startCmd.Flags().MarkHidden("chaos-seed")
 }
 
 func initConfig() {
@@ -3,32 +3,175 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/resources"
 	"github.com/spf13/cobra"
+	tm_json "github.com/tendermint/tendermint/libs/json"
 )
 
 var (
+	// statusInstance is the name of the instance whose status is shown.
+	statusInstance string
+
+	// statusJSON prints the status as JSON.
+	statusJSON bool
+
 	statusCmd = &cobra.Command{
 		Use:   "status",
 		Short: "Shows the node's status",
-		Long:  "Prints out the current height, rank and missed block counter",
+		Long: `Prints out the current height, rank, missed block counter, last signature, uptime
+and enabled features of the default validator, or of the given instance if --instance
+is set`,
 		Run: func(cmd *cobra.Command, args []string) {
-			sr, err := privval.GetStatus()
+			sr, err := privval.GetInstanceStatus(statusInstance)
 			if err != nil {
 				fmt.Printf("couldn't get status: %v", err)
 				os.Exit(1)
 			}
+			if statusJSON {
+				bz, err := tm_json.MarshalIndent(sr, "", "  ")
+				if err != nil {
+					fmt.Printf("couldn't marshal status: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Println(string(bz))
+				return
+			}
+
+			counter := fmt.Sprintf("%v/%v", sr.Counter, sr.Threshold)
+			if sr.CounterLocked {
+				counter += " (locked until the first commitsig)"
+			}
+			lastSigned := "nothing since the start"
+			if sr.LastSignedHeight > 0 {
+				lastSigned = fmt.Sprintf("height %v, round %v", sr.LastSignedHeight, sr.LastSignedRound)
+			}
+
+			features := "none"
+			if len(sr.Features) > 0 {
+				features = strings.Join(sr.Features, ", ")
+			}
+
+			var goroutines []string
+			for subsystem, n := range sr.Goroutines {
+				goroutines = append(goroutines, fmt.Sprintf("%v=%v", subsystem, n))
+			}
+			sort.Strings(goroutines)
 
-			fmt.Printf(`Status of SignCTRL validator:
-  Height:  %v
-  Rank:    %v/%v
-  Counter: %v/%v
-`, sr.Height, sr.Rank, sr.SetSize, sr.Counter, sr.Threshold)
+			slashing := "unknown"
+			if sr.Slashing != nil {
+				slashing = fmt.Sprintf("jailed=%v, tombstoned=%v, missed_blocks=%v", sr.Slashing.Jailed, sr.Slashing.Tombstoned, sr.Slashing.MissedBlocksCounter)
+			}
+			if sr.SigningPaused {
+				slashing += " (signing paused)"
+			}
+
+			name := "SignCTRL validator"
+			if statusInstance != "" {
+				name = fmt.Sprintf("SignCTRL instance %v", statusInstance)
+			}
+
+			fmt.Printf(`Status of %v:
+  Height:     %v
+  Rank:       %v/%v
+  Counter:    %v
+  Signed:     %v
+  Uptime:     %v
+  Connection: %v
+  Slashing:   %v
+  Features:   %v
+  Goroutines: %v
+  Resources:  %v
+`, name, sr.Height, sr.Rank, sr.SetSize, counter, lastSigned, sr.Uptime.Round(time.Second), sr.Connection, slashing, features, strings.Join(goroutines, ", "), formatResources(sr.Resources))
+
+			if sr.ThresholdDuration > 0 {
+				fmt.Printf("  Missed for: %v/%v\n", sr.MissedFor, sr.ThresholdDuration)
+			}
+			if len(sr.Stalled) > 0 {
+				fmt.Printf("  Unhealthy: stalled %v\n", strings.Join(sr.Stalled, ", "))
+			}
+			if sr.ValidatorStaleSince != nil {
+				fmt.Printf("  Unhealthy: validator stale since %v\n", sr.ValidatorStaleSince.Format(time.RFC3339))
+			}
+			if sr.Clock != nil {
+				skew := ""
+				if sr.Clock.Skewed {
+					skew = " (exceeds max_offset, not promoting)"
+				}
+				fmt.Printf("  Clock offset: %v%v\n", sr.Clock.Offset, skew)
+			}
+			if sr.Epoch > 0 {
+				fmt.Printf("  Epoch:      %v\n", sr.Epoch)
+			}
+			for _, p := range sr.Peers {
+				state := "live"
+				if !p.Live {
+					state = "lost"
+				}
+				fmt.Printf("  Peer %v: rank %v, signed height %v, epoch %v (%v, last seen %v)\n", p.Node, p.Rank, p.Height, p.Epoch, state, p.LastSeen.Format(time.RFC3339))
+			}
+			if sr.Election != nil {
+				fmt.Printf("  Election: %v in term %v, leader %v\n", sr.Election.Role, sr.Election.Term, sr.Election.Leader)
+			}
+			if sr.BlockTime > 0 {
+				fmt.Printf("  Block time: %v\n", sr.BlockTime)
+			}
+			if sr.MaintenanceUntil != nil {
+				fmt.Printf("  Maintenance until: %v\n", sr.MaintenanceUntil.Format(time.RFC3339))
+			}
+			if len(sr.UpgradeHeights) > 0 {
+				fmt.Printf("  Upgrade heights: %v\n", sr.UpgradeHeights)
+			}
+			if sr.LastError != nil {
+				fmt.Printf("  Last error: [%v] %v (%v)\n", sr.LastError.Code, sr.LastError.Message, sr.LastError.Time.Format(time.RFC3339))
+			}
+			if len(sr.RPCEndpoints) > 0 {
+				fmt.Println("  RPC endpoints:")
+				for _, e := range sr.RPCEndpoints {
+					health := "healthy"
+					if !e.Healthy {
+						health = "demoted"
+					}
+					if e.Error != "" {
+						health += ": " + e.Error
+					}
+					fmt.Printf("    %v (height %v, latency %v, %v)\n", e.Address, e.Height, e.Latency, health)
+				}
+			}
 		},
 	}
 )
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().StringVar(&statusInstance, "instance", "", "name of the instance to show the status of")
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "print the status as JSON")
+}
+
+// formatResources formats the resource limits and usage for the status output.
+func formatResources(r resources.Resources) string {
+	if r.Cgroup == "" {
+		return fmt.Sprintf("no cgroup, GOMAXPROCS=%v", r.MaxProcs)
+	}
+
+	cpus := "unlimited CPUs"
+	if r.Limits.CPUs > 0 {
+		cpus = fmt.Sprintf("%.2f CPUs", r.Limits.CPUs)
+	}
+	memory := "unlimited memory"
+	if r.Limits.Memory > 0 {
+		memory = fmt.Sprintf("%v memory", formatMiB(r.Limits.Memory))
+	}
+
+	return fmt.Sprintf("%v, %v (cgroup %v), GOMAXPROCS=%v, used %v CPU time and %v memory", cpus, memory, r.Cgroup, r.MaxProcs, r.Usage.CPUTime.Round(time.Millisecond), formatMiB(r.Usage.Memory))
+}
+
+// formatMiB formats the given number of bytes in MiB.
+func formatMiB(bytes uint64) string {
+	return fmt.Sprintf("%.1f MiB", float64(bytes)/(1<<20))
 }
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// swapBackend is the name of the signer backend to swap to.
+	swapBackend string
+
+	// swapParams are the parameters the new signer backend is created with.
+	swapParams map[string]string
+
+	// swapInstance is the name of the instance whose signer backend is swapped.
+	swapInstance string
+
+	// swapTOTP is the TOTP code required if totp_secret_file is set.
+	swapTOTP string
+
+	swapSignerCmd = &cobra.Command{
+		Use:   "swap-signer",
+		Short: "Swaps the running node's signer backend",
+		Long: fmt.Sprintf(`Swaps the signer backend of the running node without restarting it, e.g. to move
+the key to an HSM or to rotate to a new KMS key holding the same key. The node drains
+the requests it is handling, checks that the new backend's public key matches and
+then swaps to it. Use --instance to swap the signer backend of an instance instead of
+the default validator's one. The token is read from the configured token_file, and
+the TOTP code must be given with --totp if totp_secret_file is set.
+
+Available backends: %v
+  file: --param key_file=<path> --param state_file=<path>`, strings.Join(privval.SignerBackends(), ", ")),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := privval.SwapInstanceSigner(swapInstance, privval.SwapSignerRequest{
+				Backend: swapBackend,
+				Params:  swapParams,
+			}, adminCredentials(swapInstance, swapTOTP))
+			if err != nil {
+				fmt.Printf("couldn't swap signer backend: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Swapped signer backend to %v\n", swapBackend)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(swapSignerCmd)
+
+	swapSignerCmd.Flags().StringVar(&swapBackend, "backend", "file", "name of the signer backend to swap to")
+	swapSignerCmd.Flags().StringToStringVar(&swapParams, "param", nil, "parameter of the new signer backend as key=value (can be repeated)")
+	swapSignerCmd.Flags().StringVar(&swapInstance, "instance", "", "name of the instance whose signer backend is swapped")
+	swapSignerCmd.Flags().StringVar(&swapTOTP, "totp", "", "current TOTP code, required if totp_secret_file is set")
+}
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 
+	"github.com/BlockscapeNetwork/signctrl/integrity"
 	"github.com/spf13/cobra"
 )
 
@@ -13,10 +16,23 @@ var (
 	// SemVer is the semantiv version of SignCTRL.
 	SemVer = ""
 
+	// printManifest prints the build manifest as JSON.
+	printManifest bool
+
 	versionCmd = &cobra.Command{
 		Use:   "version",
 		Short: "Prints out the version of SignCTRL",
 		Run: func(cmd *cobra.Command, args []string) {
+			if printManifest {
+				bz, err := json.MarshalIndent(integrity.NewManifest(SemVer, GitCommit), "", "  ")
+				if err != nil {
+					fmt.Printf("couldn't marshal manifest: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Println(string(bz))
+				return
+			}
+
 			fmt.Printf(`SignCTRL
   Version:    %v
   Git commit: %v
@@ -27,4 +43,6 @@ var (
 
 func init() {
 	rootCmd.AddCommand(versionCmd)
+
+	versionCmd.Flags().BoolVar(&printManifest, "manifest", false, "print the build manifest including the embedded signing key as JSON")
 }
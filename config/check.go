@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Problem is a problem found in a configuration file.
+type Problem struct {
+	// Line is the line of the file the problem is located at, or 0 if it is unknown.
+	Line int
+
+	// Message describes the problem.
+	Message string
+}
+
+// String returns the problem prefixed with its line, if known.
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return p.Message
+	}
+
+	return fmt.Sprintf("line %v: %v", p.Line, p.Message)
+}
+
+// parseErrLineRegExp matches the position in the errors of the TOML parser, e.g. "(3, 1)".
+var parseErrLineRegExp = regexp.MustCompile(`\((\d+), \d+\)`)
+
+// problemKeyRegExp matches the key at the start of a validation error, e.g. set_size
+// in "set_size must be 2 or higher".
+var problemKeyRegExp = regexp.MustCompile(`^([a-z_]+) `)
+
+// Check loads and validates the configuration file at the given path, just like
+// LoadFrom, but returns all problems found instead of failing on the first one. Each
+// problem is located at the line its key is set in, if the key is set exactly once. An
+// error is only returned if the file couldn't be read.
+func Check(file string) (Config, []Problem, error) {
+	bytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	lines := strings.Split(string(bytes), "\n")
+
+	v := viper.New()
+	v.SetConfigType("toml")
+	if err := v.ReadConfig(strings.NewReader(string(bytes))); err != nil {
+		p := Problem{Message: err.Error()}
+		if m := parseErrLineRegExp.FindStringSubmatch(err.Error()); m != nil {
+			p.Line, _ = strconv.Atoi(m[1])
+		}
+
+		return Config{}, []Problem{p}, nil
+	}
+	var c Config
+	if err := v.Unmarshal(&c); err != nil {
+		return Config{}, []Problem{{Message: err.Error()}}, nil
+	}
+
+	var problems []Problem
+	if err := c.validate(); err != nil {
+		for _, msg := range strings.Split(err.Error(), "\n") {
+			msg = strings.TrimSpace(msg)
+			if msg == "" {
+				continue
+			}
+			p := Problem{Message: msg}
+			if m := problemKeyRegExp.FindStringSubmatch(msg); m != nil {
+				p.Line = keyLine(lines, m[1])
+			}
+			problems = append(problems, p)
+		}
+	}
+
+	return c, problems, nil
+}
+
+// keyLine returns the line the given key is set in, or 0 if it isn't set exactly once.
+func keyLine(lines []string, key string) int {
+	keyRegExp := regexp.MustCompile(`^\s*` + regexp.QuoteMeta(key) + `\s*=`)
+	line := 0
+	for i, l := range lines {
+		if keyRegExp.MatchString(l) {
+			if line != 0 {
+				return 0
+			}
+			line = i + 1
+		}
+	}
+
+	return line
+}
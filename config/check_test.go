@@ -0,0 +1,45 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, Create(dir))
+	require.NoError(t, Override(dir, map[string]interface{}{
+		"base.start_rank":  1,
+		"privval.chain_id": "testchain",
+	}))
+	c, problems, err := Check(FilePath(dir))
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+	assert.Equal(t, "testchain", c.Privval.ChainID)
+
+	// All problems are returned, located at the lines of their keys.
+	require.NoError(t, Override(dir, map[string]interface{}{
+		"base.set_size":  1,
+		"base.threshold": 1,
+	}))
+	_, problems, err = Check(FilePath(dir))
+	require.NoError(t, err)
+	require.Len(t, problems, 2)
+	assert.Equal(t, Problem{Line: 33, Message: "set_size must be 2 or higher"}, problems[0])
+	assert.Equal(t, "line 40: threshold must be 2 or higher", problems[1].String())
+
+	// Syntax errors are located as well.
+	file := filepath.Join(dir, "broken.toml")
+	require.NoError(t, ioutil.WriteFile(file, []byte("[base]\nset_size = ]\nthreshold = 10\n"), 0600))
+	_, problems, err = Check(file)
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	assert.Equal(t, 2, problems[0].Line)
+
+	_, _, err = Check(filepath.Join(dir, "missing.toml"))
+	assert.Error(t, err)
+}
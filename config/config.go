@@ -1,9 +1,13 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -11,7 +15,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/BlockscapeNetwork/signctrl/alerts"
+	"github.com/BlockscapeNetwork/signctrl/maintenance"
 	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
 	"github.com/hashicorp/logutils"
 	"github.com/spf13/viper"
 )
@@ -19,14 +26,64 @@ import (
 const (
 	// File is the full file name of the configuration file.
 	File = "config.toml"
+
+	// DefaultMaxParallelQueries is the default maximum number of RPC servers queried
+	// at the same time when a missed block is confirmed.
+	DefaultMaxParallelQueries = 4
+
+	// MinRetryDialAfter and MaxRetryDialAfter bound retry_dial_after. Shorter times
+	// reconnect before the validator's next ping is due, longer ones leave a lost
+	// connection undetected for too long.
+	MinRetryDialAfter = time.Second
+	MaxRetryDialAfter = time.Hour
+
+	// DefaultReadDeadline is the default read_deadline. MinReadDeadline and
+	// MaxReadDeadline bound it.
+	DefaultReadDeadline = 5 * time.Minute
+	MinReadDeadline     = time.Second
+	MaxReadDeadline     = 24 * time.Hour
+
+	// MinDialBackoff and MaxDialBackoff bound the delays between the attempts to dial
+	// the validator.
+	MinDialBackoff = 10 * time.Millisecond
+	MaxDialBackoff = time.Hour
 )
 
+// validateDuration returns the error line for the given field if value isn't a
+// duration like "15s" or "1m30s" between min and max. There is no upper bound if max
+// is 0.
+func validateDuration(name, value string, min, max time.Duration) string {
+	d, err := time.ParseDuration(value)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("\t%v must be a duration like \"15s\" or \"1m30s\", but is %q\n", name, value)
+	case d < min:
+		return fmt.Sprintf("\t%v must be at least %v\n", name, min)
+	case max > 0 && d > max:
+		return fmt.Sprintf("\t%v must be at most %v\n", name, max)
+	}
+
+	return ""
+}
+
 // Base defines the base configuration parameters for SignCTRL.
 type Base struct {
 	// LogLevel determines the minimum log level for SignCTRL logs.
 	// Can be DEBUG, INFO, WARN or ERR.
 	LogLevel string `mapstructure:"log_level"`
 
+	// LogFormat is the format of SignCTRL logs. Can be text or json. Defaults to text.
+	LogFormat string `mapstructure:"log_format"`
+
+	// LogRateLimit is the number of bytes per second that may be logged. DEBUG and
+	// INFO lines exceeding it are dropped, so that an error loop can't fill the disk.
+	// Logs aren't limited if it is 0.
+	LogRateLimit int `mapstructure:"log_rate_limit"`
+
+	// LogBurst is the number of bytes that may be logged at once, regardless of
+	// LogRateLimit. Defaults to LogRateLimit.
+	LogBurst int `mapstructure:"log_burst"`
+
 	// SetSize determines the number of validators in the SignCTRL set.
 	SetSize int `mapstructure:"set_size"`
 
@@ -34,6 +91,11 @@ type Base struct {
 	// triggers a rank update in the SignCTRL set.
 	Threshold int `mapstructure:"threshold"`
 
+	// ThresholdDuration is the time without a block signed by rank 1, measured by the
+	// block timestamps, that triggers a rank update as well. It suits chains with
+	// highly variable block times. Only the threshold applies if it is empty.
+	ThresholdDuration string `mapstructure:"threshold_duration"`
+
 	// StartRank determines the validator's rank on startup and therefore whether it
 	// has permission to sign votes/proposals or not.
 	StartRank int `mapstructure:"start_rank"`
@@ -50,9 +112,76 @@ type Base struct {
 	// RetryDialAfter is the time after which SignCTRL assumes it lost connection to
 	// the validator and retries dialing it.
 	RetryDialAfter string `mapstructure:"retry_dial_after"`
+
+	// ReadDeadline is the time without any data received on the connection to the
+	// validator, pings included, after which the connection is considered to be dead
+	// and redialed. Unlike RetryDialAfter, it applies to every transport, including
+	// gRPC, whose validators don't ping. Defaults to 5m.
+	ReadDeadline string `mapstructure:"read_deadline"`
+
+	// DialBackoffInitial is the delay before the first retry to dial the validator.
+	// Defaults to 1s.
+	DialBackoffInitial string `mapstructure:"dial_backoff_initial"`
+
+	// DialBackoffMax is the maximum delay between two attempts to dial the validator.
+	// Defaults to 30s.
+	DialBackoffMax string `mapstructure:"dial_backoff_max"`
+
+	// DialBackoffMultiplier is the factor the delay between the attempts to dial the
+	// validator grows by after every failed attempt. Defaults to 2.
+	DialBackoffMultiplier float64 `mapstructure:"dial_backoff_multiplier"`
+
+	// BlockSubscription determines whether SignCTRL subscribes to new blocks via the
+	// websocket endpoint of the validator's RPC server instead of polling each block
+	// it needs. Blocks are still polled while the subscription is down.
+	BlockSubscription bool `mapstructure:"block_subscription"`
+
+	// AdaptiveTimeouts determines whether SignCTRL measures the chain's block time
+	// from the blocks it queries and derives the time after which it retries dialing
+	// the validator and the health check interval of the RPC endpoints from it,
+	// instead of using the configured values.
+	AdaptiveTimeouts bool `mapstructure:"adaptive_timeouts"`
+
+	// Coordination determines how the set decides which node signs. Can be threshold,
+	// which counts missed blocks in a row to update the ranks, or raft, which elects
+	// the signer among the nodes in the p2p section. Defaults to threshold.
+	Coordination string `mapstructure:"coordination"`
+}
+
+const (
+	// CoordinationThreshold updates the ranks once the threshold of missed blocks in
+	// a row is exceeded.
+	CoordinationThreshold = "threshold"
+
+	// CoordinationRaft elects the signer with Raft's leader election.
+	CoordinationRaft = "raft"
+)
+
+// hostNameLabel matches a single label of a host name. Underscores are allowed, as
+// e.g. docker-compose service names may contain them.
+var hostNameLabel = regexp.MustCompile(`^[a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9_])?$`)
+
+// isHostName reports whether host is a syntactically valid host name, e.g.
+// validator-0.validators. The top-level label must not be numeric, so that mistyped
+// IP addresses aren't taken for host names.
+func isHostName(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	labels := strings.Split(host, ".")
+	for _, label := range labels {
+		if !hostNameLabel.MatchString(label) {
+			return false
+		}
+	}
+	_, err := strconv.Atoi(labels[len(labels)-1])
+
+	return err != nil
 }
 
-// validateAddress validates the configuration's addresses.
+// validateAddress validates the configuration's addresses. TCP addresses may either
+// contain an IP address or a host name, which is resolved whenever it's dialed.
 func validateAddress(addr string, addrName string) error {
 	protocol := regexp.MustCompile(`(tcp|unix)://`).FindString(addr)
 	switch protocol {
@@ -64,8 +193,8 @@ func validateAddress(addr string, addrName string) error {
 		if err != nil {
 			return fmt.Errorf("%v is not in the host:port format", addrName)
 		}
-		if ip := net.ParseIP(host); ip == nil {
-			return fmt.Errorf("%v is not a valid IPv4 address", addrName)
+		if ip := net.ParseIP(host); ip == nil && !isHostName(host) {
+			return fmt.Errorf("%v is neither a valid IP address nor a valid host name", addrName)
 		}
 
 	case "unix://":
@@ -83,12 +212,26 @@ func (b Base) validate() error {
 	if match, _ := regexp.MatchString(logLevelsToRegExp(&types.LogLevels), b.LogLevel); !match {
 		errs += fmt.Sprintf("\tlog_level must be one of the following: %v\n", types.LogLevels)
 	}
+	switch types.LogFormat(b.LogFormat) {
+	case "", types.LogFormatText, types.LogFormatJSON:
+	default:
+		errs += fmt.Sprintf("\tlog_format must be either %v or %v\n", types.LogFormatText, types.LogFormatJSON)
+	}
+	if b.LogRateLimit < 0 {
+		errs += "\tlog_rate_limit must be 0 or higher\n"
+	}
+	if b.LogBurst < 0 {
+		errs += "\tlog_burst must be 0 or higher\n"
+	}
 	if b.SetSize < 2 {
 		errs += "\tset_size must be 2 or higher\n"
 	}
 	if b.Threshold < 2 {
 		errs += "\tthreshold must be 2 or higher\n"
 	}
+	if b.ThresholdDuration != "" {
+		errs += validateDuration("threshold_duration", b.ThresholdDuration, time.Second, 0)
+	}
 	if b.StartRank < 1 {
 		errs += "\tstart_rank must be 1 or higher\n"
 	}
@@ -98,17 +241,30 @@ func (b Base) validate() error {
 	if err := validateAddress(b.ValidatorListenAddressRPC, "validator_laddr_rpc"); err != nil {
 		errs += fmt.Sprintf("\t%v\n", err.Error())
 	}
+	switch b.Coordination {
+	case "", CoordinationThreshold, CoordinationRaft:
+	default:
+		errs += fmt.Sprintf("\tcoordination must be either %v or %v\n", CoordinationThreshold, CoordinationRaft)
+	}
 	if b.RetryDialAfter == "" {
 		errs += "\tretry_dial_after must not be empty\n"
 	} else {
-		time := regexp.MustCompile(`[1-9][0-9]+`).FindString(b.RetryDialAfter)
-		if time == "" {
-			errs += "\tretry_dial_after is missing the time\n"
-		}
-		timeUnit := regexp.MustCompile(`s|m|h`).FindString(b.RetryDialAfter)
-		if timeUnit == "" {
-			errs += "\tretry_dial_after is missing the unit of time\n"
-		}
+		errs += validateDuration("retry_dial_after", b.RetryDialAfter, MinRetryDialAfter, MaxRetryDialAfter)
+	}
+	if b.ReadDeadline != "" {
+		errs += validateDuration("read_deadline", b.ReadDeadline, MinReadDeadline, MaxReadDeadline)
+	}
+	if b.DialBackoffInitial != "" {
+		errs += validateDuration("dial_backoff_initial", b.DialBackoffInitial, MinDialBackoff, MaxDialBackoff)
+	}
+	if b.DialBackoffMax != "" {
+		errs += validateDuration("dial_backoff_max", b.DialBackoffMax, MinDialBackoff, MaxDialBackoff)
+	}
+	if b.DialBackoffInitial != "" && b.DialBackoffMax != "" && b.GetDialBackoffMax() < b.GetDialBackoffInitial() {
+		errs += "\tdial_backoff_max must not be less than dial_backoff_initial\n"
+	}
+	if b.DialBackoffMultiplier != 0 && b.DialBackoffMultiplier < 1 {
+		errs += "\tdial_backoff_multiplier must be at least 1\n"
 	}
 	if errs != "" {
 		return errors.New(errs)
@@ -117,11 +273,146 @@ func (b Base) validate() error {
 	return nil
 }
 
+// GetThresholdDuration returns the parsed ThresholdDuration, or 0 if it is empty.
+func (b Base) GetThresholdDuration() time.Duration {
+	d, _ := time.ParseDuration(b.ThresholdDuration)
+	return d
+}
+
+// GetRetryDialAfter returns the parsed RetryDialAfter.
+func (b Base) GetRetryDialAfter() time.Duration {
+	d, _ := time.ParseDuration(b.RetryDialAfter)
+	return d
+}
+
+// GetReadDeadline returns the parsed ReadDeadline, or DefaultReadDeadline if it is
+// empty.
+func (b Base) GetReadDeadline() time.Duration {
+	if b.ReadDeadline == "" {
+		return DefaultReadDeadline
+	}
+	d, _ := time.ParseDuration(b.ReadDeadline)
+	return d
+}
+
+// GetDialBackoffInitial returns the parsed DialBackoffInitial, or 0 if it is empty.
+func (b Base) GetDialBackoffInitial() time.Duration {
+	d, _ := time.ParseDuration(b.DialBackoffInitial)
+	return d
+}
+
+// GetDialBackoffMax returns the parsed DialBackoffMax, or 0 if it is empty.
+func (b Base) GetDialBackoffMax() time.Duration {
+	d, _ := time.ParseDuration(b.DialBackoffMax)
+	return d
+}
+
+// UsesRaft returns true if the signer is elected with Raft's leader election.
+func (b Base) UsesRaft() bool {
+	return b.Coordination == CoordinationRaft
+}
+
+// GetLogFormat returns LogFormat, or text if no format is configured.
+func (b Base) GetLogFormat() types.LogFormat {
+	if b.LogFormat == "" {
+		return types.LogFormatText
+	}
+
+	return types.LogFormat(b.LogFormat)
+}
+
+// GetLogBurst returns LogBurst, or LogRateLimit if no burst is configured.
+func (b Base) GetLogBurst() int {
+	if b.LogBurst == 0 {
+		return b.LogRateLimit
+	}
+
+	return b.LogBurst
+}
+
 // PrivValidator defines the types of private validators that sign incoming sign
 // requests.
 type PrivValidator struct {
 	// ChainID is the chain that the validator validates for.
 	ChainID string `mapstructure:"chain_id"`
+
+	// TmkmsStateFile is the path to tmkms's consensus state file. If set, SignCTRL
+	// raises its last sign state to tmkms's watermark on startup and keeps the file up
+	// to date whenever it signs, so that it can be swapped with tmkms on the same host.
+	TmkmsStateFile string `mapstructure:"tmkms_state_file"`
+
+	// ValidatorSetCheck determines what happens if the validator's key is not part of
+	// the chain's active validator set on startup. Can be off, warn, which logs a
+	// warning, or refuse, which stops SignCTRL. Defaults to warn.
+	ValidatorSetCheck string `mapstructure:"validator_set_check"`
+
+	// StateMAC protects the priv_validator_state.json, signctrl_state.json and
+	// signctrl_watermark.json files with MACs that are verified on startup, so that
+	// SignCTRL refuses to start if they were modified out-of-band.
+	StateMAC bool `mapstructure:"state_mac"`
+
+	// ProposalApprovalTimeout is the time a proposal is held until a second operator
+	// approves it via the admin API. Proposals that aren't approved in time get a nil
+	// response. Proposals are signed without approval if it is empty.
+	ProposalApprovalTimeout string `mapstructure:"proposal_approval_timeout"`
+
+	// Transport is the transport the validator sends its requests over. Can be socket,
+	// in which case SignCTRL dials the validator_laddr and encrypts the connection with
+	// Tendermint's secret connection, mtls, in which case SignCTRL dials it over mutual
+	// TLS, e.g. through a TLS-terminating proxy, or grpc, in which case SignCTRL serves
+	// the PrivValidatorAPI of Tendermint v0.35+ at GRPCListenAddress. Defaults to
+	// socket.
+	Transport string `mapstructure:"transport"`
+
+	// TLSCertFile and TLSKeyFile are the paths to SignCTRL's client certificate and key
+	// for the mtls transport. They are read on every connection attempt, so renewed
+	// certificates are picked up without a restart.
+	TLSCertFile string `mapstructure:"tls_cert_file"`
+	TLSKeyFile  string `mapstructure:"tls_key_file"`
+
+	// TLSCAFile is the path to the CA certificate the validator's or proxy's server
+	// certificate must be signed by for the mtls transport.
+	TLSCAFile string `mapstructure:"tls_ca_file"`
+
+	// TLSServerName is the name the server certificate is verified against for the
+	// mtls transport. Defaults to the host of the validator_laddr.
+	TLSServerName string `mapstructure:"tls_server_name"`
+
+	// GRPCListenAddress is the TCP socket address SignCTRL's gRPC server listens on.
+	GRPCListenAddress string `mapstructure:"grpc_laddr"`
+
+	// GRPCCertFile and GRPCKeyFile are the paths to the gRPC server's TLS certificate
+	// and key. The gRPC server doesn't use TLS if they are empty.
+	GRPCCertFile string `mapstructure:"grpc_cert_file"`
+	GRPCKeyFile  string `mapstructure:"grpc_key_file"`
+
+	// GRPCClientCAFile is the path to the CA certificate the validator's client
+	// certificate must be signed by. Client certificates are not required if it is
+	// empty.
+	GRPCClientCAFile string `mapstructure:"grpc_client_ca_file"`
+}
+
+// RequiresProposalApproval returns true if proposals must be approved before they are
+// signed.
+func (p PrivValidator) RequiresProposalApproval() bool {
+	return p.ProposalApprovalTimeout != ""
+}
+
+// GetProposalApprovalTimeout returns the parsed ProposalApprovalTimeout.
+func (p PrivValidator) GetProposalApprovalTimeout() time.Duration {
+	d, _ := time.ParseDuration(p.ProposalApprovalTimeout)
+	return d
+}
+
+// UsesGRPC returns true if the validator sends its requests via gRPC.
+func (p PrivValidator) UsesGRPC() bool {
+	return p.Transport == "grpc"
+}
+
+// UsesMTLS returns true if the validator is dialed over mutual TLS instead of the
+// secret connection.
+func (p PrivValidator) UsesMTLS() bool {
+	return p.Transport == "mtls"
 }
 
 // validate validates the configuration's privval section.
@@ -130,6 +421,31 @@ func (p PrivValidator) validate() error {
 	if p.ChainID == "" {
 		errs += "\tchain_id must not be empty\n"
 	}
+	if p.ValidatorSetCheck != "" && !regexp.MustCompile(`^(off|warn|refuse)$`).MatchString(p.ValidatorSetCheck) {
+		errs += "\tvalidator_set_check must be one of the following: off, warn, refuse\n"
+	}
+	if p.RequiresProposalApproval() {
+		if d, err := time.ParseDuration(p.ProposalApprovalTimeout); err != nil || d <= 0 {
+			errs += "\tproposal_approval_timeout must be a positive duration, e.g. \"30s\"\n"
+		}
+	}
+	if p.Transport != "" && !regexp.MustCompile(`^(socket|mtls|grpc)$`).MatchString(p.Transport) {
+		errs += "\ttransport must be one of the following: socket, mtls, grpc\n"
+	}
+	if p.UsesMTLS() && (p.TLSCertFile == "" || p.TLSKeyFile == "" || p.TLSCAFile == "") {
+		errs += "\tthe mtls transport requires tls_cert_file, tls_key_file and tls_ca_file to be set\n"
+	}
+	if p.UsesGRPC() {
+		if err := validateAddress(p.GRPCListenAddress, "grpc_laddr"); err != nil {
+			errs += fmt.Sprintf("\t%v\n", err.Error())
+		}
+		if (p.GRPCCertFile == "") != (p.GRPCKeyFile == "") {
+			errs += "\tgrpc_cert_file and grpc_key_file must either both be set or both be empty\n"
+		}
+		if p.GRPCClientCAFile != "" && p.GRPCCertFile == "" {
+			errs += "\tgrpc_client_ca_file requires grpc_cert_file and grpc_key_file to be set\n"
+		}
+	}
 	if errs != "" {
 		return errors.New(errs)
 	}
@@ -137,23 +453,53 @@ func (p PrivValidator) validate() error {
 	return nil
 }
 
-// Config defines the structure of SignCTRL's configuration file.
-type Config struct {
-	// Base defines the [base] section of the configuration file.
-	Base Base `mapstructure:"base"`
+// Slashing defines the configuration for querying the Cosmos SDK's slashing and
+// staking modules about the validator.
+type Slashing struct {
+	// LCDListenAddress is the TCP socket address of the Cosmos SDK REST server. The
+	// queries are disabled if it is empty.
+	LCDListenAddress string `mapstructure:"lcd_laddr"`
 
-	// Privval defines the [privval] section of the configuration file.
-	Privval PrivValidator `mapstructure:"privval"`
+	// ValconsAddress is the validator's consensus address, e.g. cosmosvalcons1...
+	ValconsAddress string `mapstructure:"valcons_address"`
+
+	// ValoperAddress is the validator's operator address, e.g. cosmosvaloper1... The
+	// jail status is not queried from the staking module if it is empty.
+	ValoperAddress string `mapstructure:"valoper_address"`
+
+	// QueryInterval is the interval in which the modules are queried.
+	QueryInterval string `mapstructure:"query_interval"`
+
+	// PauseWhenJailed determines whether signing is paused while the validator is
+	// jailed.
+	PauseWhenJailed bool `mapstructure:"pause_when_jailed"`
+
+	// ResumeAfterUnjail determines whether paused signing is resumed once the
+	// validator is no longer jailed. Otherwise, signing stays paused until SignCTRL is
+	// restarted.
+	ResumeAfterUnjail bool `mapstructure:"resume_after_unjail"`
 }
 
-// validate validates the configuration.
-func (c Config) validate() error {
+// Enabled returns true if the slashing and staking modules are queried.
+func (s Slashing) Enabled() bool {
+	return s.LCDListenAddress != ""
+}
+
+// validate validates the configuration's slashing section.
+func (s Slashing) validate() error {
+	if !s.Enabled() {
+		return nil
+	}
+
 	var errs string
-	if err := c.Base.validate(); err != nil {
-		errs += err.Error()
+	if err := validateAddress(s.LCDListenAddress, "lcd_laddr"); err != nil {
+		errs += fmt.Sprintf("\t%v\n", err.Error())
 	}
-	if err := c.Privval.validate(); err != nil {
-		errs += err.Error()
+	if s.ValconsAddress == "" {
+		errs += "\tvalcons_address must not be empty if lcd_laddr is set\n"
+	}
+	if d, err := time.ParseDuration(s.QueryInterval); err != nil || d <= 0 {
+		errs += "\tquery_interval must be a positive duration, e.g. \"1m\"\n"
 	}
 	if errs != "" {
 		return errors.New(errs)
@@ -162,68 +508,1403 @@ func (c Config) validate() error {
 	return nil
 }
 
-// Dir returns the configuration directory in use. It is always set in the following
-// order:
-//
-// 1) Custom environment variable $SIGNCTRL_CONFIG_DIR
-// 2) $HOME/.signctrl
-// 3) Current working directory
-//
-// If one is not set, the directory falls back to the next one.
-func Dir() string {
-	if dir := os.Getenv("SIGNCTRL_CONFIG_DIR"); dir != "" {
-		return dir
-	} else if dir, err := os.UserHomeDir(); err == nil {
-		return dir + "/.signctrl"
+// GetQueryInterval returns the parsed QueryInterval.
+func (s Slashing) GetQueryInterval() time.Duration {
+	d, _ := time.ParseDuration(s.QueryInterval)
+	return d
+}
+
+// Upgrades defines the configuration of the chain's coordinated upgrade and halt
+// heights. All validators miss blocks around these heights, so they don't count as
+// missed and don't lead to promotions.
+type Upgrades struct {
+	// Heights are the known upgrade and halt heights.
+	Heights []int64 `mapstructure:"heights"`
+
+	// Window is the number of blocks before and after an upgrade height in which
+	// missed blocks aren't counted.
+	Window int64 `mapstructure:"window"`
+
+	// LCDListenAddress is the TCP socket address of the Cosmos SDK REST server the
+	// upgrade module's current plan is queried from. The queries are disabled if it is
+	// empty.
+	LCDListenAddress string `mapstructure:"lcd_laddr"`
+
+	// QueryInterval is the interval in which the upgrade module is queried.
+	QueryInterval string `mapstructure:"query_interval"`
+}
+
+// QueriesPlan returns true if the upgrade module's current plan is queried.
+func (u Upgrades) QueriesPlan() bool {
+	return u.LCDListenAddress != ""
+}
+
+// validate validates the configuration's upgrades section.
+func (u Upgrades) validate() error {
+	var errs string
+	for _, h := range u.Heights {
+		if h < 1 {
+			errs += "\theights must be 1 or higher\n"
+			break
+		}
+	}
+	if u.Window < 0 {
+		errs += "\twindow must be 0 or higher\n"
+	}
+	if u.QueriesPlan() {
+		if err := validateAddress(u.LCDListenAddress, "lcd_laddr"); err != nil {
+			errs += fmt.Sprintf("\t%v\n", err.Error())
+		}
+		if d, err := time.ParseDuration(u.QueryInterval); err != nil || d <= 0 {
+			errs += "\tquery_interval must be a positive duration, e.g. \"10m\"\n"
+		}
+	}
+	if errs != "" {
+		return errors.New(errs)
 	}
 
-	return "."
+	return nil
 }
 
-// FilePath returns the absolute path to the configuration file.
-func FilePath(cfgDir string) string {
-	return filepath.Join(cfgDir, File)
+// GetQueryInterval returns the parsed QueryInterval.
+func (u Upgrades) GetQueryInterval() time.Duration {
+	d, _ := time.ParseDuration(u.QueryInterval)
+	return d
 }
 
-// GetRetryDialTime converts the string representation of RetryDialAfter into
-// time.Duration and returns it.
-func GetRetryDialTime(timeString string) time.Duration {
-	t := regexp.MustCompile(`0|[1-9][0-9]*`).FindString(timeString)
-	tConv, _ := strconv.Atoi(t)
+// Maintenance defines the configuration of the recurring maintenance windows, in
+// which missed blocks aren't counted and don't lead to promotions.
+type Maintenance struct {
+	// Windows are the maintenance windows, each a cron-like schedule followed by a
+	// duration, e.g. "0 3 * * 0 2h".
+	Windows []string `mapstructure:"windows"`
+}
 
-	tUnit := regexp.MustCompile(`s|m|h`).FindString(timeString)
-	switch tUnit {
-	case "s":
-		return time.Duration(tConv) * time.Second
-	case "m":
-		return time.Duration(tConv) * time.Minute
-	case "h":
-		return time.Duration(tConv) * time.Hour
+// validate validates the configuration's maintenance section.
+func (m Maintenance) validate() error {
+	if _, err := m.GetWindows(); err != nil {
+		return fmt.Errorf("\t%v\n", err)
 	}
 
-	return 0
+	return nil
 }
 
-// logLevelsToRegExp returns a regular expression for the validation of log levels.
-func logLevelsToRegExp(levels *[]logutils.LogLevel) string {
-	regExp := ""
-	maxLevels := len(*levels) - 1
-	for i, lvl := range *levels {
-		regExp += string(lvl)
-		if i < maxLevels {
-			regExp += "|"
+// GetWindows returns the parsed Windows.
+func (m Maintenance) GetWindows() ([]maintenance.Window, error) {
+	return maintenance.ParseWindows(m.Windows)
+}
+
+// Admin defines the configuration of the authentication of the admin API.
+type Admin struct {
+	// TokenFile is the path to the file holding the accepted bearer tokens, one per
+	// line. Admin requests don't need a token if it is empty.
+	TokenFile string `mapstructure:"token_file"`
+
+	// TOTPSecretFile is the path to the file holding the base32-encoded TOTP secret.
+	// If set, destructive admin requests require a TOTP code.
+	TOTPSecretFile string `mapstructure:"totp_secret_file"`
+}
+
+// RequiresToken returns true if admin requests must carry a bearer token.
+func (a Admin) RequiresToken() bool {
+	return a.TokenFile != ""
+}
+
+// RequiresTOTP returns true if destructive admin requests must carry a TOTP code.
+func (a Admin) RequiresTOTP() bool {
+	return a.TOTPSecretFile != ""
+}
+
+// validate validates the configuration's admin section.
+func (a Admin) validate() error {
+	if a.RequiresTOTP() && !a.RequiresToken() {
+		return errors.New("\ttotp_secret_file requires token_file to be set\n")
+	}
+
+	return nil
+}
+
+// Sandbox defines the configuration of the sandbox SignCTRL restricts itself to
+// once it is initialized.
+type Sandbox struct {
+	// Enabled determines whether SignCTRL restricts itself to its configuration and
+	// state directories and the configured network endpoints, and is denied
+	// dangerous system calls like executing other programs. Only supported on Linux.
+	Enabled bool `mapstructure:"enabled"`
+
+	// ReadPaths are further files and directories SignCTRL may read.
+	ReadPaths []string `mapstructure:"read_paths"`
+
+	// WritePaths are further files and directories SignCTRL may read and write.
+	WritePaths []string `mapstructure:"write_paths"`
+
+	// ConnectPorts are further TCP ports SignCTRL may connect to, e.g. the ports of
+	// signer backends that are swapped to at runtime.
+	ConnectPorts []int `mapstructure:"connect_ports"`
+}
+
+// validate validates the configuration's sandbox section.
+func (s Sandbox) validate() error {
+	var errs string
+	for _, p := range append(append([]string(nil), s.ReadPaths...), s.WritePaths...) {
+		if !filepath.IsAbs(p) {
+			errs += fmt.Sprintf("	sandbox path %v must be absolute\n", p)
 		}
 	}
+	for _, port := range s.ConnectPorts {
+		if port < 1 || port > 65535 {
+			errs += fmt.Sprintf("	connect_ports must be between 1 and 65535, got %v\n", port)
+		}
+	}
+	if errs != "" {
+		return errors.New(errs)
+	}
 
-	return regExp
+	return nil
 }
 
-// Load loads and validates the configuration file.
-func Load() (c Config, err error) {
-	if err = viper.ReadInConfig(); err != nil {
-		return Config{}, err
+// Backup defines the configuration of the encrypted off-site backups of the
+// watermarks and the rank, which never contain keys.
+type Backup struct {
+	// Interval is the interval in which the watermarks are checked and backed up if
+	// they changed. Backups are disabled if it is empty.
+	Interval string `mapstructure:"interval"`
+
+	// Recipient is the age public key the backups are encrypted to, e.g. "age1...".
+	Recipient string `mapstructure:"recipient"`
+
+	// Endpoint is the URL of the S3-compatible object storage, e.g.
+	// https://s3.eu-central-1.amazonaws.com, or https://storage.googleapis.com for
+	// GCS's XML API with HMAC keys.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Region is the region of the bucket, e.g. eu-central-1 or auto for GCS.
+	Region string `mapstructure:"region"`
+
+	// Bucket is the name of the bucket the backups are uploaded to.
+	Bucket string `mapstructure:"bucket"`
+
+	// Object is the key of the object the latest backup is stored in.
+	Object string `mapstructure:"object"`
+
+	// CredentialsFile is the path to the file holding the access key ID and the
+	// secret access key of the object storage on two lines.
+	CredentialsFile string `mapstructure:"credentials_file"`
+}
+
+// Enabled returns true if the watermarks and the rank are backed up.
+func (b Backup) Enabled() bool {
+	return b.Interval != ""
+}
+
+// validate validates the configuration's backup section.
+func (b Backup) validate() error {
+	if !b.Enabled() {
+		return nil
 	}
-	if err = viper.Unmarshal(&c); err != nil {
+
+	var errs string
+	if d, err := time.ParseDuration(b.Interval); err != nil || d <= 0 {
+		errs += "\tinterval must be a positive duration, e.g. \"1m\"\n"
+	}
+	if !strings.HasPrefix(b.Recipient, "age1") {
+		errs += "\trecipient must be an age public key starting with age1\n"
+	}
+	if u, err := url.Parse(b.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs += "\tendpoint must be an http:// or https:// URL\n"
+	}
+	if b.Region == "" {
+		errs += "\tregion must not be empty\n"
+	}
+	if b.Bucket == "" {
+		errs += "\tbucket must not be empty\n"
+	}
+	if b.Object == "" {
+		errs += "\tobject must not be empty\n"
+	}
+	if b.CredentialsFile == "" {
+		errs += "\tcredentials_file must not be empty\n"
+	}
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// GetInterval returns the parsed Interval.
+func (b Backup) GetInterval() time.Duration {
+	d, _ := time.ParseDuration(b.Interval)
+	return d
+}
+
+// LoadCredentials loads the access key ID and the secret access key from a
+// credentials_file, which holds them on two lines.
+func LoadCredentials(file string) (accessKeyID, secretAccessKey string, err error) {
+	bz, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", "", fmt.Errorf("couldn't read credentials: %w", err)
+	}
+	lines := strings.Fields(string(bz))
+	if len(lines) != 2 {
+		return "", "", fmt.Errorf("%v must hold the access key ID and the secret access key on two lines", file)
+	}
+
+	return lines[0], lines[1], nil
+}
+
+// Integrity defines the configuration of the verification of the SignCTRL binary
+// against its detached release signature on startup.
+type Integrity struct {
+	// SignatureFile is the path to the detached signature of the SignCTRL binary.
+	// The verification is disabled if it is empty.
+	SignatureFile string `mapstructure:"signature_file"`
+
+	// SigningKey is the base64-encoded ed25519 public key the signature is verified
+	// with. The key embedded at build time is used if it is empty.
+	SigningKey string `mapstructure:"signing_key"`
+
+	// Enforce determines whether SignCTRL refuses to start if the binary doesn't match
+	// its signature, instead of only logging an error.
+	Enforce bool `mapstructure:"enforce"`
+}
+
+// Enabled returns true if the binary is verified on startup.
+func (i Integrity) Enabled() bool {
+	return i.SignatureFile != ""
+}
+
+// validate validates the configuration's integrity section.
+func (i Integrity) validate() error {
+	var errs string
+	if i.Enforce && !i.Enabled() {
+		errs += "	enforce requires signature_file to be set\n"
+	}
+	if i.SigningKey != "" {
+		if bz, err := base64.StdEncoding.DecodeString(i.SigningKey); err != nil || len(bz) != ed25519.PublicKeySize {
+			errs += "	signing_key must be a base64-encoded ed25519 public key\n"
+		}
+	}
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// Watchdog defines the configuration of the watchdog detecting stalled goroutines.
+type Watchdog struct {
+	// StallTimeout is the time a goroutine may take longer than expected to report
+	// that it's alive before it's considered stalled. The watchdog is disabled if it
+	// is empty.
+	StallTimeout string `mapstructure:"stall_timeout"`
+
+	// Action is what happens to a stalled goroutine. Can be restart, in which case
+	// goroutines that can be restarted are restarted once before the node is marked
+	// unhealthy, or unhealthy, in which case the node is marked unhealthy right away.
+	// Defaults to restart.
+	Action string `mapstructure:"action"`
+}
+
+// Enabled returns true if the watchdog is enabled.
+func (w Watchdog) Enabled() bool {
+	return w.StallTimeout != ""
+}
+
+// Restarts returns true if stalled goroutines are restarted.
+func (w Watchdog) Restarts() bool {
+	return w.Action == "" || w.Action == "restart"
+}
+
+// validate validates the configuration's watchdog section.
+func (w Watchdog) validate() error {
+	var errs string
+	if w.Enabled() {
+		if d, err := time.ParseDuration(w.StallTimeout); err != nil || d <= 0 {
+			errs += "\tstall_timeout must be a positive duration, e.g. \"30s\"\n"
+		}
+	}
+	switch w.Action {
+	case "", "restart", "unhealthy":
+	default:
+		errs += "\taction must be either restart or unhealthy\n"
+	}
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// GetStallTimeout returns the parsed StallTimeout.
+func (w Watchdog) GetStallTimeout() time.Duration {
+	d, _ := time.ParseDuration(w.StallTimeout)
+	return d
+}
+
+// History defines the configuration of the historical signing statistics.
+type History struct {
+	// Retention is the time the signing outcomes are kept for, measured by the block
+	// timestamps. The history is disabled if it is empty.
+	Retention string `mapstructure:"retention"`
+}
+
+// Enabled returns true if the signing outcomes are kept.
+func (h History) Enabled() bool {
+	return h.Retention != ""
+}
+
+// validate validates the configuration's history section.
+func (h History) validate() error {
+	if h.Enabled() {
+		if d, err := time.ParseDuration(h.Retention); err != nil || d <= 0 {
+			return errors.New("\tretention must be a positive duration, e.g. \"720h\"\n")
+		}
+	}
+
+	return nil
+}
+
+// GetRetention returns the parsed Retention.
+func (h History) GetRetention() time.Duration {
+	d, _ := time.ParseDuration(h.Retention)
+	return d
+}
+
+// Clock defines the configuration of the clock sanity check. Several coordination
+// features depend on the clocks of the validators in the set being roughly in sync.
+type Clock struct {
+	// NTPServer is the NTP server the local clock is compared with, e.g.
+	// pool.ntp.org. The check is disabled if it is empty.
+	NTPServer string `mapstructure:"ntp_server"`
+
+	// CheckInterval is the interval in which the clock is checked after startup.
+	CheckInterval string `mapstructure:"check_interval"`
+
+	// WarnOffset is the clock offset above which a warning is logged.
+	WarnOffset string `mapstructure:"warn_offset"`
+
+	// MaxOffset is the clock offset above which the node refuses to be promoted and
+	// to resume signing after being unjailed.
+	MaxOffset string `mapstructure:"max_offset"`
+}
+
+// Enabled returns true if the clock is checked.
+func (c Clock) Enabled() bool {
+	return c.NTPServer != ""
+}
+
+// validate validates the configuration's clock section.
+func (c Clock) validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	var errs string
+	for _, d := range []struct{ name, value string }{
+		{"check_interval", c.CheckInterval},
+		{"warn_offset", c.WarnOffset},
+		{"max_offset", c.MaxOffset},
+	} {
+		if parsed, err := time.ParseDuration(d.value); err != nil || parsed <= 0 {
+			errs += fmt.Sprintf("\t%v must be a positive duration, e.g. \"1s\"\n", d.name)
+		}
+	}
+	if errs == "" && c.GetWarnOffset() > c.GetMaxOffset() {
+		errs += "\twarn_offset must not be higher than max_offset\n"
+	}
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// GetCheckInterval returns the parsed CheckInterval.
+func (c Clock) GetCheckInterval() time.Duration {
+	d, _ := time.ParseDuration(c.CheckInterval)
+	return d
+}
+
+// GetWarnOffset returns the parsed WarnOffset.
+func (c Clock) GetWarnOffset() time.Duration {
+	d, _ := time.ParseDuration(c.WarnOffset)
+	return d
+}
+
+// GetMaxOffset returns the parsed MaxOffset.
+func (c Clock) GetMaxOffset() time.Duration {
+	d, _ := time.ParseDuration(c.MaxOffset)
+	return d
+}
+
+// P2P defines the configuration of the heartbeats the nodes in the set send each
+// other to exchange their liveness, rank and the height they last signed at.
+type P2P struct {
+	// ListenAddress is the TCP socket address heartbeats are received on. Heartbeats
+	// are disabled if it is empty.
+	ListenAddress string `mapstructure:"laddr"`
+
+	// Peers are the TCP socket addresses of the other nodes in the set.
+	Peers []string `mapstructure:"peers"`
+
+	// Name is the node's name in its heartbeats. Defaults to the host name.
+	Name string `mapstructure:"name"`
+
+	// SecretFile is the path to the file holding the hex-encoded secret shared by the
+	// nodes in the set, which the heartbeats are authenticated with.
+	SecretFile string `mapstructure:"secret_file"`
+
+	// HeartbeatInterval is the interval in which heartbeats are sent.
+	HeartbeatInterval string `mapstructure:"heartbeat_interval"`
+
+	// PeerTimeout is the time without a heartbeat after which a peer isn't considered
+	// live anymore.
+	PeerTimeout string `mapstructure:"peer_timeout"`
+
+	// ElectionTimeout is the minimum time without a heartbeat of the elected leader
+	// after which the nodes elect a new one, if the coordination is raft.
+	ElectionTimeout string `mapstructure:"election_timeout"`
+}
+
+// Enabled returns true if heartbeats are exchanged with the other nodes in the set.
+func (p P2P) Enabled() bool {
+	return p.ListenAddress != ""
+}
+
+// validate validates the configuration's p2p section.
+func (p P2P) validate() error {
+	if !p.Enabled() {
+		return nil
+	}
+
+	var errs string
+	if !strings.HasPrefix(p.ListenAddress, "tcp://") {
+		errs += "\tp2p laddr must be a TCP address\n"
+	} else if err := validateAddress(p.ListenAddress, "p2p laddr"); err != nil {
+		errs += fmt.Sprintf("\t%v\n", err)
+	}
+	if len(p.Peers) == 0 {
+		errs += "\tpeers must list the other nodes in the set\n"
+	}
+	for _, peer := range p.Peers {
+		if !strings.HasPrefix(peer, "tcp://") {
+			errs += fmt.Sprintf("\tpeer %v must be a TCP address\n", peer)
+		} else if err := validateAddress(peer, "peer "+peer); err != nil {
+			errs += fmt.Sprintf("\t%v\n", err)
+		}
+	}
+	if p.SecretFile == "" {
+		errs += "\tsecret_file must be set\n"
+	}
+	for _, d := range []struct{ name, value string }{
+		{"heartbeat_interval", p.HeartbeatInterval},
+		{"peer_timeout", p.PeerTimeout},
+	} {
+		if parsed, err := time.ParseDuration(d.value); err != nil || parsed <= 0 {
+			errs += fmt.Sprintf("\t%v must be a positive duration, e.g. \"1s\"\n", d.name)
+		}
+	}
+	if errs == "" && p.GetPeerTimeout() <= p.GetHeartbeatInterval() {
+		errs += "\tpeer_timeout must be longer than heartbeat_interval\n"
+	}
+	if p.ElectionTimeout != "" {
+		if d, err := time.ParseDuration(p.ElectionTimeout); err != nil || d <= 0 {
+			errs += "\telection_timeout must be a positive duration, e.g. \"3s\"\n"
+		} else if errs == "" && d < 3*p.GetHeartbeatInterval() {
+			errs += "\telection_timeout must be at least 3 times heartbeat_interval\n"
+		}
+	}
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// GetName returns the node's name in its heartbeats, falling back to the host name.
+func (p P2P) GetName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	name, _ := os.Hostname()
+
+	return name
+}
+
+// GetHeartbeatInterval returns the parsed HeartbeatInterval.
+func (p P2P) GetHeartbeatInterval() time.Duration {
+	d, _ := time.ParseDuration(p.HeartbeatInterval)
+	return d
+}
+
+// GetPeerTimeout returns the parsed PeerTimeout.
+func (p P2P) GetPeerTimeout() time.Duration {
+	d, _ := time.ParseDuration(p.PeerTimeout)
+	return d
+}
+
+// GetElectionTimeout returns the parsed ElectionTimeout.
+func (p P2P) GetElectionTimeout() time.Duration {
+	d, _ := time.ParseDuration(p.ElectionTimeout)
+	return d
+}
+
+// DefaultAlertEvents are the event types alerts are sent for if none are configured.
+var DefaultAlertEvents = []string{"promoted", "demoted", "missed_too_many", "must_shutdown", "disconnected"}
+
+// DefaultAlertSeverities are the severities of the alerts by event type, unless they
+// are overridden in the configuration. Alerts for any other event type are of
+// severity info.
+var DefaultAlertSeverities = map[string]string{
+	"promoted":        alerts.SeverityWarning,
+	"demoted":         alerts.SeverityWarning,
+	"missed_too_many": alerts.SeverityError,
+	"must_shutdown":   alerts.SeverityCritical,
+	"crashed":         alerts.SeverityCritical,
+	"disconnected":    alerts.SeverityCritical,
+}
+
+// Alerts defines the configuration of the alerts pushed to webhooks, PagerDuty and
+// Slack.
+type Alerts struct {
+	// Webhooks are the URLs the alerts are POSTed to as JSON.
+	Webhooks []string `mapstructure:"webhooks"`
+
+	// PagerDutyRoutingKey is the integration key of the PagerDuty service incidents
+	// are triggered for via the Events API v2.
+	PagerDutyRoutingKey string `mapstructure:"pagerduty_routing_key"`
+
+	// SlackWebhook is the URL of the Slack incoming webhook the alerts are posted to.
+	SlackWebhook string `mapstructure:"slack_webhook"`
+
+	// Severities overrides the severities of the alerts by event type.
+	Severities map[string]string `mapstructure:"severities"`
+
+	// Events are the types of the watchtower events alerts are sent for. Defaults to
+	// DefaultAlertEvents.
+	Events []string `mapstructure:"events"`
+
+	// DisconnectTimeout is the time without a connection to the validator after which
+	// it is reported as disconnected. The connection isn't monitored if it is empty.
+	DisconnectTimeout string `mapstructure:"disconnect_timeout"`
+}
+
+// Enabled returns true if alerts are sent, i.e. if there is a webhook, a PagerDuty
+// routing key or a Slack webhook.
+func (a Alerts) Enabled() bool {
+	return len(a.Webhooks) > 0 || a.PagerDutyRoutingKey != "" || a.SlackWebhook != ""
+}
+
+// validate validates the configuration's alerts section.
+func (a Alerts) validate() error {
+	var errs string
+	for _, webhook := range a.Webhooks {
+		if u, err := url.Parse(webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs += fmt.Sprintf("	webhook %v must be an http or https URL\n", webhook)
+		}
+	}
+	if a.SlackWebhook != "" {
+		if u, err := url.Parse(a.SlackWebhook); err != nil || u.Scheme != "https" || u.Host == "" {
+			errs += "	slack_webhook must be an https URL\n"
+		}
+	}
+	for _, e := range a.Events {
+		if !watchtower.EventType(e).Known() {
+			errs += fmt.Sprintf("	unknown alert event %v\n", e)
+		}
+	}
+	for e, severity := range a.Severities {
+		if !watchtower.EventType(e).Known() {
+			errs += fmt.Sprintf("	unknown alert event %v in severities\n", e)
+		}
+		if !alerts.ValidSeverity(severity) {
+			errs += fmt.Sprintf("	severity %v of %v must be critical, error, warning or info\n", severity, e)
+		}
+	}
+	if a.DisconnectTimeout != "" {
+		if d, err := time.ParseDuration(a.DisconnectTimeout); err != nil || d <= 0 {
+			errs += "	disconnect_timeout must be a positive duration, e.g. \"1m\"\n"
+		}
+	}
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// GetEvents returns the configured Events, or DefaultAlertEvents if there are none.
+func (a Alerts) GetEvents() []string {
+	if len(a.Events) == 0 {
+		return DefaultAlertEvents
+	}
+
+	return a.Events
+}
+
+// GetSeverity returns the severity of the alerts for the given event type.
+func (a Alerts) GetSeverity(event string) string {
+	if severity, ok := a.Severities[event]; ok {
+		return severity
+	}
+	if severity, ok := DefaultAlertSeverities[event]; ok {
+		return severity
+	}
+
+	return alerts.SeverityInfo
+}
+
+// GetDisconnectTimeout returns the parsed DisconnectTimeout.
+func (a Alerts) GetDisconnectTimeout() time.Duration {
+	d, _ := time.ParseDuration(a.DisconnectTimeout)
+	return d
+}
+
+// Lock backends.
+const (
+	LockBackendEtcd   = "etcd"
+	LockBackendConsul = "consul"
+)
+
+// Lock defines the configuration of the lock on the signing authority held in etcd
+// or Consul.
+type Lock struct {
+	// Backend is the store the lock is held in, either LockBackendEtcd or
+	// LockBackendConsul. No lock is used if it is empty.
+	Backend string `mapstructure:"backend"`
+
+	// Endpoints are the HTTP endpoints of the store.
+	Endpoints []string `mapstructure:"endpoints"`
+
+	// KeyPrefix is the prefix of the key the lock is held at, which the chain ID is
+	// appended to.
+	KeyPrefix string `mapstructure:"key_prefix"`
+
+	// TTL is the TTL of the lease the lock is held with.
+	TTL string `mapstructure:"ttl"`
+}
+
+// Enabled returns true if the signing lock is used.
+func (l Lock) Enabled() bool {
+	return l.Backend != ""
+}
+
+// validate validates the configuration's lock section.
+func (l Lock) validate() error {
+	if !l.Enabled() {
+		return nil
+	}
+
+	var errs string
+	if l.Backend != LockBackendEtcd && l.Backend != LockBackendConsul {
+		errs += fmt.Sprintf("\tbackend must be either %v or %v\n", LockBackendEtcd, LockBackendConsul)
+	}
+	if len(l.Endpoints) == 0 {
+		errs += "\tendpoints must not be empty if a lock backend is set\n"
+	}
+	for _, endpoint := range l.Endpoints {
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs += fmt.Sprintf("\tlock endpoint %v must be an http or https URL\n", endpoint)
+		}
+	}
+	if l.KeyPrefix == "" {
+		errs += "\tkey_prefix must not be empty\n"
+	}
+	errs += validateDuration("ttl", l.TTL, 10*time.Second, 5*time.Minute)
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// GetKey returns the key the lock for the given chain ID is held at.
+func (l Lock) GetKey(chainID string) string {
+	return strings.TrimSuffix(l.KeyPrefix, "/") + "/" + chainID
+}
+
+// GetTTL returns the parsed TTL.
+func (l Lock) GetTTL() time.Duration {
+	d, _ := time.ParseDuration(l.TTL)
+	return d
+}
+
+// KMS defines the configuration of signing with an Ed25519 key held in AWS KMS instead
+// of the priv_validator_key.json.
+type KMS struct {
+	// KeyID is the ID or ARN of the key. The priv_validator_key.json is used if it is
+	// empty.
+	KeyID string `mapstructure:"key_id"`
+
+	// Region is the region of the key, e.g. eu-central-1.
+	Region string `mapstructure:"region"`
+
+	// Endpoint is the URL of the KMS API, e.g. of a VPC endpoint. Defaults to the
+	// region's public endpoint if it is empty.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// CredentialsFile is the path to the file holding the access key ID and the
+	// secret access key on two lines. If it is empty, the credentials are taken from
+	// the AWS SDK's default credential chain, i.e. the environment, the shared
+	// credentials file, the ECS container credentials or the EC2 instance profile.
+	CredentialsFile string `mapstructure:"credentials_file"`
+}
+
+// Enabled returns true if the validator's key is held in AWS KMS.
+func (k KMS) Enabled() bool {
+	return k.KeyID != ""
+}
+
+// validate validates the configuration's kms section.
+func (k KMS) validate() error {
+	if !k.Enabled() {
+		return nil
+	}
+
+	var errs string
+	if k.Region == "" {
+		errs += "\tregion must not be empty if a KMS key_id is set\n"
+	}
+	if k.Endpoint != "" {
+		if u, err := url.Parse(k.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs += "\tKMS endpoint must be an http:// or https:// URL\n"
+		}
+	}
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// GetEndpoint returns the Endpoint, or the public endpoint of the Region if it is
+// empty.
+func (k KMS) GetEndpoint() string {
+	if k.Endpoint == "" {
+		return fmt.Sprintf("https://kms.%v.amazonaws.com", k.Region)
+	}
+
+	return k.Endpoint
+}
+
+// LightClient defines the configuration for verifying the blocks used for miss
+// detection with Tendermint's light client, so that a compromised or buggy RPC server
+// can't trick a backup into promoting.
+type LightClient struct {
+	// TrustHeight is the height of the trusted header the verification starts from.
+	// The verification is disabled if it is 0.
+	TrustHeight int64 `mapstructure:"trust_height"`
+
+	// TrustHash is the hex-encoded hash of the trusted header at TrustHeight.
+	TrustHash string `mapstructure:"trust_hash"`
+
+	// TrustPeriod is the time a verified header is trusted. It should be
+	// significantly shorter than the chain's unbonding period.
+	TrustPeriod string `mapstructure:"trust_period"`
+
+	// Witnesses are the TCP socket addresses of further RPC servers that the headers
+	// are cross-checked with in order to detect forks.
+	Witnesses []string `mapstructure:"witnesses"`
+}
+
+// Enabled returns true if the blocks are verified with the light client.
+func (l LightClient) Enabled() bool {
+	return l.TrustHeight != 0
+}
+
+// validate validates the configuration's light_client section.
+func (l LightClient) validate() error {
+	if !l.Enabled() {
+		return nil
+	}
+
+	var errs string
+	if l.TrustHeight < 0 {
+		errs += "\ttrust_height must be 0 or higher\n"
+	}
+	if !regexp.MustCompile(`^[0-9a-fA-F]{64}$`).MatchString(l.TrustHash) {
+		errs += "\ttrust_hash must be a hex-encoded 32 byte hash\n"
+	}
+	if d, err := time.ParseDuration(l.TrustPeriod); err != nil || d <= 0 {
+		errs += "\ttrust_period must be a positive duration, e.g. \"168h\"\n"
+	}
+	for _, w := range l.Witnesses {
+		if err := validateAddress(w, "witness "+w); err != nil {
+			errs += fmt.Sprintf("\t%v\n", err.Error())
+		}
+	}
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// GetTrustPeriod returns the parsed TrustPeriod.
+func (l LightClient) GetTrustPeriod() time.Duration {
+	d, _ := time.ParseDuration(l.TrustPeriod)
+	return d
+}
+
+// RPC defines the configuration of the client that queries the validator's RPC server
+// and the LCD.
+type RPC struct {
+	// Timeout is the timeout of a single request.
+	Timeout string `mapstructure:"timeout"`
+
+	// Retries is the number of times a failed request is retried.
+	Retries int `mapstructure:"retries"`
+
+	// RetryBackoff is the time waited before the first retry. It is doubled for every
+	// further retry.
+	RetryBackoff string `mapstructure:"retry_backoff"`
+
+	// CircuitThreshold is the number of failed requests in a row after which the
+	// circuit to a host is opened and further requests fail immediately. Circuit
+	// breaking is disabled if it is 0.
+	CircuitThreshold int `mapstructure:"circuit_threshold"`
+
+	// CircuitCooldown is the time the circuit to a host stays open.
+	CircuitCooldown string `mapstructure:"circuit_cooldown"`
+
+	// Endpoints are the addresses of further RPC servers of the same chain, e.g. of
+	// sentry nodes. If set, the validator's RPC server and the endpoints are health
+	// checked and queries are sent to the best of them.
+	Endpoints []string `mapstructure:"endpoints"`
+
+	// HealthCheckInterval is the interval in which the RPC servers are health
+	// checked if there are further endpoints.
+	HealthCheckInterval string `mapstructure:"health_check_interval"`
+
+	// MaxLag is the number of blocks an RPC server may lag behind the highest one
+	// before no more queries are sent to it.
+	MaxLag int64 `mapstructure:"max_lag"`
+
+	// Quorum is the number of RPC servers, out of the validator's RPC server and the
+	// endpoints, whose commits must lack the validator's signature before a block is
+	// counted as missed. The confirmation is disabled if it is 0.
+	Quorum int `mapstructure:"quorum"`
+
+	// MaxParallelQueries is the maximum number of RPC servers queried at the same time
+	// when a missed block is confirmed. If it is 0, it is twice the number of CPUs
+	// SignCTRL may use, but at most DefaultMaxParallelQueries.
+	MaxParallelQueries int `mapstructure:"max_parallel_queries"`
+
+	// StaleTimeout is the time the validator may neither send sign requests nor
+	// advance the height of its RPC server while the endpoints see the network
+	// advance, before it is reported as stale. The detection is disabled if it is
+	// empty or there are no endpoints.
+	StaleTimeout string `mapstructure:"stale_timeout"`
+
+	// WatchInterval is the interval in which the latest block of the validator's RPC
+	// server is polled, so that the commitsigs are checked even while the validator
+	// sends no sign requests. The commit watcher is disabled if it is empty.
+	WatchInterval string `mapstructure:"watch_interval"`
+}
+
+// validate validates the configuration's rpc section. Durations may be left empty to
+// use the defaults.
+func (r RPC) validate() error {
+	var errs string
+	for _, d := range []struct{ name, value string }{
+		{"timeout", r.Timeout},
+		{"retry_backoff", r.RetryBackoff},
+		{"circuit_cooldown", r.CircuitCooldown},
+		{"health_check_interval", r.HealthCheckInterval},
+		{"stale_timeout", r.StaleTimeout},
+		{"watch_interval", r.WatchInterval},
+	} {
+		if d.value == "" {
+			continue
+		}
+		if parsed, err := time.ParseDuration(d.value); err != nil || parsed <= 0 {
+			errs += fmt.Sprintf("\t%v must be a positive duration, e.g. \"5s\"\n", d.name)
+		}
+	}
+	if r.Retries < 0 {
+		errs += "\tretries must be 0 or higher\n"
+	}
+	if r.CircuitThreshold < 0 {
+		errs += "\tcircuit_threshold must be 0 or higher\n"
+	}
+	for i, endpoint := range r.Endpoints {
+		if err := validateAddress(endpoint, fmt.Sprintf("endpoints[%v]", i)); err != nil {
+			errs += fmt.Sprintf("\t%v\n", err.Error())
+		}
+	}
+	if r.MaxLag < 0 {
+		errs += "\tmax_lag must be 0 or higher\n"
+	}
+	if r.Quorum < 0 || r.Quorum > len(r.Endpoints)+1 {
+		errs += fmt.Sprintf("\tquorum must be between 0 and the number of RPC servers (%v)\n", len(r.Endpoints)+1)
+	}
+	if r.MaxParallelQueries < 0 {
+		errs += "\tmax_parallel_queries must be 0 or higher\n"
+	}
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// GetTimeout returns the parsed Timeout, or 0 if it is empty.
+func (r RPC) GetTimeout() time.Duration {
+	d, _ := time.ParseDuration(r.Timeout)
+	return d
+}
+
+// GetRetryBackoff returns the parsed RetryBackoff, or 0 if it is empty.
+func (r RPC) GetRetryBackoff() time.Duration {
+	d, _ := time.ParseDuration(r.RetryBackoff)
+	return d
+}
+
+// GetCircuitCooldown returns the parsed CircuitCooldown, or 0 if it is empty.
+func (r RPC) GetCircuitCooldown() time.Duration {
+	d, _ := time.ParseDuration(r.CircuitCooldown)
+	return d
+}
+
+// GetHealthCheckInterval returns the parsed HealthCheckInterval, or 0 if it is empty.
+func (r RPC) GetHealthCheckInterval() time.Duration {
+	d, _ := time.ParseDuration(r.HealthCheckInterval)
+	return d
+}
+
+// GetStaleTimeout returns the parsed StaleTimeout, or 0 if it is empty.
+func (r RPC) GetStaleTimeout() time.Duration {
+	d, _ := time.ParseDuration(r.StaleTimeout)
+	return d
+}
+
+// GetWatchInterval returns the parsed WatchInterval, or 0 if it is empty.
+func (r RPC) GetWatchInterval() time.Duration {
+	d, _ := time.ParseDuration(r.WatchInterval)
+	return d
+}
+
+// GetMaxParallelQueries returns MaxParallelQueries, or DefaultMaxParallelQueries if
+// it is 0.
+func (r RPC) GetMaxParallelQueries() int {
+	if r.MaxParallelQueries == 0 {
+		return DefaultMaxParallelQueries
+	}
+
+	return r.MaxParallelQueries
+}
+
+// Metrics defines the configuration of pushing SignCTRL's metrics to a Prometheus
+// remote-write endpoint, for hosts that must not accept inbound scrapes.
+type Metrics struct {
+	// RemoteWriteURL is the URL of the remote-write endpoint, e.g.
+	// https://prometheus.example.com/api/v1/write. Pushing is disabled if it is empty.
+	RemoteWriteURL string `mapstructure:"remote_write_url"`
+
+	// RemoteWriteInterval is the interval in which the metrics are pushed.
+	RemoteWriteInterval string `mapstructure:"remote_write_interval"`
+
+	// BearerTokenFile is the path to a file containing the bearer token sent with
+	// every push. No token is sent if it is empty.
+	BearerTokenFile string `mapstructure:"bearer_token_file"`
+
+	// Instance is the value of the instance label of all pushed time series. The host
+	// name is used if it is empty.
+	Instance string `mapstructure:"instance"`
+
+	// SignLatencySLO is the latency sign requests are expected to be handled within.
+	// Slower requests are logged, and the share of requests within it is exported.
+	// SLO tracking is disabled if it is empty.
+	SignLatencySLO string `mapstructure:"sign_latency_slo"`
+
+	// SLOWindows are the rolling windows the SLO compliance is exported for. They
+	// must be whole minutes.
+	SLOWindows []string `mapstructure:"slo_windows"`
+
+	// HTTPListenAddress is the TCP socket address SignCTRL's HTTP server serving the
+	// metrics, the status and the admin API listens on. It listens on port 8080 of
+	// all interfaces if it is empty.
+	HTTPListenAddress string `mapstructure:"http_laddr"`
+}
+
+// RemoteWriteEnabled returns true if the metrics are pushed to a remote-write
+// endpoint.
+func (m Metrics) RemoteWriteEnabled() bool {
+	return m.RemoteWriteURL != ""
+}
+
+// TracksSLO returns true if a sign latency SLO is configured.
+func (m Metrics) TracksSLO() bool {
+	return m.SignLatencySLO != ""
+}
+
+// validate validates the configuration's metrics section.
+func (m Metrics) validate() error {
+	var errs string
+	if m.RemoteWriteEnabled() {
+		if u, err := url.Parse(m.RemoteWriteURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs += "	remote_write_url must be an http:// or https:// URL\n"
+		}
+		if d, err := time.ParseDuration(m.RemoteWriteInterval); err != nil || d <= 0 {
+			errs += "	remote_write_interval must be a positive duration, e.g. \"15s\"\n"
+		}
+	}
+	if m.TracksSLO() {
+		if d, err := time.ParseDuration(m.SignLatencySLO); err != nil || d <= 0 {
+			errs += "	sign_latency_slo must be a positive duration, e.g. \"500ms\"\n"
+		}
+		if len(m.SLOWindows) == 0 {
+			errs += "	slo_windows must not be empty if sign_latency_slo is set\n"
+		}
+		for _, w := range m.SLOWindows {
+			if d, err := time.ParseDuration(w); err != nil || d < time.Minute || d%time.Minute != 0 {
+				errs += fmt.Sprintf("	slo_windows must be whole minutes, e.g. \"5m\", instead got %q\n", w)
+			}
+		}
+	}
+	if m.HTTPListenAddress != "" {
+		if err := validateAddress(m.HTTPListenAddress, "http_laddr"); err != nil {
+			errs += fmt.Sprintf("\t%v\n", err)
+		} else if !strings.HasPrefix(m.HTTPListenAddress, "tcp://") {
+			errs += "\thttp_laddr must be a TCP address\n"
+		}
+	}
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// GetRemoteWriteInterval returns the parsed RemoteWriteInterval.
+func (m Metrics) GetRemoteWriteInterval() time.Duration {
+	d, _ := time.ParseDuration(m.RemoteWriteInterval)
+	return d
+}
+
+// GetSignLatencySLO returns the parsed SignLatencySLO.
+func (m Metrics) GetSignLatencySLO() time.Duration {
+	d, _ := time.ParseDuration(m.SignLatencySLO)
+	return d
+}
+
+// GetSLOWindows returns the parsed SLOWindows.
+func (m Metrics) GetSLOWindows() []time.Duration {
+	windows := make([]time.Duration, 0, len(m.SLOWindows))
+	for _, w := range m.SLOWindows {
+		d, _ := time.ParseDuration(w)
+		windows = append(windows, d)
+	}
+
+	return windows
+}
+
+// Features defines the feature flags for SignCTRL's new, risky subsystems. All
+// features are disabled by default.
+type Features struct {
+	// PeerCoordination lets the validators in the set coordinate rank updates with
+	// each other.
+	PeerCoordination bool `mapstructure:"peer_coordination"`
+
+	// CircularDemotion moves the signer that exceeded the threshold to the last rank
+	// instead of taking it out of the set.
+	CircularDemotion bool `mapstructure:"circular_demotion"`
+
+	// SharedSlashingDB keeps the double-signing protection in a database shared by
+	// the validators in the set.
+	SharedSlashingDB bool `mapstructure:"shared_slashing_db"`
+}
+
+// Consumer defines an Interchain Security consumer chain that the validator validates
+// for in addition to the provider chain in the [privval] section. Each consumer chain
+// has its own validator node, and thus its own privval connection.
+type Consumer struct {
+	// ChainID is the consumer chain's ID.
+	ChainID string `mapstructure:"chain_id"`
+
+	// ValidatorListenAddress is the TCP socket address the consumer chain's validator
+	// listens on for an external PrivValidator process.
+	ValidatorListenAddress string `mapstructure:"validator_laddr"`
+
+	// ValidatorListenAddressRPC is the TCP socket address the consumer chain's
+	// validator's RPC server listens on.
+	ValidatorListenAddressRPC string `mapstructure:"validator_laddr_rpc"`
+}
+
+// validate validates a [[consumer]] section of the configuration file.
+func (c Consumer) validate() error {
+	var errs string
+	if c.ChainID == "" {
+		errs += "	chain_id of consumer must not be empty\n"
+	}
+	if err := validateAddress(c.ValidatorListenAddress, "validator_laddr of consumer "+c.ChainID); err != nil {
+		errs += fmt.Sprintf("\t%v\n", err.Error())
+	}
+	if err := validateAddress(c.ValidatorListenAddressRPC, "validator_laddr_rpc of consumer "+c.ChainID); err != nil {
+		errs += fmt.Sprintf("\t%v\n", err.Error())
+	}
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// Config defines the structure of SignCTRL's configuration file.
+type Config struct {
+	// Base defines the [base] section of the configuration file.
+	Base Base `mapstructure:"base"`
+
+	// Privval defines the [privval] section of the configuration file.
+	Privval PrivValidator `mapstructure:"privval"`
+
+	// Features defines the [features] section of the configuration file.
+	Features Features `mapstructure:"features"`
+
+	// Slashing defines the [slashing] section of the configuration file.
+	Slashing Slashing `mapstructure:"slashing"`
+
+	// RPC defines the [rpc] section of the configuration file.
+	RPC RPC `mapstructure:"rpc"`
+
+	// LightClient defines the [light_client] section of the configuration file.
+	LightClient LightClient `mapstructure:"light_client"`
+
+	// Metrics defines the [metrics] section of the configuration file.
+	Metrics Metrics `mapstructure:"metrics"`
+
+	// Upgrades defines the [upgrades] section of the configuration file.
+	Upgrades Upgrades `mapstructure:"upgrades"`
+
+	// Maintenance defines the [maintenance] section of the configuration file.
+	Maintenance Maintenance `mapstructure:"maintenance"`
+
+	// Admin defines the [admin] section of the configuration file.
+	Admin Admin `mapstructure:"admin"`
+
+	// Sandbox defines the [sandbox] section of the configuration file.
+	Sandbox Sandbox `mapstructure:"sandbox"`
+
+	// Backup defines the [backup] section of the configuration file.
+	Backup Backup `mapstructure:"backup"`
+
+	// Integrity defines the [integrity] section of the configuration file.
+	Integrity Integrity `mapstructure:"integrity"`
+
+	// Watchdog defines the [watchdog] section of the configuration file.
+	Watchdog Watchdog `mapstructure:"watchdog"`
+
+	// History defines the [history] section of the configuration file.
+	History History `mapstructure:"history"`
+
+	// Clock defines the [clock] section of the configuration file.
+	Clock Clock `mapstructure:"clock"`
+
+	// P2P defines the [p2p] section of the configuration file.
+	P2P P2P `mapstructure:"p2p"`
+
+	// Alerts defines the [alerts] section of the configuration file.
+	Alerts Alerts `mapstructure:"alerts"`
+
+	// Lock defines the [lock] section of the configuration file.
+	Lock Lock `mapstructure:"lock"`
+
+	// KMS defines the [kms] section of the configuration file.
+	KMS KMS `mapstructure:"kms"`
+
+	// Consumers defines the [[consumer]] sections of the configuration file.
+	Consumers []Consumer `mapstructure:"consumer"`
+}
+
+// ForConsumer returns the configuration for signing on the given consumer chain. The
+// set, threshold, rank and features are shared with the provider chain. The slashing
+// and staking modules, the light client's trust root, the further RPC endpoints and
+// their quorum, the upgrade heights, tmkms's state file and the heartbeats are only
+// used for the provider chain, and consumer chains are always signed for via the
+// socket transport.
+func (c Config) ForConsumer(consumer Consumer) Config {
+	c.Base.ValidatorListenAddress = consumer.ValidatorListenAddress
+	c.Base.ValidatorListenAddressRPC = consumer.ValidatorListenAddressRPC
+	c.Privval.ChainID = consumer.ChainID
+	c.Privval.TmkmsStateFile = ""
+	c.Privval.Transport = ""
+	c.Slashing = Slashing{}
+	c.LightClient = LightClient{}
+	c.RPC.Endpoints = nil
+	c.RPC.Quorum = 0
+	c.Upgrades = Upgrades{}
+	c.P2P = P2P{}
+	c.Base.Coordination = ""
+	c.Consumers = nil
+
+	return c
+}
+
+// validate validates the configuration.
+func (c Config) validate() error {
+	var errs string
+	if err := c.Base.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.Privval.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.Slashing.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.RPC.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.LightClient.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.Metrics.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.Upgrades.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.Maintenance.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.Admin.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.Sandbox.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.Backup.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.Integrity.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.Watchdog.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.History.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.Clock.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.P2P.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.Alerts.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.Lock.validate(); err != nil {
+		errs += err.Error()
+	}
+	if err := c.KMS.validate(); err != nil {
+		errs += err.Error()
+	}
+	if c.Privval.UsesMTLS() && !strings.HasPrefix(c.Base.ValidatorListenAddress, "tcp://") {
+		errs += "\tthe mtls transport requires a TCP validator_laddr\n"
+	}
+	if c.Privval.RequiresProposalApproval() && c.Privval.GetProposalApprovalTimeout() >= c.Base.GetReadDeadline() {
+		errs += "\tproposal_approval_timeout must be shorter than read_deadline, as validators that don't ping stay silent while a proposal is held\n"
+	}
+	if c.Base.UsesRaft() && (!c.P2P.Enabled() || c.P2P.ElectionTimeout == "") {
+		errs += "\tcoordination raft requires the p2p section with an election_timeout\n"
+	}
+	chainIDs := map[string]bool{c.Privval.ChainID: true}
+	for _, consumer := range c.Consumers {
+		if err := consumer.validate(); err != nil {
+			errs += err.Error()
+		}
+		if consumer.ChainID != "" && chainIDs[consumer.ChainID] {
+			errs += fmt.Sprintf("\tchain_id %v is used more than once\n", consumer.ChainID)
+		}
+		chainIDs[consumer.ChainID] = true
+	}
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// Validate validates the configuration. Load validates the configuration file on its
+// own, so Validate only needs to be called for configurations that are built in code.
+func (c Config) Validate() error {
+	return c.validate()
+}
+
+// Dir returns the configuration directory in use. It is always set in the following
+// order:
+//
+// 1) Custom environment variable $SIGNCTRL_CONFIG_DIR
+// 2) $HOME/.signctrl
+// 3) Current working directory
+//
+// If one is not set, the directory falls back to the next one.
+func Dir() string {
+	if dir := os.Getenv("SIGNCTRL_CONFIG_DIR"); dir != "" {
+		return dir
+	} else if dir, err := os.UserHomeDir(); err == nil {
+		return dir + "/.signctrl"
+	}
+
+	return "."
+}
+
+// FilePath returns the absolute path to the configuration file.
+func FilePath(cfgDir string) string {
+	return filepath.Join(cfgDir, File)
+}
+
+// GetRetryDialTime converts the string representation of RetryDialAfter, e.g. "15s"
+// or "1m30s", into time.Duration and returns it, or 0 if it is invalid.
+//
+// Deprecated: Use Base.GetRetryDialAfter, which is only called on validated
+// configurations.
+func GetRetryDialTime(timeString string) time.Duration {
+	d, _ := time.ParseDuration(timeString)
+	return d
+}
+
+// logLevelsToRegExp returns a regular expression for the validation of log levels.
+func logLevelsToRegExp(levels *[]logutils.LogLevel) string {
+	regExp := ""
+	maxLevels := len(*levels) - 1
+	for i, lvl := range *levels {
+		regExp += string(lvl)
+		if i < maxLevels {
+			regExp += "|"
+		}
+	}
+
+	return regExp
+}
+
+// Load loads and validates the configuration file.
+func Load() (c Config, err error) {
+	if err = viper.ReadInConfig(); err != nil {
+		return Config{}, err
+	}
+	if err = viper.Unmarshal(&c); err != nil {
+		return Config{}, err
+	}
+	if err = c.validate(); err != nil {
+		return Config{}, err
+	}
+
+	return c, nil
+}
+
+// LoadFrom loads and validates the configuration file in the given directory. Unlike
+// Load, it doesn't use the global viper instance, so that the configurations of
+// several directories can be loaded side by side.
+func LoadFrom(cfgDir string) (c Config, err error) {
+	v := viper.New()
+	v.SetConfigFile(FilePath(cfgDir))
+	if err = v.ReadInConfig(); err != nil {
+		return Config{}, err
+	}
+	if err = v.Unmarshal(&c); err != nil {
 		return Config{}, err
 	}
 	if err = c.validate(); err != nil {
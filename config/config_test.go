@@ -1,12 +1,16 @@
 package config
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/BlockscapeNetwork/signctrl/types"
 	"github.com/hashicorp/logutils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func testConfig(t *testing.T) *Config {
@@ -34,6 +38,24 @@ func testInvalidBase(t *testing.T, base Base) {
 	assert.Error(t, err)
 	base.LogLevel = testConfig(t).Base.LogLevel
 
+	// Invalid Base.LogFormat.
+	base.LogFormat = "xml"
+	err = base.validate()
+	assert.Error(t, err)
+	base.LogFormat = testConfig(t).Base.LogFormat
+
+	// Invalid Base.LogRateLimit.
+	base.LogRateLimit = -1
+	err = base.validate()
+	assert.Error(t, err)
+	base.LogRateLimit = testConfig(t).Base.LogRateLimit
+
+	// Invalid Base.LogBurst.
+	base.LogBurst = -1
+	err = base.validate()
+	assert.Error(t, err)
+	base.LogBurst = testConfig(t).Base.LogBurst
+
 	// Invalid Base.SetSize.
 	base.SetSize = 0
 	err = base.validate()
@@ -46,6 +68,22 @@ func testInvalidBase(t *testing.T, base Base) {
 	assert.Error(t, err)
 	base.Threshold = testConfig(t).Base.Threshold
 
+	// Invalid Base.ThresholdDuration.
+	base.ThresholdDuration = "-1m"
+	assert.Error(t, base.validate())
+	base.ThresholdDuration = "2m"
+	assert.NoError(t, base.validate())
+	assert.Equal(t, 2*time.Minute, base.GetThresholdDuration())
+	base.ThresholdDuration = ""
+
+	// Invalid Base.Coordination.
+	base.Coordination = "paxos"
+	assert.Error(t, base.validate())
+	base.Coordination = CoordinationRaft
+	assert.NoError(t, base.validate())
+	assert.True(t, base.UsesRaft())
+	base.Coordination = ""
+
 	// Invalid Base.StartRank.
 	base.StartRank = 0
 	err = base.validate()
@@ -70,12 +108,18 @@ func testInvalidBase(t *testing.T, base Base) {
 	assert.Error(t, err)
 	base.ValidatorListenAddress = testConfig(t).Base.ValidatorListenAddress
 
-	// Invalid IPv4 address in Base.ValidatorListenAddress.
+	// Invalid IP address in Base.ValidatorListenAddress.
 	base.ValidatorListenAddress = "tcp://127.300.0.1:3000"
 	err = base.validate()
 	assert.Error(t, err)
 	base.ValidatorListenAddress = testConfig(t).Base.ValidatorListenAddress
 
+	// Invalid host name in Base.ValidatorListenAddress.
+	base.ValidatorListenAddress = "tcp://validator-0..validators:3000"
+	err = base.validate()
+	assert.Error(t, err)
+	base.ValidatorListenAddress = testConfig(t).Base.ValidatorListenAddress
+
 	// Invalid protocol in Base.ValidatorListenAddressRPC.
 	base.ValidatorListenAddressRPC = "invalid://127.0.0.1:26657"
 	err = base.validate()
@@ -105,6 +149,50 @@ func testInvalidBase(t *testing.T, base Base) {
 	err = base.validate()
 	assert.Error(t, err)
 	base.RetryDialAfter = testConfig(t).Base.RetryDialAfter
+
+	// Composite Base.RetryDialAfter.
+	base.RetryDialAfter = "1m30s"
+	assert.NoError(t, base.validate())
+	base.RetryDialAfter = testConfig(t).Base.RetryDialAfter
+
+	// Invalid Base.ReadDeadline.
+	base.ReadDeadline = "500ms"
+	err = base.validate()
+	assert.Error(t, err)
+	base.ReadDeadline = "10m"
+	assert.NoError(t, base.validate())
+	assert.Equal(t, 10*time.Minute, base.GetReadDeadline())
+	base.ReadDeadline = ""
+	assert.Equal(t, DefaultReadDeadline, base.GetReadDeadline())
+
+	// Invalid Base.DialBackoffInitial.
+	base.DialBackoffInitial = "0s"
+	err = base.validate()
+	assert.Error(t, err)
+	base.DialBackoffInitial = ""
+
+	// Base.DialBackoffMax less than Base.DialBackoffInitial.
+	base.DialBackoffInitial, base.DialBackoffMax = "10s", "5s"
+	err = base.validate()
+	assert.Error(t, err)
+	base.DialBackoffInitial, base.DialBackoffMax = "", ""
+
+	// Invalid Base.DialBackoffMultiplier.
+	base.DialBackoffMultiplier = 0.5
+	err = base.validate()
+	assert.Error(t, err)
+	base.DialBackoffMultiplier = 0
+}
+
+func TestGetDialBackoff(t *testing.T) {
+	base := testConfig(t).Base
+	assert.Equal(t, time.Duration(0), base.GetDialBackoffInitial())
+	assert.Equal(t, time.Duration(0), base.GetDialBackoffMax())
+
+	base.DialBackoffInitial, base.DialBackoffMax, base.DialBackoffMultiplier = "500ms", "1m", 1.5
+	assert.NoError(t, base.validate())
+	assert.Equal(t, 500*time.Millisecond, base.GetDialBackoffInitial())
+	assert.Equal(t, time.Minute, base.GetDialBackoffMax())
 }
 
 func testInvalidPrivValidator(t *testing.T, privval PrivValidator) {
@@ -113,6 +201,747 @@ func testInvalidPrivValidator(t *testing.T, privval PrivValidator) {
 	err := privval.validate()
 	assert.Error(t, err)
 	privval.ChainID = testConfig(t).Privval.ChainID
+
+	// Invalid PrivValidator.ValidatorSetCheck.
+	privval.ValidatorSetCheck = "INVALID"
+	err = privval.validate()
+	assert.Error(t, err)
+	privval.ValidatorSetCheck = testConfig(t).Privval.ValidatorSetCheck
+}
+
+func TestValidatePrivval_ProposalApproval(t *testing.T) {
+	privval := testConfig(t).Privval
+	assert.False(t, privval.RequiresProposalApproval())
+
+	privval.ProposalApprovalTimeout = "30s"
+	assert.NoError(t, privval.validate())
+	assert.True(t, privval.RequiresProposalApproval())
+	assert.Equal(t, 30*time.Second, privval.GetProposalApprovalTimeout())
+
+	// Invalid PrivValidator.ProposalApprovalTimeout.
+	privval.ProposalApprovalTimeout = "0s"
+	assert.Error(t, privval.validate())
+}
+
+func TestValidate_ProposalApprovalReadDeadline(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Base.ReadDeadline = "1m"
+	cfg.Privval.ProposalApprovalTimeout = "30s"
+	assert.NoError(t, cfg.validate())
+
+	// Proposals must be decided before the connection is considered dead.
+	cfg.Privval.ProposalApprovalTimeout = "1m"
+	assert.Error(t, cfg.validate())
+}
+
+func TestValidatePrivval_Transport(t *testing.T) {
+	privval := testConfig(t).Privval
+	privval.Transport = "socket"
+	assert.NoError(t, privval.validate())
+	assert.False(t, privval.UsesGRPC())
+
+	privval.Transport = "grpc"
+	privval.GRPCListenAddress = "tcp://127.0.0.1:3001"
+	assert.NoError(t, privval.validate())
+	assert.True(t, privval.UsesGRPC())
+
+	// Invalid PrivValidator.Transport.
+	invalid := privval
+	invalid.Transport = "http"
+	assert.Error(t, invalid.validate())
+
+	// Invalid PrivValidator.GRPCListenAddress.
+	invalid = privval
+	invalid.GRPCListenAddress = "127.0.0.1:3001"
+	assert.Error(t, invalid.validate())
+
+	// Only one of PrivValidator.GRPCCertFile and PrivValidator.GRPCKeyFile is set.
+	invalid = privval
+	invalid.GRPCCertFile = "server.crt"
+	assert.Error(t, invalid.validate())
+
+	// PrivValidator.GRPCClientCAFile requires TLS.
+	invalid = privval
+	invalid.GRPCClientCAFile = "ca.crt"
+	assert.Error(t, invalid.validate())
+	invalid.GRPCCertFile, invalid.GRPCKeyFile = "server.crt", "server.key"
+	assert.NoError(t, invalid.validate())
+}
+
+func TestValidateConfig_MTLS(t *testing.T) {
+	cfg := *testConfig(t)
+	cfg.Privval.Transport = "mtls"
+	assert.True(t, cfg.Privval.UsesMTLS())
+	assert.False(t, cfg.Privval.UsesGRPC())
+
+	// The certificates are missing.
+	assert.Error(t, cfg.validate())
+
+	cfg.Privval.TLSCertFile, cfg.Privval.TLSKeyFile, cfg.Privval.TLSCAFile = "client.crt", "client.key", "ca.crt"
+	assert.NoError(t, cfg.validate())
+
+	// Unix domain sockets aren't dialed over TLS.
+	cfg.Base.ValidatorListenAddress = "unix:///var/run/validator.sock"
+	assert.Error(t, cfg.validate())
+}
+
+func TestValidateSlashing(t *testing.T) {
+	// Disabled by default.
+	var slashing Slashing
+	assert.NoError(t, slashing.validate())
+	assert.False(t, slashing.Enabled())
+
+	slashing = Slashing{
+		LCDListenAddress: "tcp://127.0.0.1:1317",
+		ValconsAddress:   "cosmosvalcons1abc",
+		QueryInterval:    "1m30s",
+	}
+	assert.NoError(t, slashing.validate())
+	assert.Equal(t, 90*time.Second, slashing.GetQueryInterval())
+
+	// Invalid Slashing.LCDListenAddress.
+	invalid := slashing
+	invalid.LCDListenAddress = "tcp://127.0.0.1"
+	assert.Error(t, invalid.validate())
+
+	// Invalid Slashing.ValconsAddress.
+	invalid = slashing
+	invalid.ValconsAddress = ""
+	assert.Error(t, invalid.validate())
+
+	// Invalid Slashing.QueryInterval.
+	invalid = slashing
+	invalid.QueryInterval = "0s"
+	assert.Error(t, invalid.validate())
+}
+
+func TestValidateMetrics(t *testing.T) {
+	// Disabled by default.
+	var m Metrics
+	assert.NoError(t, m.validate())
+	assert.False(t, m.RemoteWriteEnabled())
+
+	m = Metrics{
+		RemoteWriteURL:      "https://prometheus.example.com/api/v1/write",
+		RemoteWriteInterval: "15s",
+	}
+	assert.NoError(t, m.validate())
+	assert.True(t, m.RemoteWriteEnabled())
+	assert.Equal(t, 15*time.Second, m.GetRemoteWriteInterval())
+
+	// Invalid Metrics.RemoteWriteURL.
+	invalid := m
+	invalid.RemoteWriteURL = "tcp://127.0.0.1:9090"
+	assert.Error(t, invalid.validate())
+
+	// Invalid Metrics.RemoteWriteInterval.
+	invalid = m
+	invalid.RemoteWriteInterval = ""
+	assert.Error(t, invalid.validate())
+
+	// SLO tracking is independent of pushing.
+	m = Metrics{SignLatencySLO: "500ms", SLOWindows: []string{"5m", "1h"}}
+	assert.NoError(t, m.validate())
+	assert.True(t, m.TracksSLO())
+	assert.Equal(t, 500*time.Millisecond, m.GetSignLatencySLO())
+	assert.Equal(t, []time.Duration{5 * time.Minute, time.Hour}, m.GetSLOWindows())
+
+	// Invalid Metrics.SignLatencySLO.
+	invalid = m
+	invalid.SignLatencySLO = "-1s"
+	assert.Error(t, invalid.validate())
+
+	// Invalid Metrics.SLOWindows.
+	invalid = m
+	invalid.SLOWindows = nil
+	assert.Error(t, invalid.validate())
+	invalid.SLOWindows = []string{"90s"}
+	assert.Error(t, invalid.validate())
+	invalid.SLOWindows = []string{"30s"}
+	assert.Error(t, invalid.validate())
+
+	// The HTTP server may listen on IPv6 addresses.
+	m = Metrics{HTTPListenAddress: "tcp://[::]:8080"}
+	assert.NoError(t, m.validate())
+
+	// Invalid Metrics.HTTPListenAddress.
+	invalid = Metrics{HTTPListenAddress: "tcp://[::1]"}
+	assert.Error(t, invalid.validate())
+	invalid.HTTPListenAddress = "unix:///tmp/signctrl.sock"
+	assert.Error(t, invalid.validate())
+}
+
+func TestValidateUpgrades(t *testing.T) {
+	// Disabled by default.
+	var u Upgrades
+	assert.NoError(t, u.validate())
+	assert.False(t, u.QueriesPlan())
+
+	u = Upgrades{
+		Heights:          []int64{1000},
+		Window:           10,
+		LCDListenAddress: "tcp://127.0.0.1:1317",
+		QueryInterval:    "10m",
+	}
+	assert.NoError(t, u.validate())
+	assert.True(t, u.QueriesPlan())
+	assert.Equal(t, 10*time.Minute, u.GetQueryInterval())
+
+	// Invalid Upgrades.Heights.
+	invalid := u
+	invalid.Heights = []int64{1000, 0}
+	assert.Error(t, invalid.validate())
+
+	// Invalid Upgrades.Window.
+	invalid = u
+	invalid.Window = -1
+	assert.Error(t, invalid.validate())
+
+	// Invalid Upgrades.LCDListenAddress.
+	invalid = u
+	invalid.LCDListenAddress = "127.0.0.1"
+	assert.Error(t, invalid.validate())
+
+	// Invalid Upgrades.QueryInterval.
+	invalid = u
+	invalid.QueryInterval = "0s"
+	assert.Error(t, invalid.validate())
+}
+
+func TestValidateMaintenance(t *testing.T) {
+	var m Maintenance
+	assert.NoError(t, m.validate())
+
+	m.Windows = []string{"0 3 * * 0 2h"}
+	assert.NoError(t, m.validate())
+	windows, err := m.GetWindows()
+	assert.NoError(t, err)
+	assert.Len(t, windows, 1)
+
+	// Invalid Maintenance.Windows.
+	m.Windows = []string{"0 3 * * 0"}
+	assert.Error(t, m.validate())
+}
+
+func TestValidateAdmin(t *testing.T) {
+	var a Admin
+	assert.NoError(t, a.validate())
+	assert.False(t, a.RequiresToken())
+
+	a = Admin{TokenFile: "/etc/signctrl/admin_tokens", TOTPSecretFile: "/etc/signctrl/totp_secret"}
+	assert.NoError(t, a.validate())
+	assert.True(t, a.RequiresToken())
+	assert.True(t, a.RequiresTOTP())
+
+	// Admin.TOTPSecretFile without Admin.TokenFile.
+	a.TokenFile = ""
+	assert.Error(t, a.validate())
+}
+
+func TestValidateSandbox(t *testing.T) {
+	var s Sandbox
+	assert.NoError(t, s.validate())
+
+	s = Sandbox{Enabled: true, ReadPaths: []string{"/etc/signctrl"}, WritePaths: []string{"/var/lib/tmkms"}, ConnectPorts: []int{3002}}
+	assert.NoError(t, s.validate())
+
+	// Relative Sandbox.WritePaths.
+	s.WritePaths = []string{"tmkms"}
+	assert.Error(t, s.validate())
+
+	// Invalid Sandbox.ConnectPorts.
+	s.WritePaths = nil
+	s.ConnectPorts = []int{0}
+	assert.Error(t, s.validate())
+}
+
+func TestValidateBackup(t *testing.T) {
+	var b Backup
+	assert.NoError(t, b.validate())
+	assert.False(t, b.Enabled())
+
+	b = Backup{
+		Interval:        "1m",
+		Recipient:       "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p",
+		Endpoint:        "https://storage.googleapis.com",
+		Region:          "auto",
+		Bucket:          "signctrl-backups",
+		Object:          "signctrl/state.age",
+		CredentialsFile: "/etc/signctrl/backup_credentials",
+	}
+	assert.NoError(t, b.validate())
+	assert.Equal(t, time.Minute, b.GetInterval())
+
+	// Invalid Backup.Recipient.
+	b.Recipient = "AGE-SECRET-KEY-1"
+	assert.Error(t, b.validate())
+
+	// Invalid Backup.Endpoint.
+	b.Recipient = "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"
+	b.Endpoint = "storage.googleapis.com"
+	assert.Error(t, b.validate())
+}
+
+func TestLoadCredentials(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "credentials")
+	require.NoError(t, ioutil.WriteFile(file, []byte("access\nsecret\n"), 0600))
+	id, secret, err := LoadCredentials(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "access", id)
+	assert.Equal(t, "secret", secret)
+
+	require.NoError(t, ioutil.WriteFile(file, []byte("access\n"), 0600))
+	_, _, err = LoadCredentials(file)
+	assert.Error(t, err)
+}
+
+func TestValidateIntegrity(t *testing.T) {
+	var i Integrity
+	assert.NoError(t, i.validate())
+	assert.False(t, i.Enabled())
+
+	i = Integrity{SignatureFile: "/usr/local/bin/signctrl.sig", SigningKey: "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=", Enforce: true}
+	assert.NoError(t, i.validate())
+
+	// Invalid Integrity.SigningKey.
+	i.SigningKey = "AAAA"
+	assert.Error(t, i.validate())
+
+	// Integrity.Enforce without Integrity.SignatureFile.
+	i.SigningKey = ""
+	i.SignatureFile = ""
+	assert.Error(t, i.validate())
+}
+
+func TestValidateWatchdog(t *testing.T) {
+	var w Watchdog
+	assert.NoError(t, w.validate())
+	assert.False(t, w.Enabled())
+	assert.True(t, w.Restarts())
+
+	w = Watchdog{StallTimeout: "30s", Action: "unhealthy"}
+	assert.NoError(t, w.validate())
+	assert.True(t, w.Enabled())
+	assert.False(t, w.Restarts())
+	assert.Equal(t, 30*time.Second, w.GetStallTimeout())
+
+	// Invalid Watchdog.StallTimeout.
+	w.StallTimeout = "0s"
+	assert.Error(t, w.validate())
+
+	// Invalid Watchdog.Action.
+	w.StallTimeout = "30s"
+	w.Action = "panic"
+	assert.Error(t, w.validate())
+}
+
+func TestValidateHistory(t *testing.T) {
+	var h History
+	assert.NoError(t, h.validate())
+	assert.False(t, h.Enabled())
+
+	h = History{Retention: "720h"}
+	assert.NoError(t, h.validate())
+	assert.True(t, h.Enabled())
+	assert.Equal(t, 720*time.Hour, h.GetRetention())
+
+	// Invalid History.Retention.
+	h.Retention = "30d"
+	assert.Error(t, h.validate())
+}
+
+func TestValidateClock(t *testing.T) {
+	var c Clock
+	assert.NoError(t, c.validate())
+	assert.False(t, c.Enabled())
+
+	c = Clock{NTPServer: "pool.ntp.org", CheckInterval: "10m", WarnOffset: "500ms", MaxOffset: "2s"}
+	assert.NoError(t, c.validate())
+	assert.True(t, c.Enabled())
+	assert.Equal(t, 10*time.Minute, c.GetCheckInterval())
+	assert.Equal(t, 500*time.Millisecond, c.GetWarnOffset())
+	assert.Equal(t, 2*time.Second, c.GetMaxOffset())
+
+	// Invalid Clock.CheckInterval.
+	invalid := c
+	invalid.CheckInterval = ""
+	assert.Error(t, invalid.validate())
+
+	// Clock.WarnOffset higher than Clock.MaxOffset.
+	invalid = c
+	invalid.WarnOffset = "3s"
+	assert.Error(t, invalid.validate())
+}
+
+func TestValidateLock(t *testing.T) {
+	var l Lock
+	assert.NoError(t, l.validate())
+	assert.False(t, l.Enabled())
+
+	l = Lock{Backend: LockBackendEtcd, Endpoints: []string{"http://10.0.0.1:2379"}, KeyPrefix: "signctrl/lock/", TTL: "15s"}
+	assert.NoError(t, l.validate())
+	assert.True(t, l.Enabled())
+	assert.Equal(t, "signctrl/lock/cosmoshub-4", l.GetKey("cosmoshub-4"))
+	assert.Equal(t, 15*time.Second, l.GetTTL())
+
+	// Unknown Lock.Backend.
+	invalid := l
+	invalid.Backend = "zookeeper"
+	assert.Error(t, invalid.validate())
+
+	// Lock.Endpoints without a scheme.
+	invalid = l
+	invalid.Endpoints = []string{"10.0.0.1:2379"}
+	assert.Error(t, invalid.validate())
+
+	// Lock.TTL too short for Consul.
+	invalid = l
+	invalid.TTL = "5s"
+	assert.Error(t, invalid.validate())
+}
+
+func TestValidateKMS(t *testing.T) {
+	var k KMS
+	assert.NoError(t, k.validate())
+	assert.False(t, k.Enabled())
+
+	k = KMS{KeyID: "arn:aws:kms:eu-central-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab", Region: "eu-central-1"}
+	assert.NoError(t, k.validate())
+	assert.True(t, k.Enabled())
+	assert.Equal(t, "https://kms.eu-central-1.amazonaws.com", k.GetEndpoint())
+
+	k.Endpoint = "https://vpce-0123.kms.eu-central-1.vpce.amazonaws.com"
+	assert.NoError(t, k.validate())
+	assert.Equal(t, k.Endpoint, k.GetEndpoint())
+
+	// KMS.Endpoint without a scheme.
+	invalid := k
+	invalid.Endpoint = "kms.eu-central-1.amazonaws.com"
+	assert.Error(t, invalid.validate())
+
+	// Missing KMS.Region.
+	invalid = k
+	invalid.Region = ""
+	assert.Error(t, invalid.validate())
+}
+
+func TestValidateP2P(t *testing.T) {
+	var p P2P
+	assert.NoError(t, p.validate())
+	assert.False(t, p.Enabled())
+
+	p = P2P{
+		ListenAddress:     "tcp://0.0.0.0:26660",
+		Peers:             []string{"tcp://10.0.0.2:26660", "tcp://signctrl-1.validators:26660"},
+		Name:              "signctrl-0",
+		SecretFile:        "/etc/signctrl/p2p_secret",
+		HeartbeatInterval: "1s",
+		PeerTimeout:       "5s",
+	}
+	assert.NoError(t, p.validate())
+	assert.True(t, p.Enabled())
+	assert.Equal(t, "signctrl-0", p.GetName())
+	assert.Equal(t, time.Second, p.GetHeartbeatInterval())
+	assert.Equal(t, 5*time.Second, p.GetPeerTimeout())
+
+	// The name falls back to the host name.
+	hostname, _ := os.Hostname()
+	unnamed := p
+	unnamed.Name = ""
+	assert.Equal(t, hostname, unnamed.GetName())
+
+	// Unix domain socket addresses.
+	invalid := p
+	invalid.ListenAddress = "unix:///tmp/p2p.sock"
+	assert.Error(t, invalid.validate())
+	invalid = p
+	invalid.Peers = []string{"10.0.0.2:26660"}
+	assert.Error(t, invalid.validate())
+
+	// No peers.
+	invalid = p
+	invalid.Peers = nil
+	assert.Error(t, invalid.validate())
+
+	// No secret.
+	invalid = p
+	invalid.SecretFile = ""
+	assert.Error(t, invalid.validate())
+
+	// P2P.PeerTimeout not longer than P2P.HeartbeatInterval.
+	invalid = p
+	invalid.PeerTimeout = "1s"
+	assert.Error(t, invalid.validate())
+
+	// P2P.ElectionTimeout.
+	p.ElectionTimeout = "3s"
+	assert.NoError(t, p.validate())
+	assert.Equal(t, 3*time.Second, p.GetElectionTimeout())
+	invalid = p
+	invalid.ElectionTimeout = "2s"
+	assert.Error(t, invalid.validate())
+	invalid.ElectionTimeout = "-3s"
+	assert.Error(t, invalid.validate())
+}
+
+func TestValidateAlerts(t *testing.T) {
+	var a Alerts
+	assert.NoError(t, a.validate())
+	assert.False(t, a.Enabled())
+	assert.Equal(t, DefaultAlertEvents, a.GetEvents())
+
+	a = Alerts{
+		Webhooks:          []string{"https://alerts.example.com/signctrl", "http://127.0.0.1:9093/hook"},
+		Events:            []string{"promoted", "stopped"},
+		DisconnectTimeout: "1m",
+	}
+	assert.NoError(t, a.validate())
+	assert.True(t, a.Enabled())
+	assert.Equal(t, []string{"promoted", "stopped"}, a.GetEvents())
+	assert.Equal(t, time.Minute, a.GetDisconnectTimeout())
+
+	// Alerts to PagerDuty or Slack only.
+	assert.True(t, Alerts{PagerDutyRoutingKey: "key"}.Enabled())
+	assert.True(t, Alerts{SlackWebhook: "https://hooks.slack.com/services/T000/B000/XXXX"}.Enabled())
+
+	// Severities.
+	a.Severities = map[string]string{"promoted": "info"}
+	assert.NoError(t, a.validate())
+	assert.Equal(t, "info", a.GetSeverity("promoted"))
+	assert.Equal(t, "critical", a.GetSeverity("must_shutdown"))
+	assert.Equal(t, "info", a.GetSeverity("stopped"))
+
+	// Invalid Alerts.Webhooks.
+	invalid := a
+	invalid.Webhooks = []string{"alerts.example.com"}
+	assert.Error(t, invalid.validate())
+	invalid.Webhooks = []string{"tcp://127.0.0.1:9093"}
+	assert.Error(t, invalid.validate())
+
+	// Invalid Alerts.Events.
+	invalid = a
+	invalid.Events = []string{"promotion"}
+	assert.Error(t, invalid.validate())
+
+	// Invalid Alerts.SlackWebhook.
+	invalid = a
+	invalid.SlackWebhook = "http://hooks.slack.com/services/T000/B000/XXXX"
+	assert.Error(t, invalid.validate())
+
+	// Invalid Alerts.Severities.
+	invalid = a
+	invalid.Severities = map[string]string{"promotion": "warning"}
+	assert.Error(t, invalid.validate())
+	invalid.Severities = map[string]string{"promoted": "high"}
+	assert.Error(t, invalid.validate())
+
+	// Invalid Alerts.DisconnectTimeout.
+	invalid = a
+	invalid.DisconnectTimeout = "0s"
+	assert.Error(t, invalid.validate())
+}
+
+func TestValidateConfig_Raft(t *testing.T) {
+	cfg := *testConfig(t)
+	cfg.Base.Coordination = CoordinationRaft
+
+	// The p2p section is missing.
+	assert.Error(t, cfg.validate())
+
+	cfg.P2P = P2P{
+		ListenAddress:     "tcp://0.0.0.0:26660",
+		Peers:             []string{"tcp://10.0.0.2:26660", "tcp://10.0.0.3:26660"},
+		SecretFile:        "/etc/signctrl/p2p_secret",
+		HeartbeatInterval: "1s",
+		PeerTimeout:       "5s",
+	}
+	assert.Error(t, cfg.validate())
+
+	cfg.P2P.ElectionTimeout = "3s"
+	assert.NoError(t, cfg.validate())
+
+	// Consumer chains fall back to the threshold.
+	consumerCfg := cfg.ForConsumer(Consumer{
+		ChainID:                   "consumerchain",
+		ValidatorListenAddress:    "tcp://127.0.0.1:3001",
+		ValidatorListenAddressRPC: "tcp://127.0.0.1:26667",
+	})
+	assert.False(t, consumerCfg.Base.UsesRaft())
+	assert.NoError(t, consumerCfg.validate())
+}
+
+func TestValidateRPC(t *testing.T) {
+	// Empty durations fall back to the defaults.
+	var r RPC
+	assert.NoError(t, r.validate())
+	assert.Zero(t, r.GetTimeout())
+
+	r = RPC{
+		Timeout:             "5s",
+		Retries:             2,
+		RetryBackoff:        "200ms",
+		CircuitThreshold:    5,
+		CircuitCooldown:     "30s",
+		Endpoints:           []string{"tcp://10.0.0.2:26657"},
+		HealthCheckInterval: "10s",
+		MaxLag:              2,
+	}
+	assert.NoError(t, r.validate())
+	assert.Equal(t, 5*time.Second, r.GetTimeout())
+	assert.Equal(t, 200*time.Millisecond, r.GetRetryBackoff())
+	assert.Equal(t, 30*time.Second, r.GetCircuitCooldown())
+	assert.Equal(t, 10*time.Second, r.GetHealthCheckInterval())
+	assert.Zero(t, r.GetStaleTimeout())
+	assert.Zero(t, r.GetWatchInterval())
+
+	// Invalid RPC.StaleTimeout.
+	invalid := r
+	invalid.StaleTimeout = "-1m"
+	assert.Error(t, invalid.validate())
+
+	// Invalid RPC.WatchInterval.
+	invalid = r
+	invalid.WatchInterval = "0s"
+	assert.Error(t, invalid.validate())
+
+	// Invalid RPC.Timeout.
+	invalid = r
+	invalid.Timeout = "5"
+	assert.Error(t, invalid.validate())
+
+	// Invalid RPC.Retries.
+	invalid = r
+	invalid.Retries = -1
+	assert.Error(t, invalid.validate())
+
+	// Invalid RPC.CircuitThreshold.
+	invalid = r
+	invalid.CircuitThreshold = -1
+	assert.Error(t, invalid.validate())
+
+	// Invalid RPC.Endpoints.
+	invalid = r
+	invalid.Endpoints = []string{"10.0.0.2:26657"}
+	assert.Error(t, invalid.validate())
+
+	// Invalid RPC.HealthCheckInterval.
+	invalid = r
+	invalid.HealthCheckInterval = "0s"
+	assert.Error(t, invalid.validate())
+
+	// Invalid RPC.MaxLag.
+	invalid = r
+	invalid.MaxLag = -1
+	assert.Error(t, invalid.validate())
+
+	// RPC.Quorum higher than the number of RPC servers.
+	invalid = r
+	invalid.Quorum = len(r.Endpoints) + 2
+	assert.Error(t, invalid.validate())
+	invalid.Quorum = len(r.Endpoints) + 1
+	assert.NoError(t, invalid.validate())
+	assert.Equal(t, DefaultMaxParallelQueries, invalid.GetMaxParallelQueries())
+
+	// Invalid RPC.MaxParallelQueries.
+	invalid = r
+	invalid.MaxParallelQueries = -1
+	assert.Error(t, invalid.validate())
+}
+
+func TestValidateLightClient(t *testing.T) {
+	// The verification is disabled by default.
+	var l LightClient
+	assert.False(t, l.Enabled())
+	assert.NoError(t, l.validate())
+
+	l = LightClient{
+		TrustHeight: 1,
+		TrustHash:   "B7B2DB7A8E1DF3E9B4C23A1A6A5A3F8E0E2FBDC1AE2A0BC5DF11E0D7A2B1C3D4",
+		TrustPeriod: "168h",
+		Witnesses:   []string{"tcp://127.0.0.1:26667"},
+	}
+	assert.True(t, l.Enabled())
+	assert.NoError(t, l.validate())
+	assert.Equal(t, 168*time.Hour, l.GetTrustPeriod())
+
+	// Invalid LightClient.TrustHeight.
+	invalid := l
+	invalid.TrustHeight = -1
+	assert.Error(t, invalid.validate())
+
+	// Invalid LightClient.TrustHash.
+	invalid = l
+	invalid.TrustHash = "B7B2DB7A"
+	assert.Error(t, invalid.validate())
+
+	// Invalid LightClient.TrustPeriod.
+	invalid = l
+	invalid.TrustPeriod = "0s"
+	assert.Error(t, invalid.validate())
+
+	// Invalid LightClient.Witnesses.
+	invalid = l
+	invalid.Witnesses = []string{"127.0.0.1:26667"}
+	assert.Error(t, invalid.validate())
+}
+
+func TestValidateConsumers(t *testing.T) {
+	cfg := testConfig(t)
+	consumer := Consumer{
+		ChainID:                   "consumerchain",
+		ValidatorListenAddress:    "tcp://127.0.0.1:3001",
+		ValidatorListenAddressRPC: "tcp://127.0.0.1:26667",
+	}
+	cfg.Consumers = []Consumer{consumer}
+	assert.NoError(t, cfg.validate())
+
+	// Invalid Consumer.ValidatorListenAddress.
+	invalid := consumer
+	invalid.ValidatorListenAddress = "127.0.0.1:3001"
+	assert.Error(t, invalid.validate())
+
+	// Chain IDs must be unique across the provider and consumer chains.
+	cfg.Consumers = []Consumer{consumer, consumer}
+	assert.Error(t, cfg.validate())
+	invalid = consumer
+	invalid.ChainID = cfg.Privval.ChainID
+	cfg.Consumers = []Consumer{invalid}
+	assert.Error(t, cfg.validate())
+}
+
+func TestConfig_ForConsumer(t *testing.T) {
+	cfg := *testConfig(t)
+	cfg.Slashing.LCDListenAddress = "tcp://127.0.0.1:1317"
+	cfg.Privval.Transport = "grpc"
+	cfg.Privval.GRPCListenAddress = "tcp://127.0.0.1:3002"
+	cfg.RPC.Endpoints = []string{"tcp://10.0.0.2:26657"}
+	cfg.RPC.Quorum = 2
+	cfg.Upgrades.Heights = []int64{1000}
+	cfg.P2P.ListenAddress = "tcp://0.0.0.0:26660"
+	consumer := Consumer{
+		ChainID:                   "consumerchain",
+		ValidatorListenAddress:    "tcp://127.0.0.1:3001",
+		ValidatorListenAddressRPC: "tcp://127.0.0.1:26667",
+	}
+	cfg.Consumers = []Consumer{consumer}
+
+	consumerCfg := cfg.ForConsumer(consumer)
+	assert.Equal(t, "consumerchain", consumerCfg.Privval.ChainID)
+	assert.Equal(t, consumer.ValidatorListenAddress, consumerCfg.Base.ValidatorListenAddress)
+	assert.Equal(t, consumer.ValidatorListenAddressRPC, consumerCfg.Base.ValidatorListenAddressRPC)
+	assert.Equal(t, cfg.Base.Threshold, consumerCfg.Base.Threshold)
+	assert.False(t, consumerCfg.Slashing.Enabled())
+	assert.False(t, consumerCfg.Privval.UsesGRPC())
+	assert.Empty(t, consumerCfg.Consumers)
+	assert.Empty(t, consumerCfg.RPC.Endpoints)
+	assert.Zero(t, consumerCfg.RPC.Quorum)
+	assert.Empty(t, consumerCfg.Upgrades.Heights)
+	assert.False(t, consumerCfg.P2P.Enabled())
+	assert.NoError(t, consumerCfg.validate())
+
+	// The provider's configuration is left untouched.
+	assert.Equal(t, "testchain", cfg.Privval.ChainID)
+	assert.Len(t, cfg.Consumers, 1)
 }
 
 func TestValidateConfig(t *testing.T) {
@@ -162,15 +991,90 @@ func TestGetRetryDialTime(t *testing.T) {
 	dur = GetRetryDialTime("1h")
 	assert.Equal(t, time.Hour, dur)
 
-	dur = GetRetryDialTime("01h")
-	assert.Equal(t, time.Duration(0), dur)
+	dur = GetRetryDialTime("1m30s")
+	assert.Equal(t, 90*time.Second, dur)
 
 	dur = GetRetryDialTime("1d")
 	assert.Equal(t, time.Duration(0), dur)
 }
 
+func TestGetLogFormat(t *testing.T) {
+	assert.Equal(t, types.LogFormatText, Base{}.GetLogFormat())
+	assert.Equal(t, types.LogFormatJSON, Base{LogFormat: "json"}.GetLogFormat())
+}
+
+func TestGetLogBurst(t *testing.T) {
+	b := Base{LogRateLimit: 1024}
+	assert.Equal(t, 1024, b.GetLogBurst())
+	b.LogBurst = 4096
+	assert.Equal(t, 4096, b.GetLogBurst())
+}
+
+func TestValidateAddress(t *testing.T) {
+	for _, addr := range []string{
+		"tcp://127.0.0.1:3000",
+		"tcp://[::1]:3000",
+		"tcp://[2001:db8::1]:3000",
+		"tcp://localhost:3000",
+		"tcp://validator-0.validators:3000",
+		"tcp://validator-0.validators.default.svc.cluster.local.:3000",
+		"tcp://signctrl_validator_1:3000",
+		"unix:///tmp/validator.sock",
+	} {
+		assert.NoError(t, validateAddress(addr, "addr"), addr)
+	}
+	for _, addr := range []string{
+		"tcp://127.300.0.1:3000",
+		"tcp://-validator:3000",
+		"tcp://validator-.validators:3000",
+		"tcp://validator 0:3000",
+		"tcp://:3000",
+	} {
+		assert.Error(t, validateAddress(addr, "addr"), addr)
+	}
+}
+
 func TestLogLevelsToRegExp(t *testing.T) {
 	lvls := []logutils.LogLevel{"A", "BC", "DEF"}
 	regexp := logLevelsToRegExp(&lvls)
 	assert.Equal(t, "A|BC|DEF", regexp)
 }
+
+func TestLoadFrom(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, Create(dir))
+	assert.NoError(t, Override(dir, map[string]interface{}{"privval.chain_id": "cosmoshub-4", "base.start_rank": 1}))
+
+	c, err := LoadFrom(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "cosmoshub-4", c.Privval.ChainID)
+
+	// The configuration is validated.
+	assert.NoError(t, Override(dir, map[string]interface{}{"base.set_size": 0}))
+	_, err = LoadFrom(dir)
+	assert.Error(t, err)
+
+	_, err = LoadFrom(t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestValidateDuration(t *testing.T) {
+	assert.Empty(t, validateDuration("retry_dial_after", "1m30s", time.Second, time.Hour))
+	assert.Empty(t, validateDuration("threshold_duration", "100h", time.Second, 0))
+	assert.Equal(t, "\tretry_dial_after must be a duration like \"15s\" or \"1m30s\", but is \"1d\"\n", validateDuration("retry_dial_after", "1d", time.Second, time.Hour))
+	assert.Equal(t, "\tretry_dial_after must be at least 1s\n", validateDuration("retry_dial_after", "500ms", time.Second, time.Hour))
+	assert.Equal(t, "\tretry_dial_after must be at most 1h0m0s\n", validateDuration("retry_dial_after", "2h", time.Second, time.Hour))
+}
+
+func TestLoadFrom_InvalidDuration(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, Create(dir))
+	require.NoError(t, Override(dir, map[string]interface{}{
+		"privval.chain_id":      "cosmoshub-4",
+		"base.retry_dial_after": "1d",
+	}))
+
+	_, err := LoadFrom(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "retry_dial_after must be a duration")
+}
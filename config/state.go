@@ -1,11 +1,13 @@
 package config
 
 import (
-	"fmt"
-	"io/ioutil"
+	"errors"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/BlockscapeNetwork/signctrl/statefile"
+	"github.com/BlockscapeNetwork/signctrl/statemac"
 	tm_json "github.com/tendermint/tendermint/libs/json"
 )
 
@@ -16,13 +18,34 @@ const (
 
 	// PermStateFile determines the default file permissions for the
 	// signctrl_state.json file.
-	PermStateFile = os.FileMode(0644)
+	PermStateFile = os.FileMode(0600)
 )
 
 // State defines the contents of the signctrl_state.json file.
 type State struct {
 	LastHeight int64 `json:"last_height"`
 	LastRank   int   `json:"last_rank"`
+
+	// LastRound and LastStep are the round and step of the last sign request at
+	// LastHeight.
+	LastRound int32 `json:"last_round"`
+	LastStep  int8  `json:"last_step"`
+
+	// MissedInARow and CounterLocked are the counter for missed blocks in a row and
+	// whether it was locked when the state was saved.
+	MissedInARow  int  `json:"missed_in_a_row"`
+	CounterLocked bool `json:"counter_locked"`
+
+	// Epoch is the epoch the node last took the signing authority in. It increases
+	// every time a node of the set is promoted to rank 1.
+	Epoch uint64 `json:"epoch"`
+
+	// LastConnected is the time the connection to the validator was last established.
+	LastConnected time.Time `json:"last_connected"`
+
+	// Recovered is set if the file was corrupted and the state was recovered from its
+	// backup. It isn't persisted.
+	Recovered bool `json:"-"`
 }
 
 // validate validates the contents of the signctrl_state.json file.
@@ -35,7 +58,7 @@ func (s State) validate() error {
 		errs += "\tlast_rank in signctrl_state.json must be 1 or higher\n"
 	}
 	if errs != "" {
-		return fmt.Errorf(errs)
+		return errors.New(errs)
 	}
 
 	return nil
@@ -47,9 +70,18 @@ func StateFilePath(cfgDir string) string {
 }
 
 // LoadOrGenState loads the contents of the signctrl_state.json file and returns them
-// if it exists, or generetas a new one.
+// if it exists, or generetas a new one. A corrupted file is recovered from its backup,
+// which is reported by State.Recovered.
 func LoadOrGenState(cfgDir string) (State, error) {
-	if _, err := os.Stat(StateFilePath(cfgDir)); os.IsNotExist(err) {
+	var s State
+	recovered, err := statefile.Load(StateFilePath(cfgDir), func(data []byte) error {
+		s = State{}
+		if err := tm_json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		return s.validate()
+	})
+	if os.IsNotExist(err) {
 		state := State{
 			LastHeight: 1,
 			LastRank:   0,
@@ -59,33 +91,21 @@ func LoadOrGenState(cfgDir string) (State, error) {
 		}
 
 		return state, nil
-	}
-
-	bytes, err := ioutil.ReadFile(StateFilePath(cfgDir))
-	if err != nil {
-		return State{}, err
-	}
-
-	var s State
-	if err := tm_json.Unmarshal(bytes, &s); err != nil {
-		return State{}, err
-	}
-	if err := s.validate(); err != nil {
+	} else if err != nil {
 		return State{}, err
 	}
+	s.Recovered = recovered
 
 	return s, nil
 }
 
-// Save saves the current state to the signctrl_state.json file.
+// Save saves the current state to the signctrl_state.json file. The previous state is
+// kept as a backup, see the statefile package.
 func (s *State) Save(cfgDir string) error {
-	lrFile, err := tm_json.MarshalIndent(&State{
-		LastRank:   s.LastRank,
-		LastHeight: s.LastHeight,
-	}, "", "\t")
+	bytes, err := tm_json.MarshalIndent(s, "", "\t")
 	if err != nil {
 		return err
 	}
 
-	return ioutil.WriteFile(StateFilePath(cfgDir), lrFile, PermStateFile)
+	return statemac.Write(cfgDir, StateFilePath(cfgDir), bytes, PermStateFile)
 }
@@ -1,10 +1,13 @@
 package config
 
 import (
+	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func testState(t *testing.T) *State {
@@ -40,23 +43,66 @@ func TestStateFilePath(t *testing.T) {
 }
 
 func TestLoadOrGenState(t *testing.T) {
+	dir := t.TempDir()
+
 	// Generate.
-	state, err := LoadOrGenState(".")
-	defer os.Remove("./signctrl_state.json")
+	state, err := LoadOrGenState(dir)
 	assert.NotNil(t, state)
 	assert.NoError(t, err)
 
 	// Load invalid.
-	state, err = LoadOrGenState(".")
+	state, err = LoadOrGenState(dir)
 	assert.Equal(t, state, State{})
 	assert.Error(t, err)
 
 	// Load valid.
 	state = *testState(t)
-	err = state.Save(".")
+	err = state.Save(dir)
 	assert.NoError(t, err)
 
-	state, err = LoadOrGenState(".")
+	state, err = LoadOrGenState(dir)
 	assert.Equal(t, state, *testState(t))
 	assert.NoError(t, err)
 }
+
+func TestSaveState(t *testing.T) {
+	dir := t.TempDir()
+	state := State{
+		LastHeight:    10,
+		LastRank:      2,
+		LastRound:     1,
+		LastStep:      3,
+		MissedInARow:  4,
+		CounterLocked: true,
+		LastConnected: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, state.Save(dir))
+
+	loaded, err := LoadOrGenState(dir)
+	require.NoError(t, err)
+	assert.Equal(t, state, loaded)
+
+	info, err := os.Stat(StateFilePath(dir))
+	require.NoError(t, err)
+	assert.Equal(t, PermStateFile, info.Mode().Perm())
+
+	// No temporary files are left behind, only the checksum.
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestLoadOrGenState_Recovered(t *testing.T) {
+	dir := t.TempDir()
+	state := *testState(t)
+	require.NoError(t, state.Save(dir))
+	state.LastHeight++
+	require.NoError(t, state.Save(dir))
+
+	// A corrupted state file is recovered from the backup.
+	require.NoError(t, ioutil.WriteFile(StateFilePath(dir), []byte(`{"last_height":`), PermStateFile))
+	loaded, err := LoadOrGenState(dir)
+	require.NoError(t, err)
+	assert.True(t, loaded.Recovered)
+	assert.Equal(t, testState(t).LastHeight, loaded.LastHeight)
+}
@@ -3,8 +3,14 @@ package config
 import (
 	"bytes"
 	"embed"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -25,6 +31,82 @@ var (
 	// Embed the privval.toml into the SignCTRL binary.
 	//go:embed templates/privval.toml
 	privvalTemplate embed.FS
+
+	// Embed the features.toml into the SignCTRL binary.
+	//go:embed templates/features.toml
+	featuresTemplate embed.FS
+
+	// Embed the slashing.toml into the SignCTRL binary.
+	//go:embed templates/slashing.toml
+	slashingTemplate embed.FS
+
+	// Embed the rpc.toml into the SignCTRL binary.
+	//go:embed templates/rpc.toml
+	rpcTemplate embed.FS
+
+	// Embed the light_client.toml into the SignCTRL binary.
+	//go:embed templates/light_client.toml
+	lightClientTemplate embed.FS
+
+	// Embed the metrics.toml into the SignCTRL binary.
+	//go:embed templates/metrics.toml
+	metricsTemplate embed.FS
+
+	// Embed the upgrades.toml into the SignCTRL binary.
+	//go:embed templates/upgrades.toml
+	upgradesTemplate embed.FS
+
+	// Embed the maintenance.toml into the SignCTRL binary.
+	//go:embed templates/maintenance.toml
+	maintenanceTemplate embed.FS
+
+	// Embed the admin.toml into the SignCTRL binary.
+	//go:embed templates/admin.toml
+	adminTemplate embed.FS
+
+	// Embed the sandbox.toml into the SignCTRL binary.
+	//go:embed templates/sandbox.toml
+	sandboxTemplate embed.FS
+
+	// Embed the backup.toml into the SignCTRL binary.
+	//go:embed templates/backup.toml
+	backupTemplate embed.FS
+
+	// Embed the integrity.toml into the SignCTRL binary.
+	//go:embed templates/integrity.toml
+	integrityTemplate embed.FS
+
+	// Embed the watchdog.toml into the SignCTRL binary.
+	//go:embed templates/watchdog.toml
+	watchdogTemplate embed.FS
+
+	// Embed the history.toml into the SignCTRL binary.
+	//go:embed templates/history.toml
+	historyTemplate embed.FS
+
+	// Embed the clock.toml into the SignCTRL binary.
+	//go:embed templates/clock.toml
+	clockTemplate embed.FS
+
+	// Embed the p2p.toml into the SignCTRL binary.
+	//go:embed templates/p2p.toml
+	p2pTemplate embed.FS
+
+	// Embed the alerts.toml into the SignCTRL binary.
+	//go:embed templates/alerts.toml
+	alertsTemplate embed.FS
+
+	// Embed the lock.toml into the SignCTRL binary.
+	//go:embed templates/lock.toml
+	lockTemplate embed.FS
+
+	// Embed the kms.toml into the SignCTRL binary.
+	//go:embed templates/kms.toml
+	kmsTemplate embed.FS
+
+	// Embed the consumers.toml into the SignCTRL binary.
+	//go:embed templates/consumers.toml
+	consumersTemplate embed.FS
 )
 
 // Section is a custom type for specific sections in the configuration file.
@@ -36,10 +118,69 @@ const (
 
 	// PrivvalSection defines the [privval] section of the configuration file.
 	PrivvalSection
+
+	// FeaturesSection defines the [features] section of the configuration file.
+	FeaturesSection
+
+	// SlashingSection defines the [slashing] section of the configuration file.
+	SlashingSection
+
+	// RPCSection defines the [rpc] section of the configuration file.
+	RPCSection
+
+	// LightClientSection defines the [light_client] section of the configuration file.
+	LightClientSection
+
+	// MetricsSection defines the [metrics] section of the configuration file.
+	MetricsSection
+
+	// UpgradesSection defines the [upgrades] section of the configuration file.
+	UpgradesSection
+
+	// MaintenanceSection defines the [maintenance] section of the configuration file.
+	MaintenanceSection
+
+	// AdminSection defines the [admin] section of the configuration file.
+	AdminSection
+
+	// SandboxSection defines the [sandbox] section of the configuration file.
+	SandboxSection
+
+	// BackupSection defines the [backup] section of the configuration file.
+	BackupSection
+
+	// IntegritySection defines the [integrity] section of the configuration file.
+	IntegritySection
+
+	// WatchdogSection defines the [watchdog] section of the configuration file.
+	WatchdogSection
+
+	// HistorySection defines the [history] section of the configuration file.
+	HistorySection
+
+	// ClockSection defines the [clock] section of the configuration file.
+	ClockSection
+
+	// P2PSection defines the [p2p] section of the configuration file.
+	P2PSection
+
+	// AlertsSection defines the [alerts] section of the configuration file.
+	AlertsSection
+
+	// LockSection defines the [lock] section of the configuration file.
+	LockSection
+
+	// KMSSection defines the [kms] section of the configuration file.
+	KMSSection
+
+	// ConsumersSection defines the [[consumer]] sections of the configuration file.
+	ConsumersSection
 )
 
 // Create writes configuration templates to the configuration file at the specified
-// configuration directory. The base and privval sections are created by default.
+// configuration directory. The base, privval, features, slashing, rpc, light_client,
+// metrics, upgrades, maintenance, admin, sandbox, backup, integrity, watchdog, history,
+// clock, p2p, alerts, lock, kms and consumers sections are created by default.
 func Create(cfgDir string, sections ...Section) error {
 	var cfg bytes.Buffer
 	baseBytes, err := baseTemplate.ReadFile("templates/base.toml")
@@ -56,9 +197,197 @@ func Create(cfgDir string, sections ...Section) error {
 	if _, err := cfg.Write(privvalBytes); err != nil {
 		return err
 	}
+	featuresBytes, err := featuresTemplate.ReadFile("templates/features.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(featuresBytes); err != nil {
+		return err
+	}
+	slashingBytes, err := slashingTemplate.ReadFile("templates/slashing.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(slashingBytes); err != nil {
+		return err
+	}
+	rpcBytes, err := rpcTemplate.ReadFile("templates/rpc.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(rpcBytes); err != nil {
+		return err
+	}
+	lightClientBytes, err := lightClientTemplate.ReadFile("templates/light_client.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(lightClientBytes); err != nil {
+		return err
+	}
+	metricsBytes, err := metricsTemplate.ReadFile("templates/metrics.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(metricsBytes); err != nil {
+		return err
+	}
+	upgradesBytes, err := upgradesTemplate.ReadFile("templates/upgrades.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(upgradesBytes); err != nil {
+		return err
+	}
+	maintenanceBytes, err := maintenanceTemplate.ReadFile("templates/maintenance.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(maintenanceBytes); err != nil {
+		return err
+	}
+	adminBytes, err := adminTemplate.ReadFile("templates/admin.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(adminBytes); err != nil {
+		return err
+	}
+	sandboxBytes, err := sandboxTemplate.ReadFile("templates/sandbox.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(sandboxBytes); err != nil {
+		return err
+	}
+	backupBytes, err := backupTemplate.ReadFile("templates/backup.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(backupBytes); err != nil {
+		return err
+	}
+	integrityBytes, err := integrityTemplate.ReadFile("templates/integrity.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(integrityBytes); err != nil {
+		return err
+	}
+	watchdogBytes, err := watchdogTemplate.ReadFile("templates/watchdog.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(watchdogBytes); err != nil {
+		return err
+	}
+	historyBytes, err := historyTemplate.ReadFile("templates/history.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(historyBytes); err != nil {
+		return err
+	}
+	clockBytes, err := clockTemplate.ReadFile("templates/clock.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(clockBytes); err != nil {
+		return err
+	}
+	p2pBytes, err := p2pTemplate.ReadFile("templates/p2p.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(p2pBytes); err != nil {
+		return err
+	}
+	alertsBytes, err := alertsTemplate.ReadFile("templates/alerts.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(alertsBytes); err != nil {
+		return err
+	}
+	lockBytes, err := lockTemplate.ReadFile("templates/lock.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(lockBytes); err != nil {
+		return err
+	}
+	kmsBytes, err := kmsTemplate.ReadFile("templates/kms.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(kmsBytes); err != nil {
+		return err
+	}
+	consumersBytes, err := consumersTemplate.ReadFile("templates/consumers.toml")
+	if err != nil {
+		return err
+	}
+	if _, err := cfg.Write(consumersBytes); err != nil {
+		return err
+	}
 	if err := ioutil.WriteFile(FilePath(cfgDir), cfg.Bytes(), PermConfigToml); err != nil {
 		return err
 	}
 
 	return err
 }
+
+// tomlValue formats the given value as a TOML value.
+func tomlValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+
+	return fmt.Sprint(v)
+}
+
+// Override sets the given values in the configuration file at the specified
+// configuration directory, keeping the comments of the file intact. The values are
+// keyed by section and key, e.g. "base.threshold", and each key must already be
+// present in its section.
+func Override(cfgDir string, values map[string]interface{}) error {
+	bytes, err := ioutil.ReadFile(FilePath(cfgDir))
+	if err != nil {
+		return err
+	}
+
+	sectionRegExp := regexp.MustCompile(`^\[([a-z_]+)\]$`)
+	keyRegExp := regexp.MustCompile(`^([a-z_]+) = `)
+	found := make(map[string]bool, len(values))
+	lines := strings.Split(string(bytes), "\n")
+	section := ""
+	for i, line := range lines {
+		if m := sectionRegExp.FindStringSubmatch(line); m != nil {
+			section = m[1]
+			continue
+		} else if strings.HasPrefix(line, "[") {
+			section = ""
+			continue
+		}
+		if m := keyRegExp.FindStringSubmatch(line); m != nil {
+			name := section + "." + m[1]
+			if v, ok := values[name]; ok {
+				lines[i] = fmt.Sprintf("%v = %v", m[1], tomlValue(v))
+				found[name] = true
+			}
+		}
+	}
+
+	var missing []string
+	for name := range values {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return errors.New("keys not found in the configuration file: " + strings.Join(missing, ", "))
+	}
+
+	return ioutil.WriteFile(FilePath(cfgDir), []byte(strings.Join(lines, "\n")), PermConfigToml)
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -12,3 +13,26 @@ func TestCreate(t *testing.T) {
 	defer os.Remove("./config.toml")
 	assert.NoError(t, err)
 }
+
+func TestOverride(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, Create(dir))
+
+	err := Override(dir, map[string]interface{}{
+		"privval.chain_id": "cosmoshub-4",
+		"base.threshold":   12,
+		"rpc.timeout":      "3s",
+	})
+	assert.NoError(t, err)
+
+	v := viper.New()
+	v.SetConfigFile(FilePath(dir))
+	assert.NoError(t, v.ReadInConfig())
+	assert.Equal(t, "cosmoshub-4", v.GetString("privval.chain_id"))
+	assert.Equal(t, 12, v.GetInt("base.threshold"))
+	assert.Equal(t, "3s", v.GetString("rpc.timeout"))
+
+	// Keys that aren't in the configuration file are rejected.
+	err = Override(dir, map[string]interface{}{"base.unknown": 1})
+	assert.Error(t, err)
+}
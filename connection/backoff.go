@@ -0,0 +1,83 @@
+package connection
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	// DefaultBackoffInitial is the default delay before the first retry to dial the
+	// validator.
+	DefaultBackoffInitial = time.Second
+
+	// DefaultBackoffMax is the default maximum delay between two attempts to dial the
+	// validator.
+	DefaultBackoffMax = 30 * time.Second
+
+	// DefaultBackoffMultiplier is the default factor the delay grows by with every
+	// failed attempt.
+	DefaultBackoffMultiplier = 2.0
+)
+
+// Backoff computes the delays between the attempts to dial the validator. The delay
+// starts at Initial and is multiplied by Multiplier after every attempt, up to Max.
+// Every delay is randomly shortened by up to half, so that the nodes of a set that
+// lost their validators at the same time don't redial them in lockstep. A Backoff is
+// not safe for concurrent use.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+
+	// OnDelay is called with every delay, and with 0 once the backoff is reset. It is
+	// optional.
+	OnDelay func(d time.Duration)
+
+	next time.Duration // delay before jitter of the next attempt, 0 after a reset
+}
+
+// NewBackoff creates a new Backoff. Zero values are replaced with the defaults.
+func NewBackoff(initial, max time.Duration, multiplier float64) *Backoff {
+	if initial <= 0 {
+		initial = DefaultBackoffInitial
+	}
+	if max <= 0 {
+		max = DefaultBackoffMax
+	}
+	if max < initial {
+		max = initial
+	}
+	if multiplier < 1 {
+		multiplier = DefaultBackoffMultiplier
+	}
+
+	return &Backoff{Initial: initial, Max: max, Multiplier: multiplier}
+}
+
+// Next returns the delay before the next attempt and grows the delay for the one
+// after it.
+func (b *Backoff) Next() time.Duration {
+	if b.next == 0 {
+		b.next = b.Initial
+	}
+	d := b.next
+	if b.next = time.Duration(float64(b.next) * b.Multiplier); b.next > b.Max || b.next <= 0 {
+		b.next = b.Max
+	}
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int63n(half+1))
+	}
+	if b.OnDelay != nil {
+		b.OnDelay(d)
+	}
+
+	return d
+}
+
+// Reset resets the delay to Initial, e.g. once a connection proved to be working.
+func (b *Backoff) Reset() {
+	b.next = 0
+	if b.OnDelay != nil {
+		b.OnDelay(0)
+	}
+}
@@ -0,0 +1,38 @@
+package connection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBackoff_Defaults(t *testing.T) {
+	b := NewBackoff(0, 0, 0)
+	assert.Equal(t, DefaultBackoffInitial, b.Initial)
+	assert.Equal(t, DefaultBackoffMax, b.Max)
+	assert.Equal(t, DefaultBackoffMultiplier, b.Multiplier)
+
+	// The maximum is never below the initial delay.
+	b = NewBackoff(time.Minute, time.Second, 2)
+	assert.Equal(t, time.Minute, b.Max)
+}
+
+func TestBackoff(t *testing.T) {
+	var observed []time.Duration
+	b := NewBackoff(time.Second, 5*time.Second, 2)
+	b.OnDelay = func(d time.Duration) { observed = append(observed, d) }
+
+	// The delays grow up to the maximum, and are shortened by up to half.
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		d := b.Next()
+		assert.GreaterOrEqual(t, int64(d), int64(want/2))
+		assert.LessOrEqual(t, int64(d), int64(want))
+	}
+
+	// The delay starts over after a reset.
+	b.Reset()
+	assert.LessOrEqual(t, int64(b.Next()), int64(time.Second))
+	assert.Len(t, observed, 7)
+	assert.Equal(t, time.Duration(0), observed[5])
+}
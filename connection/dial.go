@@ -1,14 +1,13 @@
 package connection
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
 	"os"
-	"os/signal"
 	"regexp"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/BlockscapeNetwork/signctrl/types"
@@ -17,67 +16,100 @@ import (
 )
 
 var (
-	// ErrAbortDial is returned if either SIGINT or SIGTERM are fired into the quit
-	// channel.
+	// ErrAbortDial is returned if the context passed to RetryDial is done before the
+	// validator could be dialed.
 	ErrAbortDial = errors.New("dialing aborted")
 
-	// RetryDialInterval is the interval in which SignCTRL tries to repeatedly dial
-	// the validator. Initially set to 0, then set by retryDialX.
-	// Make SignCTRL dial immediately the first time.
-	RetryDialInterval = time.Duration(0)
+	// Clock is the clock the intervals between dials are measured with.
+	Clock = types.SystemClock
 )
 
 // retryDialTCP keeps dialing the given TCP socket address until success, using the
 // given connkey for encryption and returns the secret connection.
-func retryDialTCP(address string, connkey tm_ed25519.PrivKey, sigs chan os.Signal, logger *types.SyncLogger) (net.Conn, error) {
+func retryDialTCP(ctx context.Context, address string, connkey tm_ed25519.PrivKey, backoff *Backoff, logger types.Logger) (net.Conn, error) {
+	var dialer net.Dialer
+	interval := time.Duration(0)
 	for {
 		select {
-		case <-sigs:
-			return nil, ErrAbortDial
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ErrAbortDial, ctx.Err())
 
-		case <-time.After(RetryDialInterval):
-			if conn, err := net.Dial("tcp", strings.TrimPrefix(address, "tcp://")); err == nil {
+		case <-Clock.After(interval):
+			// Host names are resolved on every dial, so that a changed IP address,
+			// e.g. of a rescheduled pod, is picked up when reconnecting.
+			conn, err := dialer.DialContext(ctx, "tcp", strings.TrimPrefix(address, "tcp://"))
+			if err == nil {
 				logger.Info("Successfully dialed the validator ✓")
+				logger.Debug("Connected to %v", conn.RemoteAddr())
 				return tm_p2pconn.MakeSecretConnection(conn, connkey)
 			}
 
-			// After the first dial, dial in intervals of 1 second.
-			RetryDialInterval = time.Second
-			logger.Debug("Retry dialing...")
+			// After the first dial, back off between the attempts.
+			interval = backoff.Next()
+			logger.Debug("Retry dialing in %v... (%v)", interval, err)
 		}
 	}
 }
 
+// checkUnixSocket returns an error if the file at path exists but isn't a unix domain
+// socket, and warns if other users may connect to it, as any process connecting to the
+// validator's socket could pose as SignCTRL.
+func checkUnixSocket(path string, logger types.Logger) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		// The validator may not have created the socket yet.
+		return nil
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%v is not a unix domain socket", path)
+	}
+	if info.Mode().Perm()&0002 != 0 {
+		logger.Warn("%v can be connected to by any user (%v), restrict its permissions to the validator's and SignCTRL's users", path, info.Mode().Perm())
+	}
+
+	return nil
+}
+
 // retryDialUnix keeps dialing the given unix domain socket address until success and
-// returns the connection.
-func retryDialUnix(address string, sigs chan os.Signal, logger *types.SyncLogger) (net.Conn, error) {
+// returns the connection. The socket file belongs to the validator listening on it, so
+// it is never removed, not even if it is stale.
+func retryDialUnix(ctx context.Context, address string, backoff *Backoff, logger types.Logger) (net.Conn, error) {
 	addrWithoutProtocol := strings.TrimPrefix(address, "unix://")
+	if err := checkUnixSocket(addrWithoutProtocol, logger); err != nil {
+		return nil, err
+	}
 
+	var dialer net.Dialer
+	interval := time.Duration(0)
 	for {
 		select {
-		case <-sigs:
-			return nil, ErrAbortDial
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ErrAbortDial, ctx.Err())
 
-		case <-time.After(RetryDialInterval):
-			unixAddr := &net.UnixAddr{Name: addrWithoutProtocol, Net: "unix"}
-			if conn, err := net.DialUnix("unix", nil, unixAddr); err == nil {
+		case <-Clock.After(interval):
+			conn, err := dialer.DialContext(ctx, "unix", addrWithoutProtocol)
+			if err == nil {
 				logger.Info("Successfully dialed the validator ✓")
 				return conn, nil
 			}
 
-			// After the first dial, dial in intervals of 1 second.
-			os.RemoveAll(addrWithoutProtocol)
-			RetryDialInterval = time.Second
-			logger.Debug("Retry dialing...")
+			// After the first dial, back off between the attempts.
+			interval = backoff.Next()
+			logger.Debug("Retry dialing in %v... (%v)", interval, err)
 		}
 	}
 }
 
 // RetryDial keeps dialing the given address until success and returns the connection.
-func RetryDial(cfgDir, address string, logger *types.SyncLogger) (net.Conn, error) {
+// The first attempt is made right away, and the delays between the following ones are
+// taken from backoff, or from a Backoff with the default settings if it is nil. The
+// backoff isn't reset on success, as the caller knows best whether the connection
+// works. Dialing is aborted with ErrAbortDial once ctx is done.
+func RetryDial(ctx context.Context, cfgDir, address string, backoff *Backoff, logger types.Logger) (net.Conn, error) {
 	logger.Info("Dialing %v... (Use Ctrl+C to abort)", address)
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	if backoff == nil {
+		backoff = NewBackoff(0, 0, 0)
+	}
 
 	protocol := regexp.MustCompile(`tcp|unix`).FindString(address)
 	switch protocol {
@@ -86,12 +118,12 @@ func RetryDial(cfgDir, address string, logger *types.SyncLogger) (net.Conn, erro
 		// a secret/encrypted connection to the validator.
 		connKey, err := LoadConnKey(cfgDir)
 		if err != nil {
-			return nil, fmt.Errorf("couldn't load conn.key: %v", err)
+			return nil, fmt.Errorf("couldn't load conn.key: %w", err)
 		}
-		return retryDialTCP(address, connKey, sigs, logger)
+		return retryDialTCP(ctx, address, connKey, backoff, logger)
 
 	case "unix":
-		return retryDialUnix(address, sigs, logger)
+		return retryDialUnix(ctx, address, backoff, logger)
 
 	default:
 		return nil, fmt.Errorf("unknown protocol in address: %v", protocol)
@@ -1,12 +1,14 @@
 package connection
 
 import (
+	"context"
 	"crypto/ed25519"
 	"crypto/rand"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -70,7 +72,7 @@ func TestRetryDialTCP_NoConnKey(t *testing.T) {
 		assert.NoError(t, err)
 	}()
 
-	conn, err := RetryDial(cfgDir, "tcp://"+laddr, types.NewSyncLogger(ioutil.Discard, "", 0))
+	conn, err := RetryDial(context.Background(), cfgDir, "tcp://"+laddr, nil, types.NewSyncLogger(ioutil.Discard, "", 0))
 	assert.Nil(t, conn)
 	assert.Error(t, err)
 }
@@ -92,7 +94,49 @@ func TestRetryDialTCP_WithConnKey(t *testing.T) {
 		assert.NoError(t, err)
 	}()
 
-	conn, err := RetryDial(cfgDir, "tcp://"+laddr, types.NewSyncLogger(ioutil.Discard, "", 0))
+	conn, err := RetryDial(context.Background(), cfgDir, "tcp://"+laddr, nil, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.NotNil(t, conn)
+	assert.NoError(t, err)
+}
+
+func TestRetryDialTCP_HostName(t *testing.T) {
+	cfgDir := t.TempDir()
+	err := CreateBase64ConnKey(cfgDir)
+	assert.NoError(t, err)
+
+	port, _ := getFreePort(t)
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	go func() {
+		err := startMockTCPServer(t, fmt.Sprintf("127.0.0.1:%v", port), priv, 0)
+		assert.NoError(t, err)
+	}()
+
+	// The host name is resolved when dialing.
+	conn, err := RetryDial(context.Background(), cfgDir, fmt.Sprintf("tcp://localhost:%v", port), nil, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.NotNil(t, conn)
+	assert.NoError(t, err)
+}
+
+func TestRetryDialTCP_IPv6(t *testing.T) {
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 isn't available: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	cfgDir := t.TempDir()
+	err = CreateBase64ConnKey(cfgDir)
+	assert.NoError(t, err)
+
+	laddr := fmt.Sprintf("[::1]:%v", port)
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	go func() {
+		err := startMockTCPServer(t, laddr, priv, 0)
+		assert.NoError(t, err)
+	}()
+
+	conn, err := RetryDial(context.Background(), cfgDir, "tcp://"+laddr, nil, types.NewSyncLogger(ioutil.Discard, "", 0))
 	assert.NotNil(t, conn)
 	assert.NoError(t, err)
 }
@@ -131,15 +175,50 @@ func TestRetryDialUnix(t *testing.T) {
 		assert.NoError(t, err)
 	}()
 
-	conn, err := RetryDial(cfgDir, "unix://"+sockAddr, types.NewSyncLogger(ioutil.Discard, "", 0))
+	conn, err := RetryDial(context.Background(), cfgDir, "unix://"+sockAddr, nil, types.NewSyncLogger(ioutil.Discard, "", 0))
 	assert.NotNil(t, conn)
 	assert.NoError(t, err)
 
 	wg.Wait()
 }
 
+func TestRetryDialUnix_NotASocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	assert.NoError(t, ioutil.WriteFile(path, nil, 0600))
+
+	conn, err := RetryDial(context.Background(), ".", "unix://"+path, nil, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.Nil(t, conn)
+	assert.Error(t, err)
+	assert.FileExists(t, path)
+}
+
+func TestRetryDialUnix_StaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	l, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	// Dialing a stale socket fails, but it isn't removed, as it belongs to the validator.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	conn, err := RetryDial(ctx, ".", "unix://"+path, NewBackoff(10*time.Millisecond, 10*time.Millisecond, 1), types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, ErrAbortDial)
+	assert.FileExists(t, path)
+}
+
 func TestRetryDialUnknown(t *testing.T) {
-	conn, err := RetryDial(".", "invalid://127.0.0.1:3000", types.NewSyncLogger(ioutil.Discard, "", 0))
+	conn, err := RetryDial(context.Background(), ".", "invalid://127.0.0.1:3000", nil, types.NewSyncLogger(ioutil.Discard, "", 0))
 	assert.Nil(t, conn)
 	assert.Error(t, err)
 }
+
+func TestRetryDialAbort(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	conn, err := RetryDial(ctx, ".", "unix://./test_dial_abort.sock", nil, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, ErrAbortDial)
+}
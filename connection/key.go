@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 
+	tm_crypto "github.com/tendermint/tendermint/crypto"
 	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
 )
 
@@ -15,7 +16,7 @@ const (
 
 	// PermConnKeyFile determines the default file permisssions for the connection
 	// key file.
-	PermConnKeyFile = os.FileMode(0700)
+	PermConnKeyFile = os.FileMode(0600)
 )
 
 // KeyFilePath returns the absolute path to the connection key file.
@@ -46,3 +47,39 @@ func CreateBase64ConnKey(cfgDir string) error {
 
 	return ioutil.WriteFile(KeyFilePath(cfgDir), encKey, PermConnKeyFile)
 }
+
+// RotateConnKey replaces the connection key with a new one and returns its public key.
+// The new key is written to a temporary file first, which is then renamed, so that a
+// crash never leaves a truncated conn.key behind. Connections that are already
+// established keep using the old key until they are dialed again.
+func RotateConnKey(cfgDir string) (tm_crypto.PubKey, error) {
+	connKey := tm_ed25519.GenPrivKey()
+	encKey := make([]byte, base64.StdEncoding.EncodedLen(tm_ed25519.PrivateKeySize))
+	base64.StdEncoding.Encode(encKey, connKey)
+
+	tmp, err := ioutil.TempFile(cfgDir, KeyFile+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(PermConnKeyFile); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if _, err := tmp.Write(encKey); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), KeyFilePath(cfgDir)); err != nil {
+		return nil, err
+	}
+
+	return connKey.PubKey(), nil
+}
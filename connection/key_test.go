@@ -14,7 +14,7 @@ func TestKeyFilePath(t *testing.T) {
 
 func TestCreateAndLoadConnKey(t *testing.T) {
 	cfgDir := "./key_test_createandload"
-	err := os.MkdirAll(cfgDir, PermConnKeyFile)
+	err := os.MkdirAll(cfgDir, os.FileMode(0700))
 	assert.NoError(t, err)
 	defer os.RemoveAll(cfgDir)
 
@@ -31,3 +31,27 @@ func TestCreateAndLoadConnKey(t *testing.T) {
 	assert.NotNil(t, key)
 	assert.NoError(t, err)
 }
+
+func TestRotateConnKey(t *testing.T) {
+	cfgDir := t.TempDir()
+	assert.NoError(t, CreateBase64ConnKey(cfgDir))
+	oldKey, err := LoadConnKey(cfgDir)
+	assert.NoError(t, err)
+
+	pubKey, err := RotateConnKey(cfgDir)
+	assert.NoError(t, err)
+
+	newKey, err := LoadConnKey(cfgDir)
+	assert.NoError(t, err)
+	assert.False(t, oldKey.Equals(newKey))
+	assert.Equal(t, pubKey, newKey.PubKey())
+
+	info, err := os.Stat(KeyFilePath(cfgDir))
+	assert.NoError(t, err)
+	assert.Equal(t, PermConnKeyFile, info.Mode().Perm())
+
+	// No temporary files are left behind.
+	files, err := os.ReadDir(cfgDir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
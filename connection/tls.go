@@ -0,0 +1,197 @@
+package connection
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+// tlsHandshakeTimeout is the maximum time the TLS handshake with the validator may
+// take.
+const tlsHandshakeTimeout = 10 * time.Second
+
+// TLSConfig defines the certificates for dialing the validator over mutual TLS.
+type TLSConfig struct {
+	// CertFile and KeyFile are the paths to the client certificate and key.
+	CertFile string
+	KeyFile  string
+
+	// CAFile is the path to the CA certificate the server certificate must be signed
+	// by.
+	CAFile string
+
+	// ServerName is the name the server certificate is verified against. Defaults to
+	// the host of the dialed address.
+	ServerName string
+}
+
+// clientConfig returns the TLS configuration for dialing the given host. The client
+// certificate is read on every handshake, so that a renewed certificate is picked up
+// without a restart, and passed to onCert.
+func (c TLSConfig) clientConfig(host string, onCert func(*x509.Certificate)) (*tls.Config, error) {
+	ca, err := ioutil.ReadFile(c.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no CA certificate found in %v", c.CAFile)
+	}
+	serverName := c.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+
+	return &tls.Config{
+		RootCAs:    pool,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				return nil, err
+			}
+			onCert(leaf)
+
+			return &cert, nil
+		},
+	}, nil
+}
+
+// dialTLS dials the given TCP socket address and performs the TLS handshake. The
+// returned connection is closed once the client or the server certificate expires.
+func dialTLS(ctx context.Context, address string, cfg TLSConfig, logger types.Logger) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	var clientCert *x509.Certificate
+	tlsCfg, err := cfg.clientConfig(host, func(cert *x509.Certificate) { clientCert = cert })
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	raw, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn := tls.Client(raw, tlsCfg)
+	if err := conn.SetDeadline(time.Now().Add(tlsHandshakeTimeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.Handshake(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if clientCert == nil {
+		conn.Close()
+		return nil, fmt.Errorf("the server didn't request a client certificate")
+	}
+
+	notAfter := clientCert.NotAfter
+	if peers := conn.ConnectionState().PeerCertificates; len(peers) > 0 && peers[0].NotAfter.Before(notAfter) {
+		notAfter = peers[0].NotAfter
+	}
+
+	return newExpiringConn(conn, notAfter, logger), nil
+}
+
+// RetryDialTLS keeps dialing the given TCP socket address over mutual TLS until
+// success and returns the connection. The delays between the attempts are taken from
+// backoff, or from a Backoff with the default settings if it is nil. Failed handshakes
+// are retried as well, as an expired certificate may be renewed in the meantime.
+// Dialing is aborted with ErrAbortDial once ctx is done.
+func RetryDialTLS(ctx context.Context, address string, cfg TLSConfig, backoff *Backoff, logger types.Logger) (net.Conn, error) {
+	logger.Info("Dialing %v over mutual TLS... (Use Ctrl+C to abort)", address)
+	if backoff == nil {
+		backoff = NewBackoff(0, 0, 0)
+	}
+
+	interval := time.Duration(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ErrAbortDial, ctx.Err())
+
+		case <-Clock.After(interval):
+			conn, err := dialTLS(ctx, strings.TrimPrefix(address, "tcp://"), cfg, logger)
+			if err == nil {
+				logger.Info("Successfully dialed the validator ✓")
+				return conn, nil
+			}
+
+			// After the first dial, back off between the attempts.
+			interval = backoff.Next()
+			logger.Warn("couldn't dial the validator over mutual TLS, retrying in %v: %v", interval, err)
+		}
+	}
+}
+
+// expiringConn is a connection that is closed once one of the certificates it was
+// established with expires. Reads return io.EOF from then on, so that the connection
+// is dialed again with the renewed certificates right away, just like a connection
+// closed by the validator.
+type expiringConn struct {
+	net.Conn
+
+	expired  int32
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// newExpiringConn wraps conn into an expiringConn that expires at notAfter.
+func newExpiringConn(conn net.Conn, notAfter time.Time, logger types.Logger) *expiringConn {
+	c := &expiringConn{Conn: conn, stop: make(chan struct{})}
+	timer := Clock.NewTimer(notAfter.Sub(Clock.Now()))
+	goroutines.Go("tls_expiry", func() {
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+			atomic.StoreInt32(&c.expired, 1)
+			logger.Info("A TLS certificate of the connection to the validator expired, reconnecting")
+			c.Conn.Close()
+		case <-c.stop:
+		}
+	})
+
+	return c
+}
+
+// Read reads from the connection. It returns io.EOF once a certificate expired.
+// Implements the net.Conn interface.
+func (c *expiringConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err != nil && atomic.LoadInt32(&c.expired) == 1 {
+		return n, io.EOF
+	}
+
+	return n, err
+}
+
+// Close closes the connection and stops waiting for the certificates to expire.
+// Implements the net.Conn interface.
+func (c *expiringConn) Close() error {
+	c.stopOnce.Do(func() { close(c.stop) })
+	return c.Conn.Close()
+}
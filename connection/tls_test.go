@@ -0,0 +1,179 @@
+package connection
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA is a CA issuing the certificates for the TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue issues a certificate for 127.0.0.1 that expires at notAfter and returns it
+// along with its key in PEM format.
+func (ca *testCA) issue(t *testing.T, serial int64, notAfter time.Time) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// startTLSServer starts a TLS server requiring client certificates signed by ca that
+// echoes everything it reads.
+func startTLSServer(t *testing.T, ca *testCA) net.Listener {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, 2, time.Now().Add(24*time.Hour))
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return l
+}
+
+// writeTLSConfig writes a client certificate expiring at notAfter along with the CA
+// certificate to dir and returns the TLSConfig for them.
+func writeTLSConfig(t *testing.T, dir string, ca *testCA, notAfter time.Time) TLSConfig {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, 3, notAfter)
+	cfg := TLSConfig{
+		CertFile: filepath.Join(dir, "client.crt"),
+		KeyFile:  filepath.Join(dir, "client.key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	require.NoError(t, ioutil.WriteFile(cfg.CertFile, certPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(cfg.KeyFile, keyPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(cfg.CAFile, ca.pem, 0600))
+
+	return cfg
+}
+
+func TestRetryDialTLS(t *testing.T) {
+	ca := newTestCA(t)
+	l := startTLSServer(t, ca)
+	cfg := writeTLSConfig(t, t.TempDir(), ca, time.Now().Add(24*time.Hour))
+	logger := types.NewSyncLogger(ioutil.Discard, "", 0)
+
+	conn, err := RetryDialTLS(context.Background(), "tcp://"+l.Addr().String(), cfg, nil, logger)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+}
+
+func TestRetryDialTLS_UntrustedServer(t *testing.T) {
+	l := startTLSServer(t, newTestCA(t))
+	cfg := writeTLSConfig(t, t.TempDir(), newTestCA(t), time.Now().Add(24*time.Hour))
+	logger := types.NewSyncLogger(ioutil.Discard, "", 0)
+
+	// The handshake is retried until ctx is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	conn, err := RetryDialTLS(ctx, "tcp://"+l.Addr().String(), cfg, NewBackoff(10*time.Millisecond, 10*time.Millisecond, 1), logger)
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, ErrAbortDial)
+}
+
+func TestRetryDialTLS_Expiry(t *testing.T) {
+	defer func(c types.Clock) { Clock = c }(Clock)
+	clock := types.NewFakeClock(time.Now())
+	Clock = clock
+
+	ca := newTestCA(t)
+	l := startTLSServer(t, ca)
+	notAfter := time.Now().Add(time.Hour)
+	cfg := writeTLSConfig(t, t.TempDir(), ca, notAfter)
+	logger := types.NewSyncLogger(ioutil.Discard, "", 0)
+
+	conn, err := RetryDialTLS(context.Background(), "tcp://"+l.Addr().String(), cfg, nil, logger)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The connection is closed once the client certificate expires, and reads return
+	// io.EOF, so that it is dialed again with the renewed certificate.
+	clock.BlockUntil(1)
+	clock.Advance(time.Until(notAfter) + time.Second)
+	_, err = conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
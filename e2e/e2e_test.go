@@ -0,0 +1,90 @@
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_log "github.com/tendermint/tendermint/libs/log"
+)
+
+// testNetwork starts a new network that is stopped once the test is done. Set
+// E2E_LOG=1 to see the validators' logs.
+func testNetwork(t *testing.T) *Network {
+	t.Helper()
+	opts := NetworkOptions{Dir: t.TempDir()}
+	if os.Getenv("E2E_LOG") != "" {
+		opts.Logger = tm_log.NewTMLogger(tm_log.NewSyncWriter(os.Stdout))
+	}
+	n, err := NewNetwork(opts)
+	require.NoError(t, err)
+	require.NoError(t, n.Start())
+	t.Cleanup(n.Stop)
+
+	return n
+}
+
+func TestSigning(t *testing.T) {
+	n := testNetwork(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	v, err := n.AddValidator("validator-1", 1)
+	require.NoError(t, err)
+
+	// The set's key signs blocks once the validator caught up.
+	first, err := n.WaitForSigned(ctx, 0)
+	require.NoError(t, err)
+	require.NoError(t, n.WaitForHeight(ctx, first+6))
+	for h := first; h < first+5; h++ {
+		assert.True(t, n.SignedBy(h), "block %v not signed", h)
+	}
+	assert.Equal(t, 1, v.SignCTRL.Status().Rank)
+	assert.Empty(t, n.Evidence())
+}
+
+func TestFailover(t *testing.T) {
+	n := testNetwork(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	primary, err := n.AddValidator("validator-1", 1)
+	require.NoError(t, err)
+	backup, err := n.AddValidator("validator-2", 2)
+	require.NoError(t, err)
+
+	// Only the primary signs, the backup refuses to.
+	first, err := n.WaitForSigned(ctx, 0)
+	require.NoError(t, err)
+	require.NoError(t, n.WaitForHeight(ctx, first+4))
+	assert.Equal(t, 2, backup.SignCTRL.Status().Rank)
+
+	// The backup takes over once the primary's SignCTRL node is gone.
+	require.NoError(t, primary.StopSignCTRL())
+	stopped := n.Height()
+	resumed, err := n.WaitForSigned(ctx, stopped+1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, backup.SignCTRL.Status().Rank)
+	assert.GreaterOrEqual(t, resumed-stopped, int64(n.Options.Threshold))
+
+	// The restarted primary must not sign alongside the backup, as its rank became
+	// obsolete. It shuts itself down instead.
+	require.NoError(t, n.Restart(primary))
+	select {
+	case <-primary.SignCTRL.Done():
+	case <-ctx.Done():
+		t.Fatal("the restarted primary didn't shut down")
+	}
+
+	// The backup keeps signing, and nobody double-signed.
+	last, err := n.WaitForSigned(ctx, n.Height())
+	require.NoError(t, err)
+	assert.Greater(t, last, resumed)
+	assert.Empty(t, n.Evidence())
+}
@@ -0,0 +1,491 @@
+// Package e2e runs SignCTRL sets against real Tendermint validators. The validators
+// run in-process and speak the actual privval protocol, so that signing, failover and
+// the protection against double-signing are tested end to end.
+//
+// A network consists of an anchor validator, which holds the majority of the voting
+// power and keeps the chain going on its own, and of the validators of a SignCTRL set,
+// which share a single validator key. The tests are run with:
+//
+//	go test -tags e2e ./e2e
+package e2e
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl"
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_kvstore "github.com/tendermint/tendermint/abci/example/kvstore"
+	tm_cfg "github.com/tendermint/tendermint/config"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_log "github.com/tendermint/tendermint/libs/log"
+	tm_node "github.com/tendermint/tendermint/node"
+	tm_p2p "github.com/tendermint/tendermint/p2p"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_proxy "github.com/tendermint/tendermint/proxy"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+const (
+	// anchorPower and setPower are the voting powers of the anchor validator and the
+	// SignCTRL set. The anchor holds more than 2/3 of the voting power, so that the
+	// chain keeps going while the set fails over.
+	anchorPower = 100
+	setPower    = 10
+
+	// timeoutCommit is the time the validators wait for late precommits after a
+	// block was committed. It must be long enough for the set's precommits to be
+	// included in the next block, as they count as missed otherwise.
+	timeoutCommit = 500 * time.Millisecond
+
+	// timeoutPropose is the time the validators wait for a proposal before they
+	// prevote nil.
+	timeoutPropose = 2 * time.Second
+
+	// anchorVoteDelay is the time the anchor validator waits before it votes. The
+	// anchor commits blocks on its own, so without the delay its votes may reach the
+	// set before the proposal or its previous votes do, in which case the set
+	// precommits nil.
+	anchorVoteDelay = 100 * time.Millisecond
+
+	// signerTimeout is the time a validator waits for SignCTRL to connect.
+	signerTimeout = 10 * time.Second
+
+	// signerRetries is the number of times a validator sends a request to SignCTRL
+	// before giving up. Each attempt waits for SignCTRL to connect for 3s, during
+	// which the validator's consensus is blocked, so validators whose SignCTRL node
+	// is gone must give up quickly to be stopped in time.
+	signerRetries = 1
+)
+
+var (
+	// ErrNotSigned is returned if the set's key didn't sign a block in time.
+	ErrNotSigned = errors.New("block not signed by the set")
+)
+
+// NetworkOptions defines the options of a network.
+type NetworkOptions struct {
+	// Dir is the directory the validators and SignCTRL nodes keep their files in.
+	Dir string
+
+	// ChainID is the chain's ID. Defaults to "e2e".
+	ChainID string
+
+	// SetSize is the size of the SignCTRL set. Defaults to 2.
+	SetSize int
+
+	// Threshold is the number of blocks missed in a row after which the next node
+	// in the set is promoted. Defaults to 3.
+	Threshold int
+
+	// Logger is the logger of the validators. Logs are discarded if nil.
+	Logger tm_log.Logger
+
+	// SignCTRLLogger is the logger of the SignCTRL nodes. Logs are discarded if nil.
+	SignCTRLLogger types.Logger
+}
+
+// Network is a chain of in-process Tendermint validators.
+type Network struct {
+	Options NetworkOptions
+
+	// Key is the validator key shared by the SignCTRL set.
+	Key tm_ed25519.PrivKey
+
+	genesis   *tm_types.GenesisDoc
+	anchor    *tm_node.Node
+	anchorKey *tm_p2p.NodeKey
+	anchorP2P string
+	anchorRPC string
+
+	mtx        sync.Mutex
+	validators []*Validator
+}
+
+// Validator is a Tendermint validator that signs via a SignCTRL node.
+type Validator struct {
+	Name     string
+	Dir      string
+	Rank     int
+	Node     *tm_node.Node
+	SignCTRL *signctrl.Node
+
+	laddr  string
+	signer *tm_privval.SignerClient
+	cancel context.CancelFunc
+}
+
+// freeAddress returns a free TCP address on the loopback interface.
+func freeAddress() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+
+	return l.Addr().String(), nil
+}
+
+// NewNetwork creates a new network and generates the validator keys and the genesis
+// file. The anchor validator is started with Start.
+func NewNetwork(opts NetworkOptions) (*Network, error) {
+	if opts.ChainID == "" {
+		opts.ChainID = "e2e"
+	}
+	if opts.SetSize == 0 {
+		opts.SetSize = 2
+	}
+	if opts.Threshold == 0 {
+		opts.Threshold = 3
+	}
+	if opts.Logger == nil {
+		opts.Logger = tm_log.NewNopLogger()
+	}
+
+	n := &Network{
+		Options:   opts,
+		Key:       tm_ed25519.GenPrivKey(),
+		anchorKey: &tm_p2p.NodeKey{PrivKey: tm_ed25519.GenPrivKey()},
+	}
+	anchorPV := tm_ed25519.GenPrivKey()
+	n.genesis = &tm_types.GenesisDoc{
+		ChainID:         opts.ChainID,
+		GenesisTime:     time.Now(),
+		ConsensusParams: tm_types.DefaultConsensusParams(),
+		Validators: []tm_types.GenesisValidator{
+			{Address: anchorPV.PubKey().Address(), PubKey: anchorPV.PubKey(), Power: anchorPower, Name: "anchor"},
+			{Address: n.Key.PubKey().Address(), PubKey: n.Key.PubKey(), Power: setPower, Name: "signctrl"},
+		},
+	}
+
+	var err error
+	if n.anchorP2P, err = freeAddress(); err != nil {
+		return nil, err
+	}
+	if n.anchorRPC, err = freeAddress(); err != nil {
+		return nil, err
+	}
+	cfg, err := n.nodeConfig("anchor", n.anchorP2P)
+	if err != nil {
+		return nil, err
+	}
+	cfg.RPC.ListenAddress = "tcp://" + n.anchorRPC
+	filePV := tm_privval.NewFilePV(anchorPV, cfg.PrivValidatorKeyFile(), cfg.PrivValidatorStateFile())
+	filePV.Save()
+	if n.anchor, err = n.newNode(cfg, n.anchorKey, &delayedPV{PrivValidator: filePV, delay: anchorVoteDelay}); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// nodeConfig creates the configuration of the validator with the given name, which
+// listens for peers on the given address.
+func (n *Network) nodeConfig(name, p2pAddr string) (*tm_cfg.Config, error) {
+	dir := filepath.Join(n.Options.Dir, name)
+	tm_cfg.EnsureRoot(dir)
+
+	cfg := tm_cfg.TestConfig()
+	cfg.SetRoot(dir)
+	cfg.Moniker = name
+	cfg.P2P.ListenAddress = "tcp://" + p2pAddr
+	cfg.P2P.AllowDuplicateIP = true
+	cfg.P2P.AddrBookStrict = false
+	cfg.Consensus.TimeoutCommit = timeoutCommit
+	cfg.Consensus.SkipTimeoutCommit = false
+	cfg.Consensus.TimeoutPropose = timeoutPropose
+
+	// Tendermint's RPC server keeps its state in package-level variables, so only
+	// the anchor validator may serve RPC requests within the same process. The
+	// SignCTRL nodes query their blocks from it.
+	cfg.RPC.ListenAddress = ""
+	if name != "anchor" {
+		cfg.P2P.PersistentPeers = fmt.Sprintf("%v@%v", n.anchorKey.ID(), n.anchorP2P)
+	}
+
+	if err := n.genesis.SaveAs(cfg.GenesisFile()); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// newNode creates a new validator that signs with the given private validator.
+func (n *Network) newNode(cfg *tm_cfg.Config, nodeKey *tm_p2p.NodeKey, pv tm_types.PrivValidator) (*tm_node.Node, error) {
+	return tm_node.NewNode(
+		cfg,
+		pv,
+		nodeKey,
+		tm_proxy.NewLocalClientCreator(tm_kvstore.NewApplication()),
+		tm_node.DefaultGenesisDocProviderFunc(cfg),
+		tm_node.DefaultDBProvider,
+		tm_node.DefaultMetricsProvider(cfg.Instrumentation),
+		n.Options.Logger.With("validator", cfg.Moniker),
+	)
+}
+
+// Start starts the anchor validator.
+func (n *Network) Start() error {
+	return n.anchor.Start()
+}
+
+// Stop stops all validators and SignCTRL nodes that are still running.
+func (n *Network) Stop() {
+	n.mtx.Lock()
+	validators := n.validators
+	n.mtx.Unlock()
+
+	for _, v := range validators {
+		_ = v.Stop()
+	}
+	if n.anchor.IsRunning() {
+		_ = n.anchor.Stop()
+		n.anchor.Wait()
+	}
+}
+
+// RPCAddress returns the address of the anchor validator's RPC server.
+func (n *Network) RPCAddress() string {
+	return "tcp://" + n.anchorRPC
+}
+
+// Address returns the address of the set's validator key.
+func (n *Network) Address() tm_crypto.Address {
+	return n.Key.PubKey().Address()
+}
+
+// Height returns the height of the latest block committed by the anchor validator.
+func (n *Network) Height() int64 {
+	return n.anchor.BlockStore().Height()
+}
+
+// WaitForHeight waits until the anchor validator committed the block at the given
+// height or ctx is done.
+func (n *Network) WaitForHeight(ctx context.Context, height int64) error {
+	for n.Height() < height {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for height %v at height %v: %w", height, n.Height(), ctx.Err())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	return nil
+}
+
+// SignedBy returns true if the block at the given height was signed with the set's
+// key. The block's commit is only known once the next block is committed.
+func (n *Network) SignedBy(height int64) bool {
+	commit := n.anchor.BlockStore().LoadBlockCommit(height)
+	if commit == nil {
+		return false
+	}
+	for _, sig := range commit.Signatures {
+		if sig.ForBlock() && sig.ValidatorAddress.String() == n.Address().String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WaitForSigned waits until a block after the given height was signed with the set's
+// key and returns its height.
+func (n *Network) WaitForSigned(ctx context.Context, after int64) (int64, error) {
+	for h := after + 1; ; {
+		if err := n.WaitForHeight(ctx, h+1); err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrNotSigned, err)
+		}
+		if n.SignedBy(h) {
+			return h, nil
+		}
+		h++
+	}
+}
+
+// Evidence returns the evidence of misbehavior that was committed to the chain or is
+// pending in the anchor validator's evidence pool.
+func (n *Network) Evidence() []tm_types.Evidence {
+	var evidence []tm_types.Evidence
+	store := n.anchor.BlockStore()
+	for h := store.Base(); h <= store.Height(); h++ {
+		if block := store.LoadBlock(h); block != nil {
+			evidence = append(evidence, block.Evidence.Evidence...)
+		}
+	}
+	pending, _ := n.anchor.EvidencePool().PendingEvidence(n.genesis.ConsensusParams.Evidence.MaxBytes)
+
+	return append(evidence, pending...)
+}
+
+// AddValidator adds a validator to the network whose SignCTRL node starts on the
+// given rank. The SignCTRL node is started first, and the validator is started once
+// SignCTRL connected to it.
+func (n *Network) AddValidator(name string, rank int) (*Validator, error) {
+	p2pAddr, err := freeAddress()
+	if err != nil {
+		return nil, err
+	}
+	signerAddr, err := freeAddress()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := n.nodeConfig(name, p2pAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Listen for SignCTRL before it is started, so that it connects right away.
+	listener, err := tm_privval.NewSignerListener("tcp://"+signerAddr, n.Options.Logger.With("validator", name))
+	if err != nil {
+		return nil, err
+	}
+	signer, err := tm_privval.NewSignerClient(listener, n.Options.ChainID)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Validator{
+		Name:   name,
+		Dir:    filepath.Join(n.Options.Dir, name, "signctrl"),
+		Rank:   rank,
+		laddr:  "tcp://" + signerAddr,
+		signer: signer,
+	}
+	if err := os.MkdirAll(v.Dir, config.PermConfigDir); err != nil {
+		return nil, err
+	}
+	if err := connection.CreateBase64ConnKey(v.Dir); err != nil {
+		return nil, err
+	}
+
+	// All nodes in the set sign with the same key.
+	tm_privval.NewFilePV(n.Key, privval.KeyFilePath(v.Dir), privval.StateFilePath(v.Dir)).Save()
+	if err := n.startSignCTRL(v, config.State{LastHeight: 1, LastRank: rank}); err != nil {
+		signer.Close()
+		return nil, err
+	}
+	if err := signer.WaitForConnection(signerTimeout); err != nil {
+		_ = v.Stop()
+		return nil, fmt.Errorf("SignCTRL didn't connect to %v: %w", name, err)
+	}
+
+	nodeKey := &tm_p2p.NodeKey{PrivKey: tm_ed25519.GenPrivKey()}
+	if v.Node, err = n.newNode(cfg, nodeKey, tm_privval.NewRetrySignerClient(signer, signerRetries, 100*time.Millisecond)); err != nil {
+		_ = v.Stop()
+		return nil, err
+	}
+	if err := v.Node.Start(); err != nil {
+		_ = v.Stop()
+		return nil, err
+	}
+
+	n.mtx.Lock()
+	n.validators = append(n.validators, v)
+	n.mtx.Unlock()
+
+	return v, nil
+}
+
+// startSignCTRL starts the validator's SignCTRL node from the given state.
+func (n *Network) startSignCTRL(v *Validator, state config.State) error {
+	node, err := signctrl.New(signctrl.Options{
+		Config: config.Config{
+			Base: config.Base{
+				LogLevel:                  "INFO",
+				SetSize:                   n.Options.SetSize,
+				Threshold:                 n.Options.Threshold,
+				StartRank:                 v.Rank,
+				ValidatorListenAddress:    v.laddr,
+				ValidatorListenAddressRPC: n.RPCAddress(),
+				RetryDialAfter:            "10s",
+			},
+			Privval: config.PrivValidator{
+				ChainID: n.Options.ChainID,
+			},
+		},
+		State:         state,
+		CfgDir:        v.Dir,
+		PrivValidator: tm_privval.LoadFilePV(privval.KeyFilePath(v.Dir), privval.StateFilePath(v.Dir)),
+		Logger:        n.Options.SignCTRLLogger,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := node.Start(ctx); err != nil {
+		cancel()
+		return err
+	}
+	v.SignCTRL, v.cancel = node, cancel
+
+	return nil
+}
+
+// Restart restarts the validator's SignCTRL node from the state it saved when it was
+// stopped, the way the signctrl binary would after a restart of the machine.
+func (n *Network) Restart(v *Validator) error {
+	if v.SignCTRL != nil && v.SignCTRL.IsRunning() {
+		return errors.New("SignCTRL is still running")
+	}
+	state, err := config.LoadOrGenState(v.Dir)
+	if err != nil {
+		return err
+	}
+
+	return n.startSignCTRL(v, state)
+}
+
+// StopSignCTRL stops the validator's SignCTRL node, e.g. to simulate a crash.
+func (v *Validator) StopSignCTRL() error {
+	if v.SignCTRL == nil || !v.SignCTRL.IsRunning() {
+		return nil
+	}
+	err := v.SignCTRL.Stop()
+	v.cancel()
+	<-v.SignCTRL.Done()
+
+	return err
+}
+
+// Stop stops the validator and its SignCTRL node. The validator is stopped first, as
+// it may be waiting for a response from SignCTRL.
+func (v *Validator) Stop() error {
+	var err error
+	if v.Node != nil && v.Node.IsRunning() {
+		err = v.Node.Stop()
+		v.Node.Wait()
+	}
+	if stopErr := v.StopSignCTRL(); err == nil {
+		err = stopErr
+	}
+	_ = v.signer.Close()
+
+	return err
+}
+
+// delayedPV is a private validator that waits for the given delay before it signs a
+// vote.
+type delayedPV struct {
+	tm_types.PrivValidator
+	delay time.Duration
+}
+
+// SignVote signs the given vote.
+func (pv *delayedPV) SignVote(chainID string, vote *tm_typesproto.Vote) error {
+	time.Sleep(pv.delay)
+
+	return pv.PrivValidator.SignVote(chainID, vote)
+}
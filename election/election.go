@@ -0,0 +1,487 @@
+// Package election implements the leader election of Raft (Ongaro and Ousterhout,
+// "In Search of an Understandable Consensus Algorithm") for the SignCTRL nodes in a
+// set, so that exactly one of them signs. There is no log to replicate, so only the
+// terms, votes and heartbeats of Raft are used.
+//
+// A signer must never overlap with the next one, which Raft alone doesn't guarantee,
+// as a deposed leader only learns about its successor once it hears from it. Thus,
+// the leader holds a lease: it only considers itself the leader until the minimum
+// election timeout has passed since it sent the last round of heartbeats a majority
+// of the set acknowledged, minus a safety margin. The followers in turn don't vote
+// for another candidate within the minimum election timeout after they last heard
+// from the leader or granted their vote, so that no successor can be elected before
+// the lease expires. The term and vote of each node are persisted before a vote is
+// granted, so that a node never votes twice in a term, even across restarts.
+package election
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+const (
+	// TypeVote is the message type of vote requests.
+	TypeVote = "election_vote"
+
+	// TypeHeartbeat is the message type of the leader's heartbeats.
+	TypeHeartbeat = "election_heartbeat"
+
+	// PermStateFile determines the file permissions of the state file.
+	PermStateFile = os.FileMode(0600)
+
+	// leaseMargin is the share of the minimum election timeout the lease is shortened
+	// by, which covers the clocks of the nodes running at slightly different rates.
+	leaseMargin = 10
+)
+
+// Role is the role of a node in the election.
+type Role string
+
+const (
+	// Follower nodes follow the leader and vote for candidates.
+	Follower Role = "follower"
+
+	// Candidate nodes ask the other nodes for their votes to become the leader.
+	Candidate Role = "candidate"
+
+	// Leader is the node elected to sign.
+	Leader Role = "leader"
+)
+
+// Transport sends requests to the other nodes in the set. It is implemented by
+// p2p.Node.
+type Transport interface {
+	Request(ctx context.Context, addr string, typ string, req, resp interface{}) error
+}
+
+// VoteRequest is sent by candidates to ask for the votes of the other nodes.
+type VoteRequest struct {
+	Term      uint64 `json:"term"`
+	Candidate string `json:"candidate"`
+}
+
+// VoteResponse is the response to a VoteRequest.
+type VoteResponse struct {
+	Term    uint64 `json:"term"`
+	Granted bool   `json:"granted"`
+}
+
+// HeartbeatRequest is sent by the leader to assert its leadership.
+type HeartbeatRequest struct {
+	Term   uint64 `json:"term"`
+	Leader string `json:"leader"`
+}
+
+// HeartbeatResponse is the response to a HeartbeatRequest.
+type HeartbeatResponse struct {
+	Term uint64 `json:"term"`
+	Ack  bool   `json:"ack"`
+}
+
+// Config defines the configuration of the election.
+type Config struct {
+	// Name is the node's name. It must be unique within the set.
+	Name string
+
+	// Peers are the addresses of the other nodes in the set.
+	Peers []string
+
+	// ElectionTimeout is the minimum election timeout. A follower starts an election
+	// if it didn't hear from the leader for a random time between the election
+	// timeout and twice the election timeout.
+	ElectionTimeout time.Duration
+
+	// HeartbeatInterval is the interval in which the leader sends heartbeats. It must
+	// be well below the election timeout.
+	HeartbeatInterval time.Duration
+
+	// StateFile is the path to the file the node's term and vote are persisted in.
+	StateFile string
+}
+
+// persistentState is the state that is persisted before a vote is granted.
+type persistentState struct {
+	Term     uint64 `json:"term"`
+	VotedFor string `json:"voted_for"`
+}
+
+// Status is a snapshot of the node's election state.
+type Status struct {
+	// Role is the node's role. A leader whose lease expired is reported as follower.
+	Role Role `json:"role"`
+
+	// Term is the node's current term.
+	Term uint64 `json:"term"`
+
+	// Leader is the name of the leader of the current term, if known.
+	Leader string `json:"leader,omitempty"`
+}
+
+// Election is a node taking part in the leader election of the set.
+type Election struct {
+	cfg       Config
+	transport Transport
+	clock     types.Clock
+	logger    types.Logger
+
+	// OnChange is called whenever the node gains or loses the leadership, but never
+	// while the election's state is locked. An expired lease is only reported in the
+	// next iteration of Run, while IsLeader observes it right away.
+	OnChange func(leader bool)
+
+	mtx         sync.Mutex // guards the fields below
+	state       persistentState
+	role        Role
+	leader      string
+	lastContact time.Time // last time the node heard from the leader or granted its vote
+	leaseUntil  time.Time
+	timeout     time.Duration // randomized election timeout
+	rand        *rand.Rand
+}
+
+// New creates a new node taking part in the election and loads its persisted state.
+// The node starts as a follower and doesn't grant any votes within the election
+// timeout, so that it can't cut the lease of a leader short after a restart.
+func New(cfg Config, transport Transport, clock types.Clock, logger types.Logger) (*Election, error) {
+	if logger == nil {
+		logger = types.NewSyncLogger(ioutil.Discard, "", 0)
+	}
+	e := &Election{
+		cfg:         cfg,
+		transport:   transport,
+		clock:       clock,
+		logger:      logger,
+		role:        Follower,
+		lastContact: clock.Now(),
+		rand:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	e.timeout = e.randomTimeout()
+
+	bz, err := ioutil.ReadFile(cfg.StateFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if err == nil {
+		if err := json.Unmarshal(bz, &e.state); err != nil {
+			return nil, fmt.Errorf("couldn't parse %v: %w", cfg.StateFile, err)
+		}
+	}
+
+	return e, nil
+}
+
+// randomTimeout returns a random election timeout between the minimum election
+// timeout and twice that, so that the nodes rarely start elections at the same time.
+// The state must be locked.
+func (e *Election) randomTimeout() time.Duration {
+	return e.cfg.ElectionTimeout + time.Duration(e.rand.Int63n(int64(e.cfg.ElectionTimeout)))
+}
+
+// lease returns the time the leader may consider itself the leader for after it sent
+// a round of heartbeats or vote requests a majority responded to.
+func (e *Election) lease() time.Duration {
+	return e.cfg.ElectionTimeout - e.cfg.ElectionTimeout/leaseMargin
+}
+
+// majority returns the number of nodes that make up a majority of the set.
+func (e *Election) majority() int {
+	return (len(e.cfg.Peers)+1)/2 + 1
+}
+
+// persist writes the node's term and vote to the state file. The state must be
+// locked.
+func (e *Election) persist() error {
+	bz, err := json.Marshal(e.state)
+	if err != nil {
+		return err
+	}
+	tmp := e.cfg.StateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, bz, PermStateFile); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, e.cfg.StateFile)
+}
+
+// IsLeader returns true if the node is the leader and its lease hasn't expired.
+func (e *Election) IsLeader() bool {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	return e.role == Leader && e.clock.Now().Before(e.leaseUntil)
+}
+
+// Status returns a snapshot of the node's election state.
+func (e *Election) Status() Status {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	role := e.role
+	if role == Leader && !e.clock.Now().Before(e.leaseUntil) {
+		role = Follower
+	}
+
+	return Status{Role: role, Term: e.state.Term, Leader: e.leader}
+}
+
+// stepDown makes the node a follower of the given term. The state must be locked. It
+// returns true if the node was the leader.
+func (e *Election) stepDown(term uint64) (bool, error) {
+	wasLeader := e.role == Leader
+	e.role = Follower
+	e.leaseUntil = time.Time{}
+	if term > e.state.Term {
+		e.state = persistentState{Term: term}
+		e.leader = ""
+		if err := e.persist(); err != nil {
+			return wasLeader, err
+		}
+	}
+
+	return wasLeader, nil
+}
+
+// changed calls OnChange, if set.
+func (e *Election) changed(leader bool) {
+	if e.OnChange != nil {
+		e.OnChange(leader)
+	}
+}
+
+// HandleVote handles a candidate's vote request.
+func (e *Election) HandleVote(req VoteRequest) (VoteResponse, error) {
+	e.mtx.Lock()
+	now := e.clock.Now()
+
+	// Don't vote while the leader may still hold a lease, or right after voting.
+	// The term isn't updated either, so that the leader isn't deposed.
+	if now.Sub(e.lastContact) < e.cfg.ElectionTimeout || (e.role == Leader && now.Before(e.leaseUntil)) {
+		resp := VoteResponse{Term: e.state.Term}
+		e.mtx.Unlock()
+		return resp, nil
+	}
+	if req.Term < e.state.Term {
+		resp := VoteResponse{Term: e.state.Term}
+		e.mtx.Unlock()
+		return resp, nil
+	}
+
+	wasLeader, err := e.stepDown(req.Term)
+	if err == nil && (e.state.VotedFor == "" || e.state.VotedFor == req.Candidate) {
+		e.state.VotedFor = req.Candidate
+		if err = e.persist(); err == nil {
+			e.lastContact = now
+			e.timeout = e.randomTimeout()
+		}
+	}
+	resp := VoteResponse{Term: e.state.Term, Granted: err == nil && e.state.VotedFor == req.Candidate}
+	e.mtx.Unlock()
+
+	if wasLeader {
+		e.changed(false)
+	}
+	if resp.Granted {
+		e.logger.Info("Voted for %v in term %v", req.Candidate, req.Term)
+	}
+
+	return resp, err
+}
+
+// HandleHeartbeat handles a heartbeat of the leader.
+func (e *Election) HandleHeartbeat(req HeartbeatRequest) (HeartbeatResponse, error) {
+	e.mtx.Lock()
+	if req.Term < e.state.Term {
+		resp := HeartbeatResponse{Term: e.state.Term}
+		e.mtx.Unlock()
+		return resp, nil
+	}
+
+	wasLeader, err := e.stepDown(req.Term)
+	if err != nil {
+		e.mtx.Unlock()
+		return HeartbeatResponse{}, err
+	}
+	newLeader := e.leader != req.Leader
+	e.leader = req.Leader
+	e.lastContact = e.clock.Now()
+	e.timeout = e.randomTimeout()
+	resp := HeartbeatResponse{Term: e.state.Term, Ack: true}
+	e.mtx.Unlock()
+
+	if wasLeader {
+		e.changed(false)
+	}
+	if newLeader {
+		e.logger.Info("Following %v in term %v", req.Leader, req.Term)
+	}
+
+	return resp, nil
+}
+
+// broadcast sends req to all peers in parallel and returns their responses, which are
+// decoded into values created by newResp. Peers that don't respond within the
+// heartbeat interval are left out.
+func (e *Election) broadcast(ctx context.Context, typ string, req interface{}, newResp func() interface{}) []interface{} {
+	ctx, cancel := context.WithTimeout(ctx, e.cfg.HeartbeatInterval)
+	defer cancel()
+
+	var (
+		wg        sync.WaitGroup
+		mtx       sync.Mutex
+		responses []interface{}
+	)
+	wg.Add(len(e.cfg.Peers))
+	for _, addr := range e.cfg.Peers {
+		go func(addr string) {
+			defer wg.Done()
+			resp := newResp()
+			if err := e.transport.Request(ctx, addr, typ, req, resp); err != nil {
+				if ctx.Err() == nil {
+					e.logger.Debug("Couldn't send %v to %v: %v", typ, addr, err)
+				}
+				return
+			}
+			mtx.Lock()
+			responses = append(responses, resp)
+			mtx.Unlock()
+		}(addr)
+	}
+	wg.Wait()
+
+	return responses
+}
+
+// campaign starts an election in the next term and makes the node the leader if a
+// majority votes for it.
+func (e *Election) campaign(ctx context.Context) error {
+	e.mtx.Lock()
+	sent := e.clock.Now()
+	e.role = Candidate
+	e.state = persistentState{Term: e.state.Term + 1, VotedFor: e.cfg.Name}
+	e.leader = ""
+	e.lastContact = sent
+	e.timeout = e.randomTimeout()
+	term := e.state.Term
+	if err := e.persist(); err != nil {
+		e.role = Follower
+		e.mtx.Unlock()
+		return err
+	}
+	e.mtx.Unlock()
+	e.logger.Debug("Starting election for term %v", term)
+
+	votes := 1
+	responses := e.broadcast(ctx, TypeVote, VoteRequest{Term: term, Candidate: e.cfg.Name}, func() interface{} { return new(VoteResponse) })
+	e.mtx.Lock()
+	for _, r := range responses {
+		resp := r.(*VoteResponse)
+		if resp.Term > e.state.Term {
+			_, err := e.stepDown(resp.Term)
+			e.mtx.Unlock()
+			return err
+		}
+		if resp.Granted && resp.Term == term {
+			votes++
+		}
+	}
+	won := votes >= e.majority() && e.role == Candidate && e.state.Term == term
+	if won {
+		e.role = Leader
+		e.leader = e.cfg.Name
+		e.leaseUntil = sent.Add(e.lease())
+	}
+	e.mtx.Unlock()
+
+	if won {
+		e.logger.Info("Elected as leader for term %v with %v of %v votes", term, votes, len(e.cfg.Peers)+1)
+		e.changed(true)
+	}
+
+	return nil
+}
+
+// assert sends a round of heartbeats to the peers and extends the lease if a majority
+// acknowledged them. The node steps down if it learns about a newer term.
+func (e *Election) assert(ctx context.Context) error {
+	e.mtx.Lock()
+	sent := e.clock.Now()
+	term := e.state.Term
+	e.mtx.Unlock()
+
+	acks := 1
+	responses := e.broadcast(ctx, TypeHeartbeat, HeartbeatRequest{Term: term, Leader: e.cfg.Name}, func() interface{} { return new(HeartbeatResponse) })
+	e.mtx.Lock()
+	for _, r := range responses {
+		resp := r.(*HeartbeatResponse)
+		if resp.Term > e.state.Term {
+			wasLeader, err := e.stepDown(resp.Term)
+			e.mtx.Unlock()
+			if wasLeader {
+				e.logger.Warn("Stepped down as leader, a newer term %v started", resp.Term)
+				e.changed(false)
+			}
+			return err
+		}
+		if resp.Ack && resp.Term == term {
+			acks++
+		}
+	}
+	if e.role == Leader && e.state.Term == term && acks >= e.majority() {
+		e.leaseUntil = sent.Add(e.lease())
+	}
+	e.mtx.Unlock()
+
+	return nil
+}
+
+// step runs one iteration of the election: the leader asserts its leadership, or
+// steps down once its lease expired, and the other nodes start an election if they
+// didn't hear from the leader for their election timeout.
+func (e *Election) step(ctx context.Context) error {
+	e.mtx.Lock()
+	now := e.clock.Now()
+	role := e.role
+	expired := role == Leader && !now.Before(e.leaseUntil)
+	if expired {
+		e.role = Follower
+		e.lastContact = now
+		e.timeout = e.randomTimeout()
+	}
+	due := role != Leader && now.Sub(e.lastContact) >= e.timeout
+	e.mtx.Unlock()
+
+	switch {
+	case expired:
+		e.logger.Warn("Stepped down as leader, a majority of the set didn't acknowledge the heartbeats")
+		e.changed(false)
+		return nil
+	case role == Leader:
+		return e.assert(ctx)
+	case due:
+		return e.campaign(ctx)
+	}
+
+	return nil
+}
+
+// Run takes part in the election until ctx is done.
+func (e *Election) Run(ctx context.Context) {
+	for {
+		if err := e.step(ctx); err != nil {
+			e.logger.Error("couldn't persist the election state: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.clock.After(e.cfg.HeartbeatInterval):
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package election
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testElectionTimeout   = 3 * time.Second
+	testHeartbeatInterval = time.Second
+)
+
+// testTransport delivers requests to the elections of a test set in memory. Nodes
+// that are down neither send nor receive anything.
+type testTransport struct {
+	from  string
+	nodes map[string]*Election
+	mtx   *sync.Mutex
+	down  map[string]bool
+}
+
+// Request implements the Transport interface.
+func (t testTransport) Request(ctx context.Context, addr string, typ string, req, resp interface{}) error {
+	t.mtx.Lock()
+	down := t.down[t.from] || t.down[addr]
+	t.mtx.Unlock()
+	if down {
+		return errors.New("unreachable")
+	}
+
+	bz, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	var out interface{}
+	switch typ {
+	case TypeVote:
+		var r VoteRequest
+		_ = json.Unmarshal(bz, &r)
+		out, err = t.nodes[addr].HandleVote(r)
+	case TypeHeartbeat:
+		var r HeartbeatRequest
+		_ = json.Unmarshal(bz, &r)
+		out, err = t.nodes[addr].HandleHeartbeat(r)
+	}
+	if err != nil {
+		return err
+	}
+	bz, _ = json.Marshal(out)
+
+	return json.Unmarshal(bz, resp)
+}
+
+// testSet creates a set of n nodes sharing a fake clock.
+func testSet(t *testing.T, n int) ([]*Election, *types.FakeClock, func(name string, down bool)) {
+	t.Helper()
+	clock := types.NewFakeClock(time.Unix(1000, 0))
+	nodes := make(map[string]*Election)
+	mtx := new(sync.Mutex)
+	down := make(map[string]bool)
+	dir := t.TempDir()
+
+	var set []*Election
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("node-%v", i)
+		var peers []string
+		for j := 0; j < n; j++ {
+			if j != i {
+				peers = append(peers, fmt.Sprintf("node-%v", j))
+			}
+		}
+		e, err := New(Config{
+			Name:              name,
+			Peers:             peers,
+			ElectionTimeout:   testElectionTimeout,
+			HeartbeatInterval: testHeartbeatInterval,
+			StateFile:         filepath.Join(dir, name+".json"),
+		}, testTransport{from: name, nodes: nodes, mtx: mtx, down: down}, clock, nil)
+		require.NoError(t, err)
+		nodes[name] = e
+		set = append(set, e)
+	}
+
+	return set, clock, func(name string, d bool) {
+		mtx.Lock()
+		down[name] = d
+		mtx.Unlock()
+	}
+}
+
+// leaders returns the names of the nodes that consider themselves the leader.
+func leaders(set []*Election) []string {
+	var names []string
+	for _, e := range set {
+		if e.IsLeader() {
+			names = append(names, e.cfg.Name)
+		}
+	}
+	return names
+}
+
+// runFor steps all nodes of the set once per heartbeat interval for the given time,
+// and fails if more than one node considers itself the leader at any time.
+func runFor(t *testing.T, set []*Election, clock *types.FakeClock, d time.Duration) {
+	t.Helper()
+	for elapsed := time.Duration(0); elapsed < d; elapsed += testHeartbeatInterval {
+		for _, e := range set {
+			require.NoError(t, e.step(context.Background()))
+			require.LessOrEqual(t, len(leaders(set)), 1, "more than one leader")
+		}
+		clock.Advance(testHeartbeatInterval)
+	}
+}
+
+func TestElection_ElectsOneLeader(t *testing.T) {
+	set, clock, _ := testSet(t, 3)
+	assert.Empty(t, leaders(set))
+
+	runFor(t, set, clock, 10*testElectionTimeout)
+	require.Len(t, leaders(set), 1)
+
+	leader := leaders(set)[0]
+	for _, e := range set {
+		assert.Equal(t, leader, e.Status().Leader)
+	}
+}
+
+func TestElection_Reelects(t *testing.T) {
+	set, clock, setDown := testSet(t, 3)
+	runFor(t, set, clock, 10*testElectionTimeout)
+	require.Len(t, leaders(set), 1)
+	old := leaders(set)[0]
+
+	// The leader is cut off from the set, so it steps down and another node is
+	// elected.
+	setDown(old, true)
+	runFor(t, set, clock, 10*testElectionTimeout)
+	require.Len(t, leaders(set), 1)
+	assert.NotEqual(t, old, leaders(set)[0])
+
+	// The set agrees on a single leader again once the old leader is back, which may
+	// have moved on to a newer term in the meantime.
+	setDown(old, false)
+	runFor(t, set, clock, 10*testElectionTimeout)
+	require.Len(t, leaders(set), 1)
+	for _, e := range set {
+		assert.Equal(t, leaders(set)[0], e.Status().Leader)
+	}
+}
+
+func TestElection_NoMajority(t *testing.T) {
+	set, clock, setDown := testSet(t, 3)
+	setDown("node-1", true)
+	setDown("node-2", true)
+
+	// A single node of three can't be elected.
+	runFor(t, set, clock, 10*testElectionTimeout)
+	assert.Empty(t, leaders(set))
+}
+
+func TestElection_OnChange(t *testing.T) {
+	set, clock, _ := testSet(t, 1)
+	var changes []bool
+	set[0].OnChange = func(leader bool) { changes = append(changes, leader) }
+
+	// A set of one elects itself.
+	runFor(t, set, clock, 3*testElectionTimeout)
+	assert.True(t, set[0].IsLeader())
+	assert.Equal(t, []bool{true}, changes)
+	assert.Equal(t, Status{Role: Leader, Term: 1, Leader: "node-0"}, set[0].Status())
+}
+
+func TestHandleVote(t *testing.T) {
+	set, clock, _ := testSet(t, 3)
+	e := set[0]
+
+	// No votes are granted right after the start.
+	resp, err := e.HandleVote(VoteRequest{Term: 1, Candidate: "node-1"})
+	require.NoError(t, err)
+	assert.False(t, resp.Granted)
+
+	clock.Advance(testElectionTimeout)
+	resp, err = e.HandleVote(VoteRequest{Term: 1, Candidate: "node-1"})
+	require.NoError(t, err)
+	assert.True(t, resp.Granted)
+
+	// Only one vote per term, even after a restart.
+	clock.Advance(testElectionTimeout)
+	resp, err = e.HandleVote(VoteRequest{Term: 1, Candidate: "node-2"})
+	require.NoError(t, err)
+	assert.False(t, resp.Granted)
+
+	restarted, err := New(e.cfg, e.transport, clock, nil)
+	require.NoError(t, err)
+	clock.Advance(testElectionTimeout)
+	resp, err = restarted.HandleVote(VoteRequest{Term: 1, Candidate: "node-2"})
+	require.NoError(t, err)
+	assert.False(t, resp.Granted)
+	resp, err = restarted.HandleVote(VoteRequest{Term: 1, Candidate: "node-1"})
+	require.NoError(t, err)
+	assert.True(t, resp.Granted)
+
+	// No votes are granted while the leader is heard from.
+	_, err = restarted.HandleHeartbeat(HeartbeatRequest{Term: 1, Leader: "node-1"})
+	require.NoError(t, err)
+	resp, err = restarted.HandleVote(VoteRequest{Term: 2, Candidate: "node-2"})
+	require.NoError(t, err)
+	assert.False(t, resp.Granted)
+	assert.Equal(t, uint64(1), resp.Term)
+
+	// Outdated terms are rejected.
+	clock.Advance(testElectionTimeout)
+	hb, err := restarted.HandleHeartbeat(HeartbeatRequest{Term: 0, Leader: "node-2"})
+	require.NoError(t, err)
+	assert.False(t, hb.Ack)
+}
@@ -0,0 +1,106 @@
+// Package features implements the runtime feature flags that gate SignCTRL's new,
+// risky subsystems. Flags are read from the [features] section of the configuration
+// file and can be updated while the node is running, so that a subsystem can be
+// rolled out gradually and disabled instantly without a binary change.
+package features
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+)
+
+// Flag is the name of a feature flag as used in the [features] section of the
+// configuration file.
+type Flag string
+
+const (
+	// PeerCoordination gates the coordination of rank updates between the validators
+	// in the set.
+	PeerCoordination Flag = "peer_coordination"
+
+	// CircularDemotion gates moving the signer that exceeded the threshold to the
+	// last rank.
+	CircularDemotion Flag = "circular_demotion"
+
+	// SharedSlashingDB gates keeping the double-signing protection in a database
+	// shared by the validators in the set.
+	SharedSlashingDB Flag = "shared_slashing_db"
+)
+
+// Set is a set of feature flags that is safe for concurrent use. The zero value has
+// all features disabled.
+type Set struct {
+	mtx     sync.RWMutex
+	enabled map[Flag]bool
+}
+
+// New creates a new set of feature flags from the given configuration.
+func New(cfg config.Features) *Set {
+	s := new(Set)
+	s.Update(cfg)
+
+	return s
+}
+
+// flags maps the given configuration to the flags it enables.
+func flags(cfg config.Features) map[Flag]bool {
+	return map[Flag]bool{
+		PeerCoordination: cfg.PeerCoordination,
+		CircularDemotion: cfg.CircularDemotion,
+		SharedSlashingDB: cfg.SharedSlashingDB,
+	}
+}
+
+// Update replaces all flags with the ones from the given configuration and returns
+// the flags whose values changed.
+func (s *Set) Update(cfg config.Features) (changed []Flag) {
+	next := flags(cfg)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for f, on := range next {
+		if s.enabled[f] != on {
+			changed = append(changed, f)
+		}
+	}
+	s.enabled = next
+	sort.Slice(changed, func(i, j int) bool { return changed[i] < changed[j] })
+
+	return changed
+}
+
+// Enabled returns true if the given feature is enabled. It is safe to be called on a
+// nil set, in which case all features are disabled.
+func (s *Set) Enabled(f Flag) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	return s.enabled[f]
+}
+
+// List returns the names of all enabled features in alphabetical order.
+func (s *Set) List() []string {
+	list := []string{}
+	if s == nil {
+		return list
+	}
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	for f, on := range s.enabled {
+		if on {
+			list = append(list, string(f))
+		}
+	}
+	sort.Strings(list)
+
+	return list
+}
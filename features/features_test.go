@@ -0,0 +1,36 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	s := New(config.Features{CircularDemotion: true})
+	assert.True(t, s.Enabled(CircularDemotion))
+	assert.False(t, s.Enabled(PeerCoordination))
+	assert.False(t, s.Enabled(SharedSlashingDB))
+	assert.Equal(t, []string{"circular_demotion"}, s.List())
+}
+
+func TestSet_Update(t *testing.T) {
+	s := New(config.Features{PeerCoordination: true})
+	changed := s.Update(config.Features{CircularDemotion: true, SharedSlashingDB: true})
+	assert.Equal(t, []Flag{CircularDemotion, PeerCoordination, SharedSlashingDB}, changed)
+	assert.Equal(t, []string{"circular_demotion", "shared_slashing_db"}, s.List())
+
+	changed = s.Update(config.Features{CircularDemotion: true, SharedSlashingDB: true})
+	assert.Empty(t, changed)
+}
+
+func TestSet_Nil(t *testing.T) {
+	var s *Set
+	assert.False(t, s.Enabled(PeerCoordination))
+	assert.Empty(t, s.List())
+
+	var zero Set
+	assert.False(t, zero.Enabled(PeerCoordination))
+	assert.Empty(t, zero.List())
+}
@@ -3,11 +3,21 @@ module github.com/BlockscapeNetwork/signctrl
 go 1.16
 
 require (
+	filippo.io/age v1.1.1
+	github.com/aws/aws-sdk-go v1.46.7
 	github.com/gogo/protobuf v1.3.2
+	github.com/golang/snappy v0.0.1
+	github.com/gorilla/websocket v1.4.2
 	github.com/hashicorp/logutils v1.0.0
 	github.com/prometheus/client_golang v1.8.0
+	github.com/prometheus/client_model v0.2.0
+	github.com/rs/zerolog v1.20.0
 	github.com/spf13/cobra v1.1.3
 	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.7.0
 	github.com/tendermint/tendermint v0.34.8
+	github.com/tendermint/tm-db v0.6.4
+	go.uber.org/zap v1.16.0
+	google.golang.org/grpc v1.35.0
+	google.golang.org/protobuf v1.25.0
 )
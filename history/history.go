@@ -0,0 +1,290 @@
+// Package history keeps the signing outcome of each block height in a small embedded
+// database, so that the uptime of a validator can be reported for any range of
+// heights without querying an archive node. Outcomes are pruned once they are older
+// than the retention, measured by the block timestamps.
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	tm_db "github.com/tendermint/tm-db"
+)
+
+const (
+	// DBName is the name of the database in the configuration directory.
+	DBName = "signctrl_history"
+
+	// pruneInterval is the number of records written between two prunings.
+	pruneInterval = 100
+)
+
+// Outcome is the signing outcome of a block height.
+type Outcome string
+
+const (
+	// OutcomeSigned means the validator's signature is in the block's commit.
+	OutcomeSigned Outcome = "signed"
+
+	// OutcomeMissed means the validator's signature is missing from the block's commit.
+	OutcomeMissed Outcome = "missed"
+
+	// OutcomeRefused means the node refused to sign, e.g. because it's jailed.
+	OutcomeRefused Outcome = "refused"
+)
+
+var (
+	// ErrClosed is returned for operations on a closed store.
+	ErrClosed = errors.New("history store is closed")
+
+	// keyPrefix is the prefix of the keys of all records.
+	keyPrefix = []byte("h/")
+)
+
+// Record is the signing outcome of a single block height.
+type Record struct {
+	Height  int64     `json:"height"`
+	Time    time.Time `json:"time"`
+	Outcome Outcome   `json:"outcome"`
+
+	// Rank is the node's rank at the time the outcome was recorded.
+	Rank int `json:"rank"`
+
+	// SignedBy is "self" if the node itself signed the block, and "set" if another
+	// node of the set did, as seen from the RPC server.
+	SignedBy string `json:"signed_by,omitempty"`
+
+	// Source is the RPC server the commit was queried from.
+	Source string `json:"source,omitempty"`
+
+	// Reason explains why a missed block wasn't counted towards the threshold, or why
+	// the node refused to sign.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Counted returns true if the record counts towards the uptime, i.e. it's either
+// signed or missed for no good reason.
+func (r Record) Counted() bool {
+	return r.Outcome == OutcomeSigned || (r.Outcome == OutcomeMissed && r.Reason == "")
+}
+
+// Report summarizes the signing outcomes of a range of block heights.
+type Report struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+
+	Signed  int `json:"signed"`
+	Missed  int `json:"missed"`
+	Refused int `json:"refused"`
+
+	// Uncounted is the number of missed blocks that weren't counted towards the
+	// threshold, e.g. during maintenance windows.
+	Uncounted int `json:"uncounted"`
+
+	// Uptime is the ratio of signed blocks to all counted blocks, or 1 if there are
+	// none.
+	Uptime float64 `json:"uptime"`
+
+	// Records are the individual outcomes, if requested.
+	Records []Record `json:"records,omitempty"`
+}
+
+// Store is a database of signing outcomes. It's safe for concurrent use.
+type Store struct {
+	mtx       sync.Mutex
+	db        tm_db.DB
+	retention time.Duration
+	puts      int
+	closed    bool
+}
+
+// Open opens the history database in the given directory, creating it if it doesn't
+// exist yet.
+func Open(dir string, retention time.Duration) (*Store, error) {
+	db, err := tm_db.NewGoLevelDB(DBName, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStore(db, retention), nil
+}
+
+// NewStore returns a store keeping its records in db for the given retention.
+func NewStore(db tm_db.DB, retention time.Duration) *Store {
+	return &Store{db: db, retention: retention}
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	return s.db.Close()
+}
+
+// key returns the key of the record for the given height. Heights are encoded in big
+// endian so that the records are iterated in order.
+func key(height int64) []byte {
+	k := make([]byte, len(keyPrefix)+8)
+	copy(k, keyPrefix)
+	binary.BigEndian.PutUint64(k[len(keyPrefix):], uint64(height))
+
+	return k
+}
+
+// Put stores the given record, replacing an existing one for the same height. A
+// refusal to sign isn't replaced by the height being missed, as the refusal explains
+// the miss.
+func (s *Store) Put(r Record) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	if r.Outcome == OutcomeMissed {
+		old, err := s.get(r.Height)
+		if err != nil {
+			return err
+		}
+		if old != nil && old.Outcome == OutcomeRefused {
+			return nil
+		}
+	}
+
+	bytes, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Set(key(r.Height), bytes); err != nil {
+		return err
+	}
+
+	if s.puts++; s.puts%pruneInterval == 0 && !r.Time.IsZero() {
+		return s.prune(r.Time.Add(-s.retention))
+	}
+
+	return nil
+}
+
+// Get returns the record for the given height, or nil if there is none.
+func (s *Store) Get(height int64) (*Record, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		return nil, ErrClosed
+	}
+
+	return s.get(height)
+}
+
+// get returns the record for the given height. The store's lock must be held.
+func (s *Store) get(height int64) (*Record, error) {
+	bytes, err := s.db.Get(key(height))
+	if err != nil || bytes == nil {
+		return nil, err
+	}
+
+	var r Record
+	if err := json.Unmarshal(bytes, &r); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// prune deletes all records older than the given time. The store's lock must be held.
+func (s *Store) prune(before time.Time) error {
+	it, err := s.db.Iterator(key(0), nil)
+	if err != nil {
+		return err
+	}
+
+	var stale [][]byte
+	for ; it.Valid(); it.Next() {
+		var r Record
+		if err := json.Unmarshal(it.Value(), &r); err != nil {
+			it.Close()
+			return err
+		}
+		// Records are ordered by height, so all further ones are newer.
+		if !r.Time.Before(before) {
+			break
+		}
+		stale = append(stale, append([]byte(nil), it.Key()...))
+	}
+	it.Close()
+
+	for _, k := range stale {
+		if err := s.db.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Report summarizes the records of the heights from from to to, both inclusive. A
+// to of 0 means up to the latest height. The individual records are included if
+// records is true.
+func (s *Store) Report(from, to int64, records bool) (*Report, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		return nil, ErrClosed
+	}
+
+	end := []byte(nil)
+	if to > 0 {
+		end = key(to + 1)
+	}
+	it, err := s.db.Iterator(key(from), end)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	rep := &Report{From: from, To: to, Uptime: 1}
+	for ; it.Valid(); it.Next() {
+		var r Record
+		if err := json.Unmarshal(it.Value(), &r); err != nil {
+			return nil, err
+		}
+		if rep.Signed+rep.Missed+rep.Refused == 0 {
+			rep.From = r.Height
+		}
+		rep.To = r.Height
+
+		switch {
+		case r.Outcome == OutcomeSigned:
+			rep.Signed++
+		case r.Outcome == OutcomeRefused:
+			rep.Refused++
+		case r.Counted():
+			rep.Missed++
+		default:
+			rep.Missed++
+			rep.Uncounted++
+		}
+		if records {
+			rep.Records = append(rep.Records, r)
+		}
+	}
+
+	if counted := rep.Signed + rep.Missed - rep.Uncounted; counted > 0 {
+		rep.Uptime = float64(rep.Signed) / float64(counted)
+	}
+
+	return rep, it.Error()
+}
@@ -0,0 +1,97 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_db "github.com/tendermint/tm-db"
+)
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(Record{Height: 1, Outcome: OutcomeSigned}))
+	require.NoError(t, s.Close())
+	assert.ErrorIs(t, s.Put(Record{Height: 2}), ErrClosed)
+	assert.NoError(t, s.Close())
+
+	// The records survive a restart.
+	s, err = Open(dir, time.Hour)
+	require.NoError(t, err)
+	defer s.Close()
+	r, err := s.Get(1)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, OutcomeSigned, r.Outcome)
+}
+
+func TestStore_Put(t *testing.T) {
+	s := NewStore(tm_db.NewMemDB(), time.Hour)
+
+	r, err := s.Get(1)
+	assert.NoError(t, err)
+	assert.Nil(t, r)
+
+	// A refusal isn't replaced by the height being missed.
+	require.NoError(t, s.Put(Record{Height: 1, Outcome: OutcomeRefused, Reason: "jailed"}))
+	require.NoError(t, s.Put(Record{Height: 1, Outcome: OutcomeMissed}))
+	r, err = s.Get(1)
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeRefused, r.Outcome)
+
+	// It's replaced by the height being signed, though.
+	require.NoError(t, s.Put(Record{Height: 1, Outcome: OutcomeSigned, SignedBy: "set"}))
+	r, err = s.Get(1)
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeSigned, r.Outcome)
+	assert.Equal(t, "set", r.SignedBy)
+}
+
+func TestStore_Prune(t *testing.T) {
+	s := NewStore(tm_db.NewMemDB(), time.Hour)
+	start := time.Unix(0, 0)
+	for h := int64(1); h <= pruneInterval; h++ {
+		require.NoError(t, s.Put(Record{Height: h, Time: start.Add(time.Duration(h) * time.Minute), Outcome: OutcomeSigned}))
+	}
+
+	// The records older than an hour before the latest one are deleted.
+	rep, err := s.Report(0, 0, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(pruneInterval-60), rep.From)
+	assert.Equal(t, int64(pruneInterval), rep.To)
+	assert.Equal(t, 61, rep.Signed)
+}
+
+func TestStore_Report(t *testing.T) {
+	s := NewStore(tm_db.NewMemDB(), time.Hour)
+	for _, r := range []Record{
+		{Height: 1, Outcome: OutcomeSigned},
+		{Height: 2, Outcome: OutcomeSigned},
+		{Height: 3, Outcome: OutcomeMissed},
+		{Height: 4, Outcome: OutcomeMissed, Reason: "maintenance"},
+		{Height: 5, Outcome: OutcomeRefused, Reason: "jailed"},
+		{Height: 6, Outcome: OutcomeSigned},
+	} {
+		require.NoError(t, s.Put(r))
+	}
+
+	rep, err := s.Report(0, 0, false)
+	require.NoError(t, err)
+	assert.Equal(t, &Report{From: 1, To: 6, Signed: 3, Missed: 2, Refused: 1, Uncounted: 1, Uptime: 0.75}, rep)
+
+	rep, err = s.Report(2, 4, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rep.From)
+	assert.Equal(t, int64(4), rep.To)
+	assert.Equal(t, 0.5, rep.Uptime)
+	require.Len(t, rep.Records, 3)
+	assert.Equal(t, int64(3), rep.Records[1].Height)
+
+	// An empty range has full uptime.
+	rep, err = s.Report(10, 20, false)
+	require.NoError(t, err)
+	assert.Equal(t, &Report{From: 10, To: 20, Uptime: 1}, rep)
+}
@@ -0,0 +1,203 @@
+// Package integrity verifies that the running SignCTRL binary is the one that was
+// released. The release pipeline signs the SHA-256 hash of the binary with an ed25519
+// key and ships the detached signature next to it. SignCTRL checks the signature on
+// startup, before any key is loaded, with the public key embedded at build time or
+// the one in the configuration file.
+package integrity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+const (
+	// SignatureExt is appended to the path of a binary to get the default path of its
+	// detached signature.
+	SignatureExt = ".sig"
+
+	// PermSigningKeyFile determines the file permissions of the private signing key.
+	PermSigningKeyFile = os.FileMode(0600)
+
+	// messagePrefix is prepended to the hash of a binary before it is signed, so that
+	// the signing key can't be tricked into signing anything else.
+	messagePrefix = "signctrl binary sha256:"
+)
+
+var (
+	// SigningKey is the base64-encoded ed25519 public key of the release signing key.
+	// It is set via -ldflags at build time.
+	SigningKey = ""
+
+	// BuildDate is the date the binary was built. It is set via -ldflags at build
+	// time.
+	BuildDate = ""
+
+	// ErrNoSigningKey is returned if neither a signing key was embedded at build time
+	// nor one is configured.
+	ErrNoSigningKey = errors.New("no signing key to verify the binary with")
+
+	// ErrInvalidSignature is returned if the signature doesn't match the binary.
+	ErrInvalidSignature = errors.New("binary doesn't match its signature")
+)
+
+// Manifest describes the build of the running binary.
+type Manifest struct {
+	Version    string   `json:"version"`
+	GitCommit  string   `json:"git_commit"`
+	BuildDate  string   `json:"build_date"`
+	GoVersion  string   `json:"go_version"`
+	Platform   string   `json:"platform"`
+	SigningKey string   `json:"signing_key"`
+	Deps       []string `json:"deps"`
+}
+
+// NewManifest returns the manifest of the running binary with the given version and
+// commit.
+func NewManifest(version, commit string) Manifest {
+	m := Manifest{
+		Version:    version,
+		GitCommit:  commit,
+		BuildDate:  BuildDate,
+		GoVersion:  runtime.Version(),
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+		SigningKey: SigningKey,
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			m.Deps = append(m.Deps, dep.Path+"@"+dep.Version)
+		}
+	}
+
+	return m
+}
+
+// ParsePublicKey parses a base64-encoded ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	bz, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(bz) != ed25519.PublicKeySize {
+		return nil, errors.New("signing key must be a base64-encoded ed25519 public key")
+	}
+
+	return ed25519.PublicKey(bz), nil
+}
+
+// PublicKey returns the public key the binary is verified with: the configured one,
+// or the one embedded at build time if none is configured.
+func PublicKey(configured string) (ed25519.PublicKey, error) {
+	if configured != "" {
+		return ParsePublicKey(configured)
+	}
+	if SigningKey != "" {
+		return ParsePublicKey(SigningKey)
+	}
+
+	return nil, ErrNoSigningKey
+}
+
+// Hash returns the SHA-256 hash of the file at the given path.
+func Hash(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// message returns the message that is signed for a binary with the given hash.
+func message(hash []byte) []byte {
+	return append([]byte(messagePrefix), hash...)
+}
+
+// GenerateKey generates a new signing key and writes its private key to the given
+// file. It returns the base64-encoded public key.
+func GenerateKey(file string) (string, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	seed := base64.StdEncoding.EncodeToString(priv.Seed())
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, PermSigningKeyFile)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(seed + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(pub), nil
+}
+
+// Sign signs the binary at the given path with the private key in keyFile and
+// writes the base64-encoded signature to sigFile.
+func Sign(path, keyFile, sigFile string) error {
+	bz, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(bz)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return fmt.Errorf("%v doesn't hold a base64-encoded ed25519 private key", keyFile)
+	}
+	hash, err := Hash(path)
+	if err != nil {
+		return err
+	}
+	sig := ed25519.Sign(ed25519.NewKeyFromSeed(seed), message(hash))
+
+	return ioutil.WriteFile(sigFile, []byte(base64.StdEncoding.EncodeToString(sig)+"\n"), 0644)
+}
+
+// Verify verifies the binary at the given path against the signature in sigFile and
+// returns the binary's hex-encoded SHA-256 hash.
+func Verify(path, sigFile string, pub ed25519.PublicKey) (string, error) {
+	hash, err := Hash(path)
+	if err != nil {
+		return "", err
+	}
+	bz, err := ioutil.ReadFile(sigFile)
+	if err != nil {
+		return hex.EncodeToString(hash), err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(bz)))
+	if err != nil || !ed25519.Verify(pub, message(hash), sig) {
+		return hex.EncodeToString(hash), fmt.Errorf("%w (sha256 %x)", ErrInvalidSignature, hash)
+	}
+
+	return hex.EncodeToString(hash), nil
+}
+
+// Executable returns the path of the running binary with symlinks resolved.
+func Executable() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(path)
+}
@@ -0,0 +1,77 @@
+package integrity
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "signctrl")
+	require.NoError(t, ioutil.WriteFile(binary, []byte("release"), 0755))
+	keyFile := filepath.Join(dir, "signing.key")
+	pubKey, err := GenerateKey(keyFile)
+	require.NoError(t, err)
+	sigFile := binary + SignatureExt
+	require.NoError(t, Sign(binary, keyFile, sigFile))
+
+	pub, err := PublicKey(pubKey)
+	require.NoError(t, err)
+	hash, err := Verify(binary, sigFile, pub)
+	require.NoError(t, err)
+	assert.Equal(t, "a4d451ec23463726f72c43d64c710968f6b602cd653b4de8adee1b556240a829", hash)
+
+	// Existing keys are never overwritten.
+	_, err = GenerateKey(keyFile)
+	assert.Error(t, err)
+
+	// A modified binary doesn't match the signature.
+	require.NoError(t, ioutil.WriteFile(binary, []byte("tampered"), 0755))
+	_, err = Verify(binary, sigFile, pub)
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+
+	// Neither does the signature of another key.
+	require.NoError(t, ioutil.WriteFile(binary, []byte("release"), 0755))
+	other, err := GenerateKey(filepath.Join(dir, "other.key"))
+	require.NoError(t, err)
+	otherPub, err := ParsePublicKey(other)
+	require.NoError(t, err)
+	_, err = Verify(binary, sigFile, otherPub)
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+}
+
+func TestPublicKey(t *testing.T) {
+	_, err := PublicKey("")
+	assert.True(t, errors.Is(err, ErrNoSigningKey))
+
+	// The configured key takes precedence over the embedded one.
+	embedded := "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="
+	configured := "Gb9ECWmEzf6FQbrBZ9w7lshQhqowtrbLDFw4rXAxZuE="
+	SigningKey = embedded
+	defer func() { SigningKey = "" }()
+	pub, err := PublicKey("")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xd7, 0x5a, 0x98}, []byte(pub[:3]))
+	pub, err = PublicKey(configured)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x19, 0xbf, 0x44}, []byte(pub[:3]))
+
+	_, err = ParsePublicKey("AAAA")
+	assert.Error(t, err)
+}
+
+func TestNewManifest(t *testing.T) {
+	BuildDate = "2021-06-01T00:00:00Z"
+	defer func() { BuildDate = "" }()
+	m := NewManifest("v0.5.0", "abc")
+	assert.Equal(t, "v0.5.0", m.Version)
+	assert.Equal(t, "abc", m.GitCommit)
+	assert.Equal(t, "2021-06-01T00:00:00Z", m.BuildDate)
+	assert.NotEmpty(t, m.GoVersion)
+	assert.NotEmpty(t, m.Platform)
+}
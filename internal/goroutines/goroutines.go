@@ -0,0 +1,49 @@
+// Package goroutines keeps track of the goroutines started by SignCTRL's subsystems,
+// so that the number of goroutines per subsystem can be reported on long-running
+// nodes.
+package goroutines
+
+import "sync"
+
+var (
+	// mtx guards counts.
+	mtx sync.Mutex
+
+	// counts maps the subsystems to their number of running goroutines.
+	counts = make(map[string]int)
+)
+
+// Go runs f in a new goroutine that is counted towards the given subsystem until f
+// returns.
+func Go(subsystem string, f func()) {
+	add(subsystem, 1)
+	go func() {
+		defer add(subsystem, -1)
+		f()
+	}()
+}
+
+// add adds delta to the subsystem's number of running goroutines.
+func add(subsystem string, delta int) {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	counts[subsystem] += delta
+	if counts[subsystem] == 0 {
+		delete(counts, subsystem)
+	}
+}
+
+// Counts returns the number of running goroutines per subsystem. Subsystems without
+// running goroutines are left out.
+func Counts() map[string]int {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	c := make(map[string]int, len(counts))
+	for subsystem, n := range counts {
+		c[subsystem] = n
+	}
+
+	return c
+}
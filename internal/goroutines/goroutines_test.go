@@ -0,0 +1,28 @@
+package goroutines
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGo(t *testing.T) {
+	release := make(chan struct{})
+	done := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		Go("test", func() {
+			<-release
+			done <- struct{}{}
+		})
+	}
+	assert.Equal(t, 2, Counts()["test"])
+
+	close(release)
+	<-done
+	<-done
+	assert.Eventually(t, func() bool {
+		_, ok := Counts()["test"]
+		return !ok
+	}, time.Second, time.Millisecond)
+}
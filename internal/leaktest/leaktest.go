@@ -0,0 +1,124 @@
+// Package leaktest verifies that tests don't leak goroutines or file descriptors. It
+// is meant for the tests of SignCTRL's services, which start goroutines and open
+// connections that must all be cleaned up once the service is stopped.
+package leaktest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	// Timeout is the time goroutines and file descriptors are given to be cleaned up
+	// after the test finished.
+	Timeout = 5 * time.Second
+
+	// ignored are parts of the stacks of goroutines that are never considered leaked,
+	// as they are either started by the testing package or live for the whole process.
+	ignored = []string{
+		"testing.tRunner",
+		"testing.(*T).Run",
+		"testing.runTests",
+		"os/signal.signal_recv",
+		"os/signal.loop",
+		"net/http.(*persistConn)",
+		"created by github.com/tendermint/tendermint/libs/async.Parallel",
+	}
+)
+
+// goroutines returns the stacks of all goroutines, mapped by the goroutine's header,
+// e.g. "goroutine 7 [running]:". The state in the header is stripped.
+func goroutines() map[string]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	stacks := make(map[string]string)
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		header := strings.SplitN(string(stack), " [", 2)[0]
+		stacks[header] = string(stack)
+	}
+
+	return stacks
+}
+
+// isIgnored returns true if the goroutine with the given stack is never considered
+// leaked.
+func isIgnored(stack string) bool {
+	for _, s := range ignored {
+		if strings.Contains(stack, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// leakedGoroutines returns the stacks of all goroutines that are not part of before.
+func leakedGoroutines(before map[string]string) (leaked []string) {
+	for header, stack := range goroutines() {
+		if _, ok := before[header]; ok || isIgnored(stack) {
+			continue
+		}
+		// Skip the goroutine collecting the stacks.
+		if strings.Contains(stack, "leaktest.goroutines") {
+			continue
+		}
+		leaked = append(leaked, stack)
+	}
+
+	return leaked
+}
+
+// openFDs returns the number of file descriptors opened by the process, or -1 if they
+// can't be counted on this platform.
+func openFDs() int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+
+	return len(fds)
+}
+
+// Check fails the test if goroutines started or file descriptors opened during the
+// test are still around after the test and its cleanup functions finished. It must be
+// called at the start of the test and doesn't work for parallel tests.
+func Check(t testing.TB) {
+	t.Helper()
+	before := goroutines()
+	fdsBefore := openFDs()
+
+	// Cleanup functions are run in last added, first called order, so this is run
+	// after the cleanup functions added by the test itself.
+	t.Cleanup(func() {
+		deadline := time.Now().Add(Timeout)
+		for {
+			leaked := leakedGoroutines(before)
+			fds := openFDs()
+			if len(leaked) == 0 && fds <= fdsBefore {
+				return
+			}
+			if time.Now().After(deadline) {
+				for _, stack := range leaked {
+					t.Errorf("leaked goroutine:\n%v", stack)
+				}
+				if fds > fdsBefore {
+					t.Errorf("leaked %v file descriptors (%v before, %v after)", fds-fdsBefore, fdsBefore, fds)
+				}
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
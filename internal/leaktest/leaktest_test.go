@@ -0,0 +1,77 @@
+package leaktest
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingT records the errors reported by Check instead of failing the test.
+type recordingT struct {
+	testing.TB
+	cleanups []func()
+	errs     []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errs = append(t.errs, format)
+}
+
+func (t *recordingT) finish() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func TestCheck_NoLeaks(t *testing.T) {
+	rt := &recordingT{TB: t}
+	Check(rt)
+
+	done := make(chan struct{})
+	go func() { close(done) }()
+	<-done
+
+	rt.finish()
+	assert.Empty(t, rt.errs)
+}
+
+func TestCheck_LeakedGoroutine(t *testing.T) {
+	defer func(timeout time.Duration) { Timeout = timeout }(Timeout)
+	Timeout = 50 * time.Millisecond
+
+	rt := &recordingT{TB: t}
+	Check(rt)
+
+	release := make(chan struct{})
+	defer close(release)
+	go func() { <-release }()
+
+	rt.finish()
+	assert.NotEmpty(t, rt.errs)
+}
+
+func TestCheck_LeakedFD(t *testing.T) {
+	if openFDs() < 0 {
+		t.Skip("file descriptors can't be counted on this platform")
+	}
+	defer func(timeout time.Duration) { Timeout = timeout }(Timeout)
+	Timeout = 50 * time.Millisecond
+
+	rt := &recordingT{TB: t}
+	Check(rt)
+
+	f, err := os.Open(os.Args[0])
+	assert.NoError(t, err)
+	defer f.Close()
+
+	rt.finish()
+	assert.NotEmpty(t, rt.errs)
+}
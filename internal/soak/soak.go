@@ -0,0 +1,310 @@
+// Package soak runs a full SignCTRL set against mock validators for a long time in
+// order to catch leaks in the connection handling and the main loop of SignCTRL.
+package soak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl"
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/testutil"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+// ChainID is the chain ID of the mock chain used for soak tests.
+const ChainID = "soak"
+
+// Config defines the parameters of a soak test.
+type Config struct {
+	// SetSize is the number of nodes in the set.
+	SetSize int
+
+	// Threshold is the threshold of missed blocks in a row of the set.
+	Threshold int
+
+	// Duration is the time the soak test runs for.
+	Duration time.Duration
+
+	// BlockTime is the time between two blocks of the mock chain.
+	BlockTime time.Duration
+
+	// SampleInterval is the interval in which memory and goroutines are sampled.
+	SampleInterval time.Duration
+
+	// DropInterval is the interval in which a random validator drops its connection
+	// to its node. Connections are never dropped if 0.
+	DropInterval time.Duration
+
+	// RetryDialAfter is the retry_dial_after setting of the nodes.
+	RetryDialAfter string
+
+	// Seed determines which connections are dropped.
+	Seed int64
+}
+
+// DefaultConfig returns the default soak test configuration.
+func DefaultConfig() Config {
+	return Config{
+		SetSize:        3,
+		Threshold:      5,
+		Duration:       time.Hour,
+		BlockTime:      time.Second,
+		SampleInterval: time.Minute,
+		DropInterval:   10 * time.Minute,
+		RetryDialAfter: "15s",
+		Seed:           1,
+	}
+}
+
+// Sample is a snapshot of the resources used during a soak test.
+type Sample struct {
+	Elapsed    time.Duration
+	Height     int64
+	Goroutines int
+	HeapAlloc  uint64
+}
+
+// String returns a human-readable representation of the sample.
+func (s Sample) String() string {
+	return fmt.Sprintf("elapsed: %v, height: %v, goroutines: %v, heap: %.2f MiB",
+		s.Elapsed.Round(time.Second), s.Height, s.Goroutines, float64(s.HeapAlloc)/(1<<20))
+}
+
+// Report is the result of a soak test.
+type Report struct {
+	// Baseline is sampled before the set is started, Final after it is stopped again.
+	Baseline Sample
+	Final    Sample
+	Samples  []Sample
+
+	// Missed contains the heights no node signed, DoubleSigned the heights more than
+	// one node signed.
+	Missed       []int64
+	DoubleSigned []int64
+
+	// Drops is the number of connections dropped on purpose.
+	Drops int
+
+	// Stopped contains the start ranks of the nodes that shut themselves down.
+	Stopped []int
+}
+
+// LeakedGoroutines returns the number of goroutines left over after the set was
+// stopped.
+func (r *Report) LeakedGoroutines() int {
+	return r.Final.Goroutines - r.Baseline.Goroutines
+}
+
+// sample takes a snapshot of the resources currently used.
+func sample(start time.Time, chain *testutil.Chain) Sample {
+	var mem runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&mem)
+
+	return Sample{
+		Elapsed:    time.Since(start),
+		Height:     chain.Height(),
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  mem.HeapAlloc,
+	}
+}
+
+// newNode creates the node with the given start rank and its config directory.
+func newNode(cfg Config, rank int, validator *testutil.MockValidator, key tm_ed25519.PrivKey, logger types.Logger) (*signctrl.Node, string, error) {
+	dir, err := ioutil.TempDir("", fmt.Sprintf("signctrl-soak-%v-", rank))
+	if err != nil {
+		return nil, "", err
+	}
+	if err := connection.CreateBase64ConnKey(dir); err != nil {
+		return nil, dir, err
+	}
+
+	node, err := signctrl.New(signctrl.Options{
+		Config: config.Config{
+			Base: config.Base{
+				LogLevel:                  "INFO",
+				SetSize:                   cfg.SetSize,
+				Threshold:                 cfg.Threshold,
+				StartRank:                 rank,
+				ValidatorListenAddress:    validator.Address(),
+				ValidatorListenAddressRPC: validator.RPCAddress(),
+				RetryDialAfter:            cfg.RetryDialAfter,
+			},
+			Privval: config.PrivValidator{
+				ChainID: ChainID,
+			},
+		},
+		State: config.State{
+			LastHeight: 1,
+			LastRank:   rank,
+		},
+		CfgDir:        dir,
+		PrivValidator: tm_privval.NewFilePV(key, privval.KeyFilePath(dir), privval.StateFilePath(dir)),
+		Logger:        logger,
+	})
+
+	return node, dir, err
+}
+
+// Run runs a soak test with the given configuration until its duration passed or ctx
+// is done. The nodes' logs are written to nodeLogs, tagged with their start ranks.
+func Run(ctx context.Context, cfg Config, logger types.Logger, nodeLogs io.Writer) (*Report, error) {
+	if cfg.SetSize < 2 {
+		return nil, errors.New("set size must be 2 or higher")
+	}
+	if cfg.BlockTime <= 0 || cfg.SampleInterval <= 0 {
+		return nil, errors.New("block time and sample interval must be positive")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	start := time.Now()
+	key := tm_ed25519.GenPrivKey()
+	chain := testutil.NewChain(testutil.ChainParams{
+		ChainID:   ChainID,
+		BlockTime: cfg.BlockTime,
+		Address:   key.PubKey().Address(),
+	})
+	report := &Report{Baseline: sample(start, chain)}
+
+	// Start the validators and the set's nodes, one for each validator.
+	var (
+		validators []*testutil.MockValidator
+		nodes      []*signctrl.Node
+	)
+	defer func() {
+		for _, node := range nodes {
+			if node.IsRunning() {
+				_ = node.Stop()
+			}
+		}
+		for _, v := range validators {
+			_ = v.Close()
+		}
+	}()
+
+	for rank := 1; rank <= cfg.SetSize; rank++ {
+		v, err := testutil.NewMockValidator(chain, testutil.MockValidatorOptions{})
+		if err != nil {
+			return nil, err
+		}
+		validators = append(validators, v)
+
+		node, dir, err := newNode(cfg, rank, v, key, types.NewSyncLogger(nodeLogs, "", 0).With("start_rank", rank))
+		if dir != "" {
+			defer os.RemoveAll(filepath.Clean(dir))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := node.Start(ctx); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	// Track the nodes that shut themselves down before the soak test is over.
+	var (
+		mtx sync.Mutex
+		wg  sync.WaitGroup
+	)
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(rank int, node *signctrl.Node) {
+			defer wg.Done()
+			<-node.Done()
+			if ctx.Err() == nil {
+				mtx.Lock()
+				report.Stopped = append(report.Stopped, rank)
+				mtx.Unlock()
+				logger.Info("[soak] Node with start rank %v shut down", rank)
+			}
+		}(i+1, node)
+	}
+
+	// Produce blocks and track the signatures.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		chain.Produce(ctx, validators, func(r testutil.Round) {
+			mtx.Lock()
+			defer mtx.Unlock()
+
+			switch {
+			case r.Signers == 0:
+				report.Missed = append(report.Missed, r.Height)
+			case r.Signers > 1:
+				report.DoubleSigned = append(report.DoubleSigned, r.Height)
+				logger.Error("Height %v was signed by %v nodes\n", r.Height, r.Signers)
+			}
+		})
+	}()
+
+	// Sample the resources and drop connections until the soak test is over.
+	rnd := rand.New(rand.NewSource(cfg.Seed))
+	sampleTicker := time.NewTicker(cfg.SampleInterval)
+	defer sampleTicker.Stop()
+	var dropCh <-chan time.Time
+	if cfg.DropInterval > 0 {
+		dropTicker := time.NewTicker(cfg.DropInterval)
+		defer dropTicker.Stop()
+		dropCh = dropTicker.C
+	}
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+
+		case <-sampleTicker.C:
+			s := sample(start, chain)
+			logger.Info("[soak] %v", s)
+			report.Samples = append(report.Samples, s)
+
+		case <-dropCh:
+			i := rnd.Intn(len(validators))
+			logger.Info("[soak] Dropping connection of the validator for rank %v", i+1)
+			validators[i].DropConnection()
+			report.Drops++
+		}
+	}
+	// Wait for the nodes to stop and check for leftovers.
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		return nil, errors.New("set didn't stop in time")
+	}
+	for _, v := range validators {
+		_ = v.Close()
+	}
+	validators = nil
+	http.DefaultClient.CloseIdleConnections()
+
+	// Give the goroutines some time to return.
+	time.Sleep(time.Second)
+	report.Final = sample(start, chain)
+
+	return report, nil
+}
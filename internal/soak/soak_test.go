@@ -0,0 +1,42 @@
+package soak
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun_InvalidConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SetSize = 1
+	report, err := Run(context.Background(), cfg, types.NewSyncLogger(ioutil.Discard, "", 0), ioutil.Discard)
+	assert.Nil(t, report)
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping soak test in short mode")
+	}
+
+	cfg := DefaultConfig()
+	cfg.Duration = 4 * time.Second
+	cfg.BlockTime = 100 * time.Millisecond
+	cfg.SampleInterval = time.Second
+	cfg.DropInterval = 1500 * time.Millisecond
+	cfg.RetryDialAfter = "10s"
+
+	report, err := Run(context.Background(), cfg, types.NewSyncLogger(ioutil.Discard, "", 0), ioutil.Discard)
+	assert.NoError(t, err)
+	t.Logf("final: %v, missed: %v, drops: %v", report.Final, report.Missed, report.Drops)
+	assert.NotEmpty(t, report.Samples)
+	assert.Empty(t, report.DoubleSigned)
+	assert.Empty(t, report.Stopped)
+	assert.Equal(t, 2, report.Drops)
+	assert.True(t, report.Final.Height > int64(len(report.Missed)), "most blocks must be signed")
+	assert.True(t, report.LeakedGoroutines() <= 0, "leaked %v goroutines", report.LeakedGoroutines())
+}
@@ -0,0 +1,166 @@
+package lease
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEtcd emulates the lease and txn endpoints of etcd's JSON gateway for a single
+// key.
+type fakeEtcd struct {
+	mtx    sync.Mutex
+	leases map[string]bool
+	value  string
+	lease  string
+	nextID int
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	var req map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	switch r.URL.Path {
+	case "/v3/lease/grant":
+		f.nextID++
+		id := strings.Repeat("7", f.nextID)
+		f.leases[id] = true
+		_ = json.NewEncoder(w).Encode(map[string]string{"ID": id, "TTL": "30"})
+	case "/v3/lease/keepalive":
+		ttl := "0"
+		if f.leases[req["ID"].(string)] {
+			ttl = "30"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]string{"TTL": ttl}})
+	case "/v3/lease/revoke":
+		id := req["ID"].(string)
+		delete(f.leases, id)
+		if f.lease == id {
+			f.value, f.lease = "", ""
+		}
+		_, _ = w.Write([]byte("{}"))
+	case "/v3/kv/txn":
+		if f.value != "" {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"succeeded": false,
+				"responses": []interface{}{map[string]interface{}{
+					"response_range": map[string]interface{}{"kvs": []interface{}{map[string]string{"value": f.value}}},
+				}},
+			})
+			return
+		}
+		put := req["success"].([]interface{})[0].(map[string]interface{})["request_put"].(map[string]interface{})
+		f.value, f.lease = put["value"].(string), put["lease"].(string)
+		_ = json.NewEncoder(w).Encode(map[string]bool{"succeeded": true})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestEtcd(t *testing.T) {
+	f := &fakeEtcd{leases: make(map[string]bool)}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	ctx := context.Background()
+
+	// The first endpoint is unreachable.
+	a := NewEtcd([]string{"http://127.0.0.1:1", srv.URL}, "signctrl/testchain", "validator-a", nil)
+	b := NewEtcd([]string{srv.URL}, "signctrl/testchain", "validator-b", nil)
+	require.NoError(t, a.Acquire(ctx, 30*time.Second))
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("validator-a")), f.value)
+
+	// The lock is held by a, and b's unused lease is revoked.
+	err := b.Acquire(ctx, 30*time.Second)
+	assert.ErrorIs(t, err, ErrHeld)
+	assert.Contains(t, err.Error(), "validator-a")
+	assert.Len(t, f.leases, 1)
+
+	require.NoError(t, a.Renew(ctx))
+	require.NoError(t, a.Release(ctx))
+	assert.Empty(t, f.value)
+	assert.ErrorIs(t, a.Renew(ctx), ErrLost)
+
+	require.NoError(t, b.Acquire(ctx, 30*time.Second))
+}
+
+// fakeConsul emulates the session and KV endpoints of Consul for a single key.
+type fakeConsul struct {
+	mtx      sync.Mutex
+	sessions map[string]bool
+	holder   string
+	nextID   int
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	switch {
+	case r.URL.Path == "/v1/session/create":
+		f.nextID++
+		id := strings.Repeat("s", f.nextID)
+		f.sessions[id] = true
+		_ = json.NewEncoder(w).Encode(map[string]string{"ID": id})
+	case strings.HasPrefix(r.URL.Path, "/v1/session/renew/"):
+		if !f.sessions[strings.TrimPrefix(r.URL.Path, "/v1/session/renew/")] {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("[]"))
+	case strings.HasPrefix(r.URL.Path, "/v1/session/destroy/"):
+		id := strings.TrimPrefix(r.URL.Path, "/v1/session/destroy/")
+		delete(f.sessions, id)
+		if f.holder == id {
+			f.holder = ""
+		}
+		_, _ = w.Write([]byte("true"))
+	case r.URL.Path == "/v1/kv/signctrl/testchain":
+		if id := r.URL.Query().Get("acquire"); id != "" {
+			ok := f.holder == "" || f.holder == id
+			if ok {
+				f.holder = id
+			}
+			_ = json.NewEncoder(w).Encode(ok)
+			return
+		}
+		if f.holder == r.URL.Query().Get("release") {
+			f.holder = ""
+		}
+		_, _ = w.Write([]byte("true"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestConsul(t *testing.T) {
+	f := &fakeConsul{sessions: make(map[string]bool)}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	ctx := context.Background()
+
+	a := NewConsul([]string{srv.URL}, "signctrl/testchain", "validator-a", nil)
+	b := NewConsul([]string{srv.URL}, "signctrl/testchain", "validator-b", nil)
+	require.NoError(t, a.Acquire(ctx, 30*time.Second))
+
+	// The lock is held by a, and b's unused session is destroyed.
+	assert.ErrorIs(t, b.Acquire(ctx, 30*time.Second), ErrHeld)
+	assert.Len(t, f.sessions, 1)
+
+	require.NoError(t, a.Renew(ctx))
+	require.NoError(t, a.Release(ctx))
+	assert.Empty(t, f.holder)
+	assert.ErrorIs(t, a.Renew(ctx), ErrLost)
+
+	require.NoError(t, b.Acquire(ctx, 30*time.Second))
+}
@@ -0,0 +1,98 @@
+package lease
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Consul is a Backend holding the lock in Consul's KV store. The lock is the key,
+// which is acquired with a session with the given TTL. The session is created with
+// the delete behavior and without a lock delay, so that Consul deletes the key once
+// the session is invalidated and the next node can acquire it right away.
+// Implements the Backend interface.
+type Consul struct {
+	client client
+	key    string
+	holder string
+
+	session string
+}
+
+// Consul must implement the Backend interface.
+var _ Backend = &Consul{}
+
+// NewConsul creates a new Consul backend for the lock at the given key, which is
+// held under the given name, with the Consul agents at the given endpoints, e.g.
+// http://127.0.0.1:8500.
+func NewConsul(endpoints []string, key, holder string, httpClient *http.Client) *Consul {
+	return &Consul{
+		client: client{endpoints: endpoints, http: httpClient},
+		key:    key,
+		holder: holder,
+	}
+}
+
+// kvPath returns the path of the key with the given query parameter.
+func (c *Consul) kvPath(param, session string) string {
+	return "/v1/kv/" + c.key + "?" + url.Values{param: {session}}.Encode()
+}
+
+// Acquire creates a session with the given TTL and acquires the key with it.
+// Implements the Backend interface.
+func (c *Consul) Acquire(ctx context.Context, ttl time.Duration) error {
+	var session struct {
+		ID string `json:"ID"`
+	}
+	if _, err := c.client.do(ctx, http.MethodPut, "/v1/session/create", map[string]string{
+		"Name":      c.holder,
+		"TTL":       fmt.Sprintf("%ds", int64(ttl.Seconds())),
+		"Behavior":  "delete",
+		"LockDelay": "0s",
+	}, &session); err != nil {
+		return err
+	}
+	if session.ID == "" {
+		return fmt.Errorf("consul didn't create a session")
+	}
+
+	var acquired bool
+	_, err := c.client.do(ctx, http.MethodPut, c.kvPath("acquire", session.ID), c.holder, &acquired)
+	if err == nil && acquired {
+		c.session = session.ID
+		return nil
+	}
+
+	// Don't leave the unused session behind.
+	_, _ = c.client.do(ctx, http.MethodPut, "/v1/session/destroy/"+session.ID, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	return ErrHeld
+}
+
+// Renew renews the session.
+// Implements the Backend interface.
+func (c *Consul) Renew(ctx context.Context) error {
+	status, err := c.client.do(ctx, http.MethodPut, "/v1/session/renew/"+c.session, nil, nil)
+	if status == http.StatusNotFound {
+		return fmt.Errorf("%w: %v", ErrLost, err)
+	}
+
+	return err
+}
+
+// Release releases the key and destroys the session.
+// Implements the Backend interface.
+func (c *Consul) Release(ctx context.Context) error {
+	_, err := c.client.do(ctx, http.MethodPut, c.kvPath("release", c.session), nil, nil)
+	if _, destroyErr := c.client.do(ctx, http.MethodPut, "/v1/session/destroy/"+c.session, nil, nil); err == nil {
+		err = destroyErr
+	}
+	c.session = ""
+
+	return err
+}
@@ -0,0 +1,136 @@
+package lease
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// etcdInt is an int64 in the responses of etcd's JSON gateway, which encodes them as
+// strings.
+type etcdInt string
+
+// UnmarshalJSON accepts both strings and numbers.
+func (i *etcdInt) UnmarshalJSON(bz []byte) error {
+	*i = etcdInt(strings.Trim(string(bz), `"`))
+	return nil
+}
+
+// Etcd is a Backend holding the lock in etcd via the JSON gateway of its v3 API. The
+// lock is the key, which is created with the holder's name as its value and attached
+// to the lease, so that etcd deletes it once the lease expires.
+// Implements the Backend interface.
+type Etcd struct {
+	client client
+	key    string
+	holder string
+
+	leaseID etcdInt
+}
+
+// Etcd must implement the Backend interface.
+var _ Backend = &Etcd{}
+
+// NewEtcd creates a new Etcd backend for the lock at the given key, which is held
+// under the given name, in the etcd cluster with the given endpoints, e.g.
+// http://10.0.0.1:2379.
+func NewEtcd(endpoints []string, key, holder string, httpClient *http.Client) *Etcd {
+	return &Etcd{
+		client: client{endpoints: endpoints, http: httpClient},
+		key:    key,
+		holder: holder,
+	}
+}
+
+// b64 encodes s for etcd's JSON gateway.
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// Acquire grants a lease with the given TTL and creates the key with it, unless the
+// key already exists.
+// Implements the Backend interface.
+func (e *Etcd) Acquire(ctx context.Context, ttl time.Duration) error {
+	var grant struct {
+		ID etcdInt `json:"ID"`
+	}
+	if _, err := e.client.do(ctx, http.MethodPost, "/v3/lease/grant", map[string]interface{}{"TTL": int64(ttl.Seconds())}, &grant); err != nil {
+		return err
+	}
+	if grant.ID == "" {
+		return fmt.Errorf("etcd didn't grant a lease")
+	}
+
+	var txn struct {
+		Succeeded bool `json:"succeeded"`
+		Responses []struct {
+			ResponseRange struct {
+				KVs []struct {
+					Value string `json:"value"`
+				} `json:"kvs"`
+			} `json:"response_range"`
+		} `json:"responses"`
+	}
+	_, err := e.client.do(ctx, http.MethodPost, "/v3/kv/txn", map[string]interface{}{
+		"compare": []interface{}{map[string]interface{}{
+			"key":             b64(e.key),
+			"result":          "EQUAL",
+			"target":          "CREATE",
+			"create_revision": "0",
+		}},
+		"success": []interface{}{map[string]interface{}{
+			"request_put": map[string]interface{}{"key": b64(e.key), "value": b64(e.holder), "lease": grant.ID},
+		}},
+		"failure": []interface{}{map[string]interface{}{
+			"request_range": map[string]interface{}{"key": b64(e.key)},
+		}},
+	}, &txn)
+	if err == nil && txn.Succeeded {
+		e.leaseID = grant.ID
+		return nil
+	}
+
+	// Don't leave the unused lease behind.
+	_, _ = e.client.do(ctx, http.MethodPost, "/v3/lease/revoke", map[string]interface{}{"ID": grant.ID}, nil)
+	if err != nil {
+		return err
+	}
+	holder := "unknown"
+	if len(txn.Responses) > 0 && len(txn.Responses[0].ResponseRange.KVs) > 0 {
+		if bz, err := base64.StdEncoding.DecodeString(txn.Responses[0].ResponseRange.KVs[0].Value); err == nil {
+			holder = string(bz)
+		}
+	}
+
+	return fmt.Errorf("%w: %v", ErrHeld, holder)
+}
+
+// Renew sends a keep-alive for the lease.
+// Implements the Backend interface.
+func (e *Etcd) Renew(ctx context.Context) error {
+	var keepAlive struct {
+		Result struct {
+			TTL etcdInt `json:"TTL"`
+		} `json:"result"`
+	}
+	if _, err := e.client.do(ctx, http.MethodPost, "/v3/lease/keepalive", map[string]interface{}{"ID": e.leaseID}, &keepAlive); err != nil {
+		return err
+	}
+	if ttl := keepAlive.Result.TTL; ttl == "" || ttl == "0" {
+		return ErrLost
+	}
+
+	return nil
+}
+
+// Release revokes the lease, which deletes the key.
+// Implements the Backend interface.
+func (e *Etcd) Release(ctx context.Context) error {
+	_, err := e.client.do(ctx, http.MethodPost, "/v3/lease/revoke", map[string]interface{}{"ID": e.leaseID}, nil)
+	e.leaseID = ""
+
+	return err
+}
@@ -0,0 +1,75 @@
+package lease
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// client sends JSON requests to a store with several endpoints, e.g. the members of
+// an etcd cluster.
+type client struct {
+	endpoints []string
+	http      *http.Client
+}
+
+// do sends a request with the given method, path and body, which is encoded as JSON
+// unless it is a string, to the endpoints in order until one of them responds. It
+// returns the status code and, if out isn't nil, decodes a successful response into
+// it.
+func (c client) do(ctx context.Context, method, path string, body, out interface{}) (int, error) {
+	var bz []byte
+	switch b := body.(type) {
+	case nil:
+	case string:
+		bz = []byte(b)
+	default:
+		var err error
+		if bz, err = json.Marshal(b); err != nil {
+			return 0, err
+		}
+	}
+	httpClient := c.http
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	err := errors.New("no endpoints")
+	for _, endpoint := range c.endpoints {
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, method, strings.TrimSuffix(endpoint, "/")+path, bytes.NewReader(bz))
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		var resp *http.Response
+		resp, err = httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, err
+			}
+			continue
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+			return resp.StatusCode, fmt.Errorf("%v %v: %v: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+		}
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return resp.StatusCode, fmt.Errorf("couldn't decode the response to %v %v: %w", method, path, err)
+			}
+		}
+
+		return resp.StatusCode, nil
+	}
+
+	return 0, err
+}
@@ -0,0 +1,206 @@
+// Package lease arbitrates the signing authority of a set through a lock in an
+// external store, i.e. etcd or Consul, as a guard against split-brain that doesn't
+// rely on the nodes reaching each other. The signer holds the lock with a lease that
+// expires after a TTL unless it is renewed, so a node that is cut off from the store
+// loses the lock to the next one.
+//
+// The store's lease starts when the request granting it arrives, which is after it
+// was sent, so the holder only considers the lock held until the TTL has passed since
+// it sent the request, minus a safety margin. Thus, it stops signing before the store
+// hands the lock to another node, even if it can't reach the store anymore.
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+// margin is the share of the TTL the lease is shortened by, which covers the clocks
+// of the node and the store running at slightly different rates.
+const margin = 10
+
+var (
+	// ErrHeld is returned if the lock is held by another node.
+	ErrHeld = errors.New("lock is held by another node")
+
+	// ErrLost is returned if the lease expired or was revoked, and with it the lock.
+	ErrLost = errors.New("lease was lost")
+)
+
+// Backend is a store the lock is held in.
+type Backend interface {
+	// Acquire grants a lease with the given TTL and acquires the lock with it. It
+	// returns ErrHeld if another node holds the lock.
+	Acquire(ctx context.Context, ttl time.Duration) error
+
+	// Renew renews the lease the lock was acquired with. It returns ErrLost if the
+	// lease expired or was revoked.
+	Renew(ctx context.Context) error
+
+	// Release releases the lock and revokes the lease.
+	Release(ctx context.Context) error
+}
+
+// Lock is a lock on the signing authority held with a lease in a Backend.
+type Lock struct {
+	backend Backend
+	ttl     time.Duration
+	clock   types.Clock
+	logger  types.Logger
+
+	// OnLost is called when the lock is lost while it was held, i.e. if its lease
+	// expired or was revoked. It must not block.
+	OnLost func(err error)
+
+	reqMtx  sync.Mutex // serializes the requests to the backend and guards the fields below
+	lastErr error
+	retryAt time.Time
+
+	mtx     sync.Mutex // guards expires
+	expires time.Time
+}
+
+// New creates a new Lock held with leases of the given TTL in backend.
+func New(backend Backend, ttl time.Duration, clock types.Clock, logger types.Logger) *Lock {
+	if logger == nil {
+		logger = types.NewSyncLogger(ioutil.Discard, "", 0)
+	}
+
+	return &Lock{
+		backend: backend,
+		ttl:     ttl,
+		clock:   clock,
+		logger:  logger,
+	}
+}
+
+// TTL returns the TTL of the leases.
+func (l *Lock) TTL() time.Duration {
+	return l.ttl
+}
+
+// Held returns true if the node holds the lock. It never waits for requests to the
+// backend, so that it can be checked before every signature.
+func (l *Lock) Held() bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return l.clock.Now().Before(l.expires)
+}
+
+// setExpires sets the time the lease expires at.
+func (l *Lock) setExpires(t time.Time) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.expires = t
+}
+
+// extend extends the lease that was granted or renewed by a request sent at the given
+// time.
+func (l *Lock) extend(sent time.Time) {
+	l.setExpires(sent.Add(l.ttl - l.ttl/margin))
+}
+
+// Acquire acquires the lock unless it is already held. It returns ErrHeld if another
+// node holds it. After a failed attempt, the lock is only tried to be acquired again
+// once a tenth of the TTL has passed, and the error is returned right away until then,
+// so that an unreachable store doesn't hold up every caller.
+func (l *Lock) Acquire(ctx context.Context) error {
+	l.reqMtx.Lock()
+	defer l.reqMtx.Unlock()
+
+	if l.Held() {
+		return nil
+	}
+	sent := l.clock.Now()
+	if sent.Before(l.retryAt) {
+		return l.lastErr
+	}
+	if err := l.backend.Acquire(ctx, l.ttl); err != nil {
+		l.lastErr, l.retryAt = err, sent.Add(l.ttl/margin)
+		return err
+	}
+	l.extend(sent)
+	l.logger.Info("Acquired the signing lock for %v", l.ttl)
+
+	return nil
+}
+
+// Release releases the lock if it is held, so that the next node doesn't need to
+// wait for its lease to expire.
+func (l *Lock) Release(ctx context.Context) error {
+	l.reqMtx.Lock()
+	defer l.reqMtx.Unlock()
+
+	if !l.Held() {
+		return nil
+	}
+	l.setExpires(time.Time{})
+	if err := l.backend.Release(ctx); err != nil {
+		return err
+	}
+	l.logger.Info("Released the signing lock")
+
+	return nil
+}
+
+// renew renews the lease if the lock is held and returns ErrLost if the lock was
+// lost, either because the store revoked the lease or because it couldn't be renewed
+// before it expired.
+func (l *Lock) renew(ctx context.Context) error {
+	l.reqMtx.Lock()
+	defer l.reqMtx.Unlock()
+
+	l.mtx.Lock()
+	acquired := !l.expires.IsZero()
+	l.mtx.Unlock()
+	if !acquired {
+		return nil
+	}
+
+	sent := l.clock.Now()
+	err := l.backend.Renew(ctx)
+	switch {
+	case err == nil:
+		l.extend(sent)
+		return nil
+	case errors.Is(err, ErrLost):
+		l.setExpires(time.Time{})
+		return err
+	case l.Held():
+		l.logger.Warn("couldn't renew the lease of the signing lock, retrying: %v", err)
+		return nil
+	}
+	l.setExpires(time.Time{})
+
+	return fmt.Errorf("%w: %v", ErrLost, err)
+}
+
+// Run renews the lease of the lock while it is held, three times per TTL, until ctx
+// is done. OnLost is called once the lock is lost.
+func (l *Lock) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-l.clock.After(l.ttl / 3):
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, l.ttl/3)
+		err := l.renew(reqCtx)
+		cancel()
+		if err != nil {
+			l.logger.Error("Lost the signing lock: %v", err)
+			if l.OnLost != nil {
+				l.OnLost(err)
+			}
+		}
+	}
+}
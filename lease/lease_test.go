@@ -0,0 +1,128 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend is a Backend whose responses are set by the tests.
+type fakeBackend struct {
+	mtx        sync.Mutex
+	acquireErr error
+	renewErr   error
+	renewals   int
+	released   bool
+}
+
+func (b *fakeBackend) Acquire(ctx context.Context, ttl time.Duration) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.acquireErr
+}
+
+func (b *fakeBackend) Renew(ctx context.Context) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.renewals++
+	return b.renewErr
+}
+
+func (b *fakeBackend) Release(ctx context.Context) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.released = true
+	return nil
+}
+
+func (b *fakeBackend) setRenewErr(err error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.renewErr = err
+}
+
+func TestLock_AcquireRelease(t *testing.T) {
+	clock := types.NewFakeClock(time.Now())
+	backend := &fakeBackend{acquireErr: ErrHeld}
+	l := New(backend, 30*time.Second, clock, nil)
+
+	assert.ErrorIs(t, l.Acquire(context.Background()), ErrHeld)
+	assert.False(t, l.Held())
+
+	// Failed attempts aren't retried right away.
+	backend.acquireErr = nil
+	assert.ErrorIs(t, l.Acquire(context.Background()), ErrHeld)
+	clock.Advance(3 * time.Second)
+	require.NoError(t, l.Acquire(context.Background()))
+	assert.True(t, l.Held())
+
+	// The lock is given up before the store's lease expires.
+	clock.Advance(27 * time.Second)
+	assert.False(t, l.Held())
+
+	require.NoError(t, l.Acquire(context.Background()))
+	require.NoError(t, l.Release(context.Background()))
+	assert.False(t, l.Held())
+	assert.True(t, backend.released)
+}
+
+func TestLock_Run(t *testing.T) {
+	clock := types.NewFakeClock(time.Now())
+	backend := &fakeBackend{}
+	l := New(backend, 30*time.Second, clock, nil)
+	lost := make(chan error, 1)
+	l.OnLost = func(err error) { lost <- err }
+	require.NoError(t, l.Acquire(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Run(ctx)
+
+	// The lease is renewed three times per TTL, so the lock is kept.
+	for i := 0; i < 6; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(10 * time.Second)
+	}
+	clock.BlockUntil(1)
+	assert.True(t, l.Held())
+	backend.mtx.Lock()
+	assert.Equal(t, 6, backend.renewals)
+	backend.mtx.Unlock()
+
+	// Failed renewals are retried until the lease expires.
+	backend.setRenewErr(errors.New("connection refused"))
+	for i := 0; i < 2; i++ {
+		clock.Advance(10 * time.Second)
+		clock.BlockUntil(1)
+	}
+	assert.True(t, l.Held())
+	assert.Empty(t, lost)
+	clock.Advance(10 * time.Second)
+	assert.ErrorIs(t, <-lost, ErrLost)
+	assert.False(t, l.Held())
+}
+
+func TestLock_RunRevoked(t *testing.T) {
+	clock := types.NewFakeClock(time.Now())
+	backend := &fakeBackend{renewErr: ErrLost}
+	l := New(backend, 30*time.Second, clock, nil)
+	lost := make(chan error, 1)
+	l.OnLost = func(err error) { lost <- err }
+	require.NoError(t, l.Acquire(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go l.Run(ctx)
+
+	// A revoked lease is given up right away.
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Second)
+	assert.ErrorIs(t, <-lost, ErrLost)
+	assert.False(t, l.Held())
+}
@@ -0,0 +1,113 @@
+// Package logadapter adapts common logging libraries to SignCTRL's types.Logger
+// interface, so that embedded SignCTRL nodes can log to an application's existing
+// logging stack.
+package logadapter
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/rs/zerolog"
+	"go.uber.org/zap"
+)
+
+// Std adapts a standard library logger. Levels are written as tags, just like the
+// signctrl binary does.
+func Std(logger *log.Logger) types.Logger {
+	return types.WrapLogger(logger)
+}
+
+// zapLogger adapts a zap logger.
+// Implements the types.Logger interface.
+type zapLogger struct {
+	logger *zap.SugaredLogger
+}
+
+// Zap adapts a zap logger. Fields are passed on to zap as loosely typed key-value
+// pairs.
+func Zap(logger *zap.Logger) types.Logger {
+	return &zapLogger{logger: logger.WithOptions(zap.AddCallerSkip(1)).Sugar()}
+}
+
+// Debug logs a debug message.
+// Implements the types.Logger interface.
+func (z *zapLogger) Debug(format string, v ...interface{}) {
+	z.logger.Debugf(strings.TrimSuffix(format, "\n"), v...)
+}
+
+// Info logs an info message.
+// Implements the types.Logger interface.
+func (z *zapLogger) Info(format string, v ...interface{}) {
+	z.logger.Infof(strings.TrimSuffix(format, "\n"), v...)
+}
+
+// Warn logs a warning message.
+// Implements the types.Logger interface.
+func (z *zapLogger) Warn(format string, v ...interface{}) {
+	z.logger.Warnf(strings.TrimSuffix(format, "\n"), v...)
+}
+
+// Error logs an error message.
+// Implements the types.Logger interface.
+func (z *zapLogger) Error(format string, v ...interface{}) {
+	z.logger.Errorf(strings.TrimSuffix(format, "\n"), v...)
+}
+
+// With returns a logger with the given fields.
+// Implements the types.Logger interface.
+func (z *zapLogger) With(keyvals ...interface{}) types.Logger {
+	return &zapLogger{logger: z.logger.With(keyvals...)}
+}
+
+// zerologLogger adapts a zerolog logger.
+// Implements the types.Logger interface.
+type zerologLogger struct {
+	logger zerolog.Logger
+}
+
+// Zerolog adapts a zerolog logger.
+func Zerolog(logger zerolog.Logger) types.Logger {
+	return &zerologLogger{logger: logger}
+}
+
+// Debug logs a debug message.
+// Implements the types.Logger interface.
+func (z *zerologLogger) Debug(format string, v ...interface{}) {
+	z.logger.Debug().Msgf(strings.TrimSuffix(format, "\n"), v...)
+}
+
+// Info logs an info message.
+// Implements the types.Logger interface.
+func (z *zerologLogger) Info(format string, v ...interface{}) {
+	z.logger.Info().Msgf(strings.TrimSuffix(format, "\n"), v...)
+}
+
+// Warn logs a warning message.
+// Implements the types.Logger interface.
+func (z *zerologLogger) Warn(format string, v ...interface{}) {
+	z.logger.Warn().Msgf(strings.TrimSuffix(format, "\n"), v...)
+}
+
+// Error logs an error message.
+// Implements the types.Logger interface.
+func (z *zerologLogger) Error(format string, v ...interface{}) {
+	z.logger.Error().Msgf(strings.TrimSuffix(format, "\n"), v...)
+}
+
+// With returns a logger with the given fields. Keys that aren't strings are
+// formatted with fmt.Sprint.
+// Implements the types.Logger interface.
+func (z *zerologLogger) With(keyvals ...interface{}) types.Logger {
+	ctx := z.logger.With()
+	for i := 0; i < len(keyvals); i += 2 {
+		var val interface{} = "MISSING"
+		if i+1 < len(keyvals) {
+			val = keyvals[i+1]
+		}
+		ctx = ctx.Interface(fmt.Sprint(keyvals[i]), val)
+	}
+
+	return &zerologLogger{logger: ctx.Logger()}
+}
@@ -0,0 +1,49 @@
+package logadapter
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStd(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Std(log.New(&buf, "", 0)).With("rank", 1)
+	logger.Info("Promote validator (%v -> %v)\n", 2, 1)
+	assert.Equal(t, "[INFO]  signctrl: Promote validator (2 -> 1) rank=1\n", buf.String())
+}
+
+func TestZap(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := Zap(zap.New(core)).With("rank", 1)
+	logger.Debug("debug %v", 1)
+	logger.Info("info %v\n", 2)
+	logger.Warn("warn %v", 3)
+	logger.Error("error %v", 4)
+
+	entries := logs.AllUntimed()
+	assert.Len(t, entries, 4)
+	assert.Equal(t, "debug 1", entries[0].Message)
+	assert.Equal(t, "info 2", entries[1].Message)
+	assert.Equal(t, zapcore.ErrorLevel, entries[3].Level)
+	assert.Equal(t, map[string]interface{}{"rank": int64(1)}, entries[0].ContextMap())
+}
+
+func TestZerolog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := Zerolog(zerolog.New(&buf)).With("rank", 1, "missing")
+	logger.Warn("warn %v\n", 3)
+	assert.Equal(t, `{"level":"warn","rank":1,"missing":"MISSING","message":"warn 3"}`+"\n", buf.String())
+
+	buf.Reset()
+	logger.Debug("debug")
+	logger.Info("info")
+	logger.Error("error")
+	assert.Equal(t, 3, bytes.Count(buf.Bytes(), []byte("\n")))
+}
@@ -0,0 +1,202 @@
+// Package maintenance implements maintenance windows, i.e. recurring periods of time
+// given by a cron-like schedule and a duration, during which planned chain or
+// infrastructure maintenance is expected to make the validator miss blocks.
+package maintenance
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxDuration is the maximum duration of a maintenance window.
+const MaxDuration = 24 * time.Hour
+
+// field defines the range of values of a schedule's field.
+type field struct {
+	name     string
+	min, max int
+}
+
+// fields are the fields of a schedule in the order of their appearance.
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Schedule is a cron-like schedule of the times maintenance windows start at. It
+// consists of the five fields minute, hour, day of month, month and day of week, each
+// of which is either "*", a value, a range "a-b" or a comma-separated list thereof,
+// optionally followed by a step "/n". Sunday is both 0 and 7. As in cron, a time
+// matches if the day of month or the day of week matches in case both are restricted.
+type Schedule struct {
+	spec   string
+	values [5]uint64
+	anyDay [2]bool
+}
+
+// ParseSchedule parses the given cron-like schedule, e.g. "0 3 * * 0" for Sundays at
+// 03:00.
+func ParseSchedule(spec string) (*Schedule, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("schedule %q must have %v fields", spec, len(fields))
+	}
+
+	s := &Schedule{spec: strings.Join(parts, " ")}
+	for i, part := range parts {
+		values, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %v", spec, err)
+		}
+		s.values[i] = values
+	}
+	// Sunday is both 0 and 7.
+	if s.values[4]&(1<<7) != 0 {
+		s.values[4] |= 1
+	}
+	s.anyDay = [2]bool{parts[2] == "*", parts[4] == "*"}
+
+	return s, nil
+}
+
+// parseField parses a field of a schedule into a bit set of its values.
+func parseField(part string, f field) (uint64, error) {
+	var values uint64
+	for _, item := range strings.Split(part, ",") {
+		rng, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in %v %q", f.name, item)
+			}
+			rng, step = item[:i], n
+		}
+
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid %v %q", f.name, item)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid %v %q", f.name, item)
+				}
+			} else if step > 1 {
+				// "a/n" is short for "a-max/n".
+				hi = f.max
+			}
+		}
+		if lo < f.min || hi > f.max || lo > hi {
+			return 0, fmt.Errorf("%v %q must be within %v-%v", f.name, item, f.min, f.max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			values |= 1 << uint(v)
+		}
+	}
+
+	return values, nil
+}
+
+// String returns the schedule's spec.
+func (s *Schedule) String() string {
+	return s.spec
+}
+
+// Matches returns true if the schedule matches the minute of the given time.
+func (s *Schedule) Matches(t time.Time) bool {
+	has := func(i, v int) bool { return s.values[i]&(1<<uint(v)) != 0 }
+	if !has(0, t.Minute()) || !has(1, t.Hour()) || !has(3, int(t.Month())) {
+		return false
+	}
+
+	dom, dow := has(2, t.Day()), has(4, int(t.Weekday()))
+	switch {
+	case s.anyDay[0] && s.anyDay[1]:
+		return true
+	case s.anyDay[0]:
+		return dow
+	case s.anyDay[1]:
+		return dom
+	}
+
+	return dom || dow
+}
+
+// Window is a recurring maintenance window.
+type Window struct {
+	// Schedule is the schedule of the times the window starts at.
+	Schedule *Schedule
+
+	// Duration is the time the window lasts after each start.
+	Duration time.Duration
+}
+
+// ParseWindow parses a window from a schedule followed by a duration, e.g.
+// "0 3 * * 0 2h" for two hours from 03:00 on Sundays.
+func ParseWindow(spec string) (Window, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields)+1 {
+		return Window{}, fmt.Errorf("window %q must consist of a schedule with %v fields and a duration", spec, len(fields))
+	}
+
+	schedule, err := ParseSchedule(strings.Join(parts[:len(fields)], " "))
+	if err != nil {
+		return Window{}, err
+	}
+	d, err := time.ParseDuration(parts[len(fields)])
+	if err != nil {
+		return Window{}, fmt.Errorf("window %q: %v", spec, err)
+	}
+	if d <= 0 || d > MaxDuration {
+		return Window{}, fmt.Errorf("window %q: duration must be positive and at most %v", spec, MaxDuration)
+	}
+
+	return Window{Schedule: schedule, Duration: d}, nil
+}
+
+// ParseWindows parses the given windows.
+func ParseWindows(specs []string) ([]Window, error) {
+	windows := make([]Window, 0, len(specs))
+	var errs []string
+	for _, spec := range specs {
+		w, err := ParseWindow(spec)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		windows = append(windows, w)
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+
+	return windows, nil
+}
+
+// String returns the window's spec.
+func (w Window) String() string {
+	return fmt.Sprintf("%v %v", w.Schedule, w.Duration)
+}
+
+// ActiveUntil returns the time the window ends at if it is active at the given time.
+// The schedule is matched in the time's location.
+func (w Window) ActiveUntil(t time.Time) (time.Time, bool) {
+	// Find the latest start within the window's duration before t.
+	for start := t.Truncate(time.Minute); start.Add(w.Duration).After(t); start = start.Add(-time.Minute) {
+		if w.Schedule.Matches(start) {
+			return start.Add(w.Duration), true
+		}
+	}
+
+	return time.Time{}, false
+}
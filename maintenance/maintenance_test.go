@@ -0,0 +1,97 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// date returns the given time on 2021-08-<day> in UTC. 2021-08-01 is a Sunday.
+func date(day, hour, min int) time.Time {
+	return time.Date(2021, time.August, day, hour, min, 0, 0, time.UTC)
+}
+
+func TestParseSchedule(t *testing.T) {
+	for _, spec := range []string{"* * * * *", "0 3 * * 0", "*/15 0-6 1,15 * 1-5", "30 2 * 1-12/3 7"} {
+		s, err := ParseSchedule(spec)
+		assert.NoError(t, err, spec)
+		assert.Equal(t, spec, s.String())
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		_, err := ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestSchedule_Matches(t *testing.T) {
+	tests := []struct {
+		spec  string
+		t     time.Time
+		match bool
+	}{
+		{"* * * * *", date(3, 12, 34), true},
+		{"0 3 * * 0", date(1, 3, 0), true},
+		{"0 3 * * 0", date(1, 3, 1), false},
+		{"0 3 * * 0", date(2, 3, 0), false},
+		{"0 3 * * 7", date(8, 3, 0), true},
+		{"*/15 * * * *", date(3, 0, 45), true},
+		{"*/15 * * * *", date(3, 0, 46), false},
+		{"5/20 * * * *", date(3, 0, 25), true},
+		{"0 0 15 * *", date(15, 0, 0), true},
+		{"0 0 15 * *", date(16, 0, 0), false},
+		// If both days are restricted, either of them has to match.
+		{"0 0 15 * 1", date(2, 0, 0), true},
+		{"0 0 15 * 1", date(15, 0, 0), true},
+		{"0 0 15 * 1", date(3, 0, 0), false},
+		{"0 0 * 9 *", date(3, 0, 0), false},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.spec)
+		require.NoError(t, err)
+		assert.Equal(t, tt.match, s.Matches(tt.t), "%v at %v", tt.spec, tt.t)
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	w, err := ParseWindow("0 3 * * 0 2h")
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, w.Duration)
+	assert.Equal(t, "0 3 * * 0 2h0m0s", w.String())
+
+	for _, spec := range []string{"0 3 * * 0", "0 3 * * 0 2", "0 3 * * 0 0s", "0 3 * * 0 25h", "0 3 * * 8 2h"} {
+		_, err := ParseWindow(spec)
+		assert.Error(t, err, spec)
+	}
+
+	windows, err := ParseWindows([]string{"0 3 * * 0 2h", "*/30 * * * * 5m"})
+	assert.NoError(t, err)
+	assert.Len(t, windows, 2)
+	_, err = ParseWindows([]string{"0 3 * * 0 2h", "invalid"})
+	assert.Error(t, err)
+}
+
+func TestWindow_ActiveUntil(t *testing.T) {
+	w, err := ParseWindow("0 3 * * 0 2h")
+	require.NoError(t, err)
+
+	_, active := w.ActiveUntil(date(1, 2, 59))
+	assert.False(t, active)
+	until, active := w.ActiveUntil(date(1, 3, 0))
+	assert.True(t, active)
+	assert.Equal(t, date(1, 5, 0), until)
+	until, active = w.ActiveUntil(date(1, 4, 59).Add(30 * time.Second))
+	assert.True(t, active)
+	assert.Equal(t, date(1, 5, 0), until)
+	_, active = w.ActiveUntil(date(1, 5, 0))
+	assert.False(t, active)
+
+	// Windows may span midnight.
+	w, err = ParseWindow("30 23 * * * 1h")
+	require.NoError(t, err)
+	until, active = w.ActiveUntil(date(2, 0, 15))
+	assert.True(t, active)
+	assert.Equal(t, date(2, 0, 30), until)
+}
@@ -0,0 +1,131 @@
+// Package ntp implements a minimal SNTP client (RFC 4330) that measures the offset of
+// the local clock to an NTP server. SignCTRL only needs to know whether its clock is
+// roughly right, so the offset of a single exchange is good enough.
+package ntp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// DefaultPort is the port NTP servers listen on.
+	DefaultPort = "123"
+
+	// packetSize is the size of an NTP packet without extensions.
+	packetSize = 48
+
+	// versionClient is the first byte of a request: no leap second warning, version
+	// 4 and client mode.
+	versionClient = 4<<3 | 3
+
+	// modeServer is the mode of a server's response.
+	modeServer = 4
+
+	// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and the
+	// Unix epoch (1970).
+	ntpEpochOffset = 2208988800
+)
+
+var (
+	// ErrInvalidResponse is returned if the server's response isn't a valid answer to
+	// the request, e.g. because the server isn't synchronized.
+	ErrInvalidResponse = errors.New("invalid NTP response")
+)
+
+// Response is the result of a query.
+type Response struct {
+	// Offset is the time the local clock is behind the server's clock. It is negative
+	// if the local clock is ahead.
+	Offset time.Duration
+
+	// RTT is the round trip time of the exchange.
+	RTT time.Duration
+
+	// Stratum is the server's distance to its reference clock.
+	Stratum int
+}
+
+// toNTP converts t into an NTP timestamp.
+func toNTP(t time.Time) uint64 {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+
+	return secs<<32 | frac
+}
+
+// fromNTP converts an NTP timestamp into a time.
+func fromNTP(ts uint64) time.Time {
+	secs := int64(ts>>32) - ntpEpochOffset
+	nanos := (ts & 0xffffffff) * uint64(time.Second) >> 32
+
+	return time.Unix(secs, int64(nanos))
+}
+
+// Query measures the offset of the local clock to the given server, e.g.
+// pool.ntp.org. The port defaults to DefaultPort.
+func Query(ctx context.Context, server string) (*Response, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, DefaultPort)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	req := make([]byte, packetSize)
+	req[0] = versionClient
+	sent := time.Now()
+	binary.BigEndian.PutUint64(req[40:], toNTP(sent))
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	resp := make([]byte, packetSize)
+	n, err := conn.Read(resp)
+	received := time.Now()
+	if err != nil {
+		return nil, err
+	}
+
+	return parse(resp[:n], req, sent, received)
+}
+
+// parse computes the offset from the server's response to req, which was sent and
+// received at the given local times.
+func parse(resp, req []byte, sent, received time.Time) (*Response, error) {
+	if len(resp) < packetSize {
+		return nil, fmt.Errorf("%w: %v bytes", ErrInvalidResponse, len(resp))
+	}
+	if mode := resp[0] & 0x7; mode != modeServer {
+		return nil, fmt.Errorf("%w: mode %v", ErrInvalidResponse, mode)
+	}
+	// A stratum of 0 is a "kiss-o'-death" telling the client to back off.
+	stratum := int(resp[1])
+	if stratum == 0 || stratum > 15 {
+		return nil, fmt.Errorf("%w: stratum %v", ErrInvalidResponse, stratum)
+	}
+	// The server echoes the request's transmit timestamp as the originate timestamp.
+	if binary.BigEndian.Uint64(resp[24:]) != binary.BigEndian.Uint64(req[40:]) {
+		return nil, fmt.Errorf("%w: originate timestamp doesn't match", ErrInvalidResponse)
+	}
+
+	// The offset is the mean of the differences of the request and the response,
+	// which cancels out the network delay if it's symmetric.
+	serverReceived := fromNTP(binary.BigEndian.Uint64(resp[32:]))
+	serverSent := fromNTP(binary.BigEndian.Uint64(resp[40:]))
+	offset := (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2
+	rtt := received.Sub(sent) - serverSent.Sub(serverReceived)
+
+	return &Response{Offset: offset, RTT: rtt, Stratum: stratum}, nil
+}
@@ -0,0 +1,92 @@
+package ntp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer starts an NTP server whose clock is offset from the local one and
+// returns its address.
+func testServer(t *testing.T, offset time.Duration, stratum byte) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, packetSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < packetSize {
+				continue
+			}
+			now := time.Now().Add(offset)
+			resp := make([]byte, packetSize)
+			resp[0] = 4<<3 | modeServer
+			resp[1] = stratum
+			copy(resp[24:32], buf[40:48])
+			binary.BigEndian.PutUint64(resp[32:], toNTP(now))
+			binary.BigEndian.PutUint64(resp[40:], toNTP(now))
+			_, _ = conn.WriteTo(resp, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestTimestamps(t *testing.T) {
+	now := time.Unix(1600000000, 123456789)
+	assert.WithinDuration(t, now, fromNTP(toNTP(now)), time.Nanosecond)
+}
+
+func TestQuery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := Query(ctx, testServer(t, 3*time.Second, 2))
+	require.NoError(t, err)
+	assert.InDelta(t, float64(3*time.Second), float64(resp.Offset), float64(100*time.Millisecond))
+	assert.Equal(t, 2, resp.Stratum)
+
+	resp, err = Query(ctx, testServer(t, -time.Second, 1))
+	require.NoError(t, err)
+	assert.InDelta(t, float64(-time.Second), float64(resp.Offset), float64(100*time.Millisecond))
+}
+
+func TestQuery_Unsynchronized(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := Query(ctx, testServer(t, 0, 0))
+	assert.True(t, errors.Is(err, ErrInvalidResponse))
+}
+
+func TestParse(t *testing.T) {
+	req := make([]byte, packetSize)
+	binary.BigEndian.PutUint64(req[40:], 42)
+
+	// Too short.
+	_, err := parse(make([]byte, 10), req, time.Now(), time.Now())
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+
+	// Client mode.
+	resp := make([]byte, packetSize)
+	resp[0], resp[1] = 4<<3|3, 1
+	_, err = parse(resp, req, time.Now(), time.Now())
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+
+	// Originate timestamp doesn't match.
+	resp[0] = 4<<3 | modeServer
+	_, err = parse(resp, req, time.Now(), time.Now())
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+}
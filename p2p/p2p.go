@@ -0,0 +1,441 @@
+// Package p2p implements the heartbeat protocol between the SignCTRL nodes in a set.
+// Every node regularly sends each of its peers a heartbeat with its current rank and
+// the height it last signed at, so that the nodes know which of them are alive and
+// who is signing, instead of inferring it from missed blocks alone.
+//
+// Heartbeats are sent over TCP, one per connection, and authenticated with an
+// HMAC-SHA256 keyed with a secret shared by the set. Heartbeats that are too old, or
+// not newer than the last one received from the same node, are discarded, so that
+// they can't be replayed. As the secret is shared, every node in the set can speak
+// for any other one.
+//
+// Other subsystems, like the leader election, exchange requests and responses over
+// the same connections. A response is authenticated together with the request it
+// replies to, so that it can't be replayed in reply to another request.
+package p2p
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+const (
+	// SecretSize is the size of the shared secret in bytes.
+	SecretSize = 32
+
+	// maxMessageSize is the maximum size of a sealed heartbeat in bytes.
+	maxMessageSize = 4096
+
+	// readTimeout is the time a peer has to send its message once it's connected.
+	readTimeout = time.Second
+
+	// TypeHeartbeat is the message type of heartbeats.
+	TypeHeartbeat = "heartbeat"
+)
+
+var (
+	// ErrInvalidMAC is returned if a heartbeat wasn't sealed with the shared secret.
+	ErrInvalidMAC = errors.New("heartbeat has an invalid MAC")
+
+	// ErrReplayed is returned if a heartbeat is too old or not newer than the last one
+	// received from the same node.
+	ErrReplayed = errors.New("heartbeat is outdated or was replayed")
+
+	// ErrOwnHeartbeat is returned if a heartbeat carries the node's own name, e.g.
+	// because the node is listed as its own peer.
+	ErrOwnHeartbeat = errors.New("heartbeat was sent by this node")
+
+	// ErrUnexpectedType is returned if a message has an unexpected type, or a type no
+	// handler is registered for.
+	ErrUnexpectedType = errors.New("unexpected message type")
+
+	// ErrNotInReply is returned if a response wasn't sent in reply to the request.
+	ErrNotInReply = errors.New("response wasn't sent in reply to the request")
+)
+
+// Handler handles a request of the type it is registered for and returns the
+// response to it.
+type Handler func(payload json.RawMessage) (interface{}, error)
+
+// Heartbeat is the message the nodes in a set send each other.
+type Heartbeat struct {
+	// Node is the name of the sending node.
+	Node string `json:"node"`
+
+	// Time is the time the heartbeat was sent at, by the sender's clock.
+	Time time.Time `json:"time"`
+
+	// Rank is the sender's current rank.
+	Rank int `json:"rank"`
+
+	// Height is the height the sender last signed a vote or proposal at, or 0 if it
+	// hasn't signed anything since it was started.
+	Height int64 `json:"height"`
+
+	// Epoch is the epoch the sender last took the signing authority in, or 0 if it
+	// never did.
+	Epoch uint64 `json:"epoch,omitempty"`
+}
+
+// envelope is a sealed message as it is sent over the wire.
+type envelope struct {
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	InReplyTo string          `json:"in_reply_to,omitempty"`
+	MAC       string          `json:"mac"`
+}
+
+// mac returns the hex-encoded HMAC-SHA256 of the envelope's contents keyed with
+// secret.
+func (env envelope) mac(secret []byte) string {
+	h := hmac.New(sha256.New, secret)
+	for _, part := range [][]byte{[]byte(env.Type), []byte(env.InReplyTo), env.Payload} {
+		_, _ = h.Write(part)
+		_, _ = h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// seal encodes v as a message of the given type, in reply to the message with the
+// given MAC if set, and authenticates it with the given secret.
+func seal(secret []byte, typ string, inReplyTo string, v interface{}) ([]byte, envelope, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, envelope{}, err
+	}
+	env := envelope{Type: typ, Payload: payload, InReplyTo: inReplyTo}
+	env.MAC = env.mac(secret)
+	sealed, err := json.Marshal(env)
+
+	return sealed, env, err
+}
+
+// open verifies a sealed message against the given secret.
+func open(secret, sealed []byte) (envelope, error) {
+	var env envelope
+	if err := json.Unmarshal(sealed, &env); err != nil {
+		return envelope{}, err
+	}
+	if !hmac.Equal([]byte(env.MAC), []byte(env.mac(secret))) {
+		return envelope{}, ErrInvalidMAC
+	}
+
+	return env, nil
+}
+
+// Seal encodes hb and authenticates it with the given secret.
+func Seal(secret []byte, hb Heartbeat) ([]byte, error) {
+	sealed, _, err := seal(secret, TypeHeartbeat, "", hb)
+	return sealed, err
+}
+
+// Open verifies a sealed heartbeat against the given secret and decodes it.
+func Open(secret, sealed []byte) (Heartbeat, error) {
+	env, err := open(secret, sealed)
+	if err != nil {
+		return Heartbeat{}, err
+	}
+	if env.Type != TypeHeartbeat {
+		return Heartbeat{}, fmt.Errorf("%w: %v", ErrUnexpectedType, env.Type)
+	}
+	var hb Heartbeat
+	if err := json.Unmarshal(env.Payload, &hb); err != nil {
+		return Heartbeat{}, err
+	}
+
+	return hb, nil
+}
+
+// LoadSecret loads the hex-encoded shared secret from the file at the given path.
+func LoadSecret(path string) ([]byte, error) {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := hex.DecodeString(strings.TrimSpace(string(bz)))
+	if err != nil || len(secret) != SecretSize {
+		return nil, fmt.Errorf("%v must hold a hex-encoded secret of %v bytes", path, SecretSize)
+	}
+
+	return secret, nil
+}
+
+// PeerStatus is what a node knows about one of its peers.
+type PeerStatus struct {
+	// Node is the peer's name.
+	Node string `json:"node"`
+
+	// Rank is the peer's rank as of its last heartbeat.
+	Rank int `json:"rank"`
+
+	// Height is the height the peer last signed at as of its last heartbeat.
+	Height int64 `json:"height"`
+
+	// Epoch is the epoch the peer last took the signing authority in as of its last
+	// heartbeat.
+	Epoch uint64 `json:"epoch"`
+
+	// LastSeen is the time the peer's last heartbeat was received at.
+	LastSeen time.Time `json:"last_seen"`
+
+	// Live is true if the peer's last heartbeat was received within the timeout.
+	Live bool `json:"live"`
+}
+
+// Node sends heartbeats to the peers of a node and keeps track of the heartbeats it
+// receives from them. Further subsystems can exchange requests and responses with
+// the peers through it.
+type Node struct {
+	// Name is the node's name in its heartbeats.
+	Name string
+
+	// Timeout is the time after which a peer is no longer considered live if no
+	// heartbeat was received from it. Heartbeats older than the timeout are discarded.
+	// It is also the timeout of requests.
+	Timeout time.Duration
+
+	// Clock is the clock heartbeats are timed with.
+	Clock types.Clock
+
+	secret []byte
+
+	mtx      sync.RWMutex // guards peers and handlers
+	peers    map[string]peer
+	handlers map[string]Handler
+}
+
+// peer is the last heartbeat received from a peer.
+type peer struct {
+	hb       Heartbeat
+	received time.Time
+}
+
+// NewNode creates a new node with the given name and shared secret.
+func NewNode(name string, secret []byte, timeout time.Duration, clock types.Clock) *Node {
+	return &Node{
+		Name:     name,
+		Timeout:  timeout,
+		Clock:    clock,
+		secret:   secret,
+		peers:    make(map[string]peer),
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Handle registers h as the handler for requests of the given type.
+func (n *Node) Handle(typ string, h Handler) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.handlers[typ] = h
+}
+
+// dial connects to the peer at the given address, e.g. "10.0.0.2:26660". The
+// connection's deadline is the one of ctx.
+func (n *Node) dial(ctx context.Context, addr string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	return conn, nil
+}
+
+// Send sends hb to the peer at the given address, e.g. "10.0.0.2:26660".
+func (n *Node) Send(ctx context.Context, addr string, hb Heartbeat) error {
+	sealed, err := Seal(n.secret, hb)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, n.Timeout)
+	defer cancel()
+	conn, err := n.dial(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(sealed)
+
+	return err
+}
+
+// Request sends req as a request of the given type to the peer at the given address
+// and decodes the peer's response into resp.
+func (n *Node) Request(ctx context.Context, addr string, typ string, req, resp interface{}) error {
+	sealed, sent, err := seal(n.secret, typ, "", req)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, n.Timeout)
+	defer cancel()
+	conn, err := n.dial(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Write(sealed); err != nil {
+		return err
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.CloseWrite()
+	}
+	bz, err := ioutil.ReadAll(io.LimitReader(conn, maxMessageSize))
+	if err != nil {
+		return err
+	}
+	env, err := open(n.secret, bz)
+	if err != nil {
+		return err
+	}
+	if env.Type != typ {
+		return fmt.Errorf("%w: %v", ErrUnexpectedType, env.Type)
+	}
+	if env.InReplyTo != sent.MAC {
+		return ErrNotInReply
+	}
+
+	return json.Unmarshal(env.Payload, resp)
+}
+
+// Receive verifies a sealed heartbeat and records it. It returns the heartbeat if it
+// was accepted.
+func (n *Node) Receive(sealed []byte) (Heartbeat, error) {
+	hb, err := Open(n.secret, sealed)
+	if err != nil {
+		return Heartbeat{}, err
+	}
+
+	return hb, n.record(hb)
+}
+
+// record records a heartbeat unless it was sent by the node itself, or is outdated or
+// replayed.
+func (n *Node) record(hb Heartbeat) error {
+	if hb.Node == n.Name {
+		return ErrOwnHeartbeat
+	}
+
+	now := n.Clock.Now()
+	if age := now.Sub(hb.Time); age > n.Timeout || age < -n.Timeout {
+		return fmt.Errorf("%w: sent %v ago", ErrReplayed, age)
+	}
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	if last, ok := n.peers[hb.Node]; ok && !hb.Time.After(last.hb.Time) {
+		return ErrReplayed
+	}
+	n.peers[hb.Node] = peer{hb: hb, received: now}
+
+	return nil
+}
+
+// serveConn handles the heartbeat or request sent over conn.
+func (n *Node) serveConn(conn net.Conn) error {
+	sealed, err := ioutil.ReadAll(io.LimitReader(conn, maxMessageSize))
+	if err != nil {
+		return err
+	}
+	env, err := open(n.secret, sealed)
+	if err != nil {
+		return err
+	}
+	if env.Type == TypeHeartbeat {
+		var hb Heartbeat
+		if err := json.Unmarshal(env.Payload, &hb); err != nil {
+			return err
+		}
+		return n.record(hb)
+	}
+
+	n.mtx.RLock()
+	h, ok := n.handlers[env.Type]
+	n.mtx.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrUnexpectedType, env.Type)
+	}
+	resp, err := h(env.Payload)
+	if err != nil {
+		return err
+	}
+	bz, _, err := seal(n.secret, env.Type, env.MAC, resp)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(bz)
+
+	return err
+}
+
+// Serve accepts heartbeats and requests on ln until ctx is done or ln is closed.
+// Heartbeats and requests that are rejected are passed to onError, if set.
+func (n *Node) Serve(ctx context.Context, ln net.Listener, onError func(remote net.Addr, err error)) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ln.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.SetDeadline(time.Now().Add(readTimeout))
+		err = n.serveConn(conn)
+		conn.Close()
+		if err != nil && onError != nil {
+			onError(conn.RemoteAddr(), err)
+		}
+	}
+}
+
+// Peers returns the status of all peers a heartbeat was received from, sorted by
+// their names.
+func (n *Node) Peers() []PeerStatus {
+	now := n.Clock.Now()
+
+	n.mtx.RLock()
+	peers := make([]PeerStatus, 0, len(n.peers))
+	for _, p := range n.peers {
+		peers = append(peers, PeerStatus{
+			Node:     p.hb.Node,
+			Rank:     p.hb.Rank,
+			Height:   p.hb.Height,
+			Epoch:    p.hb.Epoch,
+			LastSeen: p.received,
+			Live:     now.Sub(p.received) <= n.Timeout,
+		})
+	}
+	n.mtx.RUnlock()
+
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Node < peers[j].Node })
+
+	return peers
+}
@@ -0,0 +1,160 @@
+package p2p
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSecret = []byte(strings.Repeat("s", SecretSize))
+
+func TestSealOpen(t *testing.T) {
+	hb := Heartbeat{Node: "a", Time: time.Unix(1000, 0).UTC(), Rank: 1, Height: 42}
+	sealed, err := Seal(testSecret, hb)
+	require.NoError(t, err)
+
+	opened, err := Open(testSecret, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, hb, opened)
+
+	// Wrong secret.
+	_, err = Open([]byte(strings.Repeat("x", SecretSize)), sealed)
+	assert.ErrorIs(t, err, ErrInvalidMAC)
+
+	// Tampered heartbeat.
+	tampered := []byte(strings.Replace(string(sealed), `"rank":1`, `"rank":2`, 1))
+	_, err = Open(testSecret, tampered)
+	assert.ErrorIs(t, err, ErrInvalidMAC)
+}
+
+func TestLoadSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, ioutil.WriteFile(path, []byte(strings.Repeat("ab", SecretSize)+"\n"), 0600))
+	secret, err := LoadSecret(path)
+	require.NoError(t, err)
+	assert.Len(t, secret, SecretSize)
+
+	// Too short.
+	require.NoError(t, ioutil.WriteFile(path, []byte("abab"), 0600))
+	_, err = LoadSecret(path)
+	assert.Error(t, err)
+
+	// Missing.
+	_, err = LoadSecret(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestReceive(t *testing.T) {
+	clock := types.NewFakeClock(time.Unix(1000, 0))
+	n := NewNode("a", testSecret, 5*time.Second, clock)
+
+	seal := func(hb Heartbeat) []byte {
+		sealed, err := Seal(testSecret, hb)
+		require.NoError(t, err)
+		return sealed
+	}
+
+	hb := Heartbeat{Node: "b", Time: clock.Now(), Rank: 1, Height: 10}
+	_, err := n.Receive(seal(hb))
+	require.NoError(t, err)
+	assert.Equal(t, []PeerStatus{{Node: "b", Rank: 1, Height: 10, LastSeen: clock.Now(), Live: true}}, n.Peers())
+
+	// Replayed.
+	_, err = n.Receive(seal(hb))
+	assert.ErrorIs(t, err, ErrReplayed)
+
+	// Too old.
+	_, err = n.Receive(seal(Heartbeat{Node: "c", Time: clock.Now().Add(-time.Minute)}))
+	assert.ErrorIs(t, err, ErrReplayed)
+
+	// Sent by the node itself.
+	_, err = n.Receive(seal(Heartbeat{Node: "a", Time: clock.Now()}))
+	assert.ErrorIs(t, err, ErrOwnHeartbeat)
+
+	// The peer is no longer live once the timeout passed.
+	clock.Advance(6 * time.Second)
+	assert.False(t, n.Peers()[0].Live)
+}
+
+func TestSendServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	a := NewNode("a", testSecret, 5*time.Second, types.SystemClock)
+	served := make(chan struct{})
+	go func() {
+		a.Serve(ctx, ln, nil)
+		close(served)
+	}()
+
+	b := NewNode("b", testSecret, 5*time.Second, types.SystemClock)
+	require.NoError(t, b.Send(ctx, ln.Addr().String(), Heartbeat{Node: "b", Time: time.Now(), Rank: 2, Height: 7}))
+	assert.Eventually(t, func() bool {
+		peers := a.Peers()
+		return len(peers) == 1 && peers[0].Node == "b" && peers[0].Rank == 2 && peers[0].Live
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// Heartbeats sealed with another secret are rejected.
+	rejected := make(chan error, 1)
+	ln2, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go a.Serve(ctx, ln2, func(_ net.Addr, err error) { rejected <- err })
+	c := NewNode("c", []byte(strings.Repeat("x", SecretSize)), 5*time.Second, types.SystemClock)
+	require.NoError(t, c.Send(ctx, ln2.Addr().String(), Heartbeat{Node: "c", Time: time.Now()}))
+	assert.ErrorIs(t, <-rejected, ErrInvalidMAC)
+
+	cancel()
+	<-served
+}
+
+func TestRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type echo struct {
+		Text string `json:"text"`
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	a := NewNode("a", testSecret, 5*time.Second, types.SystemClock)
+	a.Handle("echo", func(payload json.RawMessage) (interface{}, error) {
+		var req echo
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return echo{Text: req.Text + "!"}, nil
+	})
+	a.Handle("fail", func(payload json.RawMessage) (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	rejected := make(chan error, 3)
+	go a.Serve(ctx, ln, func(_ net.Addr, err error) { rejected <- err })
+
+	b := NewNode("b", testSecret, 5*time.Second, types.SystemClock)
+	var resp echo
+	require.NoError(t, b.Request(ctx, ln.Addr().String(), "echo", echo{Text: "hi"}, &resp))
+	assert.Equal(t, "hi!", resp.Text)
+
+	// Unknown types and failed handlers aren't answered.
+	assert.Error(t, b.Request(ctx, ln.Addr().String(), "unknown", echo{}, &resp))
+	assert.ErrorIs(t, <-rejected, ErrUnexpectedType)
+	assert.Error(t, b.Request(ctx, ln.Addr().String(), "fail", echo{}, &resp))
+	assert.EqualError(t, <-rejected, "failed")
+
+	// Requests sealed with another secret are rejected.
+	c := NewNode("c", []byte(strings.Repeat("x", SecretSize)), 5*time.Second, types.SystemClock)
+	assert.Error(t, c.Request(ctx, ln.Addr().String(), "echo", echo{Text: "hi"}, &resp))
+	assert.ErrorIs(t, <-rejected, ErrInvalidMAC)
+}
@@ -0,0 +1,202 @@
+// Package presets provides the configuration presets of well-known networks, which
+// prefill the chain ID, the threshold and the RPC client's timeout of a new
+// configuration file via signctrl init --network. The presets are embedded into the
+// SignCTRL binary and can be updated or extended with a networks.json file in the
+// configuration directory.
+package presets
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/rpc"
+)
+
+const (
+	// RegistryFile is the name of the file in the configuration directory that
+	// updates and extends the embedded presets.
+	RegistryFile = "networks.json"
+
+	// promotionDelay is the time the validator may be down before a backup is
+	// promoted, which the threshold is derived from.
+	promotionDelay = time.Minute
+
+	// minThreshold is the lowest threshold allowed in the configuration file.
+	minThreshold = 2
+
+	// minRPCTimeout is the lowest RPC timeout derived from the block time.
+	minRPCTimeout = time.Second
+)
+
+// ErrUnknownNetwork is returned if there is no preset for the requested network.
+var ErrUnknownNetwork = errors.New("unknown network")
+
+var (
+	// Embed the networks.json into the SignCTRL binary.
+	//go:embed networks.json
+	networksFile embed.FS
+)
+
+// Preset is the configuration preset of a network.
+type Preset struct {
+	// Name is the name the preset is selected by.
+	Name string `json:"name"`
+
+	// ChainID is the network's chain ID.
+	ChainID string `json:"chain_id"`
+
+	// BlockTime is the network's average block time, e.g. "6s".
+	BlockTime string `json:"block_time"`
+
+	// Threshold overrides the threshold derived from the block time.
+	Threshold int `json:"threshold,omitempty"`
+
+	// RPCTimeout overrides the RPC client's timeout derived from the block time.
+	RPCTimeout string `json:"rpc_timeout,omitempty"`
+}
+
+// validate validates the preset.
+func (p Preset) validate() error {
+	var errs string
+	if p.Name == "" {
+		errs += "\tname must not be empty\n"
+	}
+	if p.ChainID == "" {
+		errs += "\tchain_id must not be empty\n"
+	}
+	if d, err := time.ParseDuration(p.BlockTime); err != nil || d <= 0 {
+		errs += "\tblock_time must be a positive duration, e.g. \"6s\"\n"
+	}
+	if p.Threshold != 0 && p.Threshold < minThreshold {
+		errs += fmt.Sprintf("\tthreshold must be %v or higher\n", minThreshold)
+	}
+	if p.RPCTimeout != "" {
+		if d, err := time.ParseDuration(p.RPCTimeout); err != nil || d <= 0 {
+			errs += "\trpc_timeout must be a positive duration, e.g. \"3s\"\n"
+		}
+	}
+	if errs != "" {
+		return fmt.Errorf("invalid preset %q:\n%v", p.Name, errs)
+	}
+
+	return nil
+}
+
+// GetThreshold returns the threshold of the preset. Unless it is set explicitly, it
+// is the number of blocks committed within promotionDelay.
+func (p Preset) GetThreshold() int {
+	if p.Threshold != 0 {
+		return p.Threshold
+	}
+	blockTime, _ := time.ParseDuration(p.BlockTime)
+	threshold := int(math.Ceil(float64(promotionDelay) / float64(blockTime)))
+	if threshold < minThreshold {
+		return minThreshold
+	}
+
+	return threshold
+}
+
+// GetRPCTimeout returns the RPC client's timeout of the preset. Unless it is set
+// explicitly, it is half the block time, so that a slow RPC server doesn't hold up
+// the signing of the next block, but no more than rpc.DefaultTimeout.
+func (p Preset) GetRPCTimeout() time.Duration {
+	if p.RPCTimeout != "" {
+		d, _ := time.ParseDuration(p.RPCTimeout)
+		return d
+	}
+	blockTime, _ := time.ParseDuration(p.BlockTime)
+	switch timeout := blockTime / 2; {
+	case timeout < minRPCTimeout:
+		return minRPCTimeout
+	case timeout > rpc.DefaultTimeout:
+		return rpc.DefaultTimeout
+	default:
+		return timeout
+	}
+}
+
+// Values returns the values the preset sets in the configuration file, keyed by
+// section and key.
+func (p Preset) Values() map[string]interface{} {
+	return map[string]interface{}{
+		"privval.chain_id": p.ChainID,
+		"base.threshold":   p.GetThreshold(),
+		"rpc.timeout":      p.GetRPCTimeout().String(),
+	}
+}
+
+// Registry maps the names of the networks to their presets.
+type Registry map[string]Preset
+
+// parse adds the presets in the given JSON array to the registry, replacing the
+// presets with the same names.
+func (r Registry) parse(bytes []byte) error {
+	var presets []Preset
+	if err := json.Unmarshal(bytes, &presets); err != nil {
+		return err
+	}
+	for _, p := range presets {
+		if err := p.validate(); err != nil {
+			return err
+		}
+		r[p.Name] = p
+	}
+
+	return nil
+}
+
+// Load loads the embedded presets and updates them with the presets in the
+// networks.json in the given configuration directory, if there is one.
+func Load(cfgDir string) (Registry, error) {
+	r := make(Registry)
+	embedded, err := networksFile.ReadFile(RegistryFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.parse(embedded); err != nil {
+		return nil, fmt.Errorf("couldn't parse embedded presets: %w", err)
+	}
+
+	path := filepath.Join(cfgDir, RegistryFile)
+	bytes, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := r.parse(bytes); err != nil {
+		return nil, fmt.Errorf("couldn't parse %v: %w", path, err)
+	}
+
+	return r, nil
+}
+
+// Get returns the preset of the given network.
+func (r Registry) Get(name string) (Preset, error) {
+	p, ok := r[name]
+	if !ok {
+		return Preset{}, fmt.Errorf("%w %q, must be one of the following: %v", ErrUnknownNetwork, name, r.Names())
+	}
+
+	return p, nil
+}
+
+// Names returns the names of the networks in alphabetical order.
+func (r Registry) Names() []string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
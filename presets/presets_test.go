@@ -0,0 +1,74 @@
+package presets
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad_Embedded(t *testing.T) {
+	r, err := Load(t.TempDir())
+	assert.NoError(t, err)
+	assert.Contains(t, r.Names(), "cosmoshub")
+
+	p, err := r.Get("cosmoshub")
+	assert.NoError(t, err)
+	assert.Equal(t, "cosmoshub-4", p.ChainID)
+
+	_, err = r.Get("unknown")
+	assert.True(t, errors.Is(err, ErrUnknownNetwork))
+}
+
+func TestLoad_RegistryFile(t *testing.T) {
+	dir := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(dir, RegistryFile), []byte(`[
+		{"name": "cosmoshub", "chain_id": "cosmoshub-5", "block_time": "5s"},
+		{"name": "testnet", "chain_id": "testchain-1", "block_time": "1s", "threshold": 30}
+	]`), 0644)
+	assert.NoError(t, err)
+
+	// The registry file updates and extends the embedded presets.
+	r, err := Load(dir)
+	assert.NoError(t, err)
+	p, err := r.Get("cosmoshub")
+	assert.NoError(t, err)
+	assert.Equal(t, "cosmoshub-5", p.ChainID)
+	p, err = r.Get("testnet")
+	assert.NoError(t, err)
+	assert.Equal(t, 30, p.GetThreshold())
+	assert.Contains(t, r.Names(), "osmosis")
+
+	// Invalid presets are rejected.
+	err = ioutil.WriteFile(filepath.Join(dir, RegistryFile), []byte(`[{"name": "testnet", "block_time": "1s"}]`), 0644)
+	assert.NoError(t, err)
+	_, err = Load(dir)
+	assert.Error(t, err)
+}
+
+func TestPreset_GetThreshold(t *testing.T) {
+	assert.Equal(t, 10, Preset{BlockTime: "6s"}.GetThreshold())
+	assert.Equal(t, 12, Preset{BlockTime: "5s"}.GetThreshold())
+	assert.Equal(t, 9, Preset{BlockTime: "7s"}.GetThreshold())
+	assert.Equal(t, 2, Preset{BlockTime: "1m"}.GetThreshold())
+	assert.Equal(t, 20, Preset{BlockTime: "6s", Threshold: 20}.GetThreshold())
+}
+
+func TestPreset_GetRPCTimeout(t *testing.T) {
+	assert.Equal(t, 3*time.Second, Preset{BlockTime: "6s"}.GetRPCTimeout())
+	assert.Equal(t, time.Second, Preset{BlockTime: "1s"}.GetRPCTimeout())
+	assert.Equal(t, 5*time.Second, Preset{BlockTime: "30s"}.GetRPCTimeout())
+	assert.Equal(t, 2*time.Second, Preset{BlockTime: "6s", RPCTimeout: "2s"}.GetRPCTimeout())
+}
+
+func TestPreset_Values(t *testing.T) {
+	values := Preset{ChainID: "cosmoshub-4", BlockTime: "6s"}.Values()
+	assert.Equal(t, map[string]interface{}{
+		"privval.chain_id": "cosmoshub-4",
+		"base.threshold":   10,
+		"rpc.timeout":      "3s",
+	}, values)
+}
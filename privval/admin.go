@@ -0,0 +1,194 @@
+package privval
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/BlockscapeNetwork/signctrl/totp"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+)
+
+const (
+	// TOTPHeader is the header destructive admin requests carry the TOTP code in.
+	TOTPHeader = "X-TOTP-Code"
+
+	// adminTokenSize is the size of generated admin tokens in bytes.
+	adminTokenSize = 32
+)
+
+var (
+	// ErrUnauthorized is returned if an admin request lacks a valid token or TOTP
+	// code.
+	ErrUnauthorized = types.NewError(types.CodeUnauthorized, "unauthorized")
+)
+
+// AdminCredentials are the credentials sent with requests to the admin API.
+type AdminCredentials struct {
+	// Token is the bearer token, if the admin API requires one.
+	Token string
+
+	// TOTPCode is the current TOTP code, if destructive admin requests require one.
+	TOTPCode string
+}
+
+// LoadAdminTokens loads the tokens from the given token file, one per line. Empty
+// lines and lines starting with # are skipped.
+func LoadAdminTokens(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tokens []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+
+	return tokens, scanner.Err()
+}
+
+// RotateAdminToken writes a new random token to the given token file and returns it.
+// If keep is true, the tokens in the file are kept, so that clients can be switched
+// to the new token before the old ones are revoked.
+func RotateAdminToken(file string, keep bool) (string, error) {
+	bz := make([]byte, adminTokenSize)
+	if _, err := rand.Read(bz); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(bz)
+
+	tokens := []string{token}
+	if keep {
+		old, err := LoadAdminTokens(file)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		tokens = append(tokens, old...)
+	}
+	if err := ioutil.WriteFile(file, []byte(strings.Join(tokens, "\n")+"\n"), PermSecretFile); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// authorizeAdmin checks the credentials of the given admin request. Destructive
+// requests require a TOTP code in addition to the token if the admin API is
+// configured to.
+func (pv *SCFilePV) authorizeAdmin(r *http.Request, destructive bool) error {
+	cfg := pv.Config.Admin
+	if !cfg.RequiresToken() {
+		return nil
+	}
+
+	tokens, err := LoadAdminTokens(cfg.TokenFile)
+	if err != nil {
+		return fmt.Errorf("couldn't load admin tokens: %w", err)
+	}
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	valid := false
+	for _, token := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(given)) == 1 {
+			valid = true
+		}
+	}
+	if !valid {
+		return fmt.Errorf("%w: invalid or missing token", ErrUnauthorized)
+	}
+
+	if destructive && cfg.RequiresTOTP() {
+		bz, err := ioutil.ReadFile(cfg.TOTPSecretFile)
+		if err != nil {
+			return fmt.Errorf("couldn't load TOTP secret: %w", err)
+		}
+		secret, err := totp.DecodeSecret(string(bz))
+		if err != nil {
+			return err
+		}
+		step, ok := totp.Match(secret, r.Header.Get(TOTPHeader), pv.Clock.Now())
+		if !ok {
+			return fmt.Errorf("%w: invalid or missing TOTP code", ErrUnauthorized)
+		}
+
+		// A code is only accepted once, and neither are the codes of earlier time
+		// steps, so that an intercepted code can't be replayed within the window.
+		pv.totpMtx.Lock()
+		defer pv.totpMtx.Unlock()
+		if step <= pv.lastTOTPStep {
+			return fmt.Errorf("%w: TOTP code was already used", ErrUnauthorized)
+		}
+		pv.lastTOTPStep = step
+	}
+
+	return nil
+}
+
+// checkAdmin writes an error response and returns false unless the given admin
+// request was sent from the loopback interface and carries valid credentials.
+func (pv *SCFilePV) checkAdmin(rw http.ResponseWriter, r *http.Request, destructive bool) bool {
+	if !isLoopback(r) {
+		http.Error(rw, "admin requests are only accepted from localhost", http.StatusForbidden)
+		return false
+	}
+	if err := pv.authorizeAdmin(r, destructive); err != nil {
+		pv.Logger.Warn("Rejected admin request to %v: %v", r.URL.Path, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrUnauthorized) {
+			status = http.StatusUnauthorized
+		}
+		httpError(rw, err, status)
+		return false
+	}
+
+	return true
+}
+
+// doAdminRequest sends the given request to the admin API with the given credentials
+// and returns the response's error message if it failed.
+func doAdminRequest(req *http.Request, creds AdminCredentials) error {
+	return doAdminRequestInto(req, creds, nil)
+}
+
+// doAdminRequestInto sends the given request to the admin API with the given
+// credentials and unmarshals the response into v unless v is nil.
+func doAdminRequestInto(req *http.Request, creds AdminCredentials, v interface{}) error {
+	if creds.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+creds.Token)
+	}
+	if creds.TOTPCode != "" {
+		req.Header.Set(TOTPHeader, creds.TOTPCode)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp, body)
+	}
+	if v == nil {
+		return nil
+	}
+
+	return tm_json.Unmarshal(body, v)
+}
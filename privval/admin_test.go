@@ -0,0 +1,105 @@
+package privval
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/totp"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateAdminToken(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "admin_tokens")
+	first, err := RotateAdminToken(file, false)
+	assert.NoError(t, err)
+	assert.Len(t, first, 2*adminTokenSize)
+
+	second, err := RotateAdminToken(file, true)
+	assert.NoError(t, err)
+	tokens, err := LoadAdminTokens(file)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{second, first}, tokens)
+
+	third, err := RotateAdminToken(file, false)
+	assert.NoError(t, err)
+	tokens, err = LoadAdminTokens(file)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{third}, tokens)
+}
+
+func TestLoadAdminTokens(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "admin_tokens")
+	require.NoError(t, ioutil.WriteFile(file, []byte("# dashboard\ntoken-a\n\n  token-b  \n"), PermSecretFile))
+	tokens, err := LoadAdminTokens(file)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"token-a", "token-b"}, tokens)
+}
+
+func TestAuthorizeAdmin(t *testing.T) {
+	dir := t.TempDir()
+	pv := mockSCFilePV(t)
+	clock := types.NewFakeClock(time.Unix(1111111111, 0))
+	pv.Clock = clock
+	request := func(token, code string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/admin/signer", nil)
+		r.RemoteAddr = "127.0.0.1:1234"
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		if code != "" {
+			r.Header.Set(TOTPHeader, code)
+		}
+		return r
+	}
+
+	// No credentials are required by default.
+	assert.NoError(t, pv.authorizeAdmin(request("", ""), true))
+
+	pv.Config.Admin.TokenFile = filepath.Join(dir, "admin_tokens")
+	token, err := RotateAdminToken(pv.Config.Admin.TokenFile, false)
+	require.NoError(t, err)
+	assert.ErrorIs(t, pv.authorizeAdmin(request("", ""), false), ErrUnauthorized)
+	assert.ErrorIs(t, pv.authorizeAdmin(request("invalid", ""), false), ErrUnauthorized)
+	assert.NoError(t, pv.authorizeAdmin(request(token, ""), false))
+	assert.NoError(t, pv.authorizeAdmin(request(token, ""), true))
+
+	// Rotated tokens are accepted without a restart.
+	next, err := RotateAdminToken(pv.Config.Admin.TokenFile, false)
+	require.NoError(t, err)
+	assert.ErrorIs(t, pv.authorizeAdmin(request(token, ""), false), ErrUnauthorized)
+	assert.NoError(t, pv.authorizeAdmin(request(next, ""), false))
+
+	// Destructive requests require a TOTP code if configured.
+	pv.Config.Admin.TOTPSecretFile = filepath.Join(dir, "totp_secret")
+	s, err := totp.NewSecret()
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(pv.Config.Admin.TOTPSecretFile, []byte(s), PermSecretFile))
+	secret, err := totp.DecodeSecret(s)
+	require.NoError(t, err)
+	assert.NoError(t, pv.authorizeAdmin(request(next, ""), false))
+	assert.ErrorIs(t, pv.authorizeAdmin(request(next, ""), true), ErrUnauthorized)
+	assert.ErrorIs(t, pv.authorizeAdmin(request(next, totp.Code(secret, clock.Now().Add(-time.Hour))), true), ErrUnauthorized)
+	assert.NoError(t, pv.authorizeAdmin(request(next, totp.Code(secret, clock.Now())), true))
+
+	// Codes are only accepted once, and codes of earlier time steps aren't accepted
+	// after a later one.
+	assert.ErrorIs(t, pv.authorizeAdmin(request(next, totp.Code(secret, clock.Now())), true), ErrUnauthorized)
+	assert.ErrorIs(t, pv.authorizeAdmin(request(next, totp.Code(secret, clock.Now().Add(-totp.Step))), true), ErrUnauthorized)
+	assert.NoError(t, pv.authorizeAdmin(request(next, totp.Code(secret, clock.Now().Add(totp.Step))), true))
+	clock.Advance(2 * totp.Step)
+	assert.NoError(t, pv.authorizeAdmin(request(next, totp.Code(secret, clock.Now())), true))
+
+	// The HTTP handlers reject unauthorized requests.
+	rec := httptest.NewRecorder()
+	pv.swapSignerHandler(rec, request(next, ""))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = httptest.NewRecorder()
+	pv.maintenanceHandler(rec, request("", ""))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,104 @@
+package privval
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/alerts"
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+)
+
+// alertsFlushTimeout is the maximum time the node waits for the queued alerts to be
+// sent when it stops.
+const alertsFlushTimeout = 5 * time.Second
+
+// startAlerts starts sending alerts for the configured event types to the webhooks,
+// PagerDuty and Slack.
+func (pv *SCFilePV) startAlerts() {
+	var notifiers []alerts.Notifier
+	for _, url := range pv.Config.Alerts.Webhooks {
+		notifiers = append(notifiers, alerts.Webhook{URL: url})
+	}
+	if key := pv.Config.Alerts.PagerDutyRoutingKey; key != "" {
+		notifiers = append(notifiers, alerts.PagerDuty{RoutingKey: key})
+	}
+	if url := pv.Config.Alerts.SlackWebhook; url != "" {
+		notifiers = append(notifiers, alerts.Slack{URL: url})
+	}
+	pv.alertEvents = make(map[watchtower.EventType]bool)
+	for _, e := range pv.Config.Alerts.GetEvents() {
+		pv.alertEvents[watchtower.EventType(e)] = true
+	}
+	pv.alerts = alerts.NewDispatcher(notifiers, pv.Logger)
+	pv.Logger.Info("Sending alerts via %v notifiers", len(notifiers))
+
+	goroutines.Go("alerts", func() {
+		defer pv.recoverPanic("alerts")
+		pv.alerts.Run()
+	})
+}
+
+// alert queues an alert for e if alerts are sent for its type. The rank of the last
+// event is the alert's old rank.
+func (pv *SCFilePV) alert(e watchtower.Event) {
+	if pv.alerts == nil {
+		return
+	}
+	oldRank := int(atomic.SwapInt64(&pv.alertRank, int64(e.Rank)))
+	if oldRank == 0 {
+		oldRank = e.Rank
+	}
+	if !pv.alertEvents[e.Type] {
+		return
+	}
+
+	pv.alerts.Send(alerts.Alert{
+		Node:     pv.Config.P2P.GetName(),
+		ChainID:  pv.Config.Privval.ChainID,
+		Type:     string(e.Type),
+		Severity: pv.Config.Alerts.GetSeverity(string(e.Type)),
+		Time:     e.Time,
+		OldRank:  oldRank,
+		NewRank:  e.Rank,
+		Height:   e.Height,
+		Reason:   e.Message,
+	})
+}
+
+// stopAlerts sends the queued alerts, e.g. the one about the node stopping, and stops
+// sending alerts.
+func (pv *SCFilePV) stopAlerts() {
+	if pv.alerts != nil {
+		pv.alerts.Close(alertsFlushTimeout)
+	}
+}
+
+// monitorConnection reports the validator as disconnected once the node hasn't been
+// connected to it for longer than the disconnect timeout, and as reconnected once the
+// connection is established again, until ctx is done.
+func (pv *SCFilePV) monitorConnection(ctx context.Context) {
+	defer pv.recoverPanic("conn_monitor")
+
+	timeout := pv.Config.Alerts.GetDisconnectTimeout()
+	disconnected := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pv.Clock.After(timeout / 4):
+		}
+
+		state, since := pv.getConnStateSince()
+		switch {
+		case state == ConnConnecting && !disconnected && pv.Clock.Now().Sub(since) >= timeout:
+			disconnected = true
+			pv.Logger.Error("Not connected to the validator for %v", pv.Clock.Now().Sub(since).Round(time.Second))
+			pv.emit(watchtower.EventDisconnected, "Not connected to the validator since %v", since.UTC().Format(time.RFC3339))
+		case state == ConnConnected && disconnected:
+			disconnected = false
+			pv.emit(watchtower.EventReconnected, "Connected to the validator again")
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package privval
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/alerts"
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlerts(t *testing.T) {
+	var mtx sync.Mutex
+	var received []alerts.Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var a alerts.Alert
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&a))
+		mtx.Lock()
+		received = append(received, a)
+		mtx.Unlock()
+	}))
+	defer srv.Close()
+
+	pv := mockSCFilePV(t)
+	pv.Config.P2P.Name = "node-a"
+	pv.Config.Alerts = config.Alerts{Webhooks: []string{srv.URL}}
+	pv.SetRank(2)
+	pv.startAlerts()
+
+	// Only the configured event types are sent, but all events keep track of the rank.
+	pv.emit(watchtower.EventMissedBlock, "Missed block 10")
+	pv.SetRank(1)
+	pv.emit(watchtower.EventPromoted, "Promoted to rank 1")
+	pv.stopAlerts()
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Len(t, received, 1)
+	assert.Equal(t, "node-a", received[0].Node)
+	assert.Equal(t, pv.Config.Privval.ChainID, received[0].ChainID)
+	assert.Equal(t, "promoted", received[0].Type)
+	assert.Equal(t, "warning", received[0].Severity)
+	assert.Equal(t, 2, received[0].OldRank)
+	assert.Equal(t, 1, received[0].NewRank)
+	assert.Equal(t, "Promoted to rank 1", received[0].Reason)
+}
+
+func TestMonitorConnection(t *testing.T) {
+	pv := mockSCFilePV(t)
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	pv.Clock = clock
+	pv.Config.Alerts.DisconnectTimeout = "1m"
+	pv.setConnState(ConnConnecting)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pv.monitorConnection(ctx)
+
+	events := func() []watchtower.EventType {
+		var types []watchtower.EventType
+		events, _, _ := pv.watchEvents.Since(0)
+		for _, e := range events {
+			types = append(types, e.Type)
+		}
+		return types
+	}
+	advance := func(d time.Duration) {
+		for elapsed := time.Duration(0); elapsed < d; elapsed += 15 * time.Second {
+			clock.BlockUntil(1)
+			clock.Advance(15 * time.Second)
+		}
+	}
+
+	advance(45 * time.Second)
+	assert.Empty(t, events())
+
+	advance(15 * time.Second)
+	require.Eventually(t, func() bool { return len(events()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []watchtower.EventType{watchtower.EventDisconnected}, events())
+
+	// The disconnection is only reported once.
+	advance(time.Minute)
+	pv.setConnState(ConnConnected)
+	advance(15 * time.Second)
+	require.Eventually(t, func() bool { return len(events()) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, []watchtower.EventType{watchtower.EventDisconnected, watchtower.EventReconnected}, events())
+}
@@ -0,0 +1,228 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+var (
+	// ErrProposalNotApproved is returned for proposals that weren't approved by a
+	// second operator in time.
+	ErrProposalNotApproved = types.NewError(types.CodeNoPermission, "proposal wasn't approved")
+
+	// ErrProposalRejected is returned for proposals that were rejected by a second
+	// operator.
+	ErrProposalRejected = types.NewError(types.CodeNoPermission, "proposal was rejected")
+
+	// ErrNoPendingProposal is returned if a proposal is approved or rejected that
+	// isn't pending (anymore).
+	ErrNoPendingProposal = types.NewError(types.CodeNotFound, "no such pending proposal")
+)
+
+// PendingProposal is a proposal that is held until it is approved.
+type PendingProposal struct {
+	// ID identifies the proposal in approvals. It consists of the proposal's height
+	// and round.
+	ID string `json:"id"`
+
+	Height int64 `json:"height"`
+	Round  int32 `json:"round"`
+
+	// BlockHash is the hex-encoded hash of the proposed block.
+	BlockHash string `json:"block_hash"`
+
+	// Deadline is the time the proposal is rejected at unless it is approved.
+	Deadline time.Time `json:"deadline"`
+}
+
+// ApprovalRequest defines the request JSON for approving or rejecting a pending
+// proposal via the admin API.
+type ApprovalRequest struct {
+	ID      string `json:"id"`
+	Approve bool   `json:"approve"`
+}
+
+// approvals holds the proposals waiting for approval.
+type approvals struct {
+	mtx     sync.Mutex
+	pending map[string]*pendingApproval
+}
+
+// pendingApproval is a proposal waiting for approval and the channel its decision is
+// sent on.
+type pendingApproval struct {
+	info     PendingProposal
+	decision chan bool
+}
+
+// PendingProposals returns the proposals waiting for approval, ordered by height and
+// round.
+func (pv *SCFilePV) PendingProposals() []PendingProposal {
+	pv.approvals.mtx.Lock()
+	defer pv.approvals.mtx.Unlock()
+
+	proposals := make([]PendingProposal, 0, len(pv.approvals.pending))
+	for _, p := range pv.approvals.pending {
+		proposals = append(proposals, p.info)
+	}
+	sort.Slice(proposals, func(i, j int) bool {
+		if proposals[i].Height != proposals[j].Height {
+			return proposals[i].Height < proposals[j].Height
+		}
+		return proposals[i].Round < proposals[j].Round
+	})
+
+	return proposals
+}
+
+// DecideProposal approves or rejects the pending proposal with the given ID.
+func (pv *SCFilePV) DecideProposal(id string, approve bool) error {
+	pv.approvals.mtx.Lock()
+	p, ok := pv.approvals.pending[id]
+	if ok {
+		delete(pv.approvals.pending, id)
+	}
+	pv.approvals.mtx.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrNoPendingProposal, id)
+	}
+
+	p.decision <- approve
+	return nil
+}
+
+// holdsForApproval returns true if the given message is a proposal that is held until
+// it is approved.
+func (pv *SCFilePV) holdsForApproval(msg *tm_privvalproto.Message) bool {
+	return pv.Config.Privval.RequiresProposalApproval() && msg.GetSignProposalRequest() != nil
+}
+
+// awaitApproval holds the given proposal until it is approved, rejected or the
+// approval timeout has passed.
+func (pv *SCFilePV) awaitApproval(ctx context.Context, proposal *tm_typesproto.Proposal) error {
+	timeout := pv.Config.Privval.GetProposalApprovalTimeout()
+	p := &pendingApproval{
+		info: PendingProposal{
+			ID:        fmt.Sprintf("%v/%v", proposal.Height, proposal.Round),
+			Height:    proposal.Height,
+			Round:     proposal.Round,
+			BlockHash: fmt.Sprintf("%X", proposal.BlockID.Hash),
+			Deadline:  pv.Clock.Now().Add(timeout),
+		},
+		decision: make(chan bool, 1),
+	}
+
+	pv.approvals.mtx.Lock()
+	if pv.approvals.pending == nil {
+		pv.approvals.pending = make(map[string]*pendingApproval)
+	}
+	pv.approvals.pending[p.info.ID] = p
+	pv.approvals.mtx.Unlock()
+	defer func() {
+		pv.approvals.mtx.Lock()
+		if pv.approvals.pending[p.info.ID] == p {
+			delete(pv.approvals.pending, p.info.ID)
+		}
+		pv.approvals.mtx.Unlock()
+	}()
+
+	pv.Logger.Info("Holding proposal %v for block %v until it is approved (signctrl proposals approve %v)", p.info.ID, p.info.BlockHash, p.info.ID)
+	pv.emit(watchtower.EventProposalPending, "Proposal %v for block %v is waiting for approval", p.info.ID, p.info.BlockHash)
+
+	select {
+	case approved := <-p.decision:
+		if !approved {
+			pv.emit(watchtower.EventProposalRejected, "Proposal %v was rejected", p.info.ID)
+			return ErrProposalRejected
+		}
+		pv.Logger.Info("Proposal %v was approved", p.info.ID)
+		return nil
+	case <-pv.Clock.After(timeout):
+		pv.emit(watchtower.EventProposalRejected, "Proposal %v wasn't approved within %v", p.info.ID, timeout)
+		return ErrProposalNotApproved
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// proposalsHandler serves the pending proposals on GET requests and approves or
+// rejects them on POST requests. Only requests from the loopback interface with a
+// valid token are accepted, and approving a proposal is a destructive request.
+func (pv *SCFilePV) proposalsHandler(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if !pv.checkAdmin(rw, r, false) {
+			return
+		}
+		bytes, err := tm_json.Marshal(pv.PendingProposals())
+		if err != nil {
+			httpError(rw, err, http.StatusInternalServerError)
+			return
+		}
+		_, _ = rw.Write(bytes)
+
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			httpError(rw, err, http.StatusBadRequest)
+			return
+		}
+		var req ApprovalRequest
+		if err := tm_json.Unmarshal(body, &req); err != nil {
+			httpError(rw, err, http.StatusBadRequest)
+			return
+		}
+		if !pv.checkAdmin(rw, r, req.Approve) {
+			return
+		}
+		if err := pv.DecideProposal(req.ID, req.Approve); err != nil {
+			httpError(rw, err, http.StatusNotFound)
+			return
+		}
+
+	default:
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GetPendingProposals retrieves the proposals of the given instance, or the default
+// validator if name is empty, that are waiting for approval.
+func GetPendingProposals(name string, creds AdminCredentials) ([]PendingProposal, error) {
+	req, err := http.NewRequest(http.MethodGet, localURL(instancePath(name, "/admin/proposals")), nil)
+	if err != nil {
+		return nil, err
+	}
+	var proposals []PendingProposal
+	if err := doAdminRequestInto(req, creds, &proposals); err != nil {
+		return nil, err
+	}
+
+	return proposals, nil
+}
+
+// DecidePendingProposal approves or rejects the pending proposal with the given ID of
+// the given instance, or the default validator if name is empty.
+func DecidePendingProposal(name, id string, approve bool, creds AdminCredentials) error {
+	body, err := tm_json.Marshal(ApprovalRequest{ID: id, Approve: approve})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, localURL(instancePath(name, "/admin/proposals")), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	return doAdminRequest(req, creds)
+}
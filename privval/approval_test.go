@@ -0,0 +1,180 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+// awaitPending waits until the given number of proposals is pending.
+func awaitPending(t *testing.T, pv *SCFilePV, n int) []PendingProposal {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if pending := pv.PendingProposals(); len(pending) == n {
+			return pending
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%v proposals weren't pending in time", n)
+	return nil
+}
+
+func TestAwaitApproval(t *testing.T) {
+	pv := mockSCFilePV(t)
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	pv.Clock = clock
+	pv.Config.Privval.ProposalApprovalTimeout = "30s"
+	proposal := &tm_typesproto.Proposal{Height: 10, Round: 1, BlockID: tm_typesproto.BlockID{Hash: []byte{0xab}}}
+
+	await := func() chan error {
+		errCh := make(chan error, 1)
+		go func() { errCh <- pv.awaitApproval(context.Background(), proposal) }()
+		return errCh
+	}
+
+	// Approved proposals are signed.
+	errCh := await()
+	pending := awaitPending(t, pv, 1)
+	assert.Equal(t, PendingProposal{ID: "10/1", Height: 10, Round: 1, BlockHash: "AB", Deadline: time.Unix(30, 0)}, pending[0])
+	assert.NoError(t, pv.DecideProposal("10/1", true))
+	assert.NoError(t, <-errCh)
+	assert.Empty(t, pv.PendingProposals())
+	assert.ErrorIs(t, pv.DecideProposal("10/1", true), ErrNoPendingProposal)
+
+	// Rejected proposals aren't.
+	errCh = await()
+	awaitPending(t, pv, 1)
+	assert.NoError(t, pv.DecideProposal("10/1", false))
+	assert.ErrorIs(t, <-errCh, ErrProposalRejected)
+
+	// Neither are proposals that weren't approved in time.
+	errCh = await()
+	awaitPending(t, pv, 1)
+	clock.BlockUntil(1)
+	clock.Advance(30 * time.Second)
+	assert.ErrorIs(t, <-errCh, ErrProposalNotApproved)
+	assert.Empty(t, pv.PendingProposals())
+}
+
+func TestHandleSignRequest_ProposalNotApproved(t *testing.T) {
+	pv := mockSCFilePV(t)
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	pv.Clock = clock
+	pv.Config.Privval.ProposalApprovalTimeout = "30s"
+
+	respCh := make(chan *tm_privvalproto.Message, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		resp, err := handleSignRequest(context.Background(), wrapMsg(&tm_privvalproto.SignProposalRequest{
+			Proposal: &tm_typesproto.Proposal{Type: tm_typesproto.ProposalType, Height: 1},
+			ChainId:  "testchain",
+		}), pv)
+		respCh <- resp
+		errCh <- err
+	}()
+	awaitPending(t, pv, 1)
+	clock.BlockUntil(1)
+	clock.Advance(30 * time.Second)
+
+	// Proposals that weren't approved in time get a nil response.
+	assert.Equal(t, wrapMsg(&tm_privvalproto.SignedProposalResponse{}), <-respCh)
+	assert.ErrorIs(t, <-errCh, ErrProposalNotApproved)
+}
+
+func TestSCFilePV_ApprovalPausesTimeout(t *testing.T) {
+	conns := make(chan net.Conn, 2)
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	pv := mockSCFilePV(t)
+	pv.HTTP = nil
+	pv.Clock = clock
+	pv.Config.Privval.ValidatorSetCheck = "off"
+	pv.Config.Privval.ProposalApprovalTimeout = "1m"
+	pv.Dial = func(ctx context.Context) (net.Conn, error) {
+		signerConn, validatorConn := net.Pipe()
+		conns <- validatorConn
+		return signerConn, nil
+	}
+	require.NoError(t, pv.Start())
+	defer func() {
+		assert.NoError(t, pv.Stop())
+		<-pv.Quit()
+	}()
+
+	validatorConn := <-conns
+	defer validatorConn.Close()
+	assert.NoError(t, validatorConn.SetDeadline(time.Now().Add(5*time.Second)))
+	w := tm_protoio.NewDelimitedWriter(validatorConn)
+	r := tm_protoio.NewDelimitedReader(validatorConn, maxRemoteSignerMsgSize)
+	_, err := w.WriteMsg(wrapMsg(&tm_privvalproto.SignProposalRequest{
+		Proposal: &tm_typesproto.Proposal{Type: tm_typesproto.ProposalType, Height: 1},
+		ChainId:  "testchain",
+	}))
+	require.NoError(t, err)
+	awaitPending(t, pv, 1)
+
+	// The connection isn't considered lost while the proposal is held, even after
+	// retry_dial_after has passed.
+	clock.Advance(pv.Config.Base.GetRetryDialAfter() + time.Second)
+	select {
+	case conn := <-conns:
+		conn.Close()
+		t.Fatal("node redialed while the proposal was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Rejected proposals get a nil response on the same connection.
+	require.NoError(t, pv.DecideProposal("1/0", false))
+	var resp tm_privvalproto.Message
+	_, err = r.ReadMsg(&resp)
+	require.NoError(t, err)
+	assert.Equal(t, wrapMsg(&tm_privvalproto.SignedProposalResponse{}), &resp)
+}
+
+func TestProposalsHandler(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.Config.Privval.ProposalApprovalTimeout = "30s"
+	send := func(method string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/proposals", bytes.NewReader(body))
+		req.RemoteAddr = "127.0.0.1:1234"
+		rec := httptest.NewRecorder()
+		pv.proposalsHandler(rec, req)
+		return rec
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- pv.awaitApproval(context.Background(), &tm_typesproto.Proposal{Height: 10})
+	}()
+	awaitPending(t, pv, 1)
+
+	rec := send(http.MethodGet, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var pending []PendingProposal
+	require.NoError(t, tm_json.Unmarshal(rec.Body.Bytes(), &pending))
+	require.Len(t, pending, 1)
+	assert.Equal(t, "10/0", pending[0].ID)
+
+	body, err := tm_json.Marshal(ApprovalRequest{ID: "11/0", Approve: true})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodPost, body).Code)
+
+	body, err = tm_json.Marshal(ApprovalRequest{ID: "10/0", Approve: true})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, body).Code)
+	assert.NoError(t, <-errCh)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, send(http.MethodDelete, nil).Code)
+}
@@ -0,0 +1,195 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tm_cryptoenc "github.com/tendermint/tendermint/crypto/encoding"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+	"google.golang.org/grpc"
+)
+
+// mockPVServer serves the PrivValidatorAPI service with a MockPV, so that the gRPC
+// signer backend can be benchmarked without a second SignCTRL node.
+type mockPVServer struct {
+	pv tm_types.MockPV
+}
+
+// GetPubKey implements the privValidatorAPIServer interface.
+func (s *mockPVServer) GetPubKey(ctx context.Context, req *tm_privvalproto.PubKeyRequest) (*tm_privvalproto.PubKeyResponse, error) {
+	pub, err := s.pv.GetPubKey()
+	if err != nil {
+		return nil, err
+	}
+	pk, err := tm_cryptoenc.PubKeyToProto(pub)
+	if err != nil {
+		return nil, err
+	}
+	return &tm_privvalproto.PubKeyResponse{PubKey: pk}, nil
+}
+
+// SignVote implements the privValidatorAPIServer interface.
+func (s *mockPVServer) SignVote(ctx context.Context, req *tm_privvalproto.SignVoteRequest) (*tm_privvalproto.SignedVoteResponse, error) {
+	if err := s.pv.SignVote(req.ChainId, req.Vote); err != nil {
+		return nil, err
+	}
+	return &tm_privvalproto.SignedVoteResponse{Vote: *req.Vote}, nil
+}
+
+// SignProposal implements the privValidatorAPIServer interface.
+func (s *mockPVServer) SignProposal(ctx context.Context, req *tm_privvalproto.SignProposalRequest) (*tm_privvalproto.SignedProposalResponse, error) {
+	if err := s.pv.SignProposal(req.ChainId, req.Proposal); err != nil {
+		return nil, err
+	}
+	return &tm_privvalproto.SignedProposalResponse{Proposal: *req.Proposal}, nil
+}
+
+// benchBackend creates a signer backend the hot path is benchmarked with.
+type benchBackend struct {
+	name string
+	new  func(b *testing.B) tm_types.PrivValidator
+}
+
+// benchBackends are the signer backends the hot path is benchmarked with.
+var benchBackends = []benchBackend{
+	// memory signs without persisting the last sign state, which is the lower bound
+	// of SignCTRL's own overhead.
+	{"memory", func(b *testing.B) tm_types.PrivValidator {
+		return tm_types.NewMockPV()
+	}},
+
+	// file persists the last sign state on every signature, like the default signer
+	// backend.
+	{"file", func(b *testing.B) tm_types.PrivValidator {
+		dir := b.TempDir()
+		filePV := tm_privval.GenFilePV(filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile))
+		filePV.Save()
+		return filePV
+	}},
+
+	// grpc has a remote signer on the loopback interface sign the requests.
+	{"grpc", func(b *testing.B) tm_types.PrivValidator {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			b.Fatal(err)
+		}
+		server := grpc.NewServer(grpc.CustomCodec(gogoCodec{})) //nolint:staticcheck // see serveGRPC
+		server.RegisterService(&privValidatorAPIDesc, &mockPVServer{pv: tm_types.NewMockPV()})
+		go func() { _ = server.Serve(listener) }()
+		b.Cleanup(server.Stop)
+
+		signer, err := NewGRPCSigner(listener.Addr().String(), "testchain", nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() { _ = signer.Close() })
+		return signer
+	}},
+}
+
+// benchRequest creates a request the hot path is benchmarked with for a given height.
+type benchRequest struct {
+	name string
+	new  func(b *testing.B, height int64) *tm_privvalproto.Message
+}
+
+// benchRequests are the requests the hot path is benchmarked with.
+var benchRequests = []benchRequest{
+	{"vote", func(b *testing.B, height int64) *tm_privvalproto.Message {
+		msg := testSignVoteRequest(b)
+		msg.GetSignVoteRequest().Vote.Height = height
+		return msg
+	}},
+	{"proposal", func(b *testing.B, height int64) *tm_privvalproto.Message {
+		msg := testSignProposalRequest(b)
+		msg.GetSignProposalRequest().Proposal.Height = height
+		msg.GetSignProposalRequest().Proposal.PolRound = -1
+		return msg
+	}},
+}
+
+// benchSCFilePV returns an SCFilePV on rank 1 that signs with the given private
+// validator and whose validator signed every block.
+func benchSCFilePV(b *testing.B, signer tm_types.PrivValidator) *SCFilePV {
+	pv := mockSCFilePV(b)
+	pv.HTTP = nil
+	pv.TMFilePV = signer
+	pub, err := signer.GetPubKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+	block := &tm_types.Block{
+		LastCommit: &tm_types.Commit{
+			Signatures: []tm_types.CommitSig{{BlockIDFlag: tm_types.BlockIDFlagCommit, ValidatorAddress: pub.Address()}},
+		},
+	}
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		block.Height = height
+		block.Time = time.Unix(height, 0)
+		return &tm_coretypes.ResultBlock{Block: block}, nil
+	}
+
+	return pv
+}
+
+// BenchmarkSignPath benchmarks the path of a sign request from decoding its frame
+// through HandleRequest and the signer backend to encoding the response, for votes
+// and proposals and each signer backend. Every request is for a new height, so that
+// the missed blocks are checked and nothing is served from the last sign state.
+//
+// Compare the results of two commits with benchstat, see make bench.
+func BenchmarkSignPath(b *testing.B) {
+	for _, req := range benchRequests {
+		for _, backend := range benchBackends {
+			req, backend := req, backend
+			b.Run(req.name+"/"+backend.name, func(b *testing.B) {
+				pv := benchSCFilePV(b, backend.new(b))
+
+				// Encode the frames up front, so that only decoding them is measured.
+				var frames bytes.Buffer
+				enc := newMsgConn(struct {
+					io.Reader
+					io.Writer
+				}{nil, &frames}, maxRemoteSignerMsgSize)
+				for i := 0; i < b.N; i++ {
+					if err := enc.WriteMsg(req.new(b, int64(i)+2)); err != nil {
+						b.Fatal(err)
+					}
+				}
+				enc.release()
+
+				mc := newMsgConn(struct {
+					io.Reader
+					io.Writer
+				}{&frames, ioutil.Discard}, maxRemoteSignerMsgSize)
+				defer mc.release()
+				ctx := context.Background()
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					msg := new(tm_privvalproto.Message)
+					if err := mc.ReadMsg(msg); err != nil {
+						b.Fatal(err)
+					}
+					resp, err := HandleRequest(ctx, msg, pv)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if err := mc.WriteMsg(resp); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
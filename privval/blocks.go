@@ -0,0 +1,102 @@
+package privval
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+	"github.com/BlockscapeNetwork/signctrl/rpc"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+const (
+	// blockCacheSize is the number of most recent blocks kept from the block
+	// subscription.
+	blockCacheSize = 16
+
+	// blockSubscriptionRetry is the time after which a failed block subscription is
+	// retried. Blocks are polled in the meantime.
+	blockSubscriptionRetry = 5 * time.Second
+)
+
+// BlockSubscriber subscribes to the blocks committed by the validator and sends them
+// to blockCh. It is expected to block until either ctx is done or the subscription
+// fails.
+type BlockSubscriber func(ctx context.Context, blockCh chan<- *tm_coretypes.ResultBlock) error
+
+// blockCache keeps the most recent blocks received from the block subscription, so
+// that they don't need to be polled from the validator's RPC server.
+type blockCache struct {
+	mtx    sync.RWMutex
+	blocks map[int64]*tm_coretypes.ResultBlock
+	size   int
+}
+
+// newBlockCache creates a new cache for the given number of blocks.
+func newBlockCache(size int) *blockCache {
+	return &blockCache{
+		blocks: make(map[int64]*tm_coretypes.ResultBlock, size),
+		size:   size,
+	}
+}
+
+// add adds the given block to the cache and evicts all blocks that are too old.
+func (c *blockCache) add(block *tm_coretypes.ResultBlock) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	height := block.Block.Height
+	c.blocks[height] = block
+	for h := range c.blocks {
+		if h <= height-int64(c.size) {
+			delete(c.blocks, h)
+		}
+	}
+}
+
+// get returns the block at the given height, if it is cached.
+func (c *blockCache) get(height int64) (*tm_coretypes.ResultBlock, bool) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	block, ok := c.blocks[height]
+	return block, ok
+}
+
+// subscribeBlocks is the default BlockSubscriber of SCFilePV. It subscribes to new
+// blocks via the websocket endpoint of the best RPC server.
+func (pv *SCFilePV) subscribeBlocks(ctx context.Context, blockCh chan<- *tm_coretypes.ResultBlock) error {
+	return rpc.SubscribeBlocks(ctx, pv.rpcAddr(), blockCh, pv.Logger)
+}
+
+// watchBlocks keeps the block cache filled with the blocks committed by the
+// validator until ctx is done. If the subscription fails, it is retried after
+// blockSubscriptionRetry while blocks are polled in the meantime.
+func (pv *SCFilePV) watchBlocks(ctx context.Context) {
+	blockCh := make(chan *tm_coretypes.ResultBlock)
+	goroutines.Go("blocks", func() {
+		for {
+			select {
+			case block := <-blockCh:
+				pv.blocks.add(block)
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+
+	for {
+		err := pv.SubscribeBlocks(ctx, blockCh)
+		if ctx.Err() != nil {
+			return
+		}
+		pv.Logger.Warn("Block subscription failed, polling blocks instead: %v", err)
+
+		select {
+		case <-pv.Clock.After(blockSubscriptionRetry):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
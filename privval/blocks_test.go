@@ -0,0 +1,85 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/internal/leaktest"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+func testResultBlock(height int64) *tm_coretypes.ResultBlock {
+	return &tm_coretypes.ResultBlock{Block: &tm_types.Block{Header: tm_types.Header{Height: height}}}
+}
+
+func TestBlockCache(t *testing.T) {
+	c := newBlockCache(3)
+	for h := int64(1); h <= 5; h++ {
+		c.add(testResultBlock(h))
+	}
+
+	for h := int64(1); h <= 2; h++ {
+		_, ok := c.get(h)
+		assert.False(t, ok, h)
+	}
+	for h := int64(3); h <= 5; h++ {
+		block, ok := c.get(h)
+		assert.True(t, ok, h)
+		assert.Equal(t, h, block.Block.Height)
+	}
+}
+
+func TestSCFilePV_WatchBlocks(t *testing.T) {
+	leaktest.Check(t)
+
+	pv := mockSCFilePV(t)
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	pv.Clock = clock
+
+	// Fail the first subscription, then deliver a block.
+	attempts := make(chan int, 2)
+	delivered := make(chan struct{})
+	pv.SubscribeBlocks = func(ctx context.Context, blockCh chan<- *tm_coretypes.ResultBlock) error {
+		attempts <- len(attempts) + 1
+		if len(attempts) == 1 {
+			return errors.New("connection refused")
+		}
+		blockCh <- testResultBlock(10)
+		close(delivered)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pv.watchBlocks(ctx)
+		close(done)
+	}()
+
+	// The subscription is retried after blockSubscriptionRetry.
+	clock.BlockUntil(1)
+	clock.Advance(blockSubscriptionRetry)
+	<-delivered
+
+	// Wait until the block is cached.
+	for {
+		if _, ok := pv.blocks.get(10); ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Cached blocks are not polled from the validator's RPC server.
+	rb, err := pv.queryBlock(ctx, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), rb.Block.Height)
+
+	cancel()
+	<-done
+	assert.Len(t, attempts, 2)
+}
@@ -0,0 +1,119 @@
+package privval
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// blockTimeSamples is the number of most recent block intervals the block time is
+	// measured from.
+	blockTimeSamples = 20
+
+	// minBlockTimeSamples is the number of block intervals needed before the measured
+	// block time is used.
+	minBlockTimeSamples = 5
+
+	// retryDialBlocks is the number of block times without a message from the
+	// validator after which it is dialed again if timeouts are adaptive.
+	retryDialBlocks = 5
+
+	// minAdaptiveRetryDial and maxAdaptiveRetryDial bound the adaptive time after which
+	// the validator is dialed again. The lower bound leaves room for the validator's
+	// pings, which don't depend on the block time.
+	minAdaptiveRetryDial = 5 * time.Second
+	maxAdaptiveRetryDial = 5 * time.Minute
+
+	// healthCheckBlocks is the number of block times between two health checks of the
+	// RPC endpoints if timeouts are adaptive.
+	healthCheckBlocks = 2
+
+	// minAdaptiveHealthCheck and maxAdaptiveHealthCheck bound the adaptive health
+	// check interval of the RPC endpoints.
+	minAdaptiveHealthCheck = time.Second
+	maxAdaptiveHealthCheck = time.Minute
+)
+
+// blockTimer measures the chain's block time from the times in the headers of the
+// observed blocks.
+type blockTimer struct {
+	mtx        sync.Mutex
+	lastHeight int64
+	lastTime   time.Time
+	intervals  []time.Duration
+}
+
+// observe records the time of the block at the given height. Blocks that aren't newer
+// than the last observed one are ignored. If blocks were skipped, the time since the
+// last observed block is spread evenly over the skipped heights.
+func (bt *blockTimer) observe(height int64, t time.Time) {
+	bt.mtx.Lock()
+	defer bt.mtx.Unlock()
+
+	if height <= bt.lastHeight {
+		return
+	}
+	if bt.lastHeight > 0 {
+		if d := t.Sub(bt.lastTime) / time.Duration(height-bt.lastHeight); d > 0 {
+			bt.intervals = append(bt.intervals, d)
+			if len(bt.intervals) > blockTimeSamples {
+				bt.intervals = bt.intervals[1:]
+			}
+		}
+	}
+	bt.lastHeight, bt.lastTime = height, t
+}
+
+// blockTime returns the median of the recent block intervals. It returns false if
+// not enough blocks have been observed yet.
+func (bt *blockTimer) blockTime() (time.Duration, bool) {
+	bt.mtx.Lock()
+	defer bt.mtx.Unlock()
+
+	if len(bt.intervals) < minBlockTimeSamples {
+		return 0, false
+	}
+	sorted := append([]time.Duration(nil), bt.intervals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return sorted[len(sorted)/2], true
+}
+
+// clampDuration returns d bounded by min and max.
+func clampDuration(d, min, max time.Duration) time.Duration {
+	switch {
+	case d < min:
+		return min
+	case d > max:
+		return max
+	}
+
+	return d
+}
+
+// retryDialAfter returns the time without a message from the validator after which
+// it is dialed again. If timeouts are adaptive, it is derived from the measured block
+// time, and the configured retry_dial_after is used until the block time is known.
+func (pv *SCFilePV) retryDialAfter() time.Duration {
+	if pv.Config.Base.AdaptiveTimeouts {
+		if bt, ok := pv.blockTimes.blockTime(); ok {
+			return clampDuration(retryDialBlocks*bt, minAdaptiveRetryDial, maxAdaptiveRetryDial)
+		}
+	}
+
+	return pv.getRetryDialAfter()
+}
+
+// healthCheckInterval returns the interval in which the RPC endpoints are health
+// checked. If timeouts are adaptive, it is derived from the measured block time, and
+// the configured health_check_interval is used until the block time is known.
+func (pv *SCFilePV) healthCheckInterval() time.Duration {
+	if pv.Config.Base.AdaptiveTimeouts {
+		if bt, ok := pv.blockTimes.blockTime(); ok {
+			return clampDuration(healthCheckBlocks*bt, minAdaptiveHealthCheck, maxAdaptiveHealthCheck)
+		}
+	}
+
+	return pv.RPCPool.Interval
+}
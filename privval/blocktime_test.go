@@ -0,0 +1,75 @@
+package privval
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockTimer(t *testing.T) {
+	bt := new(blockTimer)
+	start := time.Unix(1000, 0)
+
+	// Not enough blocks have been observed yet.
+	for h := int64(1); h <= minBlockTimeSamples; h++ {
+		bt.observe(h, start.Add(time.Duration(h)*time.Second))
+	}
+	_, ok := bt.blockTime()
+	assert.False(t, ok)
+
+	bt.observe(minBlockTimeSamples+1, start.Add((minBlockTimeSamples+1)*time.Second))
+	d, ok := bt.blockTime()
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, d)
+
+	// Old blocks are ignored and skipped heights are spread evenly.
+	bt.observe(3, start)
+	bt.observe(minBlockTimeSamples+3, start.Add((minBlockTimeSamples+1)*time.Second+4*time.Second))
+	assert.Len(t, bt.intervals, minBlockTimeSamples+1)
+	assert.Equal(t, 2*time.Second, bt.intervals[len(bt.intervals)-1])
+
+	// The median ignores outliers, e.g. a halted chain.
+	bt.observe(minBlockTimeSamples+4, start.Add(time.Hour))
+	d, _ = bt.blockTime()
+	assert.Equal(t, time.Second, d)
+
+	// Only the most recent intervals are kept.
+	for h := int64(100); h < 100+blockTimeSamples+1; h++ {
+		bt.observe(h, start.Add(2*time.Hour+time.Duration(h)*6*time.Second))
+	}
+	assert.Len(t, bt.intervals, blockTimeSamples)
+	d, _ = bt.blockTime()
+	assert.Equal(t, 6*time.Second, d)
+}
+
+func TestSCFilePV_AdaptiveTimeouts(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.RPC.Endpoints = []string{"tcp://10.0.0.2:26657"}
+	cfg.RPC.HealthCheckInterval = "10s"
+	pv := NewSCFilePV(nil, cfg, testState(t), testFilePV(t), nil)
+
+	observe := func(blockTime time.Duration) {
+		start := time.Unix(1000, 0)
+		for h := int64(1); h <= minBlockTimeSamples+1; h++ {
+			pv.blockTimes.observe(h, start.Add(time.Duration(h)*blockTime))
+		}
+	}
+	observe(30 * time.Second)
+
+	// The configured values are used unless timeouts are adaptive.
+	assert.Equal(t, 15*time.Second, pv.retryDialAfter())
+	assert.Equal(t, 10*time.Second, pv.healthCheckInterval())
+
+	pv.Config.Base.AdaptiveTimeouts = true
+	assert.Equal(t, 150*time.Second, pv.retryDialAfter())
+	assert.Equal(t, time.Minute, pv.healthCheckInterval())
+
+	// Fast chains are bounded by the lower limits.
+	pv.blockTimes = new(blockTimer)
+	assert.Equal(t, 15*time.Second, pv.retryDialAfter())
+	observe(200 * time.Millisecond)
+	assert.Equal(t, minAdaptiveRetryDial, pv.retryDialAfter())
+	assert.Equal(t, minAdaptiveHealthCheck, pv.healthCheckInterval())
+	assert.Equal(t, 200*time.Millisecond, pv.Status().BlockTime)
+}
@@ -0,0 +1,120 @@
+package privval
+
+import (
+	"context"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/ntp"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+)
+
+const (
+	// clockQueryTimeout is the timeout of a single NTP query.
+	clockQueryTimeout = 5 * time.Second
+)
+
+// ClockOffsetQuerier measures the offset of the local clock to a reference clock. The
+// offset is negative if the local clock is ahead.
+type ClockOffsetQuerier func(ctx context.Context) (time.Duration, error)
+
+// ClockStatus is the result of the last clock check.
+type ClockStatus struct {
+	// Offset is the offset of the local clock to the NTP server's one.
+	Offset time.Duration `json:"offset"`
+
+	// CheckedAt is the time of the last successful check.
+	CheckedAt time.Time `json:"checked_at"`
+
+	// Skewed is true if the offset exceeds max_offset, in which case the node refuses
+	// to be promoted and to resume signing after the validator was unjailed.
+	Skewed bool `json:"skewed"`
+}
+
+// queryClockOffset measures the offset of the local clock to the configured NTP
+// server.
+func (pv *SCFilePV) queryClockOffset(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, clockQueryTimeout)
+	defer cancel()
+
+	resp, err := ntp.Query(ctx, pv.Config.Clock.NTPServer)
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.Offset, nil
+}
+
+// GetClockStatus returns the result of the last clock check, and false if the clock
+// hasn't been checked (yet).
+func (pv *SCFilePV) GetClockStatus() (ClockStatus, bool) {
+	pv.clockMtx.RLock()
+	defer pv.clockMtx.RUnlock()
+
+	return pv.clockStatus, !pv.clockStatus.CheckedAt.IsZero()
+}
+
+// IsClockSkewed returns true if the last clock check found an offset above
+// max_offset.
+func (pv *SCFilePV) IsClockSkewed() bool {
+	pv.clockMtx.RLock()
+	defer pv.clockMtx.RUnlock()
+
+	return pv.clockStatus.Skewed
+}
+
+// setClockOffset records the measured offset of the local clock and logs it if it
+// exceeds warn_offset or max_offset.
+func (pv *SCFilePV) setClockOffset(offset time.Duration) {
+	abs := offset
+	if abs < 0 {
+		abs = -abs
+	}
+	skewed := abs > pv.Config.Clock.GetMaxOffset()
+
+	pv.clockMtx.Lock()
+	wasSkewed := pv.clockStatus.Skewed
+	pv.clockStatus = ClockStatus{Offset: offset, CheckedAt: pv.Clock.Now(), Skewed: skewed}
+	pv.clockMtx.Unlock()
+
+	switch {
+	case skewed && !wasSkewed:
+		pv.Logger.Error("The clock is off by %v, which exceeds the max_offset of %v. Refusing to be promoted or to resume signing until it is fixed", offset, pv.Config.Clock.GetMaxOffset())
+		pv.emit(watchtower.EventClockSkewed, "The clock is off by %v", offset)
+	case !skewed && wasSkewed:
+		pv.Logger.Info("The clock is back in sync (off by %v)", offset)
+		pv.emit(watchtower.EventClockRecovered, "The clock is back in sync (off by %v)", offset)
+	case abs > pv.Config.Clock.GetWarnOffset():
+		pv.Logger.Warn("The clock is off by %v, which exceeds the warn_offset of %v", offset, pv.Config.Clock.GetWarnOffset())
+	}
+
+	if pv.Gauges.ClockOffsetGauge != nil {
+		pv.Gauges.ClockOffsetGauge.Set(offset.Seconds())
+	}
+}
+
+// monitorClock checks the clock right away and then in the check interval until ctx
+// is done.
+func (pv *SCFilePV) monitorClock(ctx context.Context) {
+	defer pv.recoverPanic("clock")
+	defer pv.idle("clock")
+
+	interval := pv.Config.Clock.GetCheckInterval()
+	for {
+		pv.tick("clock", clockQueryTimeout+interval, nil)
+		offset, err := pv.QueryClockOffset(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			pv.Logger.Warn("Couldn't check the clock: %v", err)
+		} else {
+			pv.setClockOffset(offset)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-pv.Clock.After(interval):
+		}
+	}
+}
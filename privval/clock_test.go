@@ -0,0 +1,118 @@
+package privval
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	"github.com/stretchr/testify/assert"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+// mockClockCheck configures the clock check of the given node.
+func mockClockCheck(pv *SCFilePV) {
+	pv.Config.Clock.NTPServer = "pool.ntp.org"
+	pv.Config.Clock.CheckInterval = "10m"
+	pv.Config.Clock.WarnOffset = "500ms"
+	pv.Config.Clock.MaxOffset = "2s"
+}
+
+func TestSetClockOffset(t *testing.T) {
+	pv := mockSCFilePV(t)
+	mockClockCheck(pv)
+	_, ok := pv.GetClockStatus()
+	assert.False(t, ok)
+
+	pv.setClockOffset(time.Second)
+	status, ok := pv.GetClockStatus()
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, status.Offset)
+	assert.False(t, pv.IsClockSkewed())
+
+	// Clocks ahead are just as bad as clocks behind.
+	pv.setClockOffset(-3 * time.Second)
+	assert.True(t, pv.IsClockSkewed())
+	assert.True(t, pv.Status().Clock.Skewed)
+
+	pv.setClockOffset(100 * time.Millisecond)
+	assert.False(t, pv.IsClockSkewed())
+
+	var got []watchtower.EventType
+	events, _, _ := pv.watchEvents.Since(0)
+	for _, e := range events {
+		got = append(got, e.Type)
+	}
+	assert.Equal(t, []watchtower.EventType{watchtower.EventClockSkewed, watchtower.EventClockRecovered}, got)
+}
+
+func TestMonitorClock(t *testing.T) {
+	pv := mockSCFilePV(t)
+	mockClockCheck(pv)
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	pv.Clock = clock
+
+	queries := make(chan struct{}, 1)
+	pv.QueryClockOffset = func(ctx context.Context) (time.Duration, error) {
+		defer func() { queries <- struct{}{} }()
+		return 5 * time.Second, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pv.monitorClock(ctx)
+		close(done)
+	}()
+
+	// The clock is checked right away and then once per interval.
+	<-queries
+	clock.BlockUntil(1)
+	assert.True(t, pv.IsClockSkewed())
+
+	clock.Advance(10 * time.Minute)
+	<-queries
+	clock.BlockUntil(1)
+
+	cancel()
+	<-done
+}
+
+func TestHandleSignRequest_ClockSkewed(t *testing.T) {
+	pv := mockSCFilePV(t)
+	mockClockCheck(pv)
+	pv.SetRank(2)
+	pv.UnlockCounter()
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		return testBlockResult(t).Result, nil
+	}
+	pv.VerifyBlock = func(ctx context.Context, block *tm_coretypes.ResultBlock) error {
+		return nil
+	}
+	pv.setClockOffset(time.Minute)
+
+	// Missed blocks aren't counted while the clock is skewed, so the node can't be
+	// promoted.
+	_, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.ErrorIs(t, err, ErrNoSigningPermission)
+	assert.Zero(t, pv.GetMissedInARow())
+}
+
+func TestSetSlashingStatus_ClockSkewed(t *testing.T) {
+	now := time.Unix(0, 0)
+	pv := mockSCFilePV(t)
+	mockClockCheck(pv)
+	pv.Config.Slashing.PauseWhenJailed = true
+	pv.Config.Slashing.ResumeAfterUnjail = true
+	pv.setSlashingStatus(SlashingStatus{Jailed: true, UpdatedAt: now})
+	pv.setClockOffset(time.Minute)
+
+	// Signing isn't resumed until the clock is fixed.
+	pv.setSlashingStatus(SlashingStatus{UpdatedAt: now})
+	assert.True(t, pv.IsSigningPaused())
+
+	pv.setClockOffset(0)
+	pv.setSlashingStatus(SlashingStatus{UpdatedAt: now})
+	assert.False(t, pv.IsSigningPaused())
+}
@@ -0,0 +1,112 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+)
+
+// ConnState is the state of the connection to the validator.
+type ConnState string
+
+const (
+	// ConnConnecting means that SignCTRL is still waiting for the validator, either
+	// dialing it or, with the gRPC transport, setting up the gRPC server. No sign
+	// requests are handled in the meantime, but the HTTP server is already served.
+	ConnConnecting ConnState = "connecting"
+
+	// ConnConnected means that SignCTRL handles the validator's requests.
+	ConnConnected ConnState = "connected"
+)
+
+// connLost logs and records that the connection to the validator was lost for the
+// given reason.
+func (pv *SCFilePV) connLost(reason string) {
+	err := fmt.Errorf("%w (%v)", ErrConnLost, reason)
+	pv.Logger.Info("Lost connection to the validator... (%v) [%v]", reason, pv.recordError(err))
+}
+
+// setConnState sets the state of the connection to the validator.
+func (pv *SCFilePV) setConnState(state ConnState) {
+	pv.connMtx.Lock()
+	defer pv.connMtx.Unlock()
+
+	if state != pv.connState {
+		pv.connSince = pv.Clock.Now()
+		if state == ConnConnected {
+			pv.setLastConnected()
+		}
+	}
+	pv.connState = state
+}
+
+// GetConnState returns the state of the connection to the validator. It is empty if
+// the service hasn't been started yet.
+func (pv *SCFilePV) GetConnState() ConnState {
+	pv.connMtx.RLock()
+	defer pv.connMtx.RUnlock()
+
+	return pv.connState
+}
+
+// getConnStateSince returns the state of the connection to the validator along with
+// the time it has been in that state since.
+func (pv *SCFilePV) getConnStateSince() (ConnState, time.Time) {
+	pv.connMtx.RLock()
+	defer pv.connMtx.RUnlock()
+
+	return pv.connState, pv.connSince
+}
+
+// connect connects to the validator, either by dialing it or by serving the gRPC
+// PrivValidatorAPI, and then runs the main loop. Dialing is retried until ctx is done,
+// so that neither an unreachable validator nor a failed handshake takes the whole
+// process down. Failing to serve gRPC stops the service, as it's caused by the local
+// configuration.
+func (pv *SCFilePV) connect(ctx context.Context) {
+	defer pv.recoverPanic("connect")
+
+	// Either serve the validator's requests via gRPC or dial the validator.
+	if pv.Config.Privval.UsesGRPC() {
+		if err := pv.serveGRPC(ctx); err != nil {
+			pv.Logger.Error("couldn't serve gRPC: %v\n", err)
+			if err := pv.Stop(); err != nil {
+				pv.Logger.Error("%v", err)
+			}
+			return
+		}
+	} else {
+		for {
+			conn, err := pv.Dial(ctx)
+			if err == nil {
+				pv.SecretConn = withReadDeadline(conn, pv.Config.Base.GetReadDeadline())
+				break
+			}
+			if ctx.Err() != nil || errors.Is(err, connection.ErrAbortDial) {
+				pv.Logger.Debug("Terminating connect goroutine: %v\n", err)
+				return
+			}
+			delay := pv.dialBackoff.Next()
+			pv.Logger.Error("couldn't dial validator, retrying in %v: %v\n", delay, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-pv.Clock.After(delay):
+			}
+		}
+	}
+
+	// Disable the parts of the protocol the validator doesn't understand.
+	pv.negotiateCapabilities(ctx)
+	pv.setConnState(ConnConnected)
+
+	// Run the main loop, which reads the requests from the connection to the
+	// validator. The gRPC server handles them on its own.
+	if !pv.Config.Privval.UsesGRPC() {
+		goroutines.Go("run", func() { pv.run(ctx) })
+	}
+}
@@ -0,0 +1,142 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+)
+
+func TestSCFilePV_StartsWhileConnecting(t *testing.T) {
+	signerConn, validatorConn := net.Pipe()
+	defer validatorConn.Close()
+
+	// The first dial fails, e.g. due to a failed handshake, and the second one blocks
+	// until the validator is reachable.
+	reachable := make(chan struct{})
+	dials := 0
+	pv := mockSCFilePV(t)
+	pv.HTTP = nil
+	pv.CfgDir = t.TempDir()
+	pv.Config.Privval.ValidatorSetCheck = "off"
+	pv.dialBackoff = connection.NewBackoff(time.Millisecond, time.Millisecond, 1)
+	pv.Dial = func(ctx context.Context) (net.Conn, error) {
+		if dials++; dials == 1 {
+			return nil, errors.New("handshake failed")
+		}
+		select {
+		case <-reachable:
+			return signerConn, nil
+		case <-ctx.Done():
+			return nil, connection.ErrAbortDial
+		}
+	}
+
+	// The service comes up without the validator.
+	require.NoError(t, pv.Start())
+	defer func() { _ = pv.Stop() }()
+	assert.True(t, pv.IsRunning())
+	assert.Equal(t, ConnConnecting, pv.GetConnState())
+	assert.Equal(t, ConnConnecting, pv.Status().Connection)
+	assert.False(t, pv.WatchtowerStatus().Signing)
+
+	// Requests are handled once the validator is reachable.
+	close(reachable)
+	assert.Eventually(t, func() bool { return pv.GetConnState() == ConnConnected }, 5*time.Second, time.Millisecond)
+	assert.True(t, pv.WatchtowerStatus().Connected)
+
+	assert.NoError(t, validatorConn.SetDeadline(time.Now().Add(5*time.Second)))
+	_, err := tm_protoio.NewDelimitedWriter(validatorConn).WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+	assert.NoError(t, err)
+	var resp tm_privvalproto.Message
+	_, err = tm_protoio.NewDelimitedReader(validatorConn, maxRemoteSignerMsgSize).ReadMsg(&resp)
+	assert.NoError(t, err)
+	assert.NotNil(t, resp.GetPingResponse())
+}
+
+func TestSCFilePV_StopWhileConnecting(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.HTTP = nil
+	pv.CfgDir = t.TempDir()
+	pv.Config.Privval.ValidatorSetCheck = "off"
+	aborted := make(chan struct{})
+	pv.Dial = func(ctx context.Context) (net.Conn, error) {
+		<-ctx.Done()
+		close(aborted)
+		return nil, connection.ErrAbortDial
+	}
+
+	// Dialing is aborted once the service is stopped.
+	require.NoError(t, pv.Start())
+	require.NoError(t, pv.Stop())
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dialing wasn't aborted")
+	}
+	assert.Equal(t, ConnConnecting, pv.GetConnState())
+}
+
+func TestSCFilePV_ReconnectBackoff(t *testing.T) {
+	conns := make(chan net.Conn, 3)
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	pv := mockSCFilePV(t)
+	pv.HTTP = nil
+	pv.Clock = clock
+	pv.Config.Privval.ValidatorSetCheck = "off"
+	pv.Gauges.DialBackoffGauge = prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_dial_backoff"})
+	pv.Dial = func(ctx context.Context) (net.Conn, error) {
+		signerConn, validatorConn := net.Pipe()
+		conns <- validatorConn
+		return signerConn, nil
+	}
+	nextConn := func() net.Conn {
+		select {
+		case conn := <-conns:
+			return conn
+		case <-time.After(5 * time.Second):
+			t.Fatal("node didn't reconnect")
+			return nil
+		}
+	}
+	require.NoError(t, pv.Start())
+	defer func() {
+		assert.NoError(t, pv.Stop())
+		<-pv.Quit()
+	}()
+
+	// The first connection is redialed right away.
+	nextConn().Close()
+
+	// A reconnected connection that broke before the validator sent a message is
+	// only redialed after backing off.
+	nextConn().Close()
+	require.Eventually(t, func() bool { return prom_testutil.ToFloat64(pv.Gauges.DialBackoffGauge) > 0 }, 5*time.Second, time.Millisecond)
+	select {
+	case <-conns:
+		t.Fatal("node redialed without backing off")
+	case <-time.After(50 * time.Millisecond):
+	}
+	clock.Advance(connection.DefaultBackoffInitial)
+	validatorConn := nextConn()
+	defer validatorConn.Close()
+
+	// The backoff starts over once the validator sends a message.
+	assert.NoError(t, validatorConn.SetDeadline(time.Now().Add(5*time.Second)))
+	_, err := tm_protoio.NewDelimitedWriter(validatorConn).WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+	require.NoError(t, err)
+	var resp tm_privvalproto.Message
+	_, err = tm_protoio.NewDelimitedReader(validatorConn, maxRemoteSignerMsgSize).ReadMsg(&resp)
+	require.NoError(t, err)
+	assert.Equal(t, float64(0), prom_testutil.ToFloat64(pv.Gauges.DialBackoffGauge))
+}
@@ -0,0 +1,54 @@
+package privval
+
+import (
+	"strings"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+)
+
+// usesConnKey returns true if the validator is dialed over Tendermint's secret
+// connection, which is authenticated with the conn.key.
+func (pv *SCFilePV) usesConnKey() bool {
+	p := pv.Config.Privval
+	return !p.UsesGRPC() && !p.UsesMTLS() && strings.HasPrefix(pv.Config.Base.ValidatorListenAddress, "tcp://")
+}
+
+// setDialedConnKey sets the public key of the conn.key the validator was last dialed
+// with.
+func (pv *SCFilePV) setDialedConnKey(pubKey tm_crypto.PubKey) {
+	pv.connMtx.Lock()
+	defer pv.connMtx.Unlock()
+
+	pv.connKey = pubKey
+}
+
+// reloadConnKey reads the conn.key again and requests a new handshake with the
+// validator if it was rotated since the validator was last dialed. It returns true if
+// a new handshake was requested.
+func (pv *SCFilePV) reloadConnKey() bool {
+	if !pv.usesConnKey() {
+		return false
+	}
+	key, err := connection.LoadConnKey(pv.CfgDir)
+	if err != nil {
+		pv.Logger.Error("couldn't reload %v, keeping the current connection: %v", connection.KeyFile, err)
+		return false
+	}
+
+	pv.connMtx.RLock()
+	dialed := pv.connKey
+	pv.connMtx.RUnlock()
+	if dialed == nil || dialed.Equals(key.PubKey()) {
+		return false
+	}
+
+	select {
+	case pv.rehandshake <- struct{}{}:
+	default:
+		// A new handshake was already requested.
+	}
+	pv.Logger.Info("%v was rotated, re-handshaking with the validator", connection.KeyFile)
+
+	return true
+}
@@ -0,0 +1,66 @@
+package privval
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadConnKey(t *testing.T) {
+	conns := make(chan net.Conn, 2)
+	pv := mockSCFilePV(t)
+	pv.HTTP = nil
+	pv.Config.Privval.ValidatorSetCheck = "off"
+	require.NoError(t, connection.CreateBase64ConnKey(pv.CfgDir))
+	pv.Dial = func(ctx context.Context) (net.Conn, error) {
+		key, err := connection.LoadConnKey(pv.CfgDir)
+		if err != nil {
+			return nil, err
+		}
+		pv.setDialedConnKey(key.PubKey())
+		signerConn, validatorConn := net.Pipe()
+		conns <- validatorConn
+		return signerConn, nil
+	}
+	nextConn := func() net.Conn {
+		select {
+		case conn := <-conns:
+			return conn
+		case <-time.After(5 * time.Second):
+			t.Fatal("node didn't dial the validator")
+			return nil
+		}
+	}
+	require.NoError(t, pv.Start())
+	defer func() {
+		assert.NoError(t, pv.Stop())
+		<-pv.Quit()
+	}()
+	first := nextConn()
+	defer first.Close()
+	require.Eventually(t, func() bool { return pv.GetConnState() == ConnConnected }, 5*time.Second, time.Millisecond)
+
+	// Nothing happens as long as the key is the same.
+	assert.False(t, pv.reloadConnKey())
+
+	// A rotated key is used for a new handshake right away.
+	_, err := connection.RotateConnKey(pv.CfgDir)
+	require.NoError(t, err)
+	assert.True(t, pv.reloadConnKey())
+	second := nextConn()
+	defer second.Close()
+	assert.False(t, pv.reloadConnKey())
+}
+
+func TestReloadConnKey_UnixSocket(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.Config.Base.ValidatorListenAddress = "unix:///run/validator/privval.sock"
+
+	// Unix domain sockets don't use the conn.key.
+	assert.False(t, pv.reloadConnKey())
+}
@@ -0,0 +1,99 @@
+package privval
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+const (
+	// ConsumersDir is the name of the directory in the configuration directory that
+	// holds a subdirectory for each consumer chain.
+	ConsumersDir = "consumers"
+)
+
+// ConsumerDir returns the absolute path to the directory that holds the assigned
+// consumer key, the watermark and the state of the given consumer chain.
+func ConsumerDir(cfgDir, chainID string) string {
+	return filepath.Join(cfgDir, ConsumersDir, chainID)
+}
+
+// LoadConsumerFilePV loads the private validator that signs for the given consumer
+// chain. It uses the consumer key assigned to the validator if there is a
+// priv_validator_key.json in the consumer chain's directory, and the provider key in
+// the configuration directory otherwise. Either way, the watermark is kept in the
+// consumer chain's own priv_validator_state.json, which is created if it doesn't
+// exist yet.
+func LoadConsumerFilePV(cfgDir, chainID string) (tm_types.PrivValidator, error) {
+	dir := ConsumerDir(cfgDir, chainID)
+	if err := os.MkdirAll(dir, config.PermConfigDir); err != nil {
+		return nil, err
+	}
+
+	keyFile := KeyFilePath(dir)
+	if _, err := os.Stat(keyFile); os.IsNotExist(err) {
+		keyFile = KeyFilePath(cfgDir)
+	}
+	stateFile := StateFilePath(dir)
+	if _, err := os.Stat(stateFile); os.IsNotExist(err) {
+		// tm_privval.LoadFilePVEmptyState exits the process on an invalid key file, so
+		// make sure it can be loaded beforehand.
+		var key tm_privval.FilePVKey
+		if err := unmarshalFile(keyFile, &key); err != nil {
+			return nil, err
+		}
+		filePV := tm_privval.LoadFilePVEmptyState(keyFile, stateFile)
+		filePV.LastSignState.Save()
+
+		return filePV, nil
+	}
+
+	return fileSignerBackend(map[string]string{"key_file": keyFile, "state_file": stateFile})
+}
+
+// NewConsumerSCFilePV creates a new instance of SCFilePV that signs for the given
+// consumer chain. It keeps its state in the consumer chain's directory, so that its
+// rank and watermark are independent of the provider chain, and dials the consumer
+// chain's validator with the connection key from the configuration directory.
+func NewConsumerSCFilePV(logger types.Logger, cfg config.Config, cfgDir string, consumer config.Consumer) (*SCFilePV, error) {
+	dir := ConsumerDir(cfgDir, consumer.ChainID)
+	if cfg.Privval.StateMAC {
+		if err := os.MkdirAll(dir, config.PermConfigDir); err != nil {
+			return nil, err
+		}
+		if err := VerifyStateFiles(dir); err != nil {
+			return nil, err
+		}
+	}
+	tmpv, err := LoadConsumerFilePV(cfgDir, consumer.ChainID)
+	if err != nil {
+		return nil, err
+	}
+	state, err := config.LoadOrGenState(dir)
+	if err != nil {
+		return nil, err
+	}
+	if state.Recovered {
+		logger.Warn("%v was corrupted, recovered the state from its backup", config.StateFilePath(dir))
+	}
+	if cfg.Privval.StateMAC {
+		if err := SignStateFiles(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	pv := NewSCFilePV(logger.With("chain_id", consumer.ChainID), cfg.ForConsumer(consumer), state, tmpv, nil)
+	pv.CfgDir = dir
+	pv.Dial = func(ctx context.Context) (net.Conn, error) {
+		return connection.RetryDial(ctx, cfgDir, consumer.ValidatorListenAddress, pv.dialBackoff, pv.Logger)
+	}
+
+	return pv, nil
+}
@@ -0,0 +1,73 @@
+package privval
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+func TestConsumerDir(t *testing.T) {
+	assert.Equal(t, "/tmp/consumers/consumerchain", ConsumerDir("/tmp", "consumerchain"))
+}
+
+func TestLoadConsumerFilePV(t *testing.T) {
+	cfgDir := t.TempDir()
+	provider := tm_privval.GenFilePV(KeyFilePath(cfgDir), StateFilePath(cfgDir))
+	provider.LastSignState.Height = 100
+	provider.Save()
+
+	// Without an assigned consumer key, the provider key is used with a fresh
+	// watermark.
+	tmpv, err := LoadConsumerFilePV(cfgDir, "consumerchain")
+	assert.NoError(t, err)
+	filePV := tmpv.(*tm_privval.FilePV)
+	assert.Equal(t, provider.Key.PubKey, filePV.Key.PubKey)
+	assert.Equal(t, int64(0), filePV.LastSignState.Height)
+	assert.FileExists(t, StateFilePath(ConsumerDir(cfgDir, "consumerchain")))
+
+	// The consumer chain's watermark is independent of the provider chain's one.
+	filePV.LastSignState.Height = 10
+	filePV.LastSignState.Save()
+	tmpv, err = LoadConsumerFilePV(cfgDir, "consumerchain")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), tmpv.(*tm_privval.FilePV).LastSignState.Height)
+	assert.Equal(t, int64(100), tm_privval.LoadFilePV(KeyFilePath(cfgDir), StateFilePath(cfgDir)).LastSignState.Height)
+
+	// An assigned consumer key takes precedence over the provider key.
+	dir := ConsumerDir(cfgDir, "consumerchain")
+	assigned := tm_privval.GenFilePV(KeyFilePath(dir), filepath.Join(t.TempDir(), StateFile))
+	assigned.Key.Save()
+	tmpv, err = LoadConsumerFilePV(cfgDir, "consumerchain")
+	assert.NoError(t, err)
+	assert.Equal(t, assigned.Key.PubKey, tmpv.(*tm_privval.FilePV).Key.PubKey)
+	assert.Equal(t, int64(10), tmpv.(*tm_privval.FilePV).LastSignState.Height)
+}
+
+func TestLoadConsumerFilePV_NoKey(t *testing.T) {
+	_, err := LoadConsumerFilePV(t.TempDir(), "consumerchain")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestNewConsumerSCFilePV(t *testing.T) {
+	cfgDir := t.TempDir()
+	tm_privval.GenFilePV(KeyFilePath(cfgDir), StateFilePath(cfgDir)).Save()
+	consumer := config.Consumer{
+		ChainID:                   "consumerchain",
+		ValidatorListenAddress:    "tcp://127.0.0.1:3001",
+		ValidatorListenAddressRPC: "tcp://127.0.0.1:26667",
+	}
+
+	pv, err := NewConsumerSCFilePV(types.NewSyncLogger(ioutil.Discard, "", 0), testConfig(t), cfgDir, consumer)
+	assert.NoError(t, err)
+	assert.Equal(t, ConsumerDir(cfgDir, "consumerchain"), pv.CfgDir)
+	assert.Equal(t, "consumerchain", pv.Config.Privval.ChainID)
+	assert.Equal(t, consumer.ValidatorListenAddressRPC, pv.Config.Base.ValidatorListenAddressRPC)
+	assert.Nil(t, pv.HTTP)
+	assert.FileExists(t, config.StateFilePath(pv.CfgDir))
+}
@@ -0,0 +1,204 @@
+package privval
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+)
+
+const (
+	// crashReportEvents determines the number of recent log messages that are kept
+	// for crash reports.
+	crashReportEvents = 100
+)
+
+var (
+	// ErrCrashed is returned for sign requests that are received after the node
+	// recovered from a panic. A crashed node never signs again.
+	ErrCrashed = types.NewError(types.CodeCrashed, "node crashed")
+)
+
+// CrashReport defines the contents of the crash report file written when SignCTRL
+// recovers from a panic or when the watchdog detects a stalled goroutine, in which
+// case Stall describes the stall and Stack contains the stacks of all goroutines.
+type CrashReport struct {
+	Time          time.Time `json:"time"`
+	Goroutine     string    `json:"goroutine"`
+	Panic         string    `json:"panic"`
+	Stall         string    `json:"stall,omitempty"`
+	Stack         string    `json:"stack"`
+	Height        int64     `json:"height"`
+	Rank          int       `json:"rank"`
+	Counter       int       `json:"counter"`
+	Threshold     int       `json:"threshold"`
+	CounterLocked bool      `json:"counter_locked"`
+	Events        []string  `json:"events"`
+}
+
+// CrashReportFilePath returns the absolute path to the crash report file for a crash
+// at the given time.
+func CrashReportFilePath(cfgDir string, t time.Time) string {
+	return filepath.Join(cfgDir, fmt.Sprintf("signctrl_crash_%v.json", t.UTC().Format("20060102T150405.000000000Z")))
+}
+
+// eventLog keeps the most recent log messages in a ring buffer.
+type eventLog struct {
+	mtx    sync.Mutex
+	events []string
+	next   int
+}
+
+// newEventLog creates a new event log keeping the last n messages.
+func newEventLog(n int) *eventLog {
+	return &eventLog{events: make([]string, 0, n)}
+}
+
+// add appends the given message to the log, overwriting the oldest message if the
+// log is full.
+func (l *eventLog) add(msg string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if len(l.events) < cap(l.events) {
+		l.events = append(l.events, msg)
+		return
+	}
+	l.events[l.next] = msg
+	l.next = (l.next + 1) % len(l.events)
+}
+
+// list returns the logged messages from oldest to newest.
+func (l *eventLog) list() []string {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	return append(append([]string{}, l.events[l.next:]...), l.events[:l.next]...)
+}
+
+// recordingLogger must implement the Logger interface.
+var _ types.Logger = new(recordingLogger)
+
+// recordingLogger records every message in an event log before passing it on to the
+// wrapped logger.
+// Implements the Logger interface.
+type recordingLogger struct {
+	types.Logger
+	events *eventLog
+	fields string
+}
+
+// record adds the message to the event log.
+func (rl *recordingLogger) record(level string, format string, v ...interface{}) {
+	msg := strings.TrimSuffix(fmt.Sprintf(format, v...), "\n")
+	rl.events.add(fmt.Sprintf("%v %v %v%v", time.Now().UTC().Format(time.RFC3339Nano), level, msg, rl.fields))
+}
+
+// Debug records the message and passes it on to the wrapped logger.
+func (rl *recordingLogger) Debug(format string, v ...interface{}) {
+	rl.record("DEBUG", format, v...)
+	rl.Logger.Debug(format, v...)
+}
+
+// Info records the message and passes it on to the wrapped logger.
+func (rl *recordingLogger) Info(format string, v ...interface{}) {
+	rl.record("INFO", format, v...)
+	rl.Logger.Info(format, v...)
+}
+
+// Warn records the message and passes it on to the wrapped logger.
+func (rl *recordingLogger) Warn(format string, v ...interface{}) {
+	rl.record("WARN", format, v...)
+	rl.Logger.Warn(format, v...)
+}
+
+// Error records the message and passes it on to the wrapped logger.
+func (rl *recordingLogger) Error(format string, v ...interface{}) {
+	rl.record("ERR", format, v...)
+	rl.Logger.Error(format, v...)
+}
+
+// With returns a recording logger that adds the given fields to every message.
+// Implements the Logger interface.
+func (rl *recordingLogger) With(keyvals ...interface{}) types.Logger {
+	return &recordingLogger{
+		Logger: rl.Logger.With(keyvals...),
+		events: rl.events,
+		fields: rl.fields + types.FormatFields(keyvals...),
+	}
+}
+
+// IsCrashed returns true if the node recovered from a panic.
+func (pv *SCFilePV) IsCrashed() bool {
+	return atomic.LoadInt32(&pv.crashed) == 1
+}
+
+// recoverPanic recovers from a panic in the given goroutine and crashes the node. It
+// must be deferred directly at the start of the goroutine.
+func (pv *SCFilePV) recoverPanic(goroutine string) {
+	if r := recover(); r != nil {
+		pv.crash(goroutine, r, debug.Stack())
+	}
+}
+
+// crash moves the node into a safe non-signing state after a panic, writes a crash
+// report to the configuration directory and stops the node. OnCrash is called last
+// with the crash report.
+func (pv *SCFilePV) crash(goroutine string, r interface{}, stack []byte) {
+	// Refuse to sign anything from now on. Only the first panic is reported.
+	if !atomic.CompareAndSwapInt32(&pv.crashed, 0, 1) {
+		return
+	}
+
+	report := CrashReport{
+		Time:      time.Now(),
+		Goroutine: goroutine,
+		Panic:     fmt.Sprint(r),
+		Stack:     string(stack),
+	}
+	pv.Logger.Error("Recovered from panic in %v goroutine: %v\n", goroutine, report.Panic)
+	pv.emit(watchtower.EventCrashed, "Recovered from panic in %v goroutine", goroutine)
+	pv.writeCrashReport(&report)
+
+	if pv.SecretConn != nil {
+		pv.SecretConn.Close()
+	}
+	if err := pv.Stop(); err != nil && !errors.Is(err, types.ErrAlreadyStopped) {
+		pv.Logger.Error("%v", err)
+	}
+
+	if pv.OnCrash != nil {
+		pv.OnCrash(report)
+	}
+}
+
+// writeCrashReport adds the node's state and the most recent log messages to the
+// given report and writes it to the configuration directory.
+func (pv *SCFilePV) writeCrashReport(report *CrashReport) {
+	report.Height = pv.GetCurrentHeight()
+	report.Rank = pv.GetRank()
+	report.Counter = pv.GetMissedInARow()
+	report.Threshold = pv.GetThreshold()
+	report.CounterLocked = pv.IsCounterLocked()
+	if pv.events != nil {
+		report.Events = pv.events.list()
+	}
+
+	if bytes, err := tm_json.MarshalIndent(report, "", "\t"); err != nil {
+		pv.Logger.Error("couldn't marshal crash report: %v\n", err)
+	} else if err := ioutil.WriteFile(CrashReportFilePath(pv.CfgDir, report.Time), bytes, config.PermStateFile); err != nil {
+		pv.Logger.Error("couldn't write crash report: %v\n", err)
+	} else {
+		pv.Logger.Error("Wrote crash report to %v\n", CrashReportFilePath(pv.CfgDir, report.Time))
+	}
+}
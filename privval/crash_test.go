@@ -0,0 +1,94 @@
+package privval
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/internal/leaktest"
+	"github.com/stretchr/testify/assert"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+func TestCrashReportFilePath(t *testing.T) {
+	path := CrashReportFilePath("/tmp", time.Date(2021, 3, 4, 5, 6, 7, 8, time.UTC))
+	assert.Equal(t, "/tmp/signctrl_crash_20210304T050607.000000008Z.json", path)
+}
+
+func TestEventLog(t *testing.T) {
+	l := newEventLog(3)
+	assert.Empty(t, l.list())
+
+	l.add("1")
+	l.add("2")
+	assert.Equal(t, []string{"1", "2"}, l.list())
+
+	l.add("3")
+	l.add("4")
+	l.add("5")
+	assert.Equal(t, []string{"3", "4", "5"}, l.list())
+}
+
+func TestSCFilePV_Crash(t *testing.T) {
+	leaktest.Check(t)
+
+	// Panic while handling pings.
+	RegisterHandler(&tm_privvalproto.Message_PingRequest{}, func(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
+		panic("test panic")
+	})
+	defer RegisterHandler(&tm_privvalproto.Message_PingRequest{}, func(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
+		return handlePingRequest(pv)
+	})
+
+	signerConn, validatorConn := net.Pipe()
+	defer validatorConn.Close()
+
+	pv := mockSCFilePV(t)
+	pv.HTTP = nil
+	pv.CfgDir = t.TempDir()
+	pv.Dial = func(ctx context.Context) (net.Conn, error) {
+		return signerConn, nil
+	}
+	reports := make(chan CrashReport, 1)
+	pv.OnCrash = func(report CrashReport) {
+		reports <- report
+	}
+	err := pv.Start()
+	assert.NoError(t, err)
+
+	w := tm_protoio.NewDelimitedWriter(validatorConn)
+	_, err = w.WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+	assert.NoError(t, err)
+
+	report := <-reports
+	<-pv.Quit()
+	assert.True(t, pv.IsCrashed())
+	assert.Equal(t, "run", report.Goroutine)
+	assert.Equal(t, "test panic", report.Panic)
+	assert.Contains(t, report.Stack, "TestSCFilePV_Crash")
+	assert.Equal(t, 1, report.Rank)
+	assert.NotEmpty(t, report.Events)
+
+	// The crash report must have been written to the configuration directory.
+	matches, err := filepath.Glob(filepath.Join(pv.CfgDir, "signctrl_crash_*.json"))
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+	bytes, err := ioutil.ReadFile(matches[0])
+	assert.NoError(t, err)
+	var written CrashReport
+	assert.NoError(t, tm_json.Unmarshal(bytes, &written))
+	assert.Equal(t, report.Panic, written.Panic)
+
+	// A crashed node must never sign again.
+	_, err = handleSignRequest(context.Background(), wrapMsg(&tm_privvalproto.SignVoteRequest{
+		Vote:    &tm_typesproto.Vote{Type: tm_typesproto.PrevoteType, Height: 1},
+		ChainId: "testchain",
+	}), pv)
+	assert.ErrorIs(t, err, ErrCrashed)
+}
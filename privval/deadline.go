@@ -0,0 +1,64 @@
+package privval
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// deadlineConn is a net.Conn that extends its read deadline before every read, so that
+// reading from a connection that doesn't receive any data for the given time, e.g.
+// because the validator's host died without closing it, fails with a timeout.
+type deadlineConn struct {
+	net.Conn
+	d time.Duration
+
+	// onTimeout is called if a read timed out, unless it is nil.
+	onTimeout func()
+}
+
+// withReadDeadline returns conn with a read deadline of d, or conn itself if d is 0.
+func withReadDeadline(conn net.Conn, d time.Duration) net.Conn {
+	if d <= 0 {
+		return conn
+	}
+
+	return &deadlineConn{Conn: conn, d: d}
+}
+
+// Read extends the read deadline and reads from the connection.
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	// Setting the deadline fails on closed connections, which the read reports in a
+	// way that tells why, e.g. with io.EOF.
+	_ = c.Conn.SetReadDeadline(time.Now().Add(c.d))
+	n, err := c.Conn.Read(b)
+	if isTimeout(err) && c.onTimeout != nil {
+		c.onTimeout()
+	}
+
+	return n, err
+}
+
+// deadlineListener is a net.Listener whose accepted connections have a read deadline
+// of d. onTimeout is called once a read from a connection timed out.
+type deadlineListener struct {
+	net.Listener
+	d         time.Duration
+	onTimeout func()
+}
+
+// Accept waits for the next connection and returns it with the read deadline.
+func (l *deadlineListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &deadlineConn{Conn: conn, d: l.d, onTimeout: l.onTimeout}, nil
+}
+
+// isTimeout returns true if err is caused by an exceeded deadline.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,78 @@
+package privval
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithReadDeadline(t *testing.T) {
+	conn, other := net.Pipe()
+	defer other.Close()
+	assert.Equal(t, conn, withReadDeadline(conn, 0))
+
+	// Reading fails once nothing was received for the deadline, but the deadline is
+	// extended by every read.
+	conn = withReadDeadline(conn, 100*time.Millisecond)
+	go func() { _, _ = other.Write([]byte("ping")) }()
+	_, err := conn.Read(make([]byte, 4))
+	require.NoError(t, err)
+	_, err = conn.Read(make([]byte, 4))
+	assert.True(t, isTimeout(err))
+}
+
+func TestDeadlineListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	var timeouts int32
+	listener = &deadlineListener{Listener: listener, d: 100 * time.Millisecond, onTimeout: func() {
+		atomic.AddInt32(&timeouts, 1)
+	}}
+	defer listener.Close()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// A connection that stays silent is reported.
+	_, err = conn.Read(make([]byte, 1))
+	assert.True(t, isTimeout(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&timeouts))
+}
+
+func TestSCFilePV_ReadDeadline(t *testing.T) {
+	conns := make(chan net.Conn, 2)
+	pv := mockSCFilePV(t)
+	pv.HTTP = nil
+	pv.Config.Privval.ValidatorSetCheck = "off"
+	pv.Config.Base.ReadDeadline = "100ms"
+	pv.Dial = func(ctx context.Context) (net.Conn, error) {
+		signerConn, validatorConn := net.Pipe()
+		conns <- validatorConn
+		return signerConn, nil
+	}
+	require.NoError(t, pv.Start())
+	defer func() {
+		assert.NoError(t, pv.Stop())
+		<-pv.Quit()
+	}()
+
+	// A connection on which nothing is received is redialed, even though the timeout
+	// of retry_dial_after is far off.
+	first := <-conns
+	defer first.Close()
+	select {
+	case conn := <-conns:
+		conn.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("node didn't redial the silent connection")
+	}
+}
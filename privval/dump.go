@@ -0,0 +1,92 @@
+package privval
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+// DiagnosticDump defines the contents of the diagnostic dump file, a snapshot of the
+// node's internal state written on request, e.g. on SIGUSR1, for forensics on a live
+// node without attaching a debugger.
+type DiagnosticDump struct {
+	Time time.Time `json:"time"`
+
+	// Status is the node's status as served by /status, including the rank, the
+	// counter, the connection, the RPC endpoints' view of the chain and the
+	// goroutines per subsystem.
+	Status StatusResponse `json:"status"`
+
+	// CounterLocked is true if missed blocks aren't counted until the validator's
+	// first commitsig was found.
+	CounterLocked bool `json:"counter_locked"`
+
+	// Watermark is the last sign state of the signer backend. It is nil if the
+	// backend doesn't keep one locally.
+	Watermark *Watermark `json:"watermark,omitempty"`
+
+	// Goroutines is the total number of goroutines, and Stack contains their stacks.
+	Goroutines int    `json:"goroutines"`
+	Stack      string `json:"stack"`
+
+	// WatchEvents are the buffered watchtower events, and Events the most recent log
+	// messages.
+	WatchEvents []watchtower.Event `json:"watch_events"`
+	Events      []string           `json:"events"`
+}
+
+// DiagnosticDumpFilePath returns the absolute path to the diagnostic dump file for a
+// dump at the given time.
+func DiagnosticDumpFilePath(cfgDir string, t time.Time) string {
+	return filepath.Join(cfgDir, fmt.Sprintf("signctrl_dump_%v.json", t.UTC().Format("20060102T150405.000000000Z")))
+}
+
+// DiagnosticDump takes a snapshot of the node's internal state.
+func (pv *SCFilePV) DiagnosticDump() DiagnosticDump {
+	dump := DiagnosticDump{
+		Time:          time.Now(),
+		Status:        pv.Status(),
+		CounterLocked: pv.IsCounterLocked(),
+		Goroutines:    runtime.NumGoroutine(),
+		Stack:         string(allStacks()),
+	}
+
+	pv.signerMtx.RLock()
+	if filePV, ok := pv.TMFilePV.(*tm_privval.FilePV); ok {
+		lss := filePV.LastSignState
+		dump.Watermark = &Watermark{Height: lss.Height, Round: lss.Round, Step: lss.Step}
+	}
+	pv.signerMtx.RUnlock()
+
+	if pv.watchEvents != nil {
+		dump.WatchEvents, _, _ = pv.watchEvents.Since(0)
+	}
+	if pv.events != nil {
+		dump.Events = pv.events.list()
+	}
+
+	return dump
+}
+
+// WriteDiagnosticDump writes a snapshot of the node's internal state to the
+// configuration directory and returns the path of the file.
+func (pv *SCFilePV) WriteDiagnosticDump() (string, error) {
+	dump := pv.DiagnosticDump()
+	bytes, err := tm_json.MarshalIndent(dump, "", "\t")
+	if err != nil {
+		return "", err
+	}
+	path := DiagnosticDumpFilePath(pv.CfgDir, dump.Time)
+	if err := ioutil.WriteFile(path, bytes, config.PermStateFile); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
@@ -0,0 +1,41 @@
+package privval
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+func TestDiagnosticDumpFilePath(t *testing.T) {
+	path := DiagnosticDumpFilePath("/tmp", time.Date(2021, 3, 4, 5, 6, 7, 8, time.UTC))
+	assert.Equal(t, "/tmp/signctrl_dump_20210304T050607.000000008Z.json", path)
+}
+
+func TestSCFilePV_WriteDiagnosticDump(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.CfgDir = t.TempDir()
+	pv.TMFilePV.(*tm_privval.FilePV).LastSignState.Height = 42
+	pv.Logger.Info("Something worth dumping")
+
+	path, err := pv.WriteDiagnosticDump()
+	require.NoError(t, err)
+
+	bytes, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	var dump DiagnosticDump
+	require.NoError(t, tm_json.Unmarshal(bytes, &dump))
+
+	assert.Equal(t, pv.GetRank(), dump.Status.Rank)
+	assert.Equal(t, pv.GetMissedInARow(), dump.Status.Counter)
+	assert.Positive(t, dump.Goroutines)
+	assert.Contains(t, dump.Stack, "goroutine")
+	require.NotNil(t, dump.Watermark)
+	assert.Equal(t, int64(42), dump.Watermark.Height)
+	require.NotEmpty(t, dump.Events)
+	assert.Contains(t, dump.Events[len(dump.Events)-1], "Something worth dumping")
+}
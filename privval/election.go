@@ -0,0 +1,75 @@
+package privval
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/BlockscapeNetwork/signctrl/election"
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+)
+
+// ElectionStateFile is the file the node's term and vote in the leader election are
+// persisted in, relative to the configuration directory.
+const ElectionStateFile = "signctrl_election.json"
+
+// startElection takes part in the leader election of the set over the p2p connections
+// until ctx is done, and lets the election decide the node's rank. The heartbeats must
+// have been started already.
+func (pv *SCFilePV) startElection(ctx context.Context) error {
+	peers := make([]string, len(pv.Config.P2P.Peers))
+	for i, addr := range pv.Config.P2P.Peers {
+		peers[i] = strings.TrimPrefix(addr, "tcp://")
+	}
+	e, err := election.New(election.Config{
+		Name:              pv.p2p.Name,
+		Peers:             peers,
+		ElectionTimeout:   pv.Config.P2P.GetElectionTimeout(),
+		HeartbeatInterval: pv.Config.P2P.GetHeartbeatInterval(),
+		StateFile:         filepath.Join(pv.CfgDir, ElectionStateFile),
+	}, pv.p2p, pv.Clock, pv.Logger)
+	if err != nil {
+		return err
+	}
+	e.OnChange = pv.onLeadershipChange
+
+	pv.p2p.Handle(election.TypeVote, func(payload json.RawMessage) (interface{}, error) {
+		var req election.VoteRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return e.HandleVote(req)
+	})
+	pv.p2p.Handle(election.TypeHeartbeat, func(payload json.RawMessage) (interface{}, error) {
+		var req election.HeartbeatRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return nil, err
+		}
+		return e.HandleHeartbeat(req)
+	})
+
+	pv.election = e
+	pv.BaseSignCtrled.SetLeadership(e)
+	pv.Logger.Info("Electing the signer with raft among %v nodes", len(peers)+1)
+
+	goroutines.Go("election", func() {
+		defer pv.recoverPanic("election")
+		e.Run(ctx)
+	})
+
+	return nil
+}
+
+// onLeadershipChange syncs the node's rank with the leadership and reports the change.
+func (pv *SCFilePV) onLeadershipChange(leader bool) {
+	_ = pv.Promote()
+	if leader {
+		return
+	}
+	pv.emit(watchtower.EventDemoted, "Lost the leadership, demoted to rank %v", pv.GetRank())
+	if pv.Gauges.RankGauge != nil {
+		pv.Gauges.RankGauge.Set(float64(pv.GetRank()))
+	}
+}
@@ -0,0 +1,72 @@
+package privval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/election"
+	"github.com/BlockscapeNetwork/signctrl/p2p"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartElection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The peer only votes and never runs for leader itself.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	peer := p2p.NewNode("b", testP2PSecret, 5*time.Second, types.SystemClock)
+	voter, err := election.New(election.Config{
+		Name:              "b",
+		ElectionTimeout:   300 * time.Millisecond,
+		HeartbeatInterval: 100 * time.Millisecond,
+		StateFile:         filepath.Join(t.TempDir(), ElectionStateFile),
+	}, peer, types.SystemClock, nil)
+	require.NoError(t, err)
+	peer.Handle(election.TypeVote, func(payload json.RawMessage) (interface{}, error) {
+		var req election.VoteRequest
+		require.NoError(t, json.Unmarshal(payload, &req))
+		return voter.HandleVote(req)
+	})
+	peer.Handle(election.TypeHeartbeat, func(payload json.RawMessage) (interface{}, error) {
+		var req election.HeartbeatRequest
+		require.NoError(t, json.Unmarshal(payload, &req))
+		return voter.HandleHeartbeat(req)
+	})
+	peerCtx, stopPeer := context.WithCancel(ctx)
+	go peer.Serve(peerCtx, ln, nil)
+
+	pv := mockSCFilePV(t)
+	pv.CfgDir = t.TempDir()
+	pv.Config.Base.Coordination = config.CoordinationRaft
+	pv.Config.P2P.Peers = []string{fmt.Sprintf("tcp://%v", ln.Addr())}
+	pv.Config.P2P.HeartbeatInterval = "100ms"
+	pv.Config.P2P.ElectionTimeout = "300ms"
+	pv.p2p = p2p.NewNode("a", testP2PSecret, 5*time.Second, types.SystemClock)
+	require.NoError(t, pv.startElection(ctx))
+
+	// The node doesn't sign until it is elected.
+	assert.Equal(t, 2, pv.GetRank())
+	require.Eventually(t, func() bool { return pv.GetRank() == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, &election.Status{Role: election.Leader, Term: 1, Leader: "a"}, pv.Status().Election)
+	assert.Eventually(t, func() bool { return voter.Status().Leader == "a" }, 5*time.Second, 10*time.Millisecond)
+	assert.FileExists(t, filepath.Join(pv.CfgDir, ElectionStateFile))
+
+	// The node steps down once the peer doesn't acknowledge its heartbeats anymore.
+	stopPeer()
+	require.Eventually(t, func() bool {
+		events, _, _ := pv.watchEvents.Since(0)
+		return len(events) > 0 && events[len(events)-1].Type == watchtower.EventDemoted
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, pv.GetRank())
+}
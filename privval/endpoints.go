@@ -0,0 +1,55 @@
+package privval
+
+import (
+	"context"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/resources"
+	"github.com/BlockscapeNetwork/signctrl/rpc"
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+// newRPCPool creates the pool of the validator's RPC server and the further RPC
+// endpoints from the configuration. The health checks aren't retried, so that slow
+// endpoints are noticed.
+func newRPCPool(logger types.Logger, cfg config.Config) *rpc.Pool {
+	client := &rpc.Client{Logger: logger, Timeout: cfg.RPC.GetTimeout()}
+	addrs := append([]string{cfg.Base.ValidatorListenAddressRPC}, cfg.RPC.Endpoints...)
+	pool := rpc.NewPool(client, addrs...)
+	if interval := cfg.RPC.GetHealthCheckInterval(); interval > 0 {
+		pool.Interval = interval
+	}
+	pool.MaxLag = cfg.RPC.MaxLag
+	pool.MaxParallel = resources.PoolSize(len(addrs))
+
+	return pool
+}
+
+// rpcAddr returns the address of the RPC server blocks and validator sets are queried
+// from. It is the best endpoint of the RPC pool if there are further endpoints, and
+// the configured validator_laddr_rpc otherwise.
+func (pv *SCFilePV) rpcAddr() string {
+	if pv.RPCPool == nil {
+		return pv.Config.Base.ValidatorListenAddressRPC
+	}
+
+	return pv.RPCPool.Best()
+}
+
+// monitorRPCEndpoints health checks the RPC endpoints right away and then in the
+// health check interval until ctx is done.
+func (pv *SCFilePV) monitorRPCEndpoints(ctx context.Context) {
+	defer pv.recoverPanic("rpc_pool")
+	defer pv.idle("rpc_pool")
+
+	for {
+		pv.tick("rpc_pool", pv.healthCheckInterval(), nil)
+		pv.RPCPool.Check(ctx)
+		pv.checkStale()
+		select {
+		case <-ctx.Done():
+			return
+		case <-pv.Clock.After(pv.healthCheckInterval()):
+		}
+	}
+}
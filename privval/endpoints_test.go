@@ -0,0 +1,61 @@
+package privval
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSCFilePV_RPCPool(t *testing.T) {
+	pv := mockSCFilePV(t)
+	assert.Nil(t, pv.RPCPool)
+	assert.Equal(t, pv.Config.Base.ValidatorListenAddressRPC, pv.rpcAddr())
+
+	cfg := testConfig(t)
+	cfg.RPC.Endpoints = []string{"tcp://10.0.0.2:26657"}
+	cfg.RPC.HealthCheckInterval = "3s"
+	cfg.RPC.MaxLag = 5
+	pv = NewSCFilePV(nil, cfg, testState(t), testFilePV(t), nil)
+	assert.NotNil(t, pv.RPCPool)
+	assert.Equal(t, 3*time.Second, pv.RPCPool.Interval)
+	assert.Equal(t, int64(5), pv.RPCPool.MaxLag)
+
+	// The validator's RPC server is used until the first health check.
+	assert.Equal(t, cfg.Base.ValidatorListenAddressRPC, pv.rpcAddr())
+	assert.Len(t, pv.Status().RPCEndpoints, 2)
+}
+
+func TestSCFilePV_RPCPoolFailover(t *testing.T) {
+	var queried string
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/status":
+			_, _ = fmt.Fprint(rw, `{"jsonrpc":"2.0","id":-1,"result":{"node_info":{"version":"0.34.8"},"sync_info":{"latest_block_height":"100"}}}`)
+		default:
+			queried = r.URL.Path
+			http.Error(rw, "not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	sentry := "tcp://" + srv.Listener.Addr().String()
+
+	// The validator's RPC server is down, so the sentry's one is queried.
+	cfg := testConfig(t)
+	cfg.Base.ValidatorListenAddressRPC = "tcp://127.0.0.1:1"
+	cfg.RPC.Endpoints = []string{sentry}
+	pv := NewSCFilePV(nil, cfg, testState(t), testFilePV(t), nil)
+	pv.RPCPool.Check(context.Background())
+	assert.Equal(t, sentry, pv.rpcAddr())
+
+	_, _ = pv.QueryBlock(context.Background(), 10)
+	assert.Equal(t, "/block", queried)
+
+	endpoints := pv.Status().RPCEndpoints
+	assert.Equal(t, []bool{false, true}, []bool{endpoints[0].Healthy, endpoints[1].Healthy})
+	assert.Equal(t, int64(100), endpoints[1].Height)
+}
@@ -0,0 +1,71 @@
+package privval
+
+import (
+	"fmt"
+
+	"github.com/BlockscapeNetwork/signctrl/p2p"
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+// ErrFenced is returned if a peer announced a higher epoch than the node's own, i.e.
+// another node took the signing authority after the node did.
+var ErrFenced = types.NewError(types.CodeRankConflict, "signing authority was taken over by another node")
+
+// GetEpoch returns the epoch the node last took the signing authority in, or 0 if it
+// never did.
+func (pv *SCFilePV) GetEpoch() uint64 {
+	pv.stateMtx.Lock()
+	defer pv.stateMtx.Unlock()
+
+	return pv.State.Epoch
+}
+
+// peerEpoch returns the peer that announced the highest epoch in its last heartbeat,
+// preferring the first one on rank 1 among equal epochs. There is none if no
+// heartbeats are exchanged or no peer ever took the signing authority.
+func (pv *SCFilePV) peerEpoch() (p2p.PeerStatus, bool) {
+	if pv.p2p == nil {
+		return p2p.PeerStatus{}, false
+	}
+	var highest p2p.PeerStatus
+	for _, p := range pv.p2p.Peers() {
+		if p.Epoch > highest.Epoch || (p.Epoch == highest.Epoch && p.Rank == 1 && highest.Rank != 1) {
+			highest = p
+		}
+	}
+
+	return highest, highest.Epoch > 0
+}
+
+// takeEpoch takes the signing authority in an epoch higher than the node's own and
+// any epoch announced by its peers, so that the previous signer is fenced off once it
+// receives the node's heartbeats. The epoch must be saved before the node signs.
+func (pv *SCFilePV) takeEpoch() {
+	peer, _ := pv.peerEpoch()
+
+	pv.stateMtx.Lock()
+	if peer.Epoch > pv.State.Epoch {
+		pv.State.Epoch = peer.Epoch
+	}
+	pv.State.Epoch++
+	epoch := pv.State.Epoch
+	pv.stateMtx.Unlock()
+
+	pv.Logger.Info("Taking the signing authority in epoch %v", epoch)
+}
+
+// checkEpoch returns ErrFenced if a peer announced a higher epoch than the node's own.
+// Of two nodes on rank 1 in the same epoch, e.g. after they were promoted on either
+// side of a partition, the one whose name sorts first keeps signing.
+func (pv *SCFilePV) checkEpoch() error {
+	peer, ok := pv.peerEpoch()
+	if !ok {
+		return nil
+	}
+	epoch := pv.GetEpoch()
+	if peer.Epoch > epoch || (peer.Epoch == epoch && peer.Rank == 1 && peer.Node < pv.p2p.Name) {
+		return fmt.Errorf("peer %v is in epoch %v, this node in epoch %v", peer.Node, peer.Epoch, epoch)
+	}
+
+	return nil
+}
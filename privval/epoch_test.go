@@ -0,0 +1,77 @@
+package privval
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/p2p"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+func TestTakeEpoch(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.CfgDir = t.TempDir()
+	assert.Zero(t, pv.GetEpoch())
+
+	pv.takeEpoch()
+	assert.Equal(t, uint64(1), pv.GetEpoch())
+
+	// The new epoch is higher than any epoch announced by a peer.
+	receive := mockP2P(t, pv)
+	receive(p2p.Heartbeat{Node: "b", Time: pv.Clock.Now(), Rank: 2, Epoch: 7})
+	pv.SetRank(1)
+	pv.OnPromote()
+	assert.Equal(t, uint64(8), pv.GetEpoch())
+
+	// The epoch is persisted along with the rank.
+	state, err := config.LoadOrGenState(pv.CfgDir)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), state.Epoch)
+}
+
+func TestCheckEpoch(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.State.Epoch = 3
+	assert.NoError(t, pv.checkEpoch())
+
+	receive := mockP2P(t, pv)
+	receive(p2p.Heartbeat{Node: "b", Time: pv.Clock.Now(), Rank: 2, Epoch: 2})
+	assert.NoError(t, pv.checkEpoch())
+
+	// Of two nodes on rank 1 in the same epoch, the one whose name sorts first signs.
+	receive(p2p.Heartbeat{Node: "c", Time: pv.Clock.Now(), Rank: 1, Epoch: 3})
+	assert.NoError(t, pv.checkEpoch())
+	receive(p2p.Heartbeat{Node: "0", Time: pv.Clock.Now(), Rank: 1, Epoch: 3})
+	assert.Error(t, pv.checkEpoch())
+
+	pv.State.Epoch = 4
+	assert.NoError(t, pv.checkEpoch())
+	receive(p2p.Heartbeat{Node: "b", Time: pv.Clock.Now().Add(1), Rank: 2, Epoch: 5})
+	assert.Error(t, pv.checkEpoch())
+}
+
+func TestHandleSignRequest_Fenced(t *testing.T) {
+	dir := t.TempDir()
+	pv := mockSCFilePV(t)
+	pv.TMFilePV = tm_privval.GenFilePV(filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile))
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		return testBlockResult(t).Result, nil
+	}
+	pv.State.Epoch = 1
+	receive := mockP2P(t, pv)
+	receive(p2p.Heartbeat{Node: "b", Time: pv.Clock.Now(), Rank: 1, Epoch: 2})
+
+	// Rank 1 doesn't sign once a peer took over in a higher epoch.
+	require.Equal(t, 1, pv.GetRank())
+	req := testSignVoteRequest(t)
+	resp, err := HandleRequest(context.Background(), req, pv)
+	assert.ErrorIs(t, err, ErrFenced)
+	assert.NotNil(t, resp.GetSignedVoteResponse().GetError())
+	assert.Empty(t, req.GetSignVoteRequest().Vote.Signature)
+	assert.Equal(t, uint64(2), pv.Status().Peers[0].Epoch)
+}
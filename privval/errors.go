@@ -0,0 +1,69 @@
+package privval
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+// ErrorCodeHeader is the header the HTTP server sets on error responses to the code
+// of the error, if it carries one.
+const ErrorCodeHeader = "X-SignCTRL-Error-Code"
+
+// ErrConnLost is recorded when the connection to the validator was lost.
+var ErrConnLost = types.NewError(types.CodeConnLost, "lost connection to the validator")
+
+// ErrorStatus describes the last error the node ran into.
+type ErrorStatus struct {
+	Code    types.ErrorCode `json:"code"`
+	Message string          `json:"message"`
+	Time    time.Time       `json:"time"`
+}
+
+// recordError counts err by its code and keeps it as the last error for /status. It
+// returns the code, so that it can be logged along with the error.
+func (pv *SCFilePV) recordError(err error) types.ErrorCode {
+	code := types.ErrorCodeOf(err)
+	pv.countError(code)
+
+	pv.lastErrorMtx.Lock()
+	defer pv.lastErrorMtx.Unlock()
+	pv.lastError = &ErrorStatus{Code: code, Message: err.Error(), Time: pv.Clock.Now()}
+
+	return code
+}
+
+// LastError returns the last error the node ran into, or nil if there was none yet.
+func (pv *SCFilePV) LastError() *ErrorStatus {
+	pv.lastErrorMtx.RLock()
+	defer pv.lastErrorMtx.RUnlock()
+
+	if pv.lastError == nil {
+		return nil
+	}
+	status := *pv.lastError
+	return &status
+}
+
+// httpError replies to the request with err and the given HTTP status code, and sets
+// the ErrorCodeHeader to err's code if it carries one.
+func httpError(rw http.ResponseWriter, err error, status int) {
+	if code := types.ErrorCodeOf(err); code != types.CodeUnknown {
+		rw.Header().Set(ErrorCodeHeader, string(code))
+	}
+	http.Error(rw, err.Error(), status)
+}
+
+// responseError returns the error of the given error response of the HTTP server with
+// the given body. It carries the code of the ErrorCodeHeader, if set.
+func responseError(resp *http.Response, body []byte) error {
+	err := fmt.Errorf("%v: %v", resp.Status, strings.TrimSpace(string(body)))
+	if code := resp.Header.Get(ErrorCodeHeader); code != "" {
+		return &types.Error{Code: types.ErrorCode(code), Err: err}
+	}
+
+	return err
+}
@@ -0,0 +1,72 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+func TestRequestError_ErrorCode(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.SetRank(2)
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		return testBlockResult(t).Result, nil
+	}
+	_, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	require.ErrorIs(t, err, ErrNoSigningPermission)
+	assert.Equal(t, types.CodeNoPermission, types.ErrorCodeOf(err))
+
+	// Without a sentinel error, the code is the cause's one.
+	reqData := sharedSignRequestData{height: 2}
+	err = reqData.requestError(pv, nil, ErrNoQuorum)
+	assert.Equal(t, types.CodeUnverified, types.ErrorCodeOf(err))
+	err = reqData.requestError(pv, ErrFenced, errors.New("test"))
+	assert.Equal(t, types.CodeRankConflict, types.ErrorCodeOf(err))
+}
+
+func TestRecordError(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.Clock = types.NewFakeClock(time.Unix(1000, 0))
+	pv.Gauges.ErrorsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "errors"}, []string{"code"})
+	assert.Nil(t, pv.Status().LastError)
+
+	pv.connLost("closed by validator")
+	assert.Equal(t, types.CodeThreshold, pv.recordError(&types.RankError{Err: types.ErrThresholdExceeded}))
+	pv.connLost("reader stalled")
+	assert.Equal(t, 2.0, prom_testutil.ToFloat64(pv.Gauges.ErrorsCounter.WithLabelValues("ERR_CONN_LOST")))
+	assert.Equal(t, 1.0, prom_testutil.ToFloat64(pv.Gauges.ErrorsCounter.WithLabelValues("ERR_THRESHOLD")))
+
+	// The last error is reported in /status.
+	assert.Equal(t, &ErrorStatus{
+		Code:    types.CodeConnLost,
+		Message: "lost connection to the validator (reader stalled)",
+		Time:    time.Unix(1000, 0),
+	}, pv.Status().LastError)
+}
+
+func TestHTTPError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	httpError(rec, ErrUnauthorized, http.StatusUnauthorized)
+	assert.Equal(t, "ERR_UNAUTHORIZED", rec.Header().Get(ErrorCodeHeader))
+
+	// The client gets the code back from the response.
+	err := responseError(rec.Result(), rec.Body.Bytes())
+	assert.Equal(t, types.CodeUnauthorized, types.ErrorCodeOf(err))
+	assert.Equal(t, "401 Unauthorized: unauthorized", err.Error())
+
+	// Errors without a code don't set the header.
+	rec = httptest.NewRecorder()
+	httpError(rec, errors.New("test"), http.StatusBadRequest)
+	assert.Empty(t, rec.Header().Get(ErrorCodeHeader))
+	assert.Equal(t, types.CodeUnknown, types.ErrorCodeOf(responseError(rec.Result(), rec.Body.Bytes())))
+}
@@ -0,0 +1,118 @@
+//go:build go1.18
+// +build go1.18
+
+package privval
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+// fuzzSeeds returns the marshaled messages the fuzz targets' corpora are seeded with.
+func fuzzSeeds(f *testing.F) [][]byte {
+	f.Helper()
+
+	var seeds [][]byte
+	for _, msg := range []*tm_privvalproto.Message{
+		testPingRequest(f),
+		testPubKeyRequest(f),
+		testSignVoteRequest(f),
+		testSignProposalRequest(f),
+		wrapMsg(&tm_privvalproto.SignVoteRequest{ChainId: "testchain"}),
+		wrapMsg(&tm_privvalproto.SignProposalRequest{ChainId: "testchain"}),
+		{},
+	} {
+		bz, err := proto.Marshal(msg)
+		if err != nil {
+			f.Fatal(err)
+		}
+		seeds = append(seeds, bz)
+	}
+
+	return seeds
+}
+
+// FuzzReadMsg feeds arbitrary frames into the delimited reader SignCTRL reads the
+// validator's messages with. Reading must never panic, never consume more than the
+// given bytes and never accept a message larger than maxRemoteSignerMsgSize.
+func FuzzReadMsg(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		var buf bytes.Buffer
+		if _, err := tm_protoio.NewDelimitedWriter(&buf).WriteMsg(&tm_privvalproto.Message{}); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(append(buf.Bytes(), seed...))
+
+		// Add the seed as a correctly delimited frame, as a truncated frame and as a
+		// frame announcing an oversized message.
+		frame := append(proto.EncodeVarint(uint64(len(seed))), seed...)
+		f.Add(frame)
+		f.Add(frame[:len(frame)/2])
+		f.Add(append(proto.EncodeVarint(maxRemoteSignerMsgSize+1), seed...))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := tm_protoio.NewDelimitedReader(bytes.NewReader(data), maxRemoteSignerMsgSize)
+		total := 0
+		for {
+			var msg tm_privvalproto.Message
+			n, err := r.ReadMsg(&msg)
+			total += n
+			if n > maxRemoteSignerMsgSize+binary.MaxVarintLen64 {
+				t.Fatalf("read %v bytes for a single message", n)
+			}
+			if total > len(data) {
+				t.Fatalf("read %v bytes from %v bytes of input", total, len(data))
+			}
+			if err != nil {
+				return
+			}
+		}
+	})
+}
+
+// FuzzHandleRequest feeds arbitrary messages into HandleRequest. Handling a request
+// must never panic and must always produce a response that can be sent back to the
+// validator.
+func FuzzHandleRequest(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+
+	priv := tm_ed25519.GenPrivKey()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var msg tm_privvalproto.Message
+		if err := proto.Unmarshal(data, &msg); err != nil {
+			return
+		}
+
+		dir := t.TempDir()
+		pv := mockSCFilePV(t)
+		pv.TMFilePV = tm_privval.NewFilePV(priv, filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile))
+		pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+			return &tm_coretypes.ResultBlock{
+				Block: &tm_types.Block{LastCommit: &tm_types.Commit{Height: height}},
+			}, nil
+		}
+
+		resp, _ := HandleRequest(context.Background(), &msg, pv)
+		if resp == nil {
+			t.Fatalf("no response for %v", msg.String())
+		}
+		if _, err := tm_protoio.NewDelimitedWriter(ioutil.Discard).WriteMsg(resp); err != nil {
+			t.Fatalf("couldn't write response: %v", err)
+		}
+	})
+}
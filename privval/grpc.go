@@ -0,0 +1,398 @@
+package privval
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	"github.com/gogo/protobuf/proto"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	tm_cryptoenc "github.com/tendermint/tendermint/crypto/encoding"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_types "github.com/tendermint/tendermint/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// grpcServiceName is the name of the gRPC service Tendermint v0.35+ validators
+	// send their requests to.
+	grpcServiceName = "tendermint.privval.PrivValidatorAPI"
+
+	// grpcRequestTimeout is the timeout of a single request to a remote gRPC signer.
+	grpcRequestTimeout = 5 * time.Second
+)
+
+// gogoCodec marshals the privval messages with gogo/protobuf, as they use gogoproto
+// extensions like stdtime that the default codec doesn't support. It replaces the
+// default "proto" codec on SignCTRL's gRPC connections only.
+type gogoCodec struct{}
+
+// Marshal implements the Codec interface.
+func (gogoCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("can't marshal %T", v)
+	}
+	return proto.Marshal(msg)
+}
+
+// Unmarshal implements the Codec interface.
+func (gogoCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("can't unmarshal into %T", v)
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// Name implements the Codec interface.
+func (gogoCodec) Name() string { return "proto" }
+
+// String implements the deprecated grpc.Codec interface.
+func (gogoCodec) String() string { return "proto" }
+
+// privValidatorAPIServer is the server API of Tendermint's PrivValidatorAPI service.
+type privValidatorAPIServer interface {
+	GetPubKey(context.Context, *tm_privvalproto.PubKeyRequest) (*tm_privvalproto.PubKeyResponse, error)
+	SignVote(context.Context, *tm_privvalproto.SignVoteRequest) (*tm_privvalproto.SignedVoteResponse, error)
+	SignProposal(context.Context, *tm_privvalproto.SignProposalRequest) (*tm_privvalproto.SignedProposalResponse, error)
+}
+
+// grpcUnaryHandler returns the description of a unary method of the PrivValidatorAPI
+// service, which decodes the request into the message returned by newReq and passes
+// it on to f.
+func grpcUnaryHandler(method string, newReq func() interface{}, f func(srv privValidatorAPIServer, ctx context.Context, req interface{}) (interface{}, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newReq()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return f(srv.(privValidatorAPIServer), ctx, req)
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: fmt.Sprintf("/%v/%v", grpcServiceName, method)}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+// privValidatorAPIDesc describes Tendermint's PrivValidatorAPI service, as defined in
+// tendermint/privval/service.proto of Tendermint v0.35.
+var privValidatorAPIDesc = grpc.ServiceDesc{
+	ServiceName: grpcServiceName,
+	HandlerType: (*privValidatorAPIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		grpcUnaryHandler("GetPubKey", func() interface{} { return new(tm_privvalproto.PubKeyRequest) },
+			func(srv privValidatorAPIServer, ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.GetPubKey(ctx, req.(*tm_privvalproto.PubKeyRequest))
+			}),
+		grpcUnaryHandler("SignVote", func() interface{} { return new(tm_privvalproto.SignVoteRequest) },
+			func(srv privValidatorAPIServer, ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.SignVote(ctx, req.(*tm_privvalproto.SignVoteRequest))
+			}),
+		grpcUnaryHandler("SignProposal", func() interface{} { return new(tm_privvalproto.SignProposalRequest) },
+			func(srv privValidatorAPIServer, ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.SignProposal(ctx, req.(*tm_privvalproto.SignProposalRequest))
+			}),
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "tendermint/privval/service.proto",
+}
+
+// grpcServer serves the PrivValidatorAPI service by passing the requests on to the
+// same handlers as the requests received via the socket transport.
+type grpcServer struct {
+	pv *SCFilePV
+}
+
+// grpcServer must implement the privValidatorAPIServer interface.
+var _ privValidatorAPIServer = new(grpcServer)
+
+// handle handles the given request and stops the node if it's forced to shut down.
+func (s *grpcServer) handle(ctx context.Context, req proto.Message) (*tm_privvalproto.Message, error) {
+	if !s.pv.IsRunning() {
+		return nil, status.Error(codes.Unavailable, "SignCTRL is not running")
+	}
+
+	resp, err := HandleRequest(ctx, wrapMsg(req), s.pv)
+	if err != nil {
+		s.pv.Logger.Error("couldn't handle request [%v]: %v\n", s.pv.recordError(err), err)
+		if errors.Is(err, types.ErrMustShutdown) || errors.Is(err, ErrRankObsolete) {
+			s.pv.emit(watchtower.EventMustShutdown, "Shutting down: %v", err)
+			if err := s.pv.Stop(); err != nil {
+				s.pv.Logger.Error("%v", err)
+			}
+		}
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return resp, nil
+}
+
+// GetPubKey returns the validator's public key.
+// Implements the privValidatorAPIServer interface.
+func (s *grpcServer) GetPubKey(ctx context.Context, req *tm_privvalproto.PubKeyRequest) (*tm_privvalproto.PubKeyResponse, error) {
+	resp, err := s.handle(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetPubKeyResponse(), nil
+}
+
+// SignVote signs the requested vote.
+// Implements the privValidatorAPIServer interface.
+func (s *grpcServer) SignVote(ctx context.Context, req *tm_privvalproto.SignVoteRequest) (*tm_privvalproto.SignedVoteResponse, error) {
+	resp, err := s.handle(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetSignedVoteResponse(), nil
+}
+
+// SignProposal signs the requested proposal.
+// Implements the privValidatorAPIServer interface.
+func (s *grpcServer) SignProposal(ctx context.Context, req *tm_privvalproto.SignProposalRequest) (*tm_privvalproto.SignedProposalResponse, error) {
+	resp, err := s.handle(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetSignedProposalResponse(), nil
+}
+
+// grpcAddress cuts the protocol from the given TCP socket address.
+func grpcAddress(laddr string) string {
+	return regexp.MustCompile(`^(tcp|grpc)://`).ReplaceAllString(laddr, "")
+}
+
+// grpcServerCredentials returns the TLS credentials of the gRPC server as configured
+// in the [privval] section, or nil if TLS is disabled.
+func (pv *SCFilePV) grpcServerCredentials() (credentials.TransportCredentials, error) {
+	cfg := pv.Config.Privval
+	if cfg.GRPCCertFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.GRPCCertFile, cfg.GRPCKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.GRPCClientCAFile != "" {
+		pool, err := loadCertPool(cfg.GRPCClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return credentials.NewTLS(tlsCfg), nil
+}
+
+// loadCertPool loads the PEM-encoded certificates in the given file into a new pool.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bytes) {
+		return nil, fmt.Errorf("no certificates found in %v", path)
+	}
+
+	return pool, nil
+}
+
+// serveGRPC starts the gRPC server the validator sends its requests to at the
+// configured grpc_laddr. The server is stopped once the node is stopped or ctx is
+// done.
+func (pv *SCFilePV) serveGRPC(ctx context.Context) error {
+	creds, err := pv.grpcServerCredentials()
+	if err != nil {
+		return fmt.Errorf("couldn't load gRPC TLS credentials: %w", err)
+	}
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{Time: pv.Config.Base.GetReadDeadline() / 2}),
+		grpc.CustomCodec(gogoCodec{}), //nolint:staticcheck // ForceServerCodec requires grpc v1.38
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					pv.crash("grpc", r, debug.Stack())
+					resp, err = nil, status.Error(codes.Internal, "SignCTRL crashed")
+				}
+			}()
+			return handler(ctx, req)
+		}),
+	}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	} else {
+		pv.Logger.Warn("The gRPC server doesn't use TLS, make sure grpc_laddr is only reachable by the validator")
+	}
+
+	listener, err := net.Listen("tcp", grpcAddress(pv.Config.Privval.GRPCListenAddress))
+	if err != nil {
+		return err
+	}
+	// The validator doesn't ping, so the server pings it within the read deadline and
+	// closes connections that don't receive anything for longer, e.g. because the
+	// validator's host died. The validator then reconnects on its own.
+	readDeadline := pv.Config.Base.GetReadDeadline()
+	listener = &deadlineListener{Listener: listener, d: readDeadline, onTimeout: func() {
+		pv.connLost(fmt.Sprintf("no data for %v", readDeadline))
+	}}
+	server := grpc.NewServer(opts...)
+	server.RegisterService(&privValidatorAPIDesc, &grpcServer{pv: pv})
+
+	pv.Logger.Info("Serving gRPC requests from the validator at %v", listener.Addr())
+	goroutines.Go("grpc", func() {
+		if err := server.Serve(listener); err != nil {
+			pv.Logger.Error("gRPC server stopped: %v\n", err)
+		}
+	})
+	goroutines.Go("grpc_watch", func() {
+		select {
+		case <-pv.Quit():
+		case <-ctx.Done():
+		}
+		server.Stop()
+	})
+
+	return nil
+}
+
+// GRPCSigner is a private validator that has the requests signed by a remote signer
+// serving the PrivValidatorAPI of Tendermint v0.35+, like a SignCTRL node using the
+// grpc transport.
+type GRPCSigner struct {
+	conn    *grpc.ClientConn
+	chainID string
+}
+
+// GRPCSigner must implement the PrivValidator interface.
+var _ tm_types.PrivValidator = new(GRPCSigner)
+
+// NewGRPCSigner creates a private validator that connects to the remote signer at the
+// given address, which signs for the given chain. TLS is used if creds is not nil.
+func NewGRPCSigner(addr, chainID string, creds credentials.TransportCredentials) (*GRPCSigner, error) {
+	opts := []grpc.DialOption{grpc.WithDefaultCallOptions(grpc.ForceCodec(gogoCodec{}))}
+	if creds != nil {
+		opts = append(opts, grpc.WithTransportCredentials(creds))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	conn, err := grpc.Dial(grpcAddress(addr), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GRPCSigner{conn: conn, chainID: chainID}, nil
+}
+
+// Close closes the connection to the remote signer.
+func (s *GRPCSigner) Close() error {
+	return s.conn.Close()
+}
+
+// invoke calls the given method of the remote signer.
+func (s *GRPCSigner) invoke(method string, req, resp interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), grpcRequestTimeout)
+	defer cancel()
+
+	return s.conn.Invoke(ctx, fmt.Sprintf("/%v/%v", grpcServiceName, method), req, resp)
+}
+
+// GetPubKey returns the remote signer's public key.
+// Implements the PrivValidator interface.
+func (s *GRPCSigner) GetPubKey() (tm_crypto.PubKey, error) {
+	var resp tm_privvalproto.PubKeyResponse
+	if err := s.invoke("GetPubKey", &tm_privvalproto.PubKeyRequest{ChainId: s.chainID}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, types.Errorf(types.CodeSigningFailed, "remote signer error: %v", resp.Error.Description)
+	}
+
+	return tm_cryptoenc.PubKeyFromProto(resp.PubKey)
+}
+
+// SignVote has the remote signer sign the given vote.
+// Implements the PrivValidator interface.
+func (s *GRPCSigner) SignVote(chainID string, vote *tm_typesproto.Vote) error {
+	var resp tm_privvalproto.SignedVoteResponse
+	if err := s.invoke("SignVote", &tm_privvalproto.SignVoteRequest{Vote: vote, ChainId: chainID}, &resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return types.Errorf(types.CodeSigningFailed, "remote signer error: %v", resp.Error.Description)
+	}
+	*vote = resp.Vote
+
+	return nil
+}
+
+// SignProposal has the remote signer sign the given proposal.
+// Implements the PrivValidator interface.
+func (s *GRPCSigner) SignProposal(chainID string, proposal *tm_typesproto.Proposal) error {
+	var resp tm_privvalproto.SignedProposalResponse
+	if err := s.invoke("SignProposal", &tm_privvalproto.SignProposalRequest{Proposal: proposal, ChainId: chainID}, &resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return types.Errorf(types.CodeSigningFailed, "remote signer error: %v", resp.Error.Description)
+	}
+	*proposal = resp.Proposal
+
+	return nil
+}
+
+// grpcSignerBackend connects to the remote gRPC signer at the addr parameter, which
+// signs for the chain in the chain_id parameter. TLS is used if the ca_file parameter
+// is set, and the client certificate in the cert_file and key_file parameters is
+// presented if they are set, too.
+func grpcSignerBackend(params map[string]string) (tm_types.PrivValidator, error) {
+	if params["addr"] == "" || params["chain_id"] == "" {
+		return nil, errors.New("addr and chain_id must not be empty")
+	}
+	if params["ca_file"] == "" {
+		return NewGRPCSigner(params["addr"], params["chain_id"], nil)
+	}
+
+	pool, err := loadCertPool(params["ca_file"])
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	if params["cert_file"] != "" {
+		cert, err := tls.LoadX509KeyPair(params["cert_file"], params["key_file"])
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return NewGRPCSigner(params["addr"], params["chain_id"], credentials.NewTLS(tlsCfg))
+}
@@ -0,0 +1,76 @@
+package privval
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+func TestGRPCAddress(t *testing.T) {
+	assert.Equal(t, "127.0.0.1:3001", grpcAddress("tcp://127.0.0.1:3001"))
+	assert.Equal(t, "127.0.0.1:3001", grpcAddress("grpc://127.0.0.1:3001"))
+	assert.Equal(t, "127.0.0.1:3001", grpcAddress("127.0.0.1:3001"))
+}
+
+func TestGRPCSignerBackend_InvalidParams(t *testing.T) {
+	_, err := NewSigner("grpc", map[string]string{})
+	assert.Error(t, err)
+
+	_, err = NewSigner("grpc", map[string]string{"addr": "tcp://127.0.0.1:3001"})
+	assert.Error(t, err)
+
+	_, err = NewSigner("grpc", map[string]string{"addr": "tcp://127.0.0.1:3001", "chain_id": "testchain", "ca_file": "/does/not/exist"})
+	assert.Error(t, err)
+}
+
+func TestGRPCServer(t *testing.T) {
+	port, err := getFreePort(t)
+	assert.NoError(t, err)
+	laddr := fmt.Sprintf("tcp://127.0.0.1:%v", port)
+
+	dir := t.TempDir()
+	filePV := tm_privval.GenFilePV(filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile))
+	filePV.Save()
+
+	pv := mockSCFilePV(t)
+	pv.HTTP = nil
+	pv.CfgDir = dir
+	pv.TMFilePV = filePV
+	pv.Config.Privval.Transport = "grpc"
+	pv.Config.Privval.GRPCListenAddress = laddr
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		return testBlockResult(t).Result, nil
+	}
+	assert.NoError(t, pv.Start())
+	defer func() { _ = pv.Stop() }()
+	assert.Eventually(t, func() bool { return pv.GetConnState() == ConnConnected }, 5*time.Second, time.Millisecond)
+
+	// Connect to SignCTRL's gRPC server like a Tendermint v0.35+ validator.
+	signer, err := NewSigner("grpc", map[string]string{"addr": laddr, "chain_id": "testchain"})
+	assert.NoError(t, err)
+	defer signer.(*GRPCSigner).Close()
+
+	pub, err := signer.GetPubKey()
+	assert.NoError(t, err)
+	assert.Equal(t, filePV.Key.PubKey, pub)
+
+	vote := testVote(t)
+	assert.NoError(t, signer.SignVote("testchain", vote))
+	assert.NotEmpty(t, vote.Signature)
+
+	// Errors are passed on to the validator.
+	vote = testVote(t)
+	vote.Height++
+	assert.Error(t, signer.SignVote("otherchain", vote))
+
+	pv.SetRank(2)
+	err = signer.SignProposal("testchain", testProposal(t))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), ErrNoSigningPermission.Error())
+}
@@ -0,0 +1,153 @@
+package privval
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/statefile"
+	"github.com/BlockscapeNetwork/signctrl/statemac"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+// HighWatermarkFile is the file the high watermark of the signatures is persisted in,
+// relative to the configuration directory.
+const HighWatermarkFile = "signctrl_watermark.json"
+
+// ErrBelowWatermark is returned if a sign request is at or below the high watermark of
+// the signatures, unless it is the request at the watermark again, apart from its
+// timestamp.
+var ErrBelowWatermark = types.NewError(types.CodeDoubleSign, "sign request is at or below the high watermark")
+
+// WatermarkRaiser raises the high watermark of the signatures to w before the vote or
+// proposal with the given sign bytes, without its timestamp, is signed. It returns an
+// error if it must not be signed.
+type WatermarkRaiser func(w Watermark, signBytes []byte) error
+
+// voteSignBytes returns the sign bytes of vote without its timestamp, as the signer
+// backends sign a vote that only differs from the last one in its timestamp with the
+// last one's timestamp again.
+func voteSignBytes(chainID string, vote *tm_typesproto.Vote) []byte {
+	v := *vote
+	v.Timestamp = time.Time{}
+
+	return tm_types.VoteSignBytes(chainID, &v)
+}
+
+// proposalSignBytes returns the sign bytes of proposal without its timestamp.
+func proposalSignBytes(chainID string, proposal *tm_typesproto.Proposal) []byte {
+	p := *proposal
+	p.Timestamp = time.Time{}
+
+	return tm_types.ProposalSignBytes(chainID, &p)
+}
+
+// highWatermark defines the contents of the high watermark file.
+type highWatermark struct {
+	Height        int64  `json:"height"`
+	Round         int32  `json:"round"`
+	Step          int8   `json:"step"`
+	SignBytesHash string `json:"sign_bytes_hash"`
+}
+
+// watermark returns the height, round and step of hwm.
+func (hwm highWatermark) watermark() Watermark {
+	return Watermark{Height: hwm.Height, Round: hwm.Round, Step: hwm.Step}
+}
+
+// highWatermarkStore keeps the high watermark of the signatures in a file of its own,
+// independently of the priv_validator_state.json of the signer backend. The watermark
+// is raised and synced to disk before anything is signed, so that a request replayed
+// over a re-dialed connection, e.g. after a crash, is never signed again.
+type highWatermarkStore struct {
+	path   string
+	logger types.Logger
+
+	mtx    sync.Mutex // guards the fields below
+	loaded bool
+	hwm    highWatermark
+}
+
+// newHighWatermarkStore creates a store for the high watermark in the given
+// configuration directory. The file is read on first use.
+func newHighWatermarkStore(cfgDir string, logger types.Logger) *highWatermarkStore {
+	return &highWatermarkStore{path: filepath.Join(cfgDir, HighWatermarkFile), logger: logger}
+}
+
+// load reads the high watermark from the file, if it exists. A corrupted file is
+// recovered from its backup, which is never more than one raise behind. Otherwise, a
+// corrupted file is never taken for an empty watermark. The store must be locked.
+func (s *highWatermarkStore) load() error {
+	if s.loaded {
+		return nil
+	}
+	recovered, err := statefile.Load(s.path, func(data []byte) error {
+		s.hwm = highWatermark{}
+		return json.Unmarshal(data, &s.hwm)
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("couldn't read %v: %w", s.path, err)
+	}
+	if recovered {
+		s.logger.Error("%v was corrupted, recovered the high watermark %v from its backup, which may be one signature behind", s.path, s.hwm.watermark())
+	}
+	s.loaded = true
+
+	return nil
+}
+
+// save persists hwm, see the statefile package, and updates the file's MAC if it is
+// protected.
+func (s *highWatermarkStore) save(hwm highWatermark) error {
+	bz, err := json.MarshalIndent(hwm, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return statemac.Write(filepath.Dir(s.path), s.path, bz, PermSecretFile)
+}
+
+// Raise raises the high watermark to w before the given sign bytes are signed. It
+// returns ErrBelowWatermark if w is at or below the high watermark, unless signBytes
+// are the same ones as at the watermark, in which case the signer backend is expected
+// to return the same signature again.
+func (s *highWatermarkStore) Raise(w Watermark, signBytes []byte) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(signBytes)
+	hash := hex.EncodeToString(sum[:])
+	cur := s.hwm.watermark()
+	if !w.After(cur) {
+		if w == cur && hash == s.hwm.SignBytesHash {
+			return nil
+		}
+		return fmt.Errorf("%w: requested %v with sign bytes %.16v, but already signed up to %v with sign bytes %.16v",
+			ErrBelowWatermark, w, hash, cur, s.hwm.SignBytesHash)
+	}
+
+	next := highWatermark{Height: w.Height, Round: w.Round, Step: w.Step, SignBytesHash: hash}
+	if err := s.save(next); err != nil {
+		return fmt.Errorf("couldn't persist the high watermark: %w", err)
+	}
+	s.hwm = next
+
+	return nil
+}
+
+// raiseWatermark is the default WatermarkRaiser of SCFilePV. It keeps the high
+// watermark in the configuration directory.
+func (pv *SCFilePV) raiseWatermark(w Watermark, signBytes []byte) error {
+	pv.hwmOnce.Do(func() { pv.hwm = newHighWatermarkStore(pv.CfgDir, pv.Logger) })
+	return pv.hwm.Raise(w, signBytes)
+}
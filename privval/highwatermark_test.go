@@ -0,0 +1,117 @@
+package privval
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/history"
+	"github.com/BlockscapeNetwork/signctrl/statefile"
+	"github.com/BlockscapeNetwork/signctrl/statemac"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_hash "github.com/tendermint/tendermint/crypto/tmhash"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+func TestHighWatermarkStore(t *testing.T) {
+	dir := t.TempDir()
+	s := newHighWatermarkStore(dir, types.NewSyncLogger(ioutil.Discard, "", 0))
+	w := Watermark{Height: 10, Round: 0, Step: stepPrevote}
+
+	require.NoError(t, s.Raise(w, []byte("vote")))
+
+	// The same request again.
+	assert.NoError(t, s.Raise(w, []byte("vote")))
+
+	// A different request at the watermark.
+	assert.ErrorIs(t, s.Raise(w, []byte("other vote")), ErrBelowWatermark)
+
+	// Below the watermark.
+	assert.ErrorIs(t, s.Raise(Watermark{Height: 9, Round: 5, Step: stepPrecommit}, []byte("vote")), ErrBelowWatermark)
+
+	// The watermark survives a restart.
+	restarted := newHighWatermarkStore(dir, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.ErrorIs(t, restarted.Raise(w, []byte("other vote")), ErrBelowWatermark)
+	assert.NoError(t, restarted.Raise(Watermark{Height: 10, Round: 0, Step: stepPrecommit}, []byte("precommit")))
+
+	// A corrupted file is recovered from its backup.
+	path := filepath.Join(dir, HighWatermarkFile)
+	require.NoError(t, ioutil.WriteFile(path, []byte("{"), 0600))
+	recovered := newHighWatermarkStore(dir, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.ErrorIs(t, recovered.Raise(w, []byte("other vote")), ErrBelowWatermark)
+
+	// Without a usable backup, a corrupted file is never taken for an empty watermark.
+	require.NoError(t, os.Remove(statefile.BackupPath(path)))
+	require.NoError(t, ioutil.WriteFile(path, []byte("{"), 0600))
+	assert.Error(t, newHighWatermarkStore(dir, types.NewSyncLogger(ioutil.Discard, "", 0)).Raise(Watermark{Height: 11}, []byte("vote")))
+}
+
+func TestHighWatermarkStore_MAC(t *testing.T) {
+	dir := t.TempDir()
+	key, _, err := statemac.LoadOrGenKey(dir)
+	require.NoError(t, err)
+	s := newHighWatermarkStore(dir, types.NewSyncLogger(ioutil.Discard, "", 0))
+	require.NoError(t, s.Raise(Watermark{Height: 10, Step: stepPrevote}, []byte("vote")))
+
+	// Raising the watermark keeps the file's MAC valid.
+	path := filepath.Join(dir, HighWatermarkFile)
+	assert.NoError(t, statemac.Verify(key, path))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, PermSecretFile, info.Mode().Perm())
+}
+
+func TestVoteSignBytes(t *testing.T) {
+	vote := testVote(t)
+	other := testVote(t)
+	other.Timestamp = vote.Timestamp.Add(time.Second)
+
+	// The timestamp doesn't matter.
+	assert.Equal(t, voteSignBytes("testchain", vote), voteSignBytes("testchain", other))
+	other.BlockID.Hash = tm_hash.Sum([]byte("OtherBlockIDHash"))
+	assert.NotEqual(t, voteSignBytes("testchain", vote), voteSignBytes("testchain", other))
+
+	proposal := testProposal(t)
+	otherProposal := testProposal(t)
+	otherProposal.Timestamp = proposal.Timestamp.Add(time.Second)
+	assert.Equal(t, proposalSignBytes("testchain", proposal), proposalSignBytes("testchain", otherProposal))
+}
+
+func TestHandleSignRequest_BelowWatermark(t *testing.T) {
+	dir := t.TempDir()
+	pv := mockSCFilePV(t)
+	pv.TMFilePV = tm_privval.GenFilePV(filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile))
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		return testBlockResult(t).Result, nil
+	}
+	pv.historyRecords = make(chan history.Record, 10)
+
+	_, err := handleSignRequest(context.Background(), testSignVoteRequest(t), pv)
+	require.NoError(t, err)
+
+	// The signer backend's state was lost, e.g. restored from an outdated backup, so
+	// only the high watermark prevents signing a conflicting vote.
+	pv.TMFilePV = tm_privval.GenFilePV(filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile))
+	req := testSignVoteRequest(t)
+	req.GetSignVoteRequest().Vote.BlockID.Hash = tm_hash.Sum([]byte("OtherBlockIDHash"))
+	resp, err := handleSignRequest(context.Background(), req, pv)
+	assert.ErrorIs(t, err, ErrBelowWatermark)
+	assert.NotNil(t, resp.GetSignedVoteResponse().GetError())
+	assert.Empty(t, req.GetSignVoteRequest().Vote.Signature)
+
+	// The refusal is kept in the history.
+	var refused []history.Record
+	for len(pv.historyRecords) > 0 {
+		if r := <-pv.historyRecords; r.Outcome == history.OutcomeRefused {
+			refused = append(refused, r)
+		}
+	}
+	require.Len(t, refused, 1)
+	assert.Equal(t, ErrBelowWatermark.Error(), refused[0].Reason)
+}
@@ -0,0 +1,172 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/BlockscapeNetwork/signctrl/history"
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+const (
+	// historyQueueSize is the number of signing outcomes buffered for the history
+	// goroutine. Outcomes are dropped if it falls behind that far.
+	historyQueueSize = 64
+)
+
+// openHistory opens the history database in the configuration directory and starts
+// writing the signing outcomes to it until ctx is done. The node runs without a
+// history if it can't be opened.
+func (pv *SCFilePV) openHistory(ctx context.Context) {
+	store, err := history.Open(pv.CfgDir, pv.Config.History.GetRetention())
+	if err != nil {
+		pv.Logger.Error("Couldn't open the history, not keeping one: %v", err)
+		return
+	}
+
+	records := make(chan history.Record, historyQueueSize)
+	pv.historyMtx.Lock()
+	pv.history, pv.historyRecords = store, records
+	pv.historyMtx.Unlock()
+
+	goroutines.Go("history", func() { pv.writeHistory(ctx, store, records) })
+}
+
+// writeHistory writes the given records to the store until ctx is done, and closes
+// the store afterwards.
+func (pv *SCFilePV) writeHistory(ctx context.Context, store *history.Store, records chan history.Record) {
+	defer pv.recoverPanic("history")
+	defer store.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r := <-records:
+			if err := store.Put(r); err != nil {
+				pv.Logger.Error("Couldn't record the outcome of height %v: %v", r.Height, err)
+			}
+		}
+	}
+}
+
+// recordOutcome queues the given signing outcome for the history, if one is kept.
+// The outcome is dropped rather than blocking the request if the queue is full.
+func (pv *SCFilePV) recordOutcome(r history.Record) {
+	pv.historyMtx.RLock()
+	records := pv.historyRecords
+	pv.historyMtx.RUnlock()
+	if records == nil {
+		return
+	}
+
+	if r.Rank == 0 {
+		r.Rank = pv.GetRank()
+	}
+	select {
+	case records <- r:
+	default:
+		pv.Logger.Warn("History queue is full, dropping the outcome of height %v", r.Height)
+	}
+}
+
+// recordCommit records the outcome of the given height as seen in its commit. reason
+// explains why a missed block wasn't counted towards the threshold.
+func (pv *SCFilePV) recordCommit(height int64, rb *tm_coretypes.ResultBlock, signed bool, reason string) {
+	r := history.Record{Height: height, Time: rb.Block.Time, Outcome: history.OutcomeMissed, Source: pv.rpcAddr(), Reason: reason}
+	if signed {
+		r.Outcome, r.Reason = history.OutcomeSigned, ""
+		r.SignedBy = "set"
+		if pv.GetRank() == 1 {
+			r.SignedBy = "self"
+		}
+	}
+	pv.recordOutcome(r)
+}
+
+// recordRefusal records the node's refusal to sign the given height, if err is one
+// of the reasons the node refuses to sign for although it has permission to.
+func (pv *SCFilePV) recordRefusal(reqData sharedSignRequestData, err error) {
+	for _, refusal := range []error{ErrCrashed, ErrTombstoned, ErrJailed, ErrSigningFailed, ErrBadSignature, ErrProposalRejected, ErrProposalNotApproved, ErrBelowWatermark} {
+		if errors.Is(err, refusal) {
+			pv.recordOutcome(history.Record{Height: reqData.height, Time: pv.Clock.Now(), Outcome: history.OutcomeRefused, Reason: refusal.Error()})
+			return
+		}
+	}
+}
+
+// HistoryReport summarizes the signing outcomes of the heights from from to to, both
+// inclusive. A to of 0 means up to the latest height.
+func (pv *SCFilePV) HistoryReport(from, to int64, records bool) (*history.Report, error) {
+	pv.historyMtx.RLock()
+	store := pv.history
+	pv.historyMtx.RUnlock()
+	if store == nil {
+		return nil, errors.New("no history is kept")
+	}
+
+	return store.Report(from, to, records)
+}
+
+// historyHandler serves the summary of the signing outcomes of the heights given by
+// the from and to query parameters, including the individual outcomes if records is
+// true.
+func (pv *SCFilePV) historyHandler(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !pv.checkAdmin(rw, r, false) {
+		return
+	}
+
+	var from, to int64
+	for param, v := range map[string]*int64{"from": &from, "to": &to} {
+		if s := r.URL.Query().Get(param); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || n < 0 {
+				http.Error(rw, fmt.Sprintf("invalid %v height: %v", param, s), http.StatusBadRequest)
+				return
+			}
+			*v = n
+		}
+	}
+	records, _ := strconv.ParseBool(r.URL.Query().Get("records"))
+
+	report, err := pv.HistoryReport(from, to, records)
+	if err != nil {
+		httpError(rw, err, http.StatusServiceUnavailable)
+		return
+	}
+	bytes, err := tm_json.Marshal(report)
+	if err != nil {
+		httpError(rw, err, http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(bytes)
+}
+
+// GetHistoryReport retrieves the summary of the signing outcomes of the heights from
+// from to to of the given instance, or the default validator if name is empty.
+func GetHistoryReport(name string, from, to int64, records bool, creds AdminCredentials) (*history.Report, error) {
+	query := url.Values{}
+	query.Set("from", strconv.FormatInt(from, 10))
+	query.Set("to", strconv.FormatInt(to, 10))
+	query.Set("records", strconv.FormatBool(records))
+	req, err := http.NewRequest(http.MethodGet, localURL(instancePath(name, "/admin/history")+"?"+query.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	var report history.Report
+	if err := doAdminRequestInto(req, creds, &report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
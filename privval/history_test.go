@@ -0,0 +1,128 @@
+package privval
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_db "github.com/tendermint/tm-db"
+)
+
+func TestHandleSignRequest_History(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.SetRank(2)
+	pv.UnlockCounter()
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		rb := testBlockResult(t).Result
+		rb.Block.Height = height
+		return rb, nil
+	}
+	pv.VerifyBlock = func(ctx context.Context, block *tm_coretypes.ResultBlock) error {
+		return ErrUnverifiedBlock
+	}
+	records := make(chan history.Record, 4)
+	pv.historyRecords = records
+
+	// The testBlockResult doesn't contain the validator's commitsig, and as it can't
+	// be verified, the miss isn't counted.
+	_, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.ErrorIs(t, err, ErrNoSigningPermission)
+	require.Len(t, records, 1)
+	r := <-records
+	// The previous block contains the commit of the height before it.
+	assert.Equal(t, testVote(t).Height-2, r.Height)
+	assert.Equal(t, history.OutcomeMissed, r.Outcome)
+	assert.Equal(t, "unverified", r.Reason)
+	assert.Equal(t, 2, r.Rank)
+	assert.Equal(t, pv.rpcAddr(), r.Source)
+
+	// A verified miss is counted.
+	pv.VerifyBlock = func(ctx context.Context, block *tm_coretypes.ResultBlock) error {
+		return nil
+	}
+	req := testSignVoteRequest(t)
+	req.GetSignVoteRequest().Vote.Height++
+	_, _ = HandleRequest(context.Background(), req, pv)
+	require.Len(t, records, 1)
+	r = <-records
+	assert.Equal(t, history.OutcomeMissed, r.Outcome)
+	assert.Empty(t, r.Reason)
+	assert.True(t, r.Counted())
+
+	// Refusing to sign is recorded for the requested height.
+	pv.SetRank(1)
+	atomic.StoreInt32(&pv.crashed, 1)
+	req.GetSignVoteRequest().Vote.Height++
+	_, err = HandleRequest(context.Background(), req, pv)
+	assert.ErrorIs(t, err, ErrCrashed)
+	require.Len(t, records, 2)
+	<-records
+	r = <-records
+	assert.Equal(t, req.GetSignVoteRequest().Vote.Height, r.Height)
+	assert.Equal(t, history.OutcomeRefused, r.Outcome)
+	assert.Equal(t, ErrCrashed.Error(), r.Reason)
+}
+
+func TestSCFilePV_OpenHistory(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.CfgDir = t.TempDir()
+	pv.Config.History.Retention = "1h"
+	ctx, cancel := context.WithCancel(context.Background())
+	pv.openHistory(ctx)
+	require.NotNil(t, pv.history)
+
+	pv.recordOutcome(history.Record{Height: 5, Time: time.Unix(0, 0), Outcome: history.OutcomeSigned})
+	assert.Eventually(t, func() bool {
+		report, err := pv.HistoryReport(0, 0, false)
+		return err == nil && report.Signed == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// The history is closed once the node is stopped.
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, err := pv.HistoryReport(0, 0, false)
+		return err == history.ErrClosed
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestHistoryHandler(t *testing.T) {
+	pv := mockSCFilePV(t)
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/admin/history"+query, nil)
+		req.RemoteAddr = "127.0.0.1:1234"
+		pv.historyHandler(rec, req)
+		return rec
+	}
+
+	// No history is kept.
+	assert.Equal(t, http.StatusServiceUnavailable, get("").Code)
+
+	pv.history = history.NewStore(tm_db.NewMemDB(), time.Hour)
+	for h := int64(1); h <= 4; h++ {
+		outcome := history.OutcomeSigned
+		if h == 3 {
+			outcome = history.OutcomeMissed
+		}
+		require.NoError(t, pv.history.Put(history.Record{Height: h, Outcome: outcome}))
+	}
+
+	rec := get("?from=2&to=3&records=true")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var report history.Report
+	require.NoError(t, tm_json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, 1, report.Signed)
+	assert.Equal(t, 1, report.Missed)
+	assert.Equal(t, 0.5, report.Uptime)
+	assert.Len(t, report.Records, 2)
+
+	assert.Equal(t, http.StatusBadRequest, get("?from=x").Code)
+}
@@ -1,11 +1,23 @@
 package privval
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/election"
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+	"github.com/BlockscapeNetwork/signctrl/p2p"
+	"github.com/BlockscapeNetwork/signctrl/resources"
+	"github.com/BlockscapeNetwork/signctrl/rpc"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	tm_json "github.com/tendermint/tendermint/libs/json"
 )
 
@@ -14,6 +26,46 @@ const (
 	DefaultHTTPPort = 8080
 )
 
+var (
+	// HTTPClientAddress is the host:port the clients of the HTTP server, e.g.
+	// GetStatus, send their requests to. It should be set to the result of
+	// ClientAddress for the configured listen address.
+	HTTPClientAddress = fmt.Sprintf("127.0.0.1:%v", DefaultHTTPPort)
+)
+
+// HTTPListenAddress returns the host:port the HTTP server listens on according to
+// cfg, which defaults to DefaultHTTPPort on all interfaces.
+func HTTPListenAddress(cfg config.Metrics) string {
+	if cfg.HTTPListenAddress == "" {
+		return fmt.Sprintf(":%v", DefaultHTTPPort)
+	}
+
+	return strings.TrimPrefix(cfg.HTTPListenAddress, "tcp://")
+}
+
+// ClientAddress returns the host:port clients reach a server listening on laddr at.
+// Unspecified hosts are replaced by the loopback address of the same IP version.
+func ClientAddress(laddr string) string {
+	host, port, err := net.SplitHostPort(laddr)
+	if err != nil {
+		return laddr
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case host == "", ip != nil && ip.Equal(net.IPv4zero):
+		host = "127.0.0.1"
+	case ip != nil && ip.Equal(net.IPv6unspecified):
+		host = "::1"
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// localURL returns the URL of the given path on the HTTP server.
+func localURL(path string) string {
+	return fmt.Sprintf("http://%v%v", HTTPClientAddress, path)
+}
+
 // StatusResponse defines the response JSON for status requests.
 type StatusResponse struct {
 	Height    int64 `json:"height"`
@@ -21,12 +73,106 @@ type StatusResponse struct {
 	SetSize   int   `json:"set_size"`
 	Counter   int   `json:"counter"`
 	Threshold int   `json:"threshold"`
+
+	// CounterLocked is true if missed blocks aren't counted until the first commitsig
+	// of the signer was found.
+	CounterLocked bool `json:"counter_locked"`
+
+	// LastSignedHeight and LastSignedRound are the height and round of the last vote
+	// or proposal the node signed. Both are 0 if it hasn't signed anything since it
+	// was started.
+	LastSignedHeight int64 `json:"last_signed_height"`
+	LastSignedRound  int32 `json:"last_signed_round"`
+
+	// Uptime is the time since the node was started.
+	Uptime time.Duration `json:"uptime"`
+
+	// MissedFor is the time without a block signed by rank 1 as of the current block.
+	// ThresholdDuration is the time that triggers a rank update, or 0 if only the
+	// threshold applies.
+	MissedFor         time.Duration `json:"missed_for"`
+	ThresholdDuration time.Duration `json:"threshold_duration,omitempty"`
+
+	// Connection is the state of the connection to the validator, either connecting
+	// or connected.
+	Connection ConnState `json:"connection"`
+
+	// Capabilities are the optional parts of the privval protocol used with the
+	// validator.
+	Capabilities Capabilities `json:"capabilities"`
+
+	// Features are the names of the enabled feature flags.
+	Features []string `json:"features"`
+
+	// Goroutines are the numbers of running goroutines per subsystem.
+	Goroutines map[string]int `json:"goroutines"`
+
+	// Resources are the CPU and memory limits of SignCTRL's container along with the
+	// current usage.
+	Resources resources.Resources `json:"resources"`
+
+	// Stalled are the goroutines the watchdog detected as stalled. The node is
+	// unhealthy until they make progress again.
+	Stalled []string `json:"stalled,omitempty"`
+
+	// Clock is the result of the last clock check. It is nil if the clock isn't
+	// checked.
+	Clock *ClockStatus `json:"clock,omitempty"`
+
+	// Epoch is the epoch the node last took the signing authority in, or 0 if it
+	// never did.
+	Epoch uint64 `json:"epoch"`
+
+	// Peers is the status of the other nodes in the set as of their heartbeats. It is
+	// empty if no heartbeats are exchanged.
+	Peers []p2p.PeerStatus `json:"peers,omitempty"`
+
+	// Election is the node's state in the set's leader election. It is nil if the
+	// coordination isn't raft.
+	Election *election.Status `json:"election,omitempty"`
+
+	// ValidatorStaleSince is the time the validator stopped advancing while the
+	// network kept going. It is nil if the validator isn't stale.
+	ValidatorStaleSince *time.Time `json:"validator_stale_since,omitempty"`
+
+	// Slashing is the validator's status in the slashing and staking modules. It is
+	// nil if the modules haven't been queried (yet).
+	Slashing *SlashingStatus `json:"slashing,omitempty"`
+
+	// SigningPaused is true if signing is paused because the validator is jailed.
+	SigningPaused bool `json:"signing_paused"`
+
+	// BlockTime is the chain's block time measured from the recently queried blocks.
+	// It is 0 if not enough blocks have been queried yet.
+	BlockTime time.Duration `json:"block_time"`
+
+	// UpgradeHeights are the heights of the known upgrades, around which missed blocks
+	// aren't counted.
+	UpgradeHeights []int64 `json:"upgrade_heights,omitempty"`
+
+	// MaintenanceUntil is the time the current maintenance window ends at, in which
+	// missed blocks aren't counted. It is nil if there's no maintenance window.
+	MaintenanceUntil *time.Time `json:"maintenance_until,omitempty"`
+
+	// RPCEndpoints are the results of the last health checks of the RPC endpoints. It
+	// is empty if there are no further endpoints.
+	RPCEndpoints []rpc.EndpointStatus `json:"rpc_endpoints,omitempty"`
+
+	// LastError is the last error the node ran into along with its code. It is nil if
+	// there was none since it was started.
+	LastError *ErrorStatus `json:"last_error,omitempty"`
 }
 
 // GetStatus retrieves the node's status in terms of current height, rank
 // and blocks missed in a row.
 func GetStatus() (*StatusResponse, error) {
-	resp, err := http.DefaultClient.Get(fmt.Sprintf("http://127.0.0.1:%v/status", DefaultHTTPPort))
+	return GetInstanceStatus("")
+}
+
+// GetInstanceStatus retrieves the status of the given instance, or the status of the
+// default validator if name is empty.
+func GetInstanceStatus(name string) (*StatusResponse, error) {
+	resp, err := http.DefaultClient.Get(localURL(instancePath(name, "/status")))
 	if err != nil {
 		return nil, err
 	}
@@ -36,6 +182,9 @@ func GetStatus() (*StatusResponse, error) {
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp, bytes)
+	}
 
 	var sr StatusResponse
 	if err := tm_json.Unmarshal(bytes, &sr); err != nil {
@@ -45,14 +194,145 @@ func GetStatus() (*StatusResponse, error) {
 	return &sr, nil
 }
 
+// Status returns the node's status in terms of current height, rank and blocks
+// missed in a row.
+func (pv *SCFilePV) Status() StatusResponse {
+	lastSigned := pv.LastSigned()
+	sr := StatusResponse{
+		Height:           pv.GetCurrentHeight(),
+		Rank:             pv.GetRank(),
+		SetSize:          pv.Config.Base.SetSize,
+		Counter:          pv.GetMissedInARow(),
+		Threshold:        pv.GetThreshold(),
+		CounterLocked:    pv.IsCounterLocked(),
+		LastSignedHeight: lastSigned.Height,
+		LastSignedRound:  lastSigned.Round,
+		MissedFor:        pv.GetMissedFor(),
+		Connection:       pv.GetConnState(),
+		Capabilities:     pv.GetCapabilities(),
+		Features:         pv.Features.List(),
+		Goroutines:       goroutines.Counts(),
+		Resources:        resources.Report(pv.Cgroup),
+	}
+	if !pv.startedAt.IsZero() {
+		sr.Uptime = pv.Clock.Now().Sub(pv.startedAt)
+	}
+	if status, ok := pv.GetSlashingStatus(); ok {
+		sr.Slashing = &status
+	}
+	if stalled := pv.StalledGoroutines(); len(stalled) > 0 {
+		sr.Stalled = stalled
+	}
+	if clock, ok := pv.GetClockStatus(); ok {
+		sr.Clock = &clock
+	}
+	sr.Epoch = pv.GetEpoch()
+	if peers := pv.Peers(); len(peers) > 0 {
+		sr.Peers = peers
+	}
+	if pv.election != nil {
+		status := pv.election.Status()
+		sr.Election = &status
+	}
+	if since, ok := pv.ValidatorStaleSince(); ok {
+		sr.ValidatorStaleSince = &since
+	}
+	sr.ThresholdDuration = pv.GetThresholdDuration()
+	sr.SigningPaused = pv.IsSigningPaused()
+	sr.UpgradeHeights = pv.UpgradeHeights()
+	if until, ok := pv.MaintenanceUntil(); ok {
+		sr.MaintenanceUntil = &until
+	}
+	if bt, ok := pv.blockTimes.blockTime(); ok {
+		sr.BlockTime = bt
+	}
+	if pv.RPCPool != nil {
+		sr.RPCEndpoints = pv.RPCPool.Endpoints()
+	}
+	sr.LastError = pv.LastError()
+
+	return sr
+}
+
+// SwapSignerRequest defines the request JSON for swapping the signer backend.
+type SwapSignerRequest struct {
+	Backend string            `json:"backend"`
+	Params  map[string]string `json:"params"`
+}
+
+// SwapSigner requests the node to swap its signer backend to the given one.
+func SwapSigner(req SwapSignerRequest, creds AdminCredentials) error {
+	return SwapInstanceSigner("", req, creds)
+}
+
+// SwapInstanceSigner requests the given instance, or the default validator if name is
+// empty, to swap its signer backend to the given one.
+func SwapInstanceSigner(name string, req SwapSignerRequest, creds AdminCredentials) error {
+	body, err := tm_json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, localURL(instancePath(name, "/admin/signer")), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	return doAdminRequest(httpReq, creds)
+}
+
+// isLoopback returns true if the request was sent from the loopback interface.
+func isLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// swapSignerHandler swaps the signer backend to the one in the request. Only requests
+// from the loopback interface are accepted, and swapping is a destructive admin
+// request, so it may require a TOTP code in addition to the token.
+func (pv *SCFilePV) swapSignerHandler(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !pv.checkAdmin(rw, r, true) {
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		httpError(rw, err, http.StatusBadRequest)
+		return
+	}
+	var req SwapSignerRequest
+	if err := tm_json.Unmarshal(body, &req); err != nil {
+		httpError(rw, err, http.StatusBadRequest)
+		return
+	}
+
+	next, err := NewSigner(req.Backend, req.Params)
+	if err != nil {
+		httpError(rw, err, http.StatusBadRequest)
+		return
+	}
+	if err := pv.SwapPrivValidator(r.Context(), next); err != nil {
+		pv.Logger.Error("couldn't swap the signer backend: %v\n", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrPubKeyMismatch) || errors.Is(err, ErrSignStateBehind) {
+			status = http.StatusConflict
+		}
+		httpError(rw, err, status)
+		return
+	}
+}
+
 func (pv *SCFilePV) statusHandler(rw http.ResponseWriter, r *http.Request) {
-	bytes, err := tm_json.Marshal(StatusResponse{
-		Height:    pv.GetCurrentHeight(),
-		Rank:      pv.GetRank(),
-		SetSize:   pv.Config.Base.SetSize,
-		Counter:   pv.GetMissedInARow(),
-		Threshold: pv.GetThreshold(),
-	})
+	bytes, err := tm_json.Marshal(pv.Status())
 	if err != nil {
 		_, _ = rw.Write(nil)
 		return
@@ -61,17 +341,50 @@ func (pv *SCFilePV) statusHandler(rw http.ResponseWriter, r *http.Request) {
 	_, _ = rw.Write(bytes)
 }
 
-// StartHTTPServer starts an HTTP server.
+// handler returns a new handler serving the /status, /healthz and /readyz endpoints,
+// the admin API under /admin and the integration API under /api/v1.
+func (pv *SCFilePV) handler() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", pv.statusHandler)
+	mux.HandleFunc("/healthz", probeHandler(pv.Healthy))
+	mux.HandleFunc("/readyz", probeHandler(pv.Ready))
+	mux.HandleFunc("/admin/signer", pv.swapSignerHandler)
+	mux.HandleFunc("/admin/maintenance", pv.maintenanceHandler)
+	mux.HandleFunc("/admin/proposals", pv.proposalsHandler)
+	mux.HandleFunc("/admin/history", pv.historyHandler)
+	mux.HandleFunc("/admin/reload", pv.reloadHandler)
+	mux.Handle(watchtower.PathPrefix+"/", watchtower.NewHandler(pv.WatchtowerStatus, pv.watchEvents))
+
+	return mux
+}
+
+// StartHTTPServer starts an HTTP server. If the server has no handler set, a new one
+// serving the prometheus metrics under /metrics, the /status endpoint, the admin API
+// under /admin and the integration API under /api/v1 is created. The same endpoints of the instances are served under
+// /instances/<name>, and the names of the instances under /instances.
 func (pv *SCFilePV) StartHTTPServer() error {
 	pv.Logger.Info("Starting HTTP server...")
 
-	var errCh chan error
-	go func() {
-		http.HandleFunc("/status", pv.statusHandler)
-		if err := pv.HTTP.ListenAndServe(); err != nil {
+	if pv.HTTP.Handler == nil {
+		mux := pv.handler()
+		mux.Handle("/metrics", promhttp.Handler())
+		if len(pv.Instances) > 0 {
+			mux.HandleFunc(instancesPath, pv.instancesHandler)
+			for name, instance := range pv.Instances {
+				prefix := instancePath(name, "")
+				mux.Handle(prefix+"/", http.StripPrefix(prefix, instance.handler()))
+			}
+		}
+		pv.HTTP.Handler = mux
+	}
+
+	errCh := make(chan error, 1)
+	goroutines.Go("http", func() {
+		defer pv.recoverPanic("http")
+		if err := pv.HTTP.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			errCh <- err
 		}
-	}()
+	})
 	select {
 	case <-time.After(100 * time.Millisecond):
 		return nil
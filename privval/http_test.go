@@ -2,10 +2,26 @@ package privval
 
 import (
 	"testing"
+	"time"
 
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
 	"github.com/stretchr/testify/assert"
 )
 
+func TestHTTPListenAddress(t *testing.T) {
+	assert.Equal(t, ":8080", HTTPListenAddress(config.Metrics{}))
+	assert.Equal(t, "[::]:9090", HTTPListenAddress(config.Metrics{HTTPListenAddress: "tcp://[::]:9090"}))
+}
+
+func TestClientAddress(t *testing.T) {
+	assert.Equal(t, "127.0.0.1:8080", ClientAddress(":8080"))
+	assert.Equal(t, "127.0.0.1:8080", ClientAddress("0.0.0.0:8080"))
+	assert.Equal(t, "[::1]:8080", ClientAddress("[::]:8080"))
+	assert.Equal(t, "[2001:db8::1]:8080", ClientAddress("[2001:db8::1]:8080"))
+	assert.Equal(t, "10.0.0.1:8080", ClientAddress("10.0.0.1:8080"))
+}
+
 func TestGetStatus(t *testing.T) {
 	pv := mockSCFilePV(t)
 	err := pv.StartHTTPServer()
@@ -15,3 +31,34 @@ func TestGetStatus(t *testing.T) {
 	assert.NotNil(t, sr)
 	assert.NoError(t, err)
 }
+
+func TestStatus_Goroutines(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.HTTP.Addr = "127.0.0.1:0"
+	err := pv.StartHTTPServer()
+	assert.NoError(t, err)
+	defer pv.HTTP.Close()
+
+	assert.GreaterOrEqual(t, pv.Status().Goroutines["http"], 1)
+}
+
+func TestStatus_LastSignedAndUptime(t *testing.T) {
+	pv := mockSCFilePV(t)
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	pv.Clock = clock
+
+	sr := pv.Status()
+	assert.Zero(t, sr.LastSignedHeight)
+	assert.Zero(t, sr.Uptime)
+
+	pv.startedAt = clock.Now()
+	pv.setLastSigned(Watermark{Height: 42, Round: 1, Step: stepPrecommit})
+	pv.LockCounter()
+	clock.Advance(time.Minute)
+
+	sr = pv.Status()
+	assert.Equal(t, int64(42), sr.LastSignedHeight)
+	assert.Equal(t, int32(1), sr.LastSignedRound)
+	assert.True(t, sr.CounterLocked)
+	assert.Equal(t, time.Minute, sr.Uptime)
+}
@@ -0,0 +1,169 @@
+package privval
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+const (
+	// InstancesDir is the name of the directory in the configuration directory that
+	// holds a subdirectory for each additional validator managed by the process.
+	InstancesDir = "instances"
+
+	// instancesPath is the path under which the endpoints of the instances are served
+	// by the HTTP server.
+	instancesPath = "/instances"
+)
+
+var (
+	// ErrConsumersInInstance is returned if an instance's configuration file contains
+	// consumer chains, which are only supported for the default validator.
+	ErrConsumersInInstance = types.NewError(types.CodeInvalidConfig, "consumer chains aren't supported for instances")
+
+	// instanceNameRegExp is the regular expression instance names must match.
+	instanceNameRegExp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
+
+// InstanceDir returns the absolute path to the directory that holds the
+// configuration file, the keys and the state of the given instance.
+func InstanceDir(cfgDir, name string) string {
+	return filepath.Join(cfgDir, InstancesDir, name)
+}
+
+// ValidInstanceName returns true if the given name can be used for an instance.
+func ValidInstanceName(name string) bool {
+	return instanceNameRegExp.MatchString(name)
+}
+
+// ListInstances returns the sorted names of the instances in the configuration
+// directory, i.e. the subdirectories of the instances directory that contain a
+// configuration file.
+func ListInstances(cfgDir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(cfgDir, InstancesDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(config.FilePath(InstanceDir(cfgDir, e.Name()))); os.IsNotExist(err) {
+			continue
+		}
+		if !ValidInstanceName(e.Name()) {
+			return nil, fmt.Errorf("invalid instance name %q", e.Name())
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// LoadInstanceFilePV loads the private validator of the given instance. Other than
+// the default validator's key, an instance's key is never generated, as instances
+// are usually set up for keys that already exist. The watermark is created if the
+// instance doesn't have one yet.
+func LoadInstanceFilePV(cfgDir, name string) (tm_types.PrivValidator, error) {
+	dir := InstanceDir(cfgDir, name)
+	keyFile, stateFile := KeyFilePath(dir), StateFilePath(dir)
+	if _, err := os.Stat(stateFile); os.IsNotExist(err) {
+		// tm_privval.LoadFilePVEmptyState exits the process on an invalid key file, so
+		// make sure it can be loaded beforehand.
+		var key tm_privval.FilePVKey
+		if err := unmarshalFile(keyFile, &key); err != nil {
+			return nil, err
+		}
+		filePV := tm_privval.LoadFilePVEmptyState(keyFile, stateFile)
+		filePV.LastSignState.Save()
+
+		return filePV, nil
+	}
+
+	return fileSignerBackend(map[string]string{"key_file": keyFile, "state_file": stateFile})
+}
+
+// NewInstanceSCFilePV creates a new instance of SCFilePV for the given instance. The
+// instance is fully isolated from the default validator: its configuration, keys,
+// watermark and state are all kept in the instance's directory, and it only shares
+// the HTTP server, on which its endpoints are served under /instances/<name>.
+func NewInstanceSCFilePV(logger types.Logger, cfgDir, name string) (*SCFilePV, error) {
+	if !ValidInstanceName(name) {
+		return nil, fmt.Errorf("invalid instance name %q", name)
+	}
+	dir := InstanceDir(cfgDir, name)
+	cfg, err := config.LoadFrom(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Consumers) > 0 {
+		return nil, ErrConsumersInInstance
+	}
+	if cfg.Privval.StateMAC {
+		if err := VerifyStateFiles(dir); err != nil {
+			return nil, err
+		}
+	}
+	tmpv, err := LoadInstanceFilePV(cfgDir, name)
+	if err != nil {
+		return nil, err
+	}
+	state, err := config.LoadOrGenState(dir)
+	if err != nil {
+		return nil, err
+	}
+	if state.Recovered {
+		logger.Warn("%v was corrupted, recovered the state from its backup", config.StateFilePath(dir))
+	}
+	if cfg.Privval.StateMAC {
+		if err := SignStateFiles(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	pv := NewSCFilePV(logger.With("instance", name), cfg, state, tmpv, nil)
+	pv.CfgDir = dir
+
+	return pv, nil
+}
+
+// instancePath returns the path of the given endpoint of an instance, or the path of
+// the default validator's endpoint if name is empty.
+func instancePath(name, endpoint string) string {
+	if name == "" {
+		return endpoint
+	}
+
+	return instancesPath + "/" + name + endpoint
+}
+
+// instancesHandler serves the names of the instances.
+func (pv *SCFilePV) instancesHandler(rw http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(pv.Instances))
+	for name := range pv.Instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bytes, err := tm_json.Marshal(names)
+	if err != nil {
+		httpError(rw, err, http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(bytes)
+}
@@ -0,0 +1,128 @@
+package privval
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+// createInstance creates the configuration file and the key of an instance.
+func createInstance(t *testing.T, cfgDir, name, chainID string) *tm_privval.FilePV {
+	t.Helper()
+	dir := InstanceDir(cfgDir, name)
+	require.NoError(t, os.MkdirAll(dir, config.PermConfigDir))
+	require.NoError(t, config.Create(dir))
+	require.NoError(t, config.Override(dir, map[string]interface{}{
+		"privval.chain_id": chainID,
+		"base.start_rank":  2,
+	}))
+	filePV := tm_privval.GenFilePV(KeyFilePath(dir), StateFilePath(dir))
+	filePV.Key.Save()
+
+	return filePV
+}
+
+func TestInstanceDir(t *testing.T) {
+	assert.Equal(t, "/tmp/instances/operator-a", InstanceDir("/tmp", "operator-a"))
+}
+
+func TestValidInstanceName(t *testing.T) {
+	assert.True(t, ValidInstanceName("operator-a"))
+	assert.True(t, ValidInstanceName("cosmoshub_4.1"))
+	assert.False(t, ValidInstanceName(""))
+	assert.False(t, ValidInstanceName(".."))
+	assert.False(t, ValidInstanceName("a/b"))
+}
+
+func TestListInstances(t *testing.T) {
+	cfgDir := t.TempDir()
+	names, err := ListInstances(cfgDir)
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+
+	createInstance(t, cfgDir, "operator-b", "testchain")
+	createInstance(t, cfgDir, "operator-a", "testchain")
+
+	// Directories without a configuration file are skipped.
+	require.NoError(t, os.MkdirAll(InstanceDir(cfgDir, "unused"), config.PermConfigDir))
+
+	names, err = ListInstances(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"operator-a", "operator-b"}, names)
+}
+
+func TestNewInstanceSCFilePV(t *testing.T) {
+	cfgDir := t.TempDir()
+	filePV := createInstance(t, cfgDir, "operator-a", "otherchain")
+
+	pv, err := NewInstanceSCFilePV(types.NewSyncLogger(ioutil.Discard, "", 0), cfgDir, "operator-a")
+	assert.NoError(t, err)
+	assert.Equal(t, InstanceDir(cfgDir, "operator-a"), pv.CfgDir)
+	assert.Equal(t, "otherchain", pv.Config.Privval.ChainID)
+	assert.Equal(t, 2, pv.Config.Base.StartRank)
+	assert.Nil(t, pv.HTTP)
+	assert.FileExists(t, config.StateFilePath(pv.CfgDir))
+	assert.FileExists(t, StateFilePath(pv.CfgDir))
+
+	pubKey, err := pv.TMFilePV.GetPubKey()
+	assert.NoError(t, err)
+	assert.Equal(t, filePV.Key.PubKey, pubKey)
+
+	// The key of an instance is never generated.
+	require.NoError(t, os.Remove(KeyFilePath(pv.CfgDir)))
+	_, err = NewInstanceSCFilePV(types.NewSyncLogger(ioutil.Discard, "", 0), cfgDir, "operator-a")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = NewInstanceSCFilePV(types.NewSyncLogger(ioutil.Discard, "", 0), cfgDir, "../operator-a")
+	assert.Error(t, err)
+}
+
+func TestStartHTTPServer_Instances(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.HTTP.Addr = "127.0.0.1:0"
+	instance := mockSCFilePV(t)
+	instance.SetRank(2)
+	pv.Instances = map[string]*SCFilePV{"operator-a": instance}
+	require.NoError(t, pv.StartHTTPServer())
+	defer pv.HTTP.Close()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		pv.HTTP.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/instances")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var names []string
+	assert.NoError(t, tm_json.Unmarshal(rec.Body.Bytes(), &names))
+	assert.Equal(t, []string{"operator-a"}, names)
+
+	// The endpoints of the instance are served under /instances/<name>.
+	rec = get("/instances/operator-a/status")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var sr StatusResponse
+	assert.NoError(t, tm_json.Unmarshal(rec.Body.Bytes(), &sr))
+	assert.Equal(t, 2, sr.Rank)
+
+	rec = get("/status")
+	assert.NoError(t, tm_json.Unmarshal(rec.Body.Bytes(), &sr))
+	assert.Equal(t, 1, sr.Rank)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, get("/instances/operator-a/admin/signer").Code)
+	assert.Equal(t, http.StatusNotFound, get("/instances/operator-b/status").Code)
+}
+
+func TestInstancePath(t *testing.T) {
+	assert.Equal(t, "/status", instancePath("", "/status"))
+	assert.Equal(t, "/instances/operator-a/status", instancePath("operator-a", "/status"))
+}
@@ -0,0 +1,214 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+const (
+	// kmsRequestTimeout is the timeout of a single request to AWS KMS.
+	kmsRequestTimeout = 5 * time.Second
+
+	// kmsSigningAlgorithm is the signing algorithm of KMS's Ed25519 keys that signs the
+	// message itself rather than a hash of it, like Tendermint does.
+	kmsSigningAlgorithm = "ED25519_SHA_512"
+)
+
+// KMSSigner is a private validator signing with an Ed25519 key held in AWS KMS, so
+// that the validator's key never touches the disk. The public key is fetched once and
+// cached for GetPubKey. Like Tendermint's FilePV, it returns the last signature again
+// for a vote or proposal that only differs from the last one in its timestamp.
+type KMSSigner struct {
+	// KeyID is the ID or ARN of the key.
+	KeyID string
+
+	client *kms.KMS
+
+	mtx    sync.Mutex
+	pubKey tm_crypto.PubKey
+	last   kmsSignature
+}
+
+// kmsSignature is the last signature of a KMSSigner.
+type kmsSignature struct {
+	// signBytes are the sign bytes without the timestamp.
+	signBytes []byte
+	timestamp time.Time
+	signature []byte
+}
+
+// KMSSigner must implement the PrivValidator and the contextSigner interface.
+var (
+	_ tm_types.PrivValidator = new(KMSSigner)
+	_ contextSigner          = new(KMSSigner)
+)
+
+// NewKMSSigner creates a private validator signing with the KMS key with the given ID
+// or ARN in the given region, using the KMS API at the given endpoint. If creds is nil,
+// the AWS SDK's default credential chain is used, i.e. the environment, the shared
+// credentials file, the ECS container credentials and the EC2 instance metadata. The
+// key's public key is fetched right away, so that a wrong key or missing permissions
+// are noticed before the first signature.
+func NewKMSSigner(endpoint, region, keyID string, creds *credentials.Credentials) (*KMSSigner, error) {
+	cfg := aws.NewConfig().
+		WithEndpoint(endpoint).
+		WithRegion(region).
+		WithHTTPClient(&http.Client{Timeout: kmsRequestTimeout})
+	if creds != nil {
+		cfg = cfg.WithCredentials(creds)
+	}
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &KMSSigner{KeyID: keyID, client: kms.New(sess)}
+	if _, err := s.GetPubKey(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NewKMSSignerFor creates a private validator signing with the KMS key of the given
+// [kms] section. The requests are signed with the credentials in its credentials_file
+// if it is set, or the ones of the AWS SDK's default credential chain otherwise.
+func NewKMSSignerFor(cfg config.KMS) (*KMSSigner, error) {
+	var creds *credentials.Credentials
+	if cfg.CredentialsFile != "" {
+		accessKeyID, secretAccessKey, err := config.LoadCredentials(cfg.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewStaticCredentials(accessKeyID, secretAccessKey, "")
+	}
+
+	return NewKMSSigner(cfg.GetEndpoint(), cfg.Region, cfg.KeyID, creds)
+}
+
+// GetPubKey returns the public key of the KMS key.
+// Implements the PrivValidator interface.
+func (s *KMSSigner) GetPubKey() (tm_crypto.PubKey, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.pubKey != nil {
+		return s.pubKey, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kmsRequestTimeout)
+	defer cancel()
+	resp, err := s.client.GetPublicKeyWithContext(ctx, &kms.GetPublicKeyInput{KeyId: aws.String(s.KeyID)})
+	if err != nil {
+		return nil, types.Errorf(types.CodeSigningFailed, "GetPublicKey failed: %w", err)
+	}
+	pub, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse the public key of %v: %w", s.KeyID, err)
+	}
+	edPub, ok := pub.(ed25519.PublicKey)
+	if !ok {
+		return nil, types.Errorf(types.CodeInvalidConfig, "%v isn't an Ed25519 key", s.KeyID)
+	}
+	s.pubKey = tm_ed25519.PubKey(edPub)
+
+	return s.pubKey, nil
+}
+
+// sign signs the given sign bytes, or returns the last signature and its timestamp
+// again if the sign bytes only differ from the last ones in the timestamp. The request
+// to KMS is canceled once ctx is done.
+func (s *KMSSigner) sign(ctx context.Context, signBytes, withoutTimestamp []byte, timestamp time.Time) ([]byte, time.Time, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.last.signature != nil && bytes.Equal(s.last.signBytes, withoutTimestamp) {
+		return s.last.signature, s.last.timestamp, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, kmsRequestTimeout)
+	defer cancel()
+	resp, err := s.client.SignWithContext(ctx, &kms.SignInput{
+		KeyId:            aws.String(s.KeyID),
+		Message:          signBytes,
+		MessageType:      aws.String(kms.MessageTypeRaw),
+		SigningAlgorithm: aws.String(kmsSigningAlgorithm),
+	})
+	if err != nil {
+		return nil, time.Time{}, types.Errorf(types.CodeSigningFailed, "Sign failed: %w", err)
+	}
+	s.last = kmsSignature{signBytes: withoutTimestamp, timestamp: timestamp, signature: resp.Signature}
+
+	return resp.Signature, timestamp, nil
+}
+
+// SignVote signs the given vote with the KMS key.
+// Implements the PrivValidator interface.
+func (s *KMSSigner) SignVote(chainID string, vote *tm_typesproto.Vote) error {
+	return s.SignVoteContext(context.Background(), chainID, vote)
+}
+
+// SignVoteContext signs the given vote with the KMS key, unless ctx is done first.
+// Implements the contextSigner interface.
+func (s *KMSSigner) SignVoteContext(ctx context.Context, chainID string, vote *tm_typesproto.Vote) error {
+	sig, timestamp, err := s.sign(ctx, tm_types.VoteSignBytes(chainID, vote), voteSignBytes(chainID, vote), vote.Timestamp)
+	if err != nil {
+		return err
+	}
+	vote.Signature, vote.Timestamp = sig, timestamp
+
+	return nil
+}
+
+// SignProposal signs the given proposal with the KMS key.
+// Implements the PrivValidator interface.
+func (s *KMSSigner) SignProposal(chainID string, proposal *tm_typesproto.Proposal) error {
+	return s.SignProposalContext(context.Background(), chainID, proposal)
+}
+
+// SignProposalContext signs the given proposal with the KMS key, unless ctx is done
+// first.
+// Implements the contextSigner interface.
+func (s *KMSSigner) SignProposalContext(ctx context.Context, chainID string, proposal *tm_typesproto.Proposal) error {
+	sig, timestamp, err := s.sign(ctx, tm_types.ProposalSignBytes(chainID, proposal), proposalSignBytes(chainID, proposal), proposal.Timestamp)
+	if err != nil {
+		return err
+	}
+	proposal.Signature, proposal.Timestamp = sig, timestamp
+
+	return nil
+}
+
+// kmsSignerBackend signs with the KMS key in the key_id parameter, which is in the
+// region parameter. The endpoint and credentials_file parameters are optional, like
+// in the [kms] section.
+func kmsSignerBackend(params map[string]string) (tm_types.PrivValidator, error) {
+	if params["region"] == "" || params["key_id"] == "" {
+		return nil, errors.New("region and key_id must not be empty")
+	}
+
+	return NewKMSSignerFor(config.KMS{
+		KeyID:           params["key_id"],
+		Region:          params["region"],
+		Endpoint:        params["endpoint"],
+		CredentialsFile: params["credentials_file"],
+	})
+}
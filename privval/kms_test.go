@@ -0,0 +1,127 @@
+package privval
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+// fakeKMS serves the GetPublicKey and Sign actions of the KMS API for the given key.
+func fakeKMS(t *testing.T, pub interface{}, priv ed25519.PrivateKey) (*httptest.Server, *int32) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	var signed int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") ||
+			!strings.Contains(r.Header.Get("Authorization"), "/eu-central-1/kms/aws4_request") {
+			rw.WriteHeader(http.StatusForbidden)
+			_, _ = rw.Write([]byte(`{"__type":"IncompleteSignature","message":"invalid signature"}`))
+			return
+		}
+		var req struct {
+			KeyID            string `json:"KeyId"`
+			Message          []byte `json:"Message"`
+			MessageType      string `json:"MessageType"`
+			SigningAlgorithm string `json:"SigningAlgorithm"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.KeyID != "alias/validator" {
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = rw.Write([]byte(`{"__type":"NotFoundException","message":"key not found"}`))
+			return
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			_ = json.NewEncoder(rw).Encode(map[string]interface{}{"PublicKey": der})
+		case "TrentService.Sign":
+			assert.Equal(t, "RAW", req.MessageType)
+			assert.Equal(t, kmsSigningAlgorithm, req.SigningAlgorithm)
+			atomic.AddInt32(&signed, 1)
+			_ = json.NewEncoder(rw).Encode(map[string]interface{}{"Signature": ed25519.Sign(priv, req.Message)})
+		default:
+			rw.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+
+	return srv, &signed
+}
+
+func TestKMSSigner(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	srv, signed := fakeKMS(t, pub, priv)
+	defer srv.Close()
+	creds := credentials.NewStaticCredentials("access", "secret", "")
+
+	// Keys that don't exist are noticed right away.
+	_, err = NewKMSSigner(srv.URL, "eu-central-1", "alias/other", creds)
+	assert.Error(t, err)
+
+	signer, err := NewKMSSigner(srv.URL, "eu-central-1", "alias/validator", creds)
+	require.NoError(t, err)
+	pubKey, err := signer.GetPubKey()
+	require.NoError(t, err)
+	assert.Equal(t, []byte(pub), pubKey.Bytes())
+
+	vote := &tm_typesproto.Vote{Type: tm_typesproto.PrevoteType, Height: 10, Timestamp: time.Unix(10, 0).UTC()}
+	require.NoError(t, signer.SignVote("testchain", vote))
+	assert.True(t, pubKey.VerifySignature(tm_types.VoteSignBytes("testchain", vote), vote.Signature))
+
+	// A vote that only differs in its timestamp gets the same signature and timestamp
+	// again.
+	again := &tm_typesproto.Vote{Type: tm_typesproto.PrevoteType, Height: 10, Timestamp: time.Unix(11, 0).UTC()}
+	require.NoError(t, signer.SignVote("testchain", again))
+	assert.Equal(t, vote.Signature, again.Signature)
+	assert.Equal(t, vote.Timestamp, again.Timestamp)
+	assert.Equal(t, int32(1), atomic.LoadInt32(signed))
+
+	proposal := &tm_typesproto.Proposal{Type: tm_typesproto.ProposalType, Height: 11, Timestamp: time.Unix(12, 0).UTC()}
+	require.NoError(t, signer.SignProposal("testchain", proposal))
+	assert.True(t, pubKey.VerifySignature(tm_types.ProposalSignBytes("testchain", proposal), proposal.Signature))
+	assert.Equal(t, int32(2), atomic.LoadInt32(signed))
+
+	// Requests are canceled along with the sign request.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = signer.SignVoteContext(ctx, "testchain", &tm_typesproto.Vote{Type: tm_typesproto.PrecommitType, Height: 11})
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(signed))
+
+	// Requests signed with the wrong credentials are rejected.
+	_, err = NewKMSSigner(srv.URL, "eu-central-1", "alias/validator", credentials.NewStaticCredentials("other", "secret", ""))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "IncompleteSignature")
+}
+
+func TestKMSSigner_NotEd25519(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	srv, _ := fakeKMS(t, key.Public(), nil)
+	defer srv.Close()
+
+	_, err = NewKMSSigner(srv.URL, "eu-central-1", "alias/validator", credentials.NewStaticCredentials("access", "secret", ""))
+	assert.Error(t, err)
+}
+
+func TestKMSSignerBackend_InvalidParams(t *testing.T) {
+	_, err := NewSigner("awskms", map[string]string{"region": "eu-central-1"})
+	assert.Error(t, err)
+	_, err = NewSigner("awskms", map[string]string{"region": "eu-central-1", "key_id": "alias/validator", "credentials_file": "/does/not/exist"})
+	assert.Error(t, err)
+}
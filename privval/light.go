@@ -0,0 +1,116 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/BlockscapeNetwork/signctrl/rpc"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_light "github.com/tendermint/tendermint/light"
+	tm_lightprovider "github.com/tendermint/tendermint/light/provider"
+	tm_lightdb "github.com/tendermint/tendermint/light/store/db"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+	tm_db "github.com/tendermint/tm-db"
+)
+
+// ErrUnverifiedBlock is returned if a block queried from the validator's RPC server
+// doesn't match the header verified by the light client.
+var ErrUnverifiedBlock = types.NewError(types.CodeUnverified, "block doesn't match the verified header")
+
+// BlockVerifier verifies that the given block, which was queried from the validator's
+// RPC server, is part of the canonical chain.
+type BlockVerifier func(ctx context.Context, block *tm_coretypes.ResultBlock) error
+
+// verifyBlock is the default BlockVerifier of SCFilePV. It verifies the header at the
+// block's height with the light client and checks that the block matches it. Blocks
+// are not verified if no trust root is configured in the [light_client] section.
+func (pv *SCFilePV) verifyBlock(ctx context.Context, block *tm_coretypes.ResultBlock) error {
+	if !pv.Config.LightClient.Enabled() {
+		return nil
+	}
+	if block == nil || block.Block == nil {
+		return fmt.Errorf("%w: empty block", ErrUnverifiedBlock)
+	}
+
+	lc, err := pv.getLightClient(ctx)
+	if err != nil {
+		return err
+	}
+	lb, err := lc.VerifyLightBlockAtHeight(ctx, block.Block.Height, pv.Clock.Now())
+	if err != nil {
+		return err
+	}
+
+	return matchesLightBlock(block.Block, lb)
+}
+
+// getLightClient returns the light client, which is created on first use as its
+// creation fetches the trusted header from the validator's RPC server.
+func (pv *SCFilePV) getLightClient(ctx context.Context) (*tm_light.Client, error) {
+	pv.lightMtx.Lock()
+	defer pv.lightMtx.Unlock()
+
+	if pv.lightClient != nil {
+		return pv.lightClient, nil
+	}
+	lc, err := pv.newLightClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create light client: %w", err)
+	}
+	pv.lightClient = lc
+
+	return lc, nil
+}
+
+// newLightClient creates a light client that uses the validator's RPC server as its
+// primary and the configured witnesses to detect forks. All of them are queried via
+// the node's RPC client. As the light client requires
+// at least one witness, the primary is also used as the witness if none are
+// configured.
+func (pv *SCFilePV) newLightClient(ctx context.Context) (*tm_light.Client, error) {
+	cfg := pv.Config.LightClient
+	chainID := pv.Config.Privval.ChainID
+
+	hash, err := hex.DecodeString(cfg.TrustHash)
+	if err != nil {
+		return nil, err
+	}
+	primary := rpc.NewLightProvider(chainID, pv.Config.Base.ValidatorListenAddressRPC, pv.RPC)
+	witnesses := make([]tm_lightprovider.Provider, 0, len(cfg.Witnesses))
+	for _, addr := range cfg.Witnesses {
+		witnesses = append(witnesses, rpc.NewLightProvider(chainID, addr, pv.RPC))
+	}
+	if len(witnesses) == 0 {
+		pv.Logger.Warn("No light client witnesses configured, forks of the validator's RPC server can't be detected")
+		witnesses = append(witnesses, primary)
+	}
+
+	return tm_light.NewClient(
+		ctx,
+		chainID,
+		tm_light.TrustOptions{
+			Period: cfg.GetTrustPeriod(),
+			Height: cfg.TrustHeight,
+			Hash:   hash,
+		},
+		primary,
+		witnesses,
+		tm_lightdb.New(tm_db.NewMemDB(), chainID),
+	)
+}
+
+// matchesLightBlock checks that the given block is consistent and that its hash
+// matches the hash of the given verified light block.
+func matchesLightBlock(block *tm_types.Block, lb *tm_types.LightBlock) error {
+	if err := block.ValidateBasic(); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnverifiedBlock, err)
+	}
+	if lb == nil || lb.SignedHeader == nil || !bytes.Equal(block.Hash(), lb.Hash()) {
+		return fmt.Errorf("%w at height %v", ErrUnverifiedBlock, block.Height)
+	}
+
+	return nil
+}
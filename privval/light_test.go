@@ -0,0 +1,142 @@
+package privval
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+// testLightChain creates a chain of the given number of blocks that are committed by
+// a single validator, along with the blocks' commits.
+func testLightChain(t *testing.T, n int64) ([]*tm_types.Block, []*tm_types.Commit, *tm_types.ValidatorSet) {
+	t.Helper()
+	privVal := tm_types.NewMockPV()
+	pub, err := privVal.GetPubKey()
+	assert.NoError(t, err)
+	vals := tm_types.NewValidatorSet([]*tm_types.Validator{tm_types.NewValidator(pub, 10)})
+
+	var blocks []*tm_types.Block
+	var commits []*tm_types.Commit
+	lastCommit := &tm_types.Commit{}
+	for h := int64(1); h <= n; h++ {
+		block := tm_types.MakeBlock(h, nil, lastCommit, nil)
+		block.ChainID = "testchain"
+		block.Time = time.Now().Add(time.Duration(h-n) * time.Second)
+		block.ValidatorsHash = vals.Hash()
+		block.NextValidatorsHash = vals.Hash()
+		block.ProposerAddress = pub.Address()
+		if len(blocks) > 0 {
+			block.LastBlockID = lastCommit.BlockID
+		}
+		blockID := tm_types.BlockID{Hash: block.Hash(), PartSetHeader: block.MakePartSet(tm_types.BlockPartSizeBytes).Header()}
+
+		voteSet := tm_types.NewVoteSet("testchain", h, 0, tm_typesproto.PrecommitType, vals)
+		commit, err := tm_types.MakeCommit(blockID, h, 0, voteSet, []tm_types.PrivValidator{privVal}, block.Time)
+		assert.NoError(t, err)
+
+		blocks = append(blocks, block)
+		commits = append(commits, commit)
+		lastCommit = commit
+	}
+
+	return blocks, commits, vals
+}
+
+// testLightServer serves the /commit and /validators endpoints for the given chain.
+func testLightServer(t *testing.T, blocks []*tm_types.Block, commits []*tm_types.Commit, vals *tm_types.ValidatorSet) *httptest.Server {
+	t.Helper()
+	respond := func(rw http.ResponseWriter, result interface{}) {
+		bytes, err := tm_json.Marshal(result)
+		assert.NoError(t, err)
+		fmt.Fprintf(rw, `{"jsonrpc":"2.0","id":-1,"result":%s}`, bytes)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/commit", func(rw http.ResponseWriter, r *http.Request) {
+		height, _ := strconv.ParseInt(r.URL.Query().Get("height"), 10, 64)
+		if height == 0 {
+			height = int64(len(blocks))
+		}
+		sh := tm_types.SignedHeader{Header: &blocks[height-1].Header, Commit: commits[height-1]}
+		respond(rw, &tm_coretypes.ResultCommit{SignedHeader: sh, CanonicalCommit: true})
+	})
+	mux.HandleFunc("/validators", func(rw http.ResponseWriter, r *http.Request) {
+		respond(rw, &tm_coretypes.ResultValidators{
+			Validators: vals.Validators,
+			Count:      vals.Size(),
+			Total:      vals.Size(),
+		})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestMatchesLightBlock(t *testing.T) {
+	blocks, commits, _ := testLightChain(t, 2)
+	block := blocks[1]
+	lb := &tm_types.LightBlock{SignedHeader: &tm_types.SignedHeader{Header: &block.Header, Commit: commits[1]}}
+	assert.NoError(t, matchesLightBlock(block, lb))
+
+	// The block differs from the verified header.
+	lb = &tm_types.LightBlock{SignedHeader: &tm_types.SignedHeader{Header: &blocks[0].Header, Commit: commits[0]}}
+	assert.True(t, errors.Is(matchesLightBlock(block, lb), ErrUnverifiedBlock))
+
+	// The block's last commit doesn't match its header.
+	block.LastCommit = commits[1]
+	lb = &tm_types.LightBlock{SignedHeader: &tm_types.SignedHeader{Header: &block.Header, Commit: commits[1]}}
+	assert.True(t, errors.Is(matchesLightBlock(block, lb), ErrUnverifiedBlock))
+}
+
+func TestVerifyBlock_Disabled(t *testing.T) {
+	blocks, _, _ := testLightChain(t, 1)
+	pv := mockSCFilePV(t)
+	assert.NoError(t, pv.VerifyBlock(context.Background(), &tm_coretypes.ResultBlock{Block: blocks[0]}))
+}
+
+func TestVerifyBlock(t *testing.T) {
+	blocks, commits, vals := testLightChain(t, 3)
+	server := testLightServer(t, blocks, commits, vals)
+	defer server.Close()
+
+	pv := mockSCFilePV(t)
+	pv.Config.Base.ValidatorListenAddressRPC = "tcp://" + strings.TrimPrefix(server.URL, "http://")
+	pv.Config.LightClient.TrustHeight = 1
+	pv.Config.LightClient.TrustHash = hex.EncodeToString(blocks[0].Hash())
+	pv.Config.LightClient.TrustPeriod = "168h"
+
+	// The block queried from the validator's RPC server matches the verified header.
+	assert.NoError(t, pv.VerifyBlock(context.Background(), &tm_coretypes.ResultBlock{Block: blocks[2]}))
+
+	// The block queried from the validator's RPC server was tampered with.
+	tampered, _, _ := testLightChain(t, 3)
+	err := pv.VerifyBlock(context.Background(), &tm_coretypes.ResultBlock{Block: tampered[2]})
+	assert.True(t, errors.Is(err, ErrUnverifiedBlock))
+}
+
+func TestVerifyBlock_WrongTrustHash(t *testing.T) {
+	blocks, commits, vals := testLightChain(t, 2)
+	server := testLightServer(t, blocks, commits, vals)
+	defer server.Close()
+
+	pv := mockSCFilePV(t)
+	pv.Config.Base.ValidatorListenAddressRPC = "tcp://" + strings.TrimPrefix(server.URL, "http://")
+	pv.Config.LightClient.TrustHeight = 1
+	pv.Config.LightClient.TrustHash = hex.EncodeToString(blocks[1].Hash())
+	pv.Config.LightClient.TrustPeriod = "168h"
+
+	// The RPC server's header at the trust height doesn't match the trust root.
+	assert.Error(t, pv.VerifyBlock(context.Background(), &tm_coretypes.ResultBlock{Block: blocks[1]}))
+}
@@ -0,0 +1,81 @@
+package privval
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+	"github.com/BlockscapeNetwork/signctrl/lease"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+)
+
+// ErrLockNotHeld is returned if a node on rank 1 doesn't hold the signing lock, e.g.
+// because the previous signer still holds it or the store can't be reached.
+var ErrLockNotHeld = types.NewError(types.CodeNoPermission, "signing lock not held")
+
+// newLockBackend creates the backend of the signing lock configured in the [lock]
+// section.
+func (pv *SCFilePV) newLockBackend() (lease.Backend, error) {
+	cfg := pv.Config.Lock
+	key, holder := cfg.GetKey(pv.Config.Privval.ChainID), pv.Config.P2P.GetName()
+	switch cfg.Backend {
+	case config.LockBackendEtcd:
+		return lease.NewEtcd(cfg.Endpoints, key, holder, nil), nil
+	case config.LockBackendConsul:
+		return lease.NewConsul(cfg.Endpoints, key, holder, nil), nil
+	}
+
+	return nil, fmt.Errorf("unknown lock backend %v", cfg.Backend)
+}
+
+// startLock sets up the signing lock and renews its lease while it is held, until
+// ctx is done. The lock is acquired by the first sign request on rank 1.
+func (pv *SCFilePV) startLock(ctx context.Context) error {
+	backend, err := pv.newLockBackend()
+	if err != nil {
+		return err
+	}
+	pv.lock = lease.New(backend, pv.Config.Lock.GetTTL(), pv.Clock, pv.Logger)
+	pv.lock.OnLost = pv.onLockLost
+	pv.Logger.Info("Signing only while holding the lock at %v in %v", pv.Config.Lock.GetKey(pv.Config.Privval.ChainID), pv.Config.Lock.Backend)
+
+	goroutines.Go("lock", func() {
+		defer pv.recoverPanic("lock")
+		pv.lock.Run(ctx)
+	})
+
+	return nil
+}
+
+// acquireLock makes sure the node holds the signing lock before it signs, if a lock
+// is used. Acquiring it takes at most a third of its TTL, so that the validator isn't
+// held up by an unreachable store.
+func (pv *SCFilePV) acquireLock(ctx context.Context) error {
+	if pv.lock == nil || pv.lock.Held() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, pv.lock.TTL()/3)
+	defer cancel()
+
+	return pv.lock.Acquire(ctx)
+}
+
+// releaseLock releases the signing lock, if it is held, so that the next signer
+// doesn't need to wait for its lease to expire.
+func (pv *SCFilePV) releaseLock() {
+	if pv.lock == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pv.lock.TTL()/3)
+	defer cancel()
+	if err := pv.lock.Release(ctx); err != nil {
+		pv.Logger.Warn("couldn't release the signing lock, it's released once its lease expires: %v", err)
+	}
+}
+
+// onLockLost reports that the node stopped signing, as it lost the signing lock.
+func (pv *SCFilePV) onLockLost(err error) {
+	pv.emit(watchtower.EventLockLost, "Lost the signing lock, stopped signing: %v", err)
+}
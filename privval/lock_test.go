@@ -0,0 +1,76 @@
+package privval
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/lease"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+// fakeLockBackend is a lease.Backend whose lock is held by another node until it is
+// freed.
+type fakeLockBackend struct {
+	mtx      sync.Mutex
+	free     bool
+	released bool
+}
+
+func (b *fakeLockBackend) Acquire(ctx context.Context, ttl time.Duration) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if !b.free {
+		return lease.ErrHeld
+	}
+	return nil
+}
+
+func (b *fakeLockBackend) Renew(ctx context.Context) error { return nil }
+
+func (b *fakeLockBackend) Release(ctx context.Context) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.released = true
+	return nil
+}
+
+func TestHandleSignRequest_LockNotHeld(t *testing.T) {
+	dir := t.TempDir()
+	pv := mockSCFilePV(t)
+	pv.TMFilePV = tm_privval.GenFilePV(filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile))
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		return testBlockResult(t).Result, nil
+	}
+	backend := &fakeLockBackend{}
+	pv.lock = lease.New(backend, 15*time.Second, pv.Clock, pv.Logger)
+
+	// Rank 1 doesn't sign while another node holds the lock.
+	require.Equal(t, 1, pv.GetRank())
+	req := testSignVoteRequest(t)
+	resp, err := HandleRequest(context.Background(), req, pv)
+	assert.ErrorIs(t, err, ErrLockNotHeld)
+	assert.ErrorIs(t, err, lease.ErrHeld)
+	assert.NotNil(t, resp.GetSignedVoteResponse().GetError())
+	assert.Empty(t, req.GetSignVoteRequest().Vote.Signature)
+
+	// Once the lock is free, it is acquired and the request is signed. A new lock
+	// doesn't wait for the failed attempt to be retried.
+	backend.free = true
+	pv.lock = lease.New(backend, 15*time.Second, pv.Clock, pv.Logger)
+	req = testSignVoteRequest(t)
+	_, err = HandleRequest(context.Background(), req, pv)
+	require.NoError(t, err)
+	assert.NotEmpty(t, req.GetSignVoteRequest().Vote.Signature)
+	assert.True(t, pv.lock.Held())
+
+	// The lock is released on demotion.
+	pv.OnDemote()
+	assert.False(t, pv.lock.Held())
+	assert.True(t, backend.released)
+}
@@ -0,0 +1,132 @@
+package privval
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/maintenance"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+)
+
+// MaintenanceRequest defines the request JSON for starting a maintenance window via the
+// admin API.
+type MaintenanceRequest struct {
+	// Duration is the duration of the maintenance window, e.g. "1h". It must be
+	// positive and at most maintenance.MaxDuration.
+	Duration string `json:"duration"`
+}
+
+// MaintenanceUntil returns the time the current maintenance window ends at, i.e. the
+// later end of the window started via the admin API and the configured windows. It
+// returns false if there's no maintenance window at the moment.
+func (pv *SCFilePV) MaintenanceUntil() (time.Time, bool) {
+	now := pv.Clock.Now().UTC()
+
+	pv.maintenanceMtx.RLock()
+	until := pv.maintenanceUntil
+	pv.maintenanceMtx.RUnlock()
+	if !until.After(now) {
+		until = time.Time{}
+	}
+	for _, w := range pv.maintenanceWindows {
+		if end, ok := w.ActiveUntil(now); ok && end.After(until) {
+			until = end
+		}
+	}
+
+	return until, !until.IsZero()
+}
+
+// InMaintenance returns true if there's a maintenance window at the moment, in which
+// missed blocks aren't counted.
+func (pv *SCFilePV) InMaintenance() bool {
+	_, ok := pv.MaintenanceUntil()
+	return ok
+}
+
+// StartMaintenanceWindow starts a maintenance window of the given duration, replacing
+// the one started before, if any.
+func (pv *SCFilePV) StartMaintenanceWindow(d time.Duration) {
+	until := pv.Clock.Now().UTC().Add(d)
+	pv.maintenanceMtx.Lock()
+	pv.maintenanceUntil = until
+	pv.maintenanceMtx.Unlock()
+
+	pv.Logger.Info("Started maintenance window until %v, not counting missed blocks", until.Format(time.RFC3339))
+	pv.emit(watchtower.EventMaintenanceStarted, "Started maintenance window until %v", until.Format(time.RFC3339))
+}
+
+// EndMaintenanceWindow ends the maintenance window started via StartMaintenanceWindow.
+// The configured windows aren't affected.
+func (pv *SCFilePV) EndMaintenanceWindow() {
+	pv.maintenanceMtx.Lock()
+	pv.maintenanceUntil = time.Time{}
+	pv.maintenanceMtx.Unlock()
+
+	pv.Logger.Info("Ended maintenance window")
+	pv.emit(watchtower.EventMaintenanceEnded, "Ended maintenance window")
+}
+
+// maintenanceHandler starts a maintenance window on POST requests and ends it on
+// DELETE requests. Only requests from the loopback interface with a valid token are
+// accepted.
+func (pv *SCFilePV) maintenanceHandler(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !pv.checkAdmin(rw, r, false) {
+		return
+	}
+	if r.Method == http.MethodDelete {
+		pv.EndMaintenanceWindow()
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		httpError(rw, err, http.StatusBadRequest)
+		return
+	}
+	var req MaintenanceRequest
+	if err := tm_json.Unmarshal(body, &req); err != nil {
+		httpError(rw, err, http.StatusBadRequest)
+		return
+	}
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil || d <= 0 || d > maintenance.MaxDuration {
+		http.Error(rw, fmt.Sprintf("duration must be positive and at most %v", maintenance.MaxDuration), http.StatusBadRequest)
+		return
+	}
+	pv.StartMaintenanceWindow(d)
+}
+
+// StartMaintenance requests the given instance, or the default validator if name is
+// empty, to start a maintenance window of the given duration.
+func StartMaintenance(name string, d time.Duration, creds AdminCredentials) error {
+	body, err := tm_json.Marshal(MaintenanceRequest{Duration: d.String()})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, localURL(instancePath(name, "/admin/maintenance")), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	return doAdminRequest(req, creds)
+}
+
+// EndMaintenance requests the given instance, or the default validator if name is
+// empty, to end the maintenance window started via StartMaintenance.
+func EndMaintenance(name string, creds AdminCredentials) error {
+	req, err := http.NewRequest(http.MethodDelete, localURL(instancePath(name, "/admin/maintenance")), nil)
+	if err != nil {
+		return err
+	}
+
+	return doAdminRequest(req, creds)
+}
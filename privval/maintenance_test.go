@@ -0,0 +1,72 @@
+package privval
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/maintenance"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+)
+
+func TestMaintenanceUntil(t *testing.T) {
+	// 2021-08-01 is a Sunday.
+	clock := types.NewFakeClock(time.Date(2021, time.August, 1, 2, 30, 0, 0, time.UTC))
+	pv := mockSCFilePV(t)
+	pv.Clock = clock
+	w, err := maintenance.ParseWindow("0 3 * * 0 2h")
+	require.NoError(t, err)
+	pv.maintenanceWindows = []maintenance.Window{w}
+	assert.False(t, pv.InMaintenance())
+	assert.Nil(t, pv.Status().MaintenanceUntil)
+
+	// Windows started via the admin API take effect immediately.
+	pv.StartMaintenanceWindow(time.Hour)
+	until, ok := pv.MaintenanceUntil()
+	assert.True(t, ok)
+	assert.Equal(t, clock.Now().Add(time.Hour), until)
+
+	// The later end of overlapping windows is used.
+	clock.Advance(time.Hour)
+	until, ok = pv.MaintenanceUntil()
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2021, time.August, 1, 5, 0, 0, 0, time.UTC), until)
+	assert.Equal(t, until, *pv.Status().MaintenanceUntil)
+
+	clock.Advance(2 * time.Hour)
+	assert.False(t, pv.InMaintenance())
+
+	pv.StartMaintenanceWindow(time.Hour)
+	assert.True(t, pv.InMaintenance())
+	pv.EndMaintenanceWindow()
+	assert.False(t, pv.InMaintenance())
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	pv := mockSCFilePV(t)
+	send := func(method, remoteAddr, duration string) int {
+		body, err := tm_json.Marshal(MaintenanceRequest{Duration: duration})
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, "/admin/maintenance", bytes.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		pv.maintenanceHandler(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusMethodNotAllowed, send(http.MethodGet, "127.0.0.1:1234", "1h"))
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "10.0.0.1:1234", "1h"))
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "127.0.0.1:1234", "0s"))
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "127.0.0.1:1234", "25h"))
+	assert.False(t, pv.InMaintenance())
+
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "127.0.0.1:1234", "1h"))
+	assert.True(t, pv.InMaintenance())
+	assert.Equal(t, http.StatusOK, send(http.MethodDelete, "127.0.0.1:1234", ""))
+	assert.False(t, pv.InMaintenance())
+}
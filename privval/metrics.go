@@ -0,0 +1,116 @@
+package privval
+
+import (
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+// msgTypeLabel returns the metrics label for the given type of signed message.
+func msgTypeLabel(t tm_typesproto.SignedMsgType) string {
+	switch t {
+	case tm_typesproto.PrevoteType:
+		return "prevote"
+	case tm_typesproto.PrecommitType:
+		return "precommit"
+	case tm_typesproto.ProposalType:
+		return "proposal"
+	default:
+		return "unknown"
+	}
+}
+
+// rejectWrongChainID logs and counts a request of the type with the given label that
+// was rejected, as it is for the given chain ID instead of the configured one.
+func (pv *SCFilePV) rejectWrongChainID(label, chainID string) {
+	pv.Logger.Warn("Rejected %v request for chain ID %q, expected %q. Is the validator configured for the right chain?", label, chainID, pv.Config.Privval.ChainID)
+	if pv.Gauges.WrongChainIDCounter != nil {
+		pv.Gauges.WrongChainIDCounter.WithLabelValues(label).Inc()
+	}
+}
+
+// observeSignRequest records the duration of the sign request described by reqData,
+// which took d and returned err, and counts it as signed if err is nil.
+func (pv *SCFilePV) observeSignRequest(reqData sharedSignRequestData, d time.Duration, err error) {
+	label := msgTypeLabel(reqData.msgType)
+	if pv.Gauges.SignDurationHistogram != nil {
+		pv.Gauges.SignDurationHistogram.WithLabelValues(label).Observe(d.Seconds())
+	}
+	if err == nil && pv.Gauges.SignedCounter != nil {
+		pv.Gauges.SignedCounter.WithLabelValues(label).Inc()
+	}
+}
+
+// updateRankGauges sets the gauges for the rank, the counter for missed blocks in a
+// row, its threshold and whether it is locked to the current state.
+func (pv *SCFilePV) updateRankGauges() {
+	if pv.Gauges.RankGauge != nil {
+		pv.Gauges.RankGauge.Set(float64(pv.GetRank()))
+	}
+	if pv.Gauges.MissedInARowGauge != nil {
+		pv.Gauges.MissedInARowGauge.Set(float64(pv.GetMissedInARow()))
+	}
+	if pv.Gauges.ThresholdGauge != nil {
+		pv.Gauges.ThresholdGauge.Set(float64(pv.GetThreshold()))
+	}
+	if pv.Gauges.CounterLockedGauge != nil {
+		locked := 0.0
+		if pv.IsCounterLocked() {
+			locked = 1
+		}
+		pv.Gauges.CounterLockedGauge.Set(locked)
+	}
+}
+
+// countConnError counts a failed read or write on the connection to the validator.
+func (pv *SCFilePV) countConnError(op string) {
+	if pv.Gauges.ConnErrorsCounter != nil {
+		pv.Gauges.ConnErrorsCounter.WithLabelValues(op).Inc()
+	}
+}
+
+// countError counts an error with the given code.
+func (pv *SCFilePV) countError(code types.ErrorCode) {
+	if pv.Gauges.ErrorsCounter != nil {
+		pv.Gauges.ErrorsCounter.WithLabelValues(string(code)).Inc()
+	}
+}
+
+// countReconnect counts an attempt to reconnect to the validator.
+func (pv *SCFilePV) countReconnect() {
+	if pv.Gauges.ReconnectsCounter != nil {
+		pv.Gauges.ReconnectsCounter.Inc()
+	}
+}
+
+// setDialBackoffGauge sets the gauge for the delay between the attempts to dial the
+// validator.
+func (pv *SCFilePV) setDialBackoffGauge(d time.Duration) {
+	if pv.Gauges.DialBackoffGauge != nil {
+		pv.Gauges.DialBackoffGauge.Set(d.Seconds())
+	}
+}
+
+// setWatcherSubscribed sets the prometheus gauge for whether the commit watcher
+// receives the blocks via the subscription.
+func (pv *SCFilePV) setWatcherSubscribed(subscribed bool) {
+	if pv.Gauges.WatcherSubscribedGauge != nil {
+		value := 0.0
+		if subscribed {
+			value = 1
+		}
+		pv.Gauges.WatcherSubscribedGauge.Set(value)
+	}
+}
+
+// updateWatcherLag sets the prometheus gauge for the time since the latest checked
+// block was committed, if any block was checked yet.
+func (pv *SCFilePV) updateWatcherLag() {
+	if pv.Gauges.WatcherLagGauge == nil {
+		return
+	}
+	if t := pv.GetRankState().BlockTime; !t.IsZero() {
+		pv.Gauges.WatcherLagGauge.Set(pv.Clock.Now().Sub(t).Seconds())
+	}
+}
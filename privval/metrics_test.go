@@ -0,0 +1,68 @@
+package privval
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	prom_model "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_hash "github.com/tendermint/tendermint/crypto/tmhash"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+func TestMsgTypeLabel(t *testing.T) {
+	assert.Equal(t, "prevote", msgTypeLabel(tm_typesproto.PrevoteType))
+	assert.Equal(t, "precommit", msgTypeLabel(tm_typesproto.PrecommitType))
+	assert.Equal(t, "proposal", msgTypeLabel(tm_typesproto.ProposalType))
+	assert.Equal(t, "unknown", msgTypeLabel(tm_typesproto.UnknownType))
+}
+
+func TestHandleSignRequest_Metrics(t *testing.T) {
+	dir := t.TempDir()
+	pv := mockSCFilePV(t)
+	pv.TMFilePV = tm_privval.GenFilePV(filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile))
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		return testBlockResult(t).Result, nil
+	}
+	pv.Gauges.SignedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_signed"}, []string{"type"})
+	pv.Gauges.SignDurationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_sign_duration"}, []string{"type"})
+	pv.Gauges.ThresholdGauge = prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_threshold"})
+	pv.Gauges.CounterLockedGauge = prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_counter_locked"})
+
+	_, err := handleSignRequest(context.Background(), testSignVoteRequest(t), pv)
+	require.NoError(t, err)
+
+	// A conflicting vote is refused, so it's observed, but not counted as signed.
+	req := testSignVoteRequest(t)
+	req.GetSignVoteRequest().Vote.BlockID.Hash = tm_hash.Sum([]byte("OtherBlockIDHash"))
+	_, err = handleSignRequest(context.Background(), req, pv)
+	require.Error(t, err)
+
+	label := msgTypeLabel(testVote(t).Type)
+	assert.Equal(t, 1.0, prom_testutil.ToFloat64(pv.Gauges.SignedCounter.WithLabelValues(label)))
+	var m prom_model.Metric
+	require.NoError(t, pv.Gauges.SignDurationHistogram.WithLabelValues(label).(prometheus.Histogram).Write(&m))
+	assert.Equal(t, uint64(2), m.GetHistogram().GetSampleCount())
+	assert.Equal(t, float64(pv.GetThreshold()), prom_testutil.ToFloat64(pv.Gauges.ThresholdGauge))
+	assert.Equal(t, 1.0, prom_testutil.ToFloat64(pv.Gauges.CounterLockedGauge))
+}
+
+func TestStartHTTPServer_Metrics(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.HTTP.Addr = "127.0.0.1:0"
+	require.NoError(t, pv.StartHTTPServer())
+	defer pv.HTTP.Close()
+
+	rec := httptest.NewRecorder()
+	pv.HTTP.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "go_goroutines")
+}
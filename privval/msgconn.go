@@ -0,0 +1,98 @@
+package privval
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+)
+
+// msgConn reads and writes varint-delimited messages on the connection to the
+// validator. It's the equivalent of tm_protoio's delimited reader and writer, but
+// reuses the same pooled buffers for all messages of a connection.
+type msgConn struct {
+	conn    io.ReadWriter
+	maxSize int
+
+	// rbuf and wbuf are the pooled buffers message bodies are read into and encoded
+	// in.
+	rbuf, wbuf *[]byte
+
+	// b is the buffer ReadByte reads into.
+	b [1]byte
+}
+
+// newMsgConn returns a new msgConn for the given connection. Its buffers are taken
+// from the pool and must be returned with release once it is no longer used.
+func newMsgConn(conn io.ReadWriter, maxSize int) *msgConn {
+	return &msgConn{
+		conn:    conn,
+		maxSize: maxSize,
+		rbuf:    msgBufPool.Get().(*[]byte),
+		wbuf:    msgBufPool.Get().(*[]byte),
+	}
+}
+
+// reset switches to the given connection, e.g. after reconnecting to the validator.
+func (c *msgConn) reset(conn io.ReadWriter) {
+	c.conn = conn
+}
+
+// release returns the buffers to the pool. The msgConn must not be used afterwards.
+func (c *msgConn) release() {
+	msgBufPool.Put(c.rbuf)
+	msgBufPool.Put(c.wbuf)
+	c.rbuf, c.wbuf = nil, nil
+}
+
+// grow returns the given buffer resliced to n bytes, growing it if needed.
+func grow(buf *[]byte, n int) []byte {
+	if cap(*buf) < n {
+		*buf = make([]byte, n)
+	}
+	*buf = (*buf)[:n]
+
+	return *buf
+}
+
+// ReadByte reads a single byte from the connection. It implements io.ByteReader
+// for reading the length prefix without consuming bytes of the next message.
+func (c *msgConn) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(c.conn, c.b[:]); err != nil {
+		return 0, err
+	}
+
+	return c.b[0], nil
+}
+
+// ReadMsg reads the next message from the connection into msg.
+func (c *msgConn) ReadMsg(msg *tm_privvalproto.Message) error {
+	l, err := binary.ReadUvarint(c)
+	if err != nil {
+		return err
+	}
+	if l > uint64(c.maxSize) {
+		return fmt.Errorf("message exceeds max size (%v > %v)", l, c.maxSize)
+	}
+	buf := grow(c.rbuf, int(l))
+	if _, err := io.ReadFull(c.conn, buf); err != nil {
+		return err
+	}
+
+	// The generated Unmarshal copies byte fields, so the buffer can be reused.
+	return msg.Unmarshal(buf)
+}
+
+// WriteMsg writes msg to the connection with a single write.
+func (c *msgConn) WriteMsg(msg *tm_privvalproto.Message) error {
+	size := msg.Size()
+	buf := grow(c.wbuf, binary.MaxVarintLen64+size)
+	n := binary.PutUvarint(buf, uint64(size))
+	if _, err := msg.MarshalTo(buf[n:]); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(buf[:n+size])
+
+	return err
+}
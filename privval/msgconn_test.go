@@ -0,0 +1,99 @@
+package privval
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+)
+
+// assertSameMsg asserts that both messages marshal to the same bytes.
+func assertSameMsg(t *testing.T, expected, actual *tm_privvalproto.Message) {
+	t.Helper()
+	expectedBz, err := proto.Marshal(expected)
+	require.NoError(t, err)
+	actualBz, err := proto.Marshal(actual)
+	require.NoError(t, err)
+	assert.Equal(t, expectedBz, actualBz)
+}
+
+func TestMsgConnCompatibility(t *testing.T) {
+	vote := testSignVoteRequest(t)
+
+	// Messages written by tm_protoio are read by msgConn.
+	var buf bytes.Buffer
+	w := tm_protoio.NewDelimitedWriter(&buf)
+	_, err := w.WriteMsg(vote)
+	require.NoError(t, err)
+	_, err = w.WriteMsg(testPingRequest(t))
+	require.NoError(t, err)
+
+	mc := newMsgConn(&buf, maxRemoteSignerMsgSize)
+	defer mc.release()
+	var msg tm_privvalproto.Message
+	require.NoError(t, mc.ReadMsg(&msg))
+	assertSameMsg(t, vote, &msg)
+	msg = tm_privvalproto.Message{}
+	require.NoError(t, mc.ReadMsg(&msg))
+	assertSameMsg(t, testPingRequest(t), &msg)
+	assert.Equal(t, io.EOF, mc.ReadMsg(&msg))
+
+	// Messages written by msgConn are read by tm_protoio.
+	require.NoError(t, mc.WriteMsg(vote))
+	r := tm_protoio.NewDelimitedReader(&buf, maxRemoteSignerMsgSize)
+	msg = tm_privvalproto.Message{}
+	_, err = r.ReadMsg(&msg)
+	require.NoError(t, err)
+	assertSameMsg(t, vote, &msg)
+}
+
+func TestMsgConnMaxSize(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := tm_protoio.NewDelimitedWriter(&buf).WriteMsg(testSignVoteRequest(t))
+	require.NoError(t, err)
+
+	mc := newMsgConn(&buf, 10)
+	defer mc.release()
+	var msg tm_privvalproto.Message
+	assert.Error(t, mc.ReadMsg(&msg))
+}
+
+func TestMsgConnReset(t *testing.T) {
+	var first, second bytes.Buffer
+	mc := newMsgConn(&first, maxRemoteSignerMsgSize)
+	defer mc.release()
+	require.NoError(t, mc.WriteMsg(testPingRequest(t)))
+	mc.reset(&second)
+	require.NoError(t, mc.WriteMsg(testPingRequest(t)))
+	assert.Equal(t, first.Bytes(), second.Bytes())
+}
+
+func TestMsgConnAllocs(t *testing.T) {
+	discard := struct {
+		io.Reader
+		io.Writer
+	}{nil, ioutil.Discard}
+	mc := newMsgConn(discard, maxRemoteSignerMsgSize)
+	defer mc.release()
+	msg := testSignVoteRequest(t)
+
+	// The buffer is reused once it has grown to the size of the message, while a new
+	// writer allocates one for every message.
+	reused := testing.AllocsPerRun(100, func() {
+		if err := mc.WriteMsg(msg); err != nil {
+			t.Fatal(err)
+		}
+	})
+	perMsg := testing.AllocsPerRun(100, func() {
+		if _, err := tm_protoio.NewDelimitedWriter(discard).WriteMsg(msg); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Less(t, reused, perMsg)
+}
@@ -0,0 +1,169 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/BlockscapeNetwork/signctrl/features"
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+	"github.com/BlockscapeNetwork/signctrl/p2p"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+)
+
+// LastSigned returns the height, round and step the node last signed a vote or
+// proposal at, or the zero watermark if it hasn't signed anything since it was
+// started.
+func (pv *SCFilePV) LastSigned() Watermark {
+	pv.lastSignedMtx.RLock()
+	defer pv.lastSignedMtx.RUnlock()
+
+	return pv.lastSigned
+}
+
+// LastSignedHeight returns the height the node last signed a vote or proposal at, or
+// 0 if it hasn't signed anything since it was started.
+func (pv *SCFilePV) LastSignedHeight() int64 {
+	return pv.LastSigned().Height
+}
+
+// setLastSigned records that a vote or proposal was signed at w.
+func (pv *SCFilePV) setLastSigned(w Watermark) {
+	pv.lastSignedMtx.Lock()
+	defer pv.lastSignedMtx.Unlock()
+
+	pv.lastSigned = w
+}
+
+// Peers returns the status of the other nodes in the set as of their heartbeats, or
+// nil if no heartbeats are exchanged.
+func (pv *SCFilePV) Peers() []p2p.PeerStatus {
+	if pv.p2p == nil {
+		return nil
+	}
+
+	return pv.p2p.Peers()
+}
+
+// startP2P starts receiving heartbeats from the other nodes in the set and sending
+// them the node's own ones until ctx is done.
+func (pv *SCFilePV) startP2P(ctx context.Context) error {
+	secret, err := p2p.LoadSecret(pv.Config.P2P.SecretFile)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", strings.TrimPrefix(pv.Config.P2P.ListenAddress, "tcp://"))
+	if err != nil {
+		return err
+	}
+	pv.p2p = p2p.NewNode(pv.Config.P2P.GetName(), secret, pv.Config.P2P.GetPeerTimeout(), pv.Clock)
+	pv.Logger.Info("Exchanging heartbeats with %v peers as %v", len(pv.Config.P2P.Peers), pv.p2p.Name)
+
+	goroutines.Go("p2p_listen", func() {
+		defer pv.recoverPanic("p2p_listen")
+		pv.p2p.Serve(ctx, ln, func(remote net.Addr, err error) {
+			if errors.Is(err, p2p.ErrInvalidMAC) {
+				pv.Logger.Warn("Rejected heartbeat from %v, check the secret_file of the set: %v", remote, err)
+				return
+			}
+			pv.Logger.Debug("Rejected heartbeat from %v: %v", remote, err)
+		})
+	})
+	goroutines.Go("p2p", func() { pv.sendHeartbeats(ctx) })
+
+	return nil
+}
+
+// sendHeartbeats sends a heartbeat to every peer in the heartbeat interval and checks
+// the peers' heartbeats until ctx is done.
+func (pv *SCFilePV) sendHeartbeats(ctx context.Context) {
+	defer pv.recoverPanic("p2p")
+	defer pv.idle("p2p")
+
+	interval := pv.Config.P2P.GetHeartbeatInterval()
+	live := make(map[string]bool)
+	conflict := false
+	for {
+		pv.tick("p2p", interval+pv.Config.P2P.GetPeerTimeout(), nil)
+		hb := p2p.Heartbeat{
+			Node:   pv.p2p.Name,
+			Time:   pv.Clock.Now(),
+			Rank:   pv.GetRank(),
+			Height: pv.LastSignedHeight(),
+			Epoch:  pv.GetEpoch(),
+		}
+		var wg sync.WaitGroup
+		wg.Add(len(pv.Config.P2P.Peers))
+		for _, addr := range pv.Config.P2P.Peers {
+			go func(addr string) {
+				defer wg.Done()
+				if err := pv.p2p.Send(ctx, strings.TrimPrefix(addr, "tcp://"), hb); err != nil && ctx.Err() == nil {
+					pv.Logger.Debug("Couldn't send heartbeat to %v: %v", addr, err)
+				}
+			}(addr)
+		}
+		wg.Wait()
+		conflict = pv.checkPeers(live, conflict)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-pv.Clock.After(interval):
+		}
+	}
+}
+
+// checkPeers logs the peers that became live or were lost since the last check, as
+// recorded in live, and reports a live peer on the node's own rank unless it was
+// already reported. It returns whether there is such a peer.
+func (pv *SCFilePV) checkPeers(live map[string]bool, reported bool) bool {
+	rank := pv.GetRank()
+	conflict := ""
+	n := 0
+	for _, p := range pv.p2p.Peers() {
+		if p.Live != live[p.Node] {
+			if p.Live {
+				pv.Logger.Info("Receiving heartbeats from peer %v (rank %v)", p.Node, p.Rank)
+			} else {
+				pv.Logger.Warn("Lost peer %v, no heartbeat for %v", p.Node, pv.p2p.Timeout)
+			}
+			live[p.Node] = p.Live
+		}
+		if !p.Live {
+			continue
+		}
+		n++
+		// With raft, all nodes but the leader share rank 2.
+		if p.Rank == rank && (rank == 1 || !pv.Config.Base.UsesRaft()) {
+			conflict = p.Node
+		}
+	}
+	if pv.Gauges.LivePeersGauge != nil {
+		pv.Gauges.LivePeersGauge.Set(float64(n))
+	}
+
+	if conflict != "" && !reported {
+		pv.Logger.Error("Peer %v is on rank %v as well, check the ranks of the set", conflict, rank)
+		pv.emit(watchtower.EventRankConflict, "Peer %v is on rank %v as well", conflict, rank)
+	}
+
+	return conflict != ""
+}
+
+// signingPeer returns a live peer that is ranked first and signed at the given height
+// or later. There is none if peer coordination is disabled or no heartbeats are
+// exchanged.
+func (pv *SCFilePV) signingPeer(height int64) (p2p.PeerStatus, bool) {
+	if pv.p2p == nil || !pv.Features.Enabled(features.PeerCoordination) {
+		return p2p.PeerStatus{}, false
+	}
+	for _, p := range pv.p2p.Peers() {
+		if p.Live && p.Rank == 1 && p.Height >= height {
+			return p, true
+		}
+	}
+
+	return p2p.PeerStatus{}, false
+}
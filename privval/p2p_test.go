@@ -0,0 +1,145 @@
+package privval
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/features"
+	"github.com/BlockscapeNetwork/signctrl/p2p"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+var testP2PSecret = []byte(strings.Repeat("s", p2p.SecretSize))
+
+// mockP2P lets the given node exchange heartbeats and returns a function that
+// delivers a heartbeat from a peer to it.
+func mockP2P(t *testing.T, pv *SCFilePV) func(hb p2p.Heartbeat) {
+	t.Helper()
+	pv.p2p = p2p.NewNode("a", testP2PSecret, 5*time.Second, pv.Clock)
+
+	return func(hb p2p.Heartbeat) {
+		sealed, err := p2p.Seal(testP2PSecret, hb)
+		require.NoError(t, err)
+		_, err = pv.p2p.Receive(sealed)
+		require.NoError(t, err)
+	}
+}
+
+func TestSigningPeer(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.Clock = types.NewFakeClock(time.Unix(1000, 0))
+	_, ok := pv.signingPeer(10)
+	assert.False(t, ok)
+
+	receive := mockP2P(t, pv)
+	receive(p2p.Heartbeat{Node: "b", Time: pv.Clock.Now(), Rank: 1, Height: 10})
+
+	// Peer coordination is disabled.
+	_, ok = pv.signingPeer(10)
+	assert.False(t, ok)
+
+	pv.Features = features.New(config.Features{PeerCoordination: true})
+	peer, ok := pv.signingPeer(10)
+	assert.True(t, ok)
+	assert.Equal(t, "b", peer.Node)
+
+	// The peer hasn't signed the height yet.
+	_, ok = pv.signingPeer(11)
+	assert.False(t, ok)
+
+	// The peer is no longer live.
+	pv.Clock.(*types.FakeClock).Advance(6 * time.Second)
+	_, ok = pv.signingPeer(10)
+	assert.False(t, ok)
+}
+
+func TestCheckPeers(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.Clock = types.NewFakeClock(time.Unix(1000, 0))
+	receive := mockP2P(t, pv)
+	live := make(map[string]bool)
+
+	receive(p2p.Heartbeat{Node: "b", Time: pv.Clock.Now(), Rank: 2})
+	assert.False(t, pv.checkPeers(live, false))
+	assert.True(t, live["b"])
+
+	// A peer on the node's own rank is reported once.
+	receive(p2p.Heartbeat{Node: "c", Time: pv.Clock.Now(), Rank: 1})
+	assert.True(t, pv.checkPeers(live, false))
+	assert.True(t, pv.checkPeers(live, true))
+
+	pv.Clock.(*types.FakeClock).Advance(6 * time.Second)
+	assert.False(t, pv.checkPeers(live, true))
+	assert.False(t, live["b"])
+
+	var got []watchtower.EventType
+	events, _, _ := pv.watchEvents.Since(0)
+	for _, e := range events {
+		got = append(got, e.Type)
+	}
+	assert.Equal(t, []watchtower.EventType{watchtower.EventRankConflict}, got)
+	assert.Len(t, pv.Status().Peers, 2)
+}
+
+func TestHandleSignRequest_PeerSigning(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.SetRank(2)
+	pv.UnlockCounter()
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		return testBlockResult(t).Result, nil
+	}
+	pv.VerifyBlock = func(ctx context.Context, block *tm_coretypes.ResultBlock) error {
+		return nil
+	}
+	pv.Features = features.New(config.Features{PeerCoordination: true})
+	receive := mockP2P(t, pv)
+	receive(p2p.Heartbeat{Node: "b", Time: pv.Clock.Now(), Rank: 1, Height: 1 << 40})
+
+	// Missed blocks aren't counted while rank 1 is alive and signing.
+	_, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.ErrorIs(t, err, ErrNoSigningPermission)
+	assert.Zero(t, pv.GetMissedInARow())
+}
+
+func TestStartP2P(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The peer receiving the node's heartbeats.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	peer := p2p.NewNode("b", testP2PSecret, 5*time.Second, types.SystemClock)
+	go peer.Serve(ctx, ln, nil)
+
+	pv := mockSCFilePV(t)
+	pv.Config.P2P = config.P2P{
+		ListenAddress:     "tcp://127.0.0.1:0",
+		Peers:             []string{fmt.Sprintf("tcp://%v", ln.Addr())},
+		Name:              "a",
+		SecretFile:        filepath.Join(t.TempDir(), "p2p_secret"),
+		HeartbeatInterval: "100ms",
+		PeerTimeout:       "5s",
+	}
+
+	// The secret is missing.
+	assert.Error(t, pv.startP2P(ctx))
+
+	require.NoError(t, ioutil.WriteFile(pv.Config.P2P.SecretFile, []byte(fmt.Sprintf("%x", testP2PSecret)), 0600))
+	pv.setLastSigned(Watermark{Height: 42})
+	require.NoError(t, pv.startP2P(ctx))
+	assert.Eventually(t, func() bool {
+		peers := peer.Peers()
+		return len(peers) == 1 && peers[0].Node == "a" && peers[0].Rank == 1 && peers[0].Height == 42
+	}, 5*time.Second, 10*time.Millisecond)
+}
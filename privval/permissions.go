@@ -0,0 +1,84 @@
+package privval
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/statefile"
+	"github.com/BlockscapeNetwork/signctrl/statemac"
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+const (
+	// PermSecretFile determines the file permissions of the keys and state files
+	// SignCTRL creates. Stricter modes like 0400 are accepted as well.
+	PermSecretFile = os.FileMode(0600)
+)
+
+// ErrInsecurePermissions is returned if a key or state file can be read or written by
+// other users than the one SignCTRL runs as.
+var ErrInsecurePermissions = types.NewError(types.CodeInvalidConfig, "insecure file permissions")
+
+// secretFiles returns the paths to the keys and state files in the given directory,
+// including the backups and MACs of the state files.
+func secretFiles(dir string) []string {
+	files := []string{
+		KeyFilePath(dir),
+		connection.KeyFilePath(dir),
+		statemac.KeyFilePath(dir),
+	}
+	for _, file := range stateFiles(dir) {
+		mac := statemac.Path(file)
+		files = append(files, file, statefile.BackupPath(file), mac, statefile.BackupPath(mac))
+	}
+
+	return files
+}
+
+// CheckPermissions checks that the keys and state files in the given directory are
+// only accessible by their owner, e.g. have a mode of 0600 or 0400, and are owned by
+// the user SignCTRL runs as. Keys created by external tools frequently arrive
+// world-readable. If fix is true, the files are fixed instead by removing the
+// permissions of the group and other users, and the paths of the fixed files are
+// returned. Changing the owner requires SignCTRL to run as root.
+func CheckPermissions(dir string, fix bool) ([]string, error) {
+	var fixed []string
+	var errs string
+	for _, file := range secretFiles(dir) {
+		info, err := os.Stat(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return fixed, err
+		}
+
+		changed := false
+		if uid, ok := fileOwner(info); ok && uid != os.Getuid() {
+			if !fix {
+				errs += fmt.Sprintf("\t%v is owned by uid %v, but SignCTRL runs as uid %v\n", file, uid, os.Getuid())
+			} else if err := os.Chown(file, os.Getuid(), os.Getgid()); err != nil {
+				return fixed, err
+			} else {
+				changed = true
+			}
+		}
+		if mode := info.Mode().Perm(); mode&0077 != 0 {
+			if !fix {
+				errs += fmt.Sprintf("\t%v has mode %04o, but must only be accessible by its owner, e.g. with mode %04o\n", file, mode, PermSecretFile)
+			} else if err := os.Chmod(file, mode&^0077); err != nil {
+				return fixed, err
+			} else {
+				changed = true
+			}
+		}
+		if changed {
+			fixed = append(fixed, file)
+		}
+	}
+	if errs != "" {
+		return fixed, fmt.Errorf("%w (use --fix-permissions to fix them):\n%v", ErrInsecurePermissions, errs)
+	}
+
+	return fixed, nil
+}
@@ -0,0 +1,82 @@
+package privval
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/statefile"
+	"github.com/BlockscapeNetwork/signctrl/statemac"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	dir := t.TempDir()
+	fixed, err := CheckPermissions(dir, false)
+	assert.NoError(t, err)
+	assert.Empty(t, fixed)
+
+	// New files are created with safe modes.
+	tm_privval.GenFilePV(KeyFilePath(dir), StateFilePath(dir)).Save()
+	_, err = config.LoadOrGenState(dir)
+	require.NoError(t, err)
+	require.NoError(t, connection.CreateBase64ConnKey(dir))
+	_, err = CheckPermissions(dir, false)
+	assert.NoError(t, err)
+
+	// Keys created by external tools may be world-readable.
+	require.NoError(t, os.Chmod(KeyFilePath(dir), 0644))
+	require.NoError(t, os.Chmod(connection.KeyFilePath(dir), 0660))
+	fixed, err = CheckPermissions(dir, false)
+	assert.True(t, errors.Is(err, ErrInsecurePermissions))
+	assert.Contains(t, err.Error(), KeyFilePath(dir))
+	assert.Contains(t, err.Error(), connection.KeyFilePath(dir))
+	assert.Empty(t, fixed)
+
+	fixed, err = CheckPermissions(dir, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{KeyFilePath(dir), connection.KeyFilePath(dir)}, fixed)
+	info, err := os.Stat(KeyFilePath(dir))
+	require.NoError(t, err)
+	assert.Equal(t, PermSecretFile, info.Mode().Perm())
+	_, err = CheckPermissions(dir, false)
+	assert.NoError(t, err)
+
+	// The high watermark is checked like the other state files.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, HighWatermarkFile), []byte("{}"), 0644))
+	_, err = CheckPermissions(dir, false)
+	assert.True(t, errors.Is(err, ErrInsecurePermissions))
+	require.NoError(t, os.Chmod(filepath.Join(dir, HighWatermarkFile), PermSecretFile))
+
+	// Stricter modes are accepted and never loosened.
+	require.NoError(t, os.Chmod(KeyFilePath(dir), 0400))
+	fixed, err = CheckPermissions(dir, true)
+	assert.NoError(t, err)
+	assert.Empty(t, fixed)
+	info, err = os.Stat(KeyFilePath(dir))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0400), info.Mode().Perm())
+
+	// The MACs and backups of the state files are checked as well.
+	mac := statemac.Path(config.StateFilePath(dir))
+	require.NoError(t, ioutil.WriteFile(mac, []byte{}, 0644))
+	require.NoError(t, ioutil.WriteFile(statefile.BackupPath(config.StateFilePath(dir)), []byte{}, 0640))
+	_, err = CheckPermissions(dir, false)
+	assert.True(t, errors.Is(err, ErrInsecurePermissions))
+	assert.Contains(t, err.Error(), mac)
+	assert.Contains(t, err.Error(), statefile.BackupPath(config.StateFilePath(dir)))
+	fixed, err = CheckPermissions(dir, true)
+	assert.NoError(t, err)
+	assert.Len(t, fixed, 2)
+
+	// Other files in the directory aren't checked.
+	require.NoError(t, ioutil.WriteFile(config.FilePath(dir), []byte{}, 0644))
+	_, err = CheckPermissions(dir, false)
+	assert.NoError(t, err)
+}
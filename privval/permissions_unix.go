@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package privval
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the uid of the given file's owner.
+func fileOwner(info os.FileInfo) (int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+
+	return int(stat.Uid), true
+}
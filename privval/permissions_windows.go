@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package privval
+
+import "os"
+
+// fileOwner returns false, as files don't have a uid on Windows.
+func fileOwner(info os.FileInfo) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,99 @@
+package privval
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolStats are the statistics of a pool.
+type PoolStats struct {
+	// Gets is the number of values taken from the pool.
+	Gets uint64
+
+	// Allocs is the number of values the pool had to allocate, because none was
+	// available for reuse.
+	Allocs uint64
+}
+
+// pool is a sync.Pool that keeps track of how often it's used and how often it has to
+// allocate, so that its effectiveness can be monitored.
+type pool struct {
+	// gets and allocs come first, so that they're 64-bit aligned on 32-bit platforms.
+	gets, allocs uint64
+
+	p sync.Pool
+}
+
+// newPool returns a new pool whose values are allocated with alloc.
+func newPool(alloc func() interface{}) *pool {
+	p := &pool{}
+	p.p.New = func() interface{} {
+		atomic.AddUint64(&p.allocs, 1)
+		return alloc()
+	}
+
+	return p
+}
+
+// Get takes a value from the pool, allocating a new one if none is available.
+func (p *pool) Get() interface{} {
+	atomic.AddUint64(&p.gets, 1)
+	return p.p.Get()
+}
+
+// Put returns a value to the pool.
+func (p *pool) Put(x interface{}) {
+	p.p.Put(x)
+}
+
+// Stats returns the pool's statistics.
+func (p *pool) Stats() PoolStats {
+	return PoolStats{
+		Gets:   atomic.LoadUint64(&p.gets),
+		Allocs: atomic.LoadUint64(&p.allocs),
+	}
+}
+
+var (
+	// msgBufPool pools the buffers the messages exchanged with the validator are read
+	// into and encoded in, so that reconnecting to the validator doesn't allocate new
+	// ones. They are bounded by maxRemoteSignerMsgSize, so keeping them around is
+	// cheap. The messages themselves aren't pooled, as decoding a request allocates
+	// its contents anew anyway.
+	msgBufPool = newPool(func() interface{} {
+		buf := make([]byte, 0, 1024)
+		return &buf
+	})
+)
+
+// BufferPoolStats returns the statistics of the pool of message buffers.
+func BufferPoolStats() PoolStats {
+	return msgBufPool.Stats()
+}
+
+// RegisterPoolMetrics registers prometheus counters for the statistics of the pool of
+// message buffers with reg. The ratio of allocations to gets shows how well the pool
+// works. An error is returned if the counters are already registered with reg.
+func RegisterPoolMetrics(reg prometheus.Registerer) error {
+	labels := prometheus.Labels{"pool": "buffer"}
+	for _, c := range []prometheus.Collector{
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "signctrl_pool_gets_total",
+			Help:        "Number of values taken from the pools of the message pipeline.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(msgBufPool.Stats().Gets) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "signctrl_pool_allocations_total",
+			Help:        "Number of values the pools of the message pipeline had to allocate.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(msgBufPool.Stats().Allocs) }),
+	} {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
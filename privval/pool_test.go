@@ -0,0 +1,49 @@
+package privval
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolStats(t *testing.T) {
+	p := newPool(func() interface{} { return new(int) })
+	assert.Equal(t, PoolStats{}, p.Stats())
+
+	x := p.Get()
+	assert.Equal(t, PoolStats{Gets: 1, Allocs: 1}, p.Stats())
+
+	// sync.Pool may drop values at any time, so only the gets are certain.
+	p.Put(x)
+	p.Get()
+	assert.EqualValues(t, 2, p.Stats().Gets)
+	assert.LessOrEqual(t, p.Stats().Allocs, uint64(2))
+}
+
+func TestRegisterPoolMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	require.NoError(t, RegisterPoolMetrics(reg))
+	mc := newMsgConn(nil, maxRemoteSignerMsgSize)
+	mc.release()
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	values := make(map[string]float64)
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "pool" {
+					values[mf.GetName()+"/"+l.GetValue()] = m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	assert.Len(t, values, 2)
+	assert.GreaterOrEqual(t, values["signctrl_pool_gets_total/buffer"], 2.0)
+	assert.LessOrEqual(t, values["signctrl_pool_allocations_total/buffer"], values["signctrl_pool_gets_total/buffer"])
+
+	// Registering the counters twice fails instead of panicking.
+	assert.Error(t, RegisterPoolMetrics(reg))
+}
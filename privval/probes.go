@@ -0,0 +1,52 @@
+package privval
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Healthy returns nil if the node is running and none of its goroutines is stalled,
+// or the reason why it isn't healthy otherwise.
+func (pv *SCFilePV) Healthy() error {
+	if pv.IsCrashed() {
+		return ErrCrashed
+	}
+	if !pv.IsRunning() {
+		return fmt.Errorf("service is not running")
+	}
+	if stalled := pv.StalledGoroutines(); len(stalled) > 0 {
+		return fmt.Errorf("stalled %v", strings.Join(stalled, ", "))
+	}
+
+	return nil
+}
+
+// Ready returns nil if the node is connected to the validator and the counter for
+// missed blocks in a row was unlocked by the first commitsig, or the reason why it
+// isn't ready otherwise.
+func (pv *SCFilePV) Ready() error {
+	if err := pv.Healthy(); err != nil {
+		return err
+	}
+	if state := pv.GetConnState(); state != ConnConnected {
+		return fmt.Errorf("connection to the validator is %v", state)
+	}
+	if pv.IsCounterLocked() {
+		return fmt.Errorf("waiting for the first commitsig")
+	}
+
+	return nil
+}
+
+// probeHandler returns a handler for a Kubernetes probe that responds with 200 if
+// check returns nil, and with 503 and the reason otherwise.
+func probeHandler(check func() error) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if err := check(); err != nil {
+			httpError(rw, err, http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = rw.Write([]byte("ok\n"))
+	}
+}
@@ -0,0 +1,49 @@
+package privval
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbes(t *testing.T) {
+	signerConn, validatorConn := net.Pipe()
+	defer validatorConn.Close()
+
+	pv := mockSCFilePV(t)
+	pv.HTTP = nil
+	pv.Dial = func(ctx context.Context) (net.Conn, error) {
+		return signerConn, nil
+	}
+	handler := pv.handler()
+	probe := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	// The node isn't running yet.
+	assert.Equal(t, http.StatusServiceUnavailable, probe("/healthz"))
+	assert.Equal(t, http.StatusServiceUnavailable, probe("/readyz"))
+
+	require.NoError(t, pv.Start())
+	defer func() {
+		assert.NoError(t, pv.Stop())
+		<-pv.Quit()
+	}()
+	require.Eventually(t, func() bool { return pv.GetConnState() == ConnConnected }, 5*time.Second, 10*time.Millisecond)
+
+	// The node is connected, but the first commitsig hasn't been found yet.
+	pv.LockCounter()
+	assert.Equal(t, http.StatusOK, probe("/healthz"))
+	assert.Equal(t, http.StatusServiceUnavailable, probe("/readyz"))
+
+	pv.UnlockCounter()
+	assert.Equal(t, http.StatusOK, probe("/readyz"))
+}
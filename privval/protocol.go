@@ -0,0 +1,152 @@
+package privval
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Tendermint and its CometBFT fork share the privval wire format up to CometBFT
+// v0.37, so requests are handled the same way for both. CometBFT v0.38 added vote
+// extensions, which would need to be signed with the validator's key alongside
+// precommits and are dropped when decoding with Tendermint's proto types, so they
+// aren't supported.
+
+// VersionQuerier queries the version of the validator's node software.
+type VersionQuerier func(ctx context.Context) (string, error)
+
+// versionRegExp matches versions like "0.34.8", "v0.38.2" or "0.37.0-rc1".
+var versionRegExp = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)`)
+
+// parseVersion parses the major, minor and patch version from the given version.
+func parseVersion(version string) (major, minor, patch int, err error) {
+	m := versionRegExp.FindStringSubmatch(version)
+	if m == nil {
+		return 0, 0, 0, fmt.Errorf("invalid version: %v", version)
+	}
+	major, _ = strconv.Atoi(m[1])
+	minor, _ = strconv.Atoi(m[2])
+	patch, _ = strconv.Atoi(m[3])
+
+	return major, minor, patch, nil
+}
+
+// HasVoteExtensions returns true if the given version of the validator's node
+// software supports vote extensions, which were introduced in CometBFT v0.38.
+func HasVoteExtensions(version string) bool {
+	major, minor, _, err := parseVersion(version)
+	if err != nil {
+		return false
+	}
+
+	return major > 0 || minor >= 38
+}
+
+// queryVersion is the default VersionQuerier of SCFilePV. It queries the version from
+// the validator's RPC server at the configured validator_laddr_rpc.
+func (pv *SCFilePV) queryVersion(ctx context.Context) (string, error) {
+	return pv.RPC.QueryNodeVersion(ctx, pv.Config.Base.ValidatorListenAddressRPC)
+}
+
+// Capabilities are the optional parts of the privval protocol that are used with the
+// validator. Parts that either side doesn't understand are disabled, so that they
+// can't fail requests in the middle of consensus.
+type Capabilities struct {
+	// Pings is true if the validator keeps idle connections alive with PingRequests.
+	// If it doesn't, SignCTRL doesn't consider idle connections to be lost.
+	Pings bool `json:"pings"`
+
+	// VoteExtensions is true if vote extensions are signed alongside precommits.
+	VoteExtensions bool `json:"vote_extensions"`
+}
+
+var (
+	// supportedCapabilities are the capabilities SignCTRL supports itself.
+	supportedCapabilities = Capabilities{Pings: true}
+
+	// defaultCapabilities are assumed if the validator's capabilities can't be
+	// detected. They match the ones of Tendermint v0.34.
+	defaultCapabilities = Capabilities{Pings: true}
+)
+
+// String returns the enabled capabilities as a comma-separated list.
+func (c Capabilities) String() string {
+	var enabled []string
+	if c.Pings {
+		enabled = append(enabled, "pings")
+	}
+	if c.VoteExtensions {
+		enabled = append(enabled, "vote extensions")
+	}
+	if len(enabled) == 0 {
+		return "none"
+	}
+
+	return strings.Join(enabled, ", ")
+}
+
+// intersect returns the capabilities that are enabled in both c and o.
+func (c Capabilities) intersect(o Capabilities) Capabilities {
+	return Capabilities{
+		Pings:          c.Pings && o.Pings,
+		VoteExtensions: c.VoteExtensions && o.VoteExtensions,
+	}
+}
+
+// DetectCapabilities returns the capabilities of a validator running the given
+// version of its node software. All versions speaking the protobuf privval protocol
+// ping the remote signer.
+func DetectCapabilities(version string) (Capabilities, error) {
+	if _, _, _, err := parseVersion(version); err != nil {
+		return Capabilities{}, err
+	}
+
+	return Capabilities{
+		Pings:          true,
+		VoteExtensions: HasVoteExtensions(version),
+	}, nil
+}
+
+// GetCapabilities returns the capabilities used with the validator.
+func (pv *SCFilePV) GetCapabilities() Capabilities {
+	pv.capsMtx.RLock()
+	defer pv.capsMtx.RUnlock()
+
+	return pv.caps
+}
+
+// negotiateCapabilities detects the capabilities of the validator that was just
+// connected to from the version reported by its RPC server and disables the ones
+// SignCTRL doesn't support. The previous capabilities are kept if detection fails.
+func (pv *SCFilePV) negotiateCapabilities(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, versionQueryTimeout)
+	defer cancel()
+
+	version, err := pv.QueryVersion(ctx)
+	var caps Capabilities
+	if err == nil {
+		caps, err = DetectCapabilities(version)
+	}
+	if err != nil {
+		pv.Logger.Warn("couldn't detect the validator's capabilities, keeping %v: %v\n", pv.GetCapabilities(), err)
+		return
+	}
+
+	if caps.VoteExtensions && !supportedCapabilities.VoteExtensions {
+		pv.Logger.Warn("SignCTRL doesn't sign vote extensions yet, so precommits are rejected on chains with vote extensions enabled")
+	}
+	caps = caps.intersect(supportedCapabilities)
+
+	// The gRPC PrivValidatorAPI has no pings.
+	if pv.Config.Privval.UsesGRPC() {
+		caps.Pings = false
+	}
+
+	pv.capsMtx.Lock()
+	pv.caps = caps
+	pv.capsMtx.Unlock()
+
+	pv.Logger.Info("Using the following privval capabilities with the validator: %v", caps)
+}
@@ -0,0 +1,106 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+)
+
+func TestHasVoteExtensions(t *testing.T) {
+	assert.False(t, HasVoteExtensions("0.34.27"))
+	assert.False(t, HasVoteExtensions("0.37.2"))
+	assert.True(t, HasVoteExtensions("0.38.2"))
+	assert.True(t, HasVoteExtensions("v1.0.0"))
+	assert.False(t, HasVoteExtensions("invalid"))
+}
+
+func TestDetectCapabilities(t *testing.T) {
+	caps, err := DetectCapabilities("0.34.8")
+	assert.NoError(t, err)
+	assert.Equal(t, Capabilities{Pings: true}, caps)
+
+	caps, err = DetectCapabilities("v0.38.2")
+	assert.NoError(t, err)
+	assert.Equal(t, Capabilities{Pings: true, VoteExtensions: true}, caps)
+
+	_, err = DetectCapabilities("invalid")
+	assert.Error(t, err)
+}
+
+func TestCapabilities_String(t *testing.T) {
+	assert.Equal(t, "none", Capabilities{}.String())
+	assert.Equal(t, "pings", Capabilities{Pings: true}.String())
+	assert.Equal(t, "pings, vote extensions", Capabilities{Pings: true, VoteExtensions: true}.String())
+}
+
+func TestSCFilePV_NegotiateCapabilities(t *testing.T) {
+	pv := mockSCFilePV(t)
+	assert.Equal(t, defaultCapabilities, pv.GetCapabilities())
+
+	// Vote extensions are disabled, as SignCTRL doesn't support them.
+	pv.QueryVersion = func(ctx context.Context) (string, error) {
+		return "0.38.2", nil
+	}
+	pv.negotiateCapabilities(context.Background())
+	assert.Equal(t, Capabilities{Pings: true}, pv.GetCapabilities())
+
+	// The previous capabilities are kept if detection fails.
+	pv.caps = Capabilities{}
+	pv.QueryVersion = func(ctx context.Context) (string, error) {
+		return "", errors.New("unreachable")
+	}
+	pv.negotiateCapabilities(context.Background())
+	assert.Equal(t, Capabilities{}, pv.GetCapabilities())
+
+	// The gRPC PrivValidatorAPI has no pings.
+	pv.QueryVersion = func(ctx context.Context) (string, error) {
+		return "0.34.8", nil
+	}
+	pv.Config.Privval.Transport = "grpc"
+	pv.negotiateCapabilities(context.Background())
+	assert.False(t, pv.GetCapabilities().Pings)
+}
+
+func TestSCFilePV_UnsupportedMessage(t *testing.T) {
+	signerConn, validatorConn := net.Pipe()
+	defer validatorConn.Close()
+
+	pv := mockSCFilePV(t)
+	pv.HTTP = nil
+	pv.CfgDir = t.TempDir()
+	pv.Config.Privval.ValidatorSetCheck = "off"
+	pv.Dial = func(ctx context.Context) (net.Conn, error) {
+		return signerConn, nil
+	}
+	assert.NoError(t, pv.Start())
+	defer func() { _ = pv.Stop() }()
+
+	assert.NoError(t, validatorConn.SetDeadline(time.Now().Add(5*time.Second)))
+	r := tm_protoio.NewDelimitedReader(validatorConn, maxRemoteSignerMsgSize)
+	w := tm_protoio.NewDelimitedWriter(validatorConn)
+
+	// A message of a type introduced by a newer validator (field 99) is rejected.
+	for i := 0; i < 2; i++ {
+		_, err := validatorConn.Write([]byte{0x03, 0x9a, 0x06, 0x00})
+		assert.NoError(t, err)
+		var resp tm_privvalproto.Message
+		_, err = r.ReadMsg(&resp)
+		assert.NoError(t, err)
+		assert.Contains(t, resp.GetPubKeyResponse().GetError().GetDescription(), ErrUnknownMessage.Error())
+	}
+
+	// The connection is still usable.
+	_, err := w.WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+	assert.NoError(t, err)
+	var resp tm_privvalproto.Message
+	_, err = r.ReadMsg(&resp)
+	assert.NoError(t, err)
+	assert.NotNil(t, resp.GetPingResponse())
+	assert.True(t, pv.IsRunning())
+}
@@ -0,0 +1,54 @@
+package privval
+
+import (
+	"errors"
+	"fmt"
+
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+)
+
+// pubKey returns the validator's public key. It's cached once the signer backend
+// returned it, so that the validator's PubKeyRequests are answered while the backend
+// is unavailable or being swapped, which keeps the connection alive until signing
+// resumes. The key never changes, as swapping to a backend with a different key is
+// refused.
+func (pv *SCFilePV) pubKey() (tm_crypto.PubKey, error) {
+	if pub := pv.cachedPubKey(); pub != nil {
+		return pub, nil
+	}
+
+	pv.signerMtx.RLock()
+	defer pv.signerMtx.RUnlock()
+
+	return pv.pubKeyLocked()
+}
+
+// pubKeyLocked returns the validator's public key like pubKey, but expects signerMtx
+// to be held already.
+func (pv *SCFilePV) pubKeyLocked() (tm_crypto.PubKey, error) {
+	if pub := pv.cachedPubKey(); pub != nil {
+		return pub, nil
+	}
+
+	pub, err := pv.TMFilePV.GetPubKey()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get public key of the signer backend: %w", err)
+	}
+	if pub == nil {
+		return nil, errors.New("couldn't get public key of the signer backend: no key")
+	}
+
+	pv.pubKeyMtx.Lock()
+	pv.pubKeyCache = pub
+	pv.pubKeyMtx.Unlock()
+
+	return pub, nil
+}
+
+// cachedPubKey returns the cached public key, or nil if it isn't known yet.
+func (pv *SCFilePV) cachedPubKey() tm_crypto.PubKey {
+	pv.pubKeyMtx.RLock()
+	defer pv.pubKeyMtx.RUnlock()
+
+	return pv.pubKeyCache
+}
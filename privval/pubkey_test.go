@@ -0,0 +1,101 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+// downPV is a signer backend that is unavailable once down is set.
+type downPV struct {
+	tm_types.PrivValidator
+	down *int32
+}
+
+func (pv downPV) GetPubKey() (tm_crypto.PubKey, error) {
+	if atomic.LoadInt32(pv.down) == 1 {
+		return nil, errors.New("backend unavailable")
+	}
+
+	return pv.PrivValidator.GetPubKey()
+}
+
+// requirePubKeyAndPing asserts that the node answers PubKeyRequests and Pings.
+func requirePubKeyAndPing(t *testing.T, pv *SCFilePV) {
+	t.Helper()
+	resp, err := HandleRequest(context.Background(), testPubKeyRequest(t), pv)
+	require.NoError(t, err)
+	assert.Nil(t, resp.GetPubKeyResponse().Error)
+	assert.NotEmpty(t, resp.GetPubKeyResponse().PubKey.GetEd25519())
+
+	resp, err = HandleRequest(context.Background(), testPingRequest(t), pv)
+	require.NoError(t, err)
+	assert.NotNil(t, resp.GetPingResponse())
+}
+
+func TestHandlePubKeyRequest_BackendDown(t *testing.T) {
+	pv := mockSCFilePV(t)
+	var down int32
+	pv.TMFilePV = downPV{PrivValidator: pv.TMFilePV, down: &down}
+
+	// The key isn't known before the backend returned it once.
+	atomic.StoreInt32(&down, 1)
+	resp, err := HandleRequest(context.Background(), testPubKeyRequest(t), pv)
+	assert.Error(t, err)
+	assert.NotNil(t, resp.GetPubKeyResponse().Error)
+
+	atomic.StoreInt32(&down, 0)
+	requirePubKeyAndPing(t, pv)
+
+	// Once known, it's answered while the backend is unavailable.
+	atomic.StoreInt32(&down, 1)
+	requirePubKeyAndPing(t, pv)
+}
+
+func TestHandlePubKeyRequest_SigningRefused(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.Config.Slashing.PauseWhenJailed = true
+	pv.StartMaintenanceWindow(time.Hour)
+	pv.setSlashingStatus(SlashingStatus{Jailed: true, UpdatedAt: time.Unix(0, 0)})
+	atomic.StoreInt32(&pv.crashed, 1)
+
+	_, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.Error(t, err)
+	requirePubKeyAndPing(t, pv)
+}
+
+func TestHandlePubKeyRequest_DuringSwap(t *testing.T) {
+	pv := mockSCFilePV(t)
+	requirePubKeyAndPing(t, pv)
+
+	// A swap waits for the requests that are being handled, e.g. a proposal held for
+	// approval, but PubKeyRequests don't wait for the swap.
+	pv.signerMtx.RLock()
+	defer pv.signerMtx.RUnlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = pv.SwapPrivValidator(ctx, pv.TMFilePV) }()
+	time.Sleep(10 * time.Millisecond)
+
+	requirePubKeyAndPing(t, pv)
+}
+
+func TestSwapPrivValidator_BackendDown(t *testing.T) {
+	pv := mockSCFilePV(t)
+	next := pv.TMFilePV
+	var down int32
+	pv.TMFilePV = downPV{PrivValidator: next, down: &down}
+	requirePubKeyAndPing(t, pv)
+
+	// An unavailable backend can be swapped for a working one.
+	atomic.StoreInt32(&down, 1)
+	require.NoError(t, pv.SwapPrivValidator(context.Background(), next))
+	assert.Equal(t, next, pv.TMFilePV)
+}
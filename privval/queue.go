@@ -0,0 +1,171 @@
+package privval
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+)
+
+const (
+	// requestQueueSize is the number of requests read from the validator that may
+	// wait for being handled. Once the queue is full, no more requests are read, so a
+	// validator flooding requests is slowed down by the connection instead of growing
+	// the queue.
+	requestQueueSize = 16
+)
+
+var (
+	// ErrRequestShed is returned to the validator for a sign request that was
+	// superseded by a newer one for the same height, round and step before it was
+	// handled.
+	ErrRequestShed = types.NewError(types.CodeOverloaded, "request superseded by a newer one for the same height, round and step")
+
+	// errQueueClosed is the error of a request queue that was closed without a read
+	// error.
+	errQueueClosed = types.NewError(types.CodeConnLost, "request queue closed")
+)
+
+// queuedRequest is a request waiting in the request queue.
+type queuedRequest struct {
+	msg *tm_privvalproto.Message
+
+	// key identifies the sign request's height, round and step. It's empty for all
+	// other requests, which are never shed.
+	key string
+
+	// shed is true if a newer request with the same key was queued, in which case
+	// the request is answered with ErrRequestShed instead of being handled.
+	shed bool
+}
+
+// dedupKey returns the key identifying the height, round and step of the given sign
+// request, or an empty string if msg isn't a sign request.
+func dedupKey(msg *tm_privvalproto.Message) string {
+	switch msg.Sum.(type) {
+	case *tm_privvalproto.Message_SignVoteRequest, *tm_privvalproto.Message_SignProposalRequest:
+		reqData := getSharedSignRequestData(msg)
+		return fmt.Sprintf("%v/%v/%v/%v", reqData.chainID, reqData.height, reqData.round, reqData.msgType)
+	}
+
+	return ""
+}
+
+// requestQueue is the bounded queue between the goroutine reading the validator's
+// requests and the one handling them. A sign request is shed once a newer one for
+// the same height, round and step is queued, so that a validator repeating requests
+// faster than they can be signed doesn't delay the requests for the current height.
+// The responses are still written in the order of the requests.
+type requestQueue struct {
+	mtx  sync.Mutex
+	cond *sync.Cond
+	reqs []*queuedRequest
+	size int
+	err  error // set once the queue is closed
+}
+
+// newRequestQueue returns an empty queue for up to size requests.
+func newRequestQueue(size int) *requestQueue {
+	q := &requestQueue{size: size}
+	q.cond = sync.NewCond(&q.mtx)
+
+	return q
+}
+
+// push appends msg to the queue and marks the queued requests it supersedes as shed.
+// It blocks while the queue is full, and returns false if the queue was closed, in
+// which case msg isn't queued.
+func (q *requestQueue) push(msg *tm_privvalproto.Message) bool {
+	req := &queuedRequest{msg: msg, key: dedupKey(msg)}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for len(q.reqs) >= q.size && q.err == nil {
+		q.cond.Wait()
+	}
+	if q.err != nil {
+		return false
+	}
+
+	if req.key != "" {
+		for _, queued := range q.reqs {
+			if queued.key == req.key {
+				queued.shed = true
+			}
+		}
+	}
+	q.reqs = append(q.reqs, req)
+	q.cond.Broadcast()
+
+	return true
+}
+
+// pop removes and returns the oldest request. It blocks while the queue is empty, and
+// returns the error the queue was closed with once it's closed.
+func (q *requestQueue) pop() (*queuedRequest, error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for len(q.reqs) == 0 && q.err == nil {
+		q.cond.Wait()
+	}
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	req := q.reqs[0]
+	q.reqs[0] = nil
+	q.reqs = q.reqs[1:]
+	q.cond.Broadcast()
+
+	return req, nil
+}
+
+// close closes the queue with the given error, which is returned by pop from now on.
+// The requests still queued are dropped, as their responses can't be delivered
+// anymore. Only the first error is kept.
+func (q *requestQueue) close(err error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if q.err != nil {
+		return
+	}
+	q.err = err
+	q.reqs = nil
+	q.cond.Broadcast()
+}
+
+// readRequests reads the validator's requests from the connection into q until
+// reading fails, in which case q is closed with the error, or until q is closed.
+func (pv *SCFilePV) readRequests(mc *msgConn, q *requestQueue) {
+	// The handler mustn't wait for requests forever if reading panics.
+	defer q.close(errQueueClosed)
+	defer pv.recoverPanic("read")
+
+	for {
+		msg := new(tm_privvalproto.Message)
+		if err := mc.ReadMsg(msg); err != nil {
+			q.close(err)
+			return
+		}
+		if !q.push(msg) {
+			return
+		}
+	}
+}
+
+// shedRequest returns the response to a sign request that was superseded by a newer
+// one before it was handled, and counts it as dropped.
+func (pv *SCFilePV) shedRequest(msg *tm_privvalproto.Message) *tm_privvalproto.Message {
+	reqData := getSharedSignRequestData(msg)
+	err := reqData.requestError(pv, ErrRequestShed, nil)
+	pv.Logger.Debug("Shedding request: %v", err)
+	if pv.Gauges.DroppedRequestsCounter != nil {
+		pv.Gauges.DroppedRequestsCounter.Inc()
+	}
+
+	return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()})
+}
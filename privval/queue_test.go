@@ -0,0 +1,136 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/internal/leaktest"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+// queueVoteRequest returns a SignVoteRequest for a prevote at the given height.
+func queueVoteRequest(height int64) *tm_privvalproto.Message {
+	return wrapMsg(&tm_privvalproto.SignVoteRequest{
+		Vote:    &tm_typesproto.Vote{Type: tm_typesproto.PrevoteType, Height: height},
+		ChainId: "testchain",
+	})
+}
+
+func TestDedupKey(t *testing.T) {
+	assert.Equal(t, dedupKey(queueVoteRequest(1)), dedupKey(queueVoteRequest(1)))
+	assert.NotEqual(t, dedupKey(queueVoteRequest(1)), dedupKey(queueVoteRequest(2)))
+	assert.NotEqual(t, dedupKey(queueVoteRequest(1)), dedupKey(wrapMsg(&tm_privvalproto.SignProposalRequest{
+		Proposal: &tm_typesproto.Proposal{Type: tm_typesproto.ProposalType, Height: 1},
+		ChainId:  "testchain",
+	})))
+	assert.Empty(t, dedupKey(wrapMsg(&tm_privvalproto.PingRequest{})))
+}
+
+func TestRequestQueue(t *testing.T) {
+	q := newRequestQueue(4)
+	require.True(t, q.push(queueVoteRequest(1)))
+	require.True(t, q.push(wrapMsg(&tm_privvalproto.PingRequest{})))
+	require.True(t, q.push(wrapMsg(&tm_privvalproto.PingRequest{})))
+	require.True(t, q.push(queueVoteRequest(1)))
+
+	// The first vote request is superseded by the second one, but pings are never
+	// shed.
+	var shed []bool
+	for i := 0; i < 4; i++ {
+		req, err := q.pop()
+		require.NoError(t, err)
+		shed = append(shed, req.shed)
+	}
+	assert.Equal(t, []bool{true, false, false, false}, shed)
+}
+
+func TestRequestQueue_Full(t *testing.T) {
+	q := newRequestQueue(1)
+	require.True(t, q.push(queueVoteRequest(1)))
+
+	// Pushing blocks until a request was popped.
+	pushed := make(chan bool)
+	go func() { pushed <- q.push(queueVoteRequest(2)) }()
+	select {
+	case <-pushed:
+		t.Fatal("pushed to a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	req, err := q.pop()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), req.msg.GetSignVoteRequest().Vote.Height)
+	assert.True(t, <-pushed)
+
+	// Closing unblocks pushing and drops the queued requests.
+	go func() { pushed <- q.push(queueVoteRequest(3)) }()
+	readErr := errors.New("read error")
+	q.close(readErr)
+	assert.False(t, <-pushed)
+	q.close(errQueueClosed)
+	_, err = q.pop()
+	assert.Equal(t, readErr, err)
+}
+
+func TestSCFilePV_ShedRequests(t *testing.T) {
+	leaktest.Check(t)
+
+	// Block the handler on the first request until the others are queued.
+	var handled int32
+	release := make(chan struct{})
+	RegisterHandler(&tm_privvalproto.Message_SignVoteRequest{}, func(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
+		if atomic.AddInt32(&handled, 1) == 1 {
+			<-release
+		}
+		return buildResponse(msg, nil), nil
+	})
+	defer RegisterHandler(&tm_privvalproto.Message_SignVoteRequest{}, handleSignRequest)
+
+	signerConn, validatorConn := net.Pipe()
+	defer validatorConn.Close()
+
+	pv := mockSCFilePV(t)
+	pv.HTTP = nil
+	pv.CfgDir = t.TempDir()
+	pv.Gauges.DroppedRequestsCounter = prometheus.NewCounter(prometheus.CounterOpts{Name: "test_dropped_requests"})
+	pv.Dial = func(ctx context.Context) (net.Conn, error) {
+		return signerConn, nil
+	}
+	require.NoError(t, pv.Start())
+	defer func() {
+		assert.NoError(t, pv.Stop())
+		<-pv.Quit()
+	}()
+
+	w := tm_protoio.NewDelimitedWriter(validatorConn)
+	for _, height := range []int64{1, 2, 2, 3} {
+		_, err := w.WriteMsg(queueVoteRequest(height))
+		require.NoError(t, err)
+	}
+	close(release)
+
+	// The responses are written in the order of the requests, and the first request
+	// for height 2 is answered without being handled.
+	r := tm_protoio.NewDelimitedReader(validatorConn, maxRemoteSignerMsgSize)
+	var errs []bool
+	for _, height := range []int64{1, 2, 2, 3} {
+		var resp tm_privvalproto.Message
+		_, err := r.ReadMsg(&resp)
+		require.NoError(t, err)
+		require.NotNil(t, resp.GetSignedVoteResponse())
+		assert.Equal(t, height, resp.GetSignedVoteResponse().Vote.Height)
+		errs = append(errs, resp.GetSignedVoteResponse().Error != nil)
+	}
+	assert.Equal(t, []bool{false, true, false, false}, errs)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&handled))
+	assert.Equal(t, float64(1), prom_testutil.ToFloat64(pv.Gauges.DroppedRequestsCounter))
+}
@@ -0,0 +1,134 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+	"github.com/BlockscapeNetwork/signctrl/resources"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+var (
+	// ErrNoQuorum is returned if fewer RPC servers than the configured quorum confirm
+	// that a block was missed.
+	ErrNoQuorum = types.NewError(types.CodeUnverified, "missed block wasn't confirmed by a quorum of RPC servers")
+
+	// errSignedCommit is returned by an RPC server whose commit contains the
+	// validator's signature.
+	errSignedCommit = errors.New("commit is signed by the validator")
+)
+
+// CommitQuerier queries the signed header at the given height from the RPC server at
+// rpcladdr.
+type CommitQuerier func(ctx context.Context, rpcladdr string, height int64) (*tm_types.SignedHeader, error)
+
+// queryCommit is the default CommitQuerier of SCFilePV.
+func (pv *SCFilePV) queryCommit(ctx context.Context, rpcladdr string, height int64) (*tm_types.SignedHeader, error) {
+	return pv.RPC.QueryCommit(ctx, rpcladdr, height)
+}
+
+// rpcAddrs returns the addresses of the validator's RPC server and the further RPC
+// endpoints.
+func (pv *SCFilePV) rpcAddrs() []string {
+	return append([]string{pv.Config.Base.ValidatorListenAddressRPC}, pv.Config.RPC.Endpoints...)
+}
+
+// checkCommit returns nil if the commit at the given height on the RPC server at
+// rpcladdr lacks the signature of the validator with the given address.
+func (pv *SCFilePV) checkCommit(ctx context.Context, rpcladdr string, height int64, valaddr tm_types.Address) error {
+	sh, err := pv.QueryCommit(ctx, rpcladdr, height)
+	if err != nil {
+		return err
+	}
+	if sh.Commit == nil {
+		return fmt.Errorf("no commit for height %v", height)
+	}
+	if pv.Adapter.HasSignedCommit(valaddr, &tm_types.Block{LastCommit: sh.Commit}) {
+		return errSignedCommit
+	}
+
+	return nil
+}
+
+// confirmMissed confirms that the validator with the given address didn't sign the
+// commit at the given height with the RPC servers. The commit is queried from all of
+// them by a pool of at most max_parallel_queries workers, and nil is returned once
+// quorum of them lack the validator's signature. The remaining queries are canceled
+// as soon as the outcome is decided, so the confirmation takes about as long as the
+// slowest query that was needed, no matter how many RPC servers there are. It always
+// succeeds if no quorum is configured.
+func (pv *SCFilePV) confirmMissed(ctx context.Context, height int64, valaddr tm_types.Address) error {
+	quorum := pv.Config.RPC.Quorum
+	if quorum == 0 {
+		return nil
+	}
+
+	// The remaining queries are canceled and their workers waited for once the
+	// outcome is decided.
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(ctx)
+	defer wg.Wait()
+	defer cancel()
+
+	// Both channels are buffered, so that the workers never block on them, even
+	// after the outcome was decided.
+	addrs := pv.rpcAddrs()
+	jobs := make(chan string, len(addrs))
+	for _, addr := range addrs {
+		jobs <- addr
+	}
+	close(jobs)
+	type result struct {
+		addr string
+		err  error
+	}
+	results := make(chan result, len(addrs))
+
+	// Without an explicit limit, the pool is sized by the CPUs SignCTRL may use.
+	workers := pv.Config.RPC.GetMaxParallelQueries()
+	if pv.Config.RPC.MaxParallelQueries == 0 {
+		workers = resources.PoolSize(workers)
+	}
+	if workers > len(addrs) {
+		workers = len(addrs)
+	}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		goroutines.Go("quorum", func() {
+			defer wg.Done()
+			for addr := range jobs {
+				if err := ctx.Err(); err != nil {
+					results <- result{addr, err}
+					continue
+				}
+				results <- result{addr, pv.checkCommit(ctx, addr, height, valaddr)}
+			}
+		})
+	}
+
+	var confirmed int
+	var reasons []string
+	for remaining := len(addrs); remaining > 0; {
+		res := <-results
+		remaining--
+		if res.err == nil {
+			confirmed++
+		} else {
+			reasons = append(reasons, fmt.Sprintf("%v: %v", res.addr, res.err))
+		}
+
+		if confirmed >= quorum {
+			return nil
+		}
+		if confirmed+remaining < quorum {
+			break
+		}
+	}
+
+	return fmt.Errorf("%w (%v/%v): %v", ErrNoQuorum, confirmed, quorum, strings.Join(reasons, "; "))
+}
@@ -0,0 +1,156 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+// testCommit returns a signed header at the given height whose commit is signed by the
+// validators with the given addresses.
+func testCommit(height int64, signers ...tm_types.Address) *tm_types.SignedHeader {
+	sigs := make([]tm_types.CommitSig, len(signers))
+	for i, addr := range signers {
+		sigs[i] = tm_types.CommitSig{BlockIDFlag: tm_types.BlockIDFlagCommit, ValidatorAddress: addr}
+	}
+
+	return &tm_types.SignedHeader{Commit: &tm_types.Commit{Height: height, Signatures: sigs}}
+}
+
+// quorumSCFilePV returns an SCFilePV with the given RPC servers besides the validator's
+// own, of which quorum must confirm missed blocks.
+func quorumSCFilePV(t *testing.T, quorum int, endpoints ...string) *SCFilePV {
+	t.Helper()
+	pv := mockSCFilePV(t)
+	pv.HTTP = nil
+	dir := t.TempDir()
+	pv.TMFilePV = tm_privval.GenFilePV(filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile))
+	pv.Config.RPC.Endpoints = endpoints
+	pv.Config.RPC.Quorum = quorum
+
+	return pv
+}
+
+func TestConfirmMissed(t *testing.T) {
+	valaddr := tm_types.Address("validator")
+	other := tm_types.Address("other")
+
+	// The confirmation is disabled without a quorum.
+	pv := quorumSCFilePV(t, 0)
+	pv.QueryCommit = func(ctx context.Context, rpcladdr string, height int64) (*tm_types.SignedHeader, error) {
+		t.Fatal("commit queried without a quorum")
+		return nil, nil
+	}
+	assert.NoError(t, pv.confirmMissed(context.Background(), 10, valaddr))
+
+	// Two of three RPC servers confirm the missed block.
+	pv = quorumSCFilePV(t, 2, "tcp://10.0.0.2:26657", "tcp://10.0.0.3:26657")
+	pv.QueryCommit = func(ctx context.Context, rpcladdr string, height int64) (*tm_types.SignedHeader, error) {
+		assert.Equal(t, int64(10), height)
+		if rpcladdr == "tcp://10.0.0.3:26657" {
+			return testCommit(height, valaddr), nil
+		}
+		return testCommit(height, other), nil
+	}
+	assert.NoError(t, pv.confirmMissed(context.Background(), 10, valaddr))
+
+	// One of them is signed and one fails, so there's no quorum.
+	pv.QueryCommit = func(ctx context.Context, rpcladdr string, height int64) (*tm_types.SignedHeader, error) {
+		switch rpcladdr {
+		case "tcp://10.0.0.2:26657":
+			return nil, errors.New("connection refused")
+		case "tcp://10.0.0.3:26657":
+			return testCommit(height, valaddr), nil
+		}
+		return testCommit(height, other), nil
+	}
+	err := pv.confirmMissed(context.Background(), 10, valaddr)
+	assert.ErrorIs(t, err, ErrNoQuorum)
+	assert.Contains(t, err.Error(), "(1/2)")
+	assert.Contains(t, err.Error(), "connection refused")
+}
+
+func TestConfirmMissed_MaxParallelQueries(t *testing.T) {
+	pv := quorumSCFilePV(t, 5, "tcp://10.0.0.2:26657", "tcp://10.0.0.3:26657", "tcp://10.0.0.4:26657", "tcp://10.0.0.5:26657")
+	pv.Config.RPC.MaxParallelQueries = 2
+
+	var inFlight, maxInFlight int32
+	pv.QueryCommit = func(ctx context.Context, rpcladdr string, height int64) (*tm_types.SignedHeader, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return testCommit(height), nil
+	}
+	assert.NoError(t, pv.confirmMissed(context.Background(), 10, tm_types.Address("validator")))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&maxInFlight))
+}
+
+func TestConfirmMissed_CancelsRemainingQueries(t *testing.T) {
+	pv := quorumSCFilePV(t, 1, "tcp://10.0.0.2:26657", "tcp://10.0.0.3:26657")
+
+	// The slow RPC servers aren't waited for once the validator's own confirmed the
+	// missed block, and their queries are canceled.
+	var started, canceled int32
+	pv.QueryCommit = func(ctx context.Context, rpcladdr string, height int64) (*tm_types.SignedHeader, error) {
+		if rpcladdr == pv.Config.Base.ValidatorListenAddressRPC {
+			return testCommit(height), nil
+		}
+		atomic.AddInt32(&started, 1)
+		<-ctx.Done()
+		atomic.AddInt32(&canceled, 1)
+		return nil, ctx.Err()
+	}
+	require.NoError(t, pv.confirmMissed(context.Background(), 10, tm_types.Address("validator")))
+	assert.Equal(t, atomic.LoadInt32(&started), atomic.LoadInt32(&canceled))
+}
+
+func TestHandleSignRequest_MissedBlockNotConfirmed(t *testing.T) {
+	pv := quorumSCFilePV(t, 2, "tcp://10.0.0.2:26657")
+	pv.UnlockCounter()
+	pub, err := pv.TMFilePV.GetPubKey()
+	require.NoError(t, err)
+
+	// The validator's RPC server claims the block was missed, while the endpoint has
+	// the validator's signature.
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		return &tm_coretypes.ResultBlock{Block: &tm_types.Block{
+			Header:     tm_types.Header{Height: height},
+			LastCommit: testCommit(height - 1).Commit,
+		}}, nil
+	}
+	pv.QueryCommit = func(ctx context.Context, rpcladdr string, height int64) (*tm_types.SignedHeader, error) {
+		if rpcladdr == pv.Config.Base.ValidatorListenAddressRPC {
+			return testCommit(height), nil
+		}
+		return testCommit(height, pub.Address()), nil
+	}
+
+	_, err = HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, pv.GetMissedInARow())
+
+	// Once both confirm it, it is counted.
+	pv.QueryCommit = func(ctx context.Context, rpcladdr string, height int64) (*tm_types.SignedHeader, error) {
+		return testCommit(height), nil
+	}
+	req := testSignVoteRequest(t)
+	req.GetSignVoteRequest().Vote.Height++
+	_, err = HandleRequest(context.Background(), req, pv)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pv.GetMissedInARow())
+}
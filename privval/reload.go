@@ -0,0 +1,148 @@
+package privval
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/hashicorp/logutils"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+)
+
+// ReloadResponse defines the response JSON for reload requests.
+type ReloadResponse struct {
+	// Applied lists the settings that were changed, e.g. "threshold: 10 -> 12".
+	Applied []string `json:"applied"`
+
+	// RestartRequired is true if further settings were changed, which only take
+	// effect after a restart.
+	RestartRequired bool `json:"restart_required"`
+}
+
+// setRetryDialAfter sets the configured time without a message from the validator
+// after which it is dialed again.
+func (pv *SCFilePV) setRetryDialAfter(d time.Duration) {
+	atomic.StoreInt64(&pv.retryDialAfterCfg, int64(d))
+}
+
+// getRetryDialAfter returns the configured time without a message from the validator
+// after which it is dialed again.
+func (pv *SCFilePV) getRetryDialAfter() time.Duration {
+	return time.Duration(atomic.LoadInt64(&pv.retryDialAfterCfg))
+}
+
+// Reload reads the configuration file in the configuration directory again and
+// applies the settings that can be changed without dropping the connection to the
+// validator, see ApplyConfig. The running configuration is kept if the file is
+// invalid. If the conn.key was rotated, the validator is dialed again with the new
+// key.
+func (pv *SCFilePV) Reload() (ReloadResponse, error) {
+	cfg, err := config.LoadFrom(pv.CfgDir)
+	if err != nil {
+		return ReloadResponse{}, fmt.Errorf("couldn't reload %v: %w", config.File, err)
+	}
+
+	resp := pv.ApplyConfig(cfg)
+	if pv.reloadConnKey() {
+		resp.Applied = append(resp.Applied, fmt.Sprintf("%v: re-handshaking with the new key", connection.KeyFile))
+	}
+
+	return resp, nil
+}
+
+// ApplyConfig applies the settings of cfg that can be changed while SignCTRL is
+// running: the log level, the threshold and threshold_duration, retry_dial_after
+// and the feature flags. The threshold must be changed on all validators in the set.
+// Changes to any other settings are only reported, as they require a restart.
+func (pv *SCFilePV) ApplyConfig(cfg config.Config) ReloadResponse {
+	var resp ReloadResponse
+	applied := func(setting string, from, to interface{}) {
+		change := fmt.Sprintf("%v: %v -> %v", setting, from, to)
+		resp.Applied = append(resp.Applied, change)
+		pv.Logger.Info("Reloaded %v", change)
+	}
+
+	if pv.LogFilter != nil {
+		if from, to := pv.LogFilter.MinLevel(), logutils.LogLevel(cfg.Base.LogLevel); from != to {
+			pv.LogFilter.SetMinLevel(to)
+			applied("log_level", from, to)
+		}
+	}
+	if from, to := pv.GetThreshold(), cfg.Base.Threshold; from != to {
+		pv.SetThreshold(to)
+		applied("threshold", from, to)
+	}
+	if from, to := pv.GetThresholdDuration(), cfg.Base.GetThresholdDuration(); from != to {
+		pv.SetThresholdDuration(to)
+		applied("threshold_duration", from, to)
+	}
+	if from, to := pv.getRetryDialAfter(), cfg.Base.GetRetryDialAfter(); from != to {
+		pv.setRetryDialAfter(to)
+		applied("retry_dial_after", from, to)
+	}
+	for _, f := range pv.Features.Update(cfg.Features) {
+		state := "disabled"
+		if pv.Features.Enabled(f) {
+			state = "enabled"
+		}
+		resp.Applied = append(resp.Applied, fmt.Sprintf("feature %v: %v", f, state))
+		pv.Logger.Info("Feature %v is now %v", f, state)
+	}
+
+	// Compare the rest of the configuration with the one SignCTRL was started with.
+	rest := cfg
+	rest.Base.LogLevel = pv.Config.Base.LogLevel
+	rest.Base.Threshold = pv.Config.Base.Threshold
+	rest.Base.ThresholdDuration = pv.Config.Base.ThresholdDuration
+	rest.Base.RetryDialAfter = pv.Config.Base.RetryDialAfter
+	rest.Features = pv.Config.Features
+	if !reflect.DeepEqual(rest, pv.Config) {
+		resp.RestartRequired = true
+		pv.Logger.Warn("Further settings in %v were changed, which only take effect after a restart", config.File)
+	}
+
+	return resp
+}
+
+// reloadHandler reloads the configuration file on POST requests. Only requests from
+// the loopback interface with a valid token are accepted.
+func (pv *SCFilePV) reloadHandler(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !pv.checkAdmin(rw, r, false) {
+		return
+	}
+
+	resp, err := pv.Reload()
+	if err != nil {
+		httpError(rw, err, http.StatusBadRequest)
+		return
+	}
+	bytes, err := tm_json.Marshal(resp)
+	if err != nil {
+		httpError(rw, err, http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(bytes)
+}
+
+// ReloadConfig requests the given instance, or the default validator if name is
+// empty, to reload its configuration file.
+func ReloadConfig(name string, creds AdminCredentials) (*ReloadResponse, error) {
+	req, err := http.NewRequest(http.MethodPost, localURL(instancePath(name, "/admin/reload")), nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp ReloadResponse
+	if err := doAdminRequestInto(req, creds, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
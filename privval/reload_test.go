@@ -0,0 +1,78 @@
+package privval
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/features"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/hashicorp/logutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyConfig(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.LogFilter = types.NewLevelFilter("INFO", ioutil.Discard)
+	assert.Equal(t, 15*time.Second, pv.retryDialAfter())
+
+	// Nothing changed.
+	resp := pv.ApplyConfig(testConfig(t))
+	assert.Empty(t, resp.Applied)
+	assert.False(t, resp.RestartRequired)
+
+	cfg := testConfig(t)
+	cfg.Base.LogLevel = "DEBUG"
+	cfg.Base.Threshold = 12
+	cfg.Base.ThresholdDuration = "2m"
+	cfg.Base.RetryDialAfter = "30s"
+	cfg.Features.CircularDemotion = true
+	resp = pv.ApplyConfig(cfg)
+	assert.Equal(t, []string{
+		"log_level: INFO -> DEBUG",
+		"threshold: 10 -> 12",
+		"threshold_duration: 0s -> 2m0s",
+		"retry_dial_after: 15s -> 30s",
+		"feature circular_demotion: enabled",
+	}, resp.Applied)
+	assert.False(t, resp.RestartRequired)
+	assert.Equal(t, logutils.LogLevel("DEBUG"), pv.LogFilter.MinLevel())
+	assert.Equal(t, 12, pv.GetThreshold())
+	assert.Equal(t, 2*time.Minute, pv.GetThresholdDuration())
+	assert.Equal(t, 30*time.Second, pv.retryDialAfter())
+	assert.True(t, pv.Features.Enabled(features.CircularDemotion))
+
+	// Any other setting requires a restart and isn't applied.
+	cfg.Base.SetSize = 3
+	resp = pv.ApplyConfig(cfg)
+	assert.Empty(t, resp.Applied)
+	assert.True(t, resp.RestartRequired)
+	assert.Equal(t, 2, pv.Config.Base.SetSize)
+}
+
+func TestReload_InvalidConfig(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.CfgDir = t.TempDir()
+
+	_, err := pv.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, 10, pv.GetThreshold())
+}
+
+func TestReloadHandler(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.CfgDir = t.TempDir()
+
+	rec := httptest.NewRecorder()
+	pv.reloadHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/reload", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	// The configuration file doesn't exist.
+	req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec = httptest.NewRecorder()
+	pv.reloadHandler(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -1,13 +1,16 @@
 package privval
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"sync"
 
-	"github.com/BlockscapeNetwork/signctrl/rpc"
+	"github.com/BlockscapeNetwork/signctrl/adapters"
+	"github.com/BlockscapeNetwork/signctrl/features"
 	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
 	"github.com/gogo/protobuf/proto"
 	tm_cryptoenc "github.com/tendermint/tendermint/crypto/encoding"
 	tm_cryptoproto "github.com/tendermint/tendermint/proto/tendermint/crypto"
@@ -19,9 +22,77 @@ import (
 var (
 	// ErrRankObsolete is returned if the requested vote height is too far ahead of the last
 	// block the validator signed. The gap must be at least {threshold} blocks.
-	ErrRankObsolete = errors.New("at least one threshold was exceeded between requested vote height and last_signed_height")
+	ErrRankObsolete = types.NewError(types.CodeRankObsolete, "at least one threshold was exceeded between requested vote height and last_signed_height")
+
+	// ErrWrongChainID is returned if a request is for a different chain ID than the one
+	// specified in the config.toml.
+	ErrWrongChainID = types.NewError(types.CodeBadRequest, "request is for the wrong chain ID")
+
+	// ErrNoSigningPermission is returned if a sign request is received by a node that is
+	// not ranked first in the set.
+	ErrNoSigningPermission = types.NewError(types.CodeNoPermission, "no signing permission")
+
+	// ErrSigningFailed is returned if the underlying private validator fails to sign a
+	// vote or proposal.
+	ErrSigningFailed = types.NewError(types.CodeSigningFailed, "failed to sign")
+
+	// ErrUnknownMessage is returned if a message of unknown type is received.
+	ErrUnknownMessage = types.NewError(types.CodeBadRequest, "unknown message")
+
+	// ErrMalformedRequest is returned if a sign request is missing its vote or
+	// proposal, or contains values that can never be signed.
+	ErrMalformedRequest = types.NewError(types.CodeBadRequest, "malformed sign request")
 )
 
+// RequestError wraps the errors returned while handling requests from the validator
+// with the request's context. Err is one of the sentinel errors of this package and
+// can be checked with errors.Is, while Cause holds the underlying error (if any) and
+// is reachable via errors.Unwrap.
+type RequestError struct {
+	Request string
+	Height  int64
+	Rank    int
+	Err     error
+	Cause   error
+}
+
+// Error returns the string representation of the error.
+func (e *RequestError) Error() string {
+	switch {
+	case e.Err == nil:
+		return fmt.Sprintf("%v: %v (height: %v, rank: %v)", e.Request, e.Cause, e.Height, e.Rank)
+	case e.Cause == nil:
+		return fmt.Sprintf("%v: %v (height: %v, rank: %v)", e.Request, e.Err, e.Height, e.Rank)
+	}
+
+	return fmt.Sprintf("%v: %v: %v (height: %v, rank: %v)", e.Request, e.Err, e.Cause, e.Height, e.Rank)
+}
+
+// Is reports whether target is the sentinel error wrapped by e.
+func (e *RequestError) Is(target error) bool {
+	return e.Err != nil && target == e.Err
+}
+
+// Unwrap returns the underlying cause of the error, or the sentinel error if there is
+// no cause.
+func (e *RequestError) Unwrap() error {
+	if e.Cause != nil {
+		return e.Cause
+	}
+
+	return e.Err
+}
+
+// ErrorCode returns the code of the sentinel error, or of the cause if there is no
+// sentinel error.
+func (e *RequestError) ErrorCode() types.ErrorCode {
+	if e.Err != nil {
+		return types.ErrorCodeOf(e.Err)
+	}
+
+	return types.ErrorCodeOf(e.Cause)
+}
+
 // wrapMsg wraps a protobuf message into a privval proto message.
 func wrapMsg(pb proto.Message) *tm_privvalproto.Message {
 	msg := tm_privvalproto.Message{}
@@ -51,18 +122,6 @@ func wrapMsg(pb proto.Message) *tm_privvalproto.Message {
 	return &msg
 }
 
-// hasSignedCommit checks whether the given validator address has a commitsig
-// in the provided commitsigs.
-func hasSignedCommit(valaddr tm_types.Address, commitsigs *[]tm_types.CommitSig) bool {
-	for _, commitsig := range *commitsigs {
-		if cmp := bytes.Compare(commitsig.ValidatorAddress, valaddr); cmp == 0 {
-			return true
-		}
-	}
-
-	return false
-}
-
 // isRankUpToDate checks whether the validator's rank is still up to date or obsolete.
 func isRankUpToDate(reqHeight int64, lastHeight int64, threshold int) bool {
 	return reqHeight-lastHeight < int64(threshold+1)
@@ -83,22 +142,30 @@ func handlePubKeyRequest(req *tm_privvalproto.PubKeyRequest, pv *SCFilePV) (*tm_
 	// Check if the PubKeyRequest is for the chain ID specified
 	// in the config.toml.
 	if req.GetChainId() != pv.Config.Privval.ChainID {
-		err := fmt.Errorf("expected PubKeyRequest for chain ID '%v', instead got '%v'", pv.Config.Privval.ChainID, req.GetChainId())
+		pv.rejectWrongChainID("pubkey", req.GetChainId())
+		err := &RequestError{
+			Request: "PubKeyRequest",
+			Height:  pv.GetCurrentHeight(),
+			Rank:    pv.GetRank(),
+			Err:     ErrWrongChainID,
+			Cause:   fmt.Errorf("expected chain ID '%v', instead got '%v'", pv.Config.Privval.ChainID, req.GetChainId()),
+		}
 		return wrapMsg(&tm_privvalproto.PubKeyResponse{
 			PubKey: tm_cryptoproto.PublicKey{},
 			Error:  &tm_privvalproto.RemoteSignerError{Description: err.Error()},
 		}), err
 	}
 
-	// GetPubKey never returns an error since the pubkey returned
-	// is never nil.
-	pubkey, _ := pv.TMFilePV.GetPubKey()
+	// The public key is answered regardless of whether the node may sign, e.g. during
+	// maintenance windows or while signing is paused, so that the validator keeps the
+	// connection alive. Only sign requests are refused.
+	pubkey, err := pv.pubKey()
+	if err != nil {
+		return pubKeyErrorResponse(pv, err)
+	}
 	pbEncPub, err := tm_cryptoenc.PubKeyToProto(pubkey)
 	if err != nil {
-		return wrapMsg(&tm_privvalproto.PubKeyResponse{
-			PubKey: tm_cryptoproto.PublicKey{},
-			Error:  &tm_privvalproto.RemoteSignerError{Description: err.Error()},
-		}), err
+		return pubKeyErrorResponse(pv, err)
 	}
 
 	return wrapMsg(&tm_privvalproto.PubKeyResponse{
@@ -107,11 +174,40 @@ func handlePubKeyRequest(req *tm_privvalproto.PubKeyRequest, pv *SCFilePV) (*tm_
 	}), nil
 }
 
+// pubKeyErrorResponse wraps the given cause into a RequestError and returns it along
+// with the PubKeyResponse carrying it.
+func pubKeyErrorResponse(pv *SCFilePV, cause error) (*tm_privvalproto.Message, error) {
+	err := &RequestError{
+		Request: "PubKeyRequest",
+		Height:  pv.GetCurrentHeight(),
+		Rank:    pv.GetRank(),
+		Cause:   cause,
+	}
+
+	return wrapMsg(&tm_privvalproto.PubKeyResponse{
+		PubKey: tm_cryptoproto.PublicKey{},
+		Error:  &tm_privvalproto.RemoteSignerError{Description: err.Error()},
+	}), err
+}
+
 // sharedSignRequestData defines data shared between votes and proposals.
 type sharedSignRequestData struct {
 	chainID string
 	msgType tm_typesproto.SignedMsgType
 	height  int64
+	round   int32
+}
+
+// requestError wraps the given sentinel error and cause into a RequestError for the
+// sign request described by reqData.
+func (reqData sharedSignRequestData) requestError(pv *SCFilePV, err error, cause error) error {
+	return &RequestError{
+		Request: reqData.msgType.String(),
+		Height:  reqData.height,
+		Rank:    pv.GetRank(),
+		Err:     err,
+		Cause:   cause,
+	}
 }
 
 // getSharedSignRequestData returns shared sign request data.
@@ -119,33 +215,77 @@ func getSharedSignRequestData(msg *tm_privvalproto.Message) (data sharedSignRequ
 	switch msg.Sum.(type) {
 	case *tm_privvalproto.Message_SignVoteRequest:
 		req := msg.GetSignVoteRequest()
-		data.chainID = req.ChainId
-		data.msgType = req.Vote.Type
-		data.height = req.Vote.Height
+		data.chainID = req.GetChainId()
+		data.msgType = req.GetVote().GetType()
+		data.height = req.GetVote().GetHeight()
+		data.round = req.GetVote().GetRound()
 
 	case *tm_privvalproto.Message_SignProposalRequest:
 		req := msg.GetSignProposalRequest()
-		data.chainID = req.ChainId
-		data.msgType = req.Proposal.Type
-		data.height = req.Proposal.Height
+		data.chainID = req.GetChainId()
+		data.msgType = req.GetProposal().GetType()
+		data.height = req.GetProposal().GetHeight()
+		data.round = req.GetProposal().GetRound()
 	}
 
 	return data
 }
 
+// validateSignRequest checks whether the given sign request carries a vote or
+// proposal that can be signed. The validator's messages are processed by the
+// underlying private validator, which panics on some malformed inputs, so they must
+// be rejected beforehand.
+func validateSignRequest(msg *tm_privvalproto.Message, adapter adapters.Adapter) error {
+	switch msg.Sum.(type) {
+	case *tm_privvalproto.Message_SignVoteRequest:
+		vote := msg.GetSignVoteRequest().GetVote()
+		if vote == nil {
+			return errors.New("missing vote")
+		}
+		if !adapter.IsValidVoteType(vote.Type) {
+			return fmt.Errorf("invalid vote type: %v", vote.Type)
+		}
+		if vote.Height < 0 || vote.Round < 0 {
+			return fmt.Errorf("negative height or round: %v/%v", vote.Height, vote.Round)
+		}
+
+	case *tm_privvalproto.Message_SignProposalRequest:
+		proposal := msg.GetSignProposalRequest().GetProposal()
+		if proposal == nil {
+			return errors.New("missing proposal")
+		}
+		if proposal.Type != tm_typesproto.ProposalType {
+			return fmt.Errorf("invalid proposal type: %v", proposal.Type)
+		}
+		if proposal.Height < 0 || proposal.Round < 0 {
+			return fmt.Errorf("negative height or round: %v/%v", proposal.Height, proposal.Round)
+		}
+	}
+
+	return nil
+}
+
 // buildResponse builds a response for the given message. The message must wrap
 // either a SignVoteRequest or a SignProposalRequest.
 func buildResponse(msg *tm_privvalproto.Message, rse *tm_privvalproto.RemoteSignerError) *tm_privvalproto.Message {
 	switch msg.Sum.(type) {
 	case *tm_privvalproto.Message_SignVoteRequest:
+		var vote tm_typesproto.Vote
+		if v := msg.GetSignVoteRequest().GetVote(); v != nil {
+			vote = *v
+		}
 		return wrapMsg(&tm_privvalproto.SignedVoteResponse{
-			Vote:  *msg.GetSignVoteRequest().GetVote(),
+			Vote:  vote,
 			Error: rse,
 		})
 
 	case *tm_privvalproto.Message_SignProposalRequest:
+		var proposal tm_typesproto.Proposal
+		if p := msg.GetSignProposalRequest().GetProposal(); p != nil {
+			proposal = *p
+		}
 		return wrapMsg(&tm_privvalproto.SignedProposalResponse{
-			Proposal: *msg.GetSignProposalRequest().GetProposal(),
+			Proposal: proposal,
 			Error:    rse,
 		})
 	}
@@ -155,7 +295,14 @@ func buildResponse(msg *tm_privvalproto.Message, rse *tm_privvalproto.RemoteSign
 
 // handleSignRequest handles SignVoteRequests and SignProposalRequests by
 // returning either a SignedVoteResponse or a SignedProposalResponse.
-func handleSignRequest(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
+func handleSignRequest(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (resp *tm_privvalproto.Message, err error) {
+	start := pv.Clock.Now()
+	pv.stale.request(start)
+
+	// Don't let the signer backend be swapped while the request is handled.
+	pv.signerMtx.RLock()
+	defer pv.signerMtx.RUnlock()
+
 	switch msg.Sum.(type) {
 	case *tm_privvalproto.Message_SignVoteRequest:
 		pv.Logger.Debug("Received SignVoteRequest: %v", msg.GetSignVoteRequest())
@@ -166,53 +313,95 @@ func handleSignRequest(ctx context.Context, msg *tm_privvalproto.Message, pv *SC
 	// Extract data shared between vote and proposal requests.
 	reqData := getSharedSignRequestData(msg)
 
+	// Track the request's latency against the SLO, including waiting for the lock.
+	steps := &signSteps{last: start}
+	defer func() {
+		pv.observeSignLatency(reqData, start, steps, err)
+		pv.observeSignRequest(reqData, pv.Clock.Now().Sub(start), err)
+		pv.updateRankGauges()
+		pv.recordRefusal(reqData, err)
+	}()
+
+	// Reject requests that can't be signed before touching any state.
+	if err := validateSignRequest(msg, pv.Adapter); err != nil {
+		err := reqData.requestError(pv, ErrMalformedRequest, err)
+		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+	}
+
 	// Check if the request is for the chain ID specified in the config.toml.
 	if reqData.chainID != pv.Config.Privval.ChainID {
-		err := fmt.Errorf("expected sign request for chain ID '%v', instead got '%v'", pv.Config.Privval.ChainID, reqData.chainID)
+		pv.rejectWrongChainID(msgTypeLabel(reqData.msgType), reqData.chainID)
+		err := reqData.requestError(pv, ErrWrongChainID, fmt.Errorf("expected chain ID '%v', instead got '%v'", pv.Config.Privval.ChainID, reqData.chainID))
 		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
 	}
 
 	// If the requested height is at least {threshold}+1 higher than last_signed_height,
 	// the node's rank has become obsolete due to a rank update in the set.
-	if !isRankUpToDate(reqData.height, pv.State.LastHeight, pv.GetThreshold()) {
-		pv.Logger.Debug("The requested height differs too much from the last height (%v - %v >= %v)", reqData.height, pv.State.LastHeight, pv.GetThreshold()+1)
-		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: ErrRankObsolete.Error()}), ErrRankObsolete
+	if lastHeight := pv.getLastHeight(); !isRankUpToDate(reqData.height, lastHeight, pv.GetThreshold()) {
+		pv.Logger.Debug("The requested height differs too much from the last height (%v - %v >= %v)", reqData.height, lastHeight, pv.GetThreshold()+1)
+		pv.markRankObsolete()
+		err := reqData.requestError(pv, ErrRankObsolete, nil)
+		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
 	}
 
-	// Only check the commitsigs once for each block height.
-	// Also, only start checking for block heights greater than 1.
-	// This is due to the genesis block not having any commitsigs.
-	if reqData.height > pv.BaseSignCtrled.GetCurrentHeight() && reqData.height > 1 {
-		// Get block information from the validator's /block endpoint.
-		rb, err := rpc.QueryBlock(ctx, pv.Config.Base.ValidatorListenAddressRPC, reqData.height-1, pv.Logger)
-		if err != nil {
+	// Check the block's commitsigs, unless the commit watcher already did.
+	checked, err := pv.checkBlock(ctx, reqData.height)
+	if err != nil {
+		if errors.Is(err, types.ErrMustShutdown) {
 			return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
 		}
-
-		// Update the current height to the height of the request.
-		pv.BaseSignCtrled.SetCurrentHeight(reqData.height)
-		pv.State.LastHeight = reqData.height
-
-		// Check if the commitsigs in the block are signed by the validator.
-		pub, _ := pv.TMFilePV.GetPubKey()
-		if !hasSignedCommit(pub.Address(), &rb.Block.LastCommit.Signatures) {
-			// Check if the threshold of too many missed blocks in a row is exceeded.
-			if err := pv.Missed(); err != nil {
-				if err == types.ErrMustShutdown {
-					return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
-				}
-			}
-		} else {
-			// If the commit was signed, reset the counter for missed blocks in a row
-			// and unlock it if it hasn't already been unlocked.
-			pv.Reset()
-			pv.UnlockCounter()
-		}
+		err := reqData.requestError(pv, nil, err)
+		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
 	}
+	if checked {
+		steps.done("block", pv.Clock.Now())
+	}
+	pv.setLastRequest(reqData)
 
 	// Prevent the node from signing if it's not ranked first in the set.
 	if pv.GetRank() > 1 {
-		err := fmt.Errorf("no signing permission for %v on block height %v (rank: %v)", reqData.msgType, reqData.height, pv.GetRank())
+		err := reqData.requestError(pv, ErrNoSigningPermission, nil)
+		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+	}
+
+	// Only sign while holding the signing lock, if any, so that a node that lost its
+	// lease stops signing right away.
+	if err := pv.acquireLock(ctx); err != nil {
+		err := reqData.requestError(pv, ErrLockNotHeld, err)
+		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+	}
+
+	// Stop signing once another node took the signing authority in a higher epoch,
+	// even if the node still believes it's ranked first.
+	if err := pv.checkEpoch(); err != nil {
+		err := reqData.requestError(pv, ErrFenced, err)
+		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+	}
+
+	// Don't sign anything if the request was canceled in the meantime, e.g. due to the
+	// service being stopped.
+	if err := ctx.Err(); err != nil {
+		err := reqData.requestError(pv, nil, err)
+		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+	}
+
+	// Never sign anything after recovering from a panic, as the node's state can't be
+	// trusted anymore.
+	if pv.IsCrashed() {
+		err := reqData.requestError(pv, ErrCrashed, nil)
+		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+	}
+
+	// Signatures of a tombstoned validator are useless, so don't sign anything.
+	if pv.IsTombstoned() {
+		err := reqData.requestError(pv, ErrTombstoned, nil)
+		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+	}
+
+	// Signatures of a jailed validator can't make it into a block, so don't sign
+	// anything while signing is paused.
+	if pv.IsSigningPaused() {
+		err := reqData.requestError(pv, ErrJailed, nil)
 		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
 	}
 
@@ -220,42 +409,251 @@ func handleSignRequest(ctx context.Context, msg *tm_privvalproto.Message, pv *SC
 	case *tm_privvalproto.Message_SignVoteRequest:
 		req := msg.GetSignVoteRequest()
 
+		// Never sign anything at or below the high watermark, even if the signer
+		// backend's own state doesn't protect against it.
+		w := Watermark{Height: req.Vote.Height, Round: req.Vote.Round, Step: voteStep(req.Vote.Type)}
+		if err := pv.RaiseWatermark(w, voteSignBytes(pv.Config.Privval.ChainID, req.Vote)); err != nil {
+			err := reqData.requestError(pv, nil, err)
+			return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+		}
+
 		// The node has permission to sign the vote, so sign it.
-		if err := pv.TMFilePV.SignVote(pv.Config.Privval.ChainID, req.Vote); err != nil {
-			err := fmt.Errorf("failed to sign %v for block height %v: %v", req.Vote.Type, req.Vote.Height, err)
+		err := signVote(ctx, pv.TMFilePV, pv.Config.Privval.ChainID, req.Vote)
+		steps.done("sign", pv.Clock.Now())
+		if err != nil {
+			err := reqData.requestError(pv, ErrSigningFailed, err)
 			return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
 		}
 
+		pv.updateStateMAC()
+		pv.exportTmkmsWatermark(w)
+
+		// Never send a signature the network would drop.
+		if err := pv.verifySignature(tm_types.VoteSignBytes(pv.Config.Privval.ChainID, req.Vote), req.Vote.Signature); err != nil {
+			pv.reportBadSignature(reqData, err)
+			req.Vote.Signature = nil
+			err := reqData.requestError(pv, ErrBadSignature, err)
+			return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+		}
+		pv.setLastSigned(w)
 		pv.Logger.Info("Signed %v for block height %v", req.Vote.Type, req.Vote.Height)
 		return buildResponse(wrapMsg(&tm_privvalproto.SignVoteRequest{Vote: req.Vote, ChainId: req.GetChainId()}), nil), nil
 
 	case *tm_privvalproto.Message_SignProposalRequest:
 		req := msg.GetSignProposalRequest()
 
+		// Hold the proposal until a second operator approves it, if required. Proposals
+		// that weren't approved get a nil response, i.e. one without a proposal or an
+		// error.
+		if pv.Config.Privval.RequiresProposalApproval() {
+			err := pv.awaitApproval(ctx, req.Proposal)
+			steps.hold("approval", pv.Clock.Now())
+			if errors.Is(err, ErrProposalNotApproved) || errors.Is(err, ErrProposalRejected) {
+				return wrapMsg(&tm_privvalproto.SignedProposalResponse{}), reqData.requestError(pv, err, nil)
+			} else if err != nil {
+				err := reqData.requestError(pv, err, nil)
+				return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+			}
+		}
+
+		// Never sign anything at or below the high watermark, even if the signer
+		// backend's own state doesn't protect against it.
+		w := Watermark{Height: req.Proposal.Height, Round: req.Proposal.Round, Step: stepPropose}
+		if err := pv.RaiseWatermark(w, proposalSignBytes(pv.Config.Privval.ChainID, req.Proposal)); err != nil {
+			err := reqData.requestError(pv, nil, err)
+			return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+		}
+
 		// The node has permission to sign the proposal, so sign it.
-		if err := pv.TMFilePV.SignProposal(pv.Config.Privval.ChainID, req.Proposal); err != nil {
-			err := fmt.Errorf("failed to sign %v for block height %v: %v", req.Proposal.Type, req.Proposal.Height, err)
+		err := signProposal(ctx, pv.TMFilePV, pv.Config.Privval.ChainID, req.Proposal)
+		steps.done("sign", pv.Clock.Now())
+		if err != nil {
+			err := reqData.requestError(pv, ErrSigningFailed, err)
 			return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
 		}
 
+		pv.updateStateMAC()
+		pv.exportTmkmsWatermark(w)
+
+		// Never send a signature the network would drop.
+		if err := pv.verifySignature(tm_types.ProposalSignBytes(pv.Config.Privval.ChainID, req.Proposal), req.Proposal.Signature); err != nil {
+			pv.reportBadSignature(reqData, err)
+			req.Proposal.Signature = nil
+			err := reqData.requestError(pv, ErrBadSignature, err)
+			return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+		}
+		pv.setLastSigned(w)
 		pv.Logger.Info("Signed %v for block height %v", req.Proposal.Type, req.Proposal.Height)
 		return buildResponse(wrapMsg(&tm_privvalproto.SignProposalRequest{Proposal: req.Proposal, ChainId: req.GetChainId()}), nil), nil
 
 	default:
-		return nil, fmt.Errorf("unknown sign request: %T", msg)
+		return nil, fmt.Errorf("%w: unknown sign request: %T", ErrUnknownMessage, msg.Sum)
 	}
 }
 
-// HandleRequest handles all incoming requests from the validator.
-func HandleRequest(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
-	switch msg.Sum.(type) {
-	case *tm_privvalproto.Message_PingRequest:
+// HandlerFunc handles a request from the validator and returns the response to it.
+type HandlerFunc func(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error)
+
+var (
+	// handlersMtx guards handlers.
+	handlersMtx sync.RWMutex
+
+	// handlers maps the types wrapped by tm_privvalproto.Message to the functions
+	// handling them.
+	handlers = make(map[reflect.Type]HandlerFunc)
+)
+
+func init() {
+	RegisterHandler(&tm_privvalproto.Message_PingRequest{}, func(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
 		return handlePingRequest(pv)
-	case *tm_privvalproto.Message_PubKeyRequest:
+	})
+	RegisterHandler(&tm_privvalproto.Message_PubKeyRequest{}, func(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
 		return handlePubKeyRequest(msg.GetPubKeyRequest(), pv)
-	case *tm_privvalproto.Message_SignVoteRequest, *tm_privvalproto.Message_SignProposalRequest:
-		return handleSignRequest(ctx, msg, pv)
-	default:
-		return nil, fmt.Errorf("unknown message: %v", msg)
+	})
+	RegisterHandler(&tm_privvalproto.Message_SignVoteRequest{}, handleSignRequest)
+	RegisterHandler(&tm_privvalproto.Message_SignProposalRequest{}, handleSignRequest)
+}
+
+// RegisterHandler registers h as the handler for all messages wrapping the same type
+// as sum, e.g. &tm_privvalproto.Message_PingRequest{}. An already registered handler
+// for the type is replaced, and passing a nil handler removes the registration.
+func RegisterHandler(sum interface{}, h HandlerFunc) {
+	handlersMtx.Lock()
+	defer handlersMtx.Unlock()
+
+	t := reflect.TypeOf(sum)
+	if h == nil {
+		delete(handlers, t)
+		return
+	}
+	handlers[t] = h
+}
+
+// unknownMessageResponse returns the response to messages that no handler is
+// registered for. Since there is no dedicated error message in the privval protocol,
+// a PubKeyResponse carrying the error is returned which the validator treats as a
+// failed request.
+func unknownMessageResponse(err error) *tm_privvalproto.Message {
+	return wrapMsg(&tm_privvalproto.PubKeyResponse{
+		PubKey: tm_cryptoproto.PublicKey{},
+		Error:  &tm_privvalproto.RemoteSignerError{Description: err.Error()},
+	})
+}
+
+// HandleRequest handles all incoming requests from the validator by dispatching them
+// to the handler registered for the message's type.
+func HandleRequest(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
+	handlersMtx.RLock()
+	h, ok := handlers[reflect.TypeOf(msg.Sum)]
+	handlersMtx.RUnlock()
+
+	if !ok {
+		err := fmt.Errorf("%w: %T", ErrUnknownMessage, msg.Sum)
+		return unknownMessageResponse(err), err
+	}
+
+	return h(ctx, msg, pv)
+}
+
+// checkBlock checks whether the validator signed the commit of the block preceding
+// the given height, which is the height of a sign request, and counts the block as
+// missed or resets the counter for missed blocks in a row accordingly. The commitsigs
+// are only checked once for each height, whether the sign request or the commit
+// watcher gets to it first, and only for heights greater than 1, as the genesis block
+// doesn't have any commitsigs. It returns whether the commitsigs were checked, and
+// types.ErrMustShutdown if the threshold is exceeded and the node must shut down.
+// signerMtx must be held.
+func (pv *SCFilePV) checkBlock(ctx context.Context, height int64) (bool, error) {
+	pv.blockMtx.Lock()
+	defer pv.blockMtx.Unlock()
+
+	if height <= pv.BaseSignCtrled.GetCurrentHeight() || height <= 1 {
+		return false, nil
+	}
+
+	// Get block information from the validator's /block endpoint.
+	rb, err := pv.QueryBlock(ctx, height-1)
+	if err != nil {
+		return false, err
+	}
+
+	// Measure the block time for the adaptive timeouts.
+	pv.blockTimes.observe(rb.Block.Height, rb.Block.Time)
+
+	// Update the current height to the height of the request.
+	pv.BaseSignCtrled.SetCurrentHeight(height)
+	pv.BaseSignCtrled.SetCurrentBlockTime(rb.Block.Time)
+	pv.setLastHeight(height)
+
+	// Check if the commitsigs in the block are signed by the validator.
+	pub, err := pv.pubKeyLocked()
+	if err != nil {
+		return true, err
 	}
+	if !pv.Adapter.HasSignedCommit(pub.Address(), rb.Block) {
+		// Only count blocks as missed that were verified by the light client, so
+		// that a compromised RPC server can't trick the node into promoting.
+		var reason string
+		if pv.IsSigningPaused() {
+			// A jailed validator isn't expected to sign any blocks.
+			pv.Logger.Debug("Signing is paused, not counting block %v as missed", rb.Block.Height)
+			reason = "signing paused"
+		} else if pv.isAroundUpgrade(rb.Block.Height) {
+			// The whole set misses blocks during a coordinated halt.
+			pv.Logger.Info("Block %v is close to a chain upgrade, not counting it as missed", rb.Block.Height)
+			reason = "chain upgrade"
+		} else if pv.InMaintenance() {
+			pv.Logger.Info("Maintenance window in progress, not counting block %v as missed", rb.Block.Height)
+			reason = "maintenance"
+		} else if pv.IsClockSkewed() {
+			// Promotions rely on the clocks of the set being roughly in sync.
+			pv.Logger.Warn("The clock is off by more than max_offset, not counting block %v as missed", rb.Block.Height)
+			reason = "clock skew"
+		} else if peer, ok := pv.signingPeer(rb.Block.Height - 1); ok {
+			// Rank 1 is alive and signing, so promoting would only risk double-signing.
+			pv.Logger.Info("Peer %v is on rank 1 and signed height %v, not counting block %v as missed", peer.Node, peer.Height, rb.Block.Height)
+			reason = "peer signing"
+		} else if !pv.wasInValidatorSet(ctx, rb.Block.Height-1, pub) {
+			// The commit is looked up by the validator's address, so the validator
+			// set on chain may change or include validators outside the set, but a
+			// validator that isn't part of it can't sign at all.
+			pv.Logger.Debug("Key %v isn't in the validator set at height %v, not counting block %v as missed", pub.Address(), rb.Block.Height-1, rb.Block.Height)
+			reason = "not in validator set"
+		} else if err := pv.confirmMissed(ctx, rb.Block.Height-1, pub.Address()); err != nil {
+			pv.Logger.Warn("Not counting block %v as missed: %v", rb.Block.Height, err)
+			reason = "unconfirmed"
+		} else if err := pv.VerifyBlock(ctx, rb); err != nil {
+			pv.Logger.Error("Couldn't verify block %v, not counting it as missed: %v", rb.Block.Height, err)
+			reason = "unverified"
+		}
+		// The block's last commit is the one of the previous height.
+		pv.recordCommit(rb.Block.Height-1, rb, false, reason)
+		if reason == "" {
+			pv.emit(watchtower.EventMissedBlock, "Missed block %v", rb.Block.Height)
+			if err := pv.Missed(); err != nil {
+				// The threshold of too many missed blocks in a row is exceeded.
+				// Rank 1 either moves to the last rank and keeps running as a
+				// backup, or shuts down.
+				if errors.Is(err, types.ErrMustShutdown) {
+					if !pv.Features.Enabled(features.CircularDemotion) {
+						pv.markRankObsolete()
+						return true, err
+					}
+					if demoteErr := pv.Demote(); demoteErr != nil {
+						pv.Logger.Error("couldn't demote the validator: %v", demoteErr)
+						pv.markRankObsolete()
+						return true, err
+					}
+				}
+			}
+		}
+	} else {
+		// If the commit was signed, reset the counter for missed blocks in a row
+		// and unlock it if it hasn't already been unlocked.
+		pv.recordCommit(rb.Block.Height-1, rb, true, "")
+		pv.Reset()
+		pv.UnlockCounter()
+	}
+
+	return true, nil
 }
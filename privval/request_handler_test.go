@@ -2,16 +2,25 @@ package privval
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/BlockscapeNetwork/signctrl/adapters"
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/features"
 	"github.com/BlockscapeNetwork/signctrl/rpc"
 	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	tm_crypto "github.com/tendermint/tendermint/crypto"
 	tm_hash "github.com/tendermint/tendermint/crypto/tmhash"
 	tm_json "github.com/tendermint/tendermint/libs/json"
@@ -68,31 +77,6 @@ func TestWrapMsg(t *testing.T) {
 	assert.Panics(t, func() { wrapMsg(nil) })
 }
 
-func testCommitSigs(t *testing.T) *[]tm_types.CommitSig {
-	t.Helper()
-	return &[]tm_types.CommitSig{
-		{
-			ValidatorAddress: []byte("ALPHA-ADDR"),
-			Signature:        []byte("ALPHA-SIG"),
-		},
-		{
-			ValidatorAddress: []byte("BETA-ADDR"),
-			Signature:        []byte("BETA-SIG"),
-		},
-	}
-}
-
-func TestHasSignedCommit(t *testing.T) {
-	signed := hasSignedCommit([]byte("ALPHA-ADDR"), testCommitSigs(t))
-	assert.True(t, signed)
-
-	signed = hasSignedCommit([]byte("BETA-SIG"), testCommitSigs(t))
-	assert.False(t, signed)
-
-	signed = hasSignedCommit([]byte("GAMMA"), testCommitSigs(t))
-	assert.False(t, signed)
-}
-
 func TestIsRankUpToDate(t *testing.T) {
 	upToDate := isRankUpToDate(2, 1, 1)
 	assert.True(t, upToDate)
@@ -101,7 +85,7 @@ func TestIsRankUpToDate(t *testing.T) {
 	assert.False(t, upToDate)
 }
 
-func testPingRequest(t *testing.T) *tm_privvalproto.Message {
+func testPingRequest(t testing.TB) *tm_privvalproto.Message {
 	t.Helper()
 	return &tm_privvalproto.Message{
 		Sum: &tm_privvalproto.Message_PingRequest{},
@@ -115,7 +99,7 @@ func TestHandlePingRequest(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func testPubKeyRequest(t *testing.T) *tm_privvalproto.Message {
+func testPubKeyRequest(t testing.TB) *tm_privvalproto.Message {
 	t.Helper()
 	return &tm_privvalproto.Message{
 		Sum: &tm_privvalproto.Message_PubKeyRequest{
@@ -132,11 +116,14 @@ func TestHandlePubKeyRequest_WrongChainID(t *testing.T) {
 
 	// Wrong chain ID.
 	pv.Config.Privval.ChainID = "wrongchain"
+	pv.Gauges.WrongChainIDCounter = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_wrong_chain_id_requests"}, []string{"type"})
 
 	// Handle request.
 	msg, err := HandleRequest(context.Background(), testPubKeyRequest(t), pv)
 	assert.NotNil(t, msg)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrWrongChainID)
+	assert.NotNil(t, msg.GetPubKeyResponse().GetError())
+	assert.Equal(t, 1.0, prom_testutil.ToFloat64(pv.Gauges.WrongChainIDCounter.WithLabelValues("pubkey")))
 }
 
 func TestHandlePubKeyRequest_InvalidPubKey(t *testing.T) {
@@ -164,7 +151,7 @@ func TestHandlePubKeyRequest_Valid(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func testVote(t *testing.T) *tm_prototypes.Vote {
+func testVote(t testing.TB) *tm_prototypes.Vote {
 	t.Helper()
 	return &tm_prototypes.Vote{
 		Type:   tm_prototypes.PrecommitType,
@@ -184,7 +171,7 @@ func testVote(t *testing.T) *tm_prototypes.Vote {
 	}
 }
 
-func testSignVoteRequest(t *testing.T) *tm_privvalproto.Message {
+func testSignVoteRequest(t testing.TB) *tm_privvalproto.Message {
 	t.Helper()
 	return &tm_privvalproto.Message{
 		Sum: &tm_privvalproto.Message_SignVoteRequest{
@@ -196,7 +183,7 @@ func testSignVoteRequest(t *testing.T) *tm_privvalproto.Message {
 	}
 }
 
-func testProposal(t *testing.T) *tm_prototypes.Proposal {
+func testProposal(t testing.TB) *tm_prototypes.Proposal {
 	t.Helper()
 	return &tm_prototypes.Proposal{
 		Type:     tm_prototypes.ProposalType,
@@ -215,7 +202,7 @@ func testProposal(t *testing.T) *tm_prototypes.Proposal {
 	}
 }
 
-func testSignProposalRequest(t *testing.T) *tm_privvalproto.Message {
+func testSignProposalRequest(t testing.TB) *tm_privvalproto.Message {
 	t.Helper()
 	return &tm_privvalproto.Message{
 		Sum: &tm_privvalproto.Message_SignProposalRequest{
@@ -250,6 +237,10 @@ func TestBuildResponse(t *testing.T) {
 	assert.NotNil(t, resp)
 	assert.IsType(t, &tm_privvalproto.Message_SignedProposalResponse{}, resp.GetSum())
 
+	resp = buildResponse(wrapMsg(&tm_privvalproto.SignVoteRequest{}), nil)
+	assert.NotNil(t, resp)
+	assert.IsType(t, &tm_privvalproto.Message_SignedVoteResponse{}, resp.GetSum())
+
 	resp = buildResponse(wrapMsg(&tm_privvalproto.Message{}), nil)
 	assert.Nil(t, resp)
 }
@@ -285,13 +276,18 @@ func testBlockEndpoint(t *testing.T, port int, result *rpc.BlockResult, quitCh c
 	})
 
 	server := http.Server{Addr: fmt.Sprintf(":%v", port), Handler: mux}
-	defer server.Close()
 
-	listener, _ := net.Listen("tcp", server.Addr)
+	// Listen before returning, so the endpoint is reachable once the test sends its
+	// first request.
+	listener, err := net.Listen("tcp", server.Addr)
+	assert.NoError(t, err)
 	go func() {
 		_ = server.Serve(listener)
 	}()
-	<-quitCh
+	go func() {
+		<-quitCh
+		server.Close()
+	}()
 }
 
 func TestHandleSignRequest(t *testing.T) {
@@ -302,7 +298,7 @@ func TestHandleSignRequest(t *testing.T) {
 	port, _ := getFreePort(t)
 	pv.Config.Base.ValidatorListenAddressRPC = fmt.Sprintf("tcp://127.0.0.1:%v", port)
 	quitCh := make(chan struct{})
-	go testBlockEndpoint(t, port, testBlockResult(t), quitCh)
+	testBlockEndpoint(t, port, testBlockResult(t), quitCh)
 	defer close(quitCh)
 
 	// Initialize new file signer.
@@ -325,11 +321,19 @@ func TestHandleSignRequest_WrongChainID(t *testing.T) {
 
 	// The testSignVoteRequest has "testchain", so change it that they don't match.
 	pv.Config.Privval.ChainID = "wrongchain"
+	pv.Gauges.WrongChainIDCounter = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_wrong_chain_id_requests"}, []string{"type"})
 
 	// Handle the request.
 	msg, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
 	assert.NotNil(t, msg)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrWrongChainID)
+	assert.NotNil(t, msg.GetSignedVoteResponse().GetError())
+	assert.Equal(t, 1.0, prom_testutil.ToFloat64(pv.Gauges.WrongChainIDCounter.WithLabelValues("precommit")))
+
+	var reqErr *RequestError
+	assert.True(t, errors.As(err, &reqErr))
+	assert.Equal(t, testVote(t).Height, reqErr.Height)
+	assert.Equal(t, pv.GetRank(), reqErr.Rank)
 }
 
 func TestHandleSignRequest_ObsoleteRank(t *testing.T) {
@@ -344,7 +348,50 @@ func TestHandleSignRequest_ObsoleteRank(t *testing.T) {
 	// Handle the request.
 	msg, err := HandleRequest(context.Background(), req, pv)
 	assert.NotNil(t, msg)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrRankObsolete)
+}
+
+func TestHandleSignRequest_Malformed(t *testing.T) {
+	// Initialize mock SCFilePV with valid values.
+	pv := mockSCFilePV(t)
+
+	invalidVote := testSignVoteRequest(t)
+	invalidVote.GetSignVoteRequest().Vote.Type = tm_prototypes.ProposalType
+	negativeRound := testSignVoteRequest(t)
+	negativeRound.GetSignVoteRequest().Vote.Round = -1
+	invalidProposal := testSignProposalRequest(t)
+	invalidProposal.GetSignProposalRequest().Proposal.Type = tm_prototypes.PrevoteType
+
+	for _, req := range []*tm_privvalproto.Message{
+		wrapMsg(&tm_privvalproto.SignVoteRequest{ChainId: "testchain"}),
+		wrapMsg(&tm_privvalproto.SignProposalRequest{ChainId: "testchain"}),
+		{Sum: &tm_privvalproto.Message_SignVoteRequest{}},
+		invalidVote,
+		negativeRound,
+		invalidProposal,
+	} {
+		// Handle the request.
+		msg, err := HandleRequest(context.Background(), req, pv)
+		assert.NotNil(t, msg)
+		assert.ErrorIs(t, err, ErrMalformedRequest)
+	}
+}
+
+// unknownVoteTypeAdapter is an adapter for a network that signs votes of an unknown
+// type.
+type unknownVoteTypeAdapter struct {
+	adapters.Default
+}
+
+func (unknownVoteTypeAdapter) IsValidVoteType(t tm_prototypes.SignedMsgType) bool {
+	return t == tm_prototypes.UnknownType || adapters.Default{}.IsValidVoteType(t)
+}
+
+func TestValidateSignRequest_Adapter(t *testing.T) {
+	req := testSignVoteRequest(t)
+	req.GetSignVoteRequest().Vote.Type = tm_prototypes.UnknownType
+	assert.Error(t, validateSignRequest(req, adapters.Default{}))
+	assert.NoError(t, validateSignRequest(req, unknownVoteTypeAdapter{}))
 }
 
 func TestHandleSignRequest_QueryBlockErr(t *testing.T) {
@@ -369,7 +416,7 @@ func TestHandleSignRequest_Missed(t *testing.T) {
 	port, _ := getFreePort(t)
 	pv.Config.Base.ValidatorListenAddressRPC = fmt.Sprintf("tcp://127.0.0.1:%v", port)
 	quitCh := make(chan struct{})
-	go testBlockEndpoint(t, port, testBlockResult(t), quitCh)
+	testBlockEndpoint(t, port, testBlockResult(t), quitCh)
 	defer close(quitCh)
 
 	// Initialize new file signer.
@@ -390,6 +437,66 @@ func TestHandleSignRequest_Missed(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestHandleSignRequest_Unverified(t *testing.T) {
+	// Initialize mock SCFilePV with valid values.
+	pv := mockSCFilePV(t)
+	pv.UnlockCounter()
+	pv.SetRank(2)
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		return testBlockResult(t).Result, nil
+	}
+	pv.VerifyBlock = func(ctx context.Context, block *tm_coretypes.ResultBlock) error {
+		return ErrUnverifiedBlock
+	}
+
+	// The testBlockResult doesn't contain the validator's commitsig, but as it can't
+	// be verified, it must not be counted as missed.
+	msg, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.NotNil(t, msg)
+	assert.True(t, errors.Is(err, ErrNoSigningPermission))
+	assert.Zero(t, pv.GetMissedInARow())
+
+	// Once the block is verified, it is counted as missed.
+	pv.VerifyBlock = func(ctx context.Context, block *tm_coretypes.ResultBlock) error {
+		return nil
+	}
+	req := testSignVoteRequest(t)
+	req.GetSignVoteRequest().Vote.Height++
+	_, _ = HandleRequest(context.Background(), req, pv)
+	assert.Equal(t, 1, pv.GetMissedInARow())
+}
+
+func TestHandleSignRequest_ThresholdDuration(t *testing.T) {
+	dir := t.TempDir()
+	pv := mockSCFilePV(t)
+	pv.TMFilePV = tm_privval.GenFilePV(filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile))
+	pv.SetRank(2)
+	pv.SetThresholdDuration(time.Minute)
+
+	// Blocks take 40s each, so rank 1 hasn't signed for a minute after 2 blocks,
+	// long before the threshold of 10 blocks is reached.
+	start := time.Unix(1000, 0)
+	pv.SetCurrentBlockTime(start.Add(time.Duration(testVote(t).Height-2) * 40 * time.Second))
+	pv.Reset()
+	pv.UnlockCounter()
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		rb := testBlockResult(t).Result
+		rb.Block.Height = height
+		rb.Block.Time = start.Add(time.Duration(height) * 40 * time.Second)
+		return rb, nil
+	}
+
+	req := testSignVoteRequest(t)
+	_, err := HandleRequest(context.Background(), req, pv)
+	assert.ErrorIs(t, err, ErrNoSigningPermission)
+	assert.Equal(t, 1, pv.GetMissedInARow())
+
+	req.GetSignVoteRequest().Vote.Height++
+	_, _ = HandleRequest(context.Background(), req, pv)
+	assert.Equal(t, 1, pv.GetRank())
+	assert.Zero(t, pv.GetMissedInARow())
+}
+
 func TestHandleSignRequest_MustShutdown(t *testing.T) {
 	// Initialize mock SCFilePV with valid values.
 	pv := mockSCFilePV(t)
@@ -408,13 +515,49 @@ func TestHandleSignRequest_MustShutdown(t *testing.T) {
 	port, _ := getFreePort(t)
 	pv.Config.Base.ValidatorListenAddressRPC = fmt.Sprintf("tcp://127.0.0.1:%v", port)
 	quitCh := make(chan struct{})
-	go testBlockEndpoint(t, port, testBlockResult(t), quitCh)
+	testBlockEndpoint(t, port, testBlockResult(t), quitCh)
 	defer close(quitCh)
 
 	// Handle the request.
 	msg, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
 	assert.NotNil(t, msg)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, types.ErrMustShutdown)
+}
+
+func TestHandleSignRequest_CircularDemotion(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.CfgDir = t.TempDir()
+	pv.Features = features.New(config.Features{CircularDemotion: true})
+	pv.BaseSignCtrled = *types.NewBaseSignCtrled(
+		pv.Logger,
+		1, // Threshold
+		1, // Rank
+		pv,
+	)
+	pv.SetSetSize(3)
+	pv.UnlockCounter()
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		return testBlockResult(t).Result, nil
+	}
+	pv.VerifyBlock = func(ctx context.Context, block *tm_coretypes.ResultBlock) error {
+		return nil
+	}
+
+	// Rank 1 moves to the last rank instead of shutting down.
+	_, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.ErrorIs(t, err, ErrNoSigningPermission)
+	assert.Equal(t, 3, pv.GetRank())
+	assert.Zero(t, pv.GetMissedInARow())
+	assert.True(t, pv.IsCounterLocked())
+
+	// The new rank is persisted.
+	state, err := config.LoadOrGenState(pv.CfgDir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, state.LastRank)
+
+	events, _, _ := pv.watchEvents.Since(0)
+	require.NotEmpty(t, events)
+	assert.Equal(t, watchtower.EventDemoted, events[len(events)-1].Type)
 }
 
 func TestHandleSignRequest_RankTooLow(t *testing.T) {
@@ -438,13 +581,13 @@ func TestHandleSignRequest_RankTooLow(t *testing.T) {
 	// Start mock endpoint for the block query.
 	port, _ := getFreePort(t)
 	pv.Config.Base.ValidatorListenAddressRPC = fmt.Sprintf("tcp://127.0.0.1:%v", port)
-	go testBlockEndpoint(t, port, br, quitCh)
+	testBlockEndpoint(t, port, br, quitCh)
 	defer close(quitCh)
 
 	// Handle the request.
 	msg, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
 	assert.NotNil(t, msg)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrNoSigningPermission)
 	assert.Equal(t, 0, pv.GetMissedInARow())
 }
 
@@ -466,7 +609,7 @@ func TestHandleSignRequest_SignVoteErr(t *testing.T) {
 	// Start mock endpoint for the block query.
 	port, _ := getFreePort(t)
 	pv.Config.Base.ValidatorListenAddressRPC = fmt.Sprintf("tcp://127.0.0.1:%v", port)
-	go testBlockEndpoint(t, port, br, quitCh)
+	testBlockEndpoint(t, port, br, quitCh)
 	defer close(quitCh)
 
 	// Initialize new file signer.
@@ -475,11 +618,48 @@ func TestHandleSignRequest_SignVoteErr(t *testing.T) {
 	// Handle the request.
 	msg, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
 	assert.NotNil(t, msg)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrSigningFailed)
+}
+
+func TestHandleSignRequest_Canceled(t *testing.T) {
+	// Initialize mock SCFilePV with valid values.
+	pv := mockSCFilePV(t)
+
+	// Request height 1, so no block is queried before signing.
+	req := testSignVoteRequest(t)
+	req.GetSignVoteRequest().Vote.Height = 1
+
+	// Handle the request with an already canceled context.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	msg, err := HandleRequest(ctx, req, pv)
+	assert.NotNil(t, msg)
+	assert.ErrorIs(t, err, context.Canceled)
 }
 
 func TestHandleRequest_UnknownMessage(t *testing.T) {
 	msg, err := HandleRequest(context.Background(), &tm_privvalproto.Message{}, nil)
-	assert.Nil(t, msg)
-	assert.Error(t, err)
+	assert.NotNil(t, msg.GetPubKeyResponse().GetError())
+	assert.ErrorIs(t, err, ErrUnknownMessage)
+
+	// Responses are never sent by the validator, so there's no handler for them.
+	msg, err = HandleRequest(context.Background(), wrapMsg(&tm_privvalproto.PingResponse{}), nil)
+	assert.NotNil(t, msg.GetPubKeyResponse().GetError())
+	assert.ErrorIs(t, err, ErrUnknownMessage)
+}
+
+func TestRegisterHandler(t *testing.T) {
+	sum := &tm_privvalproto.Message_PingResponse{}
+	RegisterHandler(sum, func(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
+		return wrapMsg(&tm_privvalproto.PingRequest{}), nil
+	})
+
+	msg, err := HandleRequest(context.Background(), wrapMsg(&tm_privvalproto.PingResponse{}), nil)
+	assert.NotNil(t, msg.GetPingRequest())
+	assert.NoError(t, err)
+
+	// Remove the registration again.
+	RegisterHandler(sum, nil)
+	_, err = HandleRequest(context.Background(), wrapMsg(&tm_privvalproto.PingResponse{}), nil)
+	assert.ErrorIs(t, err, ErrUnknownMessage)
 }
@@ -2,17 +2,36 @@ package privval
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/BlockscapeNetwork/signctrl/adapters"
+	"github.com/BlockscapeNetwork/signctrl/alerts"
 	"github.com/BlockscapeNetwork/signctrl/config"
 	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/election"
+	"github.com/BlockscapeNetwork/signctrl/features"
+	"github.com/BlockscapeNetwork/signctrl/history"
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+	"github.com/BlockscapeNetwork/signctrl/lease"
+	"github.com/BlockscapeNetwork/signctrl/maintenance"
+	"github.com/BlockscapeNetwork/signctrl/p2p"
+	"github.com/BlockscapeNetwork/signctrl/resources"
+	"github.com/BlockscapeNetwork/signctrl/rpc"
 	"github.com/BlockscapeNetwork/signctrl/types"
-	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	tm_light "github.com/tendermint/tendermint/light"
 	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
 	tm_types "github.com/tendermint/tendermint/types"
 )
 
@@ -23,11 +42,22 @@ const (
 	// StateFile is Tendermint's default file name for the private validator's state.
 	StateFile = "priv_validator_state.json"
 
-	// maxRemoteSignerMsgSize determines the maximum size in bytes for the delimited
-	// reader.
+	// maxRemoteSignerMsgSize determines the maximum size in bytes of a message from
+	// the validator.
 	maxRemoteSignerMsgSize = 1024 * 10
+
+	// versionQueryTimeout determines the time SignCTRL waits for the validator's
+	// version before it keeps the previous capabilities.
+	versionQueryTimeout = 5 * time.Second
 )
 
+// Dialer establishes a connection to the validator. It is expected to keep retrying
+// until it either succeeds or ctx is done.
+type Dialer func(ctx context.Context) (net.Conn, error)
+
+// BlockQuerier queries the block at the given height.
+type BlockQuerier func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error)
+
 // SCFilePV must implement the SignCtrled interface.
 var _ types.SignCtrled = new(SCFilePV)
 
@@ -38,13 +68,131 @@ type SCFilePV struct {
 	types.BaseService
 	types.BaseSignCtrled
 
-	Logger     *types.SyncLogger
-	Config     config.Config
-	State      config.State
-	TMFilePV   tm_types.PrivValidator
-	SecretConn net.Conn
-	HTTP       *http.Server
-	Gauges     types.Gauges
+	Logger              types.Logger
+	Config              config.Config
+	State               config.State
+	CfgDir              string
+	TMFilePV            tm_types.PrivValidator
+	Dial                Dialer
+	QueryBlock          BlockQuerier
+	QueryCommit         CommitQuerier
+	VerifyBlock         BlockVerifier
+	RaiseWatermark      WatermarkRaiser
+	SubscribeBlocks     BlockSubscriber
+	QueryVersion        VersionQuerier
+	QuerySlashing       SlashingQuerier
+	QueryValidatorSet   ValidatorSetQuerier
+	QueryValidatorSetAt ValidatorSetAtQuerier
+	QueryUpgradePlan    UpgradePlanQuerier
+	QueryClockOffset    ClockOffsetQuerier
+	QueryLatestHeight   LatestHeightQuerier
+	SecretConn          net.Conn
+	HTTP                *http.Server
+	Gauges              types.Gauges
+	Clock               types.Clock
+	Features            *features.Set
+	Adapter             adapters.Adapter
+	RPC                 *rpc.Client
+
+	// RPCPool health checks the validator's RPC server along with the further RPC
+	// endpoints from the configuration and selects the one blocks and validator sets
+	// are queried from. It is nil if there are no further endpoints.
+	RPCPool *rpc.Pool
+
+	// Cgroup is the cgroup of SignCTRL's container, whose limits and usage are
+	// reported in /status. It is nil if SignCTRL doesn't run in a cgroup.
+	Cgroup *resources.Cgroup
+
+	// LogFilter filters the log lines by their level. Its minimum level is updated
+	// when the configuration is reloaded. It is nil if the log level can't be changed,
+	// e.g. for instances sharing the default validator's logger.
+	LogFilter *types.LevelFilter
+
+	// OnCrash is called with the crash report after the node recovered from a panic
+	// and was stopped.
+	OnCrash func(report CrashReport)
+
+	// Instances are the other validators managed by the same process, keyed by their
+	// names. Their endpoints are served by this node's HTTP server.
+	Instances map[string]*SCFilePV
+
+	crashed     int32
+	events      *eventLog
+	watchEvents *watchtower.EventLog
+	blocks      *blockCache
+	blockTimes  *blockTimer
+	slo         *sloTracker    // nil if no sign latency SLO is configured
+	watchdog    *watchdog      // nil if the watchdog is disabled
+	stale       *staleDetector // nil if stale validators aren't detected
+	signerMtx   sync.RWMutex   // guards TMFilePV while requests are handled
+	blockMtx    sync.Mutex     // serializes the checks of the commitsigs
+
+	retryDialAfterCfg int64 // configured retry_dial_after, updated on reloads
+
+	pubKeyMtx   sync.RWMutex
+	pubKeyCache tm_crypto.PubKey // nil until the signer backend returned it
+
+	slashingMtx   sync.RWMutex
+	slashing      SlashingStatus
+	signingPaused bool
+
+	upgradeMtx  sync.RWMutex
+	upgradePlan UpgradePlan
+
+	approvals approvals
+
+	totpMtx      sync.Mutex
+	lastTOTPStep uint64 // time step of the last accepted TOTP code, which can't be reused
+
+	clockMtx    sync.RWMutex
+	clockStatus ClockStatus
+
+	p2p      *p2p.Node          // nil if no heartbeats are exchanged
+	election *election.Election // nil if the coordination isn't raft
+	lock     *lease.Lock        // nil if no signing lock is used
+
+	lastSignedMtx sync.RWMutex
+	lastSigned    Watermark // last signature, for the heartbeats and /status
+
+	lastErrorMtx sync.RWMutex
+	lastError    *ErrorStatus // nil until the first error, for /status
+
+	startedAt time.Time // set in OnStart, for the uptime in /status
+
+	hwmOnce sync.Once
+	hwm     *highWatermarkStore // created on first use, as CfgDir may be changed
+
+	historyMtx     sync.RWMutex
+	history        *history.Store      // nil if no history is kept
+	historyRecords chan history.Record // queues the outcomes for the history
+
+	maintenanceWindows []maintenance.Window
+	maintenanceMtx     sync.RWMutex
+	maintenanceUntil   time.Time
+
+	lightMtx    sync.Mutex
+	lightClient *tm_light.Client
+
+	capsMtx sync.RWMutex // guards caps
+	caps    Capabilities
+
+	stateMtx     sync.Mutex // guards State
+	rankObsolete int32      // set once the rank must not be started on again
+
+	connMtx   sync.RWMutex
+	connState ConnState
+	connSince time.Time
+	connKey   tm_crypto.PubKey // of the conn.key the validator was last dialed with
+
+	// rehandshake requests the run goroutine to dial the validator again, e.g. after
+	// the conn.key was rotated.
+	rehandshake chan struct{}
+
+	dialBackoff *connection.Backoff // only used by the connect and run goroutines
+
+	alerts      *alerts.Dispatcher // nil if no alerts are sent
+	alertEvents map[watchtower.EventType]bool
+	alertRank   int64 // rank of the last event, for the alerts' old rank
 }
 
 // KeyFilePath returns the absolute path to the priv_validator_key.json file.
@@ -58,14 +206,64 @@ func StateFilePath(cfgDir string) string {
 }
 
 // NewSCFilePV creates a new instance of SCFilePV.
-func NewSCFilePV(logger *types.SyncLogger, cfg config.Config, state config.State, tmpv tm_types.PrivValidator, http *http.Server) *SCFilePV {
+func NewSCFilePV(logger types.Logger, cfg config.Config, state config.State, tmpv tm_types.PrivValidator, http *http.Server) *SCFilePV {
+	// Record the most recent log messages for crash reports.
+	if logger == nil {
+		logger = types.NewSyncLogger(ioutil.Discard, "", 0)
+	}
+	events := newEventLog(crashReportEvents)
+	logger = &recordingLogger{Logger: logger, events: events}
+
 	pv := &SCFilePV{
 		Logger:   logger,
 		Config:   cfg,
 		State:    state,
+		CfgDir:   config.Dir(),
 		TMFilePV: tmpv,
 		HTTP:     http,
+		Clock:    types.SystemClock,
+		Features: features.New(cfg.Features),
+		Adapter:  adapters.For(cfg.Privval.ChainID),
+		Cgroup:   resources.Detect(),
+		events:   events,
+		blocks:   newBlockCache(blockCacheSize),
+
+		blockTimes:  new(blockTimer),
+		slo:         newSLOTracker(cfg.Metrics),
+		watchdog:    newWatchdog(cfg.Watchdog),
+		stale:       newStaleDetector(cfg.RPC),
+		watchEvents: watchtower.NewEventLog(watchtowerEvents),
+		caps:        defaultCapabilities,
+		rehandshake: make(chan struct{}, 1),
+	}
+	pv.Dial = pv.retryDial
+	pv.dialBackoff = connection.NewBackoff(cfg.Base.GetDialBackoffInitial(), cfg.Base.GetDialBackoffMax(), cfg.Base.DialBackoffMultiplier)
+	pv.dialBackoff.OnDelay = pv.setDialBackoffGauge
+	pv.QueryBlock = pv.queryBlock
+	pv.QueryCommit = pv.queryCommit
+	pv.VerifyBlock = pv.verifyBlock
+	pv.RaiseWatermark = pv.raiseWatermark
+	pv.SubscribeBlocks = pv.subscribeBlocks
+	pv.QueryVersion = pv.queryVersion
+	pv.QuerySlashing = pv.querySlashing
+	pv.QueryClockOffset = pv.queryClockOffset
+	pv.QueryValidatorSet = pv.queryValidatorSet
+	pv.QueryValidatorSetAt = pv.queryValidatorSetAt
+	pv.QueryUpgradePlan = pv.queryUpgradePlan
+	pv.QueryLatestHeight = pv.queryLatestHeight
+	pv.RPC = &rpc.Client{
+		Logger:           logger,
+		Timeout:          cfg.RPC.GetTimeout(),
+		Retries:          cfg.RPC.Retries,
+		RetryBackoff:     cfg.RPC.GetRetryBackoff(),
+		CircuitThreshold: cfg.RPC.CircuitThreshold,
+		CircuitCooldown:  cfg.RPC.GetCircuitCooldown(),
 	}
+	if len(cfg.RPC.Endpoints) > 0 {
+		pv.RPCPool = newRPCPool(logger, cfg)
+	}
+	// The windows were validated along with the configuration.
+	pv.maintenanceWindows, _ = cfg.Maintenance.GetWindows()
 	pv.BaseService = *types.NewBaseService(
 		logger,
 		"SignCTRL",
@@ -74,19 +272,235 @@ func NewSCFilePV(logger *types.SyncLogger, cfg config.Config, state config.State
 	pv.BaseSignCtrled = *types.NewBaseSignCtrled(
 		logger,
 		pv.Config.Base.Threshold,
-		pv.Config.Base.StartRank,
+		startRank(cfg, state),
 		pv,
 	)
+	if r := startRank(cfg, state); r != cfg.Base.StartRank {
+		logger.Info("Starting on rank %v from %v instead of start_rank %v", r, config.StateFile, cfg.Base.StartRank)
+	}
+	pv.SetThresholdDuration(cfg.Base.GetThresholdDuration())
+	pv.SetSetSize(cfg.Base.SetSize)
+	pv.setRetryDialAfter(cfg.Base.GetRetryDialAfter())
 
 	return pv
 }
 
+// retryDial is the default Dialer of SCFilePV. It keeps dialing the validator at the
+// configured validator_laddr until success, over mutual TLS if the mtls transport is
+// used.
+func (pv *SCFilePV) retryDial(ctx context.Context) (net.Conn, error) {
+	if p := pv.Config.Privval; p.UsesMTLS() {
+		return connection.RetryDialTLS(ctx, pv.Config.Base.ValidatorListenAddress, connection.TLSConfig{
+			CertFile:   p.TLSCertFile,
+			KeyFile:    p.TLSKeyFile,
+			CAFile:     p.TLSCAFile,
+			ServerName: p.TLSServerName,
+		}, pv.dialBackoff, pv.Logger)
+	}
+
+	if pv.usesConnKey() {
+		// The key is loaded again by RetryDial. Should it be rotated in between, the
+		// next reload finds the keys differ and merely redials once more.
+		if key, err := connection.LoadConnKey(pv.CfgDir); err == nil {
+			pv.setDialedConnKey(key.PubKey())
+		}
+	}
+
+	return connection.RetryDial(
+		ctx,
+		pv.CfgDir,
+		pv.Config.Base.ValidatorListenAddress,
+		pv.dialBackoff,
+		pv.Logger,
+	)
+}
+
+// queryBlock is the default BlockQuerier of SCFilePV. It takes the block from the
+// block subscription if it has already been received, and otherwise queries it from
+// the validator's RPC server at the configured validator_laddr_rpc.
+func (pv *SCFilePV) queryBlock(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+	if block, ok := pv.blocks.get(height); ok {
+		return block, nil
+	}
+
+	return pv.RPC.QueryBlockAt(ctx, pv.rpcAddr(), pv.Adapter.BlockPath(height), height)
+}
+
+// closeOnDone closes conn once ctx is done, timeout fires or a new handshake is
+// requested via rehandshake in order to unblock pending reads from it. The returned
+// timedOut and rehandshaking channels are closed if conn was closed because of the
+// timeout or the request. The returned function stops watching without closing conn
+// and is safe to be called multiple times.
+func closeOnDone(ctx context.Context, conn net.Conn, timeout <-chan time.Time, rehandshake <-chan struct{}) (stop func(), timedOut, rehandshaking <-chan struct{}) {
+	var once sync.Once
+	stopCh := make(chan struct{})
+	timedOutCh := make(chan struct{})
+	rehandshakingCh := make(chan struct{})
+	goroutines.Go("conn_watch", func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-timeout:
+			close(timedOutCh)
+			conn.Close()
+		case <-rehandshake:
+			close(rehandshakingCh)
+			conn.Close()
+		case <-stopCh:
+		}
+	})
+
+	return func() { once.Do(func() { close(stopCh) }) }, timedOutCh, rehandshakingCh
+}
+
 // run runs the main loop of SignCTRL. It handles incoming messages from the validator.
-// In order to stop the goroutine, Stop() can be called outside of run(). The goroutine
-// returns on its own once SignCTRL is forced to shut down.
-func (pv *SCFilePV) run() {
-	retryDialTimeout := config.GetRetryDialTime(pv.Config.Base.RetryDialAfter)
-	timeout := time.NewTimer(retryDialTimeout)
+// In order to stop the goroutine, Stop() can be called outside of run() or the context
+// the service was started with can be canceled. The goroutine returns on its own once
+// SignCTRL is forced to shut down.
+func (pv *SCFilePV) run(ctx context.Context) {
+	defer pv.recoverPanic("run")
+
+	retryDialTimeout := pv.retryDialAfter()
+	timeout := pv.Clock.NewTimer(retryDialTimeout)
+	stopWatch, timedOut, rehandshaking := closeOnDone(ctx, pv.SecretConn, timeout.C(), pv.rehandshake)
+	defer func() { stopWatch() }()
+
+	// resetTimeout restarts the timeout after which the connection is considered to
+	// be lost. Validators that don't ping may stay silent for a long time, so their
+	// connections are only considered to be lost once the read deadline is exceeded.
+	// The timeout may change along with the measured block time.
+	stopTimeout := func() {
+		if !timeout.Stop() {
+			select {
+			case <-timeout.C():
+			default:
+			}
+		}
+	}
+	resetTimeout := func() {
+		stopTimeout()
+		if d := pv.retryDialAfter(); d != retryDialTimeout {
+			pv.Logger.Info("Retrying to dial the validator after %v without a message from now on", d)
+			retryDialTimeout = d
+		}
+		if pv.GetCapabilities().Pings {
+			timeout.Reset(retryDialTimeout)
+		}
+	}
+	resetTimeout()
+
+	// mc reads and writes the messages on the current connection. It's only reset
+	// when reconnecting, so that its buffers are reused for all messages.
+	mc := newMsgConn(pv.SecretConn, maxRemoteSignerMsgSize)
+	defer func() { mc.release() }()
+
+	// The requests are read from the connection into a bounded queue by a separate
+	// goroutine, so that sign requests superseded by newer ones can be shed before
+	// they are handled. Both are replaced when reconnecting.
+	var (
+		queue      *requestQueue
+		readerConn net.Conn
+		readerDone chan struct{}
+	)
+	startReader := func() {
+		queue, readerConn, readerDone = newRequestQueue(requestQueueSize), pv.SecretConn, make(chan struct{})
+		q, done := queue, readerDone
+		goroutines.Go("read", func() {
+			defer close(done)
+			pv.readRequests(mc, q)
+		})
+	}
+	// stopReader closes the queue and the connection it's read from, and waits for
+	// the reader to return, after which mc may be reset.
+	stopReader := func() {
+		queue.close(errQueueClosed)
+		readerConn.Close()
+		<-readerDone
+	}
+	startReader()
+	defer func() { stopReader() }()
+
+	// unsupported keeps track of the message types the validator sent that SignCTRL
+	// doesn't understand, so that each of them is only reported once per connection.
+	unsupported := make(map[string]bool)
+
+	// shedReported is set once shedding requests was reported for the connection.
+	shedReported := false
+
+	// readerStalled is set by the watchdog after closing the connection because
+	// reading a message stalled, so that a new connection is established.
+	var readerStalled int32
+	restartReader := func(conn net.Conn) func() {
+		return func() {
+			atomic.StoreInt32(&readerStalled, 1)
+			conn.Close()
+		}
+	}
+	defer pv.idle("reader")
+	defer pv.idle("handler")
+
+	// working is unset for a reconnected connection until the validator sent a message
+	// on it, which resets the backoff for dialing it. A connection that broke before
+	// that is only redialed after backing off, so that a validator that accepts but
+	// drops connections, e.g. while starting up, isn't redialed in a tight loop. The
+	// first connection is redialed right away.
+	working := true
+	pv.dialBackoff.Reset()
+
+	// reconnect locks the counter for missed blocks in a row, closes the current
+	// connection and establishes a new one. It returns false if no new connection
+	// could be established.
+	reconnect := func() bool {
+		pv.LockCounter()
+		pv.updateRankGauges()
+		pv.countReconnect()
+
+		// Dialing may legitimately take until the validator is back.
+		pv.idle("reader")
+
+		stopWatch()
+		if err := pv.SecretConn.Close(); err != nil {
+			pv.Logger.Error("%v", err)
+		}
+		stopReader()
+
+		pv.setConnState(ConnConnecting)
+
+		if !working {
+			delay := pv.dialBackoff.Next()
+			pv.Logger.Info("Redialing the validator in %v", delay)
+			select {
+			case <-ctx.Done():
+				return false
+			case <-pv.Clock.After(delay):
+			}
+		}
+		working = false
+
+		conn, err := pv.Dial(ctx)
+		if err != nil {
+			pv.Logger.Error("couldn't dial validator: %v\n", err)
+			// Note: Don't use pv.Stop() in here, as RetryDial can only be stopped by
+			// canceling ctx.
+			return false
+		}
+		pv.SecretConn = withReadDeadline(conn, pv.Config.Base.GetReadDeadline())
+
+		mc.reset(pv.SecretConn)
+
+		// The validator may have been upgraded in the meantime.
+		pv.negotiateCapabilities(ctx)
+		unsupported = make(map[string]bool)
+		shedReported = false
+		startReader()
+		pv.setConnState(ConnConnected)
+
+		// Restart the timeout for the new connection.
+		resetTimeout()
+		stopWatch, timedOut, rehandshaking = closeOnDone(ctx, pv.SecretConn, timeout.C(), pv.rehandshake)
+
+		return true
+	}
 
 	for {
 		select {
@@ -95,50 +509,120 @@ func (pv *SCFilePV) run() {
 			// Note: Don't use pv.Stop() in here, as it closes the pv.Quit() channel.
 			return
 
-		case <-timeout.C:
-			pv.Logger.Info("Lost connection to the validator... (no message for %v)\n", retryDialTimeout.String())
-
-			// Lock the counter for missed blocks in a row again.
-			pv.LockCounter()
-
-			// Close the connection and establish a new one.
-			if err := pv.SecretConn.Close(); err != nil {
-				pv.Logger.Error("%v", err)
-			}
-
-			var err error
-			if pv.SecretConn, err = connection.RetryDial(
-				config.Dir(),
-				pv.Config.Base.ValidatorListenAddress,
-				pv.Logger,
-			); err != nil {
-				pv.Logger.Error("couldn't dial validator: %v\n", err)
-				// Note: Don't use pv.Stop() in here, as RetryDial can only be stopped via SIGINT/SIGTERM.
-				return
+		default:
+			// Validators that don't ping may stay silent for a long time, so the reader
+			// is only expected to make progress if they ping.
+			if pv.GetCapabilities().Pings {
+				pv.tick("reader", retryDialTimeout, restartReader(pv.SecretConn))
+			} else {
+				pv.idle("reader")
 			}
 
-		default:
-			var msg tm_privvalproto.Message
-			r := tm_protoio.NewDelimitedReader(pv.SecretConn, maxRemoteSignerMsgSize)
-			if _, err := r.ReadMsg(&msg); err != nil {
-				if err != io.EOF {
-					pv.Logger.Error("couldn't read message: %v\n", err)
+			req, err := queue.pop()
+			if err != nil {
+				// The connection is closed once the context is canceled, so don't
+				// treat that as a read error.
+				if ctx.Err() != nil {
+					pv.Logger.Debug("Terminating run goroutine: %v\n", ctx.Err())
+					return
+				}
+				if atomic.CompareAndSwapInt32(&readerStalled, 1, 0) {
+					pv.connLost("reader stalled")
+					if !reconnect() {
+						return
+					}
+					continue
+				}
+				// The connection is closed once the timeout fires, so that a validator
+				// that stopped sending messages doesn't block the read forever.
+				select {
+				case <-timedOut:
+					pv.connLost(fmt.Sprintf("no message for %v", retryDialTimeout.String()))
+					if !reconnect() {
+						return
+					}
+					continue
+				case <-rehandshaking:
+					pv.Logger.Info("Reconnecting to the validator... (new handshake requested)")
+					if !reconnect() {
+						return
+					}
+					continue
+				default:
+				}
+				// Nothing was received for the read deadline, not even a ping, so
+				// the connection is dead even if it wasn't closed.
+				if isTimeout(err) {
+					pv.connLost(fmt.Sprintf("no data for %v", pv.Config.Base.GetReadDeadline()))
+					if !reconnect() {
+						return
+					}
+					continue
+				}
+				// The validator closed the connection, so there is no point in waiting
+				// for the timeout before reconnecting.
+				if errors.Is(err, io.EOF) {
+					pv.connLost("closed by validator")
+					if !reconnect() {
+						return
+					}
+					continue
 				}
+				pv.countConnError("read")
+				pv.Logger.Error("couldn't read message: %v\n", err)
 				continue
 			}
 
-			timeout.Reset(retryDialTimeout)
-
-			ctx, cancel := context.WithCancel(context.Background())
-			resp, err := HandleRequest(ctx, &msg, pv)
-			w := tm_protoio.NewDelimitedWriter(pv.SecretConn)
-			if _, err := w.WriteMsg(resp); err != nil {
+			resetTimeout()
+			pv.idle("reader")
+			if !working {
+				working = true
+				pv.dialBackoff.Reset()
+			}
+			msg := req.msg
+
+			// A stalled request is canceled, so that the next one can be read.
+			reqCtx, cancel := context.WithCancel(ctx)
+			var resp *tm_privvalproto.Message
+			if req.shed {
+				if !shedReported {
+					shedReported = true
+					pv.Logger.Warn("The validator sends sign requests faster than they can be handled, answering the ones superseded by newer requests with an error")
+				}
+				resp = pv.shedRequest(msg)
+			} else {
+				// Pings aren't read while a proposal is held for approval, so the
+				// timeout is paused until it is decided.
+				held := pv.holdsForApproval(msg)
+				if held {
+					stopTimeout()
+				}
+				pv.tick("handler", pv.handlerBudget(), cancel)
+				resp, err = HandleRequest(reqCtx, msg, pv)
+				pv.idle("handler")
+				if held {
+					resetTimeout()
+				}
+			}
+			if err := mc.WriteMsg(resp); err != nil {
+				pv.countConnError("write")
 				pv.Logger.Error("couldn't write message: %v\n", err)
 			}
+			if errors.Is(err, ErrUnknownMessage) {
+				// Newer validators may send messages SignCTRL doesn't understand yet.
+				// They are rejected, but aren't worth an error on every occurrence.
+				if t := fmt.Sprintf("%T", msg.Sum); !unsupported[t] {
+					unsupported[t] = true
+					pv.Logger.Warn("The validator sent a message SignCTRL doesn't support, rejecting all messages of this type: %v\n", err)
+				}
+				err = nil
+			}
 			if err != nil {
-				pv.Logger.Error("couldn't handle request: %v\n", err)
-				if err == types.ErrMustShutdown || err == ErrRankObsolete {
+				pv.Logger.Error("couldn't handle request [%v]: %v\n", pv.recordError(err), err)
+				if errors.Is(err, types.ErrMustShutdown) || errors.Is(err, ErrRankObsolete) {
 					pv.Logger.Debug("Terminating run goroutine: %v\n", err)
+					pv.emit(watchtower.EventMustShutdown, "Shutting down: %v", err)
+					stopWatch()
 					if err := pv.Stop(); err != nil {
 						pv.Logger.Error("%v", err)
 					}
@@ -159,23 +643,132 @@ func (pv *SCFilePV) run() {
 // Implements the Service interface.
 func (pv *SCFilePV) OnStart() (err error) {
 	pv.Logger.Info("Starting SignCTRL on rank %v...\n", pv.GetRank())
+	pv.startedAt = pv.Clock.Now()
+	pv.updateRankGauges()
+
+	// Push alerts to the webhooks.
+	if pv.Config.Alerts.Enabled() {
+		pv.startAlerts()
+	}
+
+	// Never sign anything tmkms has already signed.
+	if pv.Config.Privval.TmkmsStateFile != "" {
+		if err := pv.importTmkmsWatermark(); err != nil {
+			return err
+		}
+	}
+
+	// A node that starts on rank 1 for the first time takes the signing authority in
+	// the first epoch. A restarted one keeps its epoch, so that it is fenced off if
+	// another node took over in the meantime.
+	if pv.GetRank() == 1 && pv.GetEpoch() == 0 {
+		pv.takeEpoch()
+	}
+
+	// Save the rank the node starts on right away, so that the state file is valid
+	// even if the node crashes before it is stopped.
+	if err := pv.saveState(); err != nil {
+		return fmt.Errorf("couldn't save state to %v: %w", config.StateFile, err)
+	}
+
+	// Remember the public key, so that the validator's PubKeyRequests are answered
+	// even if the signer backend becomes unavailable.
+	if _, err := pv.pubKey(); err != nil {
+		pv.Logger.Warn("%v", err)
+	}
+
+	// Keep the signing outcomes for reports.
+	if pv.Config.History.Enabled() {
+		pv.openHistory(pv.Context())
+	}
+
+	// Exchange heartbeats with the other nodes in the set. The node is set up before
+	// the HTTP server serves its status.
+	if pv.Config.P2P.Enabled() {
+		if err := pv.startP2P(pv.Context()); err != nil {
+			return err
+		}
+	}
+
+	// Let the set elect the signer instead of counting missed blocks.
+	if pv.Config.Base.UsesRaft() {
+		if err := pv.startElection(pv.Context()); err != nil {
+			return err
+		}
+	}
+
+	// Only sign while holding the lock on the signing authority.
+	if pv.Config.Lock.Enabled() {
+		if err := pv.startLock(pv.Context()); err != nil {
+			return err
+		}
+	}
 
 	// Start http server.
-	if err := pv.StartHTTPServer(); err != nil {
-		return err
+	if pv.HTTP != nil {
+		if err := pv.StartHTTPServer(); err != nil {
+			return err
+		}
 	}
 
-	// Dial the validator.
-	if pv.SecretConn, err = connection.RetryDial(
-		config.Dir(),
-		pv.Config.Base.ValidatorListenAddress,
-		pv.Logger,
-	); err != nil {
-		return err
+	// The clock and gauges may have been replaced after the RPC client was created.
+	pv.RPC.Clock, pv.RPC.Gauges = pv.Clock, pv.Gauges
+
+	// Keep track of the RPC server queries are sent to.
+	if pv.RPCPool != nil {
+		pv.RPCPool.Clock = pv.Clock
+		goroutines.Go("rpc_pool", func() { pv.monitorRPCEndpoints(pv.Context()) })
+	}
+
+	pv.Logger.Debug("Using the %v chain adapter for %v", pv.Adapter.Name(), pv.Config.Privval.ChainID)
+
+	// Make sure the key is part of the active validator set.
+	ctx := pv.Context()
+	if pv.Config.Privval.ValidatorSetCheck != "off" {
+		goroutines.Go("valset", func() { pv.checkValidatorSet(ctx) })
+	}
+
+	// Learn about new blocks as soon as they are committed. The commit watcher
+	// subscribes to them itself.
+	if pv.Config.Base.BlockSubscription && pv.Config.RPC.GetWatchInterval() == 0 {
+		goroutines.Go("blocks", func() { pv.watchBlocks(ctx) })
+	}
+
+	// Check the commitsigs even while the validator sends no sign requests.
+	if pv.Config.RPC.GetWatchInterval() > 0 {
+		goroutines.Go("commits", func() { pv.watchCommits(ctx) })
+	}
+
+	// Keep track of the validator's status in the slashing and staking modules.
+	if pv.Config.Slashing.Enabled() {
+		goroutines.Go("slashing", func() { pv.monitorSlashing(ctx) })
+	}
+
+	// Keep track of the upgrades scheduled via governance.
+	if pv.Config.Upgrades.QueriesPlan() {
+		goroutines.Go("upgrades", func() { pv.monitorUpgrades(ctx) })
+	}
+
+	// Make sure the clock is roughly in sync with the other validators' ones.
+	if pv.Config.Clock.Enabled() {
+		goroutines.Go("clock", func() { pv.monitorClock(ctx) })
+	}
+
+	// Detect goroutines that stopped making progress.
+	if pv.watchdog != nil {
+		goroutines.Go("watchdog", func() { pv.runWatchdog(ctx) })
 	}
 
-	// Run the main loop.
-	go pv.run()
+	// Connect to the validator in the background, so that the HTTP server is served
+	// while the validator or its RPC server are unreachable.
+	pv.setConnState(ConnConnecting)
+	goroutines.Go("connect", func() { pv.connect(ctx) })
+
+	// Report the validator as disconnected if the connection is lost for too long.
+	if pv.Config.Alerts.DisconnectTimeout != "" {
+		goroutines.Go("conn_monitor", func() { pv.monitorConnection(ctx) })
+	}
+	pv.emit(watchtower.EventStarted, "Started SignCTRL on rank %v", pv.GetRank())
 
 	return nil
 }
@@ -184,14 +777,20 @@ func (pv *SCFilePV) OnStart() (err error) {
 // Implements the Service interface.
 func (pv *SCFilePV) OnStop() error {
 	pv.Logger.Info("Stopping SignCTRL on rank %v...\n", pv.GetRank())
+	pv.emit(watchtower.EventStopped, "Stopping SignCTRL on rank %v", pv.GetRank())
+	defer pv.stopAlerts()
 
 	// Close the http server.
-	pv.Logger.Info("Stopping the HTTP server...")
-	pv.HTTP.Close()
+	if pv.HTTP != nil {
+		pv.Logger.Info("Stopping the HTTP server...")
+		pv.HTTP.Close()
+	}
 
-	// Save rank to last_rank.json file if the shutdown was not self-induced.
-	pv.State.LastRank = pv.GetRank()
-	if err := pv.State.Save(config.Dir()); err != nil {
+	// Let the next signer take over right away.
+	pv.releaseLock()
+
+	// Save the state, so that the node resumes from it when it's started again.
+	if err := pv.saveState(); err != nil {
 		pv.Logger.Error("couldn't save state to %v: %v\n", config.StateFile, err)
 		return err
 	}
@@ -203,13 +802,45 @@ func (pv *SCFilePV) OnStop() error {
 // blocks in a row.
 // Implements the SignCtrled interface.
 func (pv *SCFilePV) OnMissedTooMany() {
+	pv.emit(watchtower.EventMissedTooMany, "Missed too many blocks in a row (threshold %v)", pv.GetThreshold())
+	if pv.Gauges.MissedInARowGauge == nil {
+		return
+	}
 	pv.Logger.Debug("Setting signctrl_missed_blocks_in_a_row gauge to %v\n", pv.GetMissedInARow())
 	pv.Gauges.MissedInARowGauge.Set(float64(pv.GetMissedInARow()))
 }
 
-// OnPromote sets the prometheus gauge for the validator's rank.
+// OnPromote takes the signing authority in a new epoch if the validator is promoted
+// to rank 1, persists the validator's new rank, so that a restart doesn't undo the
+// promotion, and sets the prometheus gauge for it.
 // Implements the SignCtrled interface.
 func (pv *SCFilePV) OnPromote() {
+	pv.emit(watchtower.EventPromoted, "Promoted to rank %v", pv.GetRank())
+	if pv.GetRank() == 1 {
+		pv.takeEpoch()
+	}
+	if err := pv.saveState(); err != nil {
+		pv.Logger.Error("couldn't save state to %v: %v\n", config.StateFile, err)
+	}
+	if pv.Gauges.RankGauge == nil {
+		return
+	}
+	pv.Logger.Debug("Setting signctrl_rank gauge to %v\n", pv.GetRank())
+	pv.Gauges.RankGauge.Set(float64(pv.GetRank()))
+}
+
+// OnDemote releases the signing lock, if any, persists the validator's new rank, so
+// that it isn't started on rank 1 again, and sets the prometheus gauge for it.
+// Implements the SignCtrled interface.
+func (pv *SCFilePV) OnDemote() {
+	pv.emit(watchtower.EventDemoted, "Demoted to rank %v", pv.GetRank())
+	pv.releaseLock()
+	if err := pv.saveState(); err != nil {
+		pv.Logger.Error("couldn't save state to %v: %v\n", config.StateFile, err)
+	}
+	if pv.Gauges.RankGauge == nil {
+		return
+	}
 	pv.Logger.Debug("Setting signctrl_rank gauge to %v\n", pv.GetRank())
 	pv.Gauges.RankGauge.Set(float64(pv.GetRank()))
 }
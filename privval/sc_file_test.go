@@ -1,6 +1,7 @@
 package privval
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -36,7 +37,7 @@ func (tpv *TestFilePV) SignProposal(chainID string, proposal *tm_prototypes.Prop
 	return errors.New("")
 }
 
-func testConfig(t *testing.T) config.Config {
+func testConfig(t testing.TB) config.Config {
 	t.Helper()
 	return config.Config{
 		Base: config.Base{
@@ -54,7 +55,7 @@ func testConfig(t *testing.T) config.Config {
 	}
 }
 
-func testState(t *testing.T) config.State {
+func testState(t testing.TB) config.State {
 	t.Helper()
 	return config.State{
 		LastHeight: 1,
@@ -62,7 +63,7 @@ func testState(t *testing.T) config.State {
 	}
 }
 
-func testFilePV(t *testing.T) tm_types.PrivValidator {
+func testFilePV(t testing.TB) tm_types.PrivValidator {
 	t.Helper()
 	priv := tm_ed25519.GenPrivKey()
 	return &tm_privval.FilePV{
@@ -81,15 +82,23 @@ func testFilePV(t *testing.T) tm_types.PrivValidator {
 	}
 }
 
-func mockSCFilePV(t *testing.T) *SCFilePV {
+func mockSCFilePV(t testing.TB) *SCFilePV {
 	t.Helper()
-	return NewSCFilePV(
+	pv := NewSCFilePV(
 		types.NewSyncLogger(ioutil.Discard, "", 0),
 		testConfig(t),
 		testState(t),
 		testFilePV(t),
 		&http.Server{Addr: fmt.Sprintf(":%v", DefaultHTTPPort)},
 	)
+	pv.CfgDir = t.TempDir()
+
+	// Don't ask the validator's RPC server for its capabilities.
+	pv.QueryVersion = func(ctx context.Context) (string, error) {
+		return "0.34.8", nil
+	}
+
+	return pv
 }
 
 func TestKeyFilePath(t *testing.T) {
@@ -0,0 +1,40 @@
+package privval
+
+import (
+	"fmt"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+)
+
+var (
+	// ErrBadSignature is returned if a signature produced by the signer backend doesn't
+	// verify against its public key. It is never sent to the validator.
+	ErrBadSignature = types.NewError(types.CodeSigningFailed, "produced signature doesn't verify")
+)
+
+// verifySignature verifies a signature produced by the signer backend against the
+// given sign bytes and the validator's public key. A signature that doesn't verify,
+// e.g. due to an HSM glitch or a wrong key handle, would be dropped by the network,
+// so the block would be missed without any explanation.
+func (pv *SCFilePV) verifySignature(signBytes, sig []byte) error {
+	pub, err := pv.pubKeyLocked()
+	if err != nil {
+		return err
+	}
+	if !pub.VerifySignature(signBytes, sig) {
+		return fmt.Errorf("signature %X doesn't verify against public key %v", sig, pub.Address())
+	}
+
+	return nil
+}
+
+// reportBadSignature alerts about a signature that was refused because it doesn't
+// verify.
+func (pv *SCFilePV) reportBadSignature(reqData sharedSignRequestData, err error) {
+	pv.Logger.Error("Refusing to send the %v signature for block height %v: %v", reqData.msgType, reqData.height, err)
+	pv.emit(watchtower.EventBadSignature, "Refused to send a bad %v signature for block height %v", reqData.msgType, reqData.height)
+	if pv.Gauges.BadSignaturesCounter != nil {
+		pv.Gauges.BadSignaturesCounter.Inc()
+	}
+}
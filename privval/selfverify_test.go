@@ -0,0 +1,90 @@
+package privval
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_prototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+// glitchyPV is a private validator whose signatures are corrupted, e.g. by a glitch
+// of its HSM.
+type glitchyPV struct {
+	tm_types.PrivValidator
+}
+
+func (pv glitchyPV) SignVote(chainID string, vote *tm_prototypes.Vote) error {
+	if err := pv.PrivValidator.SignVote(chainID, vote); err != nil {
+		return err
+	}
+	vote.Signature[0] ^= 0xff
+
+	return nil
+}
+
+func (pv glitchyPV) SignProposal(chainID string, proposal *tm_prototypes.Proposal) error {
+	if err := pv.PrivValidator.SignProposal(chainID, proposal); err != nil {
+		return err
+	}
+	proposal.Signature[0] ^= 0xff
+
+	return nil
+}
+
+// signingSCFilePV returns a mock SCFilePV that signs with the given private validator.
+func signingSCFilePV(t *testing.T, wrap func(tm_types.PrivValidator) tm_types.PrivValidator) *SCFilePV {
+	t.Helper()
+	dir := t.TempDir()
+	pv := mockSCFilePV(t)
+	pv.TMFilePV = wrap(tm_privval.GenFilePV(filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile)))
+	pv.Gauges.BadSignaturesCounter = prometheus.NewCounter(prometheus.CounterOpts{Name: "test_bad_signatures"})
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		return testBlockResult(t).Result, nil
+	}
+
+	return pv
+}
+
+func TestHandleSignRequest_VerifiesSignature(t *testing.T) {
+	pv := signingSCFilePV(t, func(pv tm_types.PrivValidator) tm_types.PrivValidator { return pv })
+
+	msg, err := handleSignRequest(context.Background(), testSignVoteRequest(t), pv)
+	require.NoError(t, err)
+	assert.NotEmpty(t, msg.GetSignedVoteResponse().Vote.Signature)
+	assert.Equal(t, 0.0, prom_testutil.ToFloat64(pv.Gauges.BadSignaturesCounter))
+}
+
+func TestHandleSignRequest_BadVoteSignature(t *testing.T) {
+	pv := signingSCFilePV(t, func(pv tm_types.PrivValidator) tm_types.PrivValidator { return glitchyPV{pv} })
+
+	// The bad signature must not be sent.
+	msg, err := handleSignRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.ErrorIs(t, err, ErrBadSignature)
+	require.NotNil(t, msg.GetSignedVoteResponse())
+	assert.Empty(t, msg.GetSignedVoteResponse().Vote.Signature)
+	assert.NotNil(t, msg.GetSignedVoteResponse().Error)
+	assert.Equal(t, 1.0, prom_testutil.ToFloat64(pv.Gauges.BadSignaturesCounter))
+
+	events, _, _ := pv.watchEvents.Since(0)
+	require.NotEmpty(t, events)
+	assert.Equal(t, watchtower.EventBadSignature, events[len(events)-1].Type)
+}
+
+func TestHandleSignRequest_BadProposalSignature(t *testing.T) {
+	pv := signingSCFilePV(t, func(pv tm_types.PrivValidator) tm_types.PrivValidator { return glitchyPV{pv} })
+
+	msg, err := handleSignRequest(context.Background(), testSignProposalRequest(t), pv)
+	assert.ErrorIs(t, err, ErrBadSignature)
+	require.NotNil(t, msg.GetSignedProposalResponse())
+	assert.Empty(t, msg.GetSignedProposalResponse().Proposal.Signature)
+	assert.Equal(t, 1.0, prom_testutil.ToFloat64(pv.Gauges.BadSignaturesCounter))
+}
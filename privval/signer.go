@@ -0,0 +1,230 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+var (
+	// ErrUnknownBackend is returned if a signer backend is requested that is not
+	// registered.
+	ErrUnknownBackend = types.NewError(types.CodeInvalidConfig, "unknown signer backend")
+
+	// ErrPubKeyMismatch is returned if the public key of a new signer backend doesn't
+	// match the public key of the one currently in use.
+	ErrPubKeyMismatch = types.NewError(types.CodeInvalidConfig, "public key of the new signer backend doesn't match")
+
+	// ErrSignStateBehind is returned if the last sign state of a new signer backend is
+	// behind the one of the backend currently in use, as swapping to it could lead to
+	// double-signing.
+	ErrSignStateBehind = types.NewError(types.CodeDoubleSign, "last sign state of the new signer backend is behind")
+)
+
+// SignerBackend creates a private validator from the given parameters, e.g. the paths
+// to its key files or the address of an HSM.
+type SignerBackend func(params map[string]string) (tm_types.PrivValidator, error)
+
+// contextSigner is implemented by signer backends whose requests can be canceled, e.g.
+// because they sign over the network. The request handler passes the context of the
+// sign request to them, so that they stop signing once SignCTRL is stopped.
+type contextSigner interface {
+	SignVoteContext(ctx context.Context, chainID string, vote *tm_typesproto.Vote) error
+	SignProposalContext(ctx context.Context, chainID string, proposal *tm_typesproto.Proposal) error
+}
+
+// signVote has pv sign the given vote, passing ctx on if pv is a contextSigner.
+func signVote(ctx context.Context, pv tm_types.PrivValidator, chainID string, vote *tm_typesproto.Vote) error {
+	if cs, ok := pv.(contextSigner); ok {
+		return cs.SignVoteContext(ctx, chainID, vote)
+	}
+
+	return pv.SignVote(chainID, vote)
+}
+
+// signProposal has pv sign the given proposal, passing ctx on if pv is a
+// contextSigner.
+func signProposal(ctx context.Context, pv tm_types.PrivValidator, chainID string, proposal *tm_typesproto.Proposal) error {
+	if cs, ok := pv.(contextSigner); ok {
+		return cs.SignProposalContext(ctx, chainID, proposal)
+	}
+
+	return pv.SignProposal(chainID, proposal)
+}
+
+var (
+	// backendsMtx guards backends.
+	backendsMtx sync.RWMutex
+
+	// backends maps the names of the signer backends to the functions creating them.
+	backends = make(map[string]SignerBackend)
+)
+
+func init() {
+	RegisterSignerBackend("file", fileSignerBackend)
+	RegisterSignerBackend("grpc", grpcSignerBackend)
+	RegisterSignerBackend("awskms", kmsSignerBackend)
+}
+
+// RegisterSignerBackend registers b under the given name, so that SignCTRL can swap to
+// it at runtime. An already registered backend with the same name is replaced, and
+// passing a nil backend removes the registration.
+func RegisterSignerBackend(name string, b SignerBackend) {
+	backendsMtx.Lock()
+	defer backendsMtx.Unlock()
+
+	if b == nil {
+		delete(backends, name)
+		return
+	}
+	backends[name] = b
+}
+
+// SignerBackends returns the names of all registered signer backends in alphabetical
+// order.
+func SignerBackends() []string {
+	backendsMtx.RLock()
+	defer backendsMtx.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// NewSigner creates a new private validator using the signer backend registered under
+// the given name.
+func NewSigner(backend string, params map[string]string) (tm_types.PrivValidator, error) {
+	backendsMtx.RLock()
+	b, ok := backends[backend]
+	backendsMtx.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownBackend, backend)
+	}
+
+	return b(params)
+}
+
+// fileSignerBackend loads a tm_privval.FilePV from the key_file and state_file
+// parameters.
+func fileSignerBackend(params map[string]string) (tm_types.PrivValidator, error) {
+	keyFile, stateFile := params["key_file"], params["state_file"]
+	if keyFile == "" || stateFile == "" {
+		return nil, errors.New("key_file and state_file must not be empty")
+	}
+
+	// tm_privval.LoadFilePV exits the process on invalid files, so make sure they can
+	// be loaded beforehand.
+	var key tm_privval.FilePVKey
+	if err := unmarshalFile(keyFile, &key); err != nil {
+		return nil, err
+	}
+	if key.PrivKey == nil {
+		return nil, fmt.Errorf("%v is missing the private key", keyFile)
+	}
+	var state tm_privval.FilePVLastSignState
+	if err := unmarshalFile(stateFile, &state); err != nil {
+		return nil, err
+	}
+
+	return tm_privval.LoadFilePV(keyFile, stateFile), nil
+}
+
+// unmarshalFile unmarshals the JSON file at the given path into v.
+func unmarshalFile(path string, v interface{}) error {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := tm_json.Unmarshal(bz, v); err != nil {
+		return fmt.Errorf("couldn't read %v: %w", path, err)
+	}
+
+	return nil
+}
+
+// isSignStateBehind returns true if the last sign state of next is behind the one of
+// cur. Only file private validators keep a last sign state that can be compared.
+func isSignStateBehind(cur, next tm_types.PrivValidator) bool {
+	curFilePV, ok := cur.(*tm_privval.FilePV)
+	if !ok {
+		return false
+	}
+	nextFilePV, ok := next.(*tm_privval.FilePV)
+	if !ok {
+		return false
+	}
+
+	c, n := curFilePV.LastSignState, nextFilePV.LastSignState
+	if n.Height != c.Height {
+		return n.Height < c.Height
+	}
+	if n.Round != c.Round {
+		return n.Round < c.Round
+	}
+
+	return n.Step < c.Step
+}
+
+// SwapPrivValidator atomically replaces the private validator that signs votes and
+// proposals with next. Requests that are being handled are drained first, and no
+// new requests are handled until the swap is done. The swap is refused if the public
+// keys of both private validators don't match.
+func (pv *SCFilePV) SwapPrivValidator(ctx context.Context, next tm_types.PrivValidator) error {
+	nextPub, err := next.GetPubKey()
+	if err != nil {
+		return fmt.Errorf("couldn't get public key of the new signer backend: %w", err)
+	}
+
+	// Wait for all requests that are being handled to finish.
+	locked := make(chan struct{})
+	go func() {
+		pv.signerMtx.Lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-ctx.Done():
+		// Release the lock once it is acquired, as nobody is waiting for it anymore.
+		go func() {
+			<-locked
+			pv.signerMtx.Unlock()
+		}()
+		return ctx.Err()
+	}
+	defer pv.signerMtx.Unlock()
+
+	// The current backend may be down, which is why it's swapped, so its key is
+	// taken from the cache.
+	curPub, err := pv.pubKeyLocked()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(curPub.Bytes(), nextPub.Bytes()) {
+		return fmt.Errorf("%w: expected %v, instead got %v", ErrPubKeyMismatch, curPub.Address(), nextPub.Address())
+	}
+	if isSignStateBehind(pv.TMFilePV, next) {
+		return ErrSignStateBehind
+	}
+
+	pv.Logger.Info("Swapped the signer backend (%T -> %T)", pv.TMFilePV, next)
+	pv.emit(watchtower.EventSignerSwapped, "Swapped the signer backend (%T -> %T)", pv.TMFilePV, next)
+	pv.TMFilePV = next
+
+	return nil
+}
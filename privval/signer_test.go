@@ -0,0 +1,112 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+func TestNewSigner_File(t *testing.T) {
+	dir := t.TempDir()
+	keyFile, stateFile := filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile)
+	filePV := tm_privval.GenFilePV(keyFile, stateFile)
+	filePV.Save()
+
+	signer, err := NewSigner("file", map[string]string{"key_file": keyFile, "state_file": stateFile})
+	assert.NoError(t, err)
+	pub, err := signer.GetPubKey()
+	assert.NoError(t, err)
+	assert.Equal(t, filePV.Key.PubKey, pub)
+
+	_, err = NewSigner("file", map[string]string{"key_file": keyFile})
+	assert.Error(t, err)
+
+	_, err = NewSigner("file", map[string]string{"key_file": stateFile, "state_file": stateFile})
+	assert.Error(t, err)
+
+	_, err = NewSigner("unknown", nil)
+	assert.ErrorIs(t, err, ErrUnknownBackend)
+}
+
+func TestRegisterSignerBackend(t *testing.T) {
+	RegisterSignerBackend("test", func(params map[string]string) (tm_types.PrivValidator, error) {
+		return testFilePV(t), nil
+	})
+	assert.Equal(t, []string{"awskms", "file", "grpc", "test"}, SignerBackends())
+
+	RegisterSignerBackend("test", nil)
+	assert.Equal(t, []string{"awskms", "file", "grpc"}, SignerBackends())
+}
+
+func TestSwapPrivValidator(t *testing.T) {
+	pv := mockSCFilePV(t)
+	cur := pv.TMFilePV.(*tm_privval.FilePV)
+
+	// Different keys must be refused.
+	err := pv.SwapPrivValidator(context.Background(), testFilePV(t))
+	assert.ErrorIs(t, err, ErrPubKeyMismatch)
+	assert.Equal(t, cur, pv.TMFilePV)
+
+	// The same key with an older last sign state must be refused.
+	cur.LastSignState.Height = 10
+	behind := &tm_privval.FilePV{Key: cur.Key, LastSignState: tm_privval.FilePVLastSignState{Height: 9}}
+	err = pv.SwapPrivValidator(context.Background(), behind)
+	assert.ErrorIs(t, err, ErrSignStateBehind)
+	assert.Equal(t, cur, pv.TMFilePV)
+
+	next := &tm_privval.FilePV{Key: cur.Key, LastSignState: tm_privval.FilePVLastSignState{Height: 10}}
+	err = pv.SwapPrivValidator(context.Background(), next)
+	assert.NoError(t, err)
+	assert.Equal(t, next, pv.TMFilePV)
+}
+
+func TestSwapPrivValidator_Canceled(t *testing.T) {
+	pv := mockSCFilePV(t)
+	cur := pv.TMFilePV.(*tm_privval.FilePV)
+
+	// Simulate a request that is being handled.
+	pv.signerMtx.RLock()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := pv.SwapPrivValidator(ctx, &tm_privval.FilePV{Key: cur.Key})
+	assert.ErrorIs(t, err, context.Canceled)
+	pv.signerMtx.RUnlock()
+
+	assert.Equal(t, cur, pv.TMFilePV)
+}
+
+func TestSwapSignerHandler(t *testing.T) {
+	pv := mockSCFilePV(t)
+	RegisterSignerBackend("test", func(params map[string]string) (tm_types.PrivValidator, error) {
+		return testFilePV(t), nil
+	})
+	defer RegisterSignerBackend("test", nil)
+
+	body, err := tm_json.Marshal(SwapSignerRequest{Backend: "test"})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/signer", nil)
+	rec := httptest.NewRecorder()
+	pv.swapSignerHandler(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/signer", bytes.NewReader(body))
+	req.RemoteAddr = "10.0.0.1:1234"
+	rec = httptest.NewRecorder()
+	pv.swapSignerHandler(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/signer", bytes.NewReader(body))
+	req.RemoteAddr = "127.0.0.1:1234"
+	rec = httptest.NewRecorder()
+	pv.swapSignerHandler(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
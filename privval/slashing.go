@@ -0,0 +1,178 @@
+package privval
+
+import (
+	"context"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+)
+
+var (
+	// ErrTombstoned is returned for sign requests that are received after the slashing
+	// module reported the validator as tombstoned. A tombstoned validator can never
+	// rejoin the validator set with the same key, so signing is paused for good.
+	ErrTombstoned = types.NewError(types.CodeSlashed, "validator is tombstoned")
+
+	// ErrJailed is returned for sign requests that are received while signing is
+	// paused because the validator is jailed.
+	ErrJailed = types.NewError(types.CodeSlashed, "signing is paused while the validator is jailed")
+)
+
+// SlashingStatus defines the validator's status as reported by the Cosmos SDK's
+// slashing and staking modules.
+type SlashingStatus struct {
+	Jailed              bool      `json:"jailed"`
+	JailedUntil         time.Time `json:"jailed_until"`
+	Tombstoned          bool      `json:"tombstoned"`
+	MissedBlocksCounter int64     `json:"missed_blocks_counter"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// SlashingQuerier queries the validator's status from the slashing and staking
+// modules.
+type SlashingQuerier func(ctx context.Context) (SlashingStatus, error)
+
+// querySlashing is the default SlashingQuerier of SCFilePV. It queries the LCD at the
+// configured lcd_laddr. If no valoper_address is configured, the validator is assumed
+// to be jailed until the time reported by the slashing module.
+func (pv *SCFilePV) querySlashing(ctx context.Context) (SlashingStatus, error) {
+	cfg := pv.Config.Slashing
+	info, err := pv.RPC.QuerySigningInfo(ctx, cfg.LCDListenAddress, cfg.ValconsAddress)
+	if err != nil {
+		return SlashingStatus{}, err
+	}
+
+	status := SlashingStatus{
+		Jailed:              info.JailedUntil.After(pv.Clock.Now()),
+		JailedUntil:         info.JailedUntil,
+		Tombstoned:          info.Tombstoned,
+		MissedBlocksCounter: info.MissedBlocksCounter,
+		UpdatedAt:           pv.Clock.Now(),
+	}
+	if cfg.ValoperAddress != "" {
+		val, err := pv.RPC.QueryValidator(ctx, cfg.LCDListenAddress, cfg.ValoperAddress)
+		if err != nil {
+			return SlashingStatus{}, err
+		}
+		status.Jailed = val.Jailed
+	}
+
+	return status, nil
+}
+
+// GetSlashingStatus returns the validator's last known status in the slashing and
+// staking modules. It returns false if the modules haven't been queried yet.
+func (pv *SCFilePV) GetSlashingStatus() (SlashingStatus, bool) {
+	pv.slashingMtx.RLock()
+	defer pv.slashingMtx.RUnlock()
+
+	return pv.slashing, !pv.slashing.UpdatedAt.IsZero()
+}
+
+// IsTombstoned returns true if the slashing module reported the validator as
+// tombstoned.
+func (pv *SCFilePV) IsTombstoned() bool {
+	status, _ := pv.GetSlashingStatus()
+	return status.Tombstoned
+}
+
+// IsSigningPaused returns true if signing is paused because the validator is jailed.
+func (pv *SCFilePV) IsSigningPaused() bool {
+	pv.slashingMtx.RLock()
+	defer pv.slashingMtx.RUnlock()
+
+	return pv.signingPaused
+}
+
+// setSlashingStatus updates the validator's status in the slashing and staking
+// modules and logs changes. Once tombstoned, the validator stays tombstoned. Signing
+// is paused once the validator is jailed and resumed once it is unjailed, if
+// configured to do so.
+func (pv *SCFilePV) setSlashingStatus(status SlashingStatus) {
+	pv.slashingMtx.Lock()
+	prev := pv.slashing
+	status.Tombstoned = status.Tombstoned || prev.Tombstoned
+	pv.slashing = status
+	wasPaused := pv.signingPaused
+	switch {
+	case status.Jailed && pv.Config.Slashing.PauseWhenJailed:
+		pv.signingPaused = true
+	case !status.Jailed && pv.Config.Slashing.ResumeAfterUnjail:
+		if pv.IsClockSkewed() {
+			pv.Logger.Warn("Not resuming signing while the clock is off by more than max_offset")
+			break
+		}
+		pv.signingPaused = false
+	}
+	paused := pv.signingPaused
+	pv.slashingMtx.Unlock()
+
+	if status.Tombstoned && !prev.Tombstoned {
+		pv.Logger.Error("Validator is tombstoned, pausing signing for good")
+		pv.emit(watchtower.EventTombstoned, "Validator is tombstoned")
+	}
+	if status.Jailed && !prev.Jailed {
+		pv.Logger.Warn("Validator is jailed (until %v)", status.JailedUntil)
+		pv.emit(watchtower.EventJailed, "Validator is jailed (until %v)", status.JailedUntil)
+	} else if !status.Jailed && prev.Jailed {
+		pv.Logger.Info("Validator is no longer jailed")
+		pv.emit(watchtower.EventUnjailed, "Validator is no longer jailed")
+	}
+	if paused && !wasPaused {
+		pv.Logger.Warn("Pausing signing while the validator is jailed")
+		pv.emit(watchtower.EventSigningPaused, "Paused signing while the validator is jailed")
+	} else if !paused && wasPaused {
+		pv.Logger.Info("Resuming signing after the validator was unjailed")
+		pv.emit(watchtower.EventSigningResumed, "Resumed signing after the validator was unjailed")
+	}
+
+	if pv.Gauges.JailedGauge != nil {
+		pv.Gauges.JailedGauge.Set(boolToFloat(status.Jailed))
+	}
+	if pv.Gauges.TombstonedGauge != nil {
+		pv.Gauges.TombstonedGauge.Set(boolToFloat(status.Tombstoned))
+	}
+	if pv.Gauges.SlashingMissedBlocksGauge != nil {
+		pv.Gauges.SlashingMissedBlocksGauge.Set(float64(status.MissedBlocksCounter))
+	}
+}
+
+// boolToFloat converts b into a gauge value.
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// monitorSlashing periodically queries the validator's status from the slashing and
+// staking modules until ctx is done.
+func (pv *SCFilePV) monitorSlashing(ctx context.Context) {
+	defer pv.recoverPanic("slashing")
+	defer pv.idle("slashing")
+
+	interval := pv.Config.Slashing.GetQueryInterval()
+	for {
+		// Each iteration takes up to the query timeout plus the interval.
+		pv.tick("slashing", 2*interval, nil)
+		queryCtx, cancel := context.WithTimeout(ctx, interval)
+		status, err := pv.QuerySlashing(queryCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			pv.Logger.Error("couldn't query slashing status: %v\n", err)
+		} else {
+			pv.setSlashingStatus(status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-pv.Clock.After(interval):
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package privval
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	"github.com/stretchr/testify/assert"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+func TestSetSlashingStatus(t *testing.T) {
+	pv := mockSCFilePV(t)
+	_, ok := pv.GetSlashingStatus()
+	assert.False(t, ok)
+	assert.Nil(t, pv.Status().Slashing)
+
+	now := time.Unix(0, 0)
+	pv.setSlashingStatus(SlashingStatus{Tombstoned: true, MissedBlocksCounter: 3, UpdatedAt: now})
+	status, ok := pv.GetSlashingStatus()
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), status.MissedBlocksCounter)
+	assert.True(t, pv.IsTombstoned())
+	assert.Equal(t, &status, pv.Status().Slashing)
+
+	// A tombstoned validator stays tombstoned.
+	pv.setSlashingStatus(SlashingStatus{UpdatedAt: now})
+	assert.True(t, pv.IsTombstoned())
+
+	// A tombstoned validator must not sign.
+	_, err := handleSignRequest(context.Background(), wrapMsg(&tm_privvalproto.SignVoteRequest{
+		Vote:    &tm_typesproto.Vote{Type: tm_typesproto.PrevoteType, Height: 1},
+		ChainId: "testchain",
+	}), pv)
+	assert.ErrorIs(t, err, ErrTombstoned)
+}
+
+func TestMonitorSlashing(t *testing.T) {
+	pv := mockSCFilePV(t)
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	pv.Clock = clock
+	pv.Config.Slashing.QueryInterval = "1m"
+
+	queries := make(chan struct{}, 1)
+	pv.QuerySlashing = func(ctx context.Context) (SlashingStatus, error) {
+		defer func() { queries <- struct{}{} }()
+		return SlashingStatus{Jailed: true, UpdatedAt: clock.Now()}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pv.monitorSlashing(ctx)
+		close(done)
+	}()
+
+	// The modules are queried right away and then once per interval.
+	<-queries
+	clock.BlockUntil(1)
+	status, ok := pv.GetSlashingStatus()
+	assert.True(t, ok)
+	assert.True(t, status.Jailed)
+
+	clock.Advance(time.Minute)
+	<-queries
+	clock.BlockUntil(1)
+
+	cancel()
+	<-done
+}
+
+func TestSetSlashingStatus_PauseWhenJailed(t *testing.T) {
+	now := time.Unix(0, 0)
+
+	// Signing isn't paused unless configured.
+	pv := mockSCFilePV(t)
+	pv.setSlashingStatus(SlashingStatus{Jailed: true, UpdatedAt: now})
+	assert.False(t, pv.IsSigningPaused())
+
+	pv = mockSCFilePV(t)
+	pv.Config.Slashing.PauseWhenJailed = true
+	pv.setSlashingStatus(SlashingStatus{Jailed: true, UpdatedAt: now})
+	assert.True(t, pv.IsSigningPaused())
+	assert.True(t, pv.Status().SigningPaused)
+	_, err := handleSignRequest(context.Background(), wrapMsg(&tm_privvalproto.SignVoteRequest{
+		Vote:    &tm_typesproto.Vote{Type: tm_typesproto.PrevoteType, Height: 1},
+		ChainId: "testchain",
+	}), pv)
+	assert.ErrorIs(t, err, ErrJailed)
+
+	// Signing stays paused after the unjail unless configured otherwise.
+	pv.setSlashingStatus(SlashingStatus{UpdatedAt: now})
+	assert.True(t, pv.IsSigningPaused())
+
+	pv.Config.Slashing.ResumeAfterUnjail = true
+	pv.setSlashingStatus(SlashingStatus{UpdatedAt: now})
+	assert.False(t, pv.IsSigningPaused())
+
+	var got []watchtower.EventType
+	events, _, _ := pv.watchEvents.Since(0)
+	for _, e := range events {
+		got = append(got, e.Type)
+	}
+	assert.Equal(t, []watchtower.EventType{
+		watchtower.EventJailed,
+		watchtower.EventSigningPaused,
+		watchtower.EventUnjailed,
+		watchtower.EventSigningResumed,
+	}, got)
+}
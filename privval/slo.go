@@ -0,0 +1,159 @@
+package privval
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+)
+
+// sloBucket counts the sign requests of a single minute.
+type sloBucket struct {
+	minute int64
+	total  uint64
+	within uint64
+}
+
+// sloTracker tracks how many sign requests are handled within the sign latency SLO
+// over rolling windows. The requests are counted in per-minute buckets, so its memory
+// is bounded by the largest window instead of the number of requests.
+type sloTracker struct {
+	slo     time.Duration
+	windows []time.Duration
+
+	mtx     sync.Mutex
+	buckets []sloBucket
+}
+
+// newSLOTracker returns a new sloTracker for the given metrics configuration, or nil
+// if no sign latency SLO is configured.
+func newSLOTracker(cfg config.Metrics) *sloTracker {
+	if !cfg.TracksSLO() {
+		return nil
+	}
+
+	windows := cfg.GetSLOWindows()
+	var max time.Duration
+	for _, w := range windows {
+		if w > max {
+			max = w
+		}
+	}
+
+	return &sloTracker{
+		slo:     cfg.GetSignLatencySLO(),
+		windows: windows,
+		buckets: make([]sloBucket, max/time.Minute),
+	}
+}
+
+// index returns the index of the given minute's bucket. Minutes before the Unix epoch
+// only occur with fake clocks, but mustn't lead to a negative index.
+func (st *sloTracker) index(minute int64) int {
+	n := int64(len(st.buckets))
+	return int((minute%n + n) % n)
+}
+
+// observe records a sign request that was handled at the given time and took d. It
+// returns false if the request exceeded the SLO.
+func (st *sloTracker) observe(at time.Time, d time.Duration) bool {
+	within := d <= st.slo
+	minute := at.Unix() / 60
+
+	st.mtx.Lock()
+	defer st.mtx.Unlock()
+
+	b := &st.buckets[st.index(minute)]
+	if b.minute != minute {
+		*b = sloBucket{minute: minute}
+	}
+	b.total++
+	if within {
+		b.within++
+	}
+
+	return within
+}
+
+// compliance returns the share of sign requests within the SLO over the window up to
+// the given time, and the number of requests in the window. The share is 1 if there
+// were no requests, as none of them violated the SLO.
+func (st *sloTracker) compliance(now time.Time, window time.Duration) (float64, uint64) {
+	minute := now.Unix() / 60
+
+	st.mtx.Lock()
+	defer st.mtx.Unlock()
+
+	var total, within uint64
+	for m := minute - int64(window/time.Minute) + 1; m <= minute; m++ {
+		if b := st.buckets[st.index(m)]; b.minute == m {
+			total += b.total
+			within += b.within
+		}
+	}
+	if total == 0 {
+		return 1, 0
+	}
+
+	return float64(within) / float64(total), total
+}
+
+// signSteps records how long each step of handling a sign request took, so that slow
+// requests can be attributed to e.g. the RPC server or the signer backend.
+type signSteps struct {
+	last  time.Time
+	steps []string
+	held  time.Duration
+}
+
+// done records the end of the step with the given name, which started at the end of
+// the previous one.
+func (s *signSteps) done(name string, now time.Time) {
+	s.steps = append(s.steps, fmt.Sprintf("%v=%v", name, now.Sub(s.last)))
+	s.last = now
+}
+
+// hold records the end of a step the request was deliberately held in, e.g. waiting
+// for a proposal's approval. It isn't counted towards the request's latency.
+func (s *signSteps) hold(name string, now time.Time) {
+	s.held += now.Sub(s.last)
+	s.done(name, now)
+}
+
+// String returns the steps in the order they were taken.
+func (s *signSteps) String() string {
+	return strings.Join(s.steps, " ")
+}
+
+// observeSignLatency records the latency of the sign request described by reqData,
+// which started at start and returned err, with the SLO tracker. Requests exceeding
+// the SLO are logged along with their context, and the SLO compliance metrics are
+// updated.
+func (pv *SCFilePV) observeSignLatency(reqData sharedSignRequestData, start time.Time, steps *signSteps, err error) {
+	if pv.slo == nil {
+		return
+	}
+
+	now := pv.Clock.Now()
+	d := now.Sub(start) - steps.held
+	if !pv.slo.observe(now, d) {
+		outcome := "signed"
+		if err != nil {
+			outcome = err.Error()
+		}
+		pv.Logger.Warn("%v for height %v, round %v took %v, exceeding the SLO of %v (rank: %v, steps: %v, outcome: %v)",
+			reqData.msgType, reqData.height, reqData.round, d, pv.slo.slo, pv.GetRank(), steps, outcome)
+		if pv.Gauges.SlowSignRequestsCounter != nil {
+			pv.Gauges.SlowSignRequestsCounter.Inc()
+		}
+	}
+
+	if pv.Gauges.SLOComplianceGauge != nil {
+		for i, w := range pv.slo.windows {
+			ratio, _ := pv.slo.compliance(now, w)
+			pv.Gauges.SLOComplianceGauge.WithLabelValues(pv.Config.Metrics.SLOWindows[i]).Set(ratio)
+		}
+	}
+}
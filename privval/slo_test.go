@@ -0,0 +1,104 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+func TestNewSLOTracker(t *testing.T) {
+	assert.Nil(t, newSLOTracker(config.Metrics{}))
+
+	st := newSLOTracker(config.Metrics{SignLatencySLO: "500ms", SLOWindows: []string{"5m", "1h"}})
+	require.NotNil(t, st)
+	assert.Equal(t, 500*time.Millisecond, st.slo)
+	assert.Len(t, st.buckets, 60)
+}
+
+func TestSLOTrackerCompliance(t *testing.T) {
+	st := newSLOTracker(config.Metrics{SignLatencySLO: "1s", SLOWindows: []string{"5m", "1h"}})
+	now := time.Unix(3600, 0)
+
+	// No requests don't violate the SLO.
+	ratio, n := st.compliance(now, 5*time.Minute)
+	assert.Equal(t, 1.0, ratio)
+	assert.Zero(t, n)
+
+	// An hour ago, half of the requests exceeded the SLO.
+	assert.True(t, st.observe(now.Add(-30*time.Minute), time.Second))
+	assert.False(t, st.observe(now.Add(-30*time.Minute), 2*time.Second))
+
+	// Recently, all of them were within.
+	for i := 0; i < 4; i++ {
+		assert.True(t, st.observe(now.Add(-time.Minute), 100*time.Millisecond))
+	}
+
+	ratio, n = st.compliance(now, 5*time.Minute)
+	assert.Equal(t, 1.0, ratio)
+	assert.EqualValues(t, 4, n)
+	ratio, n = st.compliance(now, time.Hour)
+	assert.Equal(t, 5.0/6.0, ratio)
+	assert.EqualValues(t, 6, n)
+
+	// Requests older than the largest window are forgotten once their bucket is reused.
+	assert.True(t, st.observe(now.Add(30*time.Minute), 0))
+	ratio, n = st.compliance(now.Add(30*time.Minute), time.Hour)
+	assert.Equal(t, 1.0, ratio)
+	assert.EqualValues(t, 5, n)
+}
+
+func TestSignSteps(t *testing.T) {
+	start := time.Unix(0, 0)
+	steps := &signSteps{last: start}
+	steps.done("block", start.Add(time.Second))
+	steps.hold("approval", start.Add(11*time.Second))
+	steps.done("sign", start.Add(12*time.Second))
+	assert.Equal(t, "block=1s approval=10s sign=1s", steps.String())
+	assert.Equal(t, 10*time.Second, steps.held)
+}
+
+func TestHandleSignRequest_SLO(t *testing.T) {
+	var buf bytes.Buffer
+	dir := t.TempDir()
+	pv := mockSCFilePV(t)
+	pv.TMFilePV = tm_privval.GenFilePV(filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile))
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	pv.Clock = clock
+	pv.Config.Metrics.SignLatencySLO = "1s"
+	pv.Config.Metrics.SLOWindows = []string{"5m"}
+	pv.slo = newSLOTracker(pv.Config.Metrics)
+	pv.Gauges.SLOComplianceGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_slo_compliance"}, []string{"window"})
+	pv.Gauges.SlowSignRequestsCounter = prometheus.NewCounter(prometheus.CounterOpts{Name: "test_slow_sign_requests"})
+
+	// The RPC server takes longer than the SLO to respond.
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		clock.Advance(2 * time.Second)
+		return testBlockResult(t).Result, nil
+	}
+	_, err := handleSignRequest(context.Background(), testSignVoteRequest(t), pv)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "took 2s, exceeding the SLO of 1s")
+	assert.Contains(t, buf.String(), "steps: block=2s sign=0s")
+	assert.Equal(t, 1.0, prom_testutil.ToFloat64(pv.Gauges.SlowSignRequestsCounter))
+	assert.Equal(t, 0.0, prom_testutil.ToFloat64(pv.Gauges.SLOComplianceGauge.WithLabelValues("5m")))
+
+	// Requests within the SLO aren't logged.
+	buf.Reset()
+	_, err = handleSignRequest(context.Background(), testSignVoteRequest(t), pv)
+	require.NoError(t, err)
+	assert.NotContains(t, buf.String(), "exceeding the SLO")
+	assert.Equal(t, 1.0, prom_testutil.ToFloat64(pv.Gauges.SlowSignRequestsCounter))
+	assert.Equal(t, 0.5, prom_testutil.ToFloat64(pv.Gauges.SLOComplianceGauge.WithLabelValues("5m")))
+}
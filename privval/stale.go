@@ -0,0 +1,167 @@
+package privval
+
+import (
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+)
+
+// staleChange is a change of the validator's staleness.
+type staleChange int
+
+const (
+	// staleUnchanged means the validator's staleness didn't change.
+	staleUnchanged staleChange = iota
+
+	// staleStarted means the validator became stale.
+	staleStarted
+
+	// staleEnded means the validator is no longer stale.
+	staleEnded
+)
+
+// staleDetector tells a validator that stopped advancing apart from a quiet network.
+// The validator is stale if it neither sent a sign request nor advanced the height of
+// its RPC server for the stale timeout, while another RPC endpoint saw the network
+// advance in the meantime. All methods are no-ops on a nil staleDetector.
+type staleDetector struct {
+	timeout time.Duration
+
+	mtx         sync.Mutex
+	lastRequest time.Time
+
+	// validatorHeight is the highest height of the validator's RPC server, which it
+	// reached at validatorSince.
+	validatorHeight int64
+	validatorSince  time.Time
+
+	// networkHeight is the highest height of the further RPC endpoints, which they
+	// reached at networkSince.
+	networkHeight int64
+	networkSince  time.Time
+
+	// staleSince is the time the validator became stale, or zero if it isn't.
+	staleSince time.Time
+}
+
+// newStaleDetector returns a new staleDetector for the given configuration, or nil if
+// the detection is disabled or there are no further RPC endpoints to compare with.
+func newStaleDetector(cfg config.RPC) *staleDetector {
+	if cfg.GetStaleTimeout() == 0 || len(cfg.Endpoints) == 0 {
+		return nil
+	}
+
+	return &staleDetector{timeout: cfg.GetStaleTimeout()}
+}
+
+// request records a sign request from the validator.
+func (d *staleDetector) request(now time.Time) {
+	if d == nil {
+		return
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	d.lastRequest = now
+}
+
+// observe records the latest heights of the validator's RPC server and of the network,
+// which are 0 if unknown, and returns how the validator's staleness changed.
+func (d *staleDetector) observe(now time.Time, validator, network int64) staleChange {
+	if d == nil {
+		return staleUnchanged
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if d.validatorSince.IsZero() || validator > d.validatorHeight {
+		d.validatorHeight, d.validatorSince = validator, now
+	}
+	if d.networkSince.IsZero() || network > d.networkHeight {
+		d.networkHeight, d.networkSince = network, now
+	}
+
+	// The validator is active as long as it sends requests or its height advances.
+	active := d.validatorSince
+	if d.lastRequest.After(active) {
+		active = d.lastRequest
+	}
+	stale := now.Sub(active) >= d.timeout &&
+		d.networkHeight > d.validatorHeight &&
+		d.networkSince.After(d.validatorSince)
+
+	switch {
+	case stale && d.staleSince.IsZero():
+		d.staleSince = now
+		return staleStarted
+	case !stale && !d.staleSince.IsZero():
+		d.staleSince = time.Time{}
+		return staleEnded
+	}
+
+	return staleUnchanged
+}
+
+// since returns the time the validator became stale, and false if it isn't stale.
+func (d *staleDetector) since() (time.Time, bool) {
+	if d == nil {
+		return time.Time{}, false
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	return d.staleSince, !d.staleSince.IsZero()
+}
+
+// heights returns the latest known heights of the validator and the network.
+func (d *staleDetector) heights() (validator, network int64) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	return d.validatorHeight, d.networkHeight
+}
+
+// ValidatorStaleSince returns the time the validator became stale, i.e. stopped
+// advancing while the network kept going, and false if it isn't stale.
+func (pv *SCFilePV) ValidatorStaleSince() (time.Time, bool) {
+	return pv.stale.since()
+}
+
+// checkStale compares the heights of the last health checks of the validator's RPC
+// server and the further endpoints, and reports the validator becoming stale or
+// recovering.
+func (pv *SCFilePV) checkStale() {
+	if pv.stale == nil || pv.RPCPool == nil {
+		return
+	}
+
+	// The validator's RPC server is always the pool's first endpoint.
+	endpoints := pv.RPCPool.Endpoints()
+	var network int64
+	for _, e := range endpoints[1:] {
+		if e.Error == "" && e.Height > network {
+			network = e.Height
+		}
+	}
+
+	switch pv.stale.observe(pv.Clock.Now(), endpoints[0].Height, network) {
+	case staleStarted:
+		validator, network := pv.stale.heights()
+		pv.Logger.Error("The validator is stale: no sign requests and no new blocks for %v, while the network advanced to height %v (validator at %v)", pv.stale.timeout, network, validator)
+		pv.emit(watchtower.EventValidatorStale, "The validator is stuck at height %v while the network is at height %v", validator, network)
+		if pv.Gauges.ValidatorStaleGauge != nil {
+			pv.Gauges.ValidatorStaleGauge.Set(1)
+		}
+	case staleEnded:
+		pv.Logger.Info("The validator is advancing again")
+		pv.emit(watchtower.EventValidatorRecovered, "The validator is advancing again")
+		if pv.Gauges.ValidatorStaleGauge != nil {
+			pv.Gauges.ValidatorStaleGauge.Set(0)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package privval
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStaleDetector(t *testing.T) {
+	assert.Nil(t, newStaleDetector(config.RPC{StaleTimeout: "1m"}))
+	assert.Nil(t, newStaleDetector(config.RPC{Endpoints: []string{"tcp://10.0.0.2:26657"}}))
+
+	d := newStaleDetector(config.RPC{StaleTimeout: "1m", Endpoints: []string{"tcp://10.0.0.2:26657"}})
+	require.NotNil(t, d)
+	assert.Equal(t, time.Minute, d.timeout)
+}
+
+func TestStaleDetector_Nil(t *testing.T) {
+	var d *staleDetector
+	d.request(time.Unix(0, 0))
+	assert.Equal(t, staleUnchanged, d.observe(time.Unix(0, 0), 1, 2))
+	_, ok := d.since()
+	assert.False(t, ok)
+}
+
+func TestStaleDetector_Observe(t *testing.T) {
+	now := time.Unix(0, 0)
+	d := &staleDetector{timeout: time.Minute}
+	assert.Equal(t, staleUnchanged, d.observe(now, 100, 100))
+
+	// The network advances while the validator doesn't, but the validator is only
+	// stale once it neither sent requests nor advanced for the timeout.
+	d.request(now.Add(30 * time.Second))
+	assert.Equal(t, staleUnchanged, d.observe(now.Add(time.Minute), 100, 110))
+	assert.Equal(t, staleStarted, d.observe(now.Add(90*time.Second), 100, 115))
+	assert.Equal(t, staleUnchanged, d.observe(now.Add(2*time.Minute), 100, 120))
+	since, ok := d.since()
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(90*time.Second), since)
+
+	// The validator recovers once it advances again.
+	assert.Equal(t, staleEnded, d.observe(now.Add(3*time.Minute), 101, 125))
+	_, ok = d.since()
+	assert.False(t, ok)
+}
+
+func TestStaleDetector_QuietNetwork(t *testing.T) {
+	now := time.Unix(0, 0)
+	d := &staleDetector{timeout: time.Minute}
+	d.observe(now, 100, 100)
+
+	// A halted chain isn't a stale validator.
+	assert.Equal(t, staleUnchanged, d.observe(now.Add(time.Hour), 100, 100))
+
+	// Neither is a validator whose RPC server is unreachable, while it keeps sending
+	// requests.
+	for i := time.Duration(1); i <= 5; i++ {
+		d.request(now.Add(time.Hour + i*time.Minute))
+		assert.Equal(t, staleUnchanged, d.observe(now.Add(time.Hour+i*time.Minute), 0, 100+int64(i)))
+	}
+}
+
+func TestSCFilePV_CheckStale(t *testing.T) {
+	status := func(height *int64) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprintf(rw, `{"jsonrpc":"2.0","id":-1,"result":{"node_info":{"version":"0.34.8"},"sync_info":{"latest_block_height":"%v"}}}`, atomic.LoadInt64(height))
+		}))
+	}
+	validatorHeight, sentryHeight := int64(100), int64(100)
+	validator, sentry := status(&validatorHeight), status(&sentryHeight)
+	defer validator.Close()
+	defer sentry.Close()
+
+	cfg := testConfig(t)
+	cfg.Base.ValidatorListenAddressRPC = "tcp://" + validator.Listener.Addr().String()
+	cfg.RPC.Endpoints = []string{"tcp://" + sentry.Listener.Addr().String()}
+	cfg.RPC.StaleTimeout = "1m"
+	pv := NewSCFilePV(nil, cfg, testState(t), testFilePV(t), nil)
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	pv.Clock = clock
+	check := func() {
+		pv.RPCPool.Check(context.Background())
+		pv.checkStale()
+	}
+
+	check()
+	atomic.StoreInt64(&sentryHeight, 110)
+	clock.Advance(time.Minute)
+	check()
+	assert.True(t, pv.WatchtowerStatus().ValidatorStale)
+	require.NotNil(t, pv.Status().ValidatorStaleSince)
+
+	atomic.StoreInt64(&validatorHeight, 111)
+	clock.Advance(10 * time.Second)
+	check()
+	assert.False(t, pv.WatchtowerStatus().ValidatorStale)
+	assert.Nil(t, pv.Status().ValidatorStaleSince)
+
+	events, _, _ := pv.watchEvents.Since(0)
+	var got []watchtower.EventType
+	for _, e := range events {
+		got = append(got, e.Type)
+	}
+	assert.Equal(t, []watchtower.EventType{watchtower.EventValidatorStale, watchtower.EventValidatorRecovered}, got)
+}
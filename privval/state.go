@@ -0,0 +1,84 @@
+package privval
+
+import (
+	"sync/atomic"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+// startRank returns the rank the validator starts on: the last rank from the
+// signctrl_state.json file if it was saved before, or the configured start_rank
+// otherwise. A rank that became obsolete in the meantime is still detected by the
+// last height.
+func startRank(cfg config.Config, state config.State) int {
+	if state.LastRank < 1 {
+		return cfg.Base.StartRank
+	}
+
+	return state.LastRank
+}
+
+// markRankObsolete marks the validator's rank as obsolete, e.g. because it must shut
+// down after exceeding the threshold on rank 1. The last rank of the set is saved
+// instead, so that the validator doesn't start on its obsolete rank again.
+func (pv *SCFilePV) markRankObsolete() {
+	atomic.StoreInt32(&pv.rankObsolete, 1)
+}
+
+// saveState saves the rank, the counter for missed blocks in a row along with its
+// lock and the last request and connection to the signctrl_state.json file.
+func (pv *SCFilePV) saveState() error {
+	pv.stateMtx.Lock()
+	defer pv.stateMtx.Unlock()
+
+	pv.State.LastRank = pv.GetRank()
+	if atomic.LoadInt32(&pv.rankObsolete) == 1 && pv.Config.Base.SetSize > 0 {
+		pv.State.LastRank = pv.Config.Base.SetSize
+	}
+	pv.State.MissedInARow = pv.GetMissedInARow()
+	pv.State.CounterLocked = pv.IsCounterLocked()
+
+	return pv.State.Save(pv.CfgDir)
+}
+
+// getLastHeight returns the height of the last sign request the rank was checked at.
+func (pv *SCFilePV) getLastHeight() int64 {
+	pv.stateMtx.Lock()
+	defer pv.stateMtx.Unlock()
+
+	return pv.State.LastHeight
+}
+
+// setLastHeight sets the height of the last sign request the rank was checked at.
+func (pv *SCFilePV) setLastHeight(height int64) {
+	pv.stateMtx.Lock()
+	defer pv.stateMtx.Unlock()
+
+	pv.State.LastHeight = height
+}
+
+// setLastRequest sets the round and step of the last sign request if it is at the
+// last height.
+func (pv *SCFilePV) setLastRequest(reqData sharedSignRequestData) {
+	pv.stateMtx.Lock()
+	defer pv.stateMtx.Unlock()
+
+	if reqData.height != pv.State.LastHeight {
+		return
+	}
+	pv.State.LastRound = reqData.round
+	pv.State.LastStep = stepPropose
+	if reqData.msgType != tm_typesproto.ProposalType {
+		pv.State.LastStep = voteStep(reqData.msgType)
+	}
+}
+
+// setLastConnected sets the time the connection to the validator was last
+// established.
+func (pv *SCFilePV) setLastConnected() {
+	pv.stateMtx.Lock()
+	defer pv.stateMtx.Unlock()
+
+	pv.State.LastConnected = pv.Clock.Now().UTC()
+}
@@ -0,0 +1,50 @@
+package privval
+
+import (
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+func TestStartRank(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Base.StartRank = 2
+
+	// A new state file has no rank yet.
+	assert.Equal(t, 2, startRank(cfg, config.State{LastHeight: 1}))
+
+	// The last rank is resumed, e.g. after a promotion.
+	assert.Equal(t, 1, startRank(cfg, config.State{LastHeight: 1, LastRank: 1}))
+}
+
+func TestSaveState(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.setLastHeight(10)
+	pv.setLastRequest(sharedSignRequestData{msgType: tm_typesproto.PrecommitType, height: 10, round: 2})
+	pv.setConnState(ConnConnected)
+	require.NoError(t, pv.saveState())
+
+	state, err := config.LoadOrGenState(pv.CfgDir)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), state.LastHeight)
+	assert.Equal(t, int32(2), state.LastRound)
+	assert.Equal(t, stepPrecommit, state.LastStep)
+	assert.Equal(t, pv.GetRank(), state.LastRank)
+	assert.True(t, state.CounterLocked)
+	assert.False(t, state.LastConnected.IsZero())
+
+	// Requests at other heights don't change the round and step.
+	pv.setLastRequest(sharedSignRequestData{msgType: tm_typesproto.ProposalType, height: 11})
+	assert.Equal(t, int32(2), pv.State.LastRound)
+
+	// An obsolete rank is saved as the last rank of the set, so that the node doesn't
+	// start on it again.
+	pv.markRankObsolete()
+	require.NoError(t, pv.saveState())
+	state, err = config.LoadOrGenState(pv.CfgDir)
+	require.NoError(t, err)
+	assert.Equal(t, pv.Config.Base.SetSize, state.LastRank)
+}
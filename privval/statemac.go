@@ -0,0 +1,144 @@
+package privval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/statefile"
+	"github.com/BlockscapeNetwork/signctrl/statemac"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+// stateFiles returns the paths to the mutable state files in the given directory
+// that are protected with MACs. The high watermark comes first, as it is needed to
+// verify the priv_validator_state.json file.
+func stateFiles(dir string) []string {
+	return []string{filepath.Join(dir, HighWatermarkFile), StateFilePath(dir), config.StateFilePath(dir)}
+}
+
+// verifyStateFile checks that the given state file in the given directory matches its
+// MAC. SignCTRL's own state files are recovered from their backup if they are
+// corrupted, so the backup must match the previous MAC instead then. The MAC of the
+// priv_validator_state.json file can only be updated after the private validator
+// wrote it, so the file is trusted as well if it is at exactly the high watermark,
+// which is raised before anything is signed. That's what a crash in between leaves
+// behind.
+func verifyStateFile(key []byte, dir, file string) error {
+	if file != StateFilePath(dir) {
+		if statefile.Corrupted(file) {
+			bak, err := ioutil.ReadFile(statefile.BackupPath(file))
+			if err != nil {
+				return fmt.Errorf("%v is corrupted and its backup can't be read: %w", file, err)
+			}
+			return statemac.VerifyContents(key, file, bak)
+		}
+		return statemac.Verify(key, file)
+	}
+
+	err := statemac.Verify(key, file)
+	if !errors.Is(err, statemac.ErrInvalidMAC) {
+		return err
+	}
+
+	var state tm_privval.FilePVLastSignState
+	if unmarshalFile(file, &state) != nil {
+		return err
+	}
+	var hwm highWatermark
+	hwmFile := filepath.Join(dir, HighWatermarkFile)
+	bz, readErr := ioutil.ReadFile(hwmFile)
+	if readErr != nil || json.Unmarshal(bz, &hwm) != nil || statemac.Verify(key, hwmFile) != nil {
+		return err
+	}
+	if (Watermark{Height: state.Height, Round: state.Round, Step: state.Step}) != hwm.watermark() {
+		return err
+	}
+
+	return statemac.Sign(key, file)
+}
+
+// VerifyStateFiles checks that the state files in the given directory weren't
+// modified out-of-band since SignCTRL last wrote them. The secret is never generated
+// here, so that deleting it doesn't get modified files trusted, see TrustStateFiles.
+// State files that don't exist yet are skipped, so they have to be signed with
+// SignStateFiles once they were created.
+func VerifyStateFiles(dir string) error {
+	key, err := statemac.LoadKey(dir)
+	if os.IsNotExist(err) {
+		for _, file := range stateFiles(dir) {
+			if _, err := os.Stat(statemac.Path(file)); err == nil {
+				return fmt.Errorf("%w in %v, but %v has a MAC, so the state files may have been modified", statemac.ErrMissingKey, dir, file)
+			}
+		}
+		return fmt.Errorf("%w in %v", statemac.ErrMissingKey, dir)
+	} else if err != nil {
+		return err
+	}
+
+	for _, file := range stateFiles(dir) {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			continue
+		}
+		if err := verifyStateFile(key, dir, file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TrustStateFiles trusts the state files in the given directory as they are by
+// writing their MACs, generating the secret first if there is none yet. It must only
+// be called on the operator's explicit request, e.g. to protect the files for the
+// first time or after checking files that were restored from a backup.
+func TrustStateFiles(dir string) error {
+	if err := os.MkdirAll(dir, config.PermConfigDir); err != nil {
+		return err
+	}
+	if _, _, err := statemac.LoadOrGenKey(dir); err != nil {
+		return err
+	}
+
+	return SignStateFiles(dir)
+}
+
+// SignStateFiles writes the MACs of the state files in the given directory. It must
+// only be called right after VerifyStateFiles and loading the files, so that no
+// out-of-band modification can slip in. Corrupted files are skipped, as their backup
+// was loaded instead and they are replaced on the next write.
+func SignStateFiles(dir string) error {
+	key, err := statemac.LoadKey(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range stateFiles(dir) {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			continue
+		}
+		if file != StateFilePath(dir) && statefile.Corrupted(file) {
+			continue
+		}
+		if err := statemac.Sign(key, file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateStateMAC updates the MAC of the priv_validator_state.json file after the
+// private validator wrote it. Only file private validators keep their last sign state
+// in the file.
+func (pv *SCFilePV) updateStateMAC() {
+	if _, ok := pv.TMFilePV.(*tm_privval.FilePV); !ok {
+		return
+	}
+	if err := statemac.Update(pv.CfgDir, StateFilePath(pv.CfgDir)); err != nil {
+		pv.Logger.Error("couldn't update MAC of %v: %v\n", StateFile, err)
+	}
+}
@@ -0,0 +1,120 @@
+package privval
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/statefile"
+	"github.com/BlockscapeNetwork/signctrl/statemac"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+func TestVerifyStateFiles(t *testing.T) {
+	dir := t.TempDir()
+	tm_privval.GenFilePV(KeyFilePath(dir), StateFilePath(dir)).Save()
+
+	// The secret is never generated implicitly.
+	assert.True(t, errors.Is(VerifyStateFiles(dir), statemac.ErrMissingKey))
+	assert.NoFileExists(t, statemac.KeyFilePath(dir))
+
+	// The existing files are trusted on request.
+	require.NoError(t, TrustStateFiles(dir))
+	assert.FileExists(t, statemac.KeyFilePath(dir))
+	assert.NoError(t, VerifyStateFiles(dir))
+
+	// Files created afterwards are signed once they were loaded.
+	state, err := config.LoadOrGenState(dir)
+	require.NoError(t, err)
+	assert.NoError(t, SignStateFiles(dir))
+	assert.NoError(t, VerifyStateFiles(dir))
+
+	// Changes made by SignCTRL keep the MACs valid.
+	state.LastRank = 2
+	require.NoError(t, state.Save(dir))
+	_, err = RaiseWatermark(StateFilePath(dir), Watermark{Height: 10})
+	require.NoError(t, err)
+	assert.NoError(t, VerifyStateFiles(dir))
+
+	// Out-of-band changes are detected, even if the checksum was updated as well.
+	require.NoError(t, statefile.Write(config.StateFilePath(dir), []byte(`{"last_height":"1","last_rank":1}`), config.PermStateFile))
+	assert.True(t, errors.Is(VerifyStateFiles(dir), statemac.ErrInvalidMAC))
+
+	// Deleting the secret doesn't get the modified files trusted.
+	require.NoError(t, os.Remove(statemac.KeyFilePath(dir)))
+	err = VerifyStateFiles(dir)
+	assert.True(t, errors.Is(err, statemac.ErrMissingKey))
+	assert.Contains(t, err.Error(), "has a MAC")
+	assert.NoFileExists(t, statemac.KeyFilePath(dir))
+}
+
+func TestSCFilePV_UpdateStateMAC(t *testing.T) {
+	dir := t.TempDir()
+	filePV := tm_privval.GenFilePV(filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile))
+	filePV.Save()
+	require.NoError(t, TrustStateFiles(dir))
+
+	pv := mockSCFilePV(t)
+	pv.CfgDir = dir
+	pv.TMFilePV = filePV
+	require.NoError(t, filePV.SignVote("testchain", &tm_typesproto.Vote{Type: tm_typesproto.PrevoteType, Height: 1}))
+	assert.True(t, errors.Is(VerifyStateFiles(dir), statemac.ErrInvalidMAC))
+
+	pv.updateStateMAC()
+	assert.NoError(t, VerifyStateFiles(dir))
+}
+
+func TestVerifyStateFiles_AtHighWatermark(t *testing.T) {
+	dir := t.TempDir()
+	filePV := tm_privval.GenFilePV(KeyFilePath(dir), StateFilePath(dir))
+	filePV.Save()
+	require.NoError(t, TrustStateFiles(dir))
+
+	// A crash between signing and updating the MAC leaves the file at the high
+	// watermark, which was raised beforehand.
+	hwm := newHighWatermarkStore(dir, types.NewSyncLogger(ioutil.Discard, "", 0))
+	vote := &tm_typesproto.Vote{Type: tm_typesproto.PrevoteType, Height: 1}
+	require.NoError(t, hwm.Raise(Watermark{Height: 1, Step: stepPrevote}, voteSignBytes("testchain", vote)))
+	require.NoError(t, filePV.SignVote("testchain", vote))
+	assert.NoError(t, VerifyStateFiles(dir))
+	key, err := statemac.LoadKey(dir)
+	require.NoError(t, err)
+	assert.NoError(t, statemac.Verify(key, StateFilePath(dir)))
+
+	// Anything else is still detected.
+	require.NoError(t, filePV.SignVote("testchain", &tm_typesproto.Vote{Type: tm_typesproto.PrecommitType, Height: 1}))
+	assert.True(t, errors.Is(VerifyStateFiles(dir), statemac.ErrInvalidMAC))
+}
+
+func TestVerifyStateFiles_CorruptedState(t *testing.T) {
+	dir := t.TempDir()
+	state := config.State{LastHeight: 10, LastRank: 1}
+	require.NoError(t, state.Save(dir))
+	require.NoError(t, TrustStateFiles(dir))
+	state.LastHeight = 11
+	require.NoError(t, state.Save(dir))
+
+	// A corrupted file is recovered from its backup, which matches the previous MAC.
+	require.NoError(t, ioutil.WriteFile(config.StateFilePath(dir), []byte("{"), config.PermStateFile))
+	require.NoError(t, VerifyStateFiles(dir))
+	loaded, err := config.LoadOrGenState(dir)
+	require.NoError(t, err)
+	assert.True(t, loaded.Recovered)
+	assert.Equal(t, int64(10), loaded.LastHeight)
+
+	// The corrupted file isn't signed, so the backup is trusted after a restart, too.
+	require.NoError(t, SignStateFiles(dir))
+	assert.NoError(t, VerifyStateFiles(dir))
+
+	// A backup modified out-of-band is detected.
+	bak := []byte(`{"last_height":"1","last_rank":1}`)
+	require.NoError(t, ioutil.WriteFile(statefile.BackupPath(config.StateFilePath(dir)), bak, config.PermStateFile))
+	assert.True(t, errors.Is(VerifyStateFiles(dir), statemac.ErrInvalidMAC))
+}
@@ -0,0 +1,147 @@
+package privval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+// The steps of the watermark, as used by Tendermint and tmkms.
+const (
+	stepPropose   int8 = 1
+	stepPrevote   int8 = 2
+	stepPrecommit int8 = 3
+)
+
+// voteStep returns the step of the watermark for the given vote type.
+func voteStep(t tm_typesproto.SignedMsgType) int8 {
+	if t == tm_typesproto.PrecommitType {
+		return stepPrecommit
+	}
+
+	return stepPrevote
+}
+
+// tmkmsState defines the contents of tmkms's consensus state file, which tmkms uses as
+// its watermark. Heights and rounds are encoded as strings.
+type tmkmsState struct {
+	Height  flexInt     `json:"height"`
+	Round   flexInt     `json:"round"`
+	Step    flexInt     `json:"step"`
+	BlockID interface{} `json:"block_id"`
+}
+
+// LoadTmkmsWatermark loads the watermark from tmkms's consensus state file.
+func LoadTmkmsWatermark(path string) (Watermark, error) {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Watermark{}, err
+	}
+
+	var state tmkmsState
+	if err := json.Unmarshal(bz, &state); err != nil {
+		return Watermark{}, fmt.Errorf("couldn't read %v: %w", path, err)
+	}
+	if state.Height < 0 || state.Round < 0 || state.Step < 0 || state.Step > 3 {
+		return Watermark{}, errors.New("invalid height, round or step")
+	}
+
+	return Watermark{
+		Height: int64(state.Height),
+		Round:  int32(state.Round),
+		Step:   int8(state.Step),
+	}, nil
+}
+
+// WriteTmkmsWatermark raises the watermark in tmkms's consensus state file at the given
+// path to w if w is ahead of it, or creates the file if it doesn't exist. It returns
+// true if the file was changed. The file is replaced atomically, so tmkms never reads
+// a partially written file.
+func WriteTmkmsWatermark(path string, w Watermark) (bool, error) {
+	cur, err := LoadTmkmsWatermark(path)
+	switch {
+	case err == nil && !w.After(cur):
+		return false, nil
+	case err != nil && !os.IsNotExist(err):
+		return false, err
+	}
+
+	bz, err := json.MarshalIndent(map[string]interface{}{
+		"height":   strconv.FormatInt(w.Height, 10),
+		"round":    strconv.FormatInt(int64(w.Round), 10),
+		"step":     w.Step,
+		"block_id": nil,
+	}, "", "  ")
+	if err != nil {
+		return false, err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bz); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// importTmkmsWatermark raises the last sign state of the private validator to the
+// watermark in the configured tmkms_state_file, so that SignCTRL never signs anything
+// tmkms has already signed. Only file private validators keep a last sign state.
+func (pv *SCFilePV) importTmkmsWatermark() error {
+	path := pv.Config.Privval.TmkmsStateFile
+	w, err := LoadTmkmsWatermark(path)
+	if os.IsNotExist(err) {
+		pv.Logger.Info("No tmkms state file at %v yet, it is created once SignCTRL signs", path)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("couldn't load tmkms state file: %w", err)
+	}
+
+	filePV, ok := pv.TMFilePV.(*tm_privval.FilePV)
+	if !ok {
+		pv.Logger.Warn("Can't import the tmkms watermark into a %T", pv.TMFilePV)
+		return nil
+	}
+
+	lss := &filePV.LastSignState
+	if !w.After(Watermark{Height: lss.Height, Round: lss.Round, Step: lss.Step}) {
+		return nil
+	}
+	pv.Logger.Info("Raising the last sign state to the tmkms watermark (%v)", w)
+	lss.Height, lss.Round, lss.Step = w.Height, w.Round, w.Step
+	lss.Signature, lss.SignBytes = nil, nil
+	lss.Save()
+	pv.updateStateMAC()
+
+	return nil
+}
+
+// exportTmkmsWatermark writes the given watermark to the configured tmkms_state_file,
+// if any, so that tmkms never signs anything SignCTRL has already signed.
+func (pv *SCFilePV) exportTmkmsWatermark(w Watermark) {
+	path := pv.Config.Privval.TmkmsStateFile
+	if path == "" {
+		return
+	}
+	if _, err := WriteTmkmsWatermark(path, w); err != nil {
+		pv.Logger.Error("couldn't write tmkms state file: %v\n", err)
+	}
+}
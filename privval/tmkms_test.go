@@ -0,0 +1,69 @@
+package privval
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+func TestLoadTmkmsWatermark(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testchain_consensus.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"height":"1234","round":"1","step":2,"block_id":"F00D"}`), 0600))
+
+	w, err := LoadTmkmsWatermark(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Watermark{Height: 1234, Round: 1, Step: 2}, w)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"height":"1234","round":"0","step":4}`), 0600))
+	_, err = LoadTmkmsWatermark(path)
+	assert.Error(t, err)
+}
+
+func TestWriteTmkmsWatermark(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testchain_consensus.json")
+
+	// The file is created if it doesn't exist.
+	written, err := WriteTmkmsWatermark(path, Watermark{Height: 10, Round: 1, Step: 3})
+	assert.NoError(t, err)
+	assert.True(t, written)
+	bz, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"height":"10","round":"1","step":3,"block_id":null}`, string(bz))
+
+	// The watermark must never be lowered.
+	written, err = WriteTmkmsWatermark(path, Watermark{Height: 10, Round: 1, Step: 2})
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	written, err = WriteTmkmsWatermark(path, Watermark{Height: 11, Step: 1})
+	assert.NoError(t, err)
+	assert.True(t, written)
+	w, err := LoadTmkmsWatermark(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Watermark{Height: 11, Step: 1}, w)
+}
+
+func TestSCFilePV_ImportTmkmsWatermark(t *testing.T) {
+	dir := t.TempDir()
+	pv := mockSCFilePV(t)
+	filePV := tm_privval.GenFilePV(filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile))
+	filePV.Save()
+	pv.TMFilePV = filePV
+	pv.Config.Privval.TmkmsStateFile = filepath.Join(dir, "testchain_consensus.json")
+
+	// A missing state file is fine, as it's created once SignCTRL signs.
+	assert.NoError(t, pv.importTmkmsWatermark())
+
+	_, err := WriteTmkmsWatermark(pv.Config.Privval.TmkmsStateFile, Watermark{Height: 100, Round: 2, Step: 3})
+	assert.NoError(t, err)
+	assert.NoError(t, pv.importTmkmsWatermark())
+
+	// The raised sign state must have been persisted, too.
+	lss := tm_privval.LoadFilePV(filepath.Join(dir, KeyFile), filepath.Join(dir, StateFile)).LastSignState
+	assert.Equal(t, int64(100), lss.Height)
+	assert.Equal(t, int32(2), lss.Round)
+	assert.Equal(t, int8(3), lss.Step)
+}
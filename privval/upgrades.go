@@ -0,0 +1,103 @@
+package privval
+
+import (
+	"context"
+	"sort"
+)
+
+// UpgradePlan defines an upgrade scheduled in the Cosmos SDK's upgrade module.
+type UpgradePlan struct {
+	Name   string `json:"name"`
+	Height int64  `json:"height"`
+}
+
+// UpgradePlanQuerier queries the upgrade currently scheduled in the upgrade module. It
+// returns a plan with a height of 0 if no upgrade is scheduled.
+type UpgradePlanQuerier func(ctx context.Context) (UpgradePlan, error)
+
+// queryUpgradePlan is the default UpgradePlanQuerier of SCFilePV. It queries the LCD
+// at the lcd_laddr configured in the [upgrades] section.
+func (pv *SCFilePV) queryUpgradePlan(ctx context.Context) (UpgradePlan, error) {
+	plan, err := pv.RPC.QueryCurrentPlan(ctx, pv.Config.Upgrades.LCDListenAddress)
+	if err != nil || plan == nil {
+		return UpgradePlan{}, err
+	}
+
+	return UpgradePlan{Name: plan.Name, Height: plan.Height}, nil
+}
+
+// UpgradeHeights returns the sorted heights of the configured upgrades and the
+// upgrade scheduled in the upgrade module.
+func (pv *SCFilePV) UpgradeHeights() []int64 {
+	heights := append([]int64(nil), pv.Config.Upgrades.Heights...)
+
+	pv.upgradeMtx.RLock()
+	if pv.upgradePlan.Height > 0 {
+		heights = append(heights, pv.upgradePlan.Height)
+	}
+	pv.upgradeMtx.RUnlock()
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+
+	return heights
+}
+
+// isAroundUpgrade returns true if the given height is within the configured window
+// of an upgrade height, where missed blocks are expected.
+func (pv *SCFilePV) isAroundUpgrade(height int64) bool {
+	window := pv.Config.Upgrades.Window
+	for _, h := range pv.UpgradeHeights() {
+		if height >= h-window && height <= h+window {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setUpgradePlan updates the upgrade scheduled in the upgrade module and logs changes.
+func (pv *SCFilePV) setUpgradePlan(plan UpgradePlan) {
+	pv.upgradeMtx.Lock()
+	prev := pv.upgradePlan
+	pv.upgradePlan = plan
+	pv.upgradeMtx.Unlock()
+
+	if plan == prev {
+		return
+	}
+	if plan.Height > 0 {
+		window := pv.Config.Upgrades.Window
+		pv.Logger.Info("Upgrade %v is scheduled at height %v, not counting missed blocks from %v to %v", plan.Name, plan.Height, plan.Height-window, plan.Height+window)
+	} else {
+		pv.Logger.Info("Upgrade %v is no longer scheduled", prev.Name)
+	}
+}
+
+// monitorUpgrades periodically queries the upgrade scheduled in the upgrade module
+// until ctx is done.
+func (pv *SCFilePV) monitorUpgrades(ctx context.Context) {
+	defer pv.recoverPanic("upgrades")
+	defer pv.idle("upgrades")
+
+	interval := pv.Config.Upgrades.GetQueryInterval()
+	for {
+		// Each iteration takes up to the query timeout plus the interval.
+		pv.tick("upgrades", 2*interval, nil)
+		queryCtx, cancel := context.WithTimeout(ctx, interval)
+		plan, err := pv.QueryUpgradePlan(queryCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			pv.Logger.Error("couldn't query upgrade plan: %v\n", err)
+		} else {
+			pv.setUpgradePlan(plan)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-pv.Clock.After(interval):
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package privval
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsAroundUpgrade(t *testing.T) {
+	pv := mockSCFilePV(t)
+	assert.False(t, pv.isAroundUpgrade(1000))
+
+	pv.Config.Upgrades.Heights = []int64{1000}
+	pv.Config.Upgrades.Window = 2
+	assert.False(t, pv.isAroundUpgrade(997))
+	assert.True(t, pv.isAroundUpgrade(998))
+	assert.True(t, pv.isAroundUpgrade(1000))
+	assert.True(t, pv.isAroundUpgrade(1002))
+	assert.False(t, pv.isAroundUpgrade(1003))
+
+	// Upgrades scheduled via governance are taken into account as well.
+	pv.setUpgradePlan(UpgradePlan{Name: "v2", Height: 500})
+	assert.True(t, pv.isAroundUpgrade(501))
+	assert.Equal(t, []int64{500, 1000}, pv.UpgradeHeights())
+	assert.Equal(t, []int64{500, 1000}, pv.Status().UpgradeHeights)
+
+	pv.setUpgradePlan(UpgradePlan{})
+	assert.False(t, pv.isAroundUpgrade(501))
+}
+
+func TestMonitorUpgrades(t *testing.T) {
+	pv := mockSCFilePV(t)
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	pv.Clock = clock
+	pv.Config.Upgrades.QueryInterval = "10m"
+	plans := []UpgradePlan{{}, {Name: "v2", Height: 500}}
+	queried := make(chan struct{}, len(plans))
+	pv.QueryUpgradePlan = func(ctx context.Context) (UpgradePlan, error) {
+		defer func() { queried <- struct{}{} }()
+		plan := plans[0]
+		if len(plans) > 1 {
+			plans = plans[1:]
+		}
+		return plan, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pv.monitorUpgrades(ctx)
+	}()
+
+	<-queried
+	clock.BlockUntil(1)
+	assert.Empty(t, pv.UpgradeHeights())
+
+	// The plan is queried again after the interval.
+	clock.Advance(10 * time.Minute)
+	<-queried
+	clock.BlockUntil(1)
+	assert.Equal(t, []int64{500}, pv.UpgradeHeights())
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("monitorUpgrades didn't return after ctx was canceled")
+	}
+}
@@ -0,0 +1,114 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+const (
+	// validatorSetCheckRetry is the time after which a failed query of the validator
+	// set is retried. The validator's RPC server is usually not up yet when SignCTRL
+	// starts, as the validator waits for its private validator to connect first.
+	validatorSetCheckRetry = 10 * time.Second
+)
+
+// ErrNotInValidatorSet is returned if the validator's key is not part of the chain's
+// active validator set.
+var ErrNotInValidatorSet = types.NewError(types.CodeNoPermission, "key is not in the active validator set")
+
+// ValidatorSetQuerier queries the chain's active validator set.
+type ValidatorSetQuerier func(ctx context.Context) ([]*tm_types.Validator, error)
+
+// ValidatorSetAtQuerier queries the chain's validator set at the given height.
+type ValidatorSetAtQuerier func(ctx context.Context, height int64) ([]*tm_types.Validator, error)
+
+// queryValidatorSet is the default ValidatorSetQuerier of SCFilePV. It queries the
+// latest validator set from the best RPC server.
+func (pv *SCFilePV) queryValidatorSet(ctx context.Context) ([]*tm_types.Validator, error) {
+	return pv.RPC.QueryValidatorSet(ctx, pv.rpcAddr(), 0)
+}
+
+// queryValidatorSetAt is the default ValidatorSetAtQuerier of SCFilePV. It queries the
+// validator set at the given height from the best RPC server.
+func (pv *SCFilePV) queryValidatorSetAt(ctx context.Context, height int64) ([]*tm_types.Validator, error) {
+	return pv.RPC.QueryValidatorSet(ctx, pv.rpcAddr(), height)
+}
+
+// wasInValidatorSet returns false if the validator with the given public key wasn't
+// part of the validator set at the given height, e.g. because the set changed on chain
+// in the meantime, so that it wasn't expected to sign the height's commit. It returns
+// true if the validator set couldn't be queried, e.g. because the RPC server pruned
+// it, so that such blocks are still counted as missed.
+func (pv *SCFilePV) wasInValidatorSet(ctx context.Context, height int64, pub tm_crypto.PubKey) bool {
+	vals, err := pv.QueryValidatorSetAt(ctx, height)
+	if err != nil {
+		pv.Logger.Warn("Couldn't query the validator set at height %v, assuming the key is part of it: %v", height, err)
+		return true
+	}
+
+	return findValidator(vals, pub) != nil
+}
+
+// findValidator returns the validator with the given public key from vals, or nil
+// if there is none.
+func findValidator(vals []*tm_types.Validator, pub tm_crypto.PubKey) *tm_types.Validator {
+	for _, val := range vals {
+		if val != nil && val.PubKey != nil && bytes.Equal(val.PubKey.Bytes(), pub.Bytes()) {
+			return val
+		}
+	}
+
+	return nil
+}
+
+// checkValidatorSet queries the chain's active validator set until it succeeds or
+// ctx is done and checks that the validator's key is part of it. Depending on the
+// configured validator_set_check, a warning is logged or the node is stopped if it
+// isn't.
+func (pv *SCFilePV) checkValidatorSet(ctx context.Context) {
+	defer pv.recoverPanic("valset")
+
+	var vals []*tm_types.Validator
+	for {
+		var err error
+		if vals, err = pv.QueryValidatorSet(ctx); err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		pv.Logger.Debug("Couldn't query the validator set, retrying in %v: %v\n", validatorSetCheckRetry, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-pv.Clock.After(validatorSetCheckRetry):
+		}
+	}
+
+	pub, err := pv.pubKey()
+	if err != nil {
+		pv.Logger.Error("couldn't get public key: %v\n", err)
+		return
+	}
+
+	if val := findValidator(vals, pub); val != nil {
+		pv.Logger.Info("Found key %v in the active validator set with a voting power of %v", pub.Address(), val.VotingPower)
+		return
+	}
+	pv.emit(watchtower.EventNotInValidatorSet, "Key %v is not in the active validator set", pub.Address())
+	if pv.Config.Privval.ValidatorSetCheck == "refuse" {
+		pv.Logger.Error("%v: %v, stopping SignCTRL\n", ErrNotInValidatorSet, pub.Address())
+		if err := pv.Stop(); err != nil {
+			pv.Logger.Error("%v", err)
+		}
+		return
+	}
+	pv.Logger.Warn("%v: %v. Is the right key in use and is the validator bonded?\n", ErrNotInValidatorSet, pub.Address())
+}
@@ -0,0 +1,165 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+// testValidatorSet returns a validator set containing the given public key and one
+// other validator.
+func testValidatorSet(t *testing.T, pv *SCFilePV) []*tm_types.Validator {
+	t.Helper()
+	pub, err := pv.TMFilePV.GetPubKey()
+	assert.NoError(t, err)
+
+	return []*tm_types.Validator{
+		tm_types.NewValidator(tm_ed25519.GenPrivKey().PubKey(), 5),
+		tm_types.NewValidator(pub, 10),
+	}
+}
+
+// startValSetPV starts a mock SCFilePV whose validator set is queried with the given
+// querier.
+func startValSetPV(t *testing.T, mode string, querier ValidatorSetQuerier) *SCFilePV {
+	t.Helper()
+	signerConn, validatorConn := net.Pipe()
+	t.Cleanup(func() { validatorConn.Close() })
+
+	pv := mockSCFilePV(t)
+	pv.HTTP = nil
+	pv.CfgDir = t.TempDir()
+	pv.Config.Privval.ValidatorSetCheck = mode
+	pv.Dial = func(ctx context.Context) (net.Conn, error) {
+		return signerConn, nil
+	}
+	pv.QueryValidatorSet = querier
+	assert.NoError(t, pv.Start())
+
+	return pv
+}
+
+func TestFindValidator(t *testing.T) {
+	pv := mockSCFilePV(t)
+	vals := testValidatorSet(t, pv)
+	pub, err := pv.TMFilePV.GetPubKey()
+	assert.NoError(t, err)
+
+	assert.Equal(t, vals[1], findValidator(vals, pub))
+	assert.Nil(t, findValidator(vals[:1], pub))
+	assert.Nil(t, findValidator(nil, pub))
+}
+
+func TestCheckValidatorSet_Refuse(t *testing.T) {
+	pv := startValSetPV(t, "refuse", func(ctx context.Context) ([]*tm_types.Validator, error) {
+		return []*tm_types.Validator{tm_types.NewValidator(tm_ed25519.GenPrivKey().PubKey(), 5)}, nil
+	})
+
+	select {
+	case <-pv.Quit():
+	case <-time.After(5 * time.Second):
+		t.Fatal("SignCTRL wasn't stopped although its key is not in the validator set")
+	}
+}
+
+func TestCheckValidatorSet_Warn(t *testing.T) {
+	queried := make(chan struct{})
+	pv := startValSetPV(t, "warn", func(ctx context.Context) ([]*tm_types.Validator, error) {
+		defer close(queried)
+		return nil, nil
+	})
+	defer func() { _ = pv.Stop() }()
+
+	<-queried
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, pv.IsRunning())
+}
+
+func TestCheckValidatorSet_Retry(t *testing.T) {
+	clock := types.NewFakeClock(time.Now())
+	pv := mockSCFilePV(t)
+	pv.Clock = clock
+	pv.Config.Privval.ValidatorSetCheck = "refuse"
+
+	queries := 0
+	pv.QueryValidatorSet = func(ctx context.Context) ([]*tm_types.Validator, error) {
+		queries++
+		if queries == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return testValidatorSet(t, pv), nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pv.checkValidatorSet(context.Background())
+	}()
+
+	// The failed query is retried once the retry interval has passed.
+	clock.BlockUntil(1)
+	clock.Advance(validatorSetCheckRetry)
+	<-done
+	assert.Equal(t, 2, queries)
+}
+
+func TestCheckValidatorSet_Canceled(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.QueryValidatorSet = func(ctx context.Context) ([]*tm_types.Validator, error) {
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pv.checkValidatorSet(ctx)
+}
+
+func TestHandleSignRequest_NotInValidatorSet(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.SetRank(2)
+	pv.UnlockCounter()
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		rb := testBlockResult(t).Result
+		rb.Block.Height = height
+		return rb, nil
+	}
+	pv.VerifyBlock = func(ctx context.Context, block *tm_coretypes.ResultBlock) error {
+		return nil
+	}
+	var queried []int64
+	vals := testValidatorSet(t, pv)[:1]
+	var valsErr error
+	pv.QueryValidatorSetAt = func(ctx context.Context, height int64) ([]*tm_types.Validator, error) {
+		queried = append(queried, height)
+		return vals, valsErr
+	}
+	req := testSignVoteRequest(t)
+	height := req.GetSignVoteRequest().Vote.Height
+
+	// The block lacks the validator's commitsig, but the validator wasn't part of the
+	// validator set at the commit's height.
+	_, err := HandleRequest(context.Background(), req, pv)
+	assert.ErrorIs(t, err, ErrNoSigningPermission)
+	assert.Zero(t, pv.GetMissedInARow())
+	assert.Equal(t, []int64{height - 2}, queried)
+
+	// Once it is, the block is counted as missed.
+	vals = testValidatorSet(t, pv)
+	req.GetSignVoteRequest().Vote.Height++
+	_, _ = HandleRequest(context.Background(), req, pv)
+	assert.Equal(t, 1, pv.GetMissedInARow())
+
+	// A validator set that can't be queried doesn't keep the block from being counted.
+	valsErr = errors.New("pruned")
+	req.GetSignVoteRequest().Vote.Height++
+	_, _ = HandleRequest(context.Background(), req, pv)
+	assert.Equal(t, 2, pv.GetMissedInARow())
+}
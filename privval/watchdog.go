@@ -0,0 +1,245 @@
+package privval
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+)
+
+const (
+	// watchdogChecks is the number of times the watchdog checks the goroutines per
+	// stall timeout.
+	watchdogChecks = 4
+)
+
+// watchedGoroutine is a goroutine the watchdog expects liveness ticks from.
+type watchedGoroutine struct {
+	// deadline is the time the next tick is expected by.
+	deadline time.Time
+
+	// restart restarts the goroutine's work. It is nil if it can't be restarted.
+	restart func()
+
+	// restarted is true if the goroutine was restarted since its last tick.
+	restarted bool
+}
+
+// stall is a goroutine that missed its deadline.
+type stall struct {
+	goroutine string
+	overdue   time.Duration
+
+	// restart is set if the goroutine is to be restarted instead of marking the node
+	// unhealthy.
+	restart func()
+}
+
+// watchdog keeps track of the liveness ticks of the goroutines reading and handling
+// the validator's requests and monitoring the RPC endpoints, slashing and upgrades.
+// A goroutine waiting for external input, e.g. the next request of a validator that
+// doesn't ping, is idle and not expected to tick, so that a hung goroutine can be
+// told apart from an idle one. All methods are no-ops on a nil watchdog.
+type watchdog struct {
+	timeout  time.Duration
+	restarts bool
+
+	mtx     sync.Mutex
+	watched map[string]*watchedGoroutine
+	stalled map[string]time.Time // goroutines that made the node unhealthy
+}
+
+// newWatchdog returns a new watchdog for the given configuration, or nil if the
+// watchdog is disabled.
+func newWatchdog(cfg config.Watchdog) *watchdog {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	return &watchdog{
+		timeout:  cfg.GetStallTimeout(),
+		restarts: cfg.Restarts(),
+		watched:  make(map[string]*watchedGoroutine),
+		stalled:  make(map[string]time.Time),
+	}
+}
+
+// tick records a liveness tick of the given goroutine, which is expected to tick
+// again within the given duration plus the stall timeout. restart restarts the
+// goroutine's work if it stalls, or is nil if it can't be restarted. It returns true
+// if the goroutine recovered after it had made the node unhealthy.
+func (w *watchdog) tick(now time.Time, goroutine string, within time.Duration, restart func()) bool {
+	if w == nil {
+		return false
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.watched[goroutine] = &watchedGoroutine{deadline: now.Add(within + w.timeout), restart: restart}
+	return w.recover(goroutine)
+}
+
+// idle marks the given goroutine as waiting for external input, so that it's not
+// expected to tick until it's busy again. It returns true if the goroutine recovered
+// after it had made the node unhealthy.
+func (w *watchdog) idle(goroutine string) bool {
+	if w == nil {
+		return false
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	delete(w.watched, goroutine)
+	return w.recover(goroutine)
+}
+
+// recover removes the given goroutine from the stalled ones. The watchdog's lock must
+// be held.
+func (w *watchdog) recover(goroutine string) bool {
+	if _, ok := w.stalled[goroutine]; !ok {
+		return false
+	}
+	delete(w.stalled, goroutine)
+
+	return true
+}
+
+// check returns the goroutines that missed their deadlines since the last check. A
+// stalled goroutine that can be restarted is restarted once if restarts are enabled,
+// and is expected to tick again within the stall timeout. Otherwise, it makes the
+// node unhealthy until it ticks again.
+func (w *watchdog) check(now time.Time) []stall {
+	if w == nil {
+		return nil
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	var stalls []stall
+	for goroutine, g := range w.watched {
+		if !now.After(g.deadline) {
+			continue
+		}
+		s := stall{goroutine: goroutine, overdue: now.Sub(g.deadline) + w.timeout}
+		if w.restarts && g.restart != nil && !g.restarted {
+			s.restart = g.restart
+			g.restarted = true
+			g.deadline = now.Add(w.timeout)
+		} else if _, ok := w.stalled[goroutine]; ok {
+			continue
+		} else {
+			w.stalled[goroutine] = now
+		}
+		stalls = append(stalls, s)
+	}
+	sort.Slice(stalls, func(i, j int) bool { return stalls[i].goroutine < stalls[j].goroutine })
+
+	return stalls
+}
+
+// list returns the names of the goroutines that made the node unhealthy.
+func (w *watchdog) list() []string {
+	if w == nil {
+		return nil
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	stalled := make([]string, 0, len(w.stalled))
+	for goroutine := range w.stalled {
+		stalled = append(stalled, goroutine)
+	}
+	sort.Strings(stalled)
+
+	return stalled
+}
+
+// tick records a liveness tick of the given goroutine with the watchdog, see
+// watchdog.tick.
+func (pv *SCFilePV) tick(goroutine string, within time.Duration, restart func()) {
+	if pv.watchdog.tick(pv.Clock.Now(), goroutine, within, restart) {
+		pv.Logger.Info("The %v goroutine recovered", goroutine)
+	}
+}
+
+// idle marks the given goroutine as idle with the watchdog, see watchdog.idle.
+func (pv *SCFilePV) idle(goroutine string) {
+	if pv.watchdog.idle(goroutine) {
+		pv.Logger.Info("The %v goroutine recovered", goroutine)
+	}
+}
+
+// handlerBudget returns the time handling a request may take on top of the stall
+// timeout, which is the time proposals are held for approval.
+func (pv *SCFilePV) handlerBudget() time.Duration {
+	if !pv.Config.Privval.RequiresProposalApproval() {
+		return 0
+	}
+
+	return pv.Config.Privval.GetProposalApprovalTimeout()
+}
+
+// StalledGoroutines returns the names of the stalled goroutines that made the node
+// unhealthy. The node is healthy if there are none.
+func (pv *SCFilePV) StalledGoroutines() []string {
+	return pv.watchdog.list()
+}
+
+// runWatchdog checks the watched goroutines in intervals of a fraction of the stall
+// timeout until ctx is done.
+func (pv *SCFilePV) runWatchdog(ctx context.Context) {
+	defer pv.recoverPanic("watchdog")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pv.Clock.After(pv.watchdog.timeout / watchdogChecks):
+		}
+		pv.checkStalls()
+	}
+}
+
+// checkStalls handles the goroutines that stalled since the last check. The stacks of
+// all goroutines are dumped to a crash report, and the stalled goroutines are either
+// restarted or make the node unhealthy.
+func (pv *SCFilePV) checkStalls() {
+	for _, s := range pv.watchdog.check(pv.Clock.Now()) {
+		pv.Logger.Error("The %v goroutine stalled (no liveness tick for %v)\n", s.goroutine, s.overdue)
+		pv.emit(watchtower.EventStalled, "The %v goroutine stalled", s.goroutine)
+		pv.writeCrashReport(&CrashReport{
+			Time:      time.Now(),
+			Goroutine: s.goroutine,
+			Stall:     fmt.Sprintf("no liveness tick for %v", s.overdue),
+			Stack:     string(allStacks()),
+		})
+
+		if s.restart != nil {
+			pv.Logger.Warn("Restarting the %v goroutine", s.goroutine)
+			s.restart()
+		} else {
+			pv.Logger.Error("Marking the node unhealthy until the %v goroutine recovers", s.goroutine)
+		}
+	}
+}
+
+// allStacks returns the stacks of all goroutines.
+func allStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,135 @@
+package privval
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+)
+
+func TestNewWatchdog(t *testing.T) {
+	assert.Nil(t, newWatchdog(config.Watchdog{}))
+
+	w := newWatchdog(config.Watchdog{StallTimeout: "30s"})
+	require.NotNil(t, w)
+	assert.Equal(t, 30*time.Second, w.timeout)
+	assert.True(t, w.restarts)
+}
+
+func TestWatchdog_Nil(t *testing.T) {
+	var w *watchdog
+	assert.False(t, w.tick(time.Unix(0, 0), "reader", time.Second, nil))
+	assert.False(t, w.idle("reader"))
+	assert.Empty(t, w.check(time.Unix(0, 0)))
+	assert.Empty(t, w.list())
+}
+
+func TestWatchdog_RestartOnce(t *testing.T) {
+	now := time.Unix(0, 0)
+	w := newWatchdog(config.Watchdog{StallTimeout: "10s"})
+	restarts := 0
+	w.tick(now, "handler", 5*time.Second, func() { restarts++ })
+
+	// The goroutine has time until the expected duration plus the stall timeout.
+	assert.Empty(t, w.check(now.Add(15*time.Second)))
+
+	// The first stall restarts the goroutine.
+	stalls := w.check(now.Add(16 * time.Second))
+	require.Len(t, stalls, 1)
+	assert.Equal(t, "handler", stalls[0].goroutine)
+	assert.Equal(t, 11*time.Second, stalls[0].overdue)
+	require.NotNil(t, stalls[0].restart)
+	stalls[0].restart()
+	assert.Equal(t, 1, restarts)
+	assert.Empty(t, w.list())
+
+	// If the restart doesn't help, the node is marked unhealthy, which is only
+	// reported once.
+	assert.Empty(t, w.check(now.Add(26*time.Second)))
+	stalls = w.check(now.Add(27 * time.Second))
+	require.Len(t, stalls, 1)
+	assert.Nil(t, stalls[0].restart)
+	assert.Equal(t, []string{"handler"}, w.list())
+	assert.Empty(t, w.check(now.Add(time.Minute)))
+
+	// The node is healthy again once the goroutine ticks.
+	assert.True(t, w.tick(now.Add(time.Minute), "handler", 0, nil))
+	assert.Empty(t, w.list())
+}
+
+func TestWatchdog_NoRestart(t *testing.T) {
+	now := time.Unix(0, 0)
+	w := newWatchdog(config.Watchdog{StallTimeout: "10s", Action: "unhealthy"})
+	w.tick(now, "reader", 0, func() { t.Fatal("restarted") })
+	w.tick(now, "slashing", 0, nil)
+
+	stalls := w.check(now.Add(11 * time.Second))
+	require.Len(t, stalls, 2)
+	assert.Equal(t, "reader", stalls[0].goroutine)
+	assert.Nil(t, stalls[0].restart)
+	assert.Equal(t, "slashing", stalls[1].goroutine)
+	assert.Nil(t, stalls[1].restart)
+	assert.Equal(t, []string{"reader", "slashing"}, w.list())
+
+	// Idle goroutines are healthy.
+	assert.True(t, w.idle("reader"))
+	assert.Equal(t, []string{"slashing"}, w.list())
+}
+
+func TestWatchdog_Idle(t *testing.T) {
+	now := time.Unix(0, 0)
+	w := newWatchdog(config.Watchdog{StallTimeout: "10s"})
+	w.tick(now, "reader", 0, nil)
+	assert.False(t, w.idle("reader"))
+
+	assert.Empty(t, w.check(now.Add(time.Hour)))
+}
+
+func TestSCFilePV_CheckStalls(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.CfgDir = t.TempDir()
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	pv.Clock = clock
+	pv.watchdog = newWatchdog(config.Watchdog{StallTimeout: "10s"})
+
+	restarted := false
+	pv.tick("handler", 0, func() { restarted = true })
+	pv.tick("upgrades", 0, nil)
+	clock.Advance(11 * time.Second)
+	pv.checkStalls()
+
+	assert.True(t, restarted)
+	assert.Equal(t, []string{"upgrades"}, pv.Status().Stalled)
+
+	events, _, _ := pv.watchEvents.Since(0)
+	var stalled []string
+	for _, e := range events {
+		if e.Type == watchtower.EventStalled {
+			stalled = append(stalled, e.Message)
+		}
+	}
+	assert.Equal(t, []string{"The handler goroutine stalled", "The upgrades goroutine stalled"}, stalled)
+
+	// The stacks of all goroutines are dumped for each stall.
+	matches, err := filepath.Glob(filepath.Join(pv.CfgDir, "signctrl_crash_*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, matches)
+	bytes, err := ioutil.ReadFile(matches[0])
+	require.NoError(t, err)
+	var report CrashReport
+	require.NoError(t, tm_json.Unmarshal(bytes, &report))
+	assert.NotEmpty(t, report.Stall)
+	assert.Contains(t, report.Stack, "TestSCFilePV_CheckStalls")
+	assert.False(t, pv.IsCrashed())
+
+	// The node is healthy again once the stalled goroutine ticks.
+	pv.tick("upgrades", 0, nil)
+	assert.Empty(t, pv.Status().Stalled)
+}
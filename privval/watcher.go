@@ -0,0 +1,224 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+const (
+	// maxWatchBacklog is the maximum number of blocks the commit watcher catches up on
+	// at once, e.g. after the RPC server was unreachable. Older blocks are skipped.
+	maxWatchBacklog = blockCacheSize
+
+	// watchStaleIntervals is the number of watch intervals without a new block after
+	// which the block subscription is considered stale, or the RPC server is reported
+	// as stuck while polling.
+	watchStaleIntervals = 10
+
+	// watchBackoffMax is the maximum time the commit watcher polls before it tries to
+	// subscribe to the blocks again.
+	watchBackoffMax = 5 * time.Minute
+)
+
+// LatestHeightQuerier queries the height of the latest block known to the validator's
+// RPC server.
+type LatestHeightQuerier func(ctx context.Context) (int64, error)
+
+// queryLatestHeight is the default LatestHeightQuerier of SCFilePV. It queries the
+// /status endpoint of the best RPC server.
+func (pv *SCFilePV) queryLatestHeight(ctx context.Context) (int64, error) {
+	return pv.RPC.QueryLatestHeight(ctx, pv.rpcAddr())
+}
+
+// commitWatch is the state of the commit watcher. It is only used by its goroutine.
+type commitWatch struct {
+	interval time.Duration
+
+	// backoff is the time polled for before subscribing again. It is reset once the
+	// subscription delivers a block.
+	backoff *connection.Backoff
+
+	// catchUp is set once the first block was checked. The blocks before it aren't
+	// caught up on, as the node may not have been running while they were committed.
+	catchUp bool
+}
+
+// staleTimeout returns the time without a new block after which the subscription or
+// the RPC server is considered stale.
+func (w *commitWatch) staleTimeout() time.Duration {
+	return watchStaleIntervals * w.interval
+}
+
+// watchCommits checks the commitsigs of every new block until ctx is done, so that
+// missed blocks are counted and the counter is reset from the chain itself rather than
+// only when the validator sends sign requests. The blocks are received via the block
+// subscription if possible, and polled in the watch interval otherwise, until the
+// subscription is retried with an exponential backoff. Each height is only checked
+// once, whether by the watcher or a sign request. The node is stopped if it must shut
+// down.
+func (pv *SCFilePV) watchCommits(ctx context.Context) {
+	defer pv.recoverPanic("commits")
+
+	interval := pv.Config.RPC.GetWatchInterval()
+	w := &commitWatch{
+		interval: interval,
+		backoff:  connection.NewBackoff(interval, watchBackoffMax, connection.DefaultBackoffMultiplier),
+	}
+	for {
+		err := pv.watchSubscribed(ctx, w)
+		pv.setWatcherSubscribed(false)
+		if errors.Is(err, types.ErrMustShutdown) {
+			pv.shutdownWatcher(err)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		retry := w.backoff.Next()
+		pv.Logger.Warn("Commit watcher can't use the block subscription, polling blocks for %v: %v", retry.Round(time.Second), err)
+
+		if err := pv.pollCommits(ctx, w, retry); err != nil {
+			if errors.Is(err, types.ErrMustShutdown) {
+				pv.shutdownWatcher(err)
+			}
+			return
+		}
+	}
+}
+
+// watchSubscribed checks the blocks received via the block subscription until ctx is
+// done, the subscription fails or becomes stale, or the node must shut down.
+func (pv *SCFilePV) watchSubscribed(ctx context.Context, w *commitWatch) error {
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	blockCh := make(chan *tm_coretypes.ResultBlock)
+	errCh := make(chan error, 1)
+	goroutines.Go("commits", func() { errCh <- pv.SubscribeBlocks(subCtx, blockCh) })
+
+	stale := pv.Clock.NewTimer(w.staleTimeout())
+	defer stale.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errCh:
+			if err == nil {
+				err = errors.New("subscription ended")
+			}
+			return err
+		case <-stale.C():
+			pv.updateWatcherLag()
+			return fmt.Errorf("no block received for %v", w.staleTimeout())
+		case block := <-blockCh:
+			stale.Reset(w.staleTimeout())
+			w.backoff.Reset()
+			pv.setWatcherSubscribed(true)
+			pv.blocks.add(block)
+			if err := pv.watchHeight(ctx, w, block.Block.Height); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// pollCommits polls the latest height of the RPC server in the watch interval and
+// checks the new blocks until the given time has passed. It returns an error if ctx
+// is done or the node must shut down.
+func (pv *SCFilePV) pollCommits(ctx context.Context, w *commitWatch, d time.Duration) error {
+	until := pv.Clock.Now().Add(d)
+	var lastHeight int64
+	var lastAdvanced time.Time
+	reported := false
+	for pv.Clock.Now().Before(until) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-pv.Clock.After(w.interval):
+		}
+
+		latest, err := pv.QueryLatestHeight(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			pv.Logger.Warn("Commit watcher couldn't query the latest height: %v", err)
+			pv.updateWatcherLag()
+			continue
+		}
+
+		// Report an RPC server that is stuck, as it keeps the watcher from seeing any
+		// missed blocks.
+		now := pv.Clock.Now()
+		if latest > lastHeight {
+			lastHeight, lastAdvanced, reported = latest, now, false
+		} else if stuck := now.Sub(lastAdvanced); stuck >= w.staleTimeout() && !reported {
+			pv.Logger.Warn("Commit watcher: the RPC server hasn't advanced past height %v for %v", lastHeight, stuck)
+			reported = true
+		}
+
+		if err := pv.watchHeight(ctx, w, latest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// watchHeight checks the blocks up to the given latest height and updates the
+// watcher's lag. It only returns an error if the node must shut down.
+func (pv *SCFilePV) watchHeight(ctx context.Context, w *commitWatch, latest int64) error {
+	err := pv.checkCommitsUpTo(ctx, latest, w.catchUp)
+	w.catchUp = true
+	pv.updateWatcherLag()
+	if errors.Is(err, types.ErrMustShutdown) {
+		return err
+	}
+	if err != nil && ctx.Err() == nil {
+		pv.Logger.Warn("Commit watcher couldn't check the commitsigs [%v]: %v", pv.recordError(err), err)
+	}
+
+	return nil
+}
+
+// shutdownWatcher stops the node, as the commit watcher found it must shut down.
+func (pv *SCFilePV) shutdownWatcher(err error) {
+	pv.Logger.Error("Commit watcher [%v]: %v", pv.recordError(err), err)
+	pv.emit(watchtower.EventMustShutdown, "Shutting down: %v", err)
+	if err := pv.Stop(); err != nil {
+		pv.Logger.Error("%v", err)
+	}
+}
+
+// checkCommitsUpTo checks the commitsigs of the blocks up to the given latest height
+// that weren't checked yet, but at most maxWatchBacklog of them, or only the latest
+// block unless catchUp is set. As a block's commit is only included in the next block,
+// the latest block is checked just like a sign request at the next height would check
+// it.
+func (pv *SCFilePV) checkCommitsUpTo(ctx context.Context, latest int64, catchUp bool) error {
+	pv.signerMtx.RLock()
+	defer pv.signerMtx.RUnlock()
+
+	from := pv.GetCurrentHeight() + 1
+	if !catchUp {
+		from = latest + 1
+	}
+	if oldest := latest + 2 - maxWatchBacklog; from < oldest {
+		from = oldest
+	}
+	for height := from; height <= latest+1; height++ {
+		if _, err := pv.checkBlock(ctx, height); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
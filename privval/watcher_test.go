@@ -0,0 +1,162 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+// mockWatchedBlocks lets pv query blocks lacking its commitsig at any height and
+// returns the heights queried.
+func mockWatchedBlocks(t *testing.T, pv *SCFilePV) func() []int64 {
+	t.Helper()
+	var mtx sync.Mutex
+	var queried []int64
+	pv.QueryBlock = func(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		queried = append(queried, height)
+		rb := testBlockResult(t).Result
+		rb.Block.Height = height
+		return rb, nil
+	}
+	pv.VerifyBlock = func(ctx context.Context, block *tm_coretypes.ResultBlock) error {
+		return nil
+	}
+
+	return func() []int64 {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return append([]int64(nil), queried...)
+	}
+}
+
+func TestCheckCommitsUpTo(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.SetRank(2)
+	pv.UnlockCounter()
+	queried := mockWatchedBlocks(t, pv)
+
+	// Only the latest block is checked after starting.
+	require.NoError(t, pv.checkCommitsUpTo(context.Background(), 10, false))
+	assert.Equal(t, []int64{10}, queried())
+	assert.Equal(t, int64(11), pv.GetCurrentHeight())
+	assert.Equal(t, 1, pv.GetMissedInARow())
+
+	// Then every new block is checked once.
+	require.NoError(t, pv.checkCommitsUpTo(context.Background(), 12, true))
+	require.NoError(t, pv.checkCommitsUpTo(context.Background(), 12, true))
+	assert.Equal(t, []int64{10, 11, 12}, queried())
+	assert.Equal(t, 3, pv.GetMissedInARow())
+
+	// A sign request doesn't check the block again.
+	req := testSignVoteRequest(t)
+	req.GetSignVoteRequest().Vote.Height = 13
+	_, err := HandleRequest(context.Background(), req, pv)
+	assert.ErrorIs(t, err, ErrNoSigningPermission)
+	assert.Equal(t, 3, pv.GetMissedInARow())
+
+	// At most maxWatchBacklog blocks are caught up on.
+	pv.SetThreshold(100)
+	require.NoError(t, pv.checkCommitsUpTo(context.Background(), 100, true))
+	assert.Len(t, queried(), 3+maxWatchBacklog)
+	assert.Equal(t, int64(100-maxWatchBacklog+1), queried()[3])
+}
+
+func TestWatchCommits_Subscribed(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.Config.RPC.WatchInterval = "1s"
+	clock := types.NewFakeClock(time.Unix(1000, 0))
+	pv.Clock = clock
+	pv.Gauges.WatcherSubscribedGauge = prometheus.NewGauge(prometheus.GaugeOpts{Name: "subscribed"})
+	pv.Gauges.WatcherLagGauge = prometheus.NewGauge(prometheus.GaugeOpts{Name: "lag"})
+	pv.SetRank(2)
+	pv.UnlockCounter()
+	mockWatchedBlocks(t, pv)
+	pv.QueryBlock = pv.queryBlock
+	subscribed := make(chan struct{})
+	pv.SubscribeBlocks = func(ctx context.Context, blockCh chan<- *tm_coretypes.ResultBlock) error {
+		rb := testBlockResult(t).Result
+		rb.Block.Height = 10
+		rb.Block.Time = clock.Now().Add(-3 * time.Second)
+		blockCh <- rb
+		close(subscribed)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pv.watchCommits(ctx)
+		close(done)
+	}()
+
+	// The subscribed block is cached, so it doesn't need to be queried.
+	<-subscribed
+	require.Eventually(t, func() bool {
+		return prom_testutil.ToFloat64(pv.Gauges.WatcherLagGauge) == 3
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 1, pv.GetMissedInARow())
+	assert.Equal(t, 1.0, prom_testutil.ToFloat64(pv.Gauges.WatcherSubscribedGauge))
+
+	cancel()
+	<-done
+}
+
+func TestWatchCommits_Fallback(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.Config.RPC.WatchInterval = "1s"
+	clock := types.NewFakeClock(time.Unix(1000, 0))
+	pv.Clock = clock
+	pv.SetRank(2)
+	pv.UnlockCounter()
+	queried := mockWatchedBlocks(t, pv)
+	pv.QueryLatestHeight = func(ctx context.Context) (int64, error) {
+		return 10, nil
+	}
+	var subscriptions int32
+	pv.SubscribeBlocks = func(ctx context.Context, blockCh chan<- *tm_coretypes.ResultBlock) error {
+		if atomic.AddInt32(&subscriptions, 1) == 1 {
+			return errors.New("websocket unavailable")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pv.watchCommits(ctx)
+		close(done)
+	}()
+
+	// The blocks are polled while the subscription is unavailable, and it is retried
+	// after the backoff.
+	require.Eventually(t, func() bool {
+		clock.Advance(time.Second)
+		return atomic.LoadInt32(&subscriptions) == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []int64{10}, queried())
+	assert.Equal(t, 1, pv.GetMissedInARow())
+
+	// A subscription that doesn't deliver any blocks is stale, so the blocks are
+	// polled again before it is retried.
+	require.Eventually(t, func() bool {
+		clock.Advance(time.Second)
+		return atomic.LoadInt32(&subscriptions) == 3
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
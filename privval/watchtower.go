@@ -0,0 +1,58 @@
+package privval
+
+import (
+	"fmt"
+
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+)
+
+const (
+	// watchtowerEvents determines the number of recent events that are kept for
+	// monitors polling the integration API.
+	watchtowerEvents = 256
+)
+
+// emit adds an event of the given type to the events served by the integration API
+// and sends an alert for it, if alerts are sent for its type.
+func (pv *SCFilePV) emit(t watchtower.EventType, format string, args ...interface{}) {
+	e := watchtower.Event{
+		Time:    pv.Clock.Now().UTC(),
+		Type:    t,
+		Height:  pv.GetCurrentHeight(),
+		Rank:    pv.GetRank(),
+		Message: fmt.Sprintf(format, args...),
+	}
+	if pv.watchEvents != nil {
+		e = pv.watchEvents.Add(e)
+	}
+	pv.alert(e)
+}
+
+// WatchtowerStatus returns the node's status as served by the integration API.
+func (pv *SCFilePV) WatchtowerStatus() watchtower.Status {
+	status := watchtower.Status{
+		APIVersion:   watchtower.Version,
+		ChainID:      pv.Config.Privval.ChainID,
+		Running:      pv.IsRunning(),
+		Connected:    pv.GetConnState() == ConnConnected,
+		Height:       pv.GetCurrentHeight(),
+		Rank:         pv.GetRank(),
+		SetSize:      pv.Config.Base.SetSize,
+		MissedInARow: pv.GetMissedInARow(),
+		Threshold:    pv.GetThreshold(),
+		Crashed:      pv.IsCrashed(),
+	}
+
+	if pub, err := pv.pubKey(); err == nil {
+		status.Address = pub.Address().String()
+	}
+
+	if slashing, ok := pv.GetSlashingStatus(); ok {
+		status.Jailed = slashing.Jailed
+		status.Tombstoned = slashing.Tombstoned
+	}
+	_, status.ValidatorStale = pv.ValidatorStaleSince()
+	status.Signing = status.Running && status.Connected && status.Rank == 1 && !status.Crashed && !status.Tombstoned
+
+	return status
+}
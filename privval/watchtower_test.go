@@ -0,0 +1,62 @@
+package privval
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchtowerStatus(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pub, err := pv.TMFilePV.GetPubKey()
+	assert.NoError(t, err)
+
+	status := pv.WatchtowerStatus()
+	assert.Equal(t, watchtower.Version, status.APIVersion)
+	assert.Equal(t, pv.Config.Privval.ChainID, status.ChainID)
+	assert.Equal(t, pub.Address().String(), status.Address)
+	assert.Equal(t, pv.GetRank(), status.Rank)
+	assert.False(t, status.Running)
+	assert.False(t, status.Signing)
+
+	pv.setSlashingStatus(SlashingStatus{Tombstoned: true, UpdatedAt: time.Now()})
+	assert.True(t, pv.WatchtowerStatus().Tombstoned)
+}
+
+func TestWatchtowerEvents(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.SetRank(2)
+	pv.OnPromote()
+	pv.setSlashingStatus(SlashingStatus{Jailed: true, UpdatedAt: time.Now()})
+
+	events, lastSeq, _ := pv.watchEvents.Since(0)
+	assert.Equal(t, uint64(2), lastSeq)
+	assert.Equal(t, watchtower.EventPromoted, events[0].Type)
+	assert.Equal(t, 2, events[0].Rank)
+	assert.Equal(t, watchtower.EventJailed, events[1].Type)
+}
+
+func TestWatchtowerAPI(t *testing.T) {
+	port, err := getFreePort(t)
+	assert.NoError(t, err)
+	pv := mockSCFilePV(t)
+	pv.HTTP.Addr = fmt.Sprintf("127.0.0.1:%v", port)
+	assert.NoError(t, pv.StartHTTPServer())
+	defer pv.HTTP.Close()
+	pv.OnPromote()
+
+	// The integration API is served next to the /status endpoint.
+	c := watchtower.NewClient(fmt.Sprintf("http://127.0.0.1:%v", port))
+	status, err := c.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, pv.Config.Privval.ChainID, status.ChainID)
+
+	res, err := c.Events(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Len(t, res.Events, 1)
+	assert.Equal(t, watchtower.EventPromoted, res.Events[0].Type)
+}
@@ -0,0 +1,124 @@
+package privval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BlockscapeNetwork/signctrl/statemac"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+// Watermark is the height, round and step of the last vote or proposal signed with the
+// validator's key. Signing anything at or below the watermark could lead to
+// double-signing, unless it is the exact same vote or proposal.
+type Watermark struct {
+	Height int64
+	Round  int32
+	Step   int8
+}
+
+// After returns true if w is ahead of other.
+func (w Watermark) After(other Watermark) bool {
+	if w.Height != other.Height {
+		return w.Height > other.Height
+	}
+	if w.Round != other.Round {
+		return w.Round > other.Round
+	}
+
+	return w.Step > other.Step
+}
+
+// String returns the string representation of the watermark.
+func (w Watermark) String() string {
+	return fmt.Sprintf("%v/%v/%v", w.Height, w.Round, w.Step)
+}
+
+// RaiseWatermark raises the last sign state in the priv_validator_state.json file at
+// the given path to w if w is ahead of it. It returns true if the file was changed.
+// The file is never lowered, so importing an outdated watermark is always safe. As
+// the signature for the new watermark is unknown, Tendermint refuses to sign anything
+// at exactly the new watermark. The file is replaced atomically, see the statefile
+// package, and its MAC is updated if the file is protected.
+func RaiseWatermark(stateFile string, w Watermark) (bool, error) {
+	var state tm_privval.FilePVLastSignState
+	if err := unmarshalFile(stateFile, &state); err != nil {
+		return false, err
+	}
+
+	cur := Watermark{Height: state.Height, Round: state.Round, Step: state.Step}
+	if !w.After(cur) {
+		return false, nil
+	}
+
+	state.Height, state.Round, state.Step = w.Height, w.Round, w.Step
+	state.Signature, state.SignBytes = nil, nil
+	bytes, err := tm_json.MarshalIndent(&state, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	if err := statemac.Write(filepath.Dir(stateFile), stateFile, bytes, 0600); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// flexInt parses integers encoded either as JSON numbers or as JSON strings, as the
+// state files of other signers differ in how they encode 64-bit integers.
+type flexInt int64
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (i *flexInt) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*i = 0
+		return nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer: %v", string(data))
+	}
+	*i = flexInt(n)
+
+	return nil
+}
+
+// signState defines the parts of a priv_validator_state.json-like sign state file
+// that are needed for the watermark. Besides Tendermint's own files, this covers the
+// {chain-id}_priv_validator_state.json and {chain-id}_share_sign_state.json files of
+// Horcrux cosigners, which encode the integers either as numbers or as strings.
+type signState struct {
+	Height flexInt `json:"height"`
+	Round  flexInt `json:"round"`
+	Step   flexInt `json:"step"`
+}
+
+// LoadSignStateWatermark loads the watermark from a priv_validator_state.json-like
+// sign state file, e.g. of another Tendermint node or a Horcrux cosigner.
+func LoadSignStateWatermark(path string) (Watermark, error) {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Watermark{}, err
+	}
+
+	var state signState
+	if err := json.Unmarshal(bz, &state); err != nil {
+		return Watermark{}, fmt.Errorf("couldn't read %v: %w", path, err)
+	}
+	if state.Height < 0 || state.Round < 0 || state.Step < 0 || state.Step > 3 {
+		return Watermark{}, errors.New("invalid height, round or step")
+	}
+
+	return Watermark{
+		Height: int64(state.Height),
+		Round:  int32(state.Round),
+		Step:   int8(state.Step),
+	}, nil
+}
@@ -0,0 +1,78 @@
+package privval
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/statefile"
+	"github.com/stretchr/testify/assert"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+func TestWatermark_After(t *testing.T) {
+	w := Watermark{Height: 10, Round: 1, Step: 2}
+	assert.True(t, Watermark{Height: 11}.After(w))
+	assert.True(t, Watermark{Height: 10, Round: 2}.After(w))
+	assert.True(t, Watermark{Height: 10, Round: 1, Step: 3}.After(w))
+	assert.False(t, w.After(w))
+	assert.False(t, Watermark{Height: 9, Round: 5, Step: 3}.After(w))
+}
+
+func TestRaiseWatermark(t *testing.T) {
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, StateFile)
+	filePV := tm_privval.GenFilePV(filepath.Join(dir, KeyFile), stateFile)
+	filePV.LastSignState.Height = 10
+	filePV.LastSignState.Signature = []byte("sig")
+	filePV.LastSignState.SignBytes = []byte("bytes")
+	filePV.Save()
+
+	// The sign state must never be lowered.
+	raised, err := RaiseWatermark(stateFile, Watermark{Height: 9, Step: 3})
+	assert.NoError(t, err)
+	assert.False(t, raised)
+
+	raised, err = RaiseWatermark(stateFile, Watermark{Height: 12, Round: 1, Step: 2})
+	assert.NoError(t, err)
+	assert.True(t, raised)
+
+	loaded := tm_privval.LoadFilePV(filepath.Join(dir, KeyFile), stateFile)
+	assert.Equal(t, int64(12), loaded.LastSignState.Height)
+	assert.Equal(t, int32(1), loaded.LastSignState.Round)
+	assert.Equal(t, int8(2), loaded.LastSignState.Step)
+	assert.Nil(t, loaded.LastSignState.Signature)
+	assert.Nil(t, loaded.LastSignState.SignBytes)
+
+	// The file is written like SignCTRL's own state files, keeping the previous sign
+	// state as the backup.
+	_, err = statefile.Load(stateFile, func([]byte) error { return nil })
+	assert.NoError(t, err)
+	var backup tm_privval.FilePVLastSignState
+	assert.NoError(t, unmarshalFile(statefile.BackupPath(stateFile), &backup))
+	assert.Equal(t, int64(10), backup.Height)
+}
+
+func TestLoadSignStateWatermark(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"priv_validator_state.json": `{"height":"1234","round":"0","step":3,"signature":"c2ln","signbytes":"0A0B"}`,
+		"share_sign_state.json":     `{"height":1234,"round":0,"step":3,"ephemeral_public":null}`,
+	} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+
+		w, err := LoadSignStateWatermark(path)
+		assert.NoError(t, err, name)
+		assert.Equal(t, Watermark{Height: 1234, Round: 0, Step: 3}, w, name)
+	}
+
+	path := filepath.Join(dir, "invalid.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"height":"1234","round":"0","step":4}`), 0600))
+	_, err := LoadSignStateWatermark(path)
+	assert.Error(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"height":"abc"}`), 0600))
+	_, err = LoadSignStateWatermark(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,321 @@
+// Package rank implements the rank logic of SignCTRL as a pure state machine. Events
+// are fed into Next together with the current state, which returns the transition to
+// the next state, including the effects the caller is supposed to act on. The package
+// does neither log nor call back into its callers.
+package rank
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrThresholdExceeded is returned when the threshold of too many missed blocks in
+	// a row is exceeded.
+	ErrThresholdExceeded = errors.New("threshold exceeded due to too many blocks missed in a row")
+
+	// ErrMustShutdown is returned when the current signer (rank 1) needs to update its
+	// rank and must be shut down because rank 1 cannot be promoted anymore.
+	ErrMustShutdown = errors.New("node cannot be promoted anymore, so it must be shut down")
+
+	// ErrCounterLocked is returned when the counter for missed blocks in a row is
+	// still locked due to SignCTRL not having seen a signed block from rank 1.
+	ErrCounterLocked = errors.New("waiting for first commitsig from validator to unlock counter for missed blocks in a row")
+
+	// ErrCannotDemote is returned when a validator that isn't ranked first, or whose
+	// set size is unknown, is to be demoted.
+	ErrCannotDemote = errors.New("only rank 1 can be demoted to the last rank of the set")
+)
+
+// State defines the rank related state of a validator in the SignCTRL set.
+type State struct {
+	// Height is the current block height.
+	Height int64
+
+	// Rank is the validator's current rank.
+	Rank int
+
+	// MissedInARow is the number of blocks missed in a row.
+	MissedInARow int
+
+	// Threshold is the number of blocks missed in a row that triggers a rank update.
+	Threshold int
+
+	// SetSize is the number of validators in the set, which is the rank a demoted
+	// signer moves to. Demotion isn't possible if it is 0.
+	SetSize int
+
+	// ThresholdDuration is the time without a block signed by rank 1 that triggers a
+	// rank update as well, measured by the block timestamps. It is disabled if 0.
+	ThresholdDuration time.Duration
+
+	// BlockTime is the timestamp of the current block.
+	BlockTime time.Time
+
+	// LastSigned is the timestamp of the last block signed by rank 1, or of the first
+	// missed block if no signed block has been seen yet.
+	LastSigned time.Time
+
+	// CounterLocked determines whether the counter for missed blocks in a row is
+	// locked.
+	CounterLocked bool
+}
+
+// MissedFor returns the time without a block signed by rank 1 as of the current
+// block. It is 0 if no blocks were missed.
+func (s State) MissedFor() time.Duration {
+	if s.MissedInARow == 0 || s.LastSigned.IsZero() || s.BlockTime.Before(s.LastSigned) {
+		return 0
+	}
+
+	return s.BlockTime.Sub(s.LastSigned)
+}
+
+// missedTooLong returns true if no block was signed by rank 1 for at least the
+// threshold duration. Like the threshold, it requires at least 2 blocks missed in a
+// row.
+func (s State) missedTooLong() bool {
+	return s.ThresholdDuration > 0 && s.MissedInARow >= 2 && s.MissedFor() >= s.ThresholdDuration
+}
+
+// NewState returns the state of a validator that has just been started with the
+// given threshold and rank. The counter for missed blocks in a row is locked until
+// the first commitsig is found.
+func NewState(threshold, rank int) State {
+	return State{
+		Height:        1,
+		Rank:          rank,
+		Threshold:     threshold,
+		CounterLocked: true,
+	}
+}
+
+// Event is an event that causes a transition of the state.
+type Event uint8
+
+const (
+	// EventMissed signals that the validator missed a block.
+	EventMissed Event = iota + 1
+
+	// EventReset signals that the validator signed a block, so the counter for missed
+	// blocks in a row is reset.
+	EventReset
+
+	// EventLock signals that the counter for missed blocks in a row needs to be
+	// locked, e.g. after a reconnect.
+	EventLock
+
+	// EventUnlock signals that the first commitsig has been found, so that missed
+	// blocks in a row can be counted.
+	EventUnlock
+
+	// EventPromote signals that the validator needs to move up one rank.
+	EventPromote
+
+	// EventDemote signals that the signer exceeded the threshold and moves to the last
+	// rank of the set instead of being shut down.
+	EventDemote
+)
+
+// String returns the string representation of the event.
+func (e Event) String() string {
+	switch e {
+	case EventMissed:
+		return "Missed"
+	case EventReset:
+		return "Reset"
+	case EventLock:
+		return "Lock"
+	case EventUnlock:
+		return "Unlock"
+	case EventPromote:
+		return "Promote"
+	case EventDemote:
+		return "Demote"
+	}
+
+	return fmt.Sprintf("Event(%d)", uint8(e))
+}
+
+// Effect is a side effect of a transition that the caller is supposed to act on.
+type Effect uint8
+
+const (
+	// EffectMissed means that the counter for missed blocks in a row was incremented
+	// without reaching the threshold.
+	EffectMissed Effect = iota + 1
+
+	// EffectMissedTooMany means that the threshold of blocks missed in a row has been
+	// reached.
+	EffectMissedTooMany
+
+	// EffectPromoted means that the validator moved up one rank.
+	EffectPromoted
+
+	// EffectReset means that the counter for missed blocks in a row was reset to 0.
+	EffectReset
+
+	// EffectSkippedHeight means that the current height was skipped ahead, since the
+	// next block is known to not contain the validator's commitsig after a rank
+	// update.
+	EffectSkippedHeight
+
+	// EffectLocked means that the counter for missed blocks in a row was locked.
+	EffectLocked
+
+	// EffectUnlocked means that the counter for missed blocks in a row was unlocked.
+	EffectUnlocked
+
+	// EffectDemoted means that the signer moved to the last rank of the set.
+	EffectDemoted
+)
+
+// String returns the string representation of the effect.
+func (e Effect) String() string {
+	switch e {
+	case EffectMissed:
+		return "Missed"
+	case EffectMissedTooMany:
+		return "MissedTooMany"
+	case EffectPromoted:
+		return "Promoted"
+	case EffectReset:
+		return "Reset"
+	case EffectSkippedHeight:
+		return "SkippedHeight"
+	case EffectLocked:
+		return "Locked"
+	case EffectUnlocked:
+		return "Unlocked"
+	case EffectDemoted:
+		return "Demoted"
+	}
+
+	return fmt.Sprintf("Effect(%d)", uint8(e))
+}
+
+// Transition defines the transition from one state to the next.
+type Transition struct {
+	From    State
+	To      State
+	Event   Event
+	Effects []Effect
+	Err     error
+}
+
+// Has returns true if the transition has the given effect.
+func (t Transition) Has(effect Effect) bool {
+	for _, e := range t.Effects {
+		if e == effect {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Next returns the transition that the given event causes in state s. The state
+// passed in is never modified.
+func Next(s State, e Event) Transition {
+	t := Transition{From: s, To: s, Event: e}
+
+	switch e {
+	case EventMissed:
+		missed(&t)
+	case EventReset:
+		reset(&t)
+	case EventLock:
+		if !t.To.CounterLocked {
+			t.To.CounterLocked = true
+			t.Effects = append(t.Effects, EffectLocked)
+		}
+	case EventUnlock:
+		if t.To.CounterLocked {
+			t.To.CounterLocked = false
+			t.Effects = append(t.Effects, EffectUnlocked)
+		}
+	case EventPromote:
+		promote(&t)
+	case EventDemote:
+		demote(&t)
+	default:
+		t.Err = fmt.Errorf("unknown event: %v", e)
+	}
+
+	return t
+}
+
+// missed updates the counter for missed blocks in a row and promotes the validator
+// once the threshold or the threshold duration is reached.
+func missed(t *Transition) {
+	if t.To.CounterLocked {
+		t.Err = ErrCounterLocked
+		return
+	}
+
+	t.To.MissedInARow++
+	if t.To.LastSigned.IsZero() {
+		t.To.LastSigned = t.To.BlockTime
+	}
+	if t.To.MissedInARow < t.To.Threshold && !t.To.missedTooLong() {
+		t.Effects = append(t.Effects, EffectMissed)
+		return
+	}
+
+	t.Effects = append(t.Effects, EffectMissedTooMany)
+	if promote(t); t.Err != nil {
+		return
+	}
+
+	// When a rank update due to ErrThresholdExceeded is triggered, it is expected that
+	// the next block will not contain the validator's signature. This is due to a
+	// block containing the commit of the previous height which we know wasn't signed.
+	// Therefore, skip ahead.
+	// This is also the reason why the minimum threshold for blocks missed in a row is
+	// at 2.
+	t.To.Height++
+	t.Effects = append(t.Effects, EffectSkippedHeight)
+	t.Err = ErrThresholdExceeded
+}
+
+// reset resets the counter for missed blocks in a row to 0 and restarts the
+// threshold duration at the current block.
+func reset(t *Transition) {
+	t.To.LastSigned = t.To.BlockTime
+	if t.To.MissedInARow > 0 {
+		t.To.MissedInARow = 0
+		t.Effects = append(t.Effects, EffectReset)
+	}
+}
+
+// promote moves the validator up one rank. Rank 1 cannot be promoted anymore, so
+// ErrMustShutdown is set instead.
+func promote(t *Transition) {
+	if t.To.Rank <= 1 {
+		t.Err = ErrMustShutdown
+		return
+	}
+
+	t.To.Rank--
+	t.Effects = append(t.Effects, EffectPromoted)
+	reset(t)
+}
+
+// demote moves the signer to the last rank of the set. The other validators move up
+// one rank each once they see the threshold exceeded as well, so the last rank is
+// free. The counter for missed blocks in a row is reset and locked until the new
+// signer's first commitsig is found.
+func demote(t *Transition) {
+	if t.To.Rank != 1 || t.To.SetSize < 2 {
+		t.Err = ErrCannotDemote
+		return
+	}
+	t.To.Rank = t.To.SetSize
+	t.Effects = append(t.Effects, EffectDemoted)
+	reset(t)
+	if !t.To.CounterLocked {
+		t.To.CounterLocked = true
+		t.Effects = append(t.Effects, EffectLocked)
+	}
+}
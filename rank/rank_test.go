@@ -0,0 +1,278 @@
+package rank
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewState(t *testing.T) {
+	s := NewState(10, 2)
+	assert.Equal(t, State{Height: 1, Rank: 2, Threshold: 10, CounterLocked: true}, s)
+}
+
+func TestNext(t *testing.T) {
+	unlocked := State{Height: 5, Rank: 2, Threshold: 3}
+	t0 := time.Unix(1000, 0)
+	tests := []struct {
+		name    string
+		from    State
+		event   Event
+		to      State
+		effects []Effect
+		err     error
+	}{
+		{
+			name:  "missed while locked",
+			from:  State{Height: 5, Rank: 2, Threshold: 3, CounterLocked: true},
+			event: EventMissed,
+			to:    State{Height: 5, Rank: 2, Threshold: 3, CounterLocked: true},
+			err:   ErrCounterLocked,
+		},
+		{
+			name:    "missed below threshold",
+			from:    unlocked,
+			event:   EventMissed,
+			to:      State{Height: 5, Rank: 2, Threshold: 3, MissedInARow: 1},
+			effects: []Effect{EffectMissed},
+		},
+		{
+			name:    "missed reaching threshold",
+			from:    State{Height: 5, Rank: 2, Threshold: 3, MissedInARow: 2},
+			event:   EventMissed,
+			to:      State{Height: 6, Rank: 1, Threshold: 3},
+			effects: []Effect{EffectMissedTooMany, EffectPromoted, EffectReset, EffectSkippedHeight},
+			err:     ErrThresholdExceeded,
+		},
+		{
+			name:    "missed reaching threshold on rank 1",
+			from:    State{Height: 5, Rank: 1, Threshold: 3, MissedInARow: 2},
+			event:   EventMissed,
+			to:      State{Height: 5, Rank: 1, Threshold: 3, MissedInARow: 3},
+			effects: []Effect{EffectMissedTooMany},
+			err:     ErrMustShutdown,
+		},
+		{
+			name:    "missed beyond threshold on rank 1",
+			from:    State{Height: 5, Rank: 1, Threshold: 3, MissedInARow: 3},
+			event:   EventMissed,
+			to:      State{Height: 5, Rank: 1, Threshold: 3, MissedInARow: 4},
+			effects: []Effect{EffectMissedTooMany},
+			err:     ErrMustShutdown,
+		},
+		{
+			name:    "missed below threshold duration",
+			from:    State{Height: 5, Rank: 2, Threshold: 10, ThresholdDuration: time.Minute, BlockTime: t0.Add(59 * time.Second), LastSigned: t0, MissedInARow: 1},
+			event:   EventMissed,
+			to:      State{Height: 5, Rank: 2, Threshold: 10, ThresholdDuration: time.Minute, BlockTime: t0.Add(59 * time.Second), LastSigned: t0, MissedInARow: 2},
+			effects: []Effect{EffectMissed},
+		},
+		{
+			name:    "missed reaching threshold duration",
+			from:    State{Height: 5, Rank: 2, Threshold: 10, ThresholdDuration: time.Minute, BlockTime: t0.Add(time.Minute), LastSigned: t0, MissedInARow: 1},
+			event:   EventMissed,
+			to:      State{Height: 6, Rank: 1, Threshold: 10, ThresholdDuration: time.Minute, BlockTime: t0.Add(time.Minute), LastSigned: t0.Add(time.Minute)},
+			effects: []Effect{EffectMissedTooMany, EffectPromoted, EffectReset, EffectSkippedHeight},
+			err:     ErrThresholdExceeded,
+		},
+		{
+			name:    "first missed block after threshold duration",
+			from:    State{Height: 5, Rank: 2, Threshold: 10, ThresholdDuration: time.Minute, BlockTime: t0.Add(time.Hour), LastSigned: t0},
+			event:   EventMissed,
+			to:      State{Height: 5, Rank: 2, Threshold: 10, ThresholdDuration: time.Minute, BlockTime: t0.Add(time.Hour), LastSigned: t0, MissedInARow: 1},
+			effects: []Effect{EffectMissed},
+		},
+		{
+			name:    "first missed block without signed block",
+			from:    State{Height: 5, Rank: 2, Threshold: 10, ThresholdDuration: time.Minute, BlockTime: t0},
+			event:   EventMissed,
+			to:      State{Height: 5, Rank: 2, Threshold: 10, ThresholdDuration: time.Minute, BlockTime: t0, LastSigned: t0, MissedInARow: 1},
+			effects: []Effect{EffectMissed},
+		},
+		{
+			name:    "reset restarts threshold duration",
+			from:    State{Height: 5, Rank: 2, Threshold: 10, ThresholdDuration: time.Minute, BlockTime: t0.Add(time.Second), LastSigned: t0, MissedInARow: 1},
+			event:   EventReset,
+			to:      State{Height: 5, Rank: 2, Threshold: 10, ThresholdDuration: time.Minute, BlockTime: t0.Add(time.Second), LastSigned: t0.Add(time.Second)},
+			effects: []Effect{EffectReset},
+		},
+		{
+			name:    "reset",
+			from:    State{Height: 5, Rank: 2, Threshold: 3, MissedInARow: 2},
+			event:   EventReset,
+			to:      unlocked,
+			effects: []Effect{EffectReset},
+		},
+		{
+			name:  "reset without missed blocks",
+			from:  unlocked,
+			event: EventReset,
+			to:    unlocked,
+		},
+		{
+			name:    "lock",
+			from:    unlocked,
+			event:   EventLock,
+			to:      State{Height: 5, Rank: 2, Threshold: 3, CounterLocked: true},
+			effects: []Effect{EffectLocked},
+		},
+		{
+			name:  "lock while locked",
+			from:  State{Height: 5, Rank: 2, Threshold: 3, CounterLocked: true},
+			event: EventLock,
+			to:    State{Height: 5, Rank: 2, Threshold: 3, CounterLocked: true},
+		},
+		{
+			name:    "unlock",
+			from:    State{Height: 5, Rank: 2, Threshold: 3, CounterLocked: true},
+			event:   EventUnlock,
+			to:      unlocked,
+			effects: []Effect{EffectUnlocked},
+		},
+		{
+			name:  "unlock while unlocked",
+			from:  unlocked,
+			event: EventUnlock,
+			to:    unlocked,
+		},
+		{
+			name:    "promote",
+			from:    State{Height: 5, Rank: 3, Threshold: 3, MissedInARow: 1},
+			event:   EventPromote,
+			to:      State{Height: 5, Rank: 2, Threshold: 3},
+			effects: []Effect{EffectPromoted, EffectReset},
+		},
+		{
+			name:  "promote rank 1",
+			from:  State{Height: 5, Rank: 1, Threshold: 3},
+			event: EventPromote,
+			to:    State{Height: 5, Rank: 1, Threshold: 3},
+			err:   ErrMustShutdown,
+		},
+		{
+			name:    "demote",
+			from:    State{Height: 5, Rank: 1, Threshold: 3, SetSize: 3, MissedInARow: 3, BlockTime: t0},
+			event:   EventDemote,
+			to:      State{Height: 5, Rank: 3, Threshold: 3, SetSize: 3, BlockTime: t0, LastSigned: t0, CounterLocked: true},
+			effects: []Effect{EffectDemoted, EffectReset, EffectLocked},
+		},
+		{
+			name:  "demote backup",
+			from:  State{Height: 5, Rank: 2, Threshold: 3, SetSize: 3},
+			event: EventDemote,
+			to:    State{Height: 5, Rank: 2, Threshold: 3, SetSize: 3},
+			err:   ErrCannotDemote,
+		},
+		{
+			name:  "demote without set size",
+			from:  State{Height: 5, Rank: 1, Threshold: 3},
+			event: EventDemote,
+			to:    State{Height: 5, Rank: 1, Threshold: 3},
+			err:   ErrCannotDemote,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := Next(tt.from, tt.event)
+			assert.Equal(t, tt.from, tr.From)
+			assert.Equal(t, tt.to, tr.To)
+			assert.Equal(t, tt.event, tr.Event)
+			assert.Equal(t, tt.effects, tr.Effects)
+			assert.Equal(t, tt.err, tr.Err)
+		})
+	}
+}
+
+func TestState_MissedFor(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	assert.Equal(t, time.Duration(0), State{BlockTime: t0.Add(time.Minute), LastSigned: t0}.MissedFor())
+	assert.Equal(t, time.Minute, State{MissedInARow: 1, BlockTime: t0.Add(time.Minute), LastSigned: t0}.MissedFor())
+	assert.Equal(t, time.Duration(0), State{MissedInARow: 1, BlockTime: t0}.MissedFor())
+}
+
+func TestNext_UnknownEvent(t *testing.T) {
+	s := NewState(10, 2)
+	tr := Next(s, Event(0))
+	assert.Equal(t, s, tr.To)
+	assert.Error(t, tr.Err)
+}
+
+func TestTransitionHas(t *testing.T) {
+	tr := Transition{Effects: []Effect{EffectMissedTooMany, EffectPromoted}}
+	assert.True(t, tr.Has(EffectPromoted))
+	assert.False(t, tr.Has(EffectReset))
+}
+
+// scenario is a randomly generated start state together with a sequence of events.
+type scenario struct {
+	Start  State
+	Events []Event
+}
+
+// Generate implements quick.Generator.
+func (scenario) Generate(r *rand.Rand, size int) reflect.Value {
+	sc := scenario{Start: NewState(2+r.Intn(10), 1+r.Intn(5))}
+	for i := 0; i < size*10; i++ {
+		sc.Events = append(sc.Events, Event(1+r.Intn(int(EventPromote))))
+	}
+
+	return reflect.ValueOf(sc)
+}
+
+func TestNext_Properties(t *testing.T) {
+	property := func(sc scenario) bool {
+		s := sc.Start
+		for _, e := range sc.Events {
+			before := s
+			tr := Next(s, e)
+			s = tr.To
+
+			// Next is a pure function.
+			if !reflect.DeepEqual(before, tr.From) || !reflect.DeepEqual(tr, Next(before, e)) {
+				return false
+			}
+
+			// The threshold never changes.
+			if s.Threshold != before.Threshold {
+				return false
+			}
+
+			// Ranks only ever move up and never below 1.
+			if s.Rank > before.Rank || s.Rank < 1 {
+				return false
+			}
+
+			// Ranks only move up one at a time and always reset the counter.
+			if s.Rank != before.Rank && (s.Rank != before.Rank-1 || s.MissedInARow != 0 || !tr.Has(EffectPromoted)) {
+				return false
+			}
+
+			// Heights never move backwards.
+			if s.Height < before.Height {
+				return false
+			}
+
+			// The counter never changes while it is locked, except for resets.
+			if before.CounterLocked && s.MissedInARow > before.MissedInARow {
+				return false
+			}
+
+			// Only rank 1 can exceed the threshold, in which case it must shut down.
+			if s.MissedInARow >= s.Threshold && s.Rank != 1 {
+				return false
+			}
+			if s.MissedInARow > before.MissedInARow && s.MissedInARow >= s.Threshold && tr.Err != ErrMustShutdown {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	assert.NoError(t, quick.Check(property, &quick.Config{MaxCount: 1000}))
+}
@@ -0,0 +1,142 @@
+package remotewrite
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// label is a label of a time series.
+type label struct {
+	name  string
+	value string
+}
+
+// series is a time series with a single sample, as sent in a remote-write request.
+type series struct {
+	labels    []label
+	value     float64
+	timestamp int64
+}
+
+// toSeries converts the gathered metric families into time series with the given
+// timestamp. The extra labels are added to every series, unless a metric already
+// has a label of the same name. Histograms and summaries are split into the series
+// Prometheus would have scraped, e.g. _bucket, _sum and _count.
+func toSeries(families []*dto.MetricFamily, extra map[string]string, ts time.Time) []series {
+	var out []series
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			t := ts.UnixNano() / int64(time.Millisecond)
+			if m.TimestampMs != nil {
+				t = m.GetTimestampMs()
+			}
+			add := func(name string, value float64, labels ...label) {
+				out = append(out, series{
+					labels:    seriesLabels(name, m.GetLabel(), extra, labels),
+					value:     value,
+					timestamp: t,
+				})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add(name+"_bucket", float64(b.GetCumulativeCount()), label{"le", formatFloat(b.GetUpperBound())})
+				}
+				add(name+"_bucket", float64(h.GetSampleCount()), label{"le", "+Inf"})
+				add(name+"_sum", h.GetSampleSum())
+				add(name+"_count", float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(name, q.GetValue(), label{"quantile", formatFloat(q.GetQuantile())})
+				}
+				add(name+"_sum", s.GetSampleSum())
+				add(name+"_count", float64(s.GetSampleCount()))
+			}
+		}
+	}
+
+	return out
+}
+
+// seriesLabels returns the sorted labels of a series, as required by remote-write
+// receivers.
+func seriesLabels(name string, pairs []*dto.LabelPair, extra map[string]string, labels []label) []label {
+	set := make(map[string]string, len(pairs)+len(extra)+len(labels)+1)
+	for k, v := range extra {
+		set[k] = v
+	}
+	for _, p := range pairs {
+		set[p.GetName()] = p.GetValue()
+	}
+	for _, l := range labels {
+		set[l.name] = l.value
+	}
+	set["__name__"] = name
+
+	out := make([]label, 0, len(set))
+	for k, v := range set {
+		out = append(out, label{name: k, value: v})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+
+	return out
+}
+
+// formatFloat formats bucket bounds and quantiles like Prometheus does.
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// encode encodes the given time series into a remote-write WriteRequest:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label        { string name = 1; string value = 2; }
+//	message Sample       { double value = 1; int64 timestamp = 2; }
+func encode(ss []series) []byte {
+	var req []byte
+	for _, s := range ss {
+		var ts []byte
+		for _, l := range s.labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, lb)
+		}
+
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(s.timestamp))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+
+	return req
+}
@@ -0,0 +1,149 @@
+// Package remotewrite pushes SignCTRL's prometheus metrics to a Prometheus
+// remote-write endpoint. Signer hosts are often not allowed to accept inbound
+// connections, so instead of being scraped, the pusher sends the metrics over an
+// outbound connection in a regular interval.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// protocolVersion is the version of the remote-write protocol spoken by the
+	// pusher.
+	protocolVersion = "0.1.0"
+
+	// maxErrorBody determines the number of bytes of an error response that are
+	// included in the returned error.
+	maxErrorBody = 512
+)
+
+var (
+	// ErrPushFailed is returned if the remote-write endpoint didn't accept the pushed
+	// metrics.
+	ErrPushFailed = errors.New("remote-write push failed")
+)
+
+// Pusher pushes the metrics of a prometheus.Gatherer to a remote-write endpoint.
+type Pusher struct {
+	// URL is the URL of the remote-write endpoint.
+	URL string
+
+	// Interval is the interval in which the metrics are pushed.
+	Interval time.Duration
+
+	// Labels are added to every pushed time series, e.g. the job and instance
+	// labels Prometheus would have added when scraping.
+	Labels map[string]string
+
+	// BearerToken is sent with every push if it is not empty.
+	BearerToken string
+
+	// Gatherer is the source of the metrics.
+	Gatherer prometheus.Gatherer
+
+	// HTTP is the HTTP client used for the pushes.
+	HTTP *http.Client
+
+	Clock  types.Clock
+	Logger types.Logger
+}
+
+// NewPusher creates a new pusher for the given [metrics] section, which pushes the
+// metrics registered with prometheus' default registry.
+func NewPusher(cfg config.Metrics, logger types.Logger) (*Pusher, error) {
+	instance := cfg.Instance
+	if instance == "" {
+		var err error
+		if instance, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("couldn't get host name for the instance label: %w", err)
+		}
+	}
+
+	var token string
+	if cfg.BearerTokenFile != "" {
+		bz, err := ioutil.ReadFile(cfg.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read bearer token: %w", err)
+		}
+		token = strings.TrimSpace(string(bz))
+	}
+
+	return &Pusher{
+		URL:         cfg.RemoteWriteURL,
+		Interval:    cfg.GetRemoteWriteInterval(),
+		Labels:      map[string]string{"job": "signctrl", "instance": instance},
+		BearerToken: token,
+		Gatherer:    prometheus.DefaultGatherer,
+		HTTP:        http.DefaultClient,
+		Clock:       types.SystemClock,
+		Logger:      logger,
+	}, nil
+}
+
+// Push gathers the metrics and pushes them to the remote-write endpoint once.
+func (p *Pusher) Push(ctx context.Context) error {
+	families, err := p.Gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("couldn't gather metrics: %w", err)
+	}
+	body := snappy.Encode(nil, encode(toSeries(families, p.Labels, p.Clock.Now())))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "signctrl")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", protocolVersion)
+	if p.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.BearerToken)
+	}
+
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("%w: %v: %v", ErrPushFailed, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// Run pushes the metrics in the configured interval until ctx is done. Failed pushes
+// are logged and not retried, as the next push carries the current values anyway.
+func (p *Pusher) Run(ctx context.Context) {
+	p.Logger.Info("Pushing metrics to %v every %v", p.URL, p.Interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.Clock.After(p.Interval):
+		}
+
+		pushCtx, cancel := context.WithTimeout(ctx, p.Interval)
+		if err := p.Push(pushCtx); err != nil && ctx.Err() == nil {
+			p.Logger.Error("couldn't push metrics: %v\n", err)
+		}
+		cancel()
+	}
+}
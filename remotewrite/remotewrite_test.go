@@ -0,0 +1,223 @@
+package remotewrite
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decode decodes a remote-write WriteRequest.
+func decode(t *testing.T, bz []byte) []series {
+	t.Helper()
+	var out []series
+	for len(bz) > 0 {
+		num, typ, n := protowire.ConsumeTag(bz)
+		require.True(t, n > 0 && num == 1 && typ == protowire.BytesType)
+		bz = bz[n:]
+		ts, n := protowire.ConsumeBytes(bz)
+		require.True(t, n > 0)
+		bz = bz[n:]
+
+		var s series
+		for len(ts) > 0 {
+			num, _, n := protowire.ConsumeTag(ts)
+			require.True(t, n > 0)
+			ts = ts[n:]
+			msg, n := protowire.ConsumeBytes(ts)
+			require.True(t, n > 0)
+			ts = ts[n:]
+
+			switch num {
+			case 1:
+				var l label
+				for len(msg) > 0 {
+					num, _, n := protowire.ConsumeTag(msg)
+					msg = msg[n:]
+					v, n := protowire.ConsumeString(msg)
+					require.True(t, n > 0)
+					msg = msg[n:]
+					if num == 1 {
+						l.name = v
+					} else {
+						l.value = v
+					}
+				}
+				s.labels = append(s.labels, l)
+			case 2:
+				_, _, n := protowire.ConsumeTag(msg)
+				msg = msg[n:]
+				v, n := protowire.ConsumeFixed64(msg)
+				msg = msg[n:]
+				s.value = math.Float64frombits(v)
+				_, _, n = protowire.ConsumeTag(msg)
+				msg = msg[n:]
+				ms, _ := protowire.ConsumeVarint(msg)
+				s.timestamp = int64(ms)
+			}
+		}
+		out = append(out, s)
+	}
+
+	return out
+}
+
+// testRegistry returns a registry with a gauge, a counter vector and a histogram.
+func testRegistry(t *testing.T) *prometheus.Registry {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "signctrl_rank"})
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "signctrl_rpc_requests_total"}, []string{"result"})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "signctrl_duration_seconds", Buckets: []float64{0.5, 1}})
+	reg.MustRegister(gauge, counter, histogram)
+
+	gauge.Set(2)
+	counter.WithLabelValues("success").Add(3)
+	histogram.Observe(0.7)
+
+	return reg
+}
+
+func TestToSeries(t *testing.T) {
+	families, err := testRegistry(t).Gather()
+	require.NoError(t, err)
+	ss := toSeries(families, map[string]string{"job": "signctrl", "result": "overridden"}, time.Unix(10, 0))
+
+	assert.Equal(t, []series{
+		{labels: []label{{"__name__", "signctrl_duration_seconds_bucket"}, {"job", "signctrl"}, {"le", "0.5"}, {"result", "overridden"}}, value: 0, timestamp: 10000},
+		{labels: []label{{"__name__", "signctrl_duration_seconds_bucket"}, {"job", "signctrl"}, {"le", "1"}, {"result", "overridden"}}, value: 1, timestamp: 10000},
+		{labels: []label{{"__name__", "signctrl_duration_seconds_bucket"}, {"job", "signctrl"}, {"le", "+Inf"}, {"result", "overridden"}}, value: 1, timestamp: 10000},
+		{labels: []label{{"__name__", "signctrl_duration_seconds_sum"}, {"job", "signctrl"}, {"result", "overridden"}}, value: 0.7, timestamp: 10000},
+		{labels: []label{{"__name__", "signctrl_duration_seconds_count"}, {"job", "signctrl"}, {"result", "overridden"}}, value: 1, timestamp: 10000},
+		{labels: []label{{"__name__", "signctrl_rank"}, {"job", "signctrl"}, {"result", "overridden"}}, value: 2, timestamp: 10000},
+		// The metric's own labels take precedence over the extra labels.
+		{labels: []label{{"__name__", "signctrl_rpc_requests_total"}, {"job", "signctrl"}, {"result", "success"}}, value: 3, timestamp: 10000},
+	}, ss)
+}
+
+func TestEncode(t *testing.T) {
+	ss := []series{
+		{labels: []label{{"__name__", "a"}, {"job", "signctrl"}}, value: 1.5, timestamp: 1000},
+		{labels: []label{{"__name__", "b"}}, value: -2, timestamp: 2000},
+	}
+	assert.Equal(t, ss, decode(t, encode(ss)))
+	assert.Empty(t, encode(nil))
+}
+
+func TestPusher_Push(t *testing.T) {
+	var got []series
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, protocolVersion, r.Header.Get("X-Prometheus-Remote-Write-Version"))
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		bz, err := snappy.Decode(nil, body)
+		assert.NoError(t, err)
+		got = decode(t, bz)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	p := &Pusher{
+		URL:         srv.URL,
+		Labels:      map[string]string{"instance": "signer-1"},
+		BearerToken: "secret",
+		Gatherer:    testRegistry(t),
+		HTTP:        srv.Client(),
+		Clock:       types.NewFakeClock(time.Unix(10, 0)),
+	}
+	assert.NoError(t, p.Push(context.Background()))
+	require.Len(t, got, 7)
+	assert.Contains(t, got, series{labels: []label{{"__name__", "signctrl_rank"}, {"instance", "signer-1"}}, value: 2, timestamp: 10000})
+}
+
+func TestPusher_PushFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		http.Error(rw, "out of order sample", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	p := &Pusher{URL: srv.URL, Gatherer: testRegistry(t), HTTP: srv.Client(), Clock: types.SystemClock}
+	err := p.Push(context.Background())
+	assert.True(t, errors.Is(err, ErrPushFailed))
+	assert.Contains(t, err.Error(), "out of order sample")
+}
+
+func TestPusher_Run(t *testing.T) {
+	pushed := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		pushed <- struct{}{}
+	}))
+	defer srv.Close()
+
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	p := &Pusher{
+		URL:      srv.URL,
+		Interval: 15 * time.Second,
+		Gatherer: testRegistry(t),
+		HTTP:     srv.Client(),
+		Clock:    clock,
+		Logger:   types.NewSyncLogger(ioutil.Discard, "", 0),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(ctx)
+	}()
+
+	// The metrics are pushed once per interval.
+	for i := 0; i < 2; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(p.Interval)
+		select {
+		case <-pushed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("metrics weren't pushed")
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after ctx was canceled")
+	}
+}
+
+func TestNewPusher(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600))
+
+	p, err := NewPusher(config.Metrics{
+		RemoteWriteURL:      "https://prometheus.example.com/api/v1/write",
+		RemoteWriteInterval: "30s",
+		BearerTokenFile:     tokenFile,
+	}, nil)
+	require.NoError(t, err)
+	hostname, _ := os.Hostname()
+	assert.Equal(t, map[string]string{"job": "signctrl", "instance": hostname}, p.Labels)
+	assert.Equal(t, "secret", p.BearerToken)
+	assert.Equal(t, 30*time.Second, p.Interval)
+
+	_, err = NewPusher(config.Metrics{BearerTokenFile: filepath.Join(t.TempDir(), "missing")}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,262 @@
+// Package resources detects the CPU and memory limits the cgroup of SignCTRL's
+// container imposes and reports the current usage. Go schedules onto all of the
+// host's CPUs by default, so a container with a CPU quota below that is throttled in
+// bursts, which shows as sign latency spikes. SignCTRL therefore sets GOMAXPROCS to
+// the quota and sizes its worker pools by it, like go.uber.org/automaxprocs does.
+package resources
+
+import (
+	"bufio"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// cgroupRoot is the path cgroups are mounted at, relative to the file system root.
+	cgroupRoot = "sys/fs/cgroup"
+
+	// procCgroup is the file listing the process's cgroups, relative to the file
+	// system root.
+	procCgroup = "proc/self/cgroup"
+
+	// unlimitedMemory is the lowest memory limit cgroup v1 reports for unlimited
+	// memory, which is the maximum int64 rounded down to the page size.
+	unlimitedMemory = 1 << 62
+
+	// workersPerCPU is the number of workers per usable CPU. The workers mostly wait
+	// for the network, so there are more of them than CPUs.
+	workersPerCPU = 2
+)
+
+// Limits are the resource limits of a cgroup.
+type Limits struct {
+	// CPUs is the CPU quota in CPUs, e.g. 1.5. It is 0 if the CPUs aren't limited.
+	CPUs float64 `json:"cpus,omitempty"`
+
+	// Memory is the memory limit in bytes. It is 0 if the memory isn't limited.
+	Memory uint64 `json:"memory,omitempty"`
+}
+
+// Usage is the resource usage of a cgroup.
+type Usage struct {
+	// CPUTime is the CPU time consumed since the cgroup was created.
+	CPUTime time.Duration `json:"cpu_time"`
+
+	// Memory is the memory currently used in bytes.
+	Memory uint64 `json:"memory"`
+}
+
+// Resources defines the resources available to SignCTRL as reported in /status.
+type Resources struct {
+	// Cgroup is the cgroup version, either v1 or v2. It is empty if SignCTRL doesn't
+	// run in a cgroup, in which case the limits and the usage are empty.
+	Cgroup string `json:"cgroup,omitempty"`
+
+	Limits Limits `json:"limits"`
+	Usage  Usage  `json:"usage"`
+
+	// MaxProcs is the number of CPUs Go schedules onto (GOMAXPROCS).
+	MaxProcs int `json:"max_procs"`
+}
+
+// Cgroup reads the limits and usage of the cgroup the process runs in. All methods
+// are safe to be called on a nil Cgroup, which stands for no cgroup.
+type Cgroup struct {
+	fsys fs.FS
+
+	// version is either 1 or 2.
+	version int
+
+	// dirs are the directories to look for a controller's files in, in order of
+	// preference. With cgroup v2, all controllers share the key "".
+	dirs map[string][]string
+}
+
+// Detect detects the cgroup the process runs in. It returns nil if there is none,
+// e.g. outside of Linux.
+func Detect() *Cgroup {
+	return Open(os.DirFS("/"))
+}
+
+// Open detects the cgroup the process runs in on the given file system, which is
+// rooted at /. It returns nil if there is none.
+func Open(fsys fs.FS) *Cgroup {
+	// The cgroups are listed as hierarchy-ID:controllers:path. There's only one
+	// hierarchy with the ID 0 and no controllers with cgroup v2.
+	paths := make(map[string]string)
+	if f, err := fsys.Open(procCgroup); err == nil {
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			if fields := strings.SplitN(s.Text(), ":", 3); len(fields) == 3 {
+				paths[fields[1]] = fields[2]
+			}
+		}
+		f.Close()
+	}
+
+	if _, err := fs.Stat(fsys, path.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		// Inside a cgroup namespace, the process's cgroup is mounted at the root.
+		return &Cgroup{fsys: fsys, version: 2, dirs: map[string][]string{
+			"": {path.Join(cgroupRoot, paths[""]), cgroupRoot},
+		}}
+	}
+
+	dirs := make(map[string][]string)
+	for controllers, p := range paths {
+		for _, controller := range strings.Split(controllers, ",") {
+			for _, mount := range []string{controller, controllers} {
+				dir := path.Join(cgroupRoot, mount)
+				dirs[controller] = append(dirs[controller], path.Join(dir, p), dir)
+			}
+		}
+	}
+	if len(dirs["cpu"]) == 0 && len(dirs["memory"]) == 0 {
+		return nil
+	}
+
+	return &Cgroup{fsys: fsys, version: 1, dirs: dirs}
+}
+
+// Version returns the cgroup version, either v1 or v2, or an empty string if there is
+// no cgroup.
+func (c *Cgroup) Version() string {
+	if c == nil {
+		return ""
+	}
+
+	return "v" + strconv.Itoa(c.version)
+}
+
+// read returns the trimmed contents of the given file of the given controller. It
+// returns an empty string if the file doesn't exist.
+func (c *Cgroup) read(controller, file string) string {
+	if c.version == 2 {
+		controller = ""
+	}
+	for _, dir := range c.dirs[controller] {
+		if bytes, err := fs.ReadFile(c.fsys, path.Join(dir, file)); err == nil {
+			return strings.TrimSpace(string(bytes))
+		}
+	}
+
+	return ""
+}
+
+// readUint returns the unsigned integer in the given file of the given controller, or
+// 0 if it doesn't exist or doesn't contain one.
+func (c *Cgroup) readUint(controller, file string) uint64 {
+	n, _ := strconv.ParseUint(c.read(controller, file), 10, 64)
+	return n
+}
+
+// Limits returns the cgroup's CPU and memory limits.
+func (c *Cgroup) Limits() Limits {
+	if c == nil {
+		return Limits{}
+	}
+
+	var l Limits
+	if c.version == 2 {
+		// cpu.max contains the quota and the period in microseconds, or max as the
+		// quota if the CPUs aren't limited.
+		if fields := strings.Fields(c.read("", "cpu.max")); len(fields) == 2 {
+			quota, qerr := strconv.ParseFloat(fields[0], 64)
+			period, perr := strconv.ParseFloat(fields[1], 64)
+			if qerr == nil && perr == nil && quota > 0 && period > 0 {
+				l.CPUs = quota / period
+			}
+		}
+		l.Memory = c.readUint("", "memory.max")
+	} else {
+		// The quota is -1 if the CPUs aren't limited.
+		quota, qerr := strconv.ParseFloat(c.read("cpu", "cpu.cfs_quota_us"), 64)
+		period, perr := strconv.ParseFloat(c.read("cpu", "cpu.cfs_period_us"), 64)
+		if qerr == nil && perr == nil && quota > 0 && period > 0 {
+			l.CPUs = quota / period
+		}
+		if l.Memory = c.readUint("memory", "memory.limit_in_bytes"); l.Memory >= unlimitedMemory {
+			l.Memory = 0
+		}
+	}
+
+	return l
+}
+
+// Usage returns the cgroup's current CPU and memory usage.
+func (c *Cgroup) Usage() Usage {
+	if c == nil {
+		return Usage{}
+	}
+
+	var u Usage
+	if c.version == 2 {
+		for _, line := range strings.Split(c.read("", "cpu.stat"), "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "usage_usec" {
+				usec, _ := strconv.ParseInt(fields[1], 10, 64)
+				u.CPUTime = time.Duration(usec) * time.Microsecond
+			}
+		}
+		u.Memory = c.readUint("", "memory.current")
+	} else {
+		u.CPUTime = time.Duration(c.readUint("cpuacct", "cpuacct.usage"))
+		u.Memory = c.readUint("memory", "memory.usage_in_bytes")
+	}
+
+	return u
+}
+
+// Report returns the resources available to the process in the given cgroup.
+func Report(c *Cgroup) Resources {
+	return Resources{
+		Cgroup:   c.Version(),
+		Limits:   c.Limits(),
+		Usage:    c.Usage(),
+		MaxProcs: runtime.GOMAXPROCS(0),
+	}
+}
+
+// MaxProcs returns the number of CPUs Go should schedule onto with the given limits,
+// which is the CPU quota rounded down, but at least 1. It returns 0 if the CPUs
+// aren't limited.
+func MaxProcs(l Limits) int {
+	if l.CPUs <= 0 {
+		return 0
+	}
+	if n := int(math.Floor(l.CPUs)); n > 1 {
+		return n
+	}
+
+	return 1
+}
+
+// SetMaxProcs sets GOMAXPROCS to match the CPU quota of the given limits, unless it
+// was set via the environment or the quota allows for at least as many CPUs. It
+// returns the new GOMAXPROCS and true if it was changed.
+func SetMaxProcs(l Limits) (int, bool) {
+	n := MaxProcs(l)
+	if n == 0 || n >= runtime.GOMAXPROCS(0) || os.Getenv("GOMAXPROCS") != "" {
+		return runtime.GOMAXPROCS(0), false
+	}
+	runtime.GOMAXPROCS(n)
+
+	return n, true
+}
+
+// PoolSize returns the size of a worker pool of at most max workers, which is
+// limited by the number of CPUs Go schedules onto. It is at least 1.
+func PoolSize(max int) int {
+	if n := workersPerCPU * runtime.GOMAXPROCS(0); n < max {
+		max = n
+	}
+	if max < 1 {
+		return 1
+	}
+
+	return max
+}
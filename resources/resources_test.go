@@ -0,0 +1,110 @@
+package resources
+
+import (
+	"runtime"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen_None(t *testing.T) {
+	c := Open(fstest.MapFS{})
+	assert.Nil(t, c)
+	assert.Equal(t, "", c.Version())
+	assert.Equal(t, Limits{}, c.Limits())
+	assert.Equal(t, Usage{}, c.Usage())
+}
+
+func TestOpen_V2(t *testing.T) {
+	c := Open(fstest.MapFS{
+		"proc/self/cgroup":                   {Data: []byte("0::/\n")},
+		"sys/fs/cgroup/cgroup.controllers":   {Data: []byte("cpu memory\n")},
+		"sys/fs/cgroup/cpu.max":              {Data: []byte("150000 100000\n")},
+		"sys/fs/cgroup/memory.max":           {Data: []byte("536870912\n")},
+		"sys/fs/cgroup/memory.current":       {Data: []byte("1048576\n")},
+		"sys/fs/cgroup/cpu.stat":             {Data: []byte("usage_usec 2500000\nuser_usec 2000000\n")},
+		"sys/fs/cgroup/other/cpu.max":        {Data: []byte("max 100000\n")},
+		"sys/fs/cgroup/other/cgroup.procs":   {Data: []byte("1\n")},
+		"sys/fs/cgroup/other/memory.current": {Data: []byte("1\n")},
+	})
+	require.NotNil(t, c)
+	assert.Equal(t, "v2", c.Version())
+	assert.Equal(t, Limits{CPUs: 1.5, Memory: 512 << 20}, c.Limits())
+	assert.Equal(t, Usage{CPUTime: 2500 * time.Millisecond, Memory: 1 << 20}, c.Usage())
+}
+
+func TestOpen_V2Unlimited(t *testing.T) {
+	c := Open(fstest.MapFS{
+		"proc/self/cgroup":                                       {Data: []byte("0::/system.slice/signctrl.service\n")},
+		"sys/fs/cgroup/cgroup.controllers":                       {Data: []byte("cpu memory\n")},
+		"sys/fs/cgroup/cpu.max":                                  {Data: []byte("100000 100000\n")},
+		"sys/fs/cgroup/system.slice/signctrl.service/cpu.max":    {Data: []byte("max 100000\n")},
+		"sys/fs/cgroup/system.slice/signctrl.service/memory.max": {Data: []byte("max\n")},
+	})
+	require.NotNil(t, c)
+	assert.Equal(t, Limits{}, c.Limits())
+}
+
+func TestOpen_V1(t *testing.T) {
+	c := Open(fstest.MapFS{
+		"proc/self/cgroup":                                      {Data: []byte("12:memory:/docker/abc\n4:cpu,cpuacct:/docker/abc\n1:name=systemd:/docker/abc\n")},
+		"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_quota_us":            {Data: []byte("50000\n")},
+		"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_period_us":           {Data: []byte("100000\n")},
+		"sys/fs/cgroup/cpu,cpuacct/cpuacct.usage":               {Data: []byte("3000000000\n")},
+		"sys/fs/cgroup/memory/memory.limit_in_bytes":            {Data: []byte("9223372036854771712\n")},
+		"sys/fs/cgroup/memory/docker/abc/memory.usage_in_bytes": {Data: []byte("2048\n")},
+	})
+	require.NotNil(t, c)
+	assert.Equal(t, "v1", c.Version())
+	assert.Equal(t, Limits{CPUs: 0.5}, c.Limits())
+	assert.Equal(t, Usage{CPUTime: 3 * time.Second, Memory: 2048}, c.Usage())
+}
+
+func TestMaxProcs(t *testing.T) {
+	assert.Equal(t, 0, MaxProcs(Limits{}))
+	assert.Equal(t, 1, MaxProcs(Limits{CPUs: 0.5}))
+	assert.Equal(t, 1, MaxProcs(Limits{CPUs: 1.9}))
+	assert.Equal(t, 4, MaxProcs(Limits{CPUs: 4}))
+}
+
+func TestSetMaxProcs(t *testing.T) {
+	t.Setenv("GOMAXPROCS", "")
+	prev := runtime.GOMAXPROCS(4)
+	defer runtime.GOMAXPROCS(prev)
+
+	n, ok := SetMaxProcs(Limits{})
+	assert.False(t, ok)
+	assert.Equal(t, 4, n)
+
+	n, ok = SetMaxProcs(Limits{CPUs: 8})
+	assert.False(t, ok)
+	assert.Equal(t, 4, n)
+
+	n, ok = SetMaxProcs(Limits{CPUs: 2.5})
+	assert.True(t, ok)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 2, runtime.GOMAXPROCS(0))
+
+	// GOMAXPROCS set via the environment takes precedence.
+	t.Setenv("GOMAXPROCS", "2")
+	_, ok = SetMaxProcs(Limits{CPUs: 1})
+	assert.False(t, ok)
+}
+
+func TestPoolSize(t *testing.T) {
+	prev := runtime.GOMAXPROCS(2)
+	defer runtime.GOMAXPROCS(prev)
+
+	assert.Equal(t, 3, PoolSize(3))
+	assert.Equal(t, 4, PoolSize(10))
+	assert.Equal(t, 1, PoolSize(0))
+}
+
+func TestReport(t *testing.T) {
+	r := Report(nil)
+	assert.Equal(t, "", r.Cgroup)
+	assert.Equal(t, runtime.GOMAXPROCS(0), r.MaxProcs)
+}
@@ -2,16 +2,22 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"regexp"
 
+	"github.com/BlockscapeNetwork/signctrl/adapters"
 	"github.com/BlockscapeNetwork/signctrl/types"
 	tm_json "github.com/tendermint/tendermint/libs/json"
 	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
 )
 
+var (
+	// ErrNoBlockResult is returned if the response of the /block endpoint doesn't
+	// contain a result.
+	ErrNoBlockResult = errors.New("no result in /block response")
+)
+
 // BlockResult defines the JSONRPC 2.0 response structure for Tendermint's /block
 // endpoint.
 type BlockResult struct {
@@ -20,65 +26,39 @@ type BlockResult struct {
 	Result  *tm_coretypes.ResultBlock `json:"result"`
 }
 
-// resultChannelResponse defines the data passed into the result channel that is
-// retrieved from the /block endpoint.
-type resultChannelResponse struct {
-	result *tm_coretypes.ResultBlock
-	err    error
+// QueryBlock gets the block for the specified height from Tendermint's /block
+// endpoint.
+func QueryBlock(ctx context.Context, rpcladdr string, height int64, logger types.Logger) (*tm_coretypes.ResultBlock, error) {
+	return NewClient(logger).QueryBlockAt(ctx, rpcladdr, adapters.Default{}.BlockPath(height), height)
+}
+
+// QueryBlockAt gets the block for the specified height from the given path of the
+// validator's RPC server, for networks that serve blocks under a different path.
+func QueryBlockAt(ctx context.Context, rpcladdr, path string, height int64, logger types.Logger) (*tm_coretypes.ResultBlock, error) {
+	return NewClient(logger).QueryBlockAt(ctx, rpcladdr, path, height)
 }
 
-// QueryBlock gets the block for the specified height.
-func QueryBlock(ctx context.Context, rpcladdr string, height int64, logger *types.SyncLogger) (*tm_coretypes.ResultBlock, error) {
+// QueryBlockAt gets the block for the specified height from the given path of the
+// validator's RPC server.
+func (c *Client) QueryBlockAt(ctx context.Context, rpcladdr, path string, height int64) (*tm_coretypes.ResultBlock, error) {
 	if height < 1 {
 		return nil, fmt.Errorf("block height %v does not exist", height)
 	}
 
-	// Cut the protocol from rpcladdr.
-	rpcladdrHostPort := regexp.MustCompile(`(tcp|unix)://`).ReplaceAllString(rpcladdr, "")
-	url := fmt.Sprintf("http://%v/block?height=%v", rpcladdrHostPort, height)
-	resultCh := make(chan *resultChannelResponse)
-
-	go func() {
-		// Query the block.
-		logger.Debug("GET %v", url)
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-		if err != nil {
-			resultCh <- &resultChannelResponse{nil, err}
-			return
-		}
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			resultCh <- &resultChannelResponse{nil, err}
-			return
-		}
-
-		// Read from the response body.
-		bytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			resultCh <- &resultChannelResponse{nil, err}
-			return
-		}
-
-		var block BlockResult
-		if err := tm_json.Unmarshal(bytes, &block); err != nil {
-			resultCh <- &resultChannelResponse{nil, err}
-			return
-		}
-		if block.Result == nil {
-			resultCh <- &resultChannelResponse{nil, err}
-			return
-		}
+	var block BlockResult
+	if err := c.GetJSON(ctx, "block", rpcURL(rpcladdr, path), &block, tm_json.Unmarshal); err != nil {
+		return nil, err
+	}
+	if block.Result == nil {
+		return nil, fmt.Errorf("%w for height %v", ErrNoBlockResult, height)
+	}
 
-		resultCh <- &resultChannelResponse{block.Result, nil}
-	}()
+	return block.Result, nil
+}
 
-	// Wait for the query to return a result or be canceled.
-	select {
-	case <-ctx.Done():
-		logger.Debug("Canceled GET %v", url)
-		return nil, fmt.Errorf("request was canceled")
-	case rcr := <-resultCh:
-		logger.Debug("Received result for GET %v", url)
-		return rcr.result, rcr.err
-	}
+// rpcURL returns the URL for the given path on the RPC server at rpcladdr.
+func rpcURL(rpcladdr string, path string) string {
+	// Cut the protocol from rpcladdr.
+	rpcladdrHostPort := regexp.MustCompile(`(tcp|unix)://`).ReplaceAllString(rpcladdr, "")
+	return fmt.Sprintf("http://%v%v", rpcladdrHostPort, path)
 }
@@ -0,0 +1,291 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+const (
+	// DefaultTimeout is the default timeout of a single request.
+	DefaultTimeout = 5 * time.Second
+
+	// DefaultRetries is the default number of times a failed request is retried.
+	DefaultRetries = 2
+
+	// DefaultRetryBackoff is the default time waited before the first retry. It is
+	// doubled for every further retry.
+	DefaultRetryBackoff = 200 * time.Millisecond
+
+	// DefaultCircuitThreshold is the default number of failed requests in a row after
+	// which the circuit to a host is opened.
+	DefaultCircuitThreshold = 5
+
+	// DefaultCircuitCooldown is the default time the circuit to a host stays open.
+	DefaultCircuitCooldown = 30 * time.Second
+)
+
+var (
+	// ErrCircuitOpen is returned without sending a request if too many requests to the
+	// same host failed in a row, until the circuit's cooldown has passed.
+	ErrCircuitOpen = errors.New("circuit open after too many failed requests")
+
+	// ErrStatus is returned if the server responds with a non-2xx status code.
+	ErrStatus = errors.New("unexpected status code")
+)
+
+// Client sends the requests to the validator's RPC server and the LCD. Each request
+// is bounded by a timeout and retried with exponential backoff if it fails. After too
+// many failed requests to the same host in a row, the circuit to that host is opened,
+// so that requests fail fast instead of piling up while the host is down.
+type Client struct {
+	// HTTP is the underlying HTTP client. Defaults to http.DefaultClient.
+	HTTP *http.Client
+
+	// Logger is the logger used by the client. Logs are discarded if nil.
+	Logger types.Logger
+
+	// Clock is the clock the backoff and cooldown are measured with. Defaults to the
+	// system clock.
+	Clock types.Clock
+
+	// Gauges are the prometheus metrics updated by the client. Metrics are disabled if
+	// left empty.
+	Gauges types.Gauges
+
+	// Timeout is the timeout of a single request. Defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// Retries is the number of times a failed request is retried.
+	Retries int
+
+	// RetryBackoff is the time waited before the first retry. Defaults to
+	// DefaultRetryBackoff.
+	RetryBackoff time.Duration
+
+	// CircuitThreshold is the number of failed requests in a row after which the
+	// circuit to a host is opened. Circuit breaking is disabled if it is 0.
+	CircuitThreshold int
+
+	// CircuitCooldown is the time the circuit to a host stays open. Defaults to
+	// DefaultCircuitCooldown.
+	CircuitCooldown time.Duration
+
+	mtx      sync.Mutex
+	circuits map[string]*circuit
+}
+
+// circuit keeps track of the failed requests to a single host.
+type circuit struct {
+	failures  int
+	openUntil time.Time
+}
+
+// NewClient creates a new client with the default settings.
+func NewClient(logger types.Logger) *Client {
+	return &Client{
+		Logger:           logger,
+		Timeout:          DefaultTimeout,
+		Retries:          DefaultRetries,
+		RetryBackoff:     DefaultRetryBackoff,
+		CircuitThreshold: DefaultCircuitThreshold,
+		CircuitCooldown:  DefaultCircuitCooldown,
+	}
+}
+
+// logger returns the client's logger.
+func (c *Client) logger() types.Logger {
+	if c.Logger == nil {
+		return types.NewSyncLogger(ioutil.Discard, "", 0)
+	}
+
+	return c.Logger
+}
+
+// clock returns the client's clock.
+func (c *Client) clock() types.Clock {
+	if c.Clock == nil {
+		return types.SystemClock
+	}
+
+	return c.Clock
+}
+
+// settings returns the client's settings with the defaults applied to the durations.
+func (c *Client) settings() (timeout time.Duration, retries int, backoff time.Duration, threshold int, cooldown time.Duration) {
+	timeout, retries, backoff = c.Timeout, c.Retries, c.RetryBackoff
+	threshold, cooldown = c.CircuitThreshold, c.CircuitCooldown
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if retries < 0 {
+		retries = 0
+	}
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultCircuitCooldown
+	}
+
+	return
+}
+
+// allow returns ErrCircuitOpen if the circuit to the given host is open.
+func (c *Client) allow(host string) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if cb, ok := c.circuits[host]; ok && c.clock().Now().Before(cb.openUntil) {
+		return fmt.Errorf("%w to %v", ErrCircuitOpen, host)
+	}
+
+	return nil
+}
+
+// record records the outcome of a request to the given host and opens the circuit
+// to it if too many requests failed in a row. Once the cooldown has passed, a single
+// failed request reopens the circuit.
+func (c *Client) record(host string, err error) {
+	_, _, _, threshold, cooldown := c.settings()
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.circuits == nil {
+		c.circuits = make(map[string]*circuit)
+	}
+	cb, ok := c.circuits[host]
+	if !ok {
+		cb = new(circuit)
+		c.circuits[host] = cb
+	}
+
+	if err == nil {
+		cb.failures = 0
+		cb.openUntil = time.Time{}
+	} else if cb.failures++; threshold > 0 && cb.failures >= threshold {
+		if cb.failures == threshold {
+			c.logger().Warn("Opening the circuit to %v for %v after %v failed requests in a row", host, cooldown, cb.failures)
+		}
+		cb.openUntil = c.clock().Now().Add(cooldown)
+	}
+
+	if c.Gauges.RPCCircuitOpenGauge != nil {
+		open := 0
+		for _, cb := range c.circuits {
+			if threshold > 0 && cb.failures >= threshold {
+				open++
+			}
+		}
+		c.Gauges.RPCCircuitOpenGauge.Set(float64(open))
+	}
+}
+
+// observe updates the metrics for a request to the given endpoint.
+func (c *Client) observe(endpoint string, start time.Time, err error) {
+	result := "success"
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		result = "circuit_open"
+	case err != nil:
+		result = "error"
+	}
+	if c.Gauges.RPCRequestsCounter != nil {
+		c.Gauges.RPCRequestsCounter.WithLabelValues(endpoint, result).Inc()
+	}
+	if c.Gauges.RPCDurationHistogram != nil {
+		c.Gauges.RPCDurationHistogram.WithLabelValues(endpoint).Observe(c.clock().Now().Sub(start).Seconds())
+	}
+}
+
+// GetJSON queries the given URL and unmarshals the JSON response into v using
+// unmarshal. The endpoint names the queried endpoint in the metrics. Failed requests
+// are retried until they either succeed, the retries are used up or ctx is done.
+func (c *Client) GetJSON(ctx context.Context, endpoint, rawURL string, v interface{}, unmarshal func([]byte, interface{}) error) (err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := u.Host
+
+	start := c.clock().Now()
+	defer func() { c.observe(endpoint, start, err) }()
+
+	if err = c.allow(host); err != nil {
+		return err
+	}
+
+	timeout, retries, backoff, _, _ := c.settings()
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = c.get(ctx, rawURL, timeout, v, unmarshal)
+		if err == nil || !retry || ctx.Err() != nil || attempt >= retries {
+			break
+		}
+
+		c.logger().Debug("GET %v failed, retrying in %v: %v", rawURL, backoff, err)
+		select {
+		case <-c.clock().After(backoff):
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		backoff *= 2
+	}
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("request was canceled: %w", ctx.Err())
+	}
+	c.record(host, err)
+
+	return err
+}
+
+// get sends a single GET request to the given URL and unmarshals the JSON response
+// into v using unmarshal. It returns whether the request should be retried if it
+// failed. Client errors with a JSON body are passed on to the caller, as the LCD
+// responds with 404 and a JSON error for unknown validators.
+func (c *Client) get(ctx context.Context, rawURL string, timeout time.Duration, v interface{}, unmarshal func([]byte, interface{}) error) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	c.logger().Debug("GET %v", rawURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return false, err
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	bytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return true, err
+	}
+	switch {
+	case resp.StatusCode >= 500:
+		return true, fmt.Errorf("%w: %v", ErrStatus, resp.Status)
+	case resp.StatusCode >= 400:
+		if err := unmarshal(bytes, v); err != nil {
+			return false, fmt.Errorf("%w: %v", ErrStatus, resp.Status)
+		}
+		return false, nil
+	}
+
+	return true, unmarshal(bytes, v)
+}
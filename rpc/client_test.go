@@ -0,0 +1,121 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// testServer responds with the given status codes in order and with 200 once they
+// are used up, and counts the requests it received.
+func testServer(t *testing.T, requests *int32, codes ...int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(requests, 1))
+		if n <= len(codes) {
+			rw.WriteHeader(codes[n-1])
+			_, _ = rw.Write([]byte(`{"error":"failed"}`))
+			return
+		}
+		_, _ = rw.Write([]byte(`{"ok":true}`))
+	}))
+}
+
+func testClient() *Client {
+	c := NewClient(types.NewSyncLogger(ioutil.Discard, "", 0))
+	c.RetryBackoff = time.Millisecond
+	return c
+}
+
+func TestClient_GetJSON_Retry(t *testing.T) {
+	var requests int32
+	srv := testServer(t, &requests, http.StatusInternalServerError, http.StatusBadGateway)
+	defer srv.Close()
+
+	var v struct{ OK bool }
+	err := testClient().GetJSON(context.Background(), "test", srv.URL, &v, json.Unmarshal)
+	assert.NoError(t, err)
+	assert.True(t, v.OK)
+	assert.Equal(t, int32(3), requests)
+}
+
+func TestClient_GetJSON_RetriesUsedUp(t *testing.T) {
+	var requests int32
+	srv := testServer(t, &requests, 500, 500, 500, 500)
+	defer srv.Close()
+
+	var v struct{ OK bool }
+	err := testClient().GetJSON(context.Background(), "test", srv.URL, &v, json.Unmarshal)
+	assert.ErrorIs(t, err, ErrStatus)
+	assert.Equal(t, int32(DefaultRetries+1), requests)
+}
+
+func TestClient_GetJSON_ClientError(t *testing.T) {
+	var requests int32
+	srv := testServer(t, &requests, http.StatusNotFound)
+	defer srv.Close()
+
+	// Client errors are not retried, and their JSON body is passed on to the caller.
+	var v struct{ Error string }
+	err := testClient().GetJSON(context.Background(), "test", srv.URL, &v, json.Unmarshal)
+	assert.NoError(t, err)
+	assert.Equal(t, "failed", v.Error)
+	assert.Equal(t, int32(1), requests)
+}
+
+func TestClient_GetJSON_Canceled(t *testing.T) {
+	var requests int32
+	srv := testServer(t, &requests, 500, 500, 500)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var v struct{ OK bool }
+	err := testClient().GetJSON(ctx, "test", srv.URL, &v, json.Unmarshal)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestClient_GetJSON_CircuitBreaker(t *testing.T) {
+	var requests int32
+	srv := testServer(t, &requests, 500, 500, 500)
+	defer srv.Close()
+
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	c := testClient()
+	c.Clock = clock
+	c.Retries = 0
+	c.CircuitThreshold = 3
+	c.CircuitCooldown = time.Minute
+	c.Gauges.RPCCircuitOpenGauge = prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_circuits_open"})
+	c.Gauges.RPCRequestsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_requests"}, []string{"endpoint", "result"})
+
+	var v struct{ OK bool }
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, c.GetJSON(context.Background(), "test", srv.URL, &v, json.Unmarshal), ErrStatus)
+	}
+	assert.Equal(t, 1.0, prom_testutil.ToFloat64(c.Gauges.RPCCircuitOpenGauge))
+
+	// The circuit is open, so requests fail without reaching the server.
+	assert.ErrorIs(t, c.GetJSON(context.Background(), "test", srv.URL, &v, json.Unmarshal), ErrCircuitOpen)
+	assert.Equal(t, int32(3), requests)
+
+	// Once the cooldown has passed, requests are sent again and close the circuit
+	// on success.
+	clock.Advance(time.Minute)
+	assert.NoError(t, c.GetJSON(context.Background(), "test", srv.URL, &v, json.Unmarshal))
+	assert.Equal(t, 0.0, prom_testutil.ToFloat64(c.Gauges.RPCCircuitOpenGauge))
+
+	assert.Equal(t, 3.0, prom_testutil.ToFloat64(c.Gauges.RPCRequestsCounter.WithLabelValues("test", "error")))
+	assert.Equal(t, 1.0, prom_testutil.ToFloat64(c.Gauges.RPCRequestsCounter.WithLabelValues("test", "circuit_open")))
+	assert.Equal(t, 1.0, prom_testutil.ToFloat64(c.Gauges.RPCRequestsCounter.WithLabelValues("test", "success")))
+}
@@ -0,0 +1,31 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+// QueryCommit gets the signed header at the given height, or the latest height if it
+// is 0, from the /commit endpoint of the RPC server at rpcladdr.
+func (c *Client) QueryCommit(ctx context.Context, rpcladdr string, height int64) (*tm_types.SignedHeader, error) {
+	path := "/commit"
+	if height > 0 {
+		path += fmt.Sprintf("?height=%v", height)
+	}
+
+	var res struct {
+		Result *tm_coretypes.ResultCommit `json:"result"`
+	}
+	if err := c.GetJSON(ctx, "commit", rpcURL(rpcladdr, path), &res, tm_json.Unmarshal); err != nil {
+		return nil, err
+	}
+	if res.Result == nil {
+		return nil, fmt.Errorf("%w for height %v", ErrNoCommitResult, height)
+	}
+
+	return &res.Result.SignedHeader, nil
+}
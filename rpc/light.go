@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_lightprovider "github.com/tendermint/tendermint/light/provider"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+var (
+	// ErrNoCommitResult is returned if the response of the /commit endpoint doesn't
+	// contain a result.
+	ErrNoCommitResult = errors.New("no result in /commit response")
+)
+
+// LightProvider provides the light blocks of an RPC server to Tendermint's light
+// client. Unlike Tendermint's own HTTP provider, it queries the RPC server via a
+// Client, so that the requests are canceled along with their context and are subject
+// to the client's timeout, retries and circuit breaking.
+type LightProvider struct {
+	chainID  string
+	rpcladdr string
+	client   *Client
+}
+
+// LightProvider must implement Tendermint's light client Provider interface.
+var _ tm_lightprovider.Provider = new(LightProvider)
+
+// NewLightProvider creates a new light block provider for the given chain that
+// queries the RPC server at rpcladdr with the given client.
+func NewLightProvider(chainID, rpcladdr string, client *Client) *LightProvider {
+	return &LightProvider{
+		chainID:  chainID,
+		rpcladdr: rpcladdr,
+		client:   client,
+	}
+}
+
+// String returns the provider's RPC server address.
+func (p *LightProvider) String() string {
+	return p.rpcladdr
+}
+
+// ChainID returns the chain ID of the provider's chain.
+// Implements the Provider interface.
+func (p *LightProvider) ChainID() string {
+	return p.chainID
+}
+
+// LightBlock queries the signed header and validator set at the given height, or the
+// latest height if it is 0, from the /commit and /validators endpoints.
+// Implements the Provider interface.
+func (p *LightProvider) LightBlock(ctx context.Context, height int64) (*tm_types.LightBlock, error) {
+	if height < 0 {
+		return nil, tm_lightprovider.ErrBadLightBlock{Reason: fmt.Errorf("expected height >= 0, got height %v", height)}
+	}
+
+	sh, err := p.signedHeader(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	vs, err := p.validatorSet(ctx, sh.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	lb := &tm_types.LightBlock{
+		SignedHeader: sh,
+		ValidatorSet: vs,
+	}
+	if err := lb.ValidateBasic(p.chainID); err != nil {
+		return nil, tm_lightprovider.ErrBadLightBlock{Reason: err}
+	}
+
+	return lb, nil
+}
+
+// signedHeader queries the signed header at the given height from the /commit
+// endpoint.
+func (p *LightProvider) signedHeader(ctx context.Context, height int64) (*tm_types.SignedHeader, error) {
+	return p.client.QueryCommit(ctx, p.rpcladdr, height)
+}
+
+// validatorSet queries the validator set at the given height from the /validators
+// endpoint.
+func (p *LightProvider) validatorSet(ctx context.Context, height int64) (*tm_types.ValidatorSet, error) {
+	vals, err := p.client.QueryValidatorSet(ctx, p.rpcladdr, height)
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) == 0 {
+		return nil, tm_lightprovider.ErrBadLightBlock{Reason: fmt.Errorf("validator set at height %v is empty", height)}
+	}
+
+	vs, err := tm_types.ValidatorSetFromExistingValidators(vals)
+	if err != nil {
+		return nil, tm_lightprovider.ErrBadLightBlock{Reason: err}
+	}
+
+	return vs, nil
+}
+
+// ReportEvidence reports the given evidence of misbehavior to the /broadcast_evidence
+// endpoint.
+// Implements the Provider interface.
+func (p *LightProvider) ReportEvidence(ctx context.Context, ev tm_types.Evidence) error {
+	bytes, err := tm_json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	var res struct {
+		Result *tm_coretypes.ResultBroadcastEvidence `json:"result"`
+	}
+	path := "/broadcast_evidence?evidence=" + url.QueryEscape(string(bytes))
+
+	return p.client.GetJSON(ctx, "broadcast_evidence", rpcURL(p.rpcladdr, path), &res, tm_json.Unmarshal)
+}
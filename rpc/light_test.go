@@ -0,0 +1,122 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_lightprovider "github.com/tendermint/tendermint/light/provider"
+	tm_typesproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+// testLightChain creates a chain of the given number of blocks that are committed by
+// a single validator.
+func testLightChain(t *testing.T, n int64) ([]*tm_types.SignedHeader, *tm_types.ValidatorSet) {
+	t.Helper()
+	privVal := tm_types.NewMockPV()
+	pub, err := privVal.GetPubKey()
+	assert.NoError(t, err)
+	vals := tm_types.NewValidatorSet([]*tm_types.Validator{tm_types.NewValidator(pub, 10)})
+
+	var headers []*tm_types.SignedHeader
+	lastCommit := &tm_types.Commit{}
+	for h := int64(1); h <= n; h++ {
+		block := tm_types.MakeBlock(h, nil, lastCommit, nil)
+		block.ChainID = "testchain"
+		block.Time = time.Now().Add(time.Duration(h-n) * time.Second)
+		block.ValidatorsHash = vals.Hash()
+		block.NextValidatorsHash = vals.Hash()
+		block.ProposerAddress = pub.Address()
+		blockID := tm_types.BlockID{Hash: block.Hash(), PartSetHeader: block.MakePartSet(tm_types.BlockPartSizeBytes).Header()}
+
+		voteSet := tm_types.NewVoteSet("testchain", h, 0, tm_typesproto.PrecommitType, vals)
+		commit, err := tm_types.MakeCommit(blockID, h, 0, voteSet, []tm_types.PrivValidator{privVal}, block.Time)
+		assert.NoError(t, err)
+
+		headers = append(headers, &tm_types.SignedHeader{Header: &block.Header, Commit: commit})
+		lastCommit = commit
+	}
+
+	return headers, vals
+}
+
+// testLightServer serves the /commit and /validators endpoints for the given chain.
+func testLightServer(t *testing.T, headers []*tm_types.SignedHeader, vals *tm_types.ValidatorSet) *httptest.Server {
+	t.Helper()
+	respond := func(rw http.ResponseWriter, result interface{}) {
+		bytes, err := tm_json.Marshal(result)
+		assert.NoError(t, err)
+		fmt.Fprintf(rw, `{"jsonrpc":"2.0","id":-1,"result":%s}`, bytes)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/commit", func(rw http.ResponseWriter, r *http.Request) {
+		height, _ := strconv.ParseInt(r.URL.Query().Get("height"), 10, 64)
+		if height == 0 {
+			height = int64(len(headers))
+		}
+		if height > int64(len(headers)) {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		respond(rw, &tm_coretypes.ResultCommit{SignedHeader: *headers[height-1], CanonicalCommit: true})
+	})
+	mux.HandleFunc("/validators", func(rw http.ResponseWriter, r *http.Request) {
+		respond(rw, &tm_coretypes.ResultValidators{
+			Validators: vals.Validators,
+			Count:      vals.Size(),
+			Total:      vals.Size(),
+		})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestLightProvider_LightBlock(t *testing.T) {
+	headers, vals := testLightChain(t, 3)
+	server := testLightServer(t, headers, vals)
+	defer server.Close()
+
+	p := NewLightProvider("testchain", "tcp://"+strings.TrimPrefix(server.URL, "http://"), &Client{})
+	assert.Equal(t, "testchain", p.ChainID())
+
+	lb, err := p.LightBlock(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, headers[1].Hash(), lb.Hash())
+	assert.Equal(t, vals.Hash(), lb.ValidatorSet.Hash())
+
+	// Height 0 is the latest height.
+	lb, err = p.LightBlock(context.Background(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), lb.Height)
+
+	_, err = p.LightBlock(context.Background(), -1)
+	assert.True(t, errors.As(err, &tm_lightprovider.ErrBadLightBlock{}))
+
+	_, err = p.LightBlock(context.Background(), 4)
+	assert.True(t, errors.Is(err, ErrStatus))
+
+	// The light block is for a different chain.
+	p = NewLightProvider("otherchain", "tcp://"+strings.TrimPrefix(server.URL, "http://"), &Client{})
+	_, err = p.LightBlock(context.Background(), 2)
+	assert.True(t, errors.As(err, &tm_lightprovider.ErrBadLightBlock{}))
+}
+
+func TestLightProvider_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := NewLightProvider("testchain", "tcp://127.0.0.1:1", NewClient(nil))
+	_, err := p.LightBlock(ctx, 1)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
@@ -0,0 +1,217 @@
+package rpc
+
+import (
+	"context"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+const (
+	// DefaultHealthCheckInterval is the default interval in which the endpoints of a
+	// pool are health checked.
+	DefaultHealthCheckInterval = 10 * time.Second
+
+	// DefaultMaxLag is the default number of blocks an endpoint may lag behind the
+	// highest one before it is demoted.
+	DefaultMaxLag = 2
+)
+
+// EndpointStatus is the result of the last health check of an RPC endpoint.
+type EndpointStatus struct {
+	// Address is the endpoint's address, e.g. tcp://127.0.0.1:26657.
+	Address string `json:"address"`
+
+	// Height is the latest block height known to the endpoint.
+	Height int64 `json:"height"`
+
+	// Latency is the time the endpoint took to respond to the health check.
+	Latency time.Duration `json:"latency"`
+
+	// Healthy is true if the endpoint responded and doesn't lag behind.
+	Healthy bool `json:"healthy"`
+
+	// Error is the error of the last health check, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Pool health checks a number of RPC endpoints of the same chain, e.g. the
+// validator's RPC server and the ones of its sentry nodes, and routes queries to the
+// best of them. An endpoint is healthy if it responds and its latest height is no
+// more than MaxLag blocks behind the highest one. Of the healthy endpoints, the one
+// with the lowest latency is the best one. Until the first health check, and while
+// no endpoint is healthy, the first endpoint is used.
+type Pool struct {
+	// Client sends the health checks. Health checks shouldn't be retried, so that a
+	// slow endpoint is noticed instead of hidden.
+	Client *Client
+
+	// Logger is the logger used by the pool. Logs are discarded if nil.
+	Logger types.Logger
+
+	// Clock is the clock the latencies and the interval are measured with. Defaults
+	// to the system clock.
+	Clock types.Clock
+
+	// Interval is the interval in which the endpoints are health checked. Defaults to
+	// DefaultHealthCheckInterval.
+	Interval time.Duration
+
+	// MaxLag is the number of blocks an endpoint may lag behind the highest one
+	// before it is demoted.
+	MaxLag int64
+
+	// MaxParallel is the maximum number of endpoints health checked at the same time.
+	// All endpoints are checked at once if it is 0.
+	MaxParallel int
+
+	mtx       sync.RWMutex
+	endpoints []EndpointStatus
+	best      int
+}
+
+// NewPool creates a new pool of the given endpoints, which are health checked with
+// the given client. At least one endpoint must be given. The first endpoint is used
+// until the first health check.
+func NewPool(client *Client, addrs ...string) *Pool {
+	endpoints := make([]EndpointStatus, len(addrs))
+	for i, addr := range addrs {
+		endpoints[i] = EndpointStatus{Address: addr, Healthy: true}
+	}
+
+	return &Pool{
+		Client:    client,
+		Logger:    client.Logger,
+		Interval:  DefaultHealthCheckInterval,
+		MaxLag:    DefaultMaxLag,
+		endpoints: endpoints,
+	}
+}
+
+// logger returns the pool's logger.
+func (p *Pool) logger() types.Logger {
+	if p.Logger == nil {
+		return types.NewSyncLogger(ioutil.Discard, "", 0)
+	}
+
+	return p.Logger
+}
+
+// clock returns the pool's clock.
+func (p *Pool) clock() types.Clock {
+	if p.Clock == nil {
+		return types.SystemClock
+	}
+
+	return p.Clock
+}
+
+// Best returns the address of the best endpoint.
+func (p *Pool) Best() string {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	return p.endpoints[p.best].Address
+}
+
+// Endpoints returns the results of the last health checks.
+func (p *Pool) Endpoints() []EndpointStatus {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+
+	return append([]EndpointStatus(nil), p.endpoints...)
+}
+
+// Check health checks all endpoints concurrently, at most MaxParallel at a time, and
+// selects the best one.
+func (p *Pool) Check(ctx context.Context) {
+	p.mtx.RLock()
+	results := append([]EndpointStatus(nil), p.endpoints...)
+	p.mtx.RUnlock()
+
+	parallel := p.MaxParallel
+	if parallel <= 0 || parallel > len(results) {
+		parallel = len(results)
+	}
+	sem := make(chan struct{}, parallel)
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(res *EndpointStatus) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			start := p.clock().Now()
+			height, err := p.Client.QueryLatestHeight(ctx, res.Address)
+			res.Latency = p.clock().Now().Sub(start)
+			res.Height, res.Error = height, ""
+			if err != nil {
+				res.Height, res.Error = 0, err.Error()
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	var maxHeight int64
+	for _, res := range results {
+		if res.Error == "" && res.Height > maxHeight {
+			maxHeight = res.Height
+		}
+	}
+	best := -1
+	for i := range results {
+		res := &results[i]
+		res.Healthy = res.Error == "" && res.Height >= maxHeight-p.MaxLag
+		if res.Healthy && (best < 0 || res.Latency < results[best].Latency) {
+			best = i
+		}
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for i, res := range results {
+		if !res.Healthy && p.endpoints[i].Healthy {
+			if res.Error != "" {
+				p.logger().Warn("Demoting RPC endpoint %v: %v", res.Address, res.Error)
+			} else {
+				p.logger().Warn("Demoting RPC endpoint %v, which is %v blocks behind", res.Address, maxHeight-res.Height)
+			}
+		}
+	}
+	wasHealthy := p.endpoints[p.best].Healthy
+	p.endpoints = results
+	if best < 0 {
+		if wasHealthy {
+			p.logger().Warn("No healthy RPC endpoint left, keep querying %v", results[p.best].Address)
+		}
+		return
+	}
+	if best != p.best {
+		p.logger().Info("Sending RPC queries to %v (height %v, latency %v)", results[best].Address, results[best].Height, results[best].Latency)
+		p.best = best
+	}
+}
+
+// Run health checks the endpoints in the configured interval until ctx is done.
+func (p *Pool) Run(ctx context.Context) {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.clock().After(interval):
+		}
+		p.Check(ctx)
+	}
+}
@@ -0,0 +1,153 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// statusServer starts an RPC server whose /status endpoint responds with the height
+// returned by height after the given delay. It responds with an error if height
+// returns 0.
+func statusServer(t *testing.T, delay time.Duration, height func() int64) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		h := height()
+		if h == 0 {
+			http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprintf(rw, `{"jsonrpc":"2.0","id":-1,"result":{"node_info":{"version":"0.34.8"},"sync_info":{"latest_block_height":"%v"}}}`, h)
+	}))
+	t.Cleanup(srv.Close)
+
+	return "tcp://" + srv.Listener.Addr().String()
+}
+
+// fixedHeight returns a height function that always returns the given height.
+func fixedHeight(height int64) func() int64 {
+	return func() int64 { return height }
+}
+
+func TestPool_Check(t *testing.T) {
+	slow := statusServer(t, 50*time.Millisecond, fixedHeight(100))
+	fast := statusServer(t, 0, fixedHeight(100))
+	lagging := statusServer(t, 0, fixedHeight(90))
+	down := statusServer(t, 0, fixedHeight(0))
+
+	p := NewPool(&Client{}, slow, fast, lagging, down)
+	assert.Equal(t, slow, p.Best())
+
+	// The fastest endpoint that doesn't lag behind is the best one.
+	p.Check(context.Background())
+	assert.Equal(t, fast, p.Best())
+
+	endpoints := p.Endpoints()
+	assert.Len(t, endpoints, 4)
+	assert.True(t, endpoints[0].Healthy)
+	assert.True(t, endpoints[1].Healthy)
+	assert.Equal(t, int64(100), endpoints[1].Height)
+	assert.Greater(t, endpoints[0].Latency, endpoints[1].Latency)
+	assert.False(t, endpoints[2].Healthy)
+	assert.Equal(t, int64(90), endpoints[2].Height)
+	assert.False(t, endpoints[3].Healthy)
+	assert.NotEmpty(t, endpoints[3].Error)
+}
+
+func TestPool_CheckMaxLag(t *testing.T) {
+	primary := statusServer(t, 0, fixedHeight(98))
+	sentry := statusServer(t, 0, fixedHeight(100))
+
+	p := NewPool(&Client{}, primary, sentry)
+	p.Check(context.Background())
+	assert.True(t, p.Endpoints()[0].Healthy)
+
+	p.MaxLag = 1
+	p.Check(context.Background())
+	assert.False(t, p.Endpoints()[0].Healthy)
+	assert.Equal(t, sentry, p.Best())
+}
+
+func TestPool_CheckNoneHealthy(t *testing.T) {
+	var height int64 = 100
+	primary := statusServer(t, 0, fixedHeight(0))
+	sentry := statusServer(t, 0, func() int64 { return atomic.LoadInt64(&height) })
+
+	p := NewPool(&Client{}, primary, sentry)
+	p.Check(context.Background())
+	assert.Equal(t, sentry, p.Best())
+
+	// The last best endpoint is kept if no endpoint is healthy.
+	atomic.StoreInt64(&height, 0)
+	p.Check(context.Background())
+	assert.Equal(t, sentry, p.Best())
+	for _, e := range p.Endpoints() {
+		assert.False(t, e.Healthy)
+	}
+}
+
+func TestPool_CheckMaxParallel(t *testing.T) {
+	var inFlight, maxInFlight int64
+	height := func() int64 {
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			max := atomic.LoadInt64(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt64(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return 100
+	}
+	addrs := make([]string, 4)
+	for i := range addrs {
+		addrs[i] = statusServer(t, 0, height)
+	}
+
+	p := NewPool(&Client{}, addrs...)
+	p.MaxParallel = 2
+	p.Check(context.Background())
+	assert.LessOrEqual(t, atomic.LoadInt64(&maxInFlight), int64(2))
+	for _, e := range p.Endpoints() {
+		assert.True(t, e.Healthy)
+	}
+}
+
+func TestPool_Run(t *testing.T) {
+	var height int64 = 100
+	primary := statusServer(t, 0, func() int64 { return atomic.LoadInt64(&height) })
+	sentry := statusServer(t, 0, fixedHeight(100))
+
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	p := NewPool(&Client{}, primary, sentry)
+	p.Clock = clock
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(ctx)
+	}()
+
+	// The primary endpoint falls behind and is demoted on the next health check.
+	atomic.StoreInt64(&height, 50)
+	clock.BlockUntil(1)
+	clock.Advance(p.Interval)
+	assert.Eventually(t, func() bool { return p.Best() == sentry }, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after ctx was canceled")
+	}
+}
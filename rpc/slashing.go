@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+var (
+	// ErrNoSigningInfo is returned if the slashing module doesn't know the validator's
+	// consensus address.
+	ErrNoSigningInfo = errors.New("no signing info in slashing module response")
+
+	// ErrNoValidator is returned if the staking module doesn't know the validator's
+	// operator address.
+	ErrNoValidator = errors.New("no validator in staking module response")
+)
+
+// SigningInfo defines the validator's signing info as kept by the Cosmos SDK's
+// slashing module.
+type SigningInfo struct {
+	Address             string    `json:"address"`
+	StartHeight         int64     `json:"start_height,string"`
+	IndexOffset         int64     `json:"index_offset,string"`
+	JailedUntil         time.Time `json:"jailed_until"`
+	Tombstoned          bool      `json:"tombstoned"`
+	MissedBlocksCounter int64     `json:"missed_blocks_counter,string"`
+}
+
+// SigningInfoResult defines the response structure for the slashing module's
+// /cosmos/slashing/v1beta1/signing_infos/{cons_address} endpoint.
+type SigningInfoResult struct {
+	ValSigningInfo *SigningInfo `json:"val_signing_info"`
+}
+
+// Validator defines the parts of the validator as kept by the Cosmos SDK's staking
+// module that SignCTRL uses.
+type Validator struct {
+	OperatorAddress string `json:"operator_address"`
+	Jailed          bool   `json:"jailed"`
+	Status          string `json:"status"`
+}
+
+// ValidatorResult defines the response structure for the staking module's
+// /cosmos/staking/v1beta1/validators/{validator_addr} endpoint.
+type ValidatorResult struct {
+	Validator *Validator `json:"validator"`
+}
+
+// lcdURL returns the URL for the given path on the LCD at lcdladdr.
+func lcdURL(lcdladdr string, path string) string {
+	// Cut the protocol from lcdladdr.
+	lcdladdrHostPort := regexp.MustCompile(`(tcp|unix)://`).ReplaceAllString(lcdladdr, "")
+	return fmt.Sprintf("http://%v%v", lcdladdrHostPort, path)
+}
+
+// QuerySigningInfo gets the signing info of the validator with the given consensus
+// address from the slashing module.
+func QuerySigningInfo(ctx context.Context, lcdladdr string, valcons string, logger types.Logger) (*SigningInfo, error) {
+	return NewClient(logger).QuerySigningInfo(ctx, lcdladdr, valcons)
+}
+
+// QuerySigningInfo gets the signing info of the validator with the given consensus
+// address from the slashing module.
+func (c *Client) QuerySigningInfo(ctx context.Context, lcdladdr string, valcons string) (*SigningInfo, error) {
+	var result SigningInfoResult
+	if err := c.GetJSON(ctx, "signing_info", lcdURL(lcdladdr, "/cosmos/slashing/v1beta1/signing_infos/"+valcons), &result, json.Unmarshal); err != nil {
+		return nil, err
+	}
+	if result.ValSigningInfo == nil {
+		return nil, fmt.Errorf("%w for %v", ErrNoSigningInfo, valcons)
+	}
+
+	return result.ValSigningInfo, nil
+}
+
+// QueryValidator gets the validator with the given operator address from the staking
+// module.
+func QueryValidator(ctx context.Context, lcdladdr string, valoper string, logger types.Logger) (*Validator, error) {
+	return NewClient(logger).QueryValidator(ctx, lcdladdr, valoper)
+}
+
+// QueryValidator gets the validator with the given operator address from the staking
+// module.
+func (c *Client) QueryValidator(ctx context.Context, lcdladdr string, valoper string) (*Validator, error) {
+	var result ValidatorResult
+	if err := c.GetJSON(ctx, "validator", lcdURL(lcdladdr, "/cosmos/staking/v1beta1/validators/"+valoper), &result, json.Unmarshal); err != nil {
+		return nil, err
+	}
+	if result.Validator == nil {
+		return nil, fmt.Errorf("%w for %v", ErrNoValidator, valoper)
+	}
+
+	return result.Validator, nil
+}
@@ -0,0 +1,66 @@
+package rpc
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testLCD(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cosmos/slashing/v1beta1/signing_infos/cosmosvalcons1abc", func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = rw.Write([]byte(`{"val_signing_info":{"address":"cosmosvalcons1abc","start_height":"10","index_offset":"42","jailed_until":"2021-03-04T05:06:07Z","tombstoned":true,"missed_blocks_counter":"7"}}`))
+	})
+	mux.HandleFunc("/cosmos/staking/v1beta1/validators/cosmosvaloper1abc", func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = rw.Write([]byte(`{"validator":{"operator_address":"cosmosvaloper1abc","jailed":true,"status":"BOND_STATUS_UNBONDING"}}`))
+	})
+	mux.HandleFunc("/", func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+		_, _ = rw.Write([]byte(`{"code":5,"message":"not found"}`))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestQuerySigningInfo(t *testing.T) {
+	srv := testLCD(t)
+	defer srv.Close()
+	addr := "tcp://" + srv.Listener.Addr().String()
+	logger := types.NewSyncLogger(ioutil.Discard, "", 0)
+
+	info, err := QuerySigningInfo(context.Background(), addr, "cosmosvalcons1abc", logger)
+	assert.NoError(t, err)
+	assert.Equal(t, &SigningInfo{
+		Address:             "cosmosvalcons1abc",
+		StartHeight:         10,
+		IndexOffset:         42,
+		JailedUntil:         time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		Tombstoned:          true,
+		MissedBlocksCounter: 7,
+	}, info)
+
+	_, err = QuerySigningInfo(context.Background(), addr, "cosmosvalcons1unknown", logger)
+	assert.ErrorIs(t, err, ErrNoSigningInfo)
+}
+
+func TestQueryValidator(t *testing.T) {
+	srv := testLCD(t)
+	defer srv.Close()
+	addr := "tcp://" + srv.Listener.Addr().String()
+	logger := types.NewSyncLogger(ioutil.Discard, "", 0)
+
+	val, err := QueryValidator(context.Background(), addr, "cosmosvaloper1abc", logger)
+	assert.NoError(t, err)
+	assert.True(t, val.Jailed)
+	assert.Equal(t, "BOND_STATUS_UNBONDING", val.Status)
+
+	_, err = QueryValidator(context.Background(), addr, "cosmosvaloper1unknown", logger)
+	assert.ErrorIs(t, err, ErrNoValidator)
+}
@@ -0,0 +1,69 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+var (
+	// ErrNoNodeVersion is returned if the response of the /status endpoint doesn't
+	// contain the node's version.
+	ErrNoNodeVersion = errors.New("no node version in /status response")
+
+	// ErrNoLatestHeight is returned if the response of the /status endpoint doesn't
+	// contain the latest block height.
+	ErrNoLatestHeight = errors.New("no latest block height in /status response")
+)
+
+// StatusResult defines the parts of the JSONRPC 2.0 response structure for the /status
+// endpoint that SignCTRL uses. It is shared by Tendermint and CometBFT.
+type StatusResult struct {
+	Result struct {
+		NodeInfo struct {
+			Version string `json:"version"`
+		} `json:"node_info"`
+		SyncInfo struct {
+			LatestBlockHeight string `json:"latest_block_height"`
+		} `json:"sync_info"`
+	} `json:"result"`
+}
+
+// QueryNodeVersion gets the version of the validator's node software, e.g. "0.34.8".
+func QueryNodeVersion(ctx context.Context, rpcladdr string, logger types.Logger) (string, error) {
+	return NewClient(logger).QueryNodeVersion(ctx, rpcladdr)
+}
+
+// QueryNodeVersion gets the version of the validator's node software, e.g. "0.34.8".
+func (c *Client) QueryNodeVersion(ctx context.Context, rpcladdr string) (string, error) {
+	var status StatusResult
+	if err := c.GetJSON(ctx, "status", rpcURL(rpcladdr, "/status"), &status, json.Unmarshal); err != nil {
+		return "", err
+	}
+	if status.Result.NodeInfo.Version == "" {
+		return "", ErrNoNodeVersion
+	}
+
+	return status.Result.NodeInfo.Version, nil
+}
+
+// QueryLatestHeight gets the height of the latest block known to the validator.
+func QueryLatestHeight(ctx context.Context, rpcladdr string, logger types.Logger) (int64, error) {
+	return NewClient(logger).QueryLatestHeight(ctx, rpcladdr)
+}
+
+// QueryLatestHeight gets the height of the latest block known to the validator.
+func (c *Client) QueryLatestHeight(ctx context.Context, rpcladdr string) (int64, error) {
+	var status StatusResult
+	if err := c.GetJSON(ctx, "status", rpcURL(rpcladdr, "/status"), &status, json.Unmarshal); err != nil {
+		return 0, err
+	}
+	if status.Result.SyncInfo.LatestBlockHeight == "" {
+		return 0, ErrNoLatestHeight
+	}
+
+	return strconv.ParseInt(status.Result.SyncInfo.LatestBlockHeight, 10, 64)
+}
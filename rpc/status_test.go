@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryNodeVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/status", r.URL.Path)
+		_, _ = rw.Write([]byte(`{"jsonrpc":"2.0","id":-1,"result":{"node_info":{"version":"0.38.2"},"sync_info":{}}}`))
+	}))
+	defer srv.Close()
+
+	version, err := QueryNodeVersion(context.Background(), "tcp://"+srv.Listener.Addr().String(), types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "0.38.2", version)
+}
+
+func TestQueryNodeVersion_NoVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = rw.Write([]byte(`{"jsonrpc":"2.0","id":-1,"error":{"code":-32603}}`))
+	}))
+	defer srv.Close()
+
+	_, err := QueryNodeVersion(context.Background(), "tcp://"+srv.Listener.Addr().String(), types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.ErrorIs(t, err, ErrNoNodeVersion)
+}
+
+func TestQueryLatestHeight(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/status", r.URL.Path)
+		_, _ = rw.Write([]byte(`{"jsonrpc":"2.0","id":-1,"result":{"node_info":{"version":"0.38.2"},"sync_info":{"latest_block_height":"1234"}}}`))
+	}))
+	defer srv.Close()
+
+	height, err := QueryLatestHeight(context.Background(), "tcp://"+srv.Listener.Addr().String(), types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1234), height)
+}
+
+func TestQueryLatestHeight_NoHeight(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = rw.Write([]byte(`{"jsonrpc":"2.0","id":-1,"error":{"code":-32603}}`))
+	}))
+	defer srv.Close()
+
+	_, err := QueryLatestHeight(context.Background(), "tcp://"+srv.Listener.Addr().String(), types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.ErrorIs(t, err, ErrNoLatestHeight)
+}
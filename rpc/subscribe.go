@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/gorilla/websocket"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_rpctypes "github.com/tendermint/tendermint/rpc/jsonrpc/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+// newBlockQuery is the query for the events that are emitted whenever the validator
+// commits a block.
+const newBlockQuery = "tm.event='NewBlock'"
+
+// SubscribeBlocks subscribes to NewBlock events via the websocket endpoint of the
+// validator's RPC server and sends each new block to blockCh. It blocks until either
+// ctx is done or the subscription fails, so the caller can fall back to polling and
+// resubscribe later.
+func SubscribeBlocks(ctx context.Context, rpcladdr string, blockCh chan<- *tm_coretypes.ResultBlock, logger types.Logger) error {
+	// Cut the protocol from rpcladdr.
+	rpcladdrHostPort := regexp.MustCompile(`(tcp|unix)://`).ReplaceAllString(rpcladdr, "")
+	url := fmt.Sprintf("ws://%v/websocket", rpcladdrHostPort)
+
+	logger.Debug("Subscribing to %v via %v", newBlockQuery, url)
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Close the connection once ctx is done in order to unblock pending reads.
+	done := make(chan struct{})
+	defer close(done)
+	goroutines.Go("subscription", func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	})
+
+	req, err := tm_rpctypes.MapToRequest(tm_rpctypes.JSONRPCStringID("signctrl"), "subscribe", map[string]interface{}{"query": newBlockQuery})
+	if err != nil {
+		return err
+	}
+	if err := conn.WriteJSON(req); err != nil {
+		return err
+	}
+
+	for {
+		var resp tm_rpctypes.RPCResponse
+		if err := conn.ReadJSON(&resp); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if resp.Error != nil {
+			return resp.Error
+		}
+
+		// The response to the subscription request itself has an empty result.
+		var event tm_coretypes.ResultEvent
+		if err := tm_json.Unmarshal(resp.Result, &event); err != nil {
+			return err
+		}
+		if event.Data == nil {
+			continue
+		}
+		data, ok := event.Data.(tm_types.EventDataNewBlock)
+		if !ok || data.Block == nil {
+			return errors.New("unexpected event data in NewBlock event")
+		}
+
+		select {
+		case blockCh <- &tm_coretypes.ResultBlock{
+			BlockID: tm_types.BlockID{Hash: data.Block.Hash()},
+			Block:   data.Block,
+		}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
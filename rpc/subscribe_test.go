@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_rpctypes "github.com/tendermint/tendermint/rpc/jsonrpc/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+func TestSubscribeBlocks(t *testing.T) {
+	block := testBlockResult(t).Result.Block
+	block.Height = 5
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/websocket", r.URL.Path)
+		conn, err := new(websocket.Upgrader).Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		var req tm_rpctypes.RPCRequest
+		assert.NoError(t, conn.ReadJSON(&req))
+		assert.Equal(t, "subscribe", req.Method)
+
+		// Confirm the subscription, then send a new block.
+		assert.NoError(t, conn.WriteJSON(tm_rpctypes.NewRPCSuccessResponse(req.ID, &tm_coretypes.ResultEvent{})))
+		event, err := tm_json.Marshal(&tm_coretypes.ResultEvent{
+			Query: newBlockQuery,
+			Data:  tm_types.EventDataNewBlock{Block: block},
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, conn.WriteJSON(tm_rpctypes.RPCResponse{JSONRPC: "2.0", ID: req.ID, Result: event}))
+
+		// Wait for the client to close the connection.
+		conn.ReadMessage()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	blockCh := make(chan *tm_coretypes.ResultBlock)
+	errCh := make(chan error, 1)
+	go func() {
+		addr := strings.Replace(srv.URL, "http://", "tcp://", 1)
+		errCh <- SubscribeBlocks(ctx, addr, blockCh, types.NewSyncLogger(ioutil.Discard, "", 0))
+	}()
+
+	rb := <-blockCh
+	assert.Equal(t, int64(5), rb.Block.Height)
+	assert.Equal(t, block.LastCommit.Signatures, rb.Block.LastCommit.Signatures)
+
+	cancel()
+	assert.ErrorIs(t, <-errCh, context.Canceled)
+}
+
+func TestSubscribeBlocks_NoServer(t *testing.T) {
+	port, _ := getFreePort(t)
+	err := SubscribeBlocks(context.Background(), "tcp://127.0.0.1:"+strconv.Itoa(port), make(chan *tm_coretypes.ResultBlock), types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.Error(t, err)
+}
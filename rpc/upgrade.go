@@ -0,0 +1,38 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+// Plan defines the parts of an upgrade plan as kept by the Cosmos SDK's upgrade module
+// that SignCTRL uses.
+type Plan struct {
+	Name   string `json:"name"`
+	Height int64  `json:"height,string"`
+}
+
+// CurrentPlanResult defines the response structure for the upgrade module's
+// /cosmos/upgrade/v1beta1/current_plan endpoint.
+type CurrentPlanResult struct {
+	Plan *Plan `json:"plan"`
+}
+
+// QueryCurrentPlan gets the currently scheduled upgrade plan from the upgrade module.
+// It returns nil if no upgrade is scheduled.
+func QueryCurrentPlan(ctx context.Context, lcdladdr string, logger types.Logger) (*Plan, error) {
+	return NewClient(logger).QueryCurrentPlan(ctx, lcdladdr)
+}
+
+// QueryCurrentPlan gets the currently scheduled upgrade plan from the upgrade module.
+// It returns nil if no upgrade is scheduled.
+func (c *Client) QueryCurrentPlan(ctx context.Context, lcdladdr string) (*Plan, error) {
+	var result CurrentPlanResult
+	if err := c.GetJSON(ctx, "current_plan", lcdURL(lcdladdr, "/cosmos/upgrade/v1beta1/current_plan"), &result, json.Unmarshal); err != nil {
+		return nil, err
+	}
+
+	return result.Plan, nil
+}
@@ -0,0 +1,33 @@
+package rpc
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryCurrentPlan(t *testing.T) {
+	body := `{"plan":{"name":"v10","time":"0001-01-01T00:00:00Z","height":"1234567","info":"","upgraded_client_state":null}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/cosmos/upgrade/v1beta1/current_plan", r.URL.Path)
+		_, _ = rw.Write([]byte(body))
+	}))
+	defer srv.Close()
+	addr := "tcp://" + srv.Listener.Addr().String()
+	logger := types.NewSyncLogger(ioutil.Discard, "", 0)
+
+	plan, err := QueryCurrentPlan(context.Background(), addr, logger)
+	assert.NoError(t, err)
+	assert.Equal(t, &Plan{Name: "v10", Height: 1234567}, plan)
+
+	// No upgrade is scheduled.
+	body = `{"plan":null}`
+	plan, err = QueryCurrentPlan(context.Background(), addr, logger)
+	assert.NoError(t, err)
+	assert.Nil(t, plan)
+}
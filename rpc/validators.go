@@ -0,0 +1,65 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+const (
+	// validatorsPerPage is the number of validators queried per page from the
+	// /validators endpoint.
+	validatorsPerPage = 100
+
+	// maxValidatorPages is the maximum number of pages queried from the /validators
+	// endpoint, so that a malicious RPC server can't keep SignCTRL busy by reporting
+	// a huge validator set.
+	maxValidatorPages = 100
+)
+
+var (
+	// ErrNoValidatorsResult is returned if the response of the /validators endpoint
+	// doesn't contain a result.
+	ErrNoValidatorsResult = errors.New("no result in /validators response")
+)
+
+// QueryValidatorSet gets the active validator set at the given height, or at the
+// latest height if it is 0, from Tendermint's /validators endpoint.
+func QueryValidatorSet(ctx context.Context, rpcladdr string, height int64, logger types.Logger) ([]*tm_types.Validator, error) {
+	return NewClient(logger).QueryValidatorSet(ctx, rpcladdr, height)
+}
+
+// QueryValidatorSet gets the active validator set at the given height, or at the
+// latest height if it is 0, from the validator's RPC server. All pages of the
+// validator set are queried.
+func (c *Client) QueryValidatorSet(ctx context.Context, rpcladdr string, height int64) ([]*tm_types.Validator, error) {
+	var vals []*tm_types.Validator
+	for page := 1; page <= maxValidatorPages; page++ {
+		path := fmt.Sprintf("/validators?page=%v&per_page=%v", page, validatorsPerPage)
+		if height > 0 {
+			path += fmt.Sprintf("&height=%v", height)
+		}
+
+		var res struct {
+			Result *tm_coretypes.ResultValidators `json:"result"`
+		}
+		if err := c.GetJSON(ctx, "validators", rpcURL(rpcladdr, path), &res, tm_json.Unmarshal); err != nil {
+			return nil, err
+		}
+		if res.Result == nil {
+			return nil, fmt.Errorf("%w for height %v", ErrNoValidatorsResult, height)
+		}
+
+		vals = append(vals, res.Result.Validators...)
+		if len(res.Result.Validators) == 0 || len(vals) >= res.Result.Total {
+			break
+		}
+	}
+
+	return vals, nil
+}
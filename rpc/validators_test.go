@@ -0,0 +1,69 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+func TestQueryValidatorSet(t *testing.T) {
+	var vals []*tm_types.Validator
+	for i := 0; i < validatorsPerPage+1; i++ {
+		vals = append(vals, tm_types.NewValidator(tm_ed25519.GenPrivKey().PubKey(), 10))
+	}
+
+	var heights []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		heights = append(heights, query.Get("height"))
+		page, _ := strconv.Atoi(query.Get("page"))
+		perPage, _ := strconv.Atoi(query.Get("per_page"))
+		start, end := (page-1)*perPage, page*perPage
+		if end > len(vals) {
+			end = len(vals)
+		}
+
+		bytes, err := tm_json.Marshal(&tm_coretypes.ResultValidators{
+			Validators: vals[start:end],
+			Count:      end - start,
+			Total:      len(vals),
+		})
+		assert.NoError(t, err)
+		fmt.Fprintf(rw, `{"jsonrpc":"2.0","id":-1,"result":%s}`, bytes)
+	}))
+	defer server.Close()
+	rpcladdr := "tcp://" + strings.TrimPrefix(server.URL, "http://")
+
+	// All pages are queried.
+	res, err := (&Client{}).QueryValidatorSet(context.Background(), rpcladdr, 0)
+	assert.NoError(t, err)
+	assert.Len(t, res, len(vals))
+	assert.Equal(t, vals[validatorsPerPage].Address, res[validatorsPerPage].Address)
+	assert.Equal(t, []string{"", ""}, heights)
+
+	heights = nil
+	_, err = (&Client{}).QueryValidatorSet(context.Background(), rpcladdr, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"7", "7"}, heights)
+}
+
+func TestQueryValidatorSet_NoResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(rw, `{"jsonrpc":"2.0","id":-1}`)
+	}))
+	defer server.Close()
+
+	_, err := (&Client{}).QueryValidatorSet(context.Background(), "tcp://"+strings.TrimPrefix(server.URL, "http://"), 0)
+	assert.True(t, errors.Is(err, ErrNoValidatorsResult))
+}
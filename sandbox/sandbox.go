@@ -0,0 +1,280 @@
+// Package sandbox restricts SignCTRL once it is initialized, so that a compromised
+// process can't read or modify files outside its configuration and state
+// directories, connect to endpoints other than the configured ones or execute other
+// programs. The file system and network restrictions are enforced with landlock, and
+// dangerous system calls are denied with a seccomp filter. Sandboxing is only
+// supported on Linux, and it can't be undone for the lifetime of the process.
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BlockscapeNetwork/signctrl/alerts"
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+)
+
+const (
+	// dnsPort is the port DNS queries fall back to TCP on if a response is truncated.
+	dnsPort = 53
+
+	// awsContainerEndpoint and awsInstanceMetadataEndpoint are the endpoints of the ECS
+	// container credentials and the EC2 instance metadata services the AWS SDK gets
+	// its credentials from if no credentials_file is set.
+	awsContainerEndpoint        = "http://169.254.170.2"
+	awsInstanceMetadataEndpoint = "http://169.254.169.254"
+)
+
+var (
+	// ErrUnsupported is returned if sandboxing isn't supported on the platform.
+	ErrUnsupported = errors.New("sandboxing is only supported on linux/amd64 and linux/arm64")
+
+	// ErrCgo is returned if the binary uses cgo, in which case the Go runtime can't
+	// restrict threads it didn't create.
+	ErrCgo = errors.New("sandboxing requires a binary built with CGO_ENABLED=0")
+
+	// systemReadPaths are the paths that Go's standard library reads lazily, e.g. to
+	// resolve host names and to verify TLS certificates, and that the process metrics
+	// are read from. Paths that don't exist on the host are skipped.
+	systemReadPaths = []string{
+		"/etc/hosts",
+		"/etc/localtime",
+		"/etc/nsswitch.conf",
+		"/etc/resolv.conf",
+		"/etc/ssl",
+		"/etc/pki",
+		"/etc/ca-certificates",
+		"/usr/share/ca-certificates",
+		"/usr/share/zoneinfo",
+		"/dev/urandom",
+		"/proc",
+	}
+)
+
+// Rules are the files, directories and TCP ports SignCTRL may access once it is
+// sandboxed.
+type Rules struct {
+	// ReadPaths are the files and directories that may be read.
+	ReadPaths []string
+
+	// WritePaths are the files and directories that may be read and written.
+	WritePaths []string
+
+	// BindPorts are the TCP ports that may be listened on.
+	BindPorts []uint16
+
+	// ConnectPorts are the TCP ports that may be connected to.
+	ConnectPorts []uint16
+}
+
+// addrPort returns the port of a tcp:// address. It returns false for addresses of
+// unix domain sockets and empty addresses.
+func addrPort(addr string) (uint16, bool) {
+	if !strings.HasPrefix(addr, "tcp://") {
+		return 0, false
+	}
+	_, port, err := net.SplitHostPort(strings.TrimPrefix(addr, "tcp://"))
+	if err != nil {
+		return 0, false
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return 0, false
+	}
+
+	return uint16(p), true
+}
+
+// urlPort returns the port of an http:// or https:// URL, falling back to the
+// scheme's default port.
+func urlPort(rawURL string) (uint16, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, false
+	}
+	if port := u.Port(); port != "" {
+		p, err := strconv.ParseUint(port, 10, 16)
+		return uint16(p), err == nil
+	}
+	switch u.Scheme {
+	case "http":
+		return 80, true
+	case "https":
+		return 443, true
+	}
+
+	return 0, false
+}
+
+// uniquePorts returns the given ports sorted and without duplicates.
+func uniquePorts(ports []uint16) []uint16 {
+	sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+	var unique []uint16
+	for i, p := range ports {
+		if i == 0 || p != ports[i-1] {
+			unique = append(unique, p)
+		}
+	}
+
+	return unique
+}
+
+// RulesFor returns the rules for running SignCTRL with the given configuration and
+// the configurations of the instances in the configuration directory. The
+// configuration directory, which also holds the directories of the consumer chains
+// and instances, may be written. SignCTRL may listen on the HTTP port and connect to
+// DNS servers. The rules of each configuration are added by configRules.
+func RulesFor(cfgDir string, cfg config.Config, httpPort int) (Rules, error) {
+	absCfgDir, err := filepath.Abs(cfgDir)
+	if err != nil {
+		return Rules{}, err
+	}
+	r := Rules{
+		WritePaths:   []string{absCfgDir},
+		BindPorts:    []uint16{uint16(httpPort)},
+		ConnectPorts: []uint16{dnsPort},
+	}
+
+	cfgs := []config.Config{cfg}
+	names, err := privval.ListInstances(cfgDir)
+	if err != nil {
+		return Rules{}, err
+	}
+	for _, name := range names {
+		instanceCfg, err := config.LoadFrom(privval.InstanceDir(cfgDir, name))
+		if err != nil {
+			return Rules{}, fmt.Errorf("couldn't load the configuration of instance %v: %w", name, err)
+		}
+		cfgs = append(cfgs, instanceCfg)
+	}
+	for _, c := range cfgs {
+		if err := configRules(&r, c); err != nil {
+			return Rules{}, err
+		}
+	}
+	r.BindPorts, r.ConnectPorts = uniquePorts(r.BindPorts), uniquePorts(r.ConnectPorts)
+
+	return r, nil
+}
+
+// configRules adds the rules for a single configuration to r. The directory of
+// tmkms's state file may be written. The files referenced by the configuration may be
+// read. SignCTRL may listen on the gRPC listen address and the p2p listen address,
+// and connect to the validators, the RPC and LCD endpoints, the light client's
+// witnesses, the remote-write and backup endpoints, the store of the signing lock,
+// AWS KMS and the services its credentials are taken from and the peers in the set.
+func configRules(r *Rules, cfg config.Config) error {
+	if cfg.Privval.TmkmsStateFile != "" {
+		dir, err := filepath.Abs(filepath.Dir(cfg.Privval.TmkmsStateFile))
+		if err != nil {
+			return err
+		}
+		r.WritePaths = append(r.WritePaths, dir)
+	}
+	r.WritePaths = append(r.WritePaths, cfg.Sandbox.WritePaths...)
+	for _, file := range []string{
+		cfg.Admin.TokenFile,
+		cfg.Admin.TOTPSecretFile,
+		cfg.Metrics.BearerTokenFile,
+		cfg.Backup.CredentialsFile,
+		cfg.KMS.CredentialsFile,
+		cfg.Privval.GRPCCertFile,
+		cfg.Privval.GRPCKeyFile,
+		cfg.Privval.GRPCClientCAFile,
+		cfg.Privval.TLSCertFile,
+		cfg.Privval.TLSKeyFile,
+		cfg.Privval.TLSCAFile,
+		cfg.P2P.SecretFile,
+	} {
+		if file == "" {
+			continue
+		}
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		r.ReadPaths = append(r.ReadPaths, abs)
+	}
+	r.ReadPaths = append(r.ReadPaths, cfg.Sandbox.ReadPaths...)
+
+	if cfg.Privval.Transport == "grpc" {
+		if port, ok := addrPort(cfg.Privval.GRPCListenAddress); ok {
+			r.BindPorts = append(r.BindPorts, port)
+		}
+	}
+	if cfg.P2P.Enabled() {
+		if port, ok := addrPort(cfg.P2P.ListenAddress); ok {
+			r.BindPorts = append(r.BindPorts, port)
+		}
+	}
+
+	addrs := []string{cfg.Base.ValidatorListenAddress, cfg.Base.ValidatorListenAddressRPC}
+	addrs = append(addrs, cfg.RPC.Endpoints...)
+	addrs = append(addrs, cfg.LightClient.Witnesses...)
+	if cfg.Slashing.Enabled() {
+		addrs = append(addrs, cfg.Slashing.LCDListenAddress)
+	}
+	if cfg.Upgrades.QueriesPlan() {
+		addrs = append(addrs, cfg.Upgrades.LCDListenAddress)
+	}
+	for _, consumer := range cfg.Consumers {
+		addrs = append(addrs, consumer.ValidatorListenAddress, consumer.ValidatorListenAddressRPC)
+	}
+	if cfg.P2P.Enabled() {
+		addrs = append(addrs, cfg.P2P.Peers...)
+	}
+	for _, addr := range addrs {
+		if port, ok := addrPort(addr); ok {
+			r.ConnectPorts = append(r.ConnectPorts, port)
+		}
+	}
+	if cfg.Metrics.RemoteWriteEnabled() {
+		port, ok := urlPort(cfg.Metrics.RemoteWriteURL)
+		if !ok {
+			return fmt.Errorf("couldn't determine the port of %v", cfg.Metrics.RemoteWriteURL)
+		}
+		r.ConnectPorts = append(r.ConnectPorts, port)
+	}
+	if cfg.Backup.Enabled() {
+		port, ok := urlPort(cfg.Backup.Endpoint)
+		if !ok {
+			return fmt.Errorf("couldn't determine the port of %v", cfg.Backup.Endpoint)
+		}
+		r.ConnectPorts = append(r.ConnectPorts, port)
+	}
+	urls := append([]string{}, cfg.Alerts.Webhooks...)
+	if cfg.Alerts.PagerDutyRoutingKey != "" {
+		urls = append(urls, alerts.DefaultPagerDutyURL)
+	}
+	if cfg.Alerts.SlackWebhook != "" {
+		urls = append(urls, cfg.Alerts.SlackWebhook)
+	}
+	if cfg.Lock.Enabled() {
+		urls = append(urls, cfg.Lock.Endpoints...)
+	}
+	if cfg.KMS.Enabled() {
+		urls = append(urls, cfg.KMS.GetEndpoint())
+		if cfg.KMS.CredentialsFile == "" {
+			urls = append(urls, awsContainerEndpoint, awsInstanceMetadataEndpoint)
+		}
+	}
+	for _, u := range urls {
+		port, ok := urlPort(u)
+		if !ok {
+			return fmt.Errorf("couldn't determine the port of %v", u)
+		}
+		r.ConnectPorts = append(r.ConnectPorts, port)
+	}
+	for _, port := range cfg.Sandbox.ConnectPorts {
+		r.ConnectPorts = append(r.ConnectPorts, uint16(port))
+	}
+
+	return nil
+}
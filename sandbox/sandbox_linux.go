@@ -0,0 +1,309 @@
+//go:build linux
+// +build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// The landlock system calls, which have the same numbers on all architectures.
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1 << 0
+	landlockRulePathBeneath      = 1
+	landlockRuleNetPort          = 2
+
+	// The landlock file system access rights. The first 13 are supported by all
+	// landlock ABI versions.
+	accessFSExecute    = 1 << 0
+	accessFSWriteFile  = 1 << 1
+	accessFSReadFile   = 1 << 2
+	accessFSReadDir    = 1 << 3
+	accessFSRemoveDir  = 1 << 4
+	accessFSRemoveFile = 1 << 5
+	accessFSMakeChar   = 1 << 6
+	accessFSMakeDir    = 1 << 7
+	accessFSMakeReg    = 1 << 8
+	accessFSMakeSock   = 1 << 9
+	accessFSMakeFifo   = 1 << 10
+	accessFSMakeBlock  = 1 << 11
+	accessFSMakeSym    = 1 << 12
+	accessFSRefer      = 1 << 13 // ABI version 2
+	accessFSTruncate   = 1 << 14 // ABI version 3
+	accessFSIoctlDev   = 1 << 15 // ABI version 5
+
+	// The landlock network access rights, supported since ABI version 4.
+	accessNetBindTCP    = 1 << 0
+	accessNetConnectTCP = 1 << 1
+
+	// accessFSFile are the access rights that apply to files rather than directories.
+	accessFSFile = accessFSExecute | accessFSWriteFile | accessFSReadFile | accessFSTruncate | accessFSIoctlDev
+
+	// accessFSRead are the access rights granted for ReadPaths.
+	accessFSRead = accessFSReadFile | accessFSReadDir
+
+	// accessFSWrite are the access rights granted for WritePaths. Executing files and
+	// creating devices are never allowed.
+	accessFSWrite = accessFSRead | accessFSWriteFile | accessFSRemoveDir | accessFSRemoveFile |
+		accessFSMakeDir | accessFSMakeReg | accessFSMakeSock | accessFSMakeFifo | accessFSMakeSym |
+		accessFSRefer | accessFSTruncate
+
+	// oPath is O_PATH on amd64 and arm64, which the syscall package doesn't define.
+	oPath = 0x200000
+
+	prSetNoNewPrivs = 38
+
+	seccompSetModeFilter   = 1
+	seccompFilterFlagTsync = 1
+
+	seccompRetAllow = 0x7fff0000
+	seccompRetErrno = 0x00050000
+
+	bpfLd  = 0x00
+	bpfW   = 0x00
+	bpfAbs = 0x20
+	bpfJmp = 0x05
+	bpfJeq = 0x10
+	bpfJge = 0x30
+	bpfK   = 0x00
+	bpfRet = 0x06
+
+	// The offsets of the system call number and the architecture in struct
+	// seccomp_data.
+	seccompDataNr   = 0
+	seccompDataArch = 4
+)
+
+// landlockRulesetAttr is struct landlock_ruleset_attr.
+type landlockRulesetAttr struct {
+	handledAccessFS  uint64
+	handledAccessNet uint64
+}
+
+// landlockPathBeneathAttr is struct landlock_path_beneath_attr. The kernel's struct
+// is packed, which only differs in the trailing padding the kernel doesn't read.
+type landlockPathBeneathAttr struct {
+	allowedAccess uint64
+	parentFd      int32
+}
+
+// landlockNetPortAttr is struct landlock_net_port_attr.
+type landlockNetPortAttr struct {
+	allowedAccess uint64
+	port          uint64
+}
+
+// sockFilter is struct sock_filter, a single BPF instruction.
+type sockFilter struct {
+	code uint16
+	jt   uint8
+	jf   uint8
+	k    uint32
+}
+
+// sockFprog is struct sock_fprog, a BPF program.
+type sockFprog struct {
+	len    uint16
+	filter *sockFilter
+}
+
+// landlockABI returns the landlock ABI version supported by the kernel.
+func landlockABI() (int, error) {
+	abi, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return 0, errno
+	}
+
+	return int(abi), nil
+}
+
+// handledAccessFS returns the file system access rights supported by the given
+// landlock ABI version.
+func handledAccessFS(abi int) uint64 {
+	access := uint64(accessFSMakeSym<<1 - 1)
+	if abi >= 2 {
+		access |= accessFSRefer
+	}
+	if abi >= 3 {
+		access |= accessFSTruncate
+	}
+	if abi >= 5 {
+		access |= accessFSIoctlDev
+	}
+
+	return access
+}
+
+// addPathRule allows the given access to the file or directory at path.
+func addPathRule(rulesetFd int, path string, access uint64) error {
+	fd, err := syscall.Open(path, oPath|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	defer syscall.Close(fd)
+
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(fd, &stat); err != nil {
+		return &os.PathError{Op: "stat", Path: path, Err: err}
+	}
+	if stat.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		access &= accessFSFile
+	}
+	attr := landlockPathBeneathAttr{allowedAccess: access, parentFd: int32(fd)}
+	if _, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(rulesetFd), landlockRulePathBeneath, uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("couldn't add landlock rule for %v: %w", path, errno)
+	}
+
+	return nil
+}
+
+// addPortRule allows the given access to the given TCP port.
+func addPortRule(rulesetFd int, port uint16, access uint64) error {
+	attr := landlockNetPortAttr{allowedAccess: access, port: uint64(port)}
+	if _, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(rulesetFd), landlockRuleNetPort, uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("couldn't add landlock rule for port %v: %w", port, errno)
+	}
+
+	return nil
+}
+
+// restrictAccess restricts all threads of the process to the given rules with
+// landlock. The network is only restricted if the kernel supports landlock ABI
+// version 4 or higher.
+func restrictAccess(r Rules, abi int) error {
+	attr := landlockRulesetAttr{handledAccessFS: handledAccessFS(abi)}
+	size := unsafe.Sizeof(attr.handledAccessFS)
+	if abi >= 4 {
+		attr.handledAccessNet = accessNetBindTCP | accessNetConnectTCP
+		size = unsafe.Sizeof(attr)
+	}
+	fd, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), size, 0)
+	if errno != 0 {
+		return fmt.Errorf("couldn't create landlock ruleset: %w", errno)
+	}
+	defer syscall.Close(int(fd))
+
+	for _, path := range systemReadPaths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		if err := addPathRule(int(fd), path, accessFSRead&attr.handledAccessFS); err != nil {
+			return err
+		}
+	}
+	for _, path := range r.ReadPaths {
+		if err := addPathRule(int(fd), path, accessFSRead&attr.handledAccessFS); err != nil {
+			return err
+		}
+	}
+	for _, path := range r.WritePaths {
+		if err := addPathRule(int(fd), path, accessFSWrite&attr.handledAccessFS); err != nil {
+			return err
+		}
+	}
+	if abi >= 4 {
+		for _, port := range r.BindPorts {
+			if err := addPortRule(int(fd), port, accessNetBindTCP); err != nil {
+				return err
+			}
+		}
+		for _, port := range r.ConnectPorts {
+			if err := addPortRule(int(fd), port, accessNetConnectTCP); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Landlock only restricts the calling thread, so all of the runtime's threads must
+	// restrict themselves. This isn't possible if the runtime doesn't know about all
+	// threads, i.e. if cgo is used.
+	if _, _, errno := syscall.AllThreadsSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return noNewPrivsError(errno)
+	}
+	if _, _, errno := syscall.AllThreadsSyscall(sysLandlockRestrictSelf, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("couldn't enforce landlock ruleset: %w", errno)
+	}
+
+	return nil
+}
+
+// noNewPrivsError returns the error for failing to set no_new_privs.
+func noNewPrivsError(errno syscall.Errno) error {
+	if errno == syscall.ENOTSUP {
+		return ErrCgo
+	}
+
+	return fmt.Errorf("couldn't set no_new_privs: %w", errno)
+}
+
+// seccompFilter returns a BPF program that denies the deniedSyscalls and all system
+// calls of foreign architectures with EPERM and allows all other system calls.
+func seccompFilter() []sockFilter {
+	deny := sockFilter{code: bpfRet | bpfK, k: seccompRetErrno | uint32(syscall.EPERM)}
+	filter := []sockFilter{
+		{code: bpfLd | bpfW | bpfAbs, k: seccompDataArch},
+		{code: bpfJmp | bpfJeq | bpfK, jt: 1, k: auditArch},
+		deny,
+		{code: bpfLd | bpfW | bpfAbs, k: seccompDataNr},
+	}
+	if x32SyscallBit != 0 {
+		filter = append(filter, sockFilter{code: bpfJmp | bpfJge | bpfK, jf: 1, k: x32SyscallBit}, deny)
+	}
+	for _, nr := range deniedSyscalls {
+		filter = append(filter, sockFilter{code: bpfJmp | bpfJeq | bpfK, jf: 1, k: nr}, deny)
+	}
+
+	return append(filter, sockFilter{code: bpfRet | bpfK, k: seccompRetAllow})
+}
+
+// denySyscalls installs the seccomp filter on all threads of the process.
+func denySyscalls() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return noNewPrivsError(errno)
+	}
+	filter := seccompFilter()
+	prog := sockFprog{len: uint16(len(filter)), filter: &filter[0]}
+	tid, _, errno := syscall.RawSyscall(sysSeccomp, seccompSetModeFilter, seccompFilterFlagTsync, uintptr(unsafe.Pointer(&prog)))
+	if errno != 0 {
+		return fmt.Errorf("couldn't install seccomp filter: %w", errno)
+	} else if tid != 0 {
+		return fmt.Errorf("couldn't install seccomp filter on thread %v", tid)
+	}
+	runtime.KeepAlive(filter)
+
+	return nil
+}
+
+// Apply sandboxes the process with the given rules and returns the landlock ABI
+// version supported by the kernel. TCP ports are only restricted from version 4 on.
+// Files and directories in the rules must exist. Apply must be called after
+// SignCTRL is initialized, as the process can't execute other programs or access
+// anything not covered by the rules afterwards.
+func Apply(r Rules) (int, error) {
+	if auditArch == 0 {
+		return 0, ErrUnsupported
+	}
+	abi, err := landlockABI()
+	if err != nil {
+		return 0, fmt.Errorf("landlock isn't supported by the kernel: %w", err)
+	}
+	if err := restrictAccess(r, abi); err != nil {
+		return 0, err
+	}
+	if err := denySyscalls(); err != nil {
+		return 0, err
+	}
+
+	return abi, nil
+}
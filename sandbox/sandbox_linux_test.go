@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// sandboxedEnv is set for the test binary that is started by TestApply and
+	// sandboxes itself.
+	sandboxedEnv = "SIGNCTRL_SANDBOX_TEST_DIRS"
+
+	// exitUnsupported is the exit code of the sandboxed test binary if sandboxing
+	// isn't supported in the test environment.
+	exitUnsupported = 3
+)
+
+func TestSeccompFilter(t *testing.T) {
+	filter := seccompFilter()
+	denied := 1 + len(deniedSyscalls)
+	if x32SyscallBit != 0 {
+		denied++
+	}
+	assert.Len(t, filter, 4+2*(denied-1)+1)
+	assert.Equal(t, sockFilter{code: bpfRet | bpfK, k: seccompRetAllow}, filter[len(filter)-1])
+}
+
+// runSandboxed sandboxes the test binary with write access to allowed only, and
+// checks that it can't write to denied or execute other programs.
+func runSandboxed(allowed, denied string) {
+	if _, err := Apply(Rules{WritePaths: []string{allowed}}); err != nil {
+		fmt.Println(err)
+		if errors.Is(err, ErrUnsupported) || errors.Is(err, ErrCgo) || errors.Is(err, syscall.ENOSYS) || errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOTSUP) {
+			os.Exit(exitUnsupported)
+		}
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(filepath.Join(allowed, "state.json"), []byte("{}"), 0600); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(filepath.Join(denied, "state.json"), []byte("{}"), 0600); !os.IsPermission(err) {
+		fmt.Printf("writing outside the sandbox: %v\n", err)
+		os.Exit(1)
+	}
+	if err := exec.Command("/bin/true").Run(); err == nil {
+		fmt.Println("executing a program in the sandbox succeeded")
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestApply(t *testing.T) {
+	if dirs := filepath.SplitList(os.Getenv(sandboxedEnv)); len(dirs) == 2 {
+		runSandboxed(dirs[0], dirs[1])
+	}
+
+	allowed, denied := t.TempDir(), t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestApply$")
+	cmd.Env = append(os.Environ(), sandboxedEnv+"="+allowed+string(filepath.ListSeparator)+denied)
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == exitUnsupported {
+		t.Skipf("sandboxing isn't supported: %s", out)
+	}
+	require.NoError(t, err, string(out))
+	assert.FileExists(t, filepath.Join(allowed, "state.json"))
+	assert.NoFileExists(t, filepath.Join(denied, "state.json"))
+}
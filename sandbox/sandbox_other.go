@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package sandbox
+
+// Apply returns ErrUnsupported, as sandboxing is only supported on Linux.
+func Apply(r Rules) (int, error) {
+	return 0, ErrUnsupported
+}
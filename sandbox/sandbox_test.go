@@ -0,0 +1,103 @@
+package sandbox
+
+import (
+	"os"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddrPort(t *testing.T) {
+	port, ok := addrPort("tcp://127.0.0.1:26657")
+	assert.True(t, ok)
+	assert.Equal(t, uint16(26657), port)
+
+	_, ok = addrPort("unix:///var/run/validator.sock")
+	assert.False(t, ok)
+	_, ok = addrPort("")
+	assert.False(t, ok)
+}
+
+func TestURLPort(t *testing.T) {
+	port, ok := urlPort("https://prometheus.example.com/api/v1/write")
+	assert.True(t, ok)
+	assert.Equal(t, uint16(443), port)
+
+	port, ok = urlPort("http://10.0.0.1:9090/api/v1/write")
+	assert.True(t, ok)
+	assert.Equal(t, uint16(9090), port)
+}
+
+func TestRulesFor(t *testing.T) {
+	cfg := config.Config{
+		Base: config.Base{
+			ValidatorListenAddress:    "tcp://127.0.0.1:3000",
+			ValidatorListenAddressRPC: "tcp://127.0.0.1:26657",
+		},
+		Privval: config.PrivValidator{
+			TmkmsStateFile:    "/var/lib/tmkms/state/cosmoshub-4.json",
+			Transport:         "grpc",
+			GRPCListenAddress: "tcp://127.0.0.1:3001",
+		},
+		RPC:         config.RPC{Endpoints: []string{"tcp://10.0.0.2:26657"}},
+		LightClient: config.LightClient{Witnesses: []string{"tcp://10.0.0.3:26667"}},
+		Metrics:     config.Metrics{RemoteWriteURL: "https://prometheus.example.com/api/v1/write", BearerTokenFile: "/etc/signctrl/push_token"},
+		Admin:       config.Admin{TokenFile: "/etc/signctrl/admin_tokens"},
+		Backup:      config.Backup{Interval: "1m", Endpoint: "https://storage.googleapis.com", CredentialsFile: "/etc/signctrl/backup_credentials"},
+		Sandbox:     config.Sandbox{WritePaths: []string{"/var/backups/signctrl"}, ConnectPorts: []int{3002}},
+		P2P:         config.P2P{ListenAddress: "tcp://0.0.0.0:26660", Peers: []string{"tcp://10.0.0.4:26661"}, SecretFile: "/etc/signctrl/p2p_secret"},
+		Alerts:      config.Alerts{Webhooks: []string{"http://alerts.example.com:9093/hook"}},
+		Lock:        config.Lock{Backend: config.LockBackendEtcd, Endpoints: []string{"http://10.0.0.1:2379"}},
+		KMS:         config.KMS{KeyID: "alias/validator", Region: "eu-central-1"},
+		Consumers: []config.Consumer{
+			{ChainID: "neutron-1", ValidatorListenAddress: "tcp://127.0.0.1:3100", ValidatorListenAddressRPC: "tcp://127.0.0.1:26657"},
+		},
+	}
+	r, err := RulesFor("/home/signctrl/.signctrl", cfg, 8080)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/home/signctrl/.signctrl", "/var/lib/tmkms/state", "/var/backups/signctrl"}, r.WritePaths)
+	assert.Equal(t, []string{"/etc/signctrl/admin_tokens", "/etc/signctrl/push_token", "/etc/signctrl/backup_credentials", "/etc/signctrl/p2p_secret"}, r.ReadPaths)
+	assert.Equal(t, []uint16{3001, 8080, 26660}, r.BindPorts)
+
+	// The RPC port shared by both chains is only allowed once. The slashing and
+	// upgrades LCDs are disabled. The KMS credentials are taken from the instance
+	// metadata.
+	assert.Equal(t, []uint16{53, 80, 443, 2379, 3000, 3002, 3100, 9093, 26657, 26661, 26667}, r.ConnectPorts)
+}
+
+func TestRulesFor_Instances(t *testing.T) {
+	cfgDir := t.TempDir()
+	dir := privval.InstanceDir(cfgDir, "operator-a")
+	require.NoError(t, os.MkdirAll(dir, config.PermConfigDir))
+	require.NoError(t, config.Create(dir))
+	require.NoError(t, config.Override(dir, map[string]interface{}{
+		"privval.chain_id":         "otherchain",
+		"base.start_rank":          2,
+		"base.validator_laddr":     "tcp://127.0.0.1:4000",
+		"base.validator_laddr_rpc": "tcp://127.0.0.1:36657",
+		"slashing.lcd_laddr":       "tcp://127.0.0.1:1317",
+		"slashing.valcons_address": "cosmosvalcons1",
+		"slashing.query_interval":  "1m",
+	}))
+
+	cfg := config.Config{
+		Base: config.Base{
+			ValidatorListenAddress:    "tcp://127.0.0.1:3000",
+			ValidatorListenAddressRPC: "tcp://127.0.0.1:26657",
+		},
+	}
+	r, err := RulesFor(cfgDir, cfg, 8080)
+	require.NoError(t, err)
+
+	// The ports of the instance are allowed along with the ones of the default
+	// validator.
+	assert.Equal(t, []uint16{53, 1317, 3000, 4000, 26657, 36657}, r.ConnectPorts)
+
+	// An invalid instance configuration isn't skipped.
+	require.NoError(t, config.Override(dir, map[string]interface{}{"base.validator_laddr": "invalid"}))
+	_, err = RulesFor(cfgDir, cfg, 8080)
+	assert.Error(t, err)
+}
@@ -0,0 +1,25 @@
+//go:build linux && amd64
+// +build linux,amd64
+
+package sandbox
+
+const (
+	// auditArch is AUDIT_ARCH_X86_64.
+	auditArch = 0xc000003e
+
+	// x32SyscallBit is set in the numbers of x32 system calls, which are denied.
+	x32SyscallBit = 0x40000000
+
+	// sysSeccomp is the number of the seccomp system call.
+	sysSeccomp = 317
+)
+
+// deniedSyscalls are the numbers of the system calls the seccomp filter denies:
+// execve, execveat, ptrace, process_vm_readv, process_vm_writev, mount, umount2,
+// pivot_root, chroot, kexec_load, kexec_file_load, init_module, finit_module,
+// delete_module, bpf, perf_event_open, userfaultfd, keyctl, add_key, request_key,
+// reboot, swapon, swapoff, setns, unshare and open_by_handle_at.
+var deniedSyscalls = []uint32{
+	59, 322, 101, 310, 311, 165, 166, 155, 161, 246, 320, 175, 313, 176, 321, 298, 323,
+	250, 248, 249, 169, 167, 168, 308, 272, 304,
+}
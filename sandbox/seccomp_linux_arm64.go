@@ -0,0 +1,25 @@
+//go:build linux && arm64
+// +build linux,arm64
+
+package sandbox
+
+const (
+	// auditArch is AUDIT_ARCH_AARCH64.
+	auditArch = 0xc00000b7
+
+	// x32SyscallBit is only used on amd64.
+	x32SyscallBit = 0
+
+	// sysSeccomp is the number of the seccomp system call.
+	sysSeccomp = 277
+)
+
+// deniedSyscalls are the numbers of the system calls the seccomp filter denies:
+// execve, execveat, ptrace, process_vm_readv, process_vm_writev, mount, umount2,
+// pivot_root, chroot, kexec_load, kexec_file_load, init_module, finit_module,
+// delete_module, bpf, perf_event_open, userfaultfd, keyctl, add_key, request_key,
+// reboot, swapon, swapoff, setns, unshare and open_by_handle_at.
+var deniedSyscalls = []uint32{
+	221, 281, 117, 270, 271, 40, 39, 41, 51, 104, 294, 105, 273, 106, 280, 241, 282,
+	219, 217, 218, 142, 224, 225, 268, 97, 265,
+}
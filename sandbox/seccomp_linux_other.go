@@ -0,0 +1,15 @@
+//go:build linux && !amd64 && !arm64
+// +build linux,!amd64,!arm64
+
+package sandbox
+
+const (
+	// auditArch is 0 on architectures the seccomp filter isn't defined for, which
+	// makes Apply return ErrUnsupported.
+	auditArch     = 0
+	x32SyscallBit = 0
+	sysSeccomp    = 0
+)
+
+// deniedSyscalls is empty on architectures the seccomp filter isn't defined for.
+var deniedSyscalls []uint32
@@ -0,0 +1,228 @@
+// Package signctrl allows SignCTRL to be embedded into other applications. It
+// constructs and runs a SignCTRL node from an options struct, without relying on
+// viper, cobra or the configuration directory layout used by the signctrl binary.
+package signctrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/internal/goroutines"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+var (
+	// ErrNoPrivValidator is returned if the options don't contain a private validator
+	// to sign votes and proposals with.
+	ErrNoPrivValidator = errors.New("no private validator specified")
+)
+
+// Options defines the options a SignCTRL node is constructed with.
+type Options struct {
+	// Config is the configuration of the node. It is validated by New.
+	Config config.Config
+
+	// State is the state the node starts with.
+	State config.State
+
+	// CfgDir is the directory the node loads its connection key from and saves its
+	// state to. Defaults to config.Dir().
+	CfgDir string
+
+	// PrivValidator signs the votes and proposals the node has permission to sign.
+	PrivValidator tm_types.PrivValidator
+
+	// Logger is the logger used by the node. Logs are discarded if nil.
+	Logger types.Logger
+
+	// Dialer establishes the connection to the validator. Defaults to dialing the
+	// validator_laddr from the configuration.
+	Dialer privval.Dialer
+
+	// BlockQuerier queries the blocks used to check for missed blocks. Defaults to
+	// querying the validator_laddr_rpc from the configuration.
+	BlockQuerier privval.BlockQuerier
+
+	// BlockVerifier verifies the blocks before they are counted as missed. Defaults to
+	// verifying them with the light client if the [light_client] section is configured.
+	BlockVerifier privval.BlockVerifier
+
+	// BlockSubscriber subscribes to the blocks committed by the validator if
+	// block_subscription is enabled. The subscribed blocks are only used by the
+	// default BlockQuerier. Defaults to subscribing via the validator_laddr_rpc from
+	// the configuration.
+	BlockSubscriber privval.BlockSubscriber
+
+	// VersionQuerier queries the validator's version its capabilities are detected
+	// from. Defaults to querying the validator_laddr_rpc from the
+	// configuration.
+	VersionQuerier privval.VersionQuerier
+
+	// SlashingQuerier queries the validator's status from the slashing and staking
+	// modules if the [slashing] section is configured. Defaults to querying the
+	// lcd_laddr from the configuration.
+	SlashingQuerier privval.SlashingQuerier
+
+	// UpgradePlanQuerier queries the upgrade scheduled in the upgrade module if
+	// lcd_laddr is set in the [upgrades] section. Defaults to querying the lcd_laddr
+	// from the configuration.
+	UpgradePlanQuerier privval.UpgradePlanQuerier
+
+	// ValidatorSetQuerier queries the chain's active validator set, which the
+	// validator's key is checked against on startup unless validator_set_check is off.
+	// Defaults to querying the validator_laddr_rpc from the configuration.
+	ValidatorSetQuerier privval.ValidatorSetQuerier
+
+	// ValidatorSetAtQuerier queries the validator set at the height of a block lacking
+	// the validator's commitsig, which is only counted as missed if the validator's key
+	// is part of it. Defaults to querying the validator_laddr_rpc from the configuration.
+	ValidatorSetAtQuerier privval.ValidatorSetAtQuerier
+
+	// LatestHeightQuerier queries the latest height for the commit watcher if
+	// watch_interval is set in the [rpc] section. Defaults to querying the
+	// validator_laddr_rpc from the configuration.
+	LatestHeightQuerier privval.LatestHeightQuerier
+
+	// HTTP is the server that serves the node's status. The HTTP server is disabled
+	// if nil.
+	HTTP *http.Server
+
+	// Gauges are the prometheus gauges updated by the node. Gauges are disabled if
+	// left empty.
+	Gauges types.Gauges
+
+	// Clock is the clock the node's timeouts are measured with. Defaults to the
+	// system clock.
+	Clock types.Clock
+
+	// OnCrash is called with the crash report after the node recovered from a panic.
+	// The node is already stopped and never signs again. A crash report is written to
+	// the CfgDir either way.
+	OnCrash func(report privval.CrashReport)
+}
+
+// Node is an embeddable SignCTRL node.
+type Node struct {
+	pv *privval.SCFilePV
+}
+
+// New validates the given options and creates a new SignCTRL node.
+func New(opts Options) (*Node, error) {
+	if err := opts.Config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	if opts.PrivValidator == nil {
+		return nil, ErrNoPrivValidator
+	}
+	if opts.Logger == nil {
+		opts.Logger = types.NewSyncLogger(ioutil.Discard, "", 0)
+	}
+
+	pv := privval.NewSCFilePV(opts.Logger, opts.Config, opts.State, opts.PrivValidator, opts.HTTP)
+	if opts.CfgDir != "" {
+		pv.CfgDir = opts.CfgDir
+	}
+	if opts.Dialer != nil {
+		pv.Dial = opts.Dialer
+	}
+	if opts.BlockQuerier != nil {
+		pv.QueryBlock = opts.BlockQuerier
+	}
+	if opts.BlockVerifier != nil {
+		pv.VerifyBlock = opts.BlockVerifier
+	}
+	if opts.BlockSubscriber != nil {
+		pv.SubscribeBlocks = opts.BlockSubscriber
+	}
+	if opts.VersionQuerier != nil {
+		pv.QueryVersion = opts.VersionQuerier
+	}
+	if opts.SlashingQuerier != nil {
+		pv.QuerySlashing = opts.SlashingQuerier
+	}
+	if opts.UpgradePlanQuerier != nil {
+		pv.QueryUpgradePlan = opts.UpgradePlanQuerier
+	}
+	if opts.ValidatorSetQuerier != nil {
+		pv.QueryValidatorSet = opts.ValidatorSetQuerier
+	}
+	if opts.ValidatorSetAtQuerier != nil {
+		pv.QueryValidatorSetAt = opts.ValidatorSetAtQuerier
+	}
+	if opts.LatestHeightQuerier != nil {
+		pv.QueryLatestHeight = opts.LatestHeightQuerier
+	}
+	pv.Gauges = opts.Gauges
+	if opts.Clock != nil {
+		pv.Clock = opts.Clock
+	}
+	pv.OnCrash = opts.OnCrash
+
+	return &Node{pv: pv}, nil
+}
+
+// Start dials the validator and starts handling its requests. The node is stopped
+// once ctx is done, Stop is called or the node shuts itself down.
+func (n *Node) Start(ctx context.Context) error {
+	if err := n.pv.StartContext(ctx); err != nil {
+		return err
+	}
+
+	// Stop the node once ctx is done, unless it stops before that.
+	goroutines.Go("node", func() {
+		select {
+		case <-ctx.Done():
+			if err := n.pv.Stop(); err != nil && !errors.Is(err, types.ErrAlreadyStopped) {
+				n.pv.Logger.Error("couldn't stop node: %v\n", err)
+			}
+		case <-n.pv.Quit():
+		}
+	})
+
+	return nil
+}
+
+// Stop stops the node and saves its state.
+func (n *Node) Stop() error {
+	return n.pv.Stop()
+}
+
+// Done returns a channel that is closed once the node is stopped.
+func (n *Node) Done() <-chan struct{} {
+	return n.pv.Quit()
+}
+
+// IsRunning returns true if the node is running.
+func (n *Node) IsRunning() bool {
+	return n.pv.IsRunning()
+}
+
+// Status returns the node's current height, rank and counter for missed blocks in a
+// row.
+func (n *Node) Status() privval.StatusResponse {
+	return n.pv.Status()
+}
+
+// UpdateFeatures replaces the node's feature flags with the given ones while it is
+// running.
+func (n *Node) UpdateFeatures(cfg config.Features) {
+	n.pv.Features.Update(cfg)
+}
+
+// SwapPrivValidator replaces the private validator the node signs with while it is
+// running. The swap is refused if the public keys of both private validators don't
+// match.
+func (n *Node) SwapPrivValidator(ctx context.Context, next tm_types.PrivValidator) error {
+	return n.pv.SwapPrivValidator(ctx, next)
+}
+
+// PrivValidator returns the node's underlying SCFilePV.
+func (n *Node) PrivValidator() *privval.SCFilePV {
+	return n.pv
+}
@@ -0,0 +1,174 @@
+package signctrl
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/internal/leaktest"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+)
+
+func testOptions(t *testing.T) Options {
+	t.Helper()
+	priv := tm_ed25519.GenPrivKey()
+	return Options{
+		Config: config.Config{
+			Base: config.Base{
+				LogLevel:                  "INFO",
+				SetSize:                   2,
+				Threshold:                 10,
+				StartRank:                 1,
+				ValidatorListenAddress:    "tcp://127.0.0.1:3000",
+				ValidatorListenAddressRPC: "tcp://127.0.0.1:26657",
+				RetryDialAfter:            "15s",
+			},
+			Privval: config.PrivValidator{
+				ChainID: "testchain",
+			},
+		},
+		State: config.State{
+			LastHeight: 1,
+			LastRank:   1,
+		},
+		CfgDir:        t.TempDir(),
+		PrivValidator: tm_privval.NewFilePV(priv, "", ""),
+	}
+}
+
+func TestNew_InvalidOptions(t *testing.T) {
+	opts := testOptions(t)
+	opts.Config.Base.SetSize = 0
+	node, err := New(opts)
+	assert.Nil(t, node)
+	assert.Error(t, err)
+
+	opts = testOptions(t)
+	opts.PrivValidator = nil
+	node, err = New(opts)
+	assert.Nil(t, node)
+	assert.ErrorIs(t, err, ErrNoPrivValidator)
+}
+
+func TestNode_StartStop(t *testing.T) {
+	leaktest.Check(t)
+
+	signerConn, validatorConn := net.Pipe()
+	defer validatorConn.Close()
+
+	opts := testOptions(t)
+	opts.Dialer = func(ctx context.Context) (net.Conn, error) {
+		return signerConn, nil
+	}
+	node, err := New(opts)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = node.Start(ctx)
+	assert.NoError(t, err)
+	assert.True(t, node.IsRunning())
+	assert.Equal(t, 1, node.Status().Rank)
+
+	// Act as the validator and ping the node.
+	w := tm_protoio.NewDelimitedWriter(validatorConn)
+	_, err = w.WriteMsg(&tm_privvalproto.Message{
+		Sum: &tm_privvalproto.Message_PingRequest{PingRequest: &tm_privvalproto.PingRequest{}},
+	})
+	assert.NoError(t, err)
+
+	var resp tm_privvalproto.Message
+	r := tm_protoio.NewDelimitedReader(validatorConn, 1024)
+	_, err = r.ReadMsg(&resp)
+	assert.NoError(t, err)
+	assert.NotNil(t, resp.GetPingResponse())
+
+	err = node.Stop()
+	assert.NoError(t, err)
+	<-node.Done()
+	assert.False(t, node.IsRunning())
+
+	state, err := config.LoadOrGenState(opts.CfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, state.LastRank)
+}
+
+func TestNode_ReconnectOnClose(t *testing.T) {
+	leaktest.Check(t)
+
+	conns := make(chan net.Conn, 2)
+	opts := testOptions(t)
+	opts.Dialer = func(ctx context.Context) (net.Conn, error) {
+		signerConn, validatorConn := net.Pipe()
+		conns <- validatorConn
+		return signerConn, nil
+	}
+	node, err := New(opts)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = node.Start(ctx)
+	assert.NoError(t, err)
+
+	// Close the first connection from the validator's side. The node must dial
+	// again right away instead of waiting for retry_dial_after to pass.
+	(<-conns).Close()
+	select {
+	case validatorConn := <-conns:
+		validatorConn.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("node didn't reconnect")
+	}
+
+	cancel()
+	<-node.Done()
+}
+
+func TestNode_ReconnectOnTimeout(t *testing.T) {
+	leaktest.Check(t)
+
+	conns := make(chan net.Conn, 2)
+	clock := types.NewFakeClock(time.Unix(0, 0))
+	opts := testOptions(t)
+	opts.Clock = clock
+	opts.Dialer = func(ctx context.Context) (net.Conn, error) {
+		signerConn, validatorConn := net.Pipe()
+		conns <- validatorConn
+		return signerConn, nil
+	}
+	node, err := New(opts)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = node.Start(ctx)
+	assert.NoError(t, err)
+	first := <-conns
+	defer first.Close()
+	assert.Eventually(t, func() bool {
+		return node.Status().Connection == privval.ConnConnected
+	}, 5*time.Second, time.Millisecond)
+
+	// Let retry_dial_after pass without the validator sending a message. The node
+	// must assume it lost the connection and dial again.
+	clock.BlockUntil(1)
+	clock.Advance(opts.Config.Base.GetRetryDialAfter())
+	select {
+	case validatorConn := <-conns:
+		validatorConn.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("node didn't reconnect")
+	}
+
+	cancel()
+	<-node.Done()
+}
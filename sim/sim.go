@@ -0,0 +1,439 @@
+// Package sim implements a deterministic simulation harness for the SignCTRL set. A
+// scenario describes the set, the chain and scripted node behavior on a per-height
+// basis. Running it drives the real request handling of every node in the set
+// against a simulated chain and a fake clock, and checks the set's safety invariants
+// (never two signers for the same height, never signing below a previously signed
+// height) along the way.
+package sim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	tm_prototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+const (
+	// ChainID is the chain ID used in simulations.
+	ChainID = "simchain"
+
+	// DefaultBlockTime is the block time used in simulations if the scenario doesn't
+	// specify one.
+	DefaultBlockTime = 5 * time.Second
+)
+
+// ActionType defines the type of scripted node behavior.
+type ActionType uint8
+
+const (
+	// ActionDisconnect disconnects the node from the validator, so it stops receiving
+	// sign requests.
+	ActionDisconnect ActionType = iota + 1
+
+	// ActionReconnect reconnects the node to the validator. Just like after a real
+	// reconnect, the node's counter for missed blocks in a row is locked.
+	ActionReconnect
+
+	// ActionPartition partitions the node from the network, so its signatures don't
+	// make it into blocks anymore while it keeps receiving sign requests.
+	ActionPartition
+
+	// ActionHeal heals a partition.
+	ActionHeal
+)
+
+// String returns the string representation of the action type.
+func (a ActionType) String() string {
+	switch a {
+	case ActionDisconnect:
+		return "Disconnect"
+	case ActionReconnect:
+		return "Reconnect"
+	case ActionPartition:
+		return "Partition"
+	case ActionHeal:
+		return "Heal"
+	}
+
+	return fmt.Sprintf("ActionType(%d)", uint8(a))
+}
+
+// Action defines scripted behavior of a node at a given height.
+type Action struct {
+	Height int64
+	Node   int
+	Type   ActionType
+}
+
+// Scenario defines a simulation run.
+type Scenario struct {
+	// SetSize is the number of nodes in the set. Node i starts with rank i+1.
+	SetSize int
+
+	// Threshold is the threshold of missed blocks in a row used by all nodes.
+	Threshold int
+
+	// Heights is the number of heights that are simulated.
+	Heights int64
+
+	// BlockTime is the time the fake clock is advanced by for each height.
+	BlockTime time.Duration
+
+	// Actions is the scripted node behavior.
+	Actions []Action
+}
+
+// RandomScenario generates a scenario from the given seed. The same seed always
+// yields the same scenario.
+func RandomScenario(seed int64, setSize, threshold int, heights int64) Scenario {
+	r := rand.New(rand.NewSource(seed))
+	s := Scenario{
+		SetSize:   setSize,
+		Threshold: threshold,
+		Heights:   heights,
+		BlockTime: DefaultBlockTime,
+	}
+	for h := int64(1); h <= heights; h++ {
+		// Script roughly one action every 20 heights.
+		if r.Intn(20) != 0 {
+			continue
+		}
+		s.Actions = append(s.Actions, Action{
+			Height: h,
+			Node:   r.Intn(setSize),
+			Type:   ActionType(1 + r.Intn(int(ActionHeal))),
+		})
+	}
+
+	return s
+}
+
+// Signature defines a signature produced by a node during a simulation.
+type Signature struct {
+	Node   int
+	Height int64
+	Round  int32
+	Type   tm_prototypes.SignedMsgType
+}
+
+// Violation defines a violated invariant.
+type Violation struct {
+	Height int64
+	Reason string
+}
+
+// Result defines the outcome of a simulation run.
+type Result struct {
+	// Signatures contains all signatures in the order they were produced.
+	Signatures []Signature
+
+	// Committed contains the heights whose commit contains the validator's
+	// signature.
+	Committed map[int64]bool
+
+	// Stopped contains the nodes that shut themselves down, mapped to the height they
+	// did so at.
+	Stopped map[int]int64
+
+	// Ranks contains the nodes' ranks at the end of the simulation.
+	Ranks []int
+
+	// Violations contains all violated invariants.
+	Violations []Violation
+}
+
+// Missed returns the number of heights whose commit doesn't contain the validator's
+// signature.
+func (r *Result) Missed(heights int64) int {
+	return int(heights) - len(r.Committed)
+}
+
+// SortedStopped returns the nodes that shut themselves down in ascending order.
+func (r *Result) SortedStopped() []int {
+	var nodes []int
+	for n := range r.Stopped {
+		nodes = append(nodes, n)
+	}
+	sort.Ints(nodes)
+
+	return nodes
+}
+
+// node defines a simulated node in the set.
+type node struct {
+	pv           *privval.SCFilePV
+	disconnected bool
+	partitioned  bool
+	stopped      bool
+	lastSigned   *Signature
+}
+
+// simulation defines the state of a running simulation.
+type simulation struct {
+	scenario Scenario
+	clock    *types.FakeClock
+	key      tm_crypto.PrivKey
+	nodes    []*node
+	signers  map[Signature]int
+	result   *Result
+}
+
+// Run runs the given scenario and returns its result.
+func Run(s Scenario) *Result {
+	if s.BlockTime == 0 {
+		s.BlockTime = DefaultBlockTime
+	}
+
+	sim := &simulation{
+		scenario: s,
+		clock:    types.NewFakeClock(time.Unix(0, 0).UTC()),
+		key:      tm_ed25519.GenPrivKeyFromSecret([]byte(ChainID)),
+		signers:  make(map[Signature]int),
+		result: &Result{
+			Committed: make(map[int64]bool),
+			Stopped:   make(map[int]int64),
+		},
+	}
+	for i := 0; i < s.SetSize; i++ {
+		sim.nodes = append(sim.nodes, sim.newNode(i))
+	}
+
+	actions := make(map[int64][]Action)
+	for _, a := range s.Actions {
+		actions[a.Height] = append(actions[a.Height], a)
+	}
+
+	for h := int64(1); h <= s.Heights; h++ {
+		sim.clock.Advance(s.BlockTime)
+		for _, a := range actions[h] {
+			sim.apply(a)
+		}
+		sim.height(h)
+	}
+
+	for _, n := range sim.nodes {
+		sim.result.Ranks = append(sim.result.Ranks, n.pv.GetRank())
+	}
+
+	return sim.result
+}
+
+// newNode creates the i-th node of the set.
+func (sim *simulation) newNode(i int) *node {
+	cfg := config.Config{
+		Base: config.Base{
+			LogLevel:                  "ERR",
+			SetSize:                   sim.scenario.SetSize,
+			Threshold:                 sim.scenario.Threshold,
+			StartRank:                 i + 1,
+			ValidatorListenAddress:    "tcp://127.0.0.1:3000",
+			ValidatorListenAddressRPC: "tcp://127.0.0.1:26657",
+			RetryDialAfter:            "15s",
+		},
+		Privval: config.PrivValidator{
+			ChainID: ChainID,
+		},
+	}
+	state := config.State{
+		LastHeight: 1,
+		LastRank:   i + 1,
+	}
+
+	n := &node{}
+	n.pv = privval.NewSCFilePV(
+		types.NewSyncLogger(ioutil.Discard, "", 0),
+		cfg,
+		state,
+		&signer{key: sim.key, onSign: func(sig Signature) { sim.signed(i, sig) }},
+		nil,
+	)
+	n.pv.QueryBlock = sim.queryBlock
+	// The simulation detects double-signing itself, which the high watermark would
+	// partly hide, and doesn't write any files.
+	n.pv.RaiseWatermark = func(privval.Watermark, []byte) error { return nil }
+	n.pv.Clock = sim.clock
+
+	return n
+}
+
+// apply applies the given action.
+func (sim *simulation) apply(a Action) {
+	if a.Node < 0 || a.Node >= len(sim.nodes) {
+		return
+	}
+
+	n := sim.nodes[a.Node]
+	switch a.Type {
+	case ActionDisconnect:
+		n.disconnected = true
+	case ActionReconnect:
+		if n.disconnected {
+			n.disconnected = false
+			n.pv.LockCounter()
+		}
+	case ActionPartition:
+		n.partitioned = true
+	case ActionHeal:
+		n.partitioned = false
+	}
+}
+
+// height sends the sign request for height h to all nodes.
+func (sim *simulation) height(h int64) {
+	for i, n := range sim.nodes {
+		if n.stopped || n.disconnected {
+			continue
+		}
+
+		resp, err := privval.HandleRequest(context.Background(), sim.signVoteRequest(h), n.pv)
+		if err != nil && (errors.Is(err, types.ErrMustShutdown) || errors.Is(err, privval.ErrRankObsolete)) {
+			n.stopped = true
+			sim.result.Stopped[i] = h
+			continue
+		}
+		if resp.GetSignedVoteResponse().GetError() == nil && !n.partitioned {
+			sim.result.Committed[h] = true
+		}
+	}
+}
+
+// signVoteRequest returns a SignVoteRequest for a precommit at height h.
+func (sim *simulation) signVoteRequest(h int64) *tm_privvalproto.Message {
+	return &tm_privvalproto.Message{
+		Sum: &tm_privvalproto.Message_SignVoteRequest{
+			SignVoteRequest: &tm_privvalproto.SignVoteRequest{
+				Vote: &tm_prototypes.Vote{
+					Type:             tm_prototypes.PrecommitType,
+					Height:           h,
+					Timestamp:        sim.clock.Now(),
+					ValidatorAddress: sim.key.PubKey().Address(),
+				},
+				ChainId: ChainID,
+			},
+		},
+	}
+}
+
+// signed records a signature produced by node i and checks it against the
+// invariants.
+func (sim *simulation) signed(i int, sig Signature) {
+	if other, ok := sim.signers[sig]; ok && other != i {
+		sim.violation(sig.Height, "double-signed %v at round %v by nodes %v and %v", sig.Type, sig.Round, other, i)
+	}
+	sim.signers[sig] = i
+	sig.Node = i
+
+	n := sim.nodes[i]
+	if last := n.lastSigned; last != nil && !isAbove(sig, *last) {
+		sim.violation(sig.Height, "node %v signed %v/%v/%v at or below its last signature at %v/%v/%v", i, sig.Height, sig.Round, sig.Type, last.Height, last.Round, last.Type)
+	}
+	n.lastSigned = &sig
+
+	sim.result.Signatures = append(sim.result.Signatures, sig)
+}
+
+// violation records a violated invariant.
+func (sim *simulation) violation(h int64, format string, v ...interface{}) {
+	sim.result.Violations = append(sim.result.Violations, Violation{
+		Height: h,
+		Reason: fmt.Sprintf(format, v...),
+	})
+}
+
+// queryBlock returns the simulated block at the given height. Just like on a real
+// chain, the block's last commit contains the signatures for the previous height.
+// Implements privval.BlockQuerier.
+func (sim *simulation) queryBlock(ctx context.Context, height int64) (*tm_coretypes.ResultBlock, error) {
+	var sigs []tm_types.CommitSig
+	if sim.result.Committed[height-1] {
+		sigs = append(sigs, tm_types.CommitSig{
+			BlockIDFlag:      tm_types.BlockIDFlagCommit,
+			ValidatorAddress: sim.key.PubKey().Address(),
+			Timestamp:        sim.clock.Now(),
+		})
+	}
+
+	return &tm_coretypes.ResultBlock{
+		Block: &tm_types.Block{
+			Header:     tm_types.Header{ChainID: ChainID, Height: height},
+			LastCommit: &tm_types.Commit{Height: height - 1, Signatures: sigs},
+		},
+	}, nil
+}
+
+// isAbove returns true if sig is for a later height/round/step than last.
+func isAbove(sig, last Signature) bool {
+	if sig.Height != last.Height {
+		return sig.Height > last.Height
+	}
+	if sig.Round != last.Round {
+		return sig.Round > last.Round
+	}
+
+	return step(sig.Type) > step(last.Type)
+}
+
+// step maps the signed message types to their order within a round.
+func step(t tm_prototypes.SignedMsgType) int {
+	switch t {
+	case tm_prototypes.ProposalType:
+		return 1
+	case tm_prototypes.PrevoteType:
+		return 2
+	case tm_prototypes.PrecommitType:
+		return 3
+	}
+
+	return 0
+}
+
+// signer is a private validator that reports every signature to the simulation. It
+// doesn't persist a sign state in order to keep simulations fast, but its signatures
+// are real, as SignCTRL refuses to send signatures that don't verify.
+// Implements tm_types.PrivValidator.
+type signer struct {
+	key    tm_crypto.PrivKey
+	onSign func(Signature)
+}
+
+// GetPubKey returns the validator's public key.
+func (s *signer) GetPubKey() (tm_crypto.PubKey, error) {
+	return s.key.PubKey(), nil
+}
+
+// SignVote signs the given vote.
+func (s *signer) SignVote(chainID string, vote *tm_prototypes.Vote) error {
+	sig, err := s.key.Sign(tm_types.VoteSignBytes(chainID, vote))
+	if err != nil {
+		return err
+	}
+	vote.Signature = sig
+	s.onSign(Signature{Height: vote.Height, Round: vote.Round, Type: vote.Type})
+
+	return nil
+}
+
+// SignProposal signs the given proposal.
+func (s *signer) SignProposal(chainID string, proposal *tm_prototypes.Proposal) error {
+	sig, err := s.key.Sign(tm_types.ProposalSignBytes(chainID, proposal))
+	if err != nil {
+		return err
+	}
+	proposal.Signature = sig
+	s.onSign(Signature{Height: proposal.Height, Round: proposal.Round, Type: proposal.Type})
+
+	return nil
+}
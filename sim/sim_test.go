@@ -0,0 +1,88 @@
+package sim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun_HappyPath(t *testing.T) {
+	res := Run(Scenario{SetSize: 3, Threshold: 5, Heights: 100})
+	assert.Empty(t, res.Violations)
+	assert.Equal(t, 0, res.Missed(100))
+	assert.Empty(t, res.Stopped)
+	assert.Equal(t, []int{1, 2, 3}, res.Ranks)
+	for _, sig := range res.Signatures {
+		assert.Equal(t, 0, sig.Node)
+	}
+}
+
+func TestRun_Failover(t *testing.T) {
+	// Node 0 (rank 1) disconnects for good, so node 1 (rank 2) takes over.
+	res := Run(Scenario{
+		SetSize:   3,
+		Threshold: 5,
+		Heights:   100,
+		Actions: []Action{
+			{Height: 10, Node: 0, Type: ActionDisconnect},
+		},
+	})
+	assert.Empty(t, res.Violations)
+	assert.Equal(t, []int{1, 1, 2}, res.Ranks)
+	assert.Equal(t, 1, res.Signatures[len(res.Signatures)-1].Node)
+	assert.Greater(t, res.Missed(100), 0)
+}
+
+func TestRun_ObsoleteRank(t *testing.T) {
+	// Node 0 (rank 1) disconnects and reconnects after the rest of the set has moved
+	// on, so it must shut down.
+	res := Run(Scenario{
+		SetSize:   2,
+		Threshold: 5,
+		Heights:   100,
+		Actions: []Action{
+			{Height: 10, Node: 0, Type: ActionDisconnect},
+			{Height: 50, Node: 0, Type: ActionReconnect},
+		},
+	})
+	assert.Empty(t, res.Violations)
+	assert.Equal(t, int64(50), res.Stopped[0])
+	assert.Equal(t, []int{0}, res.SortedStopped())
+}
+
+func TestRun_Partition(t *testing.T) {
+	// Node 0's (rank 1) signatures don't make it into blocks anymore, so it must shut
+	// down while node 1 (rank 2) takes over.
+	res := Run(Scenario{
+		SetSize:   2,
+		Threshold: 5,
+		Heights:   100,
+		Actions: []Action{
+			{Height: 10, Node: 0, Type: ActionPartition},
+		},
+	})
+	assert.Empty(t, res.Violations)
+	assert.Contains(t, res.Stopped, 0)
+	assert.Equal(t, 1, res.Ranks[1])
+	assert.Equal(t, 1, res.Signatures[len(res.Signatures)-1].Node)
+}
+
+func TestRun_Deterministic(t *testing.T) {
+	s := RandomScenario(42, 3, 5, 200)
+	assert.Equal(t, s, RandomScenario(42, 3, 5, 200))
+	assert.Equal(t, Run(s), Run(s))
+}
+
+func TestRun_RandomScenarios(t *testing.T) {
+	scenarios := 500
+	if testing.Short() {
+		scenarios = 50
+	}
+
+	for seed := int64(0); seed < int64(scenarios); seed++ {
+		s := RandomScenario(seed, 2+int(seed%3), 2+int(seed%7), 200)
+		if res := Run(s); len(res.Violations) > 0 {
+			t.Fatalf("seed %v: %v", seed, res.Violations)
+		}
+	}
+}
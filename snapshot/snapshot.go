@@ -0,0 +1,515 @@
+// Package snapshot bundles the identity of a SignCTRL node, i.e. its configuration,
+// keys, watermark and rank, into a single file, so that a failed machine can be
+// replaced by applying the snapshot on a new one. A snapshot can only be applied once,
+// and the node it was created from refuses to start afterwards, so that no two
+// machines ever sign with the same identity.
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/statefile"
+	"github.com/BlockscapeNetwork/signctrl/statemac"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	"github.com/spf13/viper"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+const (
+	// FormatVersion is the version of the snapshot format.
+	FormatVersion = 1
+
+	// ManifestFile is the name of the manifest in the snapshot.
+	ManifestFile = "manifest.json"
+
+	// FenceFile is the name of the file written to the configuration directory a
+	// snapshot was created from. SignCTRL refuses to start as long as it exists.
+	FenceFile = "snapshot_fence.json"
+
+	// AppliedSuffix is appended to the path of a snapshot to get the path of the
+	// marker that is written once the snapshot is applied.
+	AppliedSuffix = ".applied"
+
+	// PermSnapshotFile determines the file permissions of snapshots and their
+	// markers, as snapshots contain the validator's private key.
+	PermSnapshotFile = os.FileMode(0600)
+
+	// precommitStep is the step of precommits in the priv_validator_state.json, which
+	// is the last step of a round.
+	precommitStep = 3
+)
+
+var (
+	// ErrUnsupportedVersion is returned if a snapshot has a format version this
+	// version of SignCTRL doesn't support.
+	ErrUnsupportedVersion = errors.New("unsupported snapshot version")
+
+	// ErrCorrupted is returned if the contents of a snapshot don't match its manifest.
+	ErrCorrupted = errors.New("snapshot is corrupted")
+
+	// ErrAlreadyApplied is returned if a snapshot is applied a second time.
+	ErrAlreadyApplied = errors.New("snapshot has already been applied")
+
+	// ErrIdentityExists is returned if a snapshot is applied to a configuration
+	// directory that already contains a validator key.
+	ErrIdentityExists = errors.New("configuration directory already contains a validator key")
+
+	// ErrFenced is returned if the node's identity was exported to a snapshot.
+	ErrFenced = errors.New("node identity was exported to a snapshot")
+
+	// ErrStillRunning is returned if the node that is replaced by a snapshot is still
+	// running.
+	ErrStillRunning = errors.New("replaced node is still running")
+)
+
+// Watermark is the last sign state of the validator's key in a snapshot.
+type Watermark struct {
+	Height int64 `json:"height"`
+	Round  int32 `json:"round"`
+	Step   int8  `json:"step"`
+}
+
+// File is a file of the node's identity in a snapshot.
+type File struct {
+	// Path is the file's path relative to the configuration directory, using
+	// forward slashes.
+	Path string `json:"path"`
+
+	// Mode are the file's permissions.
+	Mode os.FileMode `json:"mode"`
+
+	// SHA256 is the hex-encoded SHA-256 hash of the file's contents.
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest describes the contents of a snapshot.
+type Manifest struct {
+	Version   int       `json:"version"`
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ChainID   string    `json:"chain_id"`
+
+	// ValidatorRPC is the validator_laddr_rpc of the node's configuration, which is
+	// used to check how far the chain has moved on when the snapshot is applied.
+	ValidatorRPC string `json:"validator_laddr_rpc"`
+
+	Watermark Watermark `json:"watermark"`
+	Rank      int       `json:"rank"`
+	Files     []File    `json:"files"`
+}
+
+// Fence defines the contents of the snapshot_fence.json file.
+type Fence struct {
+	SnapshotID string    `json:"snapshot_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Snapshot is a verified snapshot that is ready to be applied.
+type Snapshot struct {
+	Manifest Manifest
+
+	path  string
+	files map[string][]byte
+}
+
+// ApplyOptions are the options for applying a snapshot.
+type ApplyOptions struct {
+	// Force allows overwriting an existing validator key in the configuration
+	// directory.
+	Force bool
+
+	// MinHeight makes sure the validator never signs anything at or below the given
+	// height by raising the watermark, e.g. to the heights the replaced machine may
+	// have signed after the snapshot was created.
+	MinHeight int64
+}
+
+// FencePath returns the absolute path to the snapshot_fence.json file.
+func FencePath(cfgDir string) string {
+	return filepath.Join(cfgDir, FenceFile)
+}
+
+// LoadFence loads the snapshot_fence.json file from the configuration directory. It
+// returns nil if there is none.
+func LoadFence(cfgDir string) (*Fence, error) {
+	bytes, err := ioutil.ReadFile(FencePath(cfgDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var fence Fence
+	if err := tm_json.Unmarshal(bytes, &fence); err != nil {
+		return nil, err
+	}
+
+	return &fence, nil
+}
+
+// CheckFence returns ErrFenced if the identity in the configuration directory was
+// exported to a snapshot.
+func CheckFence(cfgDir string) error {
+	fence, err := LoadFence(cfgDir)
+	if err != nil {
+		return err
+	}
+	if fence != nil {
+		return fmt.Errorf("%w %v at %v, remove %v to start anyway", ErrFenced, fence.SnapshotID, fence.CreatedAt.Format(time.RFC3339), FencePath(cfgDir))
+	}
+
+	return nil
+}
+
+// CheckReplaced returns ErrStillRunning if the SignCTRL node whose HTTP server is at
+// the given address, e.g. http://10.0.0.1:8080, reports that it is running. A node
+// that can't be reached is considered to be down.
+func CheckReplaced(ctx context.Context, addr string) error {
+	status, err := watchtower.NewClient(addr).Status(ctx)
+	if err != nil {
+		return nil
+	}
+	if status.Running {
+		return fmt.Errorf("%w: %v reports rank %v at height %v", ErrStillRunning, addr, status.Rank, status.Height)
+	}
+
+	return nil
+}
+
+// identityFiles returns the paths of the files that make up the node's identity,
+// relative to the configuration directory.
+func identityFiles(cfgDir string) ([]string, error) {
+	files := []string{config.File, privval.KeyFile, privval.StateFile}
+	optionals := []string{
+		config.StateFile,
+		statefile.SumPath(config.StateFile),
+		connection.KeyFile,
+		statemac.KeyFile,
+		statemac.Path(privval.StateFile),
+		statemac.Path(config.StateFile),
+	}
+	for _, optional := range optionals {
+		if _, err := os.Stat(filepath.Join(cfgDir, optional)); err == nil {
+			files = append(files, optional)
+		}
+	}
+
+	// Include the keys and watermarks of all consumer chains.
+	consumersDir := filepath.Join(cfgDir, privval.ConsumersDir)
+	err := filepath.Walk(consumersDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			rel, err := filepath.Rel(cfgDir, p)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+// newID returns a new random snapshot ID.
+func newID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// Create writes a snapshot of the node's identity in the configuration directory to
+// w and fences the configuration directory, so that SignCTRL refuses to start from
+// it afterwards. SignCTRL must not be running while the snapshot is created.
+func Create(cfgDir string, w io.Writer) (Manifest, error) {
+	id, err := newID()
+	if err != nil {
+		return Manifest{}, err
+	}
+	m := Manifest{
+		Version:   FormatVersion,
+		ID:        id,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	// Read the chain ID and the validator's RPC address from the configuration file.
+	v := viper.New()
+	v.SetConfigFile(config.FilePath(cfgDir))
+	if err := v.ReadInConfig(); err != nil {
+		return Manifest{}, err
+	}
+	m.ChainID = v.GetString("privval.chain_id")
+	m.ValidatorRPC = v.GetString("base.validator_laddr_rpc")
+
+	paths, err := identityFiles(cfgDir)
+	if err != nil {
+		return Manifest{}, err
+	}
+	contents := make(map[string][]byte, len(paths))
+	for _, p := range paths {
+		full := filepath.Join(cfgDir, filepath.FromSlash(p))
+		info, err := os.Stat(full)
+		if err != nil {
+			return Manifest{}, err
+		}
+		bytes, err := ioutil.ReadFile(full)
+		if err != nil {
+			return Manifest{}, err
+		}
+		sum := sha256.Sum256(bytes)
+		m.Files = append(m.Files, File{Path: p, Mode: info.Mode().Perm(), SHA256: hex.EncodeToString(sum[:])})
+		contents[p] = bytes
+	}
+
+	// Record the watermark and the rank, so that they can be checked before the
+	// snapshot is applied.
+	var lss tm_privval.FilePVLastSignState
+	if err := tm_json.Unmarshal(contents[privval.StateFile], &lss); err != nil {
+		return Manifest{}, fmt.Errorf("couldn't read %v: %w", privval.StateFile, err)
+	}
+	m.Watermark = Watermark{Height: lss.Height, Round: lss.Round, Step: lss.Step}
+	if bytes, ok := contents[config.StateFile]; ok {
+		var state config.State
+		if err := tm_json.Unmarshal(bytes, &state); err != nil {
+			return Manifest{}, fmt.Errorf("couldn't read %v: %w", config.StateFile, err)
+		}
+		m.Rank = state.LastRank
+	}
+
+	if err := write(w, m, contents); err != nil {
+		return Manifest{}, err
+	}
+
+	// Fence the configuration directory only once the snapshot was written.
+	fence, err := tm_json.MarshalIndent(&Fence{SnapshotID: m.ID, CreatedAt: m.CreatedAt}, "", "\t")
+	if err != nil {
+		return Manifest{}, err
+	}
+	if err := ioutil.WriteFile(FencePath(cfgDir), fence, PermSnapshotFile); err != nil {
+		return Manifest{}, err
+	}
+
+	return m, nil
+}
+
+// write writes the manifest and the files' contents as a gzipped tar archive to w.
+func write(w io.Writer, m Manifest, contents map[string][]byte) error {
+	manifest, err := tm_json.MarshalIndent(&m, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	entries := append([]File{{Path: ManifestFile, Mode: PermSnapshotFile}}, m.Files...)
+	for _, f := range entries {
+		bytes := manifest
+		if f.Path != ManifestFile {
+			bytes = contents[f.Path]
+		}
+		hdr := &tar.Header{
+			Name:    f.Path,
+			Mode:    int64(f.Mode),
+			Size:    int64(len(bytes)),
+			ModTime: m.CreatedAt,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(bytes); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+// Open reads the snapshot at the given path and verifies its contents against its
+// manifest.
+func Open(p string) (*Snapshot, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+	tr := tar.NewReader(gr)
+	files := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+		}
+		bytes, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+		}
+		files[hdr.Name] = bytes
+	}
+
+	manifest, ok := files[ManifestFile]
+	if !ok {
+		return nil, fmt.Errorf("%w: no %v", ErrCorrupted, ManifestFile)
+	}
+	delete(files, ManifestFile)
+	var m Manifest
+	if err := tm_json.Unmarshal(manifest, &m); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+	if m.Version != FormatVersion {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedVersion, m.Version)
+	}
+
+	s := &Snapshot{Manifest: m, path: p, files: files}
+	if err := s.verify(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// verify checks that the snapshot contains exactly the files listed in its manifest,
+// that their contents match their hashes and that they stay within the
+// configuration directory.
+func (s *Snapshot) verify() error {
+	if len(s.files) != len(s.Manifest.Files) {
+		return fmt.Errorf("%w: expected %v files, instead got %v", ErrCorrupted, len(s.Manifest.Files), len(s.files))
+	}
+	for _, f := range s.Manifest.Files {
+		clean := path.Clean(f.Path)
+		if clean != f.Path || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("%w: invalid path %v", ErrCorrupted, f.Path)
+		}
+		bytes, ok := s.files[f.Path]
+		if !ok {
+			return fmt.Errorf("%w: %v is missing", ErrCorrupted, f.Path)
+		}
+		sum := sha256.Sum256(bytes)
+		if hex.EncodeToString(sum[:]) != f.SHA256 {
+			return fmt.Errorf("%w: checksum of %v doesn't match", ErrCorrupted, f.Path)
+		}
+	}
+	for _, required := range []string{config.File, privval.KeyFile, privval.StateFile} {
+		if _, ok := s.files[required]; !ok {
+			return fmt.Errorf("%w: %v is missing", ErrCorrupted, required)
+		}
+	}
+
+	return nil
+}
+
+// Paths returns the sorted paths of the files in the snapshot.
+func (s *Snapshot) Paths() []string {
+	paths := make([]string, 0, len(s.files))
+	for p := range s.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+// AppliedPath returns the path to the marker that is written once the snapshot is
+// applied.
+func (s *Snapshot) AppliedPath() string {
+	return s.path + AppliedSuffix
+}
+
+// Apply writes the node's identity in the snapshot to the configuration directory.
+// The snapshot is marked as applied before anything is written, so that it can never
+// be applied twice, not even if applying it fails halfway.
+func (s *Snapshot) Apply(cfgDir string, opts ApplyOptions) error {
+	if _, err := os.Stat(s.AppliedPath()); err == nil {
+		return fmt.Errorf("%w (see %v)", ErrAlreadyApplied, s.AppliedPath())
+	}
+	if _, err := os.Stat(privval.KeyFilePath(cfgDir)); err == nil && !opts.Force {
+		return fmt.Errorf("%w: %v", ErrIdentityExists, privval.KeyFilePath(cfgDir))
+	}
+
+	// Claim the snapshot. Creating the marker fails if it was claimed in the meantime.
+	marker, err := tm_json.MarshalIndent(&Fence{SnapshotID: s.Manifest.ID, CreatedAt: time.Now().UTC()}, "", "\t")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.AppliedPath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, PermSnapshotFile)
+	if os.IsExist(err) {
+		return fmt.Errorf("%w (see %v)", ErrAlreadyApplied, s.AppliedPath())
+	} else if err != nil {
+		return err
+	}
+	_, err = f.Write(marker)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(cfgDir, config.PermConfigDir); err != nil {
+		return err
+	}
+	for _, file := range s.Manifest.Files {
+		full := filepath.Join(cfgDir, filepath.FromSlash(file.Path))
+		if err := os.MkdirAll(filepath.Dir(full), config.PermConfigDir); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(full, s.files[file.Path], file.Mode); err != nil {
+			return err
+		}
+		if err := os.Chmod(full, file.Mode); err != nil {
+			return err
+		}
+	}
+
+	// The configuration directory now holds the only copy of the identity in use.
+	if err := os.Remove(FencePath(cfgDir)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Never sign anything at or below the minimum height.
+	if opts.MinHeight > 0 {
+		w := privval.Watermark{Height: opts.MinHeight, Round: math.MaxInt32, Step: precommitStep}
+		if _, err := privval.RaiseWatermark(privval.StateFilePath(cfgDir), w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/statefile"
+	"github.com/BlockscapeNetwork/signctrl/watchtower"
+	"github.com/stretchr/testify/assert"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+// testIdentity creates the identity of a node in a new configuration directory.
+func testIdentity(t *testing.T) string {
+	t.Helper()
+	cfgDir := t.TempDir()
+	assert.NoError(t, config.Create(cfgDir))
+	assert.NoError(t, config.Override(cfgDir, map[string]interface{}{"privval.chain_id": "testchain"}))
+	filePV := tm_privval.GenFilePV(privval.KeyFilePath(cfgDir), privval.StateFilePath(cfgDir))
+	filePV.LastSignState.Height = 10
+	filePV.LastSignState.Step = 2
+	filePV.Save()
+	state := config.State{LastHeight: 10, LastRank: 2}
+	assert.NoError(t, state.Save(cfgDir))
+	assert.NoError(t, connection.CreateBase64ConnKey(cfgDir))
+
+	consumerDir := privval.ConsumerDir(cfgDir, "consumer")
+	assert.NoError(t, os.MkdirAll(consumerDir, config.PermConfigDir))
+	assert.NoError(t, ioutil.WriteFile(privval.StateFilePath(consumerDir), []byte("{}"), 0600))
+
+	return cfgDir
+}
+
+// testSnapshot creates a snapshot of the identity in cfgDir and returns its path.
+func testSnapshot(t *testing.T, cfgDir string) (string, Manifest) {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "snapshot.tar.gz")
+	f, err := os.Create(p)
+	assert.NoError(t, err)
+	m, err := Create(cfgDir, f)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	return p, m
+}
+
+func TestCreate(t *testing.T) {
+	cfgDir := testIdentity(t)
+	assert.NoError(t, CheckFence(cfgDir))
+
+	_, m := testSnapshot(t, cfgDir)
+	assert.Equal(t, FormatVersion, m.Version)
+	assert.Len(t, m.ID, 32)
+	assert.Equal(t, "testchain", m.ChainID)
+	assert.Equal(t, "tcp://127.0.0.1:26657", m.ValidatorRPC)
+	assert.Equal(t, Watermark{Height: 10, Step: 2}, m.Watermark)
+	assert.Equal(t, 2, m.Rank)
+
+	var paths []string
+	for _, f := range m.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.ElementsMatch(t, []string{
+		config.File, privval.KeyFile, privval.StateFile, config.StateFile, statefile.SumPath(config.StateFile),
+		connection.KeyFile, "consumers/consumer/" + privval.StateFile,
+	}, paths)
+
+	// The node refuses to start from the configuration directory afterwards.
+	fence, err := LoadFence(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, m.ID, fence.SnapshotID)
+	assert.True(t, errors.Is(CheckFence(cfgDir), ErrFenced))
+}
+
+func TestApply(t *testing.T) {
+	src := testIdentity(t)
+	p, m := testSnapshot(t, src)
+
+	s, err := Open(p)
+	assert.NoError(t, err)
+	assert.Equal(t, m.ID, s.Manifest.ID)
+
+	dst := filepath.Join(t.TempDir(), "signctrl")
+	assert.NoError(t, s.Apply(dst, ApplyOptions{}))
+	for _, f := range m.Files {
+		want, err := ioutil.ReadFile(filepath.Join(src, filepath.FromSlash(f.Path)))
+		assert.NoError(t, err)
+		got, err := ioutil.ReadFile(filepath.Join(dst, filepath.FromSlash(f.Path)))
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, f.Path)
+
+		info, err := os.Stat(filepath.Join(dst, filepath.FromSlash(f.Path)))
+		assert.NoError(t, err)
+		assert.Equal(t, f.Mode, info.Mode().Perm(), f.Path)
+	}
+	assert.NoError(t, CheckFence(dst))
+
+	// A snapshot can only be applied once.
+	err = s.Apply(t.TempDir(), ApplyOptions{})
+	assert.True(t, errors.Is(err, ErrAlreadyApplied))
+	s, err = Open(p)
+	assert.NoError(t, err)
+	err = s.Apply(t.TempDir(), ApplyOptions{})
+	assert.True(t, errors.Is(err, ErrAlreadyApplied))
+}
+
+func TestApply_IdentityExists(t *testing.T) {
+	src := testIdentity(t)
+	p, _ := testSnapshot(t, src)
+	s, err := Open(p)
+	assert.NoError(t, err)
+
+	// The snapshot must not be claimed if it isn't applied.
+	dst := testIdentity(t)
+	assert.True(t, errors.Is(s.Apply(dst, ApplyOptions{}), ErrIdentityExists))
+	assert.NoFileExists(t, s.AppliedPath())
+
+	assert.NoError(t, s.Apply(dst, ApplyOptions{Force: true}))
+}
+
+func TestApply_MinHeight(t *testing.T) {
+	p, _ := testSnapshot(t, testIdentity(t))
+	s, err := Open(p)
+	assert.NoError(t, err)
+
+	dst := t.TempDir()
+	assert.NoError(t, s.Apply(dst, ApplyOptions{MinHeight: 20}))
+	bytes, err := ioutil.ReadFile(privval.StateFilePath(dst))
+	assert.NoError(t, err)
+	var lss tm_privval.FilePVLastSignState
+	assert.NoError(t, tm_json.Unmarshal(bytes, &lss))
+	assert.Equal(t, int64(20), lss.Height)
+	assert.Equal(t, int8(precommitStep), lss.Step)
+}
+
+func TestOpen_Corrupted(t *testing.T) {
+	src := testIdentity(t)
+	paths, err := identityFiles(src)
+	assert.NoError(t, err)
+	contents := make(map[string][]byte)
+	for _, p := range paths {
+		contents[p], err = ioutil.ReadFile(filepath.Join(src, filepath.FromSlash(p)))
+		assert.NoError(t, err)
+	}
+
+	writeSnapshot := func(m Manifest, contents map[string][]byte) string {
+		var buf bytes.Buffer
+		assert.NoError(t, write(&buf, m, contents))
+		p := filepath.Join(t.TempDir(), "snapshot.tar.gz")
+		assert.NoError(t, ioutil.WriteFile(p, buf.Bytes(), PermSnapshotFile))
+		return p
+	}
+
+	// The checksum doesn't match.
+	m := Manifest{Version: FormatVersion}
+	for _, p := range paths {
+		m.Files = append(m.Files, File{Path: p, Mode: 0600, SHA256: "00"})
+	}
+	_, err = Open(writeSnapshot(m, contents))
+	assert.True(t, errors.Is(err, ErrCorrupted))
+
+	// The path escapes the configuration directory.
+	m = Manifest{Version: FormatVersion, Files: []File{{Path: "../" + privval.KeyFile}}}
+	_, err = Open(writeSnapshot(m, map[string][]byte{"../" + privval.KeyFile: nil}))
+	assert.True(t, errors.Is(err, ErrCorrupted))
+
+	m = Manifest{Version: FormatVersion + 1}
+	_, err = Open(writeSnapshot(m, nil))
+	assert.True(t, errors.Is(err, ErrUnsupportedVersion))
+
+	p := filepath.Join(t.TempDir(), "snapshot.tar.gz")
+	assert.NoError(t, ioutil.WriteFile(p, []byte("not a snapshot"), PermSnapshotFile))
+	_, err = Open(p)
+	assert.True(t, errors.Is(err, ErrCorrupted))
+}
+
+func TestCheckReplaced(t *testing.T) {
+	running := true
+	server := httptest.NewServer(watchtower.NewHandler(func() watchtower.Status {
+		return watchtower.Status{Running: running, Rank: 1}
+	}, watchtower.NewEventLog(1)))
+	defer server.Close()
+
+	assert.True(t, errors.Is(CheckReplaced(context.Background(), server.URL), ErrStillRunning))
+	running = false
+	assert.NoError(t, CheckReplaced(context.Background(), server.URL))
+
+	// Unreachable nodes are considered to be down.
+	assert.NoError(t, CheckReplaced(context.Background(), "http://127.0.0.1:1"))
+}
@@ -0,0 +1,203 @@
+// Package statefile writes SignCTRL's state files, e.g. the rank state and the high
+// watermark, so that they survive crashes and power losses. A file is replaced
+// atomically by renaming a synced temporary file over it, and it's accompanied by a
+// <file>.sha256 file holding the checksum of its contents. The previous version of a
+// file is kept as <file>.bak, which is loaded instead if the file itself turns out
+// to be corrupted.
+package statefile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// SumExt is appended to the path of a state file to get the path of its
+	// checksum.
+	SumExt = ".sha256"
+
+	// BackupExt is appended to the path of a state file to get the path of its
+	// backup.
+	BackupExt = ".bak"
+)
+
+// ErrChecksum is returned if the contents of a state file don't match its checksum.
+var ErrChecksum = errors.New("state file doesn't match its checksum")
+
+// SumPath returns the path to the checksum of the given file.
+func SumPath(file string) string {
+	return file + SumExt
+}
+
+// BackupPath returns the path to the backup of the given file.
+func BackupPath(file string) string {
+	return file + BackupExt
+}
+
+// checksum returns the hex-encoded SHA-256 checksum of data.
+func checksum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return []byte(hex.EncodeToString(sum[:]))
+}
+
+// read reads the given file and checks it against its checksum. Files without a
+// checksum, e.g. written by older versions, are taken as they are.
+func read(file string) ([]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := ioutil.ReadFile(SumPath(file))
+	if os.IsNotExist(err) {
+		return data, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(bytes.TrimSpace(sum), checksum(data)) {
+		return nil, fmt.Errorf("%w: %v", ErrChecksum, file)
+	}
+
+	return data, nil
+}
+
+// Corrupted returns true if the given file exists, but doesn't match its checksum, in
+// which case Load decodes its backup instead.
+func Corrupted(file string) bool {
+	_, err := read(file)
+	return errors.Is(err, ErrChecksum)
+}
+
+// Load reads the given file and passes its contents to decode. If the file is
+// corrupted, i.e. it doesn't match its checksum or decode fails, its backup is
+// decoded instead and true is returned. The returned error satisfies os.IsNotExist
+// if the file doesn't exist. A missing file is never replaced by its backup, as
+// deleting a state file is the way to reset it.
+func Load(file string, decode func(data []byte) error) (recovered bool, err error) {
+	data, err := read(file)
+	if os.IsNotExist(err) {
+		return false, err
+	}
+	if err == nil {
+		if err = decode(data); err == nil {
+			return false, nil
+		}
+	}
+
+	bak, bakErr := read(BackupPath(file))
+	if bakErr != nil {
+		return false, err
+	}
+	if bakErr := decode(bak); bakErr != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Write replaces the contents of the given file with data. The current contents are
+// kept as the file's backup first, unless they are corrupted, in which case the
+// existing backup is kept.
+func Write(file string, data []byte, perm os.FileMode) error {
+	if err := rotate(file); err != nil {
+		return err
+	}
+	if err := writeSynced(file, data, perm); err != nil {
+		return err
+	}
+	if err := writeSynced(SumPath(file), append(checksum(data), '\n'), perm); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(file))
+}
+
+// rotate keeps the current contents of the given file and their checksum as its
+// backup. Hard links are used, so that nothing is copied. A backup left behind by a
+// deleted file is removed.
+func rotate(file string) error {
+	if _, err := read(file); os.IsNotExist(err) {
+		if err := removeIfExists(BackupPath(file)); err != nil {
+			return err
+		}
+		return removeIfExists(SumPath(BackupPath(file)))
+	} else if err != nil {
+		// Don't replace a good backup with a corrupted file.
+		return nil
+	}
+
+	if err := link(file, BackupPath(file)); err != nil {
+		return err
+	}
+	if _, err := os.Stat(SumPath(file)); os.IsNotExist(err) {
+		return removeIfExists(SumPath(BackupPath(file)))
+	}
+
+	return link(SumPath(file), SumPath(BackupPath(file)))
+}
+
+// link atomically replaces newname with a hard link to oldname.
+func link(oldname, newname string) error {
+	tmp := newname + ".tmp"
+	if err := removeIfExists(tmp); err != nil {
+		return err
+	}
+	if err := os.Link(oldname, tmp); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, newname)
+}
+
+// removeIfExists removes the given file if it exists.
+func removeIfExists(file string) error {
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// writeSynced writes data to a temporary file, syncs it and renames it to file, so
+// that file is never left partially written.
+func writeSynced(file string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
+
+// syncDir syncs the given directory, so that the files renamed into it survive a
+// crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,117 @@
+package statefile
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testState struct {
+	Height int64 `json:"height"`
+}
+
+// load loads the testState in file.
+func load(file string) (testState, bool, error) {
+	var s testState
+	recovered, err := Load(file, func(data []byte) error {
+		s = testState{}
+		return json.Unmarshal(data, &s)
+	})
+
+	return s, recovered, err
+}
+
+// write writes a testState at the given height to file.
+func write(t *testing.T, file string, height int64) {
+	t.Helper()
+	bz, err := json.Marshal(testState{Height: height})
+	require.NoError(t, err)
+	require.NoError(t, Write(file, bz, 0600))
+}
+
+func TestWriteAndLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state.json")
+	_, _, err := load(file)
+	assert.True(t, os.IsNotExist(err))
+
+	write(t, file, 1)
+	write(t, file, 2)
+	s, recovered, err := load(file)
+	require.NoError(t, err)
+	assert.False(t, recovered)
+	assert.Equal(t, int64(2), s.Height)
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The previous version is kept as the backup.
+	bak, _, err := load(BackupPath(file))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), bak.Height)
+}
+
+func TestLoad_Corrupted(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state.json")
+	write(t, file, 1)
+	write(t, file, 2)
+
+	// A file that doesn't match its checksum, e.g. after a power loss, is recovered
+	// from the backup.
+	assert.False(t, Corrupted(file))
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{"height":3}`), 0600))
+	assert.True(t, Corrupted(file))
+	s, recovered, err := load(file)
+	require.NoError(t, err)
+	assert.True(t, recovered)
+	assert.Equal(t, int64(1), s.Height)
+
+	// The corrupted file doesn't replace the backup.
+	write(t, file, 4)
+	bak, _, err := load(BackupPath(file))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), bak.Height)
+
+	// A file that can't be decoded is recovered as well.
+	require.NoError(t, ioutil.WriteFile(file, []byte("{"), 0600))
+	require.NoError(t, os.Remove(SumPath(file)))
+	s, recovered, err = load(file)
+	require.NoError(t, err)
+	assert.True(t, recovered)
+	assert.Equal(t, int64(1), s.Height)
+
+	// Without a usable backup, the error is returned.
+	require.NoError(t, os.Remove(BackupPath(file)))
+	_, _, err = load(file)
+	assert.Error(t, err)
+}
+
+func TestLoad_Legacy(t *testing.T) {
+	// Files written without a checksum are taken as they are.
+	file := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{"height":5}`), 0600))
+	s, recovered, err := load(file)
+	require.NoError(t, err)
+	assert.False(t, recovered)
+	assert.Equal(t, int64(5), s.Height)
+}
+
+func TestLoad_Deleted(t *testing.T) {
+	// A deleted file isn't replaced by its backup, and the backup is removed once the
+	// file is written again.
+	file := filepath.Join(t.TempDir(), "state.json")
+	write(t, file, 1)
+	write(t, file, 2)
+	require.NoError(t, os.Remove(file))
+	_, _, err := load(file)
+	assert.True(t, os.IsNotExist(err))
+
+	write(t, file, 1)
+	_, err = os.Stat(BackupPath(file))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,200 @@
+// Package statemac protects SignCTRL's mutable state files against out-of-band
+// modifications. Each protected file is accompanied by a <file>.mac file holding an
+// HMAC-SHA256 of its contents, keyed with a secret that is generated once per
+// directory and never leaves the host. A file whose MAC doesn't match was modified
+// by something other than SignCTRL and must not be trusted.
+package statemac
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BlockscapeNetwork/signctrl/statefile"
+)
+
+const (
+	// KeyFile is the name of the file in a directory that holds the secret the MACs
+	// of the directory's state files are keyed with.
+	KeyFile = "signctrl_mac.key"
+
+	// Ext is appended to the path of a protected file to get the path of its MAC.
+	Ext = ".mac"
+
+	// PermKeyFile determines the file permissions of the key file and the MAC files.
+	PermKeyFile = os.FileMode(0600)
+
+	// keySize is the size of the secret in bytes.
+	keySize = 32
+)
+
+var (
+	// ErrMissingKey is returned if a directory's state files are to be verified, but
+	// it has no key file.
+	ErrMissingKey = errors.New("state MAC key is missing")
+
+	// ErrMissingMAC is returned if a protected file has no MAC.
+	ErrMissingMAC = errors.New("state file has no MAC")
+
+	// ErrInvalidMAC is returned if the MAC of a protected file doesn't match its
+	// contents, i.e. the file was modified out-of-band.
+	ErrInvalidMAC = errors.New("state file doesn't match its MAC, it was modified out-of-band")
+)
+
+// KeyFilePath returns the absolute path to the key file in the given directory.
+func KeyFilePath(dir string) string {
+	return filepath.Join(dir, KeyFile)
+}
+
+// Path returns the path to the MAC of the given file.
+func Path(file string) string {
+	return file + Ext
+}
+
+// LoadKey loads the secret from the key file in the given directory. The returned
+// error satisfies os.IsNotExist if there's no key file.
+func LoadKey(dir string) ([]byte, error) {
+	bz, err := ioutil.ReadFile(KeyFilePath(dir))
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(bz)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("invalid key in %v", KeyFilePath(dir))
+	}
+
+	return key, nil
+}
+
+// LoadOrGenKey loads the secret from the key file in the given directory, or
+// generates a new one if there's no key file. It returns true if the key was
+// generated.
+func LoadOrGenKey(dir string) ([]byte, bool, error) {
+	key, err := LoadKey(dir)
+	if err == nil {
+		return key, false, nil
+	} else if !os.IsNotExist(err) {
+		return nil, false, err
+	}
+
+	key = make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, false, err
+	}
+	if err := ioutil.WriteFile(KeyFilePath(dir), []byte(hex.EncodeToString(key)), PermKeyFile); err != nil {
+		return nil, false, err
+	}
+
+	return key, true, nil
+}
+
+// compute returns the MAC of the given contents.
+func compute(key, contents []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(contents)
+
+	return mac.Sum(nil)
+}
+
+// sign writes the MAC of the given contents of the given file. The MAC is replaced
+// atomically and the previous one is kept as its backup, see the statefile package.
+func sign(key []byte, file string, contents []byte) error {
+	return statefile.Write(Path(file), []byte(hex.EncodeToString(compute(key, contents))), PermKeyFile)
+}
+
+// Sign writes the MAC of the given file's current contents.
+func Sign(key []byte, file string) error {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	return sign(key, file, contents)
+}
+
+// loadMAC loads the MAC at the given path.
+func loadMAC(path string) ([]byte, error) {
+	var mac []byte
+	_, err := statefile.Load(path, func(data []byte) (err error) {
+		mac, err = hex.DecodeString(strings.TrimSpace(string(data)))
+		return err
+	})
+
+	return mac, err
+}
+
+// Verify checks that the given file matches its MAC.
+func Verify(key []byte, file string) error {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	return VerifyContents(key, file, contents)
+}
+
+// VerifyContents checks that the given contents match the MAC of the given file. The
+// previous MAC is accepted as well, so that contents SignCTRL was about to replace
+// when it crashed, or the backup the statefile package keeps of them, are trusted.
+func VerifyContents(key []byte, file string, contents []byte) error {
+	mac, err := loadMAC(Path(file))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %v", ErrMissingMAC, file)
+	} else if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMAC, file)
+	}
+	expected := compute(key, contents)
+	if hmac.Equal(mac, expected) {
+		return nil
+	}
+	if prev, err := loadMAC(statefile.BackupPath(Path(file))); err == nil && hmac.Equal(prev, expected) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %v", ErrInvalidMAC, file)
+}
+
+// Update writes the MAC of the given file in the given directory after SignCTRL
+// changed it. It does nothing if the directory's state files aren't protected, i.e.
+// there's no key file.
+func Update(dir, file string) error {
+	key, err := LoadKey(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return Sign(key, file)
+}
+
+// Write replaces the contents of the given file in the given directory with data, see
+// statefile.Write. If the directory's state files are protected, the MAC of data is
+// written first, so that a crash in between leaves the file matching its previous
+// MAC.
+func Write(dir, file string, data []byte, perm os.FileMode) error {
+	key, err := LoadKey(dir)
+	if os.IsNotExist(err) {
+		return statefile.Write(file, data, perm)
+	} else if err != nil {
+		return err
+	}
+
+	if err := sign(key, file, data); err != nil {
+		return err
+	}
+	if err := statefile.Write(file, data, perm); err != nil {
+		// Keep the MAC matching the contents that are still in place.
+		_ = Sign(key, file)
+		return err
+	}
+
+	return nil
+}
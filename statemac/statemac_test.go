@@ -0,0 +1,112 @@
+package statemac
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrGenKey(t *testing.T) {
+	dir := t.TempDir()
+	_, err := LoadKey(dir)
+	assert.True(t, os.IsNotExist(err))
+
+	key, generated, err := LoadOrGenKey(dir)
+	assert.NoError(t, err)
+	assert.True(t, generated)
+	assert.Len(t, key, keySize)
+	info, err := os.Stat(KeyFilePath(dir))
+	require.NoError(t, err)
+	assert.Equal(t, PermKeyFile, info.Mode().Perm())
+
+	loaded, generated, err := LoadOrGenKey(dir)
+	assert.NoError(t, err)
+	assert.False(t, generated)
+	assert.Equal(t, key, loaded)
+
+	require.NoError(t, ioutil.WriteFile(KeyFilePath(dir), []byte("invalid"), PermKeyFile))
+	_, err = LoadKey(dir)
+	assert.Error(t, err)
+}
+
+func TestSignVerify(t *testing.T) {
+	dir := t.TempDir()
+	key, _, err := LoadOrGenKey(dir)
+	require.NoError(t, err)
+	file := filepath.Join(dir, "state.json")
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{"height":"10"}`), 0600))
+
+	assert.True(t, errors.Is(Verify(key, file), ErrMissingMAC))
+	assert.NoError(t, Sign(key, file))
+	assert.NoError(t, Verify(key, file))
+
+	// Out-of-band modifications are detected.
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{"height":"1"}`), 0600))
+	assert.True(t, errors.Is(Verify(key, file), ErrInvalidMAC))
+
+	// The MAC is bound to the key.
+	require.NoError(t, Sign(key, file))
+	other, _, err := LoadOrGenKey(t.TempDir())
+	require.NoError(t, err)
+	assert.True(t, errors.Is(Verify(other, file), ErrInvalidMAC))
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "state.json")
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{}`), 0600))
+
+	// Unprotected directories are left alone.
+	assert.NoError(t, Update(dir, file))
+	assert.NoFileExists(t, Path(file))
+
+	key, _, err := LoadOrGenKey(dir)
+	require.NoError(t, err)
+	assert.NoError(t, Update(dir, file))
+	assert.NoError(t, Verify(key, file))
+}
+
+func TestVerify_PreviousMAC(t *testing.T) {
+	dir := t.TempDir()
+	key, _, err := LoadOrGenKey(dir)
+	require.NoError(t, err)
+	file := filepath.Join(dir, "state.json")
+
+	for _, height := range []string{"1", "2", "3"} {
+		require.NoError(t, ioutil.WriteFile(file, []byte(`{"height":"`+height+`"}`), 0600))
+		require.NoError(t, Sign(key, file))
+	}
+	assert.NoError(t, VerifyContents(key, file, []byte(`{"height":"3"}`)))
+	assert.NoError(t, VerifyContents(key, file, []byte(`{"height":"2"}`)))
+	assert.True(t, errors.Is(VerifyContents(key, file, []byte(`{"height":"1"}`)), ErrInvalidMAC))
+
+	// A MAC that doesn't match its checksum isn't trusted.
+	require.NoError(t, ioutil.WriteFile(Path(file), []byte("00"), PermKeyFile))
+	assert.True(t, errors.Is(Verify(key, file), ErrInvalidMAC))
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "state.json")
+
+	// Unprotected directories only get the file.
+	require.NoError(t, Write(dir, file, []byte(`{"height":"1"}`), 0600))
+	assert.NoFileExists(t, Path(file))
+
+	key, _, err := LoadOrGenKey(dir)
+	require.NoError(t, err)
+	require.NoError(t, Write(dir, file, []byte(`{"height":"2"}`), 0600))
+	assert.NoError(t, Verify(key, file))
+
+	// A crash after writing the MAC, but before writing the file, leaves the file
+	// matching its previous MAC.
+	require.NoError(t, sign(key, file, []byte(`{"height":"3"}`)))
+	assert.NoError(t, Verify(key, file))
+	require.NoError(t, Write(dir, file, []byte(`{"height":"3"}`), 0600))
+	assert.NoError(t, Verify(key, file))
+}
@@ -0,0 +1,74 @@
+package testutil_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl"
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/internal/leaktest"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/BlockscapeNetwork/signctrl/testutil"
+	"github.com/stretchr/testify/assert"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+// TestIntegration runs an embedded SignCTRL node against a mock validator, the way
+// downstream users are expected to write their integration tests.
+func TestIntegration(t *testing.T) {
+	leaktest.Check(t)
+
+	key := tm_ed25519.GenPrivKey()
+	chain := testutil.NewChain(testutil.ChainParams{
+		BlockTime: 100 * time.Millisecond,
+		Address:   key.PubKey().Address(),
+	})
+	v, err := testutil.NewMockValidator(chain, testutil.MockValidatorOptions{Latency: 10 * time.Millisecond})
+	assert.NoError(t, err)
+	defer v.Close()
+
+	// The node needs a connection key to establish a secret connection to the
+	// validator.
+	dir := t.TempDir()
+	assert.NoError(t, connection.CreateBase64ConnKey(dir))
+
+	node, err := signctrl.New(signctrl.Options{
+		Config: config.Config{
+			Base: config.Base{
+				LogLevel:                  "INFO",
+				SetSize:                   2,
+				Threshold:                 10,
+				StartRank:                 1,
+				ValidatorListenAddress:    v.Address(),
+				ValidatorListenAddressRPC: v.RPCAddress(),
+				RetryDialAfter:            "15s",
+			},
+			Privval: config.PrivValidator{ChainID: chain.Params.ChainID},
+		},
+		State:         config.State{LastHeight: 1, LastRank: 1},
+		CfgDir:        dir,
+		PrivValidator: tm_privval.NewFilePV(key, privval.KeyFilePath(dir), privval.StateFilePath(dir)),
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, node.Start(ctx))
+	assert.NoError(t, v.WaitForConnection(time.Second))
+
+	// Produce blocks for a second and stop the node afterwards.
+	produceCtx, cancelProduce := context.WithTimeout(ctx, time.Second)
+	defer cancelProduce()
+	var signed int
+	chain.Produce(produceCtx, []*testutil.MockValidator{v}, func(r testutil.Round) {
+		signed += r.Signers
+	})
+	cancel()
+	<-node.Done()
+
+	assert.True(t, signed > 0)
+	assert.Equal(t, chain.Height(), int64(signed))
+}
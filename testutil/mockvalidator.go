@@ -0,0 +1,358 @@
+// Package testutil provides a mock validator for integration tests of SignCTRL nodes,
+// e.g. when embedding SignCTRL into other applications. It consists of a fake chain
+// producing blocks, and of mock validators which SignCTRL nodes connect to for
+// signing and query blocks from.
+package testutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/rpc"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_log "github.com/tendermint/tendermint/libs/log"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_prototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+var (
+	// ErrInjectedFailure is returned by mock validators failing on purpose.
+	ErrInjectedFailure = errors.New("injected failure")
+)
+
+// ChainParams defines the parameters of a fake chain.
+type ChainParams struct {
+	// ChainID is the chain's ID. Defaults to "testchain".
+	ChainID string
+
+	// BlockTime is the time between two blocks. Defaults to 1s.
+	BlockTime time.Duration
+
+	// Address is the address of the validator whose signatures are tracked.
+	Address tm_types.Address
+}
+
+// Chain is a fake blockchain shared by all mock validators of a SignCTRL set. It
+// tracks which heights were signed by the set's validator key.
+type Chain struct {
+	sync.Mutex
+	Params ChainParams
+
+	height int64
+	signed map[int64]bool
+}
+
+// NewChain creates a new chain with the given parameters.
+func NewChain(params ChainParams) *Chain {
+	if params.ChainID == "" {
+		params.ChainID = "testchain"
+	}
+	if params.BlockTime <= 0 {
+		params.BlockTime = time.Second
+	}
+
+	return &Chain{
+		Params: params,
+		signed: make(map[int64]bool),
+	}
+}
+
+// Height returns the height of the latest block.
+func (c *Chain) Height() int64 {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.height
+}
+
+// Signed returns true if the validator signed the block at the given height.
+func (c *Chain) Signed(height int64) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.signed[height]
+}
+
+// Commit appends a new block to the chain, records whether the validator signed it
+// and returns its height.
+func (c *Chain) Commit(signed bool) int64 {
+	c.Lock()
+	defer c.Unlock()
+
+	c.height++
+	c.signed[c.height] = signed
+
+	return c.height
+}
+
+// Block returns the block at the given height. Its last commit contains the
+// validator's signature if it signed the previous block. An error is returned if the
+// block doesn't exist yet.
+func (c *Chain) Block(height int64) (*tm_coretypes.ResultBlock, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if height < 1 || height > c.height {
+		return nil, fmt.Errorf("height %v must be between 1 and %v", height, c.height)
+	}
+
+	var sigs []tm_types.CommitSig
+	if c.signed[height-1] {
+		sigs = append(sigs, tm_types.CommitSig{
+			BlockIDFlag:      tm_types.BlockIDFlagCommit,
+			ValidatorAddress: c.Params.Address,
+			Signature:        []byte("signature"),
+		})
+	}
+
+	return &tm_coretypes.ResultBlock{
+		Block: &tm_types.Block{
+			Header: tm_types.Header{ChainID: c.Params.ChainID, Height: height},
+			LastCommit: &tm_types.Commit{
+				Height:     height - 1,
+				Signatures: sigs,
+			},
+		},
+	}, nil
+}
+
+// Round is the outcome of a block produced by Produce.
+type Round struct {
+	Height  int64
+	Signers int
+}
+
+// Produce produces a block every block time until ctx is done. For each block, all
+// validators are asked to sign a precommit concurrently, and the block counts as
+// signed if at least one of them succeeded. onRound is called after each block,
+// with Signers greater than 1 meaning that the set double-signed.
+func (c *Chain) Produce(ctx context.Context, validators []*MockValidator, onRound func(Round)) {
+	ticker := time.NewTicker(c.Params.BlockTime)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case now := <-ticker.C:
+			height := c.Height() + 1
+
+			var (
+				wg      sync.WaitGroup
+				mtx     sync.Mutex
+				signers int
+			)
+			for _, v := range validators {
+				wg.Add(1)
+				go func(v *MockValidator) {
+					defer wg.Done()
+					if err := v.SignPrecommit(height, now); err == nil {
+						mtx.Lock()
+						signers++
+						mtx.Unlock()
+					}
+				}(v)
+			}
+			wg.Wait()
+
+			c.Commit(signers > 0)
+			if onRound != nil {
+				onRound(Round{Height: height, Signers: signers})
+			}
+		}
+	}
+}
+
+// FailureMode defines how a mock validator misbehaves.
+type FailureMode int
+
+const (
+	// FailureNone makes the mock validator behave correctly.
+	FailureNone FailureMode = iota
+
+	// FailureDropConnection makes the mock validator drop the connection to its node
+	// instead of sending sign requests.
+	FailureDropConnection
+
+	// FailureRPCUnavailable makes the mock validator's /block endpoint respond with
+	// an internal server error.
+	FailureRPCUnavailable
+
+	// FailureMissingBlock makes the mock validator's /block endpoint respond without
+	// a result, as if the block didn't exist.
+	FailureMissingBlock
+)
+
+// MockValidatorOptions defines the options a mock validator is created with.
+type MockValidatorOptions struct {
+	// Latency is added to every sign request and block query.
+	Latency time.Duration
+
+	// FailureMode is the initial failure mode of the mock validator. It can be changed
+	// later on via SetFailureMode.
+	FailureMode FailureMode
+}
+
+// MockValidator is a mock validator which a SignCTRL node connects to. It listens for
+// the node on a TCP socket and requests signatures via the privval protocol. It also
+// serves the chain's blocks on Tendermint's /block endpoint.
+type MockValidator struct {
+	sync.Mutex
+	Chain *Chain
+	opts  MockValidatorOptions
+
+	ln       net.Listener
+	endpoint *tm_privval.SignerListenerEndpoint
+	client   *tm_privval.SignerClient
+
+	rpcLn  net.Listener
+	server *http.Server
+}
+
+// NewMockValidator creates a new mock validator for the given chain. It starts
+// listening for a SignCTRL node and serving blocks on random local ports.
+func NewMockValidator(chain *Chain, opts MockValidatorOptions) (*MockValidator, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	rpcLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	endpoint := tm_privval.NewSignerListenerEndpoint(
+		tm_log.NewNopLogger(),
+		tm_privval.NewTCPListener(ln, tm_ed25519.GenPrivKey()),
+	)
+	client, err := tm_privval.NewSignerClient(endpoint, chain.Params.ChainID)
+	if err != nil {
+		ln.Close()
+		rpcLn.Close()
+		return nil, err
+	}
+
+	v := &MockValidator{
+		Chain:    chain,
+		opts:     opts,
+		ln:       ln,
+		endpoint: endpoint,
+		client:   client,
+		rpcLn:    rpcLn,
+	}
+	v.server = &http.Server{Handler: v}
+	go func() { _ = v.server.Serve(rpcLn) }()
+
+	return v, nil
+}
+
+// Address returns the address the mock validator listens on for its node, which is
+// used as the node's validator_laddr, e.g. tcp://127.0.0.1:3000.
+func (v *MockValidator) Address() string {
+	return "tcp://" + v.ln.Addr().String()
+}
+
+// RPCAddress returns the address the mock validator serves blocks on, which is used
+// as the node's validator_laddr_rpc, e.g. tcp://127.0.0.1:26657.
+func (v *MockValidator) RPCAddress() string {
+	return "tcp://" + v.rpcLn.Addr().String()
+}
+
+// SetFailureMode changes the failure mode of the mock validator.
+func (v *MockValidator) SetFailureMode(mode FailureMode) {
+	v.Lock()
+	defer v.Unlock()
+
+	v.opts.FailureMode = mode
+}
+
+// failureMode returns the current failure mode of the mock validator.
+func (v *MockValidator) failureMode() FailureMode {
+	v.Lock()
+	defer v.Unlock()
+
+	return v.opts.FailureMode
+}
+
+// IsConnected returns true if a SignCTRL node is connected to the mock validator.
+func (v *MockValidator) IsConnected() bool {
+	return v.client.IsConnected()
+}
+
+// WaitForConnection waits for a SignCTRL node to connect to the mock validator.
+func (v *MockValidator) WaitForConnection(maxWait time.Duration) error {
+	return v.client.WaitForConnection(maxWait)
+}
+
+// DropConnection closes the connection to the SignCTRL node, if any.
+func (v *MockValidator) DropConnection() {
+	v.endpoint.DropConnection()
+}
+
+// SignPrecommit requests a signature for a precommit at the given height.
+func (v *MockValidator) SignPrecommit(height int64, timestamp time.Time) error {
+	time.Sleep(v.opts.Latency)
+	if v.failureMode() == FailureDropConnection {
+		v.DropConnection()
+		return ErrInjectedFailure
+	}
+
+	return v.client.SignVote(v.Chain.Params.ChainID, &tm_prototypes.Vote{
+		Type:             tm_prototypes.PrecommitType,
+		Height:           height,
+		Timestamp:        timestamp,
+		ValidatorAddress: v.Chain.Params.Address,
+	})
+}
+
+// ServeHTTP serves the chain's blocks via Tendermint's /block endpoint.
+// Implements http.Handler.
+func (v *MockValidator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(v.opts.Latency)
+
+	height, err := strconv.ParseInt(r.URL.Query().Get("height"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// A missing block is answered without a result, just like Tendermint does.
+	var result rpc.BlockResult
+	switch v.failureMode() {
+	case FailureRPCUnavailable:
+		http.Error(w, ErrInjectedFailure.Error(), http.StatusInternalServerError)
+		return
+	case FailureMissingBlock:
+	default:
+		if rb, err := v.Chain.Block(height); err == nil {
+			result.Result = rb
+		}
+	}
+
+	bytes, err := tm_json.Marshal(&result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(bytes)
+}
+
+// Close stops the mock validator and closes its listeners.
+func (v *MockValidator) Close() error {
+	v.server.Close()
+	if err := v.endpoint.Stop(); err != nil {
+		return err
+	}
+	return v.ln.Close()
+}
@@ -0,0 +1,94 @@
+package testutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/rpc"
+	"github.com/stretchr/testify/assert"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+func testChain(t *testing.T) *Chain {
+	t.Helper()
+	return NewChain(ChainParams{Address: tm_types.Address("ADDR")})
+}
+
+func TestNewChain(t *testing.T) {
+	c := testChain(t)
+	assert.Equal(t, "testchain", c.Params.ChainID)
+	assert.Equal(t, time.Second, c.Params.BlockTime)
+	assert.Equal(t, int64(0), c.Height())
+}
+
+func TestChain(t *testing.T) {
+	c := testChain(t)
+	_, err := c.Block(1)
+	assert.Error(t, err)
+
+	assert.Equal(t, int64(1), c.Commit(true))
+	assert.Equal(t, int64(2), c.Commit(false))
+	assert.Equal(t, int64(3), c.Commit(true))
+	assert.True(t, c.Signed(1))
+	assert.False(t, c.Signed(2))
+
+	rb, err := c.Block(2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), rb.Block.Height)
+	assert.Len(t, rb.Block.LastCommit.Signatures, 1)
+	assert.Equal(t, c.Params.Address, rb.Block.LastCommit.Signatures[0].ValidatorAddress)
+
+	rb, err = c.Block(3)
+	assert.NoError(t, err)
+	assert.Empty(t, rb.Block.LastCommit.Signatures)
+}
+
+func testBlockQuery(t *testing.T, v *MockValidator, height string) (int, *rpc.BlockResult) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	v.ServeHTTP(rec, httptest.NewRequest("GET", "/block?height="+height, nil))
+	if rec.Code != http.StatusOK {
+		return rec.Code, nil
+	}
+
+	var result rpc.BlockResult
+	assert.NoError(t, tm_json.Unmarshal(rec.Body.Bytes(), &result))
+	return rec.Code, &result
+}
+
+func TestMockValidator_ServeHTTP(t *testing.T) {
+	v, err := NewMockValidator(testChain(t), MockValidatorOptions{})
+	assert.NoError(t, err)
+	defer v.Close()
+	v.Chain.Commit(true)
+	v.Chain.Commit(true)
+
+	_, result := testBlockQuery(t, v, "2")
+	assert.NotNil(t, result.Result)
+	_, result = testBlockQuery(t, v, "3")
+	assert.Nil(t, result.Result)
+	code, _ := testBlockQuery(t, v, "abc")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	v.SetFailureMode(FailureMissingBlock)
+	_, result = testBlockQuery(t, v, "2")
+	assert.Nil(t, result.Result)
+
+	v.SetFailureMode(FailureRPCUnavailable)
+	code, _ = testBlockQuery(t, v, "2")
+	assert.Equal(t, http.StatusInternalServerError, code)
+}
+
+func TestMockValidator_NotConnected(t *testing.T) {
+	v, err := NewMockValidator(testChain(t), MockValidatorOptions{FailureMode: FailureDropConnection})
+	assert.NoError(t, err)
+	defer v.Close()
+
+	assert.Regexp(t, `^tcp://127\.0\.0\.1:[0-9]+$`, v.Address())
+	assert.Regexp(t, `^tcp://127\.0\.0\.1:[0-9]+$`, v.RPCAddress())
+	assert.False(t, v.IsConnected())
+	assert.ErrorIs(t, v.SignPrecommit(1, time.Now()), ErrInjectedFailure)
+}
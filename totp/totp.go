@@ -0,0 +1,112 @@
+// Package totp implements time-based one-time passwords as specified in RFC 6238,
+// using HMAC-SHA1, a time step of 30 seconds and 6 digits, which is what common
+// authenticator apps default to.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Step is the time step in which the codes change.
+	Step = 30 * time.Second
+
+	// Digits is the number of digits of a code.
+	Digits = 6
+
+	// secretSize is the size of generated secrets in bytes.
+	secretSize = 20
+)
+
+// encoding is the base32 encoding secrets are exchanged in.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a new random secret, encoded in base32.
+func NewSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(secret), nil
+}
+
+// DecodeSecret decodes the given base32-encoded secret. Spaces and padding are
+// ignored, and the secret is case-insensitive.
+func DecodeSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.TrimRight(strings.ReplaceAll(strings.TrimSpace(s), " ", ""), "="))
+	secret, err := encoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP secret: %v", err)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("invalid TOTP secret: empty")
+	}
+
+	return secret, nil
+}
+
+// Code returns the code for the given secret at the given time.
+func Code(secret []byte, t time.Time) string {
+	return code(secret, uint64(t.Unix())/uint64(Step/time.Second))
+}
+
+// code returns the code for the given secret and counter as specified in RFC 4226.
+func code(secret []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", Digits, value%1000000)
+}
+
+// Validate returns true if the given code is valid for the given secret at the given
+// time. The codes of the previous and the next time step are accepted as well to
+// allow for clock drift.
+func Validate(secret []byte, c string, t time.Time) bool {
+	_, ok := Match(secret, c, t)
+	return ok
+}
+
+// Match returns the time step the given code is valid in for the given secret at the
+// given time, and false if it isn't valid, like Validate. Callers remember the time
+// step of the last accepted code in order to reject replays within the window.
+func Match(secret []byte, c string, t time.Time) (uint64, bool) {
+	if len(c) != Digits {
+		return 0, false
+	}
+	counter := uint64(t.Unix()) / uint64(Step/time.Second)
+	var step uint64
+	valid := false
+	for _, n := range []uint64{counter - 1, counter, counter + 1} {
+		if subtle.ConstantTimeCompare([]byte(code(secret, n)), []byte(c)) == 1 {
+			step, valid = n, true
+		}
+	}
+
+	return step, valid
+}
+
+// URI returns the otpauth:// URI of the given base32-encoded secret, which can be
+// rendered as a QR code and scanned by authenticator apps.
+func URI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+
+	return fmt.Sprintf("otpauth://totp/%v:%v?%v", url.PathEscape(issuer), url.PathEscape(account), v.Encode())
+}
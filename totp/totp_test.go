@@ -0,0 +1,80 @@
+package totp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 secret of the test vectors in RFC 6238.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	// The test vectors of RFC 6238, truncated to 6 digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.code, Code(rfcSecret, time.Unix(tt.unix, 0)), tt.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	assert.True(t, Validate(rfcSecret, "050471", now))
+
+	// Codes of the adjacent time steps are accepted to allow for clock drift.
+	assert.True(t, Validate(rfcSecret, Code(rfcSecret, now.Add(-Step)), now))
+	assert.True(t, Validate(rfcSecret, Code(rfcSecret, now.Add(Step)), now))
+	assert.False(t, Validate(rfcSecret, Code(rfcSecret, now.Add(-2*Step)), now))
+
+	assert.False(t, Validate(rfcSecret, "", now))
+	assert.False(t, Validate(rfcSecret, "50471", now))
+}
+
+func TestMatch(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	counter := uint64(now.Unix()) / 30
+
+	step, ok := Match(rfcSecret, Code(rfcSecret, now.Add(-Step)), now)
+	assert.True(t, ok)
+	assert.Equal(t, counter-1, step)
+	step, ok = Match(rfcSecret, Code(rfcSecret, now.Add(Step)), now)
+	assert.True(t, ok)
+	assert.Equal(t, counter+1, step)
+
+	_, ok = Match(rfcSecret, Code(rfcSecret, now.Add(2*Step)), now)
+	assert.False(t, ok)
+}
+
+func TestSecret(t *testing.T) {
+	s, err := NewSecret()
+	require.NoError(t, err)
+	secret, err := DecodeSecret(s)
+	assert.NoError(t, err)
+	assert.Len(t, secret, secretSize)
+
+	// Lowercase, spaces and padding are tolerated.
+	secret, err = DecodeSecret("gezd gnbv gy3t qojq gezd gnbv gy3t qojq====\n")
+	assert.NoError(t, err)
+	assert.Equal(t, rfcSecret, secret)
+
+	_, err = DecodeSecret("")
+	assert.Error(t, err)
+	_, err = DecodeSecret("not base32!")
+	assert.Error(t, err)
+}
+
+func TestURI(t *testing.T) {
+	assert.Equal(t, "otpauth://totp/SignCTRL:validator-1?issuer=SignCTRL&secret=GEZDGNBV", URI("SignCTRL", "validator-1", "GEZDGNBV"))
+}
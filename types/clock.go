@@ -0,0 +1,218 @@
+package types
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and creates timers. It allows for timeouts to be tested
+// without actually waiting for them to pass.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After waits for the duration to elapse and then sends the current time on the
+	// returned channel.
+	After(d time.Duration) <-chan time.Time
+
+	// NewTimer creates a new Timer that sends the current time on its channel after
+	// at least duration d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer created by a Clock. It behaves like a time.Timer.
+type Timer interface {
+	// C returns the channel the time is sent on once the timer fires.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It returns false if the timer has already
+	// fired or been stopped.
+	Stop() bool
+
+	// Reset changes the timer to fire after duration d. It returns true if the timer
+	// had been active.
+	Reset(d time.Duration) bool
+}
+
+// SystemClock is the clock backed by the time package.
+var SystemClock Clock = systemClock{}
+
+// systemClock implements the Clock interface using the time package.
+type systemClock struct{}
+
+// Now returns time.Now().
+// Implements the Clock interface.
+func (systemClock) Now() time.Time { return time.Now() }
+
+// After returns time.After(d).
+// Implements the Clock interface.
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// NewTimer returns a Timer wrapping time.NewTimer(d).
+// Implements the Clock interface.
+func (systemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+// systemTimer implements the Timer interface by wrapping a time.Timer.
+type systemTimer struct {
+	*time.Timer
+}
+
+// C returns the timer's channel.
+// Implements the Timer interface.
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
+
+// FakeClock is a clock that only moves forward when told to. Timers fire once the
+// clock is advanced past their deadlines.
+// Implements the Clock interface.
+type FakeClock struct {
+	mtx    sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// FakeClock must implement the Clock interface.
+var _ Clock = new(FakeClock)
+
+// NewFakeClock creates a new fake clock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mtx)
+	return c
+}
+
+// Now returns the clock's current time.
+// Implements the Clock interface.
+func (c *FakeClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.now
+}
+
+// After returns the channel of a new timer firing after d.
+// Implements the Clock interface.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer creates a new timer firing once the clock is advanced by d.
+// Implements the Clock interface.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	c.schedule(t, d)
+
+	return t
+}
+
+// schedule makes t fire after d. The clock's lock must be held.
+func (c *FakeClock) schedule(t *fakeTimer, d time.Duration) {
+	t.deadline = c.now.Add(d)
+	if d <= 0 {
+		t.fire(c.now)
+		return
+	}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+}
+
+// unschedule removes t from the clock's active timers and returns true if it was
+// active. The clock's lock must be held.
+func (c *FakeClock) unschedule(t *fakeTimer) bool {
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Advance moves the clock forward by d and fires all timers whose deadlines have
+// passed, in the order of their deadlines.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+
+	var active []*fakeTimer
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			active = append(active, t)
+			continue
+		}
+		t.fire(c.now)
+	}
+	c.timers = active
+}
+
+// Timers returns the number of active timers.
+func (c *FakeClock) Timers() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return len(c.timers)
+}
+
+// BlockUntil blocks until at least n timers are active. This allows tests to wait for
+// the code under test to start waiting before advancing the clock.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// fakeTimer is a timer created by a FakeClock.
+// Implements the Timer interface.
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+// fire sends now on the timer's channel unless a previous value wasn't received yet.
+func (t *fakeTimer) fire(now time.Time) {
+	select {
+	case t.c <- now:
+	default:
+	}
+}
+
+// C returns the timer's channel.
+// Implements the Timer interface.
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop prevents the timer from firing.
+// Implements the Timer interface.
+func (t *fakeTimer) Stop() bool {
+	t.clock.mtx.Lock()
+	defer t.clock.mtx.Unlock()
+
+	return t.clock.unschedule(t)
+}
+
+// Reset changes the timer to fire once the clock is advanced by d.
+// Implements the Timer interface.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mtx.Lock()
+	defer t.clock.mtx.Unlock()
+
+	active := t.clock.unschedule(t)
+	t.clock.schedule(t, d)
+
+	return active
+}
@@ -0,0 +1,91 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemClock(t *testing.T) {
+	before := time.Now()
+	assert.False(t, SystemClock.Now().Before(before))
+
+	timer := SystemClock.NewTimer(time.Millisecond)
+	<-timer.C()
+	assert.False(t, timer.Stop())
+	assert.False(t, timer.Reset(time.Hour))
+	assert.True(t, timer.Stop())
+
+	<-SystemClock.After(time.Millisecond)
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := NewFakeClock(start)
+	assert.Equal(t, start, c.Now())
+
+	c.Advance(5 * time.Second)
+	assert.Equal(t, start.Add(5*time.Second), c.Now())
+}
+
+func TestFakeClock_Timers(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 0))
+	first := c.NewTimer(time.Second)
+	second := c.After(2 * time.Second)
+	assert.Equal(t, 2, c.Timers())
+
+	// Timers must not fire before their deadlines.
+	c.Advance(999 * time.Millisecond)
+	select {
+	case <-first.C():
+		t.Fatal("timer fired too early")
+	default:
+	}
+
+	c.Advance(time.Millisecond)
+	assert.Equal(t, c.Now(), <-first.C())
+	assert.Equal(t, 1, c.Timers())
+	assert.False(t, first.Stop())
+
+	c.Advance(time.Second)
+	assert.Equal(t, c.Now(), <-second)
+	assert.Equal(t, 0, c.Timers())
+}
+
+func TestFakeClock_StopReset(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 0))
+	timer := c.NewTimer(time.Second)
+	assert.True(t, timer.Stop())
+	assert.False(t, timer.Stop())
+
+	c.Advance(time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+
+	assert.False(t, timer.Reset(time.Second))
+	assert.True(t, timer.Reset(2*time.Second))
+	c.Advance(time.Second)
+	assert.Equal(t, 1, c.Timers())
+	c.Advance(time.Second)
+	<-timer.C()
+
+	// Timers with non-positive durations fire right away.
+	<-c.After(0)
+}
+
+func TestFakeClock_BlockUntil(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 0))
+	done := make(chan struct{})
+	go func() {
+		<-c.After(time.Minute)
+		close(done)
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	<-done
+}
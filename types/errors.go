@@ -0,0 +1,155 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/BlockscapeNetwork/signctrl/rank"
+)
+
+// ErrorCode is a machine-readable code that classifies an error, so that automation
+// can react to specific failure classes without matching error messages. The codes
+// are part of SignCTRL's interface and are never renamed.
+type ErrorCode string
+
+const (
+	// CodeUnknown classifies errors that don't carry a code.
+	CodeUnknown ErrorCode = "ERR_UNKNOWN"
+
+	// CodeCanceled classifies errors caused by a canceled context or an exceeded
+	// deadline, e.g. while SignCTRL shuts down.
+	CodeCanceled ErrorCode = "ERR_CANCELED"
+
+	// CodeThreshold classifies the threshold of too many missed blocks in a row being
+	// exceeded.
+	CodeThreshold ErrorCode = "ERR_THRESHOLD"
+
+	// CodeMustShutdown classifies a signer that can't be promoted anymore and must shut
+	// down.
+	CodeMustShutdown ErrorCode = "ERR_MUST_SHUTDOWN"
+
+	// CodeCounterLocked classifies missed blocks that aren't counted, as the counter
+	// is locked until the signer's first commitsig is found.
+	CodeCounterLocked ErrorCode = "ERR_COUNTER_LOCKED"
+
+	// CodeRankObsolete classifies requests at heights that made the node's rank
+	// obsolete.
+	CodeRankObsolete ErrorCode = "ERR_RANK_OBSOLETE"
+
+	// CodeRankConflict classifies conflicts about which node in the set signs, e.g. a
+	// node that can't be demoted or was fenced off by another one.
+	CodeRankConflict ErrorCode = "ERR_RANK_CONFLICT"
+
+	// CodeNoPermission classifies sign requests refused because the node isn't
+	// allowed to sign, e.g. as it isn't ranked first or doesn't hold the lock.
+	CodeNoPermission ErrorCode = "ERR_NO_PERMISSION"
+
+	// CodeDoubleSign classifies sign requests refused because signing them might
+	// double-sign.
+	CodeDoubleSign ErrorCode = "ERR_DOUBLE_SIGN"
+
+	// CodeSigningFailed classifies failures of the signer backend.
+	CodeSigningFailed ErrorCode = "ERR_SIGNING_FAILED"
+
+	// CodeBadRequest classifies requests that are malformed, unknown or for the wrong
+	// chain.
+	CodeBadRequest ErrorCode = "ERR_BAD_REQUEST"
+
+	// CodeOverloaded classifies requests that were dropped because newer ones were
+	// queued.
+	CodeOverloaded ErrorCode = "ERR_OVERLOADED"
+
+	// CodeConnLost classifies a lost connection to the validator.
+	CodeConnLost ErrorCode = "ERR_CONN_LOST"
+
+	// CodeUnverified classifies blocks or missed blocks that couldn't be verified.
+	CodeUnverified ErrorCode = "ERR_UNVERIFIED"
+
+	// CodeSlashed classifies a validator that is jailed or tombstoned.
+	CodeSlashed ErrorCode = "ERR_SLASHED"
+
+	// CodeUnauthorized classifies rejected admin requests.
+	CodeUnauthorized ErrorCode = "ERR_UNAUTHORIZED"
+
+	// CodeNotFound classifies admin requests for things that don't exist.
+	CodeNotFound ErrorCode = "ERR_NOT_FOUND"
+
+	// CodeInvalidConfig classifies invalid configurations, keys or files.
+	CodeInvalidConfig ErrorCode = "ERR_INVALID_CONFIG"
+
+	// CodeCrashed classifies a node that crashed.
+	CodeCrashed ErrorCode = "ERR_CRASHED"
+)
+
+// codedErrors are the codes of the sentinel errors of packages that can't import
+// types.
+var codedErrors = []struct {
+	err  error
+	code ErrorCode
+}{
+	{rank.ErrThresholdExceeded, CodeThreshold},
+	{rank.ErrMustShutdown, CodeMustShutdown},
+	{rank.ErrCounterLocked, CodeCounterLocked},
+	{rank.ErrCannotDemote, CodeRankConflict},
+	{context.Canceled, CodeCanceled},
+	{context.DeadlineExceeded, CodeCanceled},
+}
+
+// Error is an error carrying a machine-readable code. Use errors.Is to check for a
+// sentinel Error and ErrorCodeOf to get the code of any error wrapping one.
+type Error struct {
+	Code ErrorCode
+	Err  error
+}
+
+// NewError returns a new Error with the given code and message.
+func NewError(code ErrorCode, msg string) *Error {
+	return &Error{Code: code, Err: errors.New(msg)}
+}
+
+// Errorf returns a new Error with the given code and an error formatted like
+// fmt.Errorf, so that causes can be wrapped with %w.
+func Errorf(code ErrorCode, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// Error returns the string representation of the error.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode returns the error's code.
+func (e *Error) ErrorCode() ErrorCode {
+	return e.Code
+}
+
+// ErrorCode returns the code of the wrapped error.
+func (e *RankError) ErrorCode() ErrorCode {
+	return ErrorCodeOf(e.Err)
+}
+
+// ErrorCodeOf returns the code of the first error in err's chain that carries one,
+// i.e. implements an ErrorCode method, or of a known sentinel error in the chain. It
+// returns CodeUnknown if there's none, and an empty code if err is nil.
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	var coded interface{ ErrorCode() ErrorCode }
+	if errors.As(err, &coded) {
+		return coded.ErrorCode()
+	}
+	for _, c := range codedErrors {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+
+	return CodeUnknown
+}
@@ -0,0 +1,32 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	errTest := NewError(CodeConnLost, "test")
+	assert.Equal(t, ErrorCode(""), ErrorCodeOf(nil))
+	assert.Equal(t, CodeUnknown, ErrorCodeOf(errors.New("test")))
+	assert.Equal(t, CodeConnLost, ErrorCodeOf(errTest))
+
+	// The code is kept when the error is wrapped, and the cause by the error.
+	wrapped := fmt.Errorf("wrapped: %w", errTest)
+	assert.Equal(t, CodeConnLost, ErrorCodeOf(wrapped))
+	assert.ErrorIs(t, wrapped, errTest)
+	cause := Errorf(CodeSigningFailed, "couldn't sign: %w", context.DeadlineExceeded)
+	assert.Equal(t, CodeSigningFailed, ErrorCodeOf(cause))
+	assert.ErrorIs(t, cause, context.DeadlineExceeded)
+	assert.Equal(t, "couldn't sign: context deadline exceeded", cause.Error())
+
+	// The sentinel errors of the rank package are known.
+	assert.Equal(t, CodeThreshold, ErrorCodeOf(&RankError{Err: ErrThresholdExceeded}))
+	assert.Equal(t, CodeCounterLocked, ErrorCodeOf(fmt.Errorf("wrapped: %w", ErrCounterLocked)))
+	assert.Equal(t, CodeMustShutdown, ErrorCodeOf(ErrMustShutdown))
+	assert.Equal(t, CodeCanceled, ErrorCodeOf(context.Canceled))
+}
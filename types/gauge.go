@@ -7,21 +7,165 @@ import (
 
 // Gauges wraps SignCTRL's prometheus gauges.
 type Gauges struct {
-	RankGauge         prometheus.Gauge
-	MissedInARowGauge prometheus.Gauge
+	RankGauge                 prometheus.Gauge
+	MissedInARowGauge         prometheus.Gauge
+	JailedGauge               prometheus.Gauge
+	TombstonedGauge           prometheus.Gauge
+	SlashingMissedBlocksGauge prometheus.Gauge
+	RPCCircuitOpenGauge       prometheus.Gauge
+	RPCRequestsCounter        *prometheus.CounterVec
+	RPCDurationHistogram      *prometheus.HistogramVec
+	SLOComplianceGauge        *prometheus.GaugeVec
+	SlowSignRequestsCounter   prometheus.Counter
+	BadSignaturesCounter      prometheus.Counter
+	ValidatorStaleGauge       prometheus.Gauge
+	ClockOffsetGauge          prometheus.Gauge
+	DroppedRequestsCounter    prometheus.Counter
+	LivePeersGauge            prometheus.Gauge
+	ThresholdGauge            prometheus.Gauge
+	CounterLockedGauge        prometheus.Gauge
+	SignedCounter             *prometheus.CounterVec
+	SignDurationHistogram     *prometheus.HistogramVec
+	ConnErrorsCounter         *prometheus.CounterVec
+	ReconnectsCounter         prometheus.Counter
+	DialBackoffGauge          prometheus.Gauge
+	WrongChainIDCounter       *prometheus.CounterVec
+	WatcherLagGauge           prometheus.Gauge
+	WatcherSubscribedGauge    prometheus.Gauge
+	ErrorsCounter             *prometheus.CounterVec
 }
 
-// RegisterGauges registers SignCTRL's prometheus gauges and returns them.
+// RegisterGauges registers SignCTRL's prometheus gauges, as well as its counters and
+// histograms, and returns them.
 func RegisterGauges() Gauges {
+	return registerGauges(promauto.With(prometheus.DefaultRegisterer))
+}
+
+// RegisterGaugesFor registers SignCTRL's prometheus gauges, as well as its counters and
+// histograms, for the validator with the given name signing on the given chain, and
+// returns them. All their time series are labeled with the chain_id and validator, so
+// that the validators managed by one process can be told apart. The validator's name
+// is empty for the default validator, which leaves out the label. It must not be
+// combined with RegisterGauges.
+func RegisterGaugesFor(chainID, validator string) Gauges {
+	return registerGaugesFor(prometheus.DefaultRegisterer, chainID, validator)
+}
+
+// registerGaugesFor registers the gauges labeled with the chain ID and validator with
+// reg.
+func registerGaugesFor(reg prometheus.Registerer, chainID, validator string) Gauges {
+	labels := prometheus.Labels{"chain_id": chainID, "validator": validator}
+	return registerGauges(promauto.With(prometheus.WrapRegistererWith(labels, reg)))
+}
+
+// registerGauges creates the gauges with f.
+func registerGauges(f promauto.Factory) Gauges {
 	var g Gauges
-	g.RankGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	g.RankGauge = f.NewGauge(prometheus.GaugeOpts{
 		Name: "signctrl_rank",
 		Help: "Current rank of the SignCTRL validator.",
 	})
-	g.MissedInARowGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	g.MissedInARowGauge = f.NewGauge(prometheus.GaugeOpts{
 		Name: "signctrl_missed_blocks_in_a_row",
 		Help: "Number of blocks missed in a row",
 	})
+	g.JailedGauge = f.NewGauge(prometheus.GaugeOpts{
+		Name: "signctrl_jailed",
+		Help: "Whether the validator is jailed (1) or not (0), as reported by the staking module.",
+	})
+	g.TombstonedGauge = f.NewGauge(prometheus.GaugeOpts{
+		Name: "signctrl_tombstoned",
+		Help: "Whether the validator is tombstoned (1) or not (0), as reported by the slashing module.",
+	})
+	g.SlashingMissedBlocksGauge = f.NewGauge(prometheus.GaugeOpts{
+		Name: "signctrl_slashing_missed_blocks",
+		Help: "Number of blocks missed in the signed blocks window, as reported by the slashing module.",
+	})
+	g.RPCCircuitOpenGauge = f.NewGauge(prometheus.GaugeOpts{
+		Name: "signctrl_rpc_circuits_open",
+		Help: "Number of hosts whose circuit is open after too many failed RPC requests in a row.",
+	})
+	g.RPCRequestsCounter = f.NewCounterVec(prometheus.CounterOpts{
+		Name: "signctrl_rpc_requests_total",
+		Help: "Number of RPC requests by endpoint and result (success, error or circuit_open).",
+	}, []string{"endpoint", "result"})
+	g.RPCDurationHistogram = f.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "signctrl_rpc_request_duration_seconds",
+		Help: "Duration of RPC requests by endpoint, including retries.",
+	}, []string{"endpoint"})
+	g.SLOComplianceGauge = f.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "signctrl_sign_latency_slo_compliance",
+		Help: "Share of sign requests handled within the sign latency SLO by rolling window.",
+	}, []string{"window"})
+	g.SlowSignRequestsCounter = f.NewCounter(prometheus.CounterOpts{
+		Name: "signctrl_sign_requests_slow_total",
+		Help: "Number of sign requests that exceeded the sign latency SLO.",
+	})
+	g.BadSignaturesCounter = f.NewCounter(prometheus.CounterOpts{
+		Name: "signctrl_bad_signatures_total",
+		Help: "Number of produced signatures that didn't verify and weren't sent.",
+	})
+	g.ValidatorStaleGauge = f.NewGauge(prometheus.GaugeOpts{
+		Name: "signctrl_validator_stale",
+		Help: "Whether the validator stopped advancing while the network kept going (1) or not (0).",
+	})
+	g.ClockOffsetGauge = f.NewGauge(prometheus.GaugeOpts{
+		Name: "signctrl_clock_offset_seconds",
+		Help: "Offset of the local clock to the NTP server's one in seconds.",
+	})
+	g.DroppedRequestsCounter = f.NewCounter(prometheus.CounterOpts{
+		Name: "signctrl_dropped_requests_total",
+		Help: "Number of sign requests that were answered with an error without being handled, as newer requests for the same height, round and step were queued.",
+	})
+	g.LivePeersGauge = f.NewGauge(prometheus.GaugeOpts{
+		Name: "signctrl_live_peers",
+		Help: "Number of peers in the set a heartbeat was received from within the peer timeout.",
+	})
+	g.ThresholdGauge = f.NewGauge(prometheus.GaugeOpts{
+		Name: "signctrl_threshold",
+		Help: "Number of blocks missed in a row that triggers a rank update.",
+	})
+	g.CounterLockedGauge = f.NewGauge(prometheus.GaugeOpts{
+		Name: "signctrl_counter_locked",
+		Help: "Whether the counter for missed blocks in a row is locked until the first commitsig (1) or not (0).",
+	})
+	g.SignedCounter = f.NewCounterVec(prometheus.CounterOpts{
+		Name: "signctrl_signed_total",
+		Help: "Number of signed votes and proposals by type (prevote, precommit or proposal).",
+	}, []string{"type"})
+	g.SignDurationHistogram = f.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "signctrl_sign_request_duration_seconds",
+		Help:    "Duration of handling sign requests by type (prevote, precommit or proposal), including refused ones.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"type"})
+	g.ConnErrorsCounter = f.NewCounterVec(prometheus.CounterOpts{
+		Name: "signctrl_connection_errors_total",
+		Help: "Number of errors reading from or writing to the connection to the validator by operation (read or write).",
+	}, []string{"op"})
+	g.ReconnectsCounter = f.NewCounter(prometheus.CounterOpts{
+		Name: "signctrl_reconnects_total",
+		Help: "Number of attempts to reconnect to the validator after the connection was lost.",
+	})
+	g.DialBackoffGauge = f.NewGauge(prometheus.GaugeOpts{
+		Name: "signctrl_dial_backoff_seconds",
+		Help: "Current delay between the attempts to dial the validator in seconds, 0 once the validator sends messages.",
+	})
+	g.WrongChainIDCounter = f.NewCounterVec(prometheus.CounterOpts{
+		Name: "signctrl_wrong_chain_id_requests_total",
+		Help: "Number of requests rejected for another chain ID than the configured one by type (pubkey, prevote, precommit or proposal).",
+	}, []string{"type"})
+	g.WatcherLagGauge = f.NewGauge(prometheus.GaugeOpts{
+		Name: "signctrl_watcher_lag_seconds",
+		Help: "Time since the latest block checked for the validator's commitsig was committed in seconds.",
+	})
+	g.WatcherSubscribedGauge = f.NewGauge(prometheus.GaugeOpts{
+		Name: "signctrl_watcher_subscribed",
+		Help: "Whether the commit watcher receives the blocks via the websocket subscription (1) or polls them (0).",
+	})
+	g.ErrorsCounter = f.NewCounterVec(prometheus.CounterOpts{
+		Name: "signctrl_errors_total",
+		Help: "Number of errors by code, e.g. ERR_THRESHOLD or ERR_CONN_LOST.",
+	}, []string{"code"})
 
 	return g
 }
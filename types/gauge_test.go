@@ -3,11 +3,63 @@ package types
 import (
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRegisterGauges(t *testing.T) {
 	g := RegisterGauges()
 	assert.NotNil(t, g.RankGauge)
 	assert.NotNil(t, g.MissedInARowGauge)
+	assert.NotNil(t, g.JailedGauge)
+	assert.NotNil(t, g.TombstonedGauge)
+	assert.NotNil(t, g.SlashingMissedBlocksGauge)
+	assert.NotNil(t, g.RPCCircuitOpenGauge)
+	assert.NotNil(t, g.RPCRequestsCounter)
+	assert.NotNil(t, g.RPCDurationHistogram)
+	assert.NotNil(t, g.SLOComplianceGauge)
+	assert.NotNil(t, g.SlowSignRequestsCounter)
+	assert.NotNil(t, g.BadSignaturesCounter)
+	assert.NotNil(t, g.ValidatorStaleGauge)
+	assert.NotNil(t, g.ClockOffsetGauge)
+	assert.NotNil(t, g.DroppedRequestsCounter)
+	assert.NotNil(t, g.LivePeersGauge)
+	assert.NotNil(t, g.ThresholdGauge)
+	assert.NotNil(t, g.CounterLockedGauge)
+	assert.NotNil(t, g.SignedCounter)
+	assert.NotNil(t, g.SignDurationHistogram)
+	assert.NotNil(t, g.ConnErrorsCounter)
+	assert.NotNil(t, g.ReconnectsCounter)
+	assert.NotNil(t, g.DialBackoffGauge)
+	assert.NotNil(t, g.WrongChainIDCounter)
+	assert.NotNil(t, g.WatcherLagGauge)
+	assert.NotNil(t, g.WatcherSubscribedGauge)
+	assert.NotNil(t, g.ErrorsCounter)
+}
+
+func TestRegisterGaugesFor(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	def := registerGaugesFor(reg, "cosmoshub-4", "")
+	instance := registerGaugesFor(reg, "cosmoshub-4", "operator-a")
+	def.RankGauge.Set(1)
+	instance.RankGauge.Set(2)
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	ranks := make(map[string]float64)
+	for _, mf := range mfs {
+		if mf.GetName() != "signctrl_rank" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			assert.Equal(t, "cosmoshub-4", labels["chain_id"])
+			ranks[labels["validator"]] = m.GetGauge().GetValue()
+		}
+	}
+	assert.Equal(t, map[string]float64{"": 1, "operator-a": 2}, ranks)
 }
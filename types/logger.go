@@ -1,10 +1,13 @@
 package types
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/logutils"
 )
@@ -14,10 +17,48 @@ var (
 	LogLevels = []logutils.LogLevel{"DEBUG", "INFO", "WARN", "ERR"}
 )
 
-// SyncLogger wraps a standard log.Logger and makes it synchronous.
+// LogFormat is the format of the lines written by a SyncLogger.
+type LogFormat string
+
+const (
+	// LogFormatText writes plain text lines like "[INFO]  signctrl: message key=value".
+	LogFormatText LogFormat = "text"
+
+	// LogFormatJSON writes a JSON object per line with the time, level, module and
+	// message and the logger's fields. The lines are still tagged with their level for
+	// the level filter and have to be written through a JSONWriter.
+	LogFormatJSON LogFormat = "json"
+
+	// logModule is the module all of SignCTRL's log lines are attributed to.
+	logModule = "signctrl"
+)
+
+// Logger is the logger used throughout SignCTRL. Messages are formatted according to
+// a format specifier, like fmt.Printf. Adapters for common logging libraries can be
+// found in the logadapter package.
+type Logger interface {
+	Debug(format string, v ...interface{})
+	Info(format string, v ...interface{})
+	Warn(format string, v ...interface{})
+	Error(format string, v ...interface{})
+
+	// With returns a logger that adds the given alternating keys and values as fields
+	// to every message.
+	With(keyvals ...interface{}) Logger
+}
+
+// SyncLogger must implement the Logger interface.
+var _ Logger = new(SyncLogger)
+
+// SyncLogger wraps a standard log.Logger and makes it synchronous. Levels are written
+// as tags, so the output can be filtered with logutils.
+// Implements the Logger interface.
 type SyncLogger struct {
 	sync.Mutex
-	logger *log.Logger
+	logger  *log.Logger
+	format  LogFormat
+	fields  string
+	keyvals []interface{}
 }
 
 // NewSyncLogger creates a new synchronous logger.
@@ -25,39 +66,115 @@ func NewSyncLogger(out io.Writer, prefix string, flag int) *SyncLogger {
 	return &SyncLogger{logger: log.New(out, prefix, flag)}
 }
 
+// WrapLogger creates a new synchronous logger that writes to an existing log.Logger.
+func WrapLogger(logger *log.Logger) *SyncLogger {
+	return &SyncLogger{logger: logger}
+}
+
 // SetOutput sets the output destination for the standard logger.
 func (sl *SyncLogger) SetOutput(w io.Writer) {
 	sl.logger.SetOutput(w)
 }
 
-// Debug calls sl.Output to print a debug message to the logger.
-func (sl *SyncLogger) Debug(format string, v ...interface{}) {
+// SetFormat sets the format of the log lines. It must be called before deriving
+// loggers via With.
+func (sl *SyncLogger) SetFormat(format LogFormat) {
 	sl.Lock()
 	defer sl.Unlock()
-	taggedFormat := fmt.Sprintf("[DEBUG] signctrl: %v", format)
-	_ = sl.logger.Output(2, fmt.Sprintf(taggedFormat, v...))
+	sl.format = format
 }
 
-// Info calls sl.Output to print an info message to the logger.
-func (sl *SyncLogger) Info(format string, v ...interface{}) {
+// output prints the message with the given level tag and the logger's fields.
+func (sl *SyncLogger) output(tag string, format string, v ...interface{}) {
 	sl.Lock()
 	defer sl.Unlock()
-	taggedFormat := fmt.Sprintf("[INFO]  signctrl: %v", format)
-	_ = sl.logger.Output(2, fmt.Sprintf(taggedFormat, v...))
+	msg := fmt.Sprintf(format, v...)
+	if sl.format == LogFormatJSON {
+		level := strings.Trim(tag, "[] ")
+		_ = sl.logger.Output(3, fmt.Sprintf("[%v] %s", level, encodeLogLine(time.Now(), level, msg, sl.keyvals...)))
+		return
+	}
+	if sl.fields != "" {
+		msg = strings.TrimSuffix(msg, "\n") + sl.fields
+	}
+	_ = sl.logger.Output(3, fmt.Sprintf("%v %v: %v", tag, logModule, msg))
+}
+
+// Debug calls sl.Output to print a debug message to the logger.
+func (sl *SyncLogger) Debug(format string, v ...interface{}) {
+	sl.output("[DEBUG]", format, v...)
+}
+
+// Info calls sl.Output to print an info message to the logger.
+func (sl *SyncLogger) Info(format string, v ...interface{}) {
+	sl.output("[INFO] ", format, v...)
 }
 
 // Warn calls sl.Output to print a warning message to the logger.
 func (sl *SyncLogger) Warn(format string, v ...interface{}) {
-	sl.Lock()
-	defer sl.Unlock()
-	taggedFormat := fmt.Sprintf("[WARN]  signctrl: %v", format)
-	_ = sl.logger.Output(2, fmt.Sprintf(taggedFormat, v...))
+	sl.output("[WARN] ", format, v...)
 }
 
 // Error calls sl.Output to print an error message to the logger.
 func (sl *SyncLogger) Error(format string, v ...interface{}) {
-	sl.Lock()
-	defer sl.Unlock()
-	taggedFormat := fmt.Sprintf("[ERR]   signctrl: %v", format)
-	_ = sl.logger.Output(2, fmt.Sprintf(taggedFormat, v...))
+	sl.output("[ERR]  ", format, v...)
+}
+
+// With returns a logger writing to the same log.Logger that appends the given fields
+// to every message as key=value pairs.
+// Implements the Logger interface.
+func (sl *SyncLogger) With(keyvals ...interface{}) Logger {
+	return &SyncLogger{
+		logger:  sl.logger,
+		format:  sl.format,
+		fields:  sl.fields + FormatFields(keyvals...),
+		keyvals: append(append([]interface{}{}, sl.keyvals...), keyvals...),
+	}
+}
+
+// FormatFields formats the given alternating keys and values as " key=value" pairs.
+// A missing value for the last key is formatted as "MISSING".
+func FormatFields(keyvals ...interface{}) string {
+	var b strings.Builder
+	for i := 0; i < len(keyvals); i += 2 {
+		var val interface{} = "MISSING"
+		if i+1 < len(keyvals) {
+			val = keyvals[i+1]
+		}
+		fmt.Fprintf(&b, " %v=%v", keyvals[i], val)
+	}
+
+	return b.String()
+}
+
+// encodeLogLine encodes a log line as a JSON object with the time, level, module and
+// message, followed by the given alternating keys and values. Values that can't be
+// encoded as JSON are formatted as strings.
+func encodeLogLine(t time.Time, level, msg string, keyvals ...interface{}) []byte {
+	var b strings.Builder
+	field := func(key string, val interface{}) {
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(val)
+		if err != nil {
+			v, _ = json.Marshal(fmt.Sprint(val))
+		}
+		b.WriteByte(',')
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+
+	field("time", t.UTC().Format(time.RFC3339Nano))
+	field("level", level)
+	field("module", logModule)
+	field("msg", strings.TrimSuffix(msg, "\n"))
+	for i := 0; i < len(keyvals); i += 2 {
+		var val interface{} = "MISSING"
+		if i+1 < len(keyvals) {
+			val = keyvals[i+1]
+		}
+		field(fmt.Sprint(keyvals[i]), val)
+	}
+
+	return []byte("{" + b.String()[1:] + "}")
 }
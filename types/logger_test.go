@@ -1,8 +1,14 @@
 package types
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestSyncLoggerDebug(t *testing.T) {
@@ -32,3 +38,51 @@ func TestSyncLoggerError(t *testing.T) {
 	// Output:
 	// [ERR] signctrl: Debug test msg
 }
+
+func TestSyncLoggerWith(t *testing.T) {
+	var buf bytes.Buffer
+	sl := NewSyncLogger(&buf, "", 0)
+	sl.With("rank", 2).With("height", 10).Info("Missed a block (%v/%v)\n", 1, 5)
+	assert.Equal(t, "[INFO]  signctrl: Missed a block (1/5) rank=2 height=10\n", buf.String())
+
+	// The original logger must not be affected.
+	buf.Reset()
+	sl.Error("Error test msg")
+	assert.Equal(t, "[ERR]   signctrl: Error test msg\n", buf.String())
+}
+
+func TestFormatFields(t *testing.T) {
+	assert.Equal(t, "", FormatFields())
+	assert.Equal(t, " a=1 b=MISSING", FormatFields("a", 1, "b"))
+}
+
+func TestSyncLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	sl := NewSyncLogger(&buf, "", 0)
+	sl.SetFormat(LogFormatJSON)
+	sl.With("rank", 2, "instance", "b").Warn("Missed a block (%v/%v)\n", 1, 5)
+
+	// The line is still tagged with its level for the level filter.
+	line := buf.String()
+	assert.True(t, strings.HasPrefix(line, "[WARN] {"))
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "[WARN] ")), &entry))
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "signctrl", entry["module"])
+	assert.Equal(t, "Missed a block (1/5)", entry["msg"])
+	assert.Equal(t, float64(2), entry["rank"])
+	assert.Equal(t, "b", entry["instance"])
+	_, err := time.Parse(time.RFC3339Nano, entry["time"].(string))
+	assert.NoError(t, err)
+}
+
+func TestEncodeLogLine(t *testing.T) {
+	line := encodeLogLine(time.Unix(0, 0), "INFO", "msg\n", "ch", make(chan int), "missing")
+	assert.True(t, strings.HasPrefix(string(line), `{"time":"1970-01-01T00:00:00Z","level":"INFO","module":"signctrl","msg":"msg",`))
+
+	// Values that can't be encoded are formatted as strings.
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(line, &entry))
+	assert.True(t, strings.HasPrefix(entry["ch"].(string), "0x"))
+	assert.Equal(t, "MISSING", entry["missing"])
+}
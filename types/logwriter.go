@@ -0,0 +1,215 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/logutils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// droppableLevels are the log levels whose lines may be dropped by a
+// RateLimitedWriter.
+var droppableLevels = map[string]bool{"DEBUG": true, "INFO": true}
+
+// RateLimitedWriter caps the throughput of the logs written to the underlying writer
+// with a token bucket of bytes, so that a pathological error loop can't fill the
+// disk and take signing down with it. DEBUG and INFO lines exceeding the rate are
+// dropped and counted, while WARN and ERR lines are always written, but still use up
+// the rate. Once lines are written again, a warning with the number of dropped lines
+// is written first.
+// Implements the io.Writer interface.
+type RateLimitedWriter struct {
+	w     io.Writer
+	rate  float64
+	burst float64
+	clock Clock
+
+	mtx          sync.Mutex
+	tokens       float64
+	last         time.Time
+	dropped      uint64 // since the last warning
+	droppedTotal uint64
+	droppedBytes uint64
+}
+
+// RateLimitedWriter must implement the io.Writer interface.
+var _ io.Writer = new(RateLimitedWriter)
+
+// NewRateLimitedWriter returns a new RateLimitedWriter writing up to rate bytes per
+// second with bursts of up to burst bytes to w. The bucket starts out full.
+func NewRateLimitedWriter(w io.Writer, rate, burst int, clock Clock) *RateLimitedWriter {
+	return &RateLimitedWriter{
+		w:      w,
+		rate:   float64(rate),
+		burst:  float64(burst),
+		clock:  clock,
+		tokens: float64(burst),
+		last:   clock.Now(),
+	}
+}
+
+// logLevel returns the level of the given log line, which is tagged like
+// "[INFO] ..." by the SyncLogger.
+func logLevel(line []byte) string {
+	start := bytes.IndexByte(line, '[')
+	if start == -1 {
+		return ""
+	}
+	end := bytes.IndexByte(line[start:], ']')
+	if end == -1 {
+		return ""
+	}
+
+	return string(line[start+1 : start+end])
+}
+
+// Write writes the log line p, unless it's a DEBUG or INFO line exceeding the rate.
+// Dropped lines are reported as written, so that the logger carries on.
+// Implements the io.Writer interface.
+func (rw *RateLimitedWriter) Write(p []byte) (int, error) {
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+
+	// Refill the bucket with the bytes accrued since the last write.
+	now := rw.clock.Now()
+	if elapsed := now.Sub(rw.last).Seconds(); elapsed > 0 {
+		rw.tokens += elapsed * rw.rate
+		if rw.tokens > rw.burst {
+			rw.tokens = rw.burst
+		}
+	}
+	rw.last = now
+
+	if rw.tokens < float64(len(p)) && droppableLevels[logLevel(p)] {
+		rw.dropped++
+		rw.droppedTotal++
+		rw.droppedBytes += uint64(len(p))
+		return len(p), nil
+	}
+
+	// Report the dropped lines before the first line written again. The warning
+	// itself isn't limited, as it's short and written at most once per line.
+	if rw.dropped > 0 {
+		if _, err := fmt.Fprintf(rw.w, "[WARN]  signctrl: Dropped %v DEBUG/INFO log lines exceeding the log rate limit of %v bytes/s\n", rw.dropped, rw.rate); err != nil {
+			return 0, err
+		}
+		rw.dropped = 0
+	}
+
+	// WARN and ERR lines may overdraw the bucket, which delays the next DEBUG and INFO
+	// lines accordingly.
+	rw.tokens -= float64(len(p))
+
+	return rw.w.Write(p)
+}
+
+// Dropped returns the total number of lines and bytes dropped so far.
+func (rw *RateLimitedWriter) Dropped() (lines, n uint64) {
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+
+	return rw.droppedTotal, rw.droppedBytes
+}
+
+// RegisterMetrics registers prometheus counters for the dropped lines and bytes.
+func (rw *RateLimitedWriter) RegisterMetrics() {
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "signctrl_log_lines_dropped_total",
+		Help: "Number of DEBUG and INFO log lines dropped due to the log rate limit.",
+	}, func() float64 {
+		lines, _ := rw.Dropped()
+		return float64(lines)
+	})
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "signctrl_log_bytes_dropped_total",
+		Help: "Number of bytes of DEBUG and INFO log lines dropped due to the log rate limit.",
+	}, func() float64 {
+		_, n := rw.Dropped()
+		return float64(n)
+	})
+}
+
+// JSONWriter writes the lines of a SyncLogger with the JSON format to the underlying
+// writer without their level tags, which are only needed by the level filter and the
+// RateLimitedWriter in front of it. Plain text lines, e.g. the warnings of a
+// RateLimitedWriter, are encoded as JSON as well.
+// Implements the io.Writer interface.
+type JSONWriter struct {
+	w     io.Writer
+	clock Clock
+}
+
+// JSONWriter must implement the io.Writer interface.
+var _ io.Writer = new(JSONWriter)
+
+// NewJSONWriter returns a new JSONWriter writing to w.
+func NewJSONWriter(w io.Writer, clock Clock) *JSONWriter {
+	return &JSONWriter{w: w, clock: clock}
+}
+
+// Write writes the log line p as a JSON object.
+// Implements the io.Writer interface.
+func (jw *JSONWriter) Write(p []byte) (int, error) {
+	level := logLevel(p)
+	line := bytes.TrimSpace(p)
+	if level != "" {
+		line = bytes.TrimSpace(line[bytes.IndexByte(line, ']')+1:])
+	}
+	if !bytes.HasPrefix(line, []byte("{")) {
+		msg := bytes.TrimPrefix(line, []byte(logModule+": "))
+		line = encodeLogLine(jw.clock.Now(), level, string(msg))
+	}
+	if _, err := jw.w.Write(append(line, '\n')); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// LevelFilter filters log lines by their level like logutils.LevelFilter, but its
+// minimum level may be changed while it's in use, e.g. when the configuration is
+// reloaded.
+// Implements the io.Writer interface.
+type LevelFilter struct {
+	mtx    sync.RWMutex
+	filter *logutils.LevelFilter
+}
+
+// LevelFilter must implement the io.Writer interface.
+var _ io.Writer = new(LevelFilter)
+
+// NewLevelFilter returns a new LevelFilter writing the lines of at least the given
+// level to w.
+func NewLevelFilter(min logutils.LogLevel, w io.Writer) *LevelFilter {
+	return &LevelFilter{filter: &logutils.LevelFilter{Levels: LogLevels, MinLevel: min, Writer: w}}
+}
+
+// Write writes the log line p, unless its level is below the minimum level.
+// Implements the io.Writer interface.
+func (f *LevelFilter) Write(p []byte) (int, error) {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	return f.filter.Write(p)
+}
+
+// MinLevel returns the minimum level of the lines that are written.
+func (f *LevelFilter) MinLevel() logutils.LogLevel {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	return f.filter.MinLevel
+}
+
+// SetMinLevel sets the minimum level of the lines that are written.
+func (f *LevelFilter) SetMinLevel(min logutils.LogLevel) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.filter.SetMinLevel(min)
+}
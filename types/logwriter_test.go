@@ -0,0 +1,98 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/logutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogLevel(t *testing.T) {
+	assert.Equal(t, "INFO", logLevel([]byte("[INFO]  signctrl: msg\n")))
+	assert.Equal(t, "ERR", logLevel([]byte("2021/08/01 [ERR]   signctrl: msg\n")))
+	assert.Equal(t, "", logLevel([]byte("no level\n")))
+	assert.Equal(t, "", logLevel([]byte("[unterminated\n")))
+}
+
+func TestRateLimitedWriter(t *testing.T) {
+	var buf bytes.Buffer
+	clock := NewFakeClock(time.Unix(0, 0))
+	rw := NewRateLimitedWriter(&buf, 10, 20, clock)
+	info := []byte("[INFO]  signctrl: x\n") // 20 bytes
+	warn := []byte("[WARN]  signctrl: x\n")
+
+	// The burst is written right away.
+	n, err := rw.Write(info)
+	assert.NoError(t, err)
+	assert.Equal(t, len(info), n)
+	assert.Equal(t, string(info), buf.String())
+
+	// Lines exceeding the rate are dropped, but reported as written.
+	buf.Reset()
+	n, err = rw.Write(info)
+	assert.NoError(t, err)
+	assert.Equal(t, len(info), n)
+	assert.Empty(t, buf.String())
+	lines, size := rw.Dropped()
+	assert.EqualValues(t, 1, lines)
+	assert.EqualValues(t, len(info), size)
+
+	// Warnings are never dropped, but overdraw the bucket.
+	_, err = rw.Write(warn)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "Dropped 1 DEBUG/INFO log lines")
+	assert.Contains(t, buf.String(), string(warn))
+
+	// The bucket refills over time. It's 20 bytes in debt after the warning, so it
+	// takes 4s to afford the next line.
+	buf.Reset()
+	clock.Advance(3 * time.Second)
+	_, _ = rw.Write(info)
+	assert.Empty(t, buf.String())
+	clock.Advance(time.Second)
+	_, _ = rw.Write(info)
+	assert.Equal(t, "[WARN]  signctrl: Dropped 1 DEBUG/INFO log lines exceeding the log rate limit of 10 bytes/s\n"+string(info), buf.String())
+	lines, _ = rw.Dropped()
+	assert.EqualValues(t, 2, lines)
+
+	// The bucket never holds more than the burst.
+	buf.Reset()
+	clock.Advance(time.Hour)
+	_, _ = rw.Write(info)
+	_, _ = rw.Write(info)
+	assert.Equal(t, string(info), buf.String())
+}
+
+func TestJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	jw := NewJSONWriter(&buf, NewFakeClock(time.Unix(0, 0)))
+
+	// The level tag of JSON lines is stripped.
+	line := []byte(`[INFO] {"time":"1970-01-01T00:00:00Z","level":"INFO","module":"signctrl","msg":"x"}` + "\n")
+	n, err := jw.Write(line)
+	assert.NoError(t, err)
+	assert.Equal(t, len(line), n)
+	assert.Equal(t, `{"time":"1970-01-01T00:00:00Z","level":"INFO","module":"signctrl","msg":"x"}`+"\n", buf.String())
+
+	// Plain text lines are encoded.
+	buf.Reset()
+	_, err = jw.Write([]byte("[WARN]  signctrl: Dropped 3 DEBUG/INFO log lines\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"time":"1970-01-01T00:00:00Z","level":"WARN","module":"signctrl","msg":"Dropped 3 DEBUG/INFO log lines"}`+"\n", buf.String())
+}
+
+func TestLevelFilter(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewLevelFilter("INFO", &buf)
+	_, _ = f.Write([]byte("[DEBUG] signctrl: dropped\n"))
+	_, _ = f.Write([]byte("[INFO]  signctrl: kept\n"))
+	assert.Equal(t, "[INFO]  signctrl: kept\n", buf.String())
+
+	buf.Reset()
+	f.SetMinLevel("DEBUG")
+	assert.Equal(t, logutils.LogLevel("DEBUG"), f.MinLevel())
+	_, _ = f.Write([]byte("[DEBUG] signctrl: kept\n"))
+	assert.Equal(t, "[DEBUG] signctrl: kept\n", buf.String())
+}
@@ -1,8 +1,10 @@
 package types
 
 import (
+	"context"
 	"errors"
 	"io/ioutil"
+	"sync"
 )
 
 var (
@@ -43,7 +45,8 @@ started, and stopped.
 Users can override the OnStart/OnStop methods. In the absence of errors, these methods
 are guaranteed to be called at most once. If OnStart returns an error, service won't
 be marked as started, so the user can call Start again.
-The caller must ensure that Start and Stop are not called concurrently.
+Start, Stop and IsRunning are safe to be called concurrently, so a service can stop
+itself while its caller stops it, too.
 It is ok to call Stop without calling Start first.
 
 Typical usage:
@@ -70,17 +73,20 @@ Typical usage:
 	}
 */
 type BaseService struct {
-	Logger  *SyncLogger
+	Logger  Logger
 	name    string
+	mtx     *sync.Mutex // guards running, since services may stop themselves
 	running bool
 	quit    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
 
 	// The "subclass" of BaseService
 	impl Service
 }
 
 // NewBaseService creates a new instance of BaseService.
-func NewBaseService(logger *SyncLogger, name string, impl Service) *BaseService {
+func NewBaseService(logger Logger, name string, impl Service) *BaseService {
 	if logger == nil {
 		logger = NewSyncLogger(ioutil.Discard, "", 0)
 	}
@@ -88,6 +94,7 @@ func NewBaseService(logger *SyncLogger, name string, impl Service) *BaseService
 	return &BaseService{
 		Logger:  logger,
 		name:    name,
+		mtx:     new(sync.Mutex),
 		running: false,
 		impl:    impl,
 	}
@@ -96,6 +103,16 @@ func NewBaseService(logger *SyncLogger, name string, impl Service) *BaseService
 // Start starts a service. An error is returned if the service is already running.
 // Implements the Service interface.
 func (bs *BaseService) Start() error {
+	return bs.StartContext(context.Background())
+}
+
+// StartContext starts a service with a context derived from ctx. The derived context
+// is canceled once the parent context is done or the service is stopped. An error is
+// returned if the service is already running.
+func (bs *BaseService) StartContext(ctx context.Context) error {
+	bs.mtx.Lock()
+	defer bs.mtx.Unlock()
+
 	if bs.running {
 		return ErrAlreadyStarted
 	}
@@ -103,6 +120,8 @@ func (bs *BaseService) Start() error {
 	bs.Logger.Debug("Starting %v service", bs.name)
 	bs.running = true
 	bs.quit = make(chan struct{})
+	bs.ctx, bs.cancel = context.WithCancel(ctx)
+
 	if err := bs.impl.OnStart(); err != nil {
 		return err
 	}
@@ -118,12 +137,18 @@ func (bs *BaseService) OnStart() error { return nil }
 // service is already stopped.
 // Implements the Service interface.
 func (bs *BaseService) Stop() error {
+	bs.mtx.Lock()
+	defer bs.mtx.Unlock()
+
 	if !bs.running {
 		return ErrAlreadyStopped
 	}
 
 	bs.Logger.Debug("Stopping %v service", bs.name)
 	bs.running = false
+	if bs.cancel != nil {
+		bs.cancel()
+	}
 	if err := bs.impl.OnStop(); err != nil {
 		return err
 	}
@@ -140,6 +165,9 @@ func (bs *BaseService) OnStop() error { return nil }
 // or not.
 // Implements the Service interface.
 func (bs *BaseService) IsRunning() bool {
+	bs.mtx.Lock()
+	defer bs.mtx.Unlock()
+
 	return bs.running
 }
 
@@ -155,6 +183,16 @@ func (bs *BaseService) Quit() <-chan struct{} {
 	return bs.quit
 }
 
+// Context returns the service's context which is canceled once the service is
+// stopped. If the service has never been started, context.Background() is returned.
+func (bs *BaseService) Context() context.Context {
+	if bs.ctx == nil {
+		return context.Background()
+	}
+
+	return bs.ctx
+}
+
 // String returns a string representation of the service.
 // Implements the Service interface.
 func (bs *BaseService) String() string {
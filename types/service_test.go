@@ -1,9 +1,11 @@
 package types
 
 import (
+	"context"
 	"testing"
 	"time"
 
+	"github.com/BlockscapeNetwork/signctrl/internal/leaktest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -12,6 +14,8 @@ type testService struct {
 }
 
 func TestStartStopService(t *testing.T) {
+	leaktest.Check(t)
+
 	ts := &testService{}
 	ts.BaseService = *NewBaseService(nil, "TestService", ts)
 
@@ -38,6 +42,8 @@ func TestStartStopService(t *testing.T) {
 }
 
 func TestWait(t *testing.T) {
+	leaktest.Check(t)
+
 	ts := &testService{}
 	ts.BaseService = *NewBaseService(nil, "TestService", ts)
 
@@ -64,6 +70,8 @@ func TestWait(t *testing.T) {
 }
 
 func TestQuit(t *testing.T) {
+	leaktest.Check(t)
+
 	ts := &testService{}
 	ts.BaseService = *NewBaseService(nil, "TestService", ts)
 
@@ -82,3 +90,28 @@ func TestQuit(t *testing.T) {
 		t.Fatal("expected Quit() to finish within 100ms")
 	}
 }
+
+func TestContext(t *testing.T) {
+	leaktest.Check(t)
+
+	ts := &testService{}
+	ts.BaseService = *NewBaseService(nil, "TestService", ts)
+	assert.NoError(t, ts.Context().Err())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := ts.StartContext(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, ts.Context().Err())
+
+	// Canceling the parent context cancels the service's context.
+	cancel()
+	assert.ErrorIs(t, ts.Context().Err(), context.Canceled)
+
+	// Stopping the service cancels its context as well.
+	ts.BaseService = *NewBaseService(nil, "TestService", ts)
+	err = ts.Start()
+	assert.NoError(t, err)
+	err = ts.Stop()
+	assert.NoError(t, err)
+	assert.ErrorIs(t, ts.Context().Err(), context.Canceled)
+}
@@ -1,24 +1,51 @@
 package types
 
 import (
-	"errors"
+	"fmt"
 	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/rank"
 )
 
 var (
 	// ErrThresholdExceeded is returned when the threshold of too many missed blocks in
 	// a row is exceeded.
-	ErrThresholdExceeded = errors.New("threshold exceeded due to too many blocks missed in a row")
+	ErrThresholdExceeded = rank.ErrThresholdExceeded
 
 	// ErrMustShutdown is returned when the current signer (rank 1) beeds to update its
 	// ranks and must be shut down because rank 1 cannot be promoted anymore.
-	ErrMustShutdown = errors.New("node cannot be promoted anymore, so it must be shut down")
+	ErrMustShutdown = rank.ErrMustShutdown
 
 	// ErrCounterLocked is returned when the counter for missed blocks in a row is
 	// still locked due to SignCTRL not having seen a signed block from rank 1.
-	ErrCounterLocked = errors.New("waiting for first commitsig from validator to unlock counter for missed blocks in a row")
+	ErrCounterLocked = rank.ErrCounterLocked
+
+	// ErrCannotDemote is returned when a validator that isn't ranked first is to be
+	// demoted.
+	ErrCannotDemote = rank.ErrCannotDemote
 )
 
+// RankError wraps the errors returned by BaseSignCtrled with the height and rank the
+// validator was at when the error occurred. Use errors.Is to check for the wrapped
+// sentinel error and errors.As to retrieve the context.
+type RankError struct {
+	Height int64
+	Rank   int
+	Err    error
+}
+
+// Error returns the string representation of the error.
+func (e *RankError) Error() string {
+	return fmt.Sprintf("%v (height: %v, rank: %v)", e.Err, e.Height, e.Rank)
+}
+
+// Unwrap returns the wrapped error.
+func (e *RankError) Unwrap() error {
+	return e.Err
+}
+
 // SignCtrled defines the functionality of a SignCTRL PrivValidator that monitors the
 // blockchain for missed blocks in a row and keeps its rank up to date.
 type SignCtrled interface {
@@ -29,34 +56,99 @@ type SignCtrled interface {
 
 	Promote() error
 	OnPromote()
+
+	Demote() error
+	OnDemote()
+}
+
+// Leadership is implemented by leader elections that decide which node in the set
+// signs, instead of the threshold of blocks missed in a row.
+type Leadership interface {
+	IsLeader() bool
 }
 
-// BaseSignCtrled is a base implementation of SignCtrled.
+// BaseSignCtrled is a base implementation of SignCtrled. The rank logic itself is
+// implemented by the state machine in the rank package, while BaseSignCtrled logs
+// the transitions and calls back into its implementation.
+//
+// All methods are safe for concurrent use, so that the status, metrics and admin
+// endpoints can read the rank state while the run loop updates it. Each transition
+// and each setter is applied atomically, and the getters never observe a transition
+// halfway through. The transitions are logged and the implementation is called back
+// after the state was released, so callbacks may use the getters and setters.
+// Sequences of separate calls, e.g. GetRank followed by SetRank, are not atomic.
 type BaseSignCtrled struct {
-	Logger        *SyncLogger
-	counterLocked bool
-	currentHeight int64
-	missedInARow  int
-	threshold     int
-	rank          int
+	Logger Logger
+
+	mtx        sync.RWMutex // guards state and leadership
+	state      rank.State
+	leadership Leadership
 
 	impl SignCtrled
 }
 
 // NewBaseSignCtrled creates a new instance of BaseSignCtrled.
-func NewBaseSignCtrled(logger *SyncLogger, threshold int, rank int, impl SignCtrled) *BaseSignCtrled {
+func NewBaseSignCtrled(logger Logger, threshold int, startRank int, impl SignCtrled) *BaseSignCtrled {
 	if logger == nil {
 		logger = NewSyncLogger(ioutil.Discard, "", 0)
 	}
 
 	return &BaseSignCtrled{
-		Logger:        logger,
-		counterLocked: true,
-		currentHeight: 1,
-		threshold:     threshold,
-		rank:          rank,
-		impl:          impl,
+		Logger: logger,
+		state:  rank.NewState(threshold, startRank),
+		impl:   impl,
+	}
+}
+
+// apply applies the given event to the validator's rank state, logs the effects of
+// the transition and calls back into the implementation where needed.
+func (bsc *BaseSignCtrled) apply(e rank.Event) error {
+	bsc.mtx.Lock()
+	t := rank.Next(bsc.state, e)
+	bsc.state = t.To
+	bsc.mtx.Unlock()
+
+	for _, effect := range t.Effects {
+		switch effect {
+		case rank.EffectMissed:
+			bsc.Logger.Info("Missed a block (%v/%v)", t.To.MissedInARow, t.To.Threshold)
+		case rank.EffectMissedTooMany:
+			if t.From.MissedInARow+1 < t.From.Threshold {
+				bsc.Logger.Info("Missed blocks for too long (%v/%v)", t.From.BlockTime.Sub(t.From.LastSigned), t.From.ThresholdDuration)
+			} else {
+				bsc.Logger.Info("Missed too many blocks in a row (%v/%v)", t.From.MissedInARow+1, t.To.Threshold)
+			}
+			if bsc.impl != nil {
+				bsc.impl.OnMissedTooMany()
+			}
+		case rank.EffectPromoted:
+			bsc.Logger.Info("Promote validator (%v -> %v)", t.From.Rank, t.To.Rank)
+			if bsc.impl != nil {
+				bsc.impl.OnPromote()
+			}
+		case rank.EffectDemoted:
+			bsc.Logger.Info("Demote validator (%v -> %v)", t.From.Rank, t.To.Rank)
+			if bsc.impl != nil {
+				bsc.impl.OnDemote()
+			}
+		case rank.EffectReset:
+			bsc.Logger.Debug("Reset counter for missed blocks in a row")
+		case rank.EffectLocked:
+			bsc.Logger.Info("Looking for first commitsig from validator after reconnect, stop counting missed blocks in a row...")
+		case rank.EffectUnlocked:
+			bsc.Logger.Info("Found first commitsig from validator since fully synced, start counting missed blocks in a row...")
+		}
+	}
+
+	if t.Err != nil {
+		return &RankError{
+			Height: t.From.Height,
+			Rank:   t.From.Rank,
+			Err:    t.Err,
+		}
 	}
+
+	return nil
 }
 
 // LockCounter locks the counter for missed blocks in a row.
@@ -64,10 +156,7 @@ func NewBaseSignCtrled(logger *SyncLogger, threshold int, rank int, impl SignCtr
 // validators in the set if they are started up in incorrect order, and if a reconnect
 // takes place.
 func (bsc *BaseSignCtrled) LockCounter() {
-	if !bsc.counterLocked {
-		bsc.Logger.Info("Looking for first commitsig from validator after reconnect, stop counting missed blocks in a row...")
-		bsc.counterLocked = true
-	}
+	_ = bsc.apply(rank.EventLock)
 }
 
 // UnlockCounter unlocks the counter for missed blocks in a row.
@@ -75,41 +164,157 @@ func (bsc *BaseSignCtrled) LockCounter() {
 // validators in the set if they are started up in incorrect order, and if a reconnect
 // takes place.
 func (bsc *BaseSignCtrled) UnlockCounter() {
-	if bsc.counterLocked {
-		bsc.Logger.Info("Found first commitsig from validator since fully synced, start counting missed blocks in a row...")
-		bsc.counterLocked = false
-	}
+	_ = bsc.apply(rank.EventUnlock)
+}
+
+// IsCounterLocked returns true if the counter for missed blocks in a row is locked.
+func (bsc *BaseSignCtrled) IsCounterLocked() bool {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+
+	return bsc.state.CounterLocked
 }
 
 // GetCurrentHeight returns the validator's current height.
 func (bsc *BaseSignCtrled) GetCurrentHeight() int64 {
-	return bsc.currentHeight
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+
+	return bsc.state.Height
 }
 
 // SetCurrentHeight sets the current height to the given value.
 func (bsc *BaseSignCtrled) SetCurrentHeight(height int64) {
-	bsc.currentHeight = height
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+
+	bsc.state.Height = height
 }
 
 // GetThreshold returns the threshold of blocks missed in a row that trigger a rank
 // update.
 func (bsc *BaseSignCtrled) GetThreshold() int {
-	return bsc.threshold
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+
+	return bsc.state.Threshold
+}
+
+// SetThreshold sets the threshold of blocks missed in a row that trigger a rank
+// update, e.g. after the configuration was reloaded.
+func (bsc *BaseSignCtrled) SetThreshold(threshold int) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+
+	bsc.state.Threshold = threshold
+}
+
+// SetSetSize sets the number of validators in the set, which is the rank the
+// validator is demoted to.
+func (bsc *BaseSignCtrled) SetSetSize(setSize int) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+
+	bsc.state.SetSize = setSize
+}
+
+// SetCurrentBlockTime sets the timestamp of the current block, which the threshold
+// duration is measured by.
+func (bsc *BaseSignCtrled) SetCurrentBlockTime(t time.Time) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+
+	bsc.state.BlockTime = t
+}
+
+// GetThresholdDuration returns the time without a signed block that triggers a rank
+// update, or 0 if only the threshold of blocks missed in a row triggers one.
+func (bsc *BaseSignCtrled) GetThresholdDuration() time.Duration {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+
+	return bsc.state.ThresholdDuration
+}
+
+// SetThresholdDuration sets the time without a signed block that triggers a rank
+// update along with the threshold of blocks missed in a row. It is disabled if 0.
+func (bsc *BaseSignCtrled) SetThresholdDuration(d time.Duration) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+
+	bsc.state.ThresholdDuration = d
+}
+
+// GetMissedFor returns the time without a signed block as of the current block
+// timestamp.
+func (bsc *BaseSignCtrled) GetMissedFor() time.Duration {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+
+	return bsc.state.MissedFor()
 }
 
 // GetMissedInARow returns the number of blocks missed in a row.
 func (bsc *BaseSignCtrled) GetMissedInARow() int {
-	return bsc.missedInARow
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+
+	return bsc.state.MissedInARow
 }
 
-// GetRank returns the validators current rank.
+// SetLeadership makes the given leader election decide the validator's rank: it is
+// ranked first while it is the leader and second otherwise. Missed blocks aren't
+// counted anymore, and Promote only syncs the rank with the leadership.
+func (bsc *BaseSignCtrled) SetLeadership(l Leadership) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+
+	bsc.leadership = l
+	bsc.state.Rank = leadershipRank(l)
+}
+
+// leadershipRank returns the rank decided by the given leadership.
+func leadershipRank(l Leadership) int {
+	if l.IsLeader() {
+		return 1
+	}
+
+	return 2
+}
+
+// GetRank returns the validators current rank. If the rank is decided by a leader
+// election, the leadership is checked on every call, so that a leader stops signing
+// as soon as it loses the leadership.
 func (bsc *BaseSignCtrled) GetRank() int {
-	return bsc.rank
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+
+	if bsc.leadership != nil {
+		return leadershipRank(bsc.leadership)
+	}
+
+	return bsc.state.Rank
 }
 
 // SetRank sets the validator's rank to the given rank.
 func (bsc *BaseSignCtrled) SetRank(rank int) {
-	bsc.rank = rank
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+
+	bsc.state.Rank = rank
+}
+
+// GetRankState returns a copy of the validator's rank state.
+func (bsc *BaseSignCtrled) GetRankState() rank.State {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+
+	state := bsc.state
+	if bsc.leadership != nil {
+		state.Rank = leadershipRank(bsc.leadership)
+	}
+
+	return state
 }
 
 // Missed updates the counter for missed blocks in a row. Errors are returned if...
@@ -118,33 +323,18 @@ func (bsc *BaseSignCtrled) SetRank(rank int) {
 // 2) the validator's promotion fails
 // 3) the counter for missed blocks in a row is still locked
 //
+// If the rank is decided by a leader election, missed blocks aren't counted and the
+// rank is only synced with the leadership.
 // Implements the SignCtrled interface.
 func (bsc *BaseSignCtrled) Missed() error {
-	if bsc.counterLocked {
-		return ErrCounterLocked
-	}
-
-	bsc.missedInARow++
-	if bsc.missedInARow < bsc.threshold {
-		bsc.Logger.Info("Missed a block (%v/%v)", bsc.missedInARow, bsc.threshold)
-	} else if bsc.missedInARow == bsc.threshold {
-		bsc.Logger.Info("Missed too many blocks in a row (%v/%v)", bsc.missedInARow, bsc.threshold)
-		bsc.OnMissedTooMany()
-		if err := bsc.Promote(); err != nil {
-			return err
-		}
-
-		// When a rank update due to ErrThresholdExceeded is triggered, it is expected
-		// that the next block will not contain the validator's signature. This is due
-		// to a block containing the commit of the previous height which we know wasn't
-		// signed. Therefore, skip ahead.
-		// This is also the reason why the minimum threshold for blocks missed in a row
-		// is at 2.
-		bsc.currentHeight++
-		return ErrThresholdExceeded
+	bsc.mtx.RLock()
+	elected := bsc.leadership != nil
+	bsc.mtx.RUnlock()
+	if elected {
+		return bsc.Promote()
 	}
 
-	return nil
+	return bsc.apply(rank.EventMissed)
 }
 
 // OnMissedTooMany does nothing. This way, users don't need to call BaseSignCtrled.OnMissedTooMany().
@@ -154,26 +344,37 @@ func (bsc *BaseSignCtrled) OnMissedTooMany() {}
 // Reset resets the counter for missed blocks in a row to 0.
 // Implements the SignCtrled interface.
 func (bsc *BaseSignCtrled) Reset() {
-	if bsc.missedInARow > 0 {
-		bsc.Logger.Debug("Reset counter for missed blocks in a row")
-		bsc.missedInARow = 0
-	}
+	_ = bsc.apply(rank.EventReset)
 }
 
 // Promote moves the validator up one rank. An error is returned if the validator
 // cannot be promoted anymore and it has to be shut down consequently.
 // This method is only supposed to be called from within the Missed method and never
 // on its own.
+//
+// If the rank is decided by a leader election, Promote syncs the rank with the
+// leadership instead, and may be called whenever the leadership changed.
 // Implements the SignCtrled interface.
 func (bsc *BaseSignCtrled) Promote() error {
-	if bsc.rank == 1 {
-		return ErrMustShutdown
+	bsc.mtx.Lock()
+	if bsc.leadership == nil {
+		bsc.mtx.Unlock()
+		return bsc.apply(rank.EventPromote)
 	}
+	from := bsc.state.Rank
+	bsc.state.Rank = leadershipRank(bsc.leadership)
+	to := bsc.state.Rank
+	bsc.mtx.Unlock()
 
-	bsc.Logger.Info("Promote validator (%v -> %v)", bsc.rank, bsc.rank-1)
-	bsc.rank--
-	bsc.Reset()
-	bsc.OnPromote()
+	switch {
+	case to < from:
+		bsc.Logger.Info("Promote validator (%v -> %v)", from, to)
+		if bsc.impl != nil {
+			bsc.impl.OnPromote()
+		}
+	case to > from:
+		bsc.Logger.Info("Lost the leadership, demote validator (%v -> %v)", from, to)
+	}
 
 	return nil
 }
@@ -181,3 +382,16 @@ func (bsc *BaseSignCtrled) Promote() error {
 // OnPromote does nothing. This way, users don't have to call BaseSignCtrled.OnPromote().
 // Implements the SignCtrled interface.
 func (bsc *BaseSignCtrled) OnPromote() {}
+
+// Demote moves the validator from rank 1 to the last rank of the set, so that it
+// keeps running as a backup after it exceeded the threshold instead of being shut
+// down. The counter for missed blocks in a row is locked until the new signer's first
+// commitsig is found. An error is returned if the validator isn't ranked first.
+// Implements the SignCtrled interface.
+func (bsc *BaseSignCtrled) Demote() error {
+	return bsc.apply(rank.EventDemote)
+}
+
+// OnDemote does nothing. This way, users don't have to call BaseSignCtrled.OnDemote().
+// Implements the SignCtrled interface.
+func (bsc *BaseSignCtrled) OnDemote() {}
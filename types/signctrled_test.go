@@ -1,7 +1,10 @@
 package types
 
 import (
+	"errors"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -22,7 +25,7 @@ func TestMissed(t *testing.T) {
 
 	sc.LockCounter()
 	err = sc.Missed()
-	assert.ErrorIs(t, ErrCounterLocked, err)
+	assert.ErrorIs(t, err, ErrCounterLocked)
 	assert.Equal(t, 1, sc.GetMissedInARow())
 	assert.Equal(t, 1, sc.GetRank())
 }
@@ -33,7 +36,7 @@ func TestThresholdExceeded(t *testing.T) {
 
 	sc.UnlockCounter()
 	err := sc.Missed()
-	assert.ErrorIs(t, ErrThresholdExceeded, err)
+	assert.ErrorIs(t, err, ErrThresholdExceeded)
 	assert.Equal(t, 0, sc.GetMissedInARow())
 	assert.Equal(t, 1, sc.GetRank())
 }
@@ -41,7 +44,7 @@ func TestThresholdExceeded(t *testing.T) {
 func TestReset(t *testing.T) {
 	sc := &testSignCtrled{}
 	sc.BaseSignCtrled = *NewBaseSignCtrled(nil, 2, 1, sc)
-	sc.missedInARow = 1
+	sc.state.MissedInARow = 1
 
 	sc.UnlockCounter()
 	sc.Reset()
@@ -54,5 +57,171 @@ func TestPromote(t *testing.T) {
 
 	sc.UnlockCounter()
 	err := sc.Missed()
-	assert.ErrorIs(t, ErrMustShutdown, err)
+	assert.ErrorIs(t, err, ErrMustShutdown)
+}
+
+func TestRankError(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *NewBaseSignCtrled(nil, 1, 1, sc)
+	sc.SetCurrentHeight(42)
+
+	sc.UnlockCounter()
+	err := sc.Missed()
+	assert.ErrorIs(t, err, ErrMustShutdown)
+
+	var rankErr *RankError
+	assert.True(t, errors.As(err, &rankErr))
+	assert.Equal(t, int64(42), rankErr.Height)
+	assert.Equal(t, 1, rankErr.Rank)
+}
+
+type testCallbackSignCtrled struct {
+	BaseSignCtrled
+	missedTooMany int
+	promoted      int
+	demoted       int
+}
+
+func (sc *testCallbackSignCtrled) OnMissedTooMany() { sc.missedTooMany++ }
+
+// OnPromote reads the new rank, which must not deadlock.
+func (sc *testCallbackSignCtrled) OnPromote() {
+	if sc.GetRank() < 2 {
+		sc.promoted++
+	}
+}
+
+func TestCallbacks(t *testing.T) {
+	sc := &testCallbackSignCtrled{}
+	sc.BaseSignCtrled = *NewBaseSignCtrled(nil, 2, 2, sc)
+
+	sc.UnlockCounter()
+	assert.NoError(t, sc.Missed())
+	assert.ErrorIs(t, sc.Missed(), ErrThresholdExceeded)
+	assert.Equal(t, 1, sc.missedTooMany)
+	assert.Equal(t, 1, sc.promoted)
+	assert.Equal(t, 1, sc.GetRankState().Rank)
+	assert.False(t, sc.IsCounterLocked())
+}
+
+func (sc *testCallbackSignCtrled) OnDemote() { sc.demoted++ }
+
+func TestDemote(t *testing.T) {
+	sc := &testCallbackSignCtrled{}
+	sc.BaseSignCtrled = *NewBaseSignCtrled(nil, 2, 1, sc)
+
+	// The set size is unknown.
+	assert.ErrorIs(t, sc.Demote(), ErrCannotDemote)
+
+	sc.SetSetSize(3)
+	sc.UnlockCounter()
+	assert.NoError(t, sc.Missed())
+	assert.ErrorIs(t, sc.Missed(), ErrMustShutdown)
+	assert.NoError(t, sc.Demote())
+	assert.Equal(t, 1, sc.demoted)
+	assert.Equal(t, 3, sc.GetRank())
+	assert.Zero(t, sc.GetMissedInARow())
+	assert.True(t, sc.IsCounterLocked())
+
+	// Only rank 1 can be demoted.
+	assert.ErrorIs(t, sc.Demote(), ErrCannotDemote)
+	assert.Equal(t, 1, sc.demoted)
+}
+
+func TestThresholdDuration(t *testing.T) {
+	sc := &testCallbackSignCtrled{}
+	sc.BaseSignCtrled = *NewBaseSignCtrled(nil, 100, 2, sc)
+	sc.SetThresholdDuration(time.Minute)
+	assert.Equal(t, time.Minute, sc.GetThresholdDuration())
+
+	// Rank 1 signed the last block.
+	t0 := time.Unix(1000, 0)
+	sc.SetCurrentBlockTime(t0)
+	sc.Reset()
+	sc.UnlockCounter()
+
+	// A slow chain reaches the threshold duration long before the threshold.
+	sc.SetCurrentBlockTime(t0.Add(30 * time.Second))
+	assert.NoError(t, sc.Missed())
+	assert.Equal(t, 30*time.Second, sc.GetMissedFor())
+	sc.SetCurrentBlockTime(t0.Add(time.Minute))
+	assert.ErrorIs(t, sc.Missed(), ErrThresholdExceeded)
+	assert.Equal(t, 1, sc.promoted)
+	assert.Equal(t, 1, sc.GetRank())
+	assert.Equal(t, time.Duration(0), sc.GetMissedFor())
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *NewBaseSignCtrled(nil, 1000, 1, sc)
+	sc.UnlockCounter()
+
+	// The run loop updates the state while the status endpoint reads it.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			sc.SetCurrentHeight(int64(i))
+			_ = sc.Missed()
+			sc.Reset()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			state := sc.GetRankState()
+			assert.LessOrEqual(t, state.MissedInARow, 1)
+			_ = sc.GetRank()
+			_ = sc.IsCounterLocked()
+		}
+	}()
+	wg.Wait()
+	assert.Equal(t, 0, sc.GetMissedInARow())
+}
+
+func TestSetThreshold(t *testing.T) {
+	sc := &testCallbackSignCtrled{}
+	sc.BaseSignCtrled = *NewBaseSignCtrled(nil, 3, 2, sc)
+	sc.SetThreshold(5)
+	assert.Equal(t, 5, sc.GetThreshold())
+}
+
+type testLeadership struct {
+	leader bool
+}
+
+func (l *testLeadership) IsLeader() bool { return l.leader }
+
+func TestLeadership(t *testing.T) {
+	sc := &testCallbackSignCtrled{}
+	sc.BaseSignCtrled = *NewBaseSignCtrled(nil, 2, 1, sc)
+	l := &testLeadership{}
+
+	// The start rank is overridden by the leadership.
+	sc.SetLeadership(l)
+	assert.Equal(t, 2, sc.GetRank())
+
+	// Missed blocks aren't counted.
+	sc.UnlockCounter()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, sc.Missed())
+	}
+	assert.Zero(t, sc.GetMissedInARow())
+	assert.Zero(t, sc.missedTooMany)
+	assert.Equal(t, 2, sc.GetRank())
+
+	// The leadership is observed right away, while the callback is only called
+	// once the rank is synced.
+	l.leader = true
+	assert.Equal(t, 1, sc.GetRank())
+	assert.Zero(t, sc.promoted)
+	assert.NoError(t, sc.Promote())
+	assert.Equal(t, 1, sc.promoted)
+	assert.Equal(t, 1, sc.GetRankState().Rank)
+
+	l.leader = false
+	assert.Equal(t, 2, sc.GetRank())
+	assert.NoError(t, sc.Promote())
+	assert.Equal(t, 1, sc.promoted)
 }
@@ -0,0 +1,58 @@
+package watchtower
+
+import (
+	"sync"
+)
+
+// EventLog buffers the most recent events for monitors to poll.
+type EventLog struct {
+	mtx     sync.Mutex
+	events  []Event
+	next    int
+	lastSeq uint64
+}
+
+// NewEventLog creates a new event log buffering the n most recent events.
+func NewEventLog(n int) *EventLog {
+	return &EventLog{events: make([]Event, 0, n)}
+}
+
+// Add assigns the next sequence number to e, adds it to the log and returns it. The
+// oldest event is dropped if the log is full.
+func (l *EventLog) Add(e Event) Event {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.lastSeq++
+	e.Seq = l.lastSeq
+	if len(l.events) < cap(l.events) {
+		l.events = append(l.events, e)
+		return e
+	}
+	l.events[l.next] = e
+	l.next = (l.next + 1) % len(l.events)
+
+	return e
+}
+
+// Since returns the buffered events with sequence numbers greater than seq, oldest
+// first, along with the sequence number of the last event. It returns true if events
+// after seq were already dropped from the log.
+func (l *EventLog) Since(seq uint64) ([]Event, uint64, bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	ordered := append(append([]Event{}, l.events[l.next:]...), l.events[:l.next]...)
+	events := []Event{}
+	for _, e := range ordered {
+		if e.Seq > seq {
+			events = append(events, e)
+		}
+	}
+
+	// Events were dropped if the oldest buffered event isn't the one directly after
+	// seq.
+	truncated := seq < l.lastSeq && (len(ordered) == 0 || ordered[0].Seq > seq+1)
+
+	return events, l.lastSeq, truncated
+}
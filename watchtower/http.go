@@ -0,0 +1,153 @@
+package watchtower
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrRequestFailed is returned by the Client if the integration API responded
+	// with an error.
+	ErrRequestFailed = errors.New("integration API request failed")
+)
+
+// handler serves the integration API.
+type handler struct {
+	status func() Status
+	events *EventLog
+}
+
+// NewHandler creates a new HTTP handler serving the /api/v1/status and /api/v1/events
+// endpoints. The status is taken from the given function, the events from the given
+// event log.
+func NewHandler(status func() Status, events *EventLog) http.Handler {
+	h := &handler{status: status, events: events}
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathPrefix+"/status", h.statusHandler)
+	mux.HandleFunc(PathPrefix+"/events", h.eventsHandler)
+	mux.HandleFunc("/", func(rw http.ResponseWriter, r *http.Request) {
+		writeError(rw, http.StatusNotFound, fmt.Errorf("unknown endpoint %v", r.URL.Path))
+	})
+
+	return mux
+}
+
+// statusHandler serves the node's status.
+func (h *handler) statusHandler(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	status := h.status()
+	status.APIVersion = Version
+	writeJSON(rw, http.StatusOK, status)
+}
+
+// eventsHandler serves the events after the sequence number in the since parameter,
+// or all buffered events if it is omitted.
+func (h *handler) eventsHandler(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	var since uint64
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = strconv.ParseUint(s, 10, 64); err != nil {
+			writeError(rw, http.StatusBadRequest, fmt.Errorf("invalid since parameter: %v", s))
+			return
+		}
+	}
+
+	events, lastSeq, truncated := h.events.Since(since)
+	writeJSON(rw, http.StatusOK, EventsResponse{
+		APIVersion: Version,
+		Events:     events,
+		LastSeq:    lastSeq,
+		Truncated:  truncated,
+	})
+}
+
+// writeJSON writes v as the JSON response body with the given status code.
+func writeJSON(rw http.ResponseWriter, code int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	_ = json.NewEncoder(rw).Encode(v)
+}
+
+// writeError writes err as the JSON response body with the given status code.
+func writeError(rw http.ResponseWriter, code int, err error) {
+	writeJSON(rw, code, ErrorResponse{APIVersion: Version, Error: err.Error()})
+}
+
+// Client queries the integration API of a SignCTRL node.
+type Client struct {
+	// Address is the address of the node's HTTP server, e.g. http://127.0.0.1:8080.
+	Address string
+
+	// HTTP is the HTTP client used for the requests. If nil, http.DefaultClient is
+	// used.
+	HTTP *http.Client
+}
+
+// NewClient creates a new client for the integration API of the node at the given
+// address.
+func NewClient(address string) *Client {
+	return &Client{Address: strings.TrimSuffix(address, "/")}
+}
+
+// Status queries the node's status.
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.get(ctx, "/status", &status); err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// Events queries the events after the given sequence number. Pass the LastSeq of the
+// previous response to poll for new events.
+func (c *Client) Events(ctx context.Context, since uint64) (*EventsResponse, error) {
+	var events EventsResponse
+	if err := c.get(ctx, fmt.Sprintf("/events?since=%v", since), &events); err != nil {
+		return nil, err
+	}
+
+	return &events, nil
+}
+
+// get queries the given endpoint of the integration API and decodes the response
+// into v.
+func (c *Client) get(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.Address+PathPrefix+endpoint, nil)
+	if err != nil {
+		return err
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Error == "" {
+			return fmt.Errorf("%w: %v", ErrRequestFailed, resp.Status)
+		}
+		return fmt.Errorf("%w: %v: %v", ErrRequestFailed, resp.Status, errResp.Error)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
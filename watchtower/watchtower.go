@@ -0,0 +1,248 @@
+// Package watchtower defines SignCTRL's integration API for external monitoring tools
+// like tenderduty. The API is versioned: within a version, fields and event types are
+// only ever added, never renamed, removed or changed in meaning, so that monitors
+// written against it keep working across SignCTRL releases.
+package watchtower
+
+import (
+	"time"
+)
+
+const (
+	// Version is the version of the integration API.
+	Version = "v1"
+
+	// PathPrefix is the path prefix of all endpoints of the integration API.
+	PathPrefix = "/api/" + Version
+)
+
+// Status is the response JSON of the /api/v1/status endpoint.
+type Status struct {
+	// APIVersion is the version of the integration API, i.e. Version.
+	APIVersion string `json:"api_version"`
+
+	// ChainID is the ID of the chain the node signs for.
+	ChainID string `json:"chain_id"`
+
+	// Address is the hex-encoded consensus address of the validator's key.
+	Address string `json:"address"`
+
+	// Running is true if the node is running.
+	Running bool `json:"running"`
+
+	// Connected is true if the node handles the validator's requests. It is false
+	// while the node is still waiting for the validator.
+	Connected bool `json:"connected"`
+
+	// Signing is true if the node is running, connected to the validator, ranked first
+	// in the set and allowed to sign, i.e. it hasn't crashed and the validator isn't
+	// tombstoned.
+	Signing bool `json:"signing"`
+
+	// Height is the height of the last sign request the node received.
+	Height int64 `json:"height"`
+
+	// Rank is the node's rank in the set, starting at 1.
+	Rank int `json:"rank"`
+
+	// SetSize is the number of nodes in the set.
+	SetSize int `json:"set_size"`
+
+	// MissedInARow is the number of blocks missed in a row by the validator.
+	MissedInARow int `json:"missed_in_a_row"`
+
+	// Threshold is the number of blocks that must be missed in a row for the node to
+	// be promoted.
+	Threshold int `json:"threshold"`
+
+	// Crashed is true if the node recovered from a panic and refuses to sign.
+	Crashed bool `json:"crashed"`
+
+	// Jailed is true if the slashing module reported the validator as jailed.
+	Jailed bool `json:"jailed"`
+
+	// Tombstoned is true if the slashing module reported the validator as tombstoned.
+	Tombstoned bool `json:"tombstoned"`
+
+	// ValidatorStale is true if the validator stopped advancing while the network
+	// kept going.
+	ValidatorStale bool `json:"validator_stale"`
+}
+
+// EventType is the type of an Event. Monitors must ignore event types they don't
+// know, as new ones may be added within a version.
+type EventType string
+
+const (
+	// EventStarted is emitted once the node has started.
+	EventStarted EventType = "started"
+
+	// EventStopped is emitted when the node stops.
+	EventStopped EventType = "stopped"
+
+	// EventMissedBlock is emitted for every block the validator missed.
+	EventMissedBlock EventType = "missed_block"
+
+	// EventMissedTooMany is emitted if the validator missed too many blocks in a row.
+	EventMissedTooMany EventType = "missed_too_many"
+
+	// EventPromoted is emitted if the node was promoted to the next higher rank.
+	EventPromoted EventType = "promoted"
+
+	// EventDemoted is emitted if the node lost rank 1, either because it exceeded the
+	// threshold and moved to the last rank, or lost the set's leader election.
+	EventDemoted EventType = "demoted"
+
+	// EventCrashed is emitted if the node recovered from a panic.
+	EventCrashed EventType = "crashed"
+
+	// EventSignerSwapped is emitted if the signer backend was swapped at runtime.
+	EventSignerSwapped EventType = "signer_swapped"
+
+	// EventNotInValidatorSet is emitted if the validator's key is not part of the
+	// chain's active validator set.
+	EventNotInValidatorSet EventType = "not_in_validator_set"
+
+	// EventJailed is emitted if the slashing module reported the validator as jailed.
+	EventJailed EventType = "jailed"
+
+	// EventUnjailed is emitted if the validator is no longer jailed.
+	EventUnjailed EventType = "unjailed"
+
+	// EventTombstoned is emitted if the slashing module reported the validator as
+	// tombstoned.
+	EventTombstoned EventType = "tombstoned"
+
+	// EventSigningPaused is emitted if signing was paused because the validator is
+	// jailed.
+	EventSigningPaused EventType = "signing_paused"
+
+	// EventSigningResumed is emitted if paused signing was resumed after the
+	// validator was unjailed.
+	EventSigningResumed EventType = "signing_resumed"
+
+	// EventMaintenanceStarted is emitted if a maintenance window was started via the
+	// admin API.
+	EventMaintenanceStarted EventType = "maintenance_started"
+
+	// EventMaintenanceEnded is emitted if a maintenance window was ended via the admin
+	// API.
+	EventMaintenanceEnded EventType = "maintenance_ended"
+
+	// EventProposalPending is emitted if a proposal is held until it is approved.
+	EventProposalPending EventType = "proposal_pending"
+
+	// EventProposalRejected is emitted if a held proposal was rejected or wasn't
+	// approved in time.
+	EventProposalRejected EventType = "proposal_rejected"
+
+	// EventStalled is emitted if the watchdog detected a goroutine that stopped
+	// making progress.
+	EventStalled EventType = "stalled"
+
+	// EventBadSignature is emitted if a signature produced by the signer backend
+	// didn't verify and was refused.
+	EventBadSignature EventType = "bad_signature"
+
+	// EventValidatorStale is emitted if the validator stopped advancing while the
+	// network kept going.
+	EventValidatorStale EventType = "validator_stale"
+
+	// EventValidatorRecovered is emitted if a stale validator is advancing again.
+	EventValidatorRecovered EventType = "validator_recovered"
+
+	// EventClockSkewed is emitted if the local clock is off by more than max_offset,
+	// so that the node refuses to be promoted.
+	EventClockSkewed EventType = "clock_skewed"
+
+	// EventClockRecovered is emitted if a skewed clock is back in sync.
+	EventClockRecovered EventType = "clock_recovered"
+
+	// EventRankConflict is emitted if a live peer in the set reports the same rank as
+	// the node.
+	EventRankConflict EventType = "rank_conflict"
+
+	// EventMustShutdown is emitted if the node shuts down because it was replaced by
+	// the next rank or its rank became obsolete.
+	EventMustShutdown EventType = "must_shutdown"
+
+	// EventDisconnected is emitted if the node hasn't been connected to the validator
+	// for longer than the disconnect timeout.
+	EventDisconnected EventType = "disconnected"
+
+	// EventReconnected is emitted if the node is connected to the validator again
+	// after it was reported as disconnected.
+	EventReconnected EventType = "reconnected"
+
+	// EventLockLost is emitted if the node lost the signing lock on rank 1, so that it
+	// stopped signing.
+	EventLockLost EventType = "lock_lost"
+)
+
+// eventTypes are all known event types.
+var eventTypes = map[EventType]bool{
+	EventStarted: true, EventStopped: true, EventMissedBlock: true, EventMissedTooMany: true,
+	EventPromoted: true, EventDemoted: true, EventCrashed: true, EventSignerSwapped: true,
+	EventNotInValidatorSet: true, EventJailed: true, EventUnjailed: true, EventTombstoned: true,
+	EventSigningPaused: true, EventSigningResumed: true, EventMaintenanceStarted: true,
+	EventMaintenanceEnded: true, EventProposalPending: true, EventProposalRejected: true,
+	EventStalled: true, EventBadSignature: true, EventValidatorStale: true,
+	EventValidatorRecovered: true, EventClockSkewed: true, EventClockRecovered: true,
+	EventRankConflict: true, EventMustShutdown: true, EventDisconnected: true,
+	EventReconnected: true, EventLockLost: true,
+}
+
+// Known returns true if t is one of the event types defined in this version.
+func (t EventType) Known() bool {
+	return eventTypes[t]
+}
+
+// Event is something that happened to the node that is relevant to monitors.
+type Event struct {
+	// Seq is the event's sequence number. Sequence numbers start at 1 and increase
+	// by 1 for every event. They start over if the node is restarted.
+	Seq uint64 `json:"seq"`
+
+	// Time is the time the event occurred at.
+	Time time.Time `json:"time"`
+
+	// Type is the type of the event.
+	Type EventType `json:"type"`
+
+	// Height is the height of the last sign request the node received when the event
+	// occurred.
+	Height int64 `json:"height"`
+
+	// Rank is the node's rank when the event occurred.
+	Rank int `json:"rank"`
+
+	// Message is a human-readable description of the event. Its wording may change
+	// at any time, so monitors must not parse it.
+	Message string `json:"message,omitempty"`
+}
+
+// EventsResponse is the response JSON of the /api/v1/events endpoint.
+type EventsResponse struct {
+	// APIVersion is the version of the integration API, i.e. Version.
+	APIVersion string `json:"api_version"`
+
+	// Events are the events after the requested sequence number, oldest first.
+	Events []Event `json:"events"`
+
+	// LastSeq is the sequence number of the last event that occurred. It is the since
+	// parameter to poll for new events with.
+	LastSeq uint64 `json:"last_seq"`
+
+	// Truncated is true if some of the events after the requested sequence number
+	// are no longer buffered and were dropped from the response.
+	Truncated bool `json:"truncated"`
+}
+
+// ErrorResponse is the response JSON of all endpoints if a request failed.
+type ErrorResponse struct {
+	// APIVersion is the version of the integration API, i.e. Version.
+	APIVersion string `json:"api_version"`
+
+	// Error describes why the request failed.
+	Error string `json:"error"`
+}
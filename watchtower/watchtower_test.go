@@ -0,0 +1,168 @@
+package watchtower
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The JSON schema of v1 must never change in a backwards-incompatible way. Fields may
+// only be added to these golden documents.
+func TestSchema_Status(t *testing.T) {
+	bytes, err := json.Marshal(Status{
+		APIVersion:   Version,
+		ChainID:      "testchain",
+		Address:      "ABCD",
+		Running:      true,
+		Signing:      true,
+		Height:       10,
+		Rank:         1,
+		SetSize:      2,
+		MissedInARow: 1,
+		Threshold:    3,
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"api_version": "v1",
+		"chain_id": "testchain",
+		"address": "ABCD",
+		"running": true,
+		"connected": false,
+		"signing": true,
+		"height": 10,
+		"rank": 1,
+		"set_size": 2,
+		"missed_in_a_row": 1,
+		"threshold": 3,
+		"crashed": false,
+		"jailed": false,
+		"tombstoned": false,
+		"validator_stale": false
+	}`, string(bytes))
+}
+
+func TestSchema_Events(t *testing.T) {
+	bytes, err := json.Marshal(EventsResponse{
+		APIVersion: Version,
+		Events: []Event{{
+			Seq:     1,
+			Time:    time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+			Type:    EventPromoted,
+			Height:  10,
+			Rank:    1,
+			Message: "Promoted to rank 1",
+		}},
+		LastSeq: 1,
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"api_version": "v1",
+		"events": [{
+			"seq": 1,
+			"time": "2021-03-04T05:06:07Z",
+			"type": "promoted",
+			"height": 10,
+			"rank": 1,
+			"message": "Promoted to rank 1"
+		}],
+		"last_seq": 1,
+		"truncated": false
+	}`, string(bytes))
+}
+
+func TestEventType_Known(t *testing.T) {
+	assert.True(t, EventPromoted.Known())
+	assert.True(t, EventDisconnected.Known())
+	assert.False(t, EventType("promotion").Known())
+}
+
+func TestEventLog(t *testing.T) {
+	l := NewEventLog(3)
+	events, lastSeq, truncated := l.Since(0)
+	assert.Empty(t, events)
+	assert.Equal(t, uint64(0), lastSeq)
+	assert.False(t, truncated)
+
+	for i := 0; i < 2; i++ {
+		l.Add(Event{Type: EventMissedBlock})
+	}
+	events, lastSeq, truncated = l.Since(0)
+	assert.Len(t, events, 2)
+	assert.Equal(t, uint64(2), lastSeq)
+	assert.False(t, truncated)
+
+	// Only the events after the given sequence number are returned.
+	events, _, _ = l.Since(1)
+	assert.Len(t, events, 1)
+	assert.Equal(t, uint64(2), events[0].Seq)
+
+	// The oldest events are dropped once the log is full.
+	for i := 0; i < 3; i++ {
+		l.Add(Event{Type: EventMissedBlock})
+	}
+	events, lastSeq, truncated = l.Since(1)
+	assert.Equal(t, uint64(5), lastSeq)
+	assert.True(t, truncated)
+	assert.Equal(t, []uint64{3, 4, 5}, []uint64{events[0].Seq, events[1].Seq, events[2].Seq})
+
+	events, _, truncated = l.Since(2)
+	assert.Len(t, events, 3)
+	assert.False(t, truncated)
+
+	events, _, truncated = l.Since(5)
+	assert.Empty(t, events)
+	assert.False(t, truncated)
+}
+
+func TestClient(t *testing.T) {
+	events := NewEventLog(10)
+	events.Add(Event{Type: EventStarted, Rank: 2})
+	events.Add(Event{Type: EventPromoted, Rank: 1})
+	server := httptest.NewServer(NewHandler(func() Status {
+		return Status{ChainID: "testchain", Rank: 1}
+	}, events))
+	defer server.Close()
+	c := NewClient(server.URL + "/")
+
+	status, err := c.Status(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, Version, status.APIVersion)
+	assert.Equal(t, "testchain", status.ChainID)
+
+	res, err := c.Events(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), res.LastSeq)
+	assert.Len(t, res.Events, 1)
+	assert.Equal(t, EventPromoted, res.Events[0].Type)
+
+	res, err = c.Events(context.Background(), res.LastSeq)
+	assert.NoError(t, err)
+	assert.NotNil(t, res.Events)
+	assert.Empty(t, res.Events)
+}
+
+func TestHandler_Errors(t *testing.T) {
+	server := httptest.NewServer(NewHandler(func() Status { return Status{} }, NewEventLog(1)))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + PathPrefix + "/events?since=-1")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Post(server.URL+PathPrefix+"/status", "application/json", nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	// Unknown endpoints and versions are reported as JSON errors.
+	err = NewClient(server.URL).get(context.Background(), "/unknown", nil)
+	assert.True(t, errors.Is(err, ErrRequestFailed))
+	assert.Contains(t, err.Error(), "unknown endpoint")
+}